@@ -59,6 +59,7 @@ type AgentDTO struct {
 	SummaryMemory   string
 	LastConnectedAt string
 	DeviceCount     int32
+	OnlineCount     int64 // 当前在线设备数
 }
 
 // AgentPluginMapping 插件映射
@@ -184,7 +185,9 @@ type AgentUsecase struct {
 	configUsecase   *ConfigUsecase
 	modelUsecase    *ModelUsecase
 	ttsVoiceUsecase *TtsVoiceUsecase
+	deviceUsecase   *DeviceUsecase
 	redisClient     *kit.RedisClient
+	configNotifier  *ConfigNotifier
 	handleError     *cerrors.HandleError
 	log             *log.Helper
 }
@@ -195,7 +198,9 @@ func NewAgentUsecase(
 	configUsecase *ConfigUsecase,
 	modelUsecase *ModelUsecase,
 	ttsVoiceUsecase *TtsVoiceUsecase,
+	deviceUsecase *DeviceUsecase,
 	redisClient *kit.RedisClient,
+	configNotifier *ConfigNotifier,
 	logger log.Logger,
 ) *AgentUsecase {
 	return &AgentUsecase{
@@ -203,7 +208,9 @@ func NewAgentUsecase(
 		configUsecase:   configUsecase,
 		modelUsecase:    modelUsecase,
 		ttsVoiceUsecase: ttsVoiceUsecase,
+		deviceUsecase:   deviceUsecase,
 		redisClient:     redisClient,
+		configNotifier:  configNotifier,
 		handleError:     cerrors.NewHandleError(logger),
 		log:             kit.LogHelper(logger),
 	}
@@ -260,6 +267,11 @@ func (uc *AgentUsecase) ListUserAgents(ctx context.Context, userId int64) ([]*Ag
 		if lastTime, err := uc.repo.GetLatestLastConnectionTimeByAgentID(ctx, agent.ID); err == nil && lastTime != nil {
 			agent.LastConnectedAt = lastTime.Format("2006-01-02 15:04:05")
 		}
+
+		// 获取在线设备数
+		if onlineCount, err := uc.deviceUsecase.CountOnlineDevicesByAgent(ctx, agent.ID); err == nil {
+			agent.OnlineCount = onlineCount
+		}
 	}
 
 	return agents, nil
@@ -348,7 +360,12 @@ func (uc *AgentUsecase) UpdateAgent(ctx context.Context, agent *Agent) error {
 		existing.Updater = agent.Updater
 	}
 
-	return uc.repo.UpdateAgent(ctx, existing)
+	if err := uc.repo.UpdateAgent(ctx, existing); err != nil {
+		return err
+	}
+	uc.configNotifier.NotifyAgent(ctx, existing.ID, "agent")
+
+	return nil
 }
 
 // UpdateAgentMemoryByMacAddress 根据设备更新智能体记忆
@@ -379,7 +396,12 @@ func (uc *AgentUsecase) DeleteAgent(ctx context.Context, id string) error {
 	// TODO: 删除关联的插件映射（需要 AgentPluginMappingService）
 
 	// 删除智能体
-	return uc.repo.DeleteAgent(ctx, id)
+	if err := uc.repo.DeleteAgent(ctx, id); err != nil {
+		return err
+	}
+	uc.configNotifier.NotifyAgent(ctx, id, "agent")
+
+	return nil
 }
 
 // GetAgentTemplateList 获取模板列表
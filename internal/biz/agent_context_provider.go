@@ -35,20 +35,23 @@ type AgentContextProviderRepo interface {
 
 // AgentContextProviderUsecase 智能体上下文源配置业务逻辑
 type AgentContextProviderUsecase struct {
-	repo        AgentContextProviderRepo
-	handleError *cerrors.HandleError
-	log         *log.Helper
+	repo           AgentContextProviderRepo
+	configNotifier *ConfigNotifier
+	handleError    *cerrors.HandleError
+	log            *log.Helper
 }
 
 // NewAgentContextProviderUsecase 创建智能体上下文源配置用例
 func NewAgentContextProviderUsecase(
 	repo AgentContextProviderRepo,
+	configNotifier *ConfigNotifier,
 	logger log.Logger,
 ) *AgentContextProviderUsecase {
 	return &AgentContextProviderUsecase{
-		repo:        repo,
-		handleError: cerrors.NewHandleError(logger),
-		log:         log.NewHelper(log.With(logger, "module", "agent-matrix-service/biz/agent_context_provider")),
+		repo:           repo,
+		configNotifier: configNotifier,
+		handleError:    cerrors.NewHandleError(logger),
+		log:            log.NewHelper(log.With(logger, "module", "agent-matrix-service/biz/agent_context_provider")),
 	}
 }
 
@@ -65,7 +68,11 @@ func (uc *AgentContextProviderUsecase) SaveOrUpdateByAgentId(ctx context.Context
 		Updater:          userId,
 		UpdatedAt:        time.Now(),
 	}
-	return uc.repo.SaveOrUpdateByAgentId(ctx, entity)
+	if err := uc.repo.SaveOrUpdateByAgentId(ctx, entity); err != nil {
+		return err
+	}
+	uc.configNotifier.NotifyAgent(ctx, agentId, "context_provider")
+	return nil
 }
 
 // DeleteByAgentId 根据智能体ID删除上下文源配置
@@ -0,0 +1,262 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+	"github.com/weetime/agent-matrix/internal/kit/cerrors"
+	"github.com/weetime/agent-matrix/internal/middleware"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// 智能体知识库检索默认参数，RAG模型配置中未设置时使用
+const (
+	agentRAGDefaultSimilarityThreshold    = 0.2
+	agentRAGDefaultVectorSimilarityWeight = 0.3
+	agentRAGDefaultTopN                   = 5
+	agentRAGMaxTopN                       = 50
+)
+
+// AgentDataset 智能体与知识库的绑定关系
+type AgentDataset struct {
+	ID        string
+	AgentID   string
+	DatasetID string // RAG侧数据集ID
+	Sort      int32
+	Creator   int64
+	CreatedAt time.Time
+}
+
+// AgentRetrievalChunk 智能体知识库检索命中的切片
+type AgentRetrievalChunk struct {
+	ID           string
+	Content      string
+	DocumentID   string
+	DocumentName string
+	DatasetID    string
+	DatasetName  string
+	Similarity   float64
+}
+
+// AgentDatasetRepo 智能体知识库绑定数据访问接口
+type AgentDatasetRepo interface {
+	// ListByAgentId 按排序返回智能体绑定的知识库
+	ListByAgentId(ctx context.Context, agentId string) ([]*AgentDataset, error)
+	// ReplaceByAgentId 用给定的知识库列表替换智能体的全部绑定
+	ReplaceByAgentId(ctx context.Context, agentId string, datasetIds []string, userId int64) error
+	DeleteByAgentId(ctx context.Context, agentId string) error
+}
+
+// AgentDatasetUsecase 智能体知识库绑定业务逻辑
+type AgentDatasetUsecase struct {
+	repo              AgentDatasetRepo
+	datasetRepo       DatasetRepo
+	modelConfigRepo   ModelConfigRepo
+	ragAdapterFactory RAGAdapterFactory
+	configNotifier    *ConfigNotifier
+	handleError       *cerrors.HandleError
+	log               *log.Helper
+}
+
+// NewAgentDatasetUsecase 创建智能体知识库绑定用例
+func NewAgentDatasetUsecase(
+	repo AgentDatasetRepo,
+	datasetRepo DatasetRepo,
+	modelConfigRepo ModelConfigRepo,
+	ragAdapterFactory RAGAdapterFactory,
+	configNotifier *ConfigNotifier,
+	logger log.Logger,
+) *AgentDatasetUsecase {
+	return &AgentDatasetUsecase{
+		repo:              repo,
+		datasetRepo:       datasetRepo,
+		modelConfigRepo:   modelConfigRepo,
+		ragAdapterFactory: ragAdapterFactory,
+		configNotifier:    configNotifier,
+		handleError:       cerrors.NewHandleError(logger),
+		log:               log.NewHelper(log.With(logger, "module", "agent-matrix-service/biz/agent_dataset")),
+	}
+}
+
+// GetDatasetsByAgentId 获取智能体绑定的知识库，已删除的知识库会被忽略
+func (uc *AgentDatasetUsecase) GetDatasetsByAgentId(ctx context.Context, agentId string) ([]*Dataset, error) {
+	bindings, err := uc.repo.ListByAgentId(ctx, agentId)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	datasets := make([]*Dataset, 0, len(bindings))
+	for _, binding := range bindings {
+		dataset, err := uc.datasetRepo.GetByDatasetID(ctx, binding.DatasetID)
+		if err != nil {
+			return nil, uc.handleError.ErrInternal(ctx, err)
+		}
+		if dataset == nil {
+			uc.log.Warnf("智能体绑定的知识库不存在, agentId: %s, datasetId: %s", agentId, binding.DatasetID)
+			continue
+		}
+		datasets = append(datasets, dataset)
+	}
+
+	return datasets, nil
+}
+
+// SaveByAgentId 保存智能体绑定的知识库，datasetIds为空时解绑全部知识库
+// 只能绑定自己创建的知识库，已绑定的知识库可以保留，拥有全部权限的用户不受限制
+func (uc *AgentDatasetUsecase) SaveByAgentId(ctx context.Context, agentId string, datasetIds []string, userId int64) error {
+	datasetIds = kit.Uniq(datasetIds)
+	bindings, err := uc.repo.ListByAgentId(ctx, agentId)
+	if err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	bound := make(map[string]bool, len(bindings))
+	for _, binding := range bindings {
+		bound[binding.DatasetID] = true
+	}
+	allowAll := middleware.HasPermission(ctx, middleware.PermissionAll)
+
+	for _, datasetId := range datasetIds {
+		dataset, err := uc.datasetRepo.GetByDatasetID(ctx, datasetId)
+		if err != nil {
+			return uc.handleError.ErrInternal(ctx, err)
+		}
+		if dataset == nil {
+			return uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("知识库不存在: %s", datasetId))
+		}
+		if dataset.Creator != userId && !bound[datasetId] && !allowAll {
+			return uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("无权限操作此知识库: %s", datasetId))
+		}
+	}
+
+	if err := uc.repo.ReplaceByAgentId(ctx, agentId, datasetIds, userId); err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	uc.configNotifier.NotifyAgent(ctx, agentId, "dataset")
+	return nil
+}
+
+// DeleteByAgentId 删除智能体的全部知识库绑定
+func (uc *AgentDatasetUsecase) DeleteByAgentId(ctx context.Context, agentId string) error {
+	return uc.repo.DeleteByAgentId(ctx, agentId)
+}
+
+// Retrieve 在智能体绑定的知识库中检索问题，按相似度从高到低返回最多topN个切片
+// topN<=0时使用默认值，similarityThreshold为nil时使用RAG模型配置或默认阈值
+func (uc *AgentDatasetUsecase) Retrieve(ctx context.Context, agentId, question string, topN int, similarityThreshold *float64) ([]*AgentRetrievalChunk, error) {
+	if topN <= 0 {
+		topN = agentRAGDefaultTopN
+	}
+	topN = min(topN, agentRAGMaxTopN)
+
+	datasets, err := uc.GetDatasetsByAgentId(ctx, agentId)
+	if err != nil {
+		return nil, err
+	}
+
+	// 按RAG模型分组，同一模型的知识库一次检索
+	modelIds := make([]string, 0)
+	datasetIds := make(map[string][]string)
+	datasetNames := make(map[string]string, len(datasets))
+	for _, dataset := range datasets {
+		if dataset.RagModelID == "" {
+			continue
+		}
+		if _, ok := datasetIds[dataset.RagModelID]; !ok {
+			modelIds = append(modelIds, dataset.RagModelID)
+		}
+		datasetIds[dataset.RagModelID] = append(datasetIds[dataset.RagModelID], dataset.DatasetID)
+		datasetNames[dataset.DatasetID] = dataset.Name
+	}
+
+	chunks := make([]*AgentRetrievalChunk, 0)
+	var lastErr error
+	for _, modelId := range modelIds {
+		modelChunks, err := uc.retrieveFromModel(ctx, modelId, datasetIds[modelId], question, topN, similarityThreshold)
+		if err != nil {
+			uc.log.Warnf("知识库检索失败, agentId: %s, ragModelId: %s, error: %v", agentId, modelId, err)
+			lastErr = err
+			continue
+		}
+		chunks = append(chunks, modelChunks...)
+	}
+	if len(chunks) == 0 && lastErr != nil {
+		return nil, uc.handleError.ErrInternal(ctx, fmt.Errorf("知识库检索失败: %w", lastErr))
+	}
+
+	sort.SliceStable(chunks, func(i, j int) bool { return chunks[i].Similarity > chunks[j].Similarity })
+	if len(chunks) > topN {
+		chunks = chunks[:topN]
+	}
+	for _, chunk := range chunks {
+		chunk.DatasetName = datasetNames[chunk.DatasetID]
+	}
+
+	return chunks, nil
+}
+
+// retrieveFromModel 通过RAG模型对应的适配器检索知识库，过滤低于相似度阈值的切片
+func (uc *AgentDatasetUsecase) retrieveFromModel(ctx context.Context, ragModelId string, datasetIds []string, question string, topN int, similarityThreshold *float64) ([]*AgentRetrievalChunk, error) {
+	ragConfig, err := loadRAGConfig(ctx, uc.modelConfigRepo, ragModelId)
+	if err != nil {
+		return nil, err
+	}
+	adapter, err := uc.ragAdapterFactory.GetAdapter(ragAdapterType(ragConfig), ragConfig)
+	if err != nil {
+		return nil, fmt.Errorf("获取RAG适配器失败: %w", err)
+	}
+
+	threshold := agentRAGDefaultSimilarityThreshold
+	if value, ok := paramFloat(ragConfig["similarity_threshold"]); ok {
+		threshold = value
+	}
+	if similarityThreshold != nil {
+		threshold = *similarityThreshold
+	}
+	weight := agentRAGDefaultVectorSimilarityWeight
+	if value, ok := paramFloat(ragConfig["vector_similarity_weight"]); ok {
+		weight = value
+	}
+
+	result, err := adapter.RetrievalTest(ctx, map[string]interface{}{
+		"question":               question,
+		"datasetIds":             datasetIds,
+		"page":                   1,
+		"pageSize":               topN,
+		"similarityThreshold":    threshold,
+		"vectorSimilarityWeight": weight,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	items, _ := result["chunks"].([]interface{})
+	chunks := make([]*AgentRetrievalChunk, 0, len(items))
+	for _, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		similarity, _ := paramFloat(fields["similarity"])
+		if similarity < threshold {
+			continue
+		}
+		chunk := &AgentRetrievalChunk{
+			Similarity: similarity,
+		}
+		chunk.ID, _ = fields["id"].(string)
+		chunk.Content, _ = fields["content"].(string)
+		chunk.DocumentID, _ = fields["document_id"].(string)
+		chunk.DocumentName, _ = fields["document_keyword"].(string)
+		chunk.DatasetID, _ = fields["dataset_id"].(string)
+		if chunk.DatasetID == "" {
+			chunk.DatasetID, _ = fields["kb_id"].(string)
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks, nil
+}
@@ -0,0 +1,176 @@
+package biz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/weetime/agent-matrix/internal/kit/cerrors"
+	"github.com/weetime/agent-matrix/internal/middleware"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentDatasetRetrieve(t *testing.T) {
+	datasets := map[string]*Dataset{
+		"ds1": {ID: "1", DatasetID: "ds1", RagModelID: "RAG_a", Name: "产品手册"},
+		"ds2": {ID: "2", DatasetID: "ds2", RagModelID: "RAG_b", Name: "售后政策"},
+		"ds3": {ID: "3", DatasetID: "ds3", RagModelID: "RAG_a", Name: "常见问题"},
+	}
+	modelConfigs := &stubModelConfigRepo{configs: map[string]*ModelConfig{
+		"RAG_a": {ID: "RAG_a", ConfigJSON: `{"type":"stub","model":"RAG_a","similarity_threshold":0.5}`},
+		"RAG_b": {ID: "RAG_b", ConfigJSON: `{"type":"stub","model":"RAG_b"}`},
+	}}
+	factory := &stubRAGAdapterFactory{results: map[string][]interface{}{
+		"RAG_a": {
+			map[string]interface{}{"id": "c1", "content": "保修一年", "document_id": "d1", "document_keyword": "manual.pdf", "dataset_id": "ds1", "similarity": 0.9},
+			map[string]interface{}{"id": "c2", "content": "无关内容", "document_id": "d2", "document_keyword": "faq.md", "dataset_id": "ds3", "similarity": 0.4},
+		},
+		"RAG_b": {
+			map[string]interface{}{"id": "c3", "content": "七天无理由退货", "document_id": "d3", "document_keyword": "policy.txt", "kb_id": "ds2", "similarity": 0.7},
+		},
+	}}
+	uc := NewAgentDatasetUsecase(
+		&stubAgentDatasetRepo{bindings: map[string][]string{"agent1": {"ds1", "ds2", "ds3", "missing"}}},
+		&stubDatasetRepo{datasets: datasets},
+		modelConfigs,
+		factory,
+		nil,
+		log.DefaultLogger,
+	)
+	low := 0.1
+
+	tests := []struct {
+		name      string
+		agentId   string
+		topK      int
+		threshold *float64
+		wantIds   []string
+	}{
+		{name: "按各RAG模型的相似度阈值过滤并合并排序", agentId: "agent1", wantIds: []string{"c1", "c3"}},
+		{name: "指定阈值和返回数量", agentId: "agent1", topK: 1, threshold: &low, wantIds: []string{"c1"}},
+		{name: "未绑定知识库", agentId: "agent2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := uc.Retrieve(context.Background(), tt.agentId, "保修多久", tt.topK, tt.threshold)
+			require.NoError(t, err)
+			var ids []string
+			for _, chunk := range chunks {
+				ids = append(ids, chunk.ID)
+			}
+			require.Equal(t, tt.wantIds, ids)
+		})
+	}
+
+	chunks, err := uc.Retrieve(context.Background(), "agent1", "保修多久", 0, nil)
+	require.NoError(t, err)
+	require.Equal(t, "manual.pdf", chunks[0].DocumentName)
+	require.Equal(t, "产品手册", chunks[0].DatasetName)
+	require.Equal(t, "售后政策", chunks[1].DatasetName)
+	require.ElementsMatch(t, []string{"ds1", "ds3"}, factory.datasetIds["RAG_a"])
+}
+
+func TestAgentDatasetSaveOwnership(t *testing.T) {
+	datasets := map[string]*Dataset{
+		"mine":   {DatasetID: "mine", Creator: 1},
+		"others": {DatasetID: "others", Creator: 2},
+		"bound":  {DatasetID: "bound", Creator: 2},
+	}
+	admin := context.WithValue(context.Background(), middleware.UserDetailKey, &middleware.UserDetail{ID: 3, Permissions: []string{middleware.PermissionAll}})
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		userId     int64
+		datasetIds []string
+		wantErr    bool
+	}{
+		{name: "绑定自己的知识库", ctx: context.Background(), userId: 1, datasetIds: []string{"mine"}},
+		{name: "不能绑定他人的知识库", ctx: context.Background(), userId: 1, datasetIds: []string{"mine", "others"}, wantErr: true},
+		{name: "已绑定的他人知识库可以保留", ctx: context.Background(), userId: 1, datasetIds: []string{"bound", "mine"}},
+		{name: "拥有全部权限时不受限制", ctx: admin, userId: 3, datasetIds: []string{"others"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &stubAgentDatasetRepo{bindings: map[string][]string{"agent1": {"bound"}}}
+			uc := NewAgentDatasetUsecase(repo, &stubDatasetRepo{datasets: datasets}, nil, nil, nil, log.DefaultLogger)
+			err := uc.SaveByAgentId(tt.ctx, "agent1", tt.datasetIds, tt.userId)
+			if tt.wantErr {
+				require.True(t, cerrors.IsPermissionDenied(err))
+				require.Equal(t, []string{"bound"}, repo.bindings["agent1"])
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.datasetIds, repo.bindings["agent1"])
+		})
+	}
+}
+
+// stubAgentDatasetRepo 返回固定的智能体知识库绑定，并记录保存结果
+type stubAgentDatasetRepo struct {
+	bindings map[string][]string
+}
+
+func (r *stubAgentDatasetRepo) ListByAgentId(ctx context.Context, agentId string) ([]*AgentDataset, error) {
+	result := make([]*AgentDataset, 0, len(r.bindings[agentId]))
+	for i, datasetId := range r.bindings[agentId] {
+		result = append(result, &AgentDataset{AgentID: agentId, DatasetID: datasetId, Sort: int32(i)})
+	}
+	return result, nil
+}
+
+func (r *stubAgentDatasetRepo) ReplaceByAgentId(ctx context.Context, agentId string, datasetIds []string, userId int64) error {
+	r.bindings[agentId] = datasetIds
+	return nil
+}
+
+func (r *stubAgentDatasetRepo) DeleteByAgentId(ctx context.Context, agentId string) error {
+	delete(r.bindings, agentId)
+	return nil
+}
+
+// stubDatasetRepo 仅实现GetByDatasetID的知识库存储
+type stubDatasetRepo struct {
+	DatasetRepo
+	datasets map[string]*Dataset
+}
+
+func (r *stubDatasetRepo) GetByDatasetID(ctx context.Context, datasetId string) (*Dataset, error) {
+	return r.datasets[datasetId], nil
+}
+
+// stubRAGAdapterFactory 按RAG模型返回固定检索结果，并记录每个模型检索的知识库
+type stubRAGAdapterFactory struct {
+	results    map[string][]interface{}
+	datasetIds map[string][]string
+}
+
+func (f *stubRAGAdapterFactory) GetAdapter(adapterType string, config map[string]interface{}) (RAGAdapter, error) {
+	modelId, _ := config["model"].(string)
+	return &stubRetrievalAdapter{factory: f, modelId: modelId}, nil
+}
+
+type stubRetrievalAdapter struct {
+	RAGAdapter
+	factory *stubRAGAdapterFactory
+	modelId string
+}
+
+func (a *stubRetrievalAdapter) RetrievalTest(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	if a.factory.datasetIds == nil {
+		a.factory.datasetIds = make(map[string][]string)
+	}
+	a.factory.datasetIds[a.modelId] = params["datasetIds"].([]string)
+	return map[string]interface{}{"chunks": a.factory.results[a.modelId]}, nil
+}
+
+// stubModelConfigRepo 仅实现GetModelConfigByIDRaw的模型配置存储
+type stubModelConfigRepo struct {
+	ModelConfigRepo
+	configs map[string]*ModelConfig
+}
+
+func (r *stubModelConfigRepo) GetModelConfigByIDRaw(ctx context.Context, id string) (*ModelConfig, error) {
+	return r.configs[id], nil
+}
@@ -7,9 +7,11 @@ import (
 // ProviderSet is server providers.
 var ProviderSet = wire.NewSet(
 	NewApiKeyUsecase,
+	NewConfigNotifier,
 	NewConfigUsecase,
 	NewAgentUsecase,
 	NewUserUsecase,
+	NewRoleUsecase,
 	NewUserTokenService,
 	NewParamsServiceAdapter,
 	NewCaptchaServiceAdapter,
@@ -21,9 +23,11 @@ var ProviderSet = wire.NewSet(
 	NewTtsVoiceUsecase,
 	NewAgentVoicePrintUsecase,
 	NewAgentContextProviderUsecase,
+	NewAgentDatasetUsecase,
 	NewVoiceCloneUsecase,
 	NewDatasetUsecase,
 	NewRAGAdapterFactory,
 	NewDocumentUsecase,
 	NewOtaUsecase,
+	NewNodeUsecase,
 )
@@ -41,9 +41,11 @@ type ConfigRepo interface {
 	TotalSysParams(ctx context.Context, params *ListSysParamsParams) (int, error)
 	// 兼容旧接口
 	PageSysParams(ctx context.Context, page, limit int32, paramCode string) ([]*SysParam, int, error)
+	// GetSysParamsByID 只返回非系统参数（param_type = 1），系统参数按不存在处理
 	GetSysParamsByID(ctx context.Context, id int64) (*SysParam, error)
 	CreateSysParams(ctx context.Context, param *SysParam) (*SysParam, error)
 	UpdateSysParams(ctx context.Context, param *SysParam) error
+	// DeleteSysParams 只删除非系统参数
 	DeleteSysParams(ctx context.Context, ids []int64) error
 	GetSysParamsByCode(ctx context.Context, paramCode string) (*SysParam, error)
 }
@@ -58,7 +60,9 @@ type ConfigUsecase struct {
 	voiceCloneUsecase *VoiceCloneUsecase
 	voicePrintRepo    AgentVoicePrintRepo
 	contextProviderUc *AgentContextProviderUsecase
+	agentDatasetUc    *AgentDatasetUsecase
 	redisClient       *kit.RedisClient
+	configNotifier    *ConfigNotifier
 	handleError       *cerrors.HandleError
 	log               *log.Helper
 }
@@ -73,7 +77,9 @@ func NewConfigUsecase(
 	voiceCloneUsecase *VoiceCloneUsecase,
 	voicePrintRepo AgentVoicePrintRepo,
 	contextProviderUc *AgentContextProviderUsecase,
+	agentDatasetUc *AgentDatasetUsecase,
 	redisClient *kit.RedisClient,
+	configNotifier *ConfigNotifier,
 	logger log.Logger,
 ) *ConfigUsecase {
 	return &ConfigUsecase{
@@ -85,7 +91,9 @@ func NewConfigUsecase(
 		voiceCloneUsecase: voiceCloneUsecase,
 		voicePrintRepo:    voicePrintRepo,
 		contextProviderUc: contextProviderUc,
+		agentDatasetUc:    agentDatasetUc,
 		redisClient:       redisClient,
+		configNotifier:    configNotifier,
 		handleError:       cerrors.NewHandleError(logger),
 		log:               kit.LogHelper(logger),
 	}
@@ -359,7 +367,15 @@ func (uc *ConfigUsecase) CreateSysParams(ctx context.Context, param *SysParam) (
 		param.ParamType = 1 // 默认为非系统参数
 	}
 
-	return uc.repo.CreateSysParams(ctx, param)
+	created, err := uc.repo.CreateSysParams(ctx, param)
+	if err != nil {
+		return nil, err
+	}
+
+	// 清除配置缓存并通知节点
+	uc.ClearConfigCache(ctx)
+
+	return created, nil
 }
 
 // UpdateSysParams 更新参数
@@ -388,10 +404,8 @@ func (uc *ConfigUsecase) UpdateSysParams(ctx context.Context, param *SysParam) e
 		return err
 	}
 
-	// 清除配置缓存
-	if uc.redisClient != nil {
-		uc.redisClient.Delete(ctx, kit.RedisKeyServerConfig)
-	}
+	// 清除配置缓存并通知节点
+	uc.ClearConfigCache(ctx)
 
 	return nil
 }
@@ -406,10 +420,8 @@ func (uc *ConfigUsecase) DeleteSysParams(ctx context.Context, ids []int64) error
 		return err
 	}
 
-	// 清除配置缓存
-	if uc.redisClient != nil {
-		uc.redisClient.Delete(ctx, kit.RedisKeyServerConfig)
-	}
+	// 清除配置缓存并通知节点
+	uc.ClearConfigCache(ctx)
 
 	return nil
 }
@@ -580,11 +592,35 @@ func (uc *ConfigUsecase) ValidateParamValue(ctx context.Context, paramCode, para
 	return uc.validateParamValue(ctx, paramCode, paramValue)
 }
 
-// ClearConfigCache 清除配置缓存
+// SetValue 设置参数值，参数不存在时创建为不下发给节点的参数（param_type为0）
+func (uc *ConfigUsecase) SetValue(ctx context.Context, paramCode, paramValue, remark string) error {
+	existing, _ := uc.repo.GetSysParamsByCode(ctx, paramCode)
+	if existing == nil {
+		_, err := uc.repo.CreateSysParams(ctx, &SysParam{
+			ParamCode:  paramCode,
+			ParamValue: paramValue,
+			ValueType:  "string",
+			Remark:     remark,
+		})
+		return err
+	}
+
+	existing.ParamValue = paramValue
+	if err := uc.repo.UpdateSysParams(ctx, existing); err != nil {
+		return err
+	}
+	if existing.ParamType == 1 {
+		uc.ClearConfigCache(ctx)
+	}
+	return nil
+}
+
+// ClearConfigCache 清除配置缓存，并通知所有节点重新拉取全局配置
 func (uc *ConfigUsecase) ClearConfigCache(ctx context.Context) {
 	if uc.redisClient != nil {
 		uc.redisClient.Delete(ctx, kit.RedisKeyServerConfig)
 	}
+	uc.configNotifier.NotifyGlobal(ctx, "server_config")
 }
 
 // GetValue 获取参数值
@@ -884,6 +920,13 @@ func (uc *ConfigUsecase) buildModuleConfigForAgent(
 ) error {
 	selectedModule := make(map[string]interface{})
 
+	// 智能体绑定的知识库按RAG模型分组，第一个知识库的RAG模型作为选中的RAG模块
+	ragModelIds, ragDatasetIds := uc.getAgentRAGDatasets(ctx, agent.ID)
+	ragModelId := ""
+	if len(ragModelIds) > 0 {
+		ragModelId = ragModelIds[0]
+	}
+
 	// 定义模型类型和对应的模型ID
 	modelTypes := []string{"VAD", "ASR", "TTS", "Memory", "Intent", "LLM", "VLLM", "RAG"}
 	modelIds := []string{
@@ -894,7 +937,7 @@ func (uc *ConfigUsecase) buildModuleConfigForAgent(
 		agent.IntentModelID,
 		agent.LLMModelID,
 		agent.VLLMModelID,
-		"", // RAG 模型ID（暂时为空）
+		ragModelId,
 	}
 
 	var intentLLMModelId string
@@ -1028,6 +1071,20 @@ func (uc *ConfigUsecase) buildModuleConfigForAgent(
 			}
 		}
 
+		// RAG 特殊处理：注入知识库和检索参数，绑定了多个RAG模型的知识库时附加其余模型
+		if modelType == "RAG" {
+			applyAgentRAGParams(configJSON, agent.ID, ragDatasetIds[model.ID])
+			for _, extraModelId := range ragModelIds[1:] {
+				extraConfig, err := loadRAGConfig(ctx, uc.modelRepo, extraModelId)
+				if err != nil {
+					uc.log.Warn("Failed to load RAG model config", "modelId", extraModelId, "error", err)
+					continue
+				}
+				applyAgentRAGParams(extraConfig, agent.ID, ragDatasetIds[extraModelId])
+				typeConfig[extraModelId] = extraConfig
+			}
+		}
+
 		// 将类型配置添加到结果中
 		if existing, ok := result[modelType].(map[string]interface{}); ok {
 			// 如果已存在，合并配置
@@ -1061,6 +1118,51 @@ func (uc *ConfigUsecase) buildModuleConfigForAgent(
 	return nil
 }
 
+// getAgentRAGDatasets 获取智能体绑定的知识库，按RAG模型分组
+// 返回RAG模型ID（按知识库绑定顺序）及每个模型下的数据集ID
+func (uc *ConfigUsecase) getAgentRAGDatasets(ctx context.Context, agentId string) ([]string, map[string][]string) {
+	if uc.agentDatasetUc == nil {
+		return nil, nil
+	}
+
+	datasets, err := uc.agentDatasetUc.GetDatasetsByAgentId(ctx, agentId)
+	if err != nil {
+		uc.log.Warn("获取智能体知识库失败", "agentId", agentId, "error", err)
+		return nil, nil
+	}
+
+	modelIds := make([]string, 0)
+	datasetIds := make(map[string][]string)
+	for _, dataset := range datasets {
+		if dataset.RagModelID == "" {
+			continue
+		}
+		if _, ok := datasetIds[dataset.RagModelID]; !ok {
+			modelIds = append(modelIds, dataset.RagModelID)
+		}
+		datasetIds[dataset.RagModelID] = append(datasetIds[dataset.RagModelID], dataset.DatasetID)
+	}
+	return modelIds, datasetIds
+}
+
+// applyAgentRAGParams 在RAG模型配置中写入知识库ID和检索参数，模型配置中已设置的检索参数优先
+// 语音服务通过retrieve_path代理检索，不下发RAG服务的api_key
+func applyAgentRAGParams(configJSON map[string]interface{}, agentId string, datasetIds []string) {
+	delete(configJSON, "api_key")
+	configJSON["type"] = ragAdapterType(configJSON)
+	configJSON["dataset_ids"] = datasetIds
+	configJSON["retrieve_path"] = fmt.Sprintf("/agent/%s/retrieve", agentId)
+	if _, ok := configJSON["similarity_threshold"]; !ok {
+		configJSON["similarity_threshold"] = agentRAGDefaultSimilarityThreshold
+	}
+	if _, ok := configJSON["vector_similarity_weight"]; !ok {
+		configJSON["vector_similarity_weight"] = agentRAGDefaultVectorSimilarityWeight
+	}
+	if _, ok := configJSON["top_n"]; !ok {
+		configJSON["top_n"] = agentRAGDefaultTopN
+	}
+}
+
 // getAgentMcpAccessAddress 获取智能体的MCP接入点地址
 // 对应 Java 的 AgentMcpAccessPointService.getAgentMcpAccessAddress 方法
 func (uc *ConfigUsecase) getAgentMcpAccessAddress(ctx context.Context, agentId string) (string, error) {
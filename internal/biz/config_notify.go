@@ -0,0 +1,91 @@
+package biz
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// ConfigChangeScope 配置变更范围
+type ConfigChangeScope string
+
+const (
+	ConfigChangeScopeGlobal ConfigChangeScope = "global" // 系统参数等全局配置，节点需重新拉取服务配置
+	ConfigChangeScopeAgent  ConfigChangeScope = "agent"  // 单个智能体的配置，id为智能体ID
+	ConfigChangeScopeModel  ConfigChangeScope = "model"  // 单个模型的配置（含音色），id为模型ID
+)
+
+// ConfigChangedEvent 配置变更事件，作为config_changed消息的data下发给节点
+type ConfigChangedEvent struct {
+	Scope     ConfigChangeScope `json:"scope"`
+	ID        string            `json:"id,omitempty"`     // 全局变更时为空
+	Reason    string            `json:"reason,omitempty"` // 触发变更的配置项，如sys_params、agent、tts_voice
+	ChangedAt int64             `json:"changed_at"`       // 变更时间（毫秒）
+}
+
+// NodeBroadcaster 向所有已连接的语音服务节点广播消息
+type NodeBroadcaster interface {
+	BroadcastToAllNodes(message kit.WebSocketMessage)
+}
+
+// ConfigNotifier 配置变更通知，通过/ws节点连接推送config_changed事件
+type ConfigNotifier struct {
+	hub NodeBroadcaster
+	log *log.Helper
+}
+
+// NewConfigNotifier 创建配置变更通知
+func NewConfigNotifier(logger log.Logger) *ConfigNotifier {
+	return &ConfigNotifier{
+		hub: kit.GetWebSocket(),
+		log: kit.LogHelper(logger),
+	}
+}
+
+// NotifyGlobal 通知节点全局配置已变更
+func (n *ConfigNotifier) NotifyGlobal(ctx context.Context, reason string) {
+	n.notify(ctx, ConfigChangeScopeGlobal, "", reason)
+}
+
+// NotifyAgent 通知节点智能体配置已变更
+func (n *ConfigNotifier) NotifyAgent(ctx context.Context, agentId, reason string) {
+	n.notify(ctx, ConfigChangeScopeAgent, agentId, reason)
+}
+
+// NotifyModel 通知节点模型配置已变更
+func (n *ConfigNotifier) NotifyModel(ctx context.Context, modelId, reason string) {
+	n.notify(ctx, ConfigChangeScopeModel, modelId, reason)
+}
+
+// notify 广播配置变更事件，通知失败不影响配置的保存
+func (n *ConfigNotifier) notify(ctx context.Context, scope ConfigChangeScope, id, reason string) {
+	if n == nil || n.hub == nil {
+		return
+	}
+	if scope != ConfigChangeScopeGlobal && id == "" {
+		return
+	}
+
+	now := time.Now()
+	data, err := json.Marshal(&ConfigChangedEvent{
+		Scope:     scope,
+		ID:        id,
+		Reason:    reason,
+		ChangedAt: now.UnixMilli(),
+	})
+	if err != nil {
+		n.log.Errorf("序列化配置变更事件失败: %v", err)
+		return
+	}
+
+	n.hub.BroadcastToAllNodes(kit.WebSocketMessage{
+		Type:      kit.ConfigChangedMessageType,
+		Timestamp: now,
+		Data:      data,
+	})
+	n.log.Infof("已广播配置变更, scope: %s, id: %s, reason: %s", scope, id, reason)
+}
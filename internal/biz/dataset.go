@@ -2,7 +2,10 @@ package biz
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/weetime/agent-matrix/internal/kit"
@@ -13,6 +16,9 @@ import (
 	"github.com/google/uuid"
 )
 
+// datasetDocumentCountConcurrency 分页查询时并发获取文档数量的最大并发数
+const datasetDocumentCountConcurrency = 8
+
 // Dataset 知识库实体
 type Dataset struct {
 	ID          string
@@ -39,7 +45,7 @@ type DatasetDTO struct {
 	CreatedAt     string // 格式化为字符串
 	Updater       string // 格式化为字符串
 	UpdatedAt     string // 格式化为字符串
-	DocumentCount int32  // 文档数量（从RAG API获取，暂时返回0）
+	DocumentCount int32  // 文档数量（从RAG API获取）
 }
 
 // DatasetRepo 知识库数据访问接口
@@ -52,27 +58,36 @@ type DatasetRepo interface {
 	DeleteByID(ctx context.Context, id string) error
 	CheckDuplicateName(ctx context.Context, name string, creator int64, excludeId *string) (bool, error)
 	DeletePluginMappingByKnowledgeBaseID(ctx context.Context, knowledgeBaseId string) error
+	DeleteAgentBindingsByDatasetID(ctx context.Context, datasetId string) error
+	// ListAgentIDsByKnowledgeBase 查询通过插件映射或知识库绑定引用该知识库的智能体
+	ListAgentIDsByKnowledgeBase(ctx context.Context, knowledgeBaseId, datasetId string) ([]string, error)
 }
 
 // DatasetUsecase 知识库业务逻辑
 type DatasetUsecase struct {
-	datasetRepo     DatasetRepo
-	modelConfigRepo ModelConfigRepo
-	log             *log.Helper
-	handleError     *cerrors.HandleError
+	datasetRepo       DatasetRepo
+	modelConfigRepo   ModelConfigRepo
+	ragAdapterFactory RAGAdapterFactory
+	configNotifier    *ConfigNotifier
+	log               *log.Helper
+	handleError       *cerrors.HandleError
 }
 
 // NewDatasetUsecase 创建知识库用例
 func NewDatasetUsecase(
 	datasetRepo DatasetRepo,
 	modelConfigRepo ModelConfigRepo,
+	ragAdapterFactory RAGAdapterFactory,
+	configNotifier *ConfigNotifier,
 	logger log.Logger,
 ) *DatasetUsecase {
 	return &DatasetUsecase{
-		datasetRepo:     datasetRepo,
-		modelConfigRepo: modelConfigRepo,
-		log:             log.NewHelper(log.With(logger, "module", "biz/dataset")),
-		handleError:     cerrors.NewHandleError(logger),
+		datasetRepo:       datasetRepo,
+		modelConfigRepo:   modelConfigRepo,
+		ragAdapterFactory: ragAdapterFactory,
+		configNotifier:    configNotifier,
+		log:               log.NewHelper(log.With(logger, "module", "biz/dataset")),
+		handleError:       cerrors.NewHandleError(logger),
 	}
 }
 
@@ -88,6 +103,22 @@ func (uc *DatasetUsecase) PageDatasets(ctx context.Context, name *string, creato
 		result[i] = uc.toDTO(datasets[i])
 	}
 
+	// 文档数量需逐个查询RAG服务，并发查询并限制并发数
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, datasetDocumentCountConcurrency)
+	for i := range datasets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			result[i].DocumentCount = uc.getDocumentCount(ctx, datasets[i])
+		}(i)
+	}
+	wg.Wait()
+
 	return result, total, nil
 }
 
@@ -121,10 +152,18 @@ func (uc *DatasetUsecase) CreateDataset(ctx context.Context, req *CreateDatasetR
 		return nil, uc.handleError.ErrAlreadyExists(ctx, fmt.Errorf("知识库名称已存在"))
 	}
 
+	// 在RAG服务中创建数据集，使用RAG侧返回的ID作为dataset_id
+	adapter, err := uc.getAdapter(ctx, req.RagModelID)
+	if err != nil {
+		return nil, uc.handleError.ErrInvalidInput(ctx, err)
+	}
+	datasetId, err := adapter.CreateDataset(ctx, req.Name, req.Description)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, fmt.Errorf("创建RAG数据集失败: %w", err))
+	}
+
 	// 生成UUID作为id
-	id := uuid.New().String()
-	// 生成dataset_id（暂时使用id，后续需要调用RAG API创建数据集）
-	datasetId := id
+	id := strings.ReplaceAll(uuid.New().String(), "-", "")
 
 	// 创建知识库实体
 	dataset := &Dataset{
@@ -140,12 +179,11 @@ func (uc *DatasetUsecase) CreateDataset(ctx context.Context, req *CreateDatasetR
 		UpdatedAt:   time.Now(),
 	}
 
-	// TODO: 调用RAG API创建数据集
-	// 暂时跳过RAG API调用，直接保存到数据库
-	// 后续实现RAG适配器后再补充
-
-	// 保存到数据库
+	// 保存到数据库，失败时回滚RAG侧的数据集
 	if err := uc.datasetRepo.Create(ctx, dataset); err != nil {
+		if delErr := adapter.DeleteDataset(ctx, datasetId); delErr != nil {
+			uc.log.Warnf("Failed to rollback RAG dataset %s: %v", datasetId, delErr)
+		}
 		return nil, uc.handleError.ErrInternal(ctx, err)
 	}
 
@@ -187,22 +225,29 @@ func (uc *DatasetUsecase) UpdateDataset(ctx context.Context, datasetId string, r
 		dataset.Name = *req.Name
 	}
 
+	// 数据集存放在创建时所选的RAG服务中，不能切换RAG模型
+	if req.RagModelID != nil && *req.RagModelID != "" && *req.RagModelID != dataset.RagModelID {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("知识库创建后不能更换RAG模型"))
+	}
+
 	// 更新字段
 	if req.Description != nil {
 		dataset.Description = *req.Description
 	}
-	if req.RagModelID != nil {
-		dataset.RagModelID = *req.RagModelID
-	}
 	if req.Status != nil {
 		dataset.Status = *req.Status
 	}
 	dataset.Updater = currentUserId
 	dataset.UpdatedAt = time.Now()
 
-	// TODO: 调用RAG API更新数据集
-	// 暂时跳过RAG API调用，直接更新数据库
-	// 后续实现RAG适配器后再补充
+	// 同步更新RAG服务中的数据集
+	adapter, err := uc.getAdapter(ctx, dataset.RagModelID)
+	if err != nil {
+		return nil, uc.handleError.ErrInvalidInput(ctx, err)
+	}
+	if err := adapter.UpdateDataset(ctx, dataset.DatasetID, dataset.Name, dataset.Description); err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, fmt.Errorf("更新RAG数据集失败: %w", err))
+	}
 
 	// 更新数据库
 	if err := uc.datasetRepo.Update(ctx, dataset); err != nil {
@@ -234,9 +279,24 @@ func (uc *DatasetUsecase) DeleteDataset(ctx context.Context, datasetId string) e
 		return uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("无权限操作此知识库"))
 	}
 
-	// TODO: 调用RAG API删除数据集
-	// 暂时跳过RAG API调用，直接删除数据库记录
-	// 后续实现RAG适配器后再补充
+	// 删除RAG服务中的数据集，RAG模型配置已删除时无法访问RAG服务，只删除本地记录
+	adapter, err := uc.getAdapter(ctx, dataset.RagModelID)
+	switch {
+	case errors.Is(err, errRAGConfigNotFound):
+		uc.log.Warnf("RAG model config %s not found, deleting knowledge base %s locally only", dataset.RagModelID, dataset.ID)
+	case err != nil:
+		return uc.handleError.ErrInvalidInput(ctx, err)
+	default:
+		if err := adapter.DeleteDataset(ctx, dataset.DatasetID); err != nil {
+			return uc.handleError.ErrInternal(ctx, fmt.Errorf("删除RAG数据集失败: %w", err))
+		}
+	}
+
+	// 记录引用该知识库的智能体，解除引用后通知节点
+	agentIds, err := uc.datasetRepo.ListAgentIDsByKnowledgeBase(ctx, dataset.ID, dataset.DatasetID)
+	if err != nil {
+		uc.log.Warnf("Failed to list agents referencing knowledge base %s: %v", dataset.ID, err)
+	}
 
 	// 删除关联的插件映射
 	if err := uc.datasetRepo.DeletePluginMappingByKnowledgeBaseID(ctx, dataset.ID); err != nil {
@@ -244,11 +304,20 @@ func (uc *DatasetUsecase) DeleteDataset(ctx context.Context, datasetId string) e
 		// 继续删除知识库，不因为插件映射删除失败而中断
 	}
 
+	// 解除智能体对该知识库的绑定
+	if err := uc.datasetRepo.DeleteAgentBindingsByDatasetID(ctx, dataset.DatasetID); err != nil {
+		uc.log.Warnf("Failed to delete agent bindings for knowledge base %s: %v", dataset.DatasetID, err)
+	}
+
 	// 删除知识库
 	if err := uc.datasetRepo.DeleteByID(ctx, dataset.ID); err != nil {
 		return uc.handleError.ErrInternal(ctx, err)
 	}
 
+	for _, agentId := range agentIds {
+		uc.configNotifier.NotifyAgent(ctx, agentId, "plugin_mapping")
+	}
+
 	return nil
 }
 
@@ -270,6 +339,38 @@ func (uc *DatasetUsecase) GetRAGModels(ctx context.Context) ([]map[string]interf
 	return result, nil
 }
 
+// getAdapter 根据RAG模型配置ID获取RAG适配器
+func (uc *DatasetUsecase) getAdapter(ctx context.Context, ragModelId string) (RAGAdapter, error) {
+	ragConfig, err := loadRAGConfig(ctx, uc.modelConfigRepo, ragModelId)
+	if err != nil {
+		return nil, err
+	}
+
+	adapter, err := uc.ragAdapterFactory.GetAdapter(ragAdapterType(ragConfig), ragConfig)
+	if err != nil {
+		return nil, fmt.Errorf("获取RAG适配器失败: %w", err)
+	}
+
+	return adapter, nil
+}
+
+// getDocumentCount 从RAG服务获取知识库文档数量，失败时返回0不影响列表查询
+func (uc *DatasetUsecase) getDocumentCount(ctx context.Context, dataset *Dataset) int32 {
+	adapter, err := uc.getAdapter(ctx, dataset.RagModelID)
+	if err != nil {
+		uc.log.Warnf("Failed to get RAG adapter for dataset %s: %v", dataset.DatasetID, err)
+		return 0
+	}
+
+	count, err := adapter.GetDocumentCount(ctx, dataset.DatasetID)
+	if err != nil {
+		uc.log.Warnf("Failed to get document count for dataset %s: %v", dataset.DatasetID, err)
+		return 0
+	}
+
+	return int32(count)
+}
+
 // toDTO 转换为DTO
 func (uc *DatasetUsecase) toDTO(dataset *Dataset) *DatasetDTO {
 	creator := ""
@@ -292,7 +393,7 @@ func (uc *DatasetUsecase) toDTO(dataset *Dataset) *DatasetDTO {
 		CreatedAt:     dataset.CreatedAt.Format("2006-01-02 15:04:05"),
 		Updater:       updater,
 		UpdatedAt:     dataset.UpdatedAt.Format("2006-01-02 15:04:05"),
-		DocumentCount: 0, // 暂时返回0，后续从RAG API获取
+		DocumentCount: 0, // 列表查询时从RAG API获取
 	}
 }
 
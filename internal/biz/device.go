@@ -37,32 +37,51 @@ type DeviceRepo interface {
 	Delete(ctx context.Context, deviceId string, userId int64) error
 	GetByID(ctx context.Context, deviceId string) (*Device, error)
 	GetByMacAddress(ctx context.Context, macAddress string) (*Device, error)
+	// ListByIds 批量查询设备，不存在的设备不返回
+	ListByIds(ctx context.Context, deviceIds []string) ([]*Device, error)
 	ListByUserAndAgent(ctx context.Context, userId int64, agentId string) ([]*Device, error)
 	SelectCountByUserId(ctx context.Context, userId int64) (int64, error)
 	DeleteByUserId(ctx context.Context, userId int64) error
-	PageDevices(ctx context.Context, keywords *string, page *kit.PageRequest) ([]*Device, error)
-	TotalDevices(ctx context.Context, keywords *string) (int, error)
+	PageDevices(ctx context.Context, params *ListDeviceParams, page *kit.PageRequest) ([]*Device, error)
+	TotalDevices(ctx context.Context, params *ListDeviceParams) (int, error)
+	// CountByAppVersion 按固件版本统计指定硬件型号的设备数
+	CountByAppVersion(ctx context.Context, board string) (map[string]int64, error)
 }
 
 // DeviceUsecase 设备业务逻辑
 type DeviceUsecase struct {
-	repo        DeviceRepo
-	redisClient *kit.RedisClient
-	handleError *cerrors.HandleError
-	log         *log.Helper
+	repo          DeviceRepo
+	telemetryRepo DeviceTelemetryRepo
+	groupRepo     DeviceGroupRepo
+	transferRepo  DeviceTransferRepo
+	agentRepo     AgentRepo
+	userRepo      UserRepo
+	redisClient   *kit.RedisClient
+	handleError   *cerrors.HandleError
+	log           *log.Helper
 }
 
 // NewDeviceUsecase 创建设备用例
 func NewDeviceUsecase(
 	repo DeviceRepo,
+	telemetryRepo DeviceTelemetryRepo,
+	groupRepo DeviceGroupRepo,
+	transferRepo DeviceTransferRepo,
+	agentRepo AgentRepo,
+	userRepo UserRepo,
 	redisClient *kit.RedisClient,
 	logger log.Logger,
 ) *DeviceUsecase {
 	return &DeviceUsecase{
-		repo:        repo,
-		redisClient: redisClient,
-		handleError: cerrors.NewHandleError(logger),
-		log:         log.NewHelper(log.With(logger, "module", "agent-matrix-service/biz/device")),
+		repo:          repo,
+		telemetryRepo: telemetryRepo,
+		groupRepo:     groupRepo,
+		transferRepo:  transferRepo,
+		agentRepo:     agentRepo,
+		userRepo:      userRepo,
+		redisClient:   redisClient,
+		handleError:   cerrors.NewHandleError(logger),
+		log:           log.NewHelper(log.With(logger, "module", "agent-matrix-service/biz/device")),
 	}
 }
 
@@ -0,0 +1,329 @@
+package biz
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+)
+
+const (
+	deviceBatchMaxSize  = 500      // 单次批量操作的最大设备数
+	deviceAliasMaxLen   = 64       // 设备别名最大长度
+	deviceAliasCSVBOM   = "\ufeff" // Excel导出的UTF-8 CSV带BOM
+	deviceBatchNotFound = "设备不存在"
+)
+
+// deviceAliasCSVHeaders 别名导入CSV首行为表头时的可识别列名
+var deviceAliasCSVHeaders = map[string]bool{
+	"mac":         true,
+	"mac_address": true,
+	"macaddress":  true,
+	"device_id":   true,
+	"id":          true,
+	"mac地址":       true,
+	"设备id":        true,
+}
+
+// DeviceBatchResult 批量操作中单个设备的处理结果
+type DeviceBatchResult struct {
+	DeviceID string
+	Row      int // 别名导入时对应的CSV行号，从1开始
+	Success  bool
+	Message  string
+}
+
+func (r *DeviceBatchResult) succeed() {
+	r.Success = true
+	r.Message = ""
+}
+
+func (r *DeviceBatchResult) fail(message string) {
+	r.Success = false
+	r.Message = message
+}
+
+// DeviceBatchTarget 批量操作的目标设备：指定的设备ID与分组内的全部设备取并集
+type DeviceBatchTarget struct {
+	DeviceIDs []string
+	GroupID   string
+}
+
+// BatchAssignAgent 批量将设备切换到指定智能体，智能体需与设备属于同一用户
+func (uc *DeviceUsecase) BatchAssignAgent(ctx context.Context, op *DeviceOperator, target *DeviceBatchTarget, agentId string) ([]*DeviceBatchResult, error) {
+	agent, _, err := uc.agentRepo.GetAgentByID(ctx, agentId)
+	if err != nil || agent == nil {
+		return nil, uc.handleError.ErrNotFound(ctx, fmt.Errorf("智能体不存在"))
+	}
+	if !op.SuperAdmin && agent.UserID != op.UserID {
+		return nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("无权使用该智能体"))
+	}
+
+	deviceIds, err := uc.resolveBatchTarget(ctx, op, target)
+	if err != nil {
+		return nil, err
+	}
+	deviceIds, devices, results, err := uc.loadBatchDevices(ctx, op, deviceIds)
+	if err != nil {
+		return nil, err
+	}
+
+	affectedAgents := map[string]bool{agentId: true}
+	for _, device := range devices {
+		result := results[device.ID]
+		if device.UserID != agent.UserID {
+			result.fail("智能体不属于设备所属用户")
+			continue
+		}
+		if device.AgentID == agentId {
+			result.succeed()
+			continue
+		}
+		previousAgentId := device.AgentID
+		device.AgentID = agentId
+		if err := uc.updateBatchDevice(ctx, op, device); err != nil {
+			result.fail(err.Error())
+			continue
+		}
+		affectedAgents[previousAgentId] = true
+		result.succeed()
+	}
+	uc.clearAgentDeviceCountCache(ctx, affectedAgents)
+	return orderedBatchResults(deviceIds, results), nil
+}
+
+// BatchSetAutoUpdate 批量设置设备自动更新开关
+func (uc *DeviceUsecase) BatchSetAutoUpdate(ctx context.Context, op *DeviceOperator, target *DeviceBatchTarget, autoUpdate int32) ([]*DeviceBatchResult, error) {
+	if autoUpdate != 0 && autoUpdate != 1 {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("自动更新开关只能为0或1"))
+	}
+	deviceIds, err := uc.resolveBatchTarget(ctx, op, target)
+	if err != nil {
+		return nil, err
+	}
+	deviceIds, devices, results, err := uc.loadBatchDevices(ctx, op, deviceIds)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, device := range devices {
+		device.AutoUpdate = autoUpdate
+		if err := uc.updateBatchDevice(ctx, op, device); err != nil {
+			results[device.ID].fail(err.Error())
+			continue
+		}
+		results[device.ID].succeed()
+	}
+	return orderedBatchResults(deviceIds, results), nil
+}
+
+// BatchUnbindDevices 批量解绑设备
+func (uc *DeviceUsecase) BatchUnbindDevices(ctx context.Context, op *DeviceOperator, target *DeviceBatchTarget) ([]*DeviceBatchResult, error) {
+	deviceIds, err := uc.resolveBatchTarget(ctx, op, target)
+	if err != nil {
+		return nil, err
+	}
+	deviceIds, devices, results, err := uc.loadBatchDevices(ctx, op, deviceIds)
+	if err != nil {
+		return nil, err
+	}
+
+	affectedAgents := make(map[string]bool)
+	for _, device := range devices {
+		if err := uc.repo.Delete(ctx, device.ID, device.UserID); err != nil {
+			results[device.ID].fail(err.Error())
+			continue
+		}
+		affectedAgents[device.AgentID] = true
+		results[device.ID].succeed()
+	}
+	uc.clearAgentDeviceCountCache(ctx, affectedAgents)
+	return orderedBatchResults(deviceIds, results), nil
+}
+
+// ImportDeviceAliases 从CSV批量导入设备别名，每行为“MAC地址或设备ID,别名”，首行可为表头
+func (uc *DeviceUsecase) ImportDeviceAliases(ctx context.Context, op *DeviceOperator, data []byte) ([]*DeviceBatchResult, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("CSV格式错误: %v", err))
+	}
+	if len(records) > 0 && len(records[0]) > 0 {
+		records[0][0] = strings.TrimPrefix(records[0][0], deviceAliasCSVBOM)
+	}
+
+	startRow := 0
+	if len(records) > 0 && len(records[0]) > 0 && deviceAliasCSVHeaders[strings.ToLower(strings.TrimSpace(records[0][0]))] {
+		startRow = 1
+	}
+	if len(records)-startRow == 0 {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("CSV中没有可导入的数据"))
+	}
+	if len(records)-startRow > deviceBatchMaxSize {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("单次最多导入%d个设备", deviceBatchMaxSize))
+	}
+
+	results := make([]*DeviceBatchResult, 0, len(records)-startRow)
+	for i := startRow; i < len(records); i++ {
+		record := records[i]
+		result := &DeviceBatchResult{Row: i + 1}
+		results = append(results, result)
+		if len(record) > 0 {
+			result.DeviceID = strings.TrimSpace(record[0])
+		}
+		if result.DeviceID == "" {
+			result.fail("缺少MAC地址或设备ID")
+			continue
+		}
+		if len(record) < 2 || strings.TrimSpace(record[1]) == "" {
+			result.fail("别名不能为空")
+			continue
+		}
+		alias := strings.TrimSpace(record[1])
+		if utf8.RuneCountInString(alias) > deviceAliasMaxLen {
+			result.fail(fmt.Sprintf("别名不能超过%d个字符", deviceAliasMaxLen))
+			continue
+		}
+
+		device, err := uc.findDeviceByMacOrID(ctx, result.DeviceID)
+		if err != nil {
+			result.fail(err.Error())
+			continue
+		}
+		if device == nil {
+			result.fail(deviceBatchNotFound)
+			continue
+		}
+		result.DeviceID = device.ID
+		if !op.canOperateDevice(device) {
+			result.fail("无权操作该设备")
+			continue
+		}
+		device.Alias = alias
+		if err := uc.updateBatchDevice(ctx, op, device); err != nil {
+			result.fail(err.Error())
+			continue
+		}
+		result.succeed()
+	}
+	return results, nil
+}
+
+// resolveBatchTarget 合并指定设备与分组内设备，去重后返回
+func (uc *DeviceUsecase) resolveBatchTarget(ctx context.Context, op *DeviceOperator, target *DeviceBatchTarget) ([]string, error) {
+	if target == nil {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("请指定设备或分组"))
+	}
+	deviceIds := append([]string(nil), target.DeviceIDs...)
+	if target.GroupID != "" {
+		if _, err := uc.getOperableGroup(ctx, op, target.GroupID); err != nil {
+			return nil, err
+		}
+		groupDeviceIds, err := uc.groupRepo.ListDeviceIds(ctx, target.GroupID)
+		if err != nil {
+			return nil, uc.handleError.ErrInternal(ctx, err)
+		}
+		deviceIds = append(deviceIds, groupDeviceIds...)
+	}
+	return deviceIds, nil
+}
+
+// checkBatchSize 去除空值和重复设备ID并校验数量
+func (uc *DeviceUsecase) checkBatchSize(ctx context.Context, deviceIds []string) ([]string, error) {
+	seen := make(map[string]bool, len(deviceIds))
+	result := make([]string, 0, len(deviceIds))
+	for _, deviceId := range deviceIds {
+		deviceId = strings.TrimSpace(deviceId)
+		if deviceId == "" || seen[deviceId] {
+			continue
+		}
+		seen[deviceId] = true
+		result = append(result, deviceId)
+	}
+	if len(result) == 0 {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("设备列表不能为空"))
+	}
+	if len(result) > deviceBatchMaxSize {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("单次最多操作%d个设备", deviceBatchMaxSize))
+	}
+	return result, nil
+}
+
+// loadBatchDevices 查询批量操作的设备，返回去重后的设备ID、有权操作的设备及各设备的结果
+// 不存在或无权操作的设备直接记为失败
+func (uc *DeviceUsecase) loadBatchDevices(ctx context.Context, op *DeviceOperator, deviceIds []string) ([]string, []*Device, map[string]*DeviceBatchResult, error) {
+	deviceIds, err := uc.checkBatchSize(ctx, deviceIds)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	devices, err := uc.repo.ListByIds(ctx, deviceIds)
+	if err != nil {
+		return nil, nil, nil, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	results := make(map[string]*DeviceBatchResult, len(deviceIds))
+	for _, deviceId := range deviceIds {
+		results[deviceId] = &DeviceBatchResult{DeviceID: deviceId, Message: deviceBatchNotFound}
+	}
+	operable := make([]*Device, 0, len(devices))
+	for _, device := range devices {
+		result, ok := results[device.ID]
+		if !ok {
+			continue
+		}
+		if !op.canOperateDevice(device) {
+			result.fail("无权操作该设备")
+			continue
+		}
+		result.Message = ""
+		operable = append(operable, device)
+	}
+	return deviceIds, operable, results, nil
+}
+
+// orderedBatchResults 按请求中的设备顺序返回结果
+func orderedBatchResults(deviceIds []string, results map[string]*DeviceBatchResult) []*DeviceBatchResult {
+	ordered := make([]*DeviceBatchResult, 0, len(deviceIds))
+	for _, deviceId := range deviceIds {
+		if result, ok := results[deviceId]; ok {
+			ordered = append(ordered, result)
+		}
+	}
+	return ordered
+}
+
+// updateBatchDevice 保存批量操作修改的设备
+func (uc *DeviceUsecase) updateBatchDevice(ctx context.Context, op *DeviceOperator, device *Device) error {
+	device.Updater = op.UserID
+	device.UpdateDate = time.Now()
+	return uc.repo.Update(ctx, device)
+}
+
+// findDeviceByMacOrID 按MAC地址查询设备，不存在时按设备ID查询
+func (uc *DeviceUsecase) findDeviceByMacOrID(ctx context.Context, key string) (*Device, error) {
+	device, err := uc.repo.GetByMacAddress(ctx, key)
+	if err != nil || device != nil {
+		return device, err
+	}
+	return uc.repo.GetByID(ctx, key)
+}
+
+// clearAgentDeviceCountCache 清除智能体设备数量缓存
+func (uc *DeviceUsecase) clearAgentDeviceCountCache(ctx context.Context, agentIds map[string]bool) {
+	keys := make([]string, 0, len(agentIds))
+	for agentId := range agentIds {
+		if agentId != "" {
+			keys = append(keys, kit.GetAgentDeviceCountKey(agentId))
+		}
+	}
+	if len(keys) > 0 {
+		_ = uc.redisClient.Delete(ctx, keys...)
+	}
+}
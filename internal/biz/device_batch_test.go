@@ -0,0 +1,170 @@
+package biz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchSetAutoUpdate(t *testing.T) {
+	tests := []struct {
+		name      string
+		op        *DeviceOperator
+		deviceIds []string
+		wantErr   bool
+		wantMsgs  []string // 为空表示成功
+		wantSaved map[string]int32
+	}{
+		{
+			// 重复的设备只处理一次，不存在和无权操作的设备逐项记为失败
+			name:      "逐项返回处理结果",
+			op:        &DeviceOperator{UserID: 1},
+			deviceIds: []string{"aa:aa", "cc:cc", "aa:aa", "zz:zz", "bb:bb"},
+			wantMsgs:  []string{"", "无权操作该设备", deviceBatchNotFound, ""},
+			wantSaved: map[string]int32{"aa:aa": 0, "bb:bb": 0},
+		},
+		{
+			name:    "未选择设备",
+			op:      &DeviceOperator{UserID: 1},
+			wantErr: true,
+		},
+		{
+			name:      "超级管理员可操作所有设备",
+			op:        &DeviceOperator{UserID: 9, SuperAdmin: true},
+			deviceIds: []string{"cc:cc"},
+			wantMsgs:  []string{""},
+			wantSaved: map[string]int32{"cc:cc": 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newStubDeviceRepo()
+			uc := NewDeviceUsecase(repo, nil, nil, nil, nil, nil, nil, log.DefaultLogger)
+			results, err := uc.BatchSetAutoUpdate(context.Background(), tt.op, &DeviceBatchTarget{DeviceIDs: tt.deviceIds}, 0)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantMsgs, batchResultMessages(results))
+			require.Equal(t, tt.wantSaved, repo.autoUpdates())
+		})
+	}
+}
+
+func TestImportDeviceAliases(t *testing.T) {
+	tests := []struct {
+		name      string
+		csv       string
+		wantErr   bool
+		wantRows  []int
+		wantMsgs  []string
+		wantAlias map[string]string
+	}{
+		{
+			name:      "按行返回导入结果",
+			csv:       "\ufeffmac_address,alias\naa:aa, 一年级1班\ncc:cc,二年级\nzz:zz,三年级\nbb:bb\n",
+			wantRows:  []int{2, 3, 4, 5},
+			wantMsgs:  []string{"", "无权操作该设备", deviceBatchNotFound, "别名不能为空"},
+			wantAlias: map[string]string{"aa:aa": "一年级1班"},
+		},
+		{
+			name:    "没有数据行",
+			csv:     "mac_address,alias\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newStubDeviceRepo()
+			uc := NewDeviceUsecase(repo, nil, nil, nil, nil, nil, nil, log.DefaultLogger)
+			results, err := uc.ImportDeviceAliases(context.Background(), &DeviceOperator{UserID: 1}, []byte(tt.csv))
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantMsgs, batchResultMessages(results))
+			var rows []int
+			for _, result := range results {
+				rows = append(rows, result.Row)
+			}
+			require.Equal(t, tt.wantRows, rows)
+			aliases := make(map[string]string)
+			for id, device := range repo.updated {
+				aliases[id] = device.Alias
+			}
+			require.Equal(t, tt.wantAlias, aliases)
+		})
+	}
+}
+
+// batchResultMessages 成功的结果记为空字符串
+func batchResultMessages(results []*DeviceBatchResult) []string {
+	msgs := make([]string, 0, len(results))
+	for _, result := range results {
+		if result.Success {
+			msgs = append(msgs, "")
+			continue
+		}
+		msgs = append(msgs, result.Message)
+	}
+	return msgs
+}
+
+// stubDeviceRepo 返回固定的设备，并记录更新的设备
+type stubDeviceRepo struct {
+	DeviceRepo
+	devices map[string]*Device
+	updated map[string]*Device
+}
+
+func newStubDeviceRepo() *stubDeviceRepo {
+	return &stubDeviceRepo{
+		devices: map[string]*Device{
+			"aa:aa": {ID: "aa:aa", MacAddress: "aa:aa", UserID: 1, AutoUpdate: 1},
+			"bb:bb": {ID: "bb:bb", MacAddress: "bb:bb", UserID: 1, AutoUpdate: 1},
+			"cc:cc": {ID: "cc:cc", MacAddress: "cc:cc", UserID: 2, AutoUpdate: 1},
+		},
+		updated: make(map[string]*Device),
+	}
+}
+
+func (r *stubDeviceRepo) autoUpdates() map[string]int32 {
+	result := make(map[string]int32)
+	for id, device := range r.updated {
+		result[id] = device.AutoUpdate
+	}
+	return result
+}
+
+func (r *stubDeviceRepo) GetByID(ctx context.Context, deviceId string) (*Device, error) {
+	return r.GetByMacAddress(ctx, deviceId)
+}
+
+func (r *stubDeviceRepo) GetByMacAddress(ctx context.Context, macAddress string) (*Device, error) {
+	if device, ok := r.devices[macAddress]; ok {
+		copied := *device
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (r *stubDeviceRepo) ListByIds(ctx context.Context, deviceIds []string) ([]*Device, error) {
+	var devices []*Device
+	for _, deviceId := range deviceIds {
+		if device, ok := r.devices[deviceId]; ok {
+			copied := *device
+			devices = append(devices, &copied)
+		}
+	}
+	return devices, nil
+}
+
+func (r *stubDeviceRepo) Update(ctx context.Context, device *Device) error {
+	copied := *device
+	r.updated[device.ID] = &copied
+	return nil
+}
@@ -0,0 +1,281 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	deviceGroupNameMaxLen = 64  // 分组名称最大长度
+	deviceGroupDescMaxLen = 255 // 分组描述最大长度
+)
+
+// DeviceGroup 设备分组，UserID为0时为管理员创建的全局分组，可包含任意用户的设备
+type DeviceGroup struct {
+	ID          string
+	Name        string
+	Description string
+	UserID      int64
+	Creator     int64
+	DeviceCount int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// IsGlobal 是否为全局分组
+func (g *DeviceGroup) IsGlobal() bool {
+	return g.UserID == 0
+}
+
+// DeviceOperator 设备操作人，超级管理员可操作所有用户的设备和全局分组
+type DeviceOperator struct {
+	UserID     int64
+	SuperAdmin bool
+}
+
+// canOperateDevice 是否有权操作该设备
+func (op *DeviceOperator) canOperateDevice(device *Device) bool {
+	return op.SuperAdmin || device.UserID == op.UserID
+}
+
+// canOperateGroup 是否有权操作该分组
+func (op *DeviceOperator) canOperateGroup(group *DeviceGroup) bool {
+	if group.IsGlobal() {
+		return op.SuperAdmin
+	}
+	return op.SuperAdmin || group.UserID == op.UserID
+}
+
+// DeviceGroupRepo 设备分组数据访问接口
+type DeviceGroupRepo interface {
+	Create(ctx context.Context, group *DeviceGroup) error
+	Update(ctx context.Context, group *DeviceGroup) error
+
+	// Delete 删除分组及其成员关系，不影响设备本身
+	Delete(ctx context.Context, id string) error
+
+	GetByID(ctx context.Context, id string) (*DeviceGroup, error)
+
+	// GetByName 查询用户下指定名称的分组，不存在时返回nil
+	GetByName(ctx context.Context, userId int64, name string) (*DeviceGroup, error)
+
+	// ListByUserIds 查询指定用户的分组（含设备数），按创建时间排序
+	ListByUserIds(ctx context.Context, userIds []int64) ([]*DeviceGroup, error)
+
+	// ListDeviceIds 查询分组内的设备ID
+	ListDeviceIds(ctx context.Context, groupId string) ([]string, error)
+
+	// AddDevices 将设备加入分组，已在分组中的设备忽略
+	AddDevices(ctx context.Context, groupId string, deviceIds []string) error
+
+	// RemoveDevices 将设备移出分组，返回实际移出的设备ID
+	RemoveDevices(ctx context.Context, groupId string, deviceIds []string) ([]string, error)
+}
+
+// CreateDeviceGroup 创建设备分组，global为true时创建全局分组（仅超级管理员）
+func (uc *DeviceUsecase) CreateDeviceGroup(ctx context.Context, op *DeviceOperator, name, description string, global bool) (*DeviceGroup, error) {
+	if global && !op.SuperAdmin {
+		return nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("仅超级管理员可创建全局分组"))
+	}
+	name, err := uc.checkDeviceGroupInput(ctx, name, description)
+	if err != nil {
+		return nil, err
+	}
+
+	group := &DeviceGroup{
+		Name:        name,
+		Description: description,
+		UserID:      op.UserID,
+		Creator:     op.UserID,
+	}
+	if global {
+		group.UserID = 0
+	}
+	if err := uc.checkDeviceGroupName(ctx, group.UserID, name, ""); err != nil {
+		return nil, err
+	}
+	if err := uc.groupRepo.Create(ctx, group); err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	return group, nil
+}
+
+// UpdateDeviceGroup 修改分组名称和描述
+func (uc *DeviceUsecase) UpdateDeviceGroup(ctx context.Context, op *DeviceOperator, groupId string, name, description *string) error {
+	group, err := uc.getOperableGroup(ctx, op, groupId)
+	if err != nil {
+		return err
+	}
+
+	newName, newDescription := group.Name, group.Description
+	if name != nil {
+		newName = *name
+	}
+	if description != nil {
+		newDescription = *description
+	}
+	newName, err = uc.checkDeviceGroupInput(ctx, newName, newDescription)
+	if err != nil {
+		return err
+	}
+	if newName != group.Name {
+		if err := uc.checkDeviceGroupName(ctx, group.UserID, newName, group.ID); err != nil {
+			return err
+		}
+	}
+
+	group.Name = newName
+	group.Description = newDescription
+	if err := uc.groupRepo.Update(ctx, group); err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	return nil
+}
+
+// DeleteDeviceGroup 删除分组，分组内的设备不受影响
+func (uc *DeviceUsecase) DeleteDeviceGroup(ctx context.Context, op *DeviceOperator, groupId string) error {
+	if _, err := uc.getOperableGroup(ctx, op, groupId); err != nil {
+		return err
+	}
+	if err := uc.groupRepo.Delete(ctx, groupId); err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	return nil
+}
+
+// ListDeviceGroups 查询可见的分组：用户自己的分组，超级管理员额外可见全局分组
+func (uc *DeviceUsecase) ListDeviceGroups(ctx context.Context, op *DeviceOperator) ([]*DeviceGroup, error) {
+	userIds := []int64{op.UserID}
+	if op.SuperAdmin {
+		userIds = append(userIds, 0)
+	}
+	groups, err := uc.groupRepo.ListByUserIds(ctx, userIds)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	return groups, nil
+}
+
+// GetDeviceGroupDevices 查询分组内的设备
+func (uc *DeviceUsecase) GetDeviceGroupDevices(ctx context.Context, op *DeviceOperator, groupId string) (*DeviceGroup, []*Device, error) {
+	group, err := uc.getOperableGroup(ctx, op, groupId)
+	if err != nil {
+		return nil, nil, err
+	}
+	deviceIds, err := uc.groupRepo.ListDeviceIds(ctx, groupId)
+	if err != nil {
+		return nil, nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	devices, err := uc.repo.ListByIds(ctx, deviceIds)
+	if err != nil {
+		return nil, nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	return group, devices, nil
+}
+
+// AddDeviceGroupDevices 将设备加入分组，用户分组只能加入分组所属用户的设备
+func (uc *DeviceUsecase) AddDeviceGroupDevices(ctx context.Context, op *DeviceOperator, groupId string, deviceIds []string) ([]*DeviceBatchResult, error) {
+	group, err := uc.getOperableGroup(ctx, op, groupId)
+	if err != nil {
+		return nil, err
+	}
+	deviceIds, devices, results, err := uc.loadBatchDevices(ctx, op, deviceIds)
+	if err != nil {
+		return nil, err
+	}
+
+	accepted := make([]string, 0, len(devices))
+	for _, device := range devices {
+		if !group.IsGlobal() && device.UserID != group.UserID {
+			results[device.ID].fail("设备不属于分组所属用户")
+			continue
+		}
+		accepted = append(accepted, device.ID)
+	}
+	if len(accepted) > 0 {
+		if err := uc.groupRepo.AddDevices(ctx, groupId, accepted); err != nil {
+			return nil, uc.handleError.ErrInternal(ctx, err)
+		}
+		for _, deviceId := range accepted {
+			results[deviceId].succeed()
+		}
+	}
+	return orderedBatchResults(deviceIds, results), nil
+}
+
+// RemoveDeviceGroupDevices 将设备移出分组
+func (uc *DeviceUsecase) RemoveDeviceGroupDevices(ctx context.Context, op *DeviceOperator, groupId string, deviceIds []string) ([]*DeviceBatchResult, error) {
+	if _, err := uc.getOperableGroup(ctx, op, groupId); err != nil {
+		return nil, err
+	}
+	deviceIds, err := uc.checkBatchSize(ctx, deviceIds)
+	if err != nil {
+		return nil, err
+	}
+
+	removed, err := uc.groupRepo.RemoveDevices(ctx, groupId, deviceIds)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	removedSet := make(map[string]bool, len(removed))
+	for _, deviceId := range removed {
+		removedSet[deviceId] = true
+	}
+
+	results := make([]*DeviceBatchResult, 0, len(deviceIds))
+	for _, deviceId := range deviceIds {
+		result := &DeviceBatchResult{DeviceID: deviceId}
+		if removedSet[deviceId] {
+			result.succeed()
+		} else {
+			result.fail("设备不在分组中")
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// getOperableGroup 查询分组并校验操作权限
+func (uc *DeviceUsecase) getOperableGroup(ctx context.Context, op *DeviceOperator, groupId string) (*DeviceGroup, error) {
+	group, err := uc.groupRepo.GetByID(ctx, groupId)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if group == nil {
+		return nil, uc.handleError.ErrNotFound(ctx, fmt.Errorf("分组不存在"))
+	}
+	if !op.canOperateGroup(group) {
+		return nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("无权操作该分组"))
+	}
+	return group, nil
+}
+
+// checkDeviceGroupInput 校验分组名称和描述，返回去除首尾空白后的名称
+func (uc *DeviceUsecase) checkDeviceGroupInput(ctx context.Context, name, description string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("分组名称不能为空"))
+	}
+	if utf8.RuneCountInString(name) > deviceGroupNameMaxLen {
+		return "", uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("分组名称不能超过%d个字符", deviceGroupNameMaxLen))
+	}
+	if utf8.RuneCountInString(description) > deviceGroupDescMaxLen {
+		return "", uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("分组描述不能超过%d个字符", deviceGroupDescMaxLen))
+	}
+	return name, nil
+}
+
+// checkDeviceGroupName 同一用户下分组名称不能重复
+func (uc *DeviceUsecase) checkDeviceGroupName(ctx context.Context, userId int64, name, excludeId string) error {
+	existing, err := uc.groupRepo.GetByName(ctx, userId, name)
+	if err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	if existing != nil && existing.ID != excludeId {
+		return uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("分组名称已存在"))
+	}
+	return nil
+}
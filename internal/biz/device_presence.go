@@ -0,0 +1,224 @@
+package biz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// devicePresenceTTL 设备在线状态的过期时间，节点需在此时间内上报心跳，否则视为离线
+const devicePresenceTTL = kit.HeartbeatInterval * kit.MaxMissedHeartbeats
+
+// DevicePresence 设备在线状态
+type DevicePresence struct {
+	MacAddress  string    `json:"mac_address"`
+	NodeID      string    `json:"node_id"`  // 设备连接的语音服务节点
+	AgentID     string    `json:"agent_id"` // 设备绑定的智能体，未绑定时为空
+	SessionID   string    `json:"session_id,omitempty"`
+	ConnectedAt time.Time `json:"connected_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+// devicePresenceEvent 节点推送的设备在线事件数据
+type devicePresenceEvent struct {
+	MacAddress   string   `json:"mac_address"`
+	MacAddresses []string `json:"mac_addresses"` // 批量心跳时使用
+	SessionID    string   `json:"session_id"`
+}
+
+// HandleNodeDeviceMessage 处理节点通过/ws推送的设备连接、断开和心跳消息
+func (uc *DeviceUsecase) HandleNodeDeviceMessage(ctx context.Context, nodeID string, msg kit.WebSocketMessage) error {
+	var event devicePresenceEvent
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			return fmt.Errorf("解析设备事件失败: %w", err)
+		}
+	}
+
+	macAddresses := event.MacAddresses
+	if event.MacAddress != "" {
+		macAddresses = append(macAddresses, event.MacAddress)
+	}
+	if len(macAddresses) == 0 {
+		return fmt.Errorf("设备事件缺少mac_address, 节点ID: %s", nodeID)
+	}
+
+	for _, macAddress := range macAddresses {
+		var err error
+		switch msg.Type {
+		case kit.DeviceConnectMessageType:
+			err = uc.DeviceOnline(ctx, nodeID, macAddress, event.SessionID)
+		case kit.DeviceHeartbeatMessageType:
+			err = uc.DeviceHeartbeat(ctx, nodeID, macAddress)
+		case kit.DeviceDisconnectMessageType:
+			err = uc.DeviceOffline(ctx, nodeID, macAddress)
+		default:
+			return fmt.Errorf("不支持的设备事件类型: %s", msg.Type)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeviceOnline 记录设备连接到节点，并更新设备的最后连接时间
+func (uc *DeviceUsecase) DeviceOnline(ctx context.Context, nodeID, macAddress, sessionID string) error {
+	now := time.Now()
+	presence := &DevicePresence{
+		MacAddress:  macAddress,
+		NodeID:      nodeID,
+		SessionID:   sessionID,
+		ConnectedAt: now,
+		LastSeenAt:  now,
+	}
+
+	device, err := uc.repo.GetByMacAddress(ctx, macAddress)
+	if err != nil {
+		return fmt.Errorf("查询设备失败: %w", err)
+	}
+	if device != nil {
+		presence.AgentID = device.AgentID
+		device.LastConnectedAt = &now
+		if err := uc.repo.Update(ctx, device); err != nil {
+			uc.log.Warnf("更新设备最后连接时间失败, mac: %s, error: %v", macAddress, err)
+		}
+	}
+
+	// 设备从其他节点迁移过来时，先从原节点索引中移除
+	if previous, err := uc.getDevicePresence(ctx, macAddress); err == nil && previous != nil && previous.NodeID != nodeID {
+		uc.redisClient.GetClient().ZRem(ctx, kit.GetDevicePresenceNodeKey(previous.NodeID), macAddress)
+	}
+
+	return uc.saveDevicePresence(ctx, presence)
+}
+
+// DeviceHeartbeat 刷新设备在线状态，管理端重启等原因丢失状态时按新连接处理
+func (uc *DeviceUsecase) DeviceHeartbeat(ctx context.Context, nodeID, macAddress string) error {
+	presence, err := uc.getDevicePresence(ctx, macAddress)
+	if err != nil {
+		return err
+	}
+	if presence == nil || presence.NodeID != nodeID {
+		return uc.DeviceOnline(ctx, nodeID, macAddress, "")
+	}
+
+	presence.LastSeenAt = time.Now()
+	return uc.saveDevicePresence(ctx, presence)
+}
+
+// DeviceOffline 记录设备从节点断开，设备已重新连接到其他节点时忽略
+func (uc *DeviceUsecase) DeviceOffline(ctx context.Context, nodeID, macAddress string) error {
+	presence, err := uc.getDevicePresence(ctx, macAddress)
+	if err != nil {
+		return err
+	}
+
+	client := uc.redisClient.GetClient()
+	client.ZRem(ctx, kit.GetDevicePresenceNodeKey(nodeID), macAddress)
+	if presence == nil || presence.NodeID != nodeID {
+		return nil
+	}
+
+	if presence.AgentID != "" {
+		client.ZRem(ctx, kit.GetDevicePresenceAgentKey(presence.AgentID), macAddress)
+	}
+	return uc.redisClient.Delete(ctx, kit.GetDevicePresenceKey(macAddress))
+}
+
+// NodeOffline 节点断开时将其上的设备全部标记为离线
+func (uc *DeviceUsecase) NodeOffline(ctx context.Context, nodeID string) error {
+	nodeKey := kit.GetDevicePresenceNodeKey(nodeID)
+	macAddresses, err := uc.redisClient.GetClient().ZRange(ctx, nodeKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, macAddress := range macAddresses {
+		if err := uc.DeviceOffline(ctx, nodeID, macAddress); err != nil {
+			uc.log.Warnf("标记设备离线失败, mac: %s, 节点ID: %s, error: %v", macAddress, nodeID, err)
+		}
+	}
+	uc.log.Infof("节点 %s 下线，%d 台设备已标记为离线", nodeID, len(macAddresses))
+	return uc.redisClient.Delete(ctx, nodeKey)
+}
+
+// GetDevicePresences 批量查询设备在线状态，返回在线设备的状态（按MAC地址索引）
+func (uc *DeviceUsecase) GetDevicePresences(ctx context.Context, macAddresses []string) (map[string]*DevicePresence, error) {
+	result := make(map[string]*DevicePresence)
+	if len(macAddresses) == 0 {
+		return result, nil
+	}
+
+	keys := make([]string, len(macAddresses))
+	for i, macAddress := range macAddresses {
+		keys[i] = kit.GetDevicePresenceKey(macAddress)
+	}
+	values, err := uc.redisClient.GetClient().MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	for i, value := range values {
+		str, ok := value.(string)
+		if !ok || str == "" {
+			continue
+		}
+		var presence DevicePresence
+		if err := json.Unmarshal([]byte(str), &presence); err != nil {
+			continue
+		}
+		result[macAddresses[i]] = &presence
+	}
+	return result, nil
+}
+
+// CountOnlineDevicesByAgent 统计智能体当前在线的设备数
+func (uc *DeviceUsecase) CountOnlineDevicesByAgent(ctx context.Context, agentId string) (int64, error) {
+	key := kit.GetDevicePresenceAgentKey(agentId)
+	client := uc.redisClient.GetClient()
+
+	// 清理心跳已过期的设备
+	expiredBefore := time.Now().Add(-devicePresenceTTL).Unix()
+	if err := client.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(expiredBefore, 10)).Err(); err != nil {
+		return 0, err
+	}
+	return client.ZCard(ctx, key).Result()
+}
+
+// saveDevicePresence 保存设备在线状态并更新节点、智能体索引，索引的分值为最后心跳时间
+func (uc *DeviceUsecase) saveDevicePresence(ctx context.Context, presence *DevicePresence) error {
+	if err := uc.redisClient.SetObject(ctx, kit.GetDevicePresenceKey(presence.MacAddress), presence, devicePresenceTTL); err != nil {
+		return err
+	}
+
+	client := uc.redisClient.GetClient()
+	member := redis.Z{Score: float64(presence.LastSeenAt.Unix()), Member: presence.MacAddress}
+	if err := client.ZAdd(ctx, kit.GetDevicePresenceNodeKey(presence.NodeID), member).Err(); err != nil {
+		return err
+	}
+	if presence.AgentID != "" {
+		if err := client.ZAdd(ctx, kit.GetDevicePresenceAgentKey(presence.AgentID), member).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getDevicePresence 获取设备在线状态，离线时返回nil
+func (uc *DeviceUsecase) getDevicePresence(ctx context.Context, macAddress string) (*DevicePresence, error) {
+	var presence DevicePresence
+	if err := uc.redisClient.GetObject(ctx, kit.GetDevicePresenceKey(macAddress), &presence); err != nil {
+		return nil, err
+	}
+	if presence.MacAddress == "" {
+		return nil, nil
+	}
+	return &presence, nil
+}
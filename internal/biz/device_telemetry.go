@@ -0,0 +1,196 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+)
+
+const (
+	deviceTelemetryRetention     = 30 * 24 * time.Hour // 历史上报保留时长，每台设备最新一次上报始终保留
+	deviceTelemetryCleanInterval = time.Hour           // 过期清理间隔
+	deviceTelemetryCleanLockTTL  = 10 * time.Minute    // 清理锁有效期
+	deviceTelemetryRecentLimit   = 20                  // 设备详情返回的最近上报条数
+)
+
+// DeviceTelemetry 设备通过/ota上报的硬件与网络信息快照
+type DeviceTelemetry struct {
+	ID                  string
+	DeviceID            string
+	MacAddress          string
+	Board               string
+	AppVersion          string
+	ChipModelName       string
+	FlashSize           *int32
+	MinimumFreeHeapSize *int32
+	IdfVersion          string
+	PartitionTable      []*Partition
+	OtaLabel            string
+	SSID                string
+	RSSI                *int32
+	Channel             *int32
+	IP                  string
+	CreatedAt           time.Time
+}
+
+// DeviceTelemetryFilter 按设备最新一次上报的遥测筛选设备，未上报过遥测的设备不会命中任何条件
+type DeviceTelemetryFilter struct {
+	ChipModelName *string
+	IdfVersion    *string
+	RSSIBelow     *int32 // WiFi信号强度低于该值(dBm)
+	FreeHeapBelow *int32 // 最小剩余堆内存低于该值(字节)
+}
+
+// IsEmpty 是否未设置任何遥测筛选条件
+func (f *DeviceTelemetryFilter) IsEmpty() bool {
+	return f == nil || (f.ChipModelName == nil && f.IdfVersion == nil && f.RSSIBelow == nil && f.FreeHeapBelow == nil)
+}
+
+// ListDeviceParams 管理端设备列表查询条件
+type ListDeviceParams struct {
+	Keywords   *string // 按别名或MAC地址模糊查询
+	Board      *string
+	AppVersion *string
+	Telemetry  *DeviceTelemetryFilter
+}
+
+// DeviceTelemetryRepo 设备遥测数据访问接口
+type DeviceTelemetryRepo interface {
+	// Create 保存一次上报，并将该设备此前的上报标记为非最新
+	Create(ctx context.Context, telemetry *DeviceTelemetry) error
+
+	// ListByDevice 按上报时间倒序查询设备最近的上报
+	ListByDevice(ctx context.Context, deviceId string, limit int) ([]*DeviceTelemetry, error)
+
+	// GetLatestByDevices 批量查询设备最新一次上报，key为设备ID
+	GetLatestByDevices(ctx context.Context, deviceIds []string) (map[string]*DeviceTelemetry, error)
+
+	// DeleteBefore 删除指定时间之前的历史上报，每台设备最新一次上报不删除
+	DeleteBefore(ctx context.Context, before time.Time) (int, error)
+}
+
+// newDeviceTelemetry 从设备上报内容中提取遥测信息
+func newDeviceTelemetry(device *Device, report *DeviceReportReqDTO) *DeviceTelemetry {
+	telemetry := &DeviceTelemetry{
+		DeviceID:            device.ID,
+		MacAddress:          device.MacAddress,
+		Board:               device.Board,
+		AppVersion:          device.AppVersion,
+		FlashSize:           report.FlashSize,
+		MinimumFreeHeapSize: report.MinimumFreeHeapSize,
+		PartitionTable:      report.PartitionTable,
+	}
+	if report.ChipModelName != nil {
+		telemetry.ChipModelName = *report.ChipModelName
+	}
+	if app := report.Application; app != nil {
+		if app.Version != nil {
+			telemetry.AppVersion = *app.Version
+		}
+		if app.IdfVersion != nil {
+			telemetry.IdfVersion = *app.IdfVersion
+		}
+	}
+	if report.Ota != nil && report.Ota.Label != nil {
+		telemetry.OtaLabel = *report.Ota.Label
+	}
+	if board := report.Board; board != nil {
+		if board.Type != nil {
+			telemetry.Board = *board.Type
+		}
+		if board.SSID != nil {
+			telemetry.SSID = *board.SSID
+		}
+		if board.IP != nil {
+			telemetry.IP = *board.IP
+		}
+		telemetry.RSSI = board.RSSI
+		telemetry.Channel = board.Channel
+	}
+	return telemetry
+}
+
+// RecordTelemetry 保存设备本次/ota上报的遥测信息
+func (uc *DeviceUsecase) RecordTelemetry(ctx context.Context, device *Device, report *DeviceReportReqDTO) error {
+	if device == nil || report == nil {
+		return nil
+	}
+	if err := uc.telemetryRepo.Create(ctx, newDeviceTelemetry(device, report)); err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	return nil
+}
+
+// PageAdminDevices 管理端分页查询设备，同时返回各设备最新一次上报的遥测
+func (uc *DeviceUsecase) PageAdminDevices(ctx context.Context, params *ListDeviceParams, page *kit.PageRequest) ([]*Device, map[string]*DeviceTelemetry, int, error) {
+	total, err := uc.repo.TotalDevices(ctx, params)
+	if err != nil {
+		return nil, nil, 0, uc.handleError.ErrInternal(ctx, err)
+	}
+	devices, err := uc.repo.PageDevices(ctx, params, page)
+	if err != nil {
+		return nil, nil, 0, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	deviceIds := make([]string, len(devices))
+	for i, device := range devices {
+		deviceIds[i] = device.ID
+	}
+	telemetries, err := uc.telemetryRepo.GetLatestByDevices(ctx, deviceIds)
+	if err != nil {
+		return nil, nil, 0, uc.handleError.ErrInternal(ctx, err)
+	}
+	return devices, telemetries, total, nil
+}
+
+// GetAdminDeviceDetail 管理端查询设备详情及最近的遥测上报
+func (uc *DeviceUsecase) GetAdminDeviceDetail(ctx context.Context, deviceId string) (*Device, []*DeviceTelemetry, error) {
+	device, err := uc.repo.GetByID(ctx, deviceId)
+	if err != nil {
+		return nil, nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if device == nil {
+		return nil, nil, uc.handleError.ErrNotFound(ctx, fmt.Errorf("设备不存在"))
+	}
+	telemetries, err := uc.telemetryRepo.ListByDevice(ctx, deviceId, deviceTelemetryRecentLimit)
+	if err != nil {
+		return nil, nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	return device, telemetries, nil
+}
+
+// RunTelemetryRetention 定期清理过期的遥测上报，随应用启动，ctx取消时退出
+func (uc *DeviceUsecase) RunTelemetryRetention(ctx context.Context) {
+	ticker := time.NewTicker(deviceTelemetryCleanInterval)
+	defer ticker.Stop()
+
+	for {
+		uc.cleanExpiredTelemetry(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// cleanExpiredTelemetry 删除超过保留时长的遥测上报，通过Redis锁避免多副本重复执行
+func (uc *DeviceUsecase) cleanExpiredTelemetry(ctx context.Context) {
+	locked, err := uc.redisClient.GetClient().SetNX(ctx, kit.RedisKeyDeviceTelemetryCleanLock, "1", deviceTelemetryCleanLockTTL).Result()
+	if err != nil || !locked {
+		return
+	}
+	defer uc.redisClient.Delete(ctx, kit.RedisKeyDeviceTelemetryCleanLock)
+
+	deleted, err := uc.telemetryRepo.DeleteBefore(ctx, time.Now().Add(-deviceTelemetryRetention))
+	if err != nil {
+		uc.log.Errorf("清理过期设备遥测失败: %v", err)
+		return
+	}
+	if deleted > 0 {
+		uc.log.Infof("已清理过期设备遥测 %d 条", deleted)
+	}
+}
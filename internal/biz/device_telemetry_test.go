@@ -0,0 +1,47 @@
+package biz
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDeviceTelemetry(t *testing.T) {
+	str := func(v string) *string { return &v }
+	num := func(v int32) *int32 { return &v }
+	device := &Device{ID: "aa:bb:cc:dd:ee:ff", MacAddress: "aa:bb:cc:dd:ee:ff", Board: "bread-compact-wifi", AppVersion: "1.0.0"}
+
+	telemetry := newDeviceTelemetry(device, &DeviceReportReqDTO{
+		FlashSize:           num(16777216),
+		MinimumFreeHeapSize: num(38000),
+		ChipModelName:       str("esp32s3"),
+		Application:         &Application{Version: str("1.2.0"), IdfVersion: str("v5.3.1")},
+		PartitionTable:      []*Partition{{Label: str("ota_0"), Size: num(0x300000)}},
+		Ota:                 &OtaInfo{Label: str("ota_1")},
+		Board:               &BoardInfo{SSID: str("office"), RSSI: num(-82), Channel: num(6), IP: str("192.168.1.20")},
+	})
+	assert.Equal(t, device.ID, telemetry.DeviceID)
+	assert.Equal(t, "bread-compact-wifi", telemetry.Board)
+	assert.Equal(t, "1.2.0", telemetry.AppVersion)
+	assert.Equal(t, "esp32s3", telemetry.ChipModelName)
+	assert.Equal(t, "v5.3.1", telemetry.IdfVersion)
+	assert.Equal(t, "ota_1", telemetry.OtaLabel)
+	assert.Equal(t, "office", telemetry.SSID)
+	assert.Equal(t, int32(-82), *telemetry.RSSI)
+	assert.Equal(t, int32(38000), *telemetry.MinimumFreeHeapSize)
+	assert.Len(t, telemetry.PartitionTable, 1)
+
+	// 未上报的信息保持为空，不会被当作0参与筛选
+	telemetry = newDeviceTelemetry(device, &DeviceReportReqDTO{})
+	assert.Equal(t, "1.0.0", telemetry.AppVersion)
+	assert.Nil(t, telemetry.RSSI)
+	assert.Nil(t, telemetry.MinimumFreeHeapSize)
+}
+
+func TestDeviceTelemetryFilterIsEmpty(t *testing.T) {
+	var filter *DeviceTelemetryFilter
+	assert.True(t, filter.IsEmpty())
+	assert.True(t, (&DeviceTelemetryFilter{}).IsEmpty())
+	rssi := int32(-80)
+	assert.False(t, (&DeviceTelemetryFilter{RSSIBelow: &rssi}).IsEmpty())
+}
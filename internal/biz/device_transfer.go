@@ -0,0 +1,269 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 设备转移状态
+const (
+	DeviceTransferStatusPending   = "pending"   // 等待接收方确认
+	DeviceTransferStatusAccepted  = "accepted"  // 已接受，设备已转移
+	DeviceTransferStatusRejected  = "rejected"  // 接收方已拒绝
+	DeviceTransferStatusCancelled = "cancelled" // 发起方已取消，或设备归属已变更
+	DeviceTransferStatusExpired   = "expired"   // 超时未处理
+)
+
+// deviceTransferTTL 转移请求的有效期
+const deviceTransferTTL = 7 * 24 * time.Hour
+
+// DeviceTransfer 设备转移请求
+type DeviceTransfer struct {
+	ID             string
+	DeviceID       string
+	MacAddress     string
+	FromUserID     int64
+	ToUserID       int64
+	FromUsername   string
+	ToUsername     string
+	AgentID        string // 接收方接受时指定的智能体
+	MigrateHistory bool   // 是否将该设备的聊天记录迁移给接收方，否则保留在原用户的智能体下
+	Status         string
+	ExpiresAt      time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// isExpired 待确认的请求是否已超时
+func (t *DeviceTransfer) isExpired(now time.Time) bool {
+	return t.Status == DeviceTransferStatusPending && now.After(t.ExpiresAt)
+}
+
+// DeviceTransferRepo 设备转移数据访问接口
+type DeviceTransferRepo interface {
+	Create(ctx context.Context, transfer *DeviceTransfer) error
+	GetByID(ctx context.Context, id string) (*DeviceTransfer, error)
+
+	// GetPendingByDevice 查询设备待确认的转移请求，不存在时返回nil
+	GetPendingByDevice(ctx context.Context, deviceId string) (*DeviceTransfer, error)
+
+	// ListByUser 查询用户发起（incoming为false）或收到（incoming为true）的转移请求，按创建时间倒序
+	ListByUser(ctx context.Context, userId int64, incoming bool) ([]*DeviceTransfer, error)
+
+	// UpdateStatus 仅当请求处于fromStatus时更新状态，返回是否更新成功
+	UpdateStatus(ctx context.Context, id, fromStatus, toStatus string) (bool, error)
+
+	// Accept 在同一事务中标记请求已接受并变更设备归属和智能体，移除设备在原用户分组中的成员关系；
+	// historyAgentIds不为空时将设备在这些智能体下的聊天记录迁移到接收方的智能体。
+	// 请求已不是待确认状态时返回false
+	Accept(ctx context.Context, transfer *DeviceTransfer, historyAgentIds []string) (bool, error)
+}
+
+// CreateDeviceTransfer 设备所有者发起转移，接收方确认后生效
+func (uc *DeviceUsecase) CreateDeviceTransfer(ctx context.Context, userId int64, deviceId, toUsername string, migrateHistory bool) (*DeviceTransfer, error) {
+	device, err := uc.repo.GetByID(ctx, deviceId)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if device == nil {
+		return nil, uc.handleError.ErrNotFound(ctx, fmt.Errorf("设备不存在"))
+	}
+	if device.UserID != userId {
+		return nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("只有设备所有者可以发起转移"))
+	}
+
+	toUsername = strings.TrimSpace(toUsername)
+	if toUsername == "" {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("接收方用户名不能为空"))
+	}
+	recipient, err := uc.userRepo.GetByUsername(ctx, toUsername)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if recipient == nil {
+		return nil, uc.handleError.ErrNotFound(ctx, fmt.Errorf("接收方用户不存在"))
+	}
+	if recipient.ID == userId {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("不能将设备转移给自己"))
+	}
+
+	pending, err := uc.getPendingTransfer(ctx, deviceId)
+	if err != nil {
+		return nil, err
+	}
+	if pending != nil {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("该设备已有待确认的转移请求"))
+	}
+
+	now := time.Now()
+	transfer := &DeviceTransfer{
+		DeviceID:       device.ID,
+		MacAddress:     device.MacAddress,
+		FromUserID:     userId,
+		ToUserID:       recipient.ID,
+		ToUsername:     recipient.Username,
+		MigrateHistory: migrateHistory,
+		Status:         DeviceTransferStatusPending,
+		ExpiresAt:      now.Add(deviceTransferTTL),
+	}
+	if err := uc.transferRepo.Create(ctx, transfer); err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	return transfer, nil
+}
+
+// ListDeviceTransfers 查询用户发起或收到的转移请求
+func (uc *DeviceUsecase) ListDeviceTransfers(ctx context.Context, userId int64, incoming bool) ([]*DeviceTransfer, error) {
+	transfers, err := uc.transferRepo.ListByUser(ctx, userId, incoming)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	userIdSet := make(map[int64]bool)
+	now := time.Now()
+	for _, transfer := range transfers {
+		if transfer.isExpired(now) {
+			transfer.Status = DeviceTransferStatusExpired
+		}
+		userIdSet[transfer.FromUserID] = true
+		userIdSet[transfer.ToUserID] = true
+	}
+	if len(userIdSet) == 0 {
+		return transfers, nil
+	}
+	userIds := make([]int64, 0, len(userIdSet))
+	for id := range userIdSet {
+		userIds = append(userIds, id)
+	}
+	users, err := uc.userRepo.GetUsersByIDs(ctx, userIds)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	for _, transfer := range transfers {
+		if user := users[transfer.FromUserID]; user != nil {
+			transfer.FromUsername = user.Username
+		}
+		if user := users[transfer.ToUserID]; user != nil {
+			transfer.ToUsername = user.Username
+		}
+	}
+	return transfers, nil
+}
+
+// AcceptDeviceTransfer 接收方接受转移，设备归属和智能体原子地变更为接收方指定的智能体
+func (uc *DeviceUsecase) AcceptDeviceTransfer(ctx context.Context, userId int64, transferId, agentId string) (*DeviceTransfer, error) {
+	transfer, err := uc.getPendingTransferFor(ctx, transferId, userId, true)
+	if err != nil {
+		return nil, err
+	}
+
+	agent, _, err := uc.agentRepo.GetAgentByID(ctx, agentId)
+	if err != nil || agent == nil {
+		return nil, uc.handleError.ErrNotFound(ctx, fmt.Errorf("智能体不存在"))
+	}
+	if agent.UserID != userId {
+		return nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("无权使用该智能体"))
+	}
+
+	device, err := uc.repo.GetByID(ctx, transfer.DeviceID)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if device == nil || device.UserID != transfer.FromUserID {
+		// 发起后设备已被解绑或转移，请求随之失效
+		_, _ = uc.transferRepo.UpdateStatus(ctx, transfer.ID, DeviceTransferStatusPending, DeviceTransferStatusCancelled)
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("设备归属已变更，转移请求已失效"))
+	}
+
+	var historyAgentIds []string
+	if transfer.MigrateHistory {
+		agents, err := uc.agentRepo.ListUserAgents(ctx, transfer.FromUserID)
+		if err != nil {
+			return nil, uc.handleError.ErrInternal(ctx, err)
+		}
+		for _, item := range agents {
+			historyAgentIds = append(historyAgentIds, item.ID)
+		}
+	}
+
+	transfer.AgentID = agentId
+	accepted, err := uc.transferRepo.Accept(ctx, transfer, historyAgentIds)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if !accepted {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("转移请求已处理"))
+	}
+	transfer.Status = DeviceTransferStatusAccepted
+
+	uc.clearAgentDeviceCountCache(ctx, map[string]bool{device.AgentID: true, agentId: true})
+	return transfer, nil
+}
+
+// RejectDeviceTransfer 接收方拒绝转移
+func (uc *DeviceUsecase) RejectDeviceTransfer(ctx context.Context, userId int64, transferId string) error {
+	return uc.closeDeviceTransfer(ctx, userId, transferId, true, DeviceTransferStatusRejected)
+}
+
+// CancelDeviceTransfer 发起方取消转移
+func (uc *DeviceUsecase) CancelDeviceTransfer(ctx context.Context, userId int64, transferId string) error {
+	return uc.closeDeviceTransfer(ctx, userId, transferId, false, DeviceTransferStatusCancelled)
+}
+
+// closeDeviceTransfer 将待确认的请求置为拒绝或取消
+func (uc *DeviceUsecase) closeDeviceTransfer(ctx context.Context, userId int64, transferId string, recipient bool, status string) error {
+	transfer, err := uc.getPendingTransferFor(ctx, transferId, userId, recipient)
+	if err != nil {
+		return err
+	}
+	updated, err := uc.transferRepo.UpdateStatus(ctx, transfer.ID, DeviceTransferStatusPending, status)
+	if err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	if !updated {
+		return uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("转移请求已处理"))
+	}
+	return nil
+}
+
+// getPendingTransferFor 查询待确认的转移请求并校验当前用户是接收方（recipient为true）或发起方
+func (uc *DeviceUsecase) getPendingTransferFor(ctx context.Context, transferId string, userId int64, recipient bool) (*DeviceTransfer, error) {
+	transfer, err := uc.transferRepo.GetByID(ctx, transferId)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if transfer == nil {
+		return nil, uc.handleError.ErrNotFound(ctx, fmt.Errorf("转移请求不存在"))
+	}
+	if (recipient && transfer.ToUserID != userId) || (!recipient && transfer.FromUserID != userId) {
+		return nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("无权操作该转移请求"))
+	}
+	if transfer.isExpired(time.Now()) {
+		_, _ = uc.transferRepo.UpdateStatus(ctx, transfer.ID, DeviceTransferStatusPending, DeviceTransferStatusExpired)
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("转移请求已过期"))
+	}
+	if transfer.Status != DeviceTransferStatusPending {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("转移请求已处理"))
+	}
+	return transfer, nil
+}
+
+// getPendingTransfer 查询设备仍有效的待确认请求，已超时的请求标记为过期
+func (uc *DeviceUsecase) getPendingTransfer(ctx context.Context, deviceId string) (*DeviceTransfer, error) {
+	pending, err := uc.transferRepo.GetPendingByDevice(ctx, deviceId)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if pending == nil {
+		return nil, nil
+	}
+	if pending.isExpired(time.Now()) {
+		if _, err := uc.transferRepo.UpdateStatus(ctx, pending.ID, DeviceTransferStatusPending, DeviceTransferStatusExpired); err != nil {
+			return nil, uc.handleError.ErrInternal(ctx, err)
+		}
+		return nil, nil
+	}
+	return pending, nil
+}
@@ -0,0 +1,242 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceTransferLifecycle(t *testing.T) {
+	ctx := context.Background()
+	transferRepo := &memoryDeviceTransferRepo{transfers: map[string]*DeviceTransfer{}}
+	uc := NewDeviceUsecase(newStubDeviceRepo(), nil, nil, transferRepo, nil, newTransferUserRepo(), nil, log.DefaultLogger)
+
+	_, err := uc.CreateDeviceTransfer(ctx, 2, "aa:aa", "alice", false)
+	require.Error(t, err, "非设备所有者不能发起转移")
+	_, err = uc.CreateDeviceTransfer(ctx, 1, "aa:aa", "alice", false)
+	require.Error(t, err, "不能转移给自己")
+	_, err = uc.CreateDeviceTransfer(ctx, 1, "aa:aa", "carol", false)
+	require.Error(t, err, "接收方不存在")
+
+	transfer, err := uc.CreateDeviceTransfer(ctx, 1, "aa:aa", "bob", true)
+	require.NoError(t, err)
+	require.Equal(t, DeviceTransferStatusPending, transfer.Status)
+	_, err = uc.CreateDeviceTransfer(ctx, 1, "aa:aa", "bob", false)
+	require.Error(t, err, "已有待确认的请求")
+
+	// 只有接收方可以拒绝，拒绝后不能重复处理
+	require.Error(t, uc.RejectDeviceTransfer(ctx, 1, transfer.ID))
+	require.NoError(t, uc.RejectDeviceTransfer(ctx, 2, transfer.ID))
+	require.Error(t, uc.CancelDeviceTransfer(ctx, 1, transfer.ID))
+
+	// 超时的请求不再阻塞新的转移，列表中显示为过期
+	expired, err := uc.CreateDeviceTransfer(ctx, 1, "aa:aa", "bob", false)
+	require.NoError(t, err)
+	transferRepo.transfers[expired.ID].ExpiresAt = time.Now().Add(-time.Minute)
+	list, err := uc.ListDeviceTransfers(ctx, 2, true)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.Equal(t, DeviceTransferStatusExpired, list[0].Status)
+	require.Equal(t, "alice", list[0].FromUsername)
+
+	_, err = uc.CreateDeviceTransfer(ctx, 1, "aa:aa", "bob", false)
+	require.NoError(t, err)
+	require.Equal(t, DeviceTransferStatusExpired, transferRepo.transfers[expired.ID].Status)
+}
+
+func TestAcceptDeviceTransfer(t *testing.T) {
+	tests := []struct {
+		name        string
+		agentId     string
+		migrate     bool
+		expired     bool
+		reowned     bool // 发起后设备被其他用户重新绑定
+		acceptTwice bool
+		wantErr     string
+		wantStatus  string
+		wantHistory []string
+	}{
+		{name: "接收方必须拥有目标智能体", agentId: "alice-agent1", wantErr: "无权使用该智能体", wantStatus: DeviceTransferStatusPending},
+		{name: "目标智能体不存在", agentId: "missing", wantErr: "智能体不存在", wantStatus: DeviceTransferStatusPending},
+		{name: "超时的请求标记为过期", agentId: "bob-agent", expired: true, wantErr: "已过期", wantStatus: DeviceTransferStatusExpired},
+		{name: "设备归属已变更时请求失效", agentId: "bob-agent", reowned: true, wantErr: "设备归属已变更", wantStatus: DeviceTransferStatusCancelled},
+		{name: "重复接受返回已处理", agentId: "bob-agent", acceptTwice: true, wantErr: "已处理", wantStatus: DeviceTransferStatusAccepted},
+		{name: "不迁移聊天记录", agentId: "bob-agent", wantStatus: DeviceTransferStatusAccepted},
+		{
+			name:        "只迁移发起方智能体下的聊天记录",
+			agentId:     "bob-agent",
+			migrate:     true,
+			wantStatus:  DeviceTransferStatusAccepted,
+			wantHistory: []string{"alice-agent1", "alice-agent2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			deviceRepo := newStubDeviceRepo()
+			transferRepo := &memoryDeviceTransferRepo{transfers: map[string]*DeviceTransfer{}}
+			agentRepo := &stubTransferAgentRepo{agents: map[string]*Agent{
+				"alice-agent1": {ID: "alice-agent1", UserID: 1},
+				"alice-agent2": {ID: "alice-agent2", UserID: 1},
+				"bob-agent":    {ID: "bob-agent", UserID: 2},
+			}}
+			server := miniredis.RunT(t)
+			redisClient := kit.NewRedisClientWithClient(redis.NewClient(&redis.Options{Addr: server.Addr()}), log.DefaultLogger)
+			uc := NewDeviceUsecase(deviceRepo, nil, nil, transferRepo, agentRepo, newTransferUserRepo(), redisClient, log.DefaultLogger)
+
+			transfer, err := uc.CreateDeviceTransfer(ctx, 1, "aa:aa", "bob", tt.migrate)
+			require.NoError(t, err)
+			if tt.expired {
+				transferRepo.transfers[transfer.ID].ExpiresAt = time.Now().Add(-time.Minute)
+			}
+			if tt.reowned {
+				deviceRepo.devices["aa:aa"].UserID = 3
+			}
+
+			_, err = uc.AcceptDeviceTransfer(ctx, 2, transfer.ID, tt.agentId)
+			if tt.acceptTwice {
+				require.NoError(t, err)
+				_, err = uc.AcceptDeviceTransfer(ctx, 2, transfer.ID, tt.agentId)
+			}
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantStatus, transferRepo.transfers[transfer.ID].Status)
+			if tt.wantStatus == DeviceTransferStatusAccepted {
+				require.Equal(t, tt.agentId, transferRepo.transfers[transfer.ID].AgentID)
+				require.Equal(t, tt.wantHistory, transferRepo.historyAgentIds)
+			}
+		})
+	}
+}
+
+// newTransferUserRepo 转移测试使用的发起方alice和接收方bob
+func newTransferUserRepo() *memoryUserRepo {
+	return &memoryUserRepo{users: map[string]*User{
+		"alice": {ID: 1, Username: "alice"},
+		"bob":   {ID: 2, Username: "bob"},
+	}}
+}
+
+// stubTransferAgentRepo 返回固定的智能体
+type stubTransferAgentRepo struct {
+	AgentRepo
+	agents map[string]*Agent
+}
+
+func (r *stubTransferAgentRepo) GetAgentByID(ctx context.Context, id string) (*Agent, []*AgentPluginMapping, error) {
+	return r.agents[id], nil, nil
+}
+
+func (r *stubTransferAgentRepo) ListUserAgents(ctx context.Context, userId int64) ([]*AgentDTO, error) {
+	var result []*AgentDTO
+	for _, id := range []string{"alice-agent1", "alice-agent2", "bob-agent"} {
+		if agent := r.agents[id]; agent != nil && agent.UserID == userId {
+			result = append(result, &AgentDTO{ID: agent.ID})
+		}
+	}
+	return result, nil
+}
+
+// memoryDeviceTransferRepo 内存中的转移请求
+type memoryDeviceTransferRepo struct {
+	DeviceTransferRepo
+	transfers       map[string]*DeviceTransfer
+	order           []string
+	historyAgentIds []string
+}
+
+func (r *memoryDeviceTransferRepo) Create(ctx context.Context, transfer *DeviceTransfer) error {
+	transfer.ID = fmt.Sprintf("transfer%d", len(r.order)+1)
+	copied := *transfer
+	r.transfers[transfer.ID] = &copied
+	r.order = append(r.order, transfer.ID)
+	return nil
+}
+
+func (r *memoryDeviceTransferRepo) GetByID(ctx context.Context, id string) (*DeviceTransfer, error) {
+	if transfer, ok := r.transfers[id]; ok {
+		copied := *transfer
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (r *memoryDeviceTransferRepo) GetPendingByDevice(ctx context.Context, deviceId string) (*DeviceTransfer, error) {
+	for _, transfer := range r.transfers {
+		if transfer.DeviceID == deviceId && transfer.Status == DeviceTransferStatusPending {
+			copied := *transfer
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryDeviceTransferRepo) ListByUser(ctx context.Context, userId int64, incoming bool) ([]*DeviceTransfer, error) {
+	var result []*DeviceTransfer
+	for i := len(r.order) - 1; i >= 0; i-- {
+		transfer := r.transfers[r.order[i]]
+		if (incoming && transfer.ToUserID == userId) || (!incoming && transfer.FromUserID == userId) {
+			copied := *transfer
+			result = append(result, &copied)
+		}
+	}
+	return result, nil
+}
+
+func (r *memoryDeviceTransferRepo) UpdateStatus(ctx context.Context, id, fromStatus, toStatus string) (bool, error) {
+	transfer, ok := r.transfers[id]
+	if !ok || transfer.Status != fromStatus {
+		return false, nil
+	}
+	transfer.Status = toStatus
+	return true, nil
+}
+
+func (r *memoryDeviceTransferRepo) Accept(ctx context.Context, transfer *DeviceTransfer, historyAgentIds []string) (bool, error) {
+	stored, ok := r.transfers[transfer.ID]
+	if !ok || stored.Status != DeviceTransferStatusPending {
+		return false, nil
+	}
+	stored.Status = DeviceTransferStatusAccepted
+	stored.AgentID = transfer.AgentID
+	r.historyAgentIds = historyAgentIds
+	return true, nil
+}
+
+// memoryUserRepo 内存中的用户
+type memoryUserRepo struct {
+	UserRepo
+	users map[string]*User
+}
+
+func (r *memoryUserRepo) GetByUsername(ctx context.Context, username string) (*User, error) {
+	return r.users[username], nil
+}
+
+func (r *memoryUserRepo) GetByUserId(ctx context.Context, userId int64) (*User, error) {
+	for _, user := range r.users {
+		if user.ID == userId {
+			return user, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryUserRepo) GetUsersByIDs(ctx context.Context, userIds []int64) (map[int64]*User, error) {
+	result := make(map[int64]*User)
+	for _, user := range r.users {
+		result[user.ID] = user
+	}
+	return result, nil
+}
@@ -2,10 +2,10 @@ package biz
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"time"
 
+	"github.com/weetime/agent-matrix/internal/kit"
 	"github.com/weetime/agent-matrix/internal/kit/cerrors"
 
 	"github.com/go-kratos/kratos/v2/log"
@@ -23,8 +23,12 @@ type Document struct {
 	MetaFields   map[string]interface{}
 	ChunkMethod  string
 	ParserConfig map[string]interface{}
-	Status       int32  // 0-未开始，1-进行中，2-已取消，3-已完成，4-失败
-	Run          string // RAG状态字符串
+	Status       int32   // 0-未开始，1-进行中，2-已取消，3-已完成，4-失败
+	Run          string  // RAG状态字符串
+	Progress     float64 // 解析进度（0-1）
+	ProgressMsg  string  // 解析进度信息
+	ChunkCount   int64   // 切片数量
+	TokenCount   int64   // Token数量
 	Creator      int64
 	CreatedAt    time.Time
 	Updater      int64
@@ -45,6 +49,10 @@ type DocumentDTO struct {
 	ParserConfig map[string]interface{}
 	Status       int32  // 0-未开始，1-进行中，2-已取消，3-已完成，4-失败
 	Run          string // RAG状态字符串
+	Progress     float64
+	ProgressMsg  string
+	ChunkCount   int64
+	TokenCount   int64
 	Creator      string // 格式化为字符串
 	CreatedAt    string // 格式化为字符串
 	Updater      string // 格式化为字符串
@@ -63,10 +71,20 @@ type RAGAdapter interface {
 	DeleteDocument(ctx context.Context, datasetId, documentId string) error
 	// ParseDocuments 解析文档（切块）
 	ParseDocuments(ctx context.Context, datasetId string, documentIds []string) (bool, error)
+	// StopParseDocuments 停止正在解析的文档
+	StopParseDocuments(ctx context.Context, datasetId string, documentIds []string) error
 	// ListChunks 列出指定文档的切片
 	ListChunks(ctx context.Context, datasetId, documentId string, params map[string]interface{}) (map[string]interface{}, error)
 	// RetrievalTest 召回测试
 	RetrievalTest(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error)
+	// CreateDataset 创建数据集，返回RAG侧的数据集ID
+	CreateDataset(ctx context.Context, name, description string) (string, error)
+	// UpdateDataset 更新数据集名称和描述
+	UpdateDataset(ctx context.Context, datasetId, name, description string) error
+	// DeleteDataset 删除数据集
+	DeleteDataset(ctx context.Context, datasetId string) error
+	// GetDocumentCount 获取数据集中的文档数量
+	GetDocumentCount(ctx context.Context, datasetId string) (int, error)
 }
 
 // RAGAdapterFactory RAG适配器工厂
@@ -79,6 +97,7 @@ type DocumentUsecase struct {
 	datasetUsecase    *DatasetUsecase
 	modelConfigRepo   ModelConfigRepo
 	ragAdapterFactory RAGAdapterFactory
+	redisClient       *kit.RedisClient
 	log               *log.Helper
 	handleError       *cerrors.HandleError
 }
@@ -88,12 +107,14 @@ func NewDocumentUsecase(
 	datasetUsecase *DatasetUsecase,
 	modelConfigRepo ModelConfigRepo,
 	ragAdapterFactory RAGAdapterFactory,
+	redisClient *kit.RedisClient,
 	logger log.Logger,
 ) *DocumentUsecase {
 	return &DocumentUsecase{
 		datasetUsecase:    datasetUsecase,
 		modelConfigRepo:   modelConfigRepo,
 		ragAdapterFactory: ragAdapterFactory,
+		redisClient:       redisClient,
 		log:               log.NewHelper(log.With(logger, "module", "biz/document")),
 		handleError:       cerrors.NewHandleError(logger),
 	}
@@ -203,29 +224,6 @@ func (uc *DocumentUsecase) DeleteDocument(ctx context.Context, datasetId, docume
 	return nil
 }
 
-// ParseDocuments 解析文档（切块）
-func (uc *DocumentUsecase) ParseDocuments(ctx context.Context, datasetId string, documentIds []string) (bool, error) {
-	// 获取RAG配置
-	ragConfig, err := uc.getRAGConfig(ctx, datasetId)
-	if err != nil {
-		return false, uc.handleError.ErrInternal(ctx, err)
-	}
-
-	// 获取适配器
-	adapter, err := uc.getAdapter(ragConfig)
-	if err != nil {
-		return false, uc.handleError.ErrInternal(ctx, err)
-	}
-
-	// 调用适配器解析文档
-	success, err := adapter.ParseDocuments(ctx, datasetId, documentIds)
-	if err != nil {
-		return false, uc.handleError.ErrInternal(ctx, err)
-	}
-
-	return success, nil
-}
-
 // ListChunks 列出文档切片
 func (uc *DocumentUsecase) ListChunks(ctx context.Context, datasetId, documentId string, keywords *string, page, pageSize int, chunkId *string) (map[string]interface{}, error) {
 	// 获取RAG配置
@@ -338,40 +336,12 @@ func (uc *DocumentUsecase) getRAGConfig(ctx context.Context, datasetId string) (
 		return nil, fmt.Errorf("获取知识库失败: %w", err)
 	}
 
-	// 获取RAG模型配置
-	modelConfig, err := uc.modelConfigRepo.GetModelConfigByID(ctx, dataset.RagModelID)
-	if err != nil {
-		return nil, fmt.Errorf("获取RAG模型配置失败: %w", err)
-	}
-
-	// 解析ConfigJSON
-	var ragConfig map[string]interface{}
-	if modelConfig.ConfigJSON != "" {
-		if err := json.Unmarshal([]byte(modelConfig.ConfigJSON), &ragConfig); err != nil {
-			return nil, fmt.Errorf("解析RAG配置JSON失败: %w", err)
-		}
-	} else {
-		ragConfig = make(map[string]interface{})
-	}
-
-	// 从配置中提取适配器类型，如果没有则默认使用ragflow
-	adapterType, ok := ragConfig["type"].(string)
-	if !ok || adapterType == "" {
-		adapterType = "ragflow"
-		ragConfig["type"] = adapterType
-	}
-
-	return ragConfig, nil
+	return loadRAGConfig(ctx, uc.modelConfigRepo, dataset.RagModelID)
 }
 
 // getAdapter 获取RAG适配器
 func (uc *DocumentUsecase) getAdapter(ragConfig map[string]interface{}) (RAGAdapter, error) {
-	adapterType, ok := ragConfig["adapter_type"].(string)
-	if !ok || adapterType == "" {
-		adapterType = "ragflow" // 默认使用ragflow
-	}
-
-	adapter, err := uc.ragAdapterFactory.GetAdapter(adapterType, ragConfig)
+	adapter, err := uc.ragAdapterFactory.GetAdapter(ragAdapterType(ragConfig), ragConfig)
 	if err != nil {
 		return nil, fmt.Errorf("获取RAG适配器失败: %w", err)
 	}
@@ -379,6 +349,15 @@ func (uc *DocumentUsecase) getAdapter(ragConfig map[string]interface{}) (RAGAdap
 	return adapter, nil
 }
 
+// getDatasetAdapter 获取知识库对应的RAG适配器
+func (uc *DocumentUsecase) getDatasetAdapter(ctx context.Context, datasetId string) (RAGAdapter, error) {
+	ragConfig, err := uc.getRAGConfig(ctx, datasetId)
+	if err != nil {
+		return nil, err
+	}
+	return uc.getAdapter(ragConfig)
+}
+
 // toDTO 转换为DTO
 func (uc *DocumentUsecase) toDTO(document *Document) *DocumentDTO {
 	creator := ""
@@ -410,6 +389,10 @@ func (uc *DocumentUsecase) toDTO(document *Document) *DocumentDTO {
 		ParserConfig: document.ParserConfig,
 		Status:       document.Status,
 		Run:          document.Run,
+		Progress:     document.Progress,
+		ProgressMsg:  document.ProgressMsg,
+		ChunkCount:   document.ChunkCount,
+		TokenCount:   document.TokenCount,
 		Creator:      creator,
 		CreatedAt:    document.CreatedAt.Format("2006-01-02 15:04:05"),
 		Updater:      updater,
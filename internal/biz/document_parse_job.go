@@ -0,0 +1,546 @@
+package biz
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/constant"
+	"github.com/weetime/agent-matrix/internal/kit"
+	"github.com/weetime/agent-matrix/internal/middleware"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// 文档解析任务队列参数
+const (
+	parseJobWorkers       = 2                  // 单个节点并发执行的任务数
+	parseJobLeaseTTL      = 60 * time.Second   // 执行节点的租约时长，节点宕机后租约过期任务被重新入队
+	parseJobSweepInterval = 30 * time.Second   // 检查失效租约的间隔
+	parseJobTimeout       = 2 * time.Hour      // 单个文档解析的超时时间
+	parseJobRetention     = 7 * 24 * time.Hour // 已结束任务的保留时长
+	parseJobMaxPollErrors = 5                  // 连续查询进度失败的最大次数
+	parseJobUpdateRetries = 10                 // 并发修改任务冲突时的最大重试次数
+)
+
+// parseJobPollInterval 轮询RAG解析进度的间隔
+var parseJobPollInterval = 3 * time.Second
+
+// DocumentParseJob 文档解析任务
+type DocumentParseJob struct {
+	ID         string     `json:"id"`
+	DatasetID  string     `json:"dataset_id"`
+	DocumentID string     `json:"document_id"`
+	Status     string     `json:"status"`
+	Progress   float64    `json:"progress"`
+	Message    string     `json:"message"`
+	Attempt    int        `json:"attempt"`   // 第几次解析，重试时递增
+	Submitted  bool       `json:"submitted"` // 是否已提交给RAG适配器，重启恢复时避免重复提交
+	Creator    int64      `json:"creator"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// IsActive 任务是否仍在排队或执行中
+func (j *DocumentParseJob) IsActive() bool {
+	return j.Status == constant.ParseJobStatusQueued || j.Status == constant.ParseJobStatusRunning
+}
+
+// ParseDocuments 为文档创建解析任务并加入队列，由后台任务异步提交给RAG适配器并跟踪进度
+func (uc *DocumentUsecase) ParseDocuments(ctx context.Context, datasetId string, documentIds []string) ([]*DocumentParseJob, error) {
+	adapter, err := uc.getDatasetAdapter(ctx, datasetId)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	attempts := make(map[string]int, len(documentIds))
+	for _, documentId := range documentIds {
+		if _, err := adapter.GetDocumentById(ctx, datasetId, documentId); err != nil {
+			return nil, uc.handleError.ErrNotFound(ctx, err)
+		}
+		latest, err := uc.getLatestParseJob(ctx, documentId)
+		if err != nil {
+			return nil, uc.handleError.ErrInternal(ctx, err)
+		}
+		if latest != nil && latest.IsActive() {
+			return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("文档正在解析中: %s", documentId))
+		}
+		attempts[documentId] = 1
+		if latest != nil {
+			attempts[documentId] = latest.Attempt + 1
+		}
+	}
+
+	return uc.enqueueParseJobs(ctx, datasetId, documentIds, attempts)
+}
+
+// RetryParseDocuments 重新解析失败或已取消的文档
+func (uc *DocumentUsecase) RetryParseDocuments(ctx context.Context, datasetId string, documentIds []string) ([]*DocumentParseJob, error) {
+	adapter, err := uc.getDatasetAdapter(ctx, datasetId)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	attempts := make(map[string]int, len(documentIds))
+	for _, documentId := range documentIds {
+		latest, err := uc.getLatestParseJob(ctx, documentId)
+		if err != nil {
+			return nil, uc.handleError.ErrInternal(ctx, err)
+		}
+		if latest != nil && latest.DatasetID == datasetId {
+			if latest.Status != constant.ParseJobStatusFailed && latest.Status != constant.ParseJobStatusCancelled {
+				return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("只能重试失败或已取消的解析任务: %s", documentId))
+			}
+			attempts[documentId] = latest.Attempt + 1
+			continue
+		}
+
+		// 没有任务记录时（如任务已过期）以文档状态为准
+		doc, err := adapter.GetDocumentById(ctx, datasetId, documentId)
+		if err != nil {
+			return nil, uc.handleError.ErrNotFound(ctx, err)
+		}
+		if doc.Status != constant.DocumentStatusFailed && doc.Status != constant.DocumentStatusCancelled {
+			return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("只能重试解析失败或已取消的文档: %s", documentId))
+		}
+		attempts[documentId] = 2
+	}
+
+	return uc.enqueueParseJobs(ctx, datasetId, documentIds, attempts)
+}
+
+// CancelParseDocuments 取消排队中或解析中的文档，返回被取消的任务
+func (uc *DocumentUsecase) CancelParseDocuments(ctx context.Context, datasetId string, documentIds []string) ([]*DocumentParseJob, error) {
+	adapter, err := uc.getDatasetAdapter(ctx, datasetId)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	client := uc.redisClient.GetClient()
+	cancelled := make([]*DocumentParseJob, 0, len(documentIds))
+	for _, documentId := range documentIds {
+		job, err := uc.getLatestParseJob(ctx, documentId)
+		if err != nil {
+			return nil, uc.handleError.ErrInternal(ctx, err)
+		}
+		if job == nil || job.DatasetID != datasetId || !job.IsActive() {
+			continue
+		}
+
+		if job.Submitted {
+			if err := adapter.StopParseDocuments(ctx, datasetId, []string{documentId}); err != nil {
+				return nil, uc.handleError.ErrInternal(ctx, fmt.Errorf("停止解析失败: %w", err))
+			}
+		} else {
+			client.LRem(ctx, kit.RedisKeyDocumentParseQueue, 0, job.ID)
+		}
+
+		updated, err := uc.updateParseJob(ctx, job.ID, func(job *DocumentParseJob) {
+			uc.finishParseJob(job, constant.ParseJobStatusCancelled, "解析已取消")
+		})
+		if err != nil {
+			return nil, uc.handleError.ErrInternal(ctx, err)
+		}
+		if updated == nil {
+			// 任务已在此期间结束
+			continue
+		}
+		cancelled = append(cancelled, updated)
+	}
+
+	if len(cancelled) == 0 {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("没有可取消的解析任务"))
+	}
+	return cancelled, nil
+}
+
+// ListParseJobs 查询知识库的解析任务，按创建时间倒序
+func (uc *DocumentUsecase) ListParseJobs(ctx context.Context, datasetId string, status string) ([]*DocumentParseJob, error) {
+	client := uc.redisClient.GetClient()
+	datasetKey := kit.GetDocumentParseJobsByDatasetKey(datasetId)
+	jobIds, err := client.SMembers(ctx, datasetKey).Result()
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	jobs := make([]*DocumentParseJob, 0, len(jobIds))
+	for _, jobId := range jobIds {
+		job, err := uc.getParseJob(ctx, jobId)
+		if err != nil {
+			return nil, uc.handleError.ErrInternal(ctx, err)
+		}
+		if job == nil {
+			// 任务已过期，清理索引
+			client.SRem(ctx, datasetKey, jobId)
+			continue
+		}
+		if status != "" && job.Status != status {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+	return jobs, nil
+}
+
+// RunParseWorker 执行解析任务队列，随应用启动，ctx取消时退出
+// 任务ID在执行期间保存在processing列表中并由租约保护，节点重启或宕机后由sweepParseJobs重新入队
+func (uc *DocumentUsecase) RunParseWorker(ctx context.Context) {
+	client := uc.redisClient.GetClient()
+	go uc.sweepParseJobs(ctx)
+
+	slots := make(chan struct{}, parseJobWorkers)
+	for {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		jobId, err := client.BLMove(ctx, kit.RedisKeyDocumentParseQueue, kit.RedisKeyDocumentParseProcessing, "RIGHT", "LEFT", 5*time.Second).Result()
+		if err != nil {
+			<-slots
+			if ctx.Err() != nil {
+				return
+			}
+			if !errors.Is(err, redis.Nil) {
+				uc.log.Errorf("获取解析任务失败: %v", err)
+				sleepContext(ctx, time.Second)
+			}
+			continue
+		}
+
+		go func() {
+			defer func() { <-slots }()
+			uc.runParseJob(ctx, jobId)
+		}()
+	}
+}
+
+// runParseJob 执行单个解析任务，任务结束后从processing列表移除
+func (uc *DocumentUsecase) runParseJob(ctx context.Context, jobId string) {
+	client := uc.redisClient.GetClient()
+	leaseKey := kit.GetDocumentParseJobLeaseKey(jobId)
+	client.Set(ctx, leaseKey, "1", parseJobLeaseTTL)
+
+	if !uc.executeParseJob(ctx, jobId) {
+		// 应用退出，保留在processing列表中等待租约过期后重新入队
+		return
+	}
+
+	client.LRem(context.WithoutCancel(ctx), kit.RedisKeyDocumentParseProcessing, 0, jobId)
+	client.Del(context.WithoutCancel(ctx), leaseKey)
+}
+
+// executeParseJob 提交解析并轮询进度，返回任务是否已结束
+func (uc *DocumentUsecase) executeParseJob(ctx context.Context, jobId string) bool {
+	job, err := uc.getParseJob(ctx, jobId)
+	if err != nil {
+		uc.log.Errorf("读取解析任务失败, jobId: %s, error: %v", jobId, err)
+		return ctx.Err() == nil
+	}
+	if job == nil || !job.IsActive() {
+		return true
+	}
+
+	adapter, err := uc.getDatasetAdapter(ctx, job.DatasetID)
+	if err != nil {
+		return uc.failParseJob(ctx, job, err.Error())
+	}
+	return uc.processParseJob(ctx, adapter, job)
+}
+
+// processParseJob 提交解析并轮询进度直到任务结束
+// 任务状态每次都以乐观锁修改，不会覆盖其他节点（如取消接口）写入的结束状态
+func (uc *DocumentUsecase) processParseJob(ctx context.Context, adapter RAGAdapter, job *DocumentParseJob) bool {
+	// 已提交过的任务再次被取出，说明原执行节点的租约已失效，进程内的解析（如本地适配器）已随节点丢失，需停止后重新提交
+	if job.Submitted {
+		uc.log.Infof("解析任务租约已失效，重新提交解析, jobId: %s, documentId: %s", job.ID, job.DocumentID)
+		if err := adapter.StopParseDocuments(ctx, job.DatasetID, []string{job.DocumentID}); err != nil {
+			uc.log.Warnf("停止原解析失败, documentId: %s, error: %v", job.DocumentID, err)
+		}
+	}
+
+	if _, err := adapter.ParseDocuments(ctx, job.DatasetID, []string{job.DocumentID}); err != nil {
+		if ctx.Err() != nil {
+			return false
+		}
+		return uc.failParseJob(ctx, job, fmt.Sprintf("提交解析失败: %v", err))
+	}
+	updated, err := uc.updateParseJob(ctx, job.ID, func(job *DocumentParseJob) {
+		now := time.Now()
+		job.Submitted = true
+		job.Status = constant.ParseJobStatusRunning
+		job.StartedAt = &now
+		job.Message = "已提交解析"
+	})
+	if err != nil {
+		uc.log.Errorf("保存解析任务失败, jobId: %s, error: %v", job.ID, err)
+	} else if updated == nil {
+		// 提交期间任务已被取消
+		if err := adapter.StopParseDocuments(ctx, job.DatasetID, []string{job.DocumentID}); err != nil {
+			uc.log.Warnf("停止已取消的解析失败, documentId: %s, error: %v", job.DocumentID, err)
+		}
+		return true
+	}
+
+	client := uc.redisClient.GetClient()
+	leaseKey := kit.GetDocumentParseJobLeaseKey(job.ID)
+	ticker := time.NewTicker(parseJobPollInterval)
+	defer ticker.Stop()
+
+	pollErrors := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+		client.Expire(ctx, leaseKey, parseJobLeaseTTL)
+
+		doc, err := adapter.GetDocumentById(ctx, job.DatasetID, job.DocumentID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return false
+			}
+			pollErrors++
+			if pollErrors >= parseJobMaxPollErrors {
+				return uc.failParseJob(ctx, job, fmt.Sprintf("查询解析进度失败: %v", err))
+			}
+			continue
+		}
+		pollErrors = 0
+
+		timedOut := false
+		updated, err := uc.updateParseJob(ctx, job.ID, func(job *DocumentParseJob) {
+			job.Progress = doc.Progress
+			if doc.ProgressMsg != "" {
+				job.Message = doc.ProgressMsg
+			}
+			switch doc.Status {
+			case constant.DocumentStatusDone:
+				job.Progress = 1
+				uc.finishParseJob(job, constant.ParseJobStatusDone, job.Message)
+			case constant.DocumentStatusFailed:
+				uc.finishParseJob(job, constant.ParseJobStatusFailed, job.Message)
+			case constant.DocumentStatusCancelled:
+				uc.finishParseJob(job, constant.ParseJobStatusCancelled, job.Message)
+			default:
+				if job.StartedAt != nil && time.Since(*job.StartedAt) > parseJobTimeout {
+					timedOut = true
+					uc.finishParseJob(job, constant.ParseJobStatusFailed, "解析超时")
+				}
+			}
+		})
+		if err != nil {
+			uc.log.Errorf("保存解析任务失败, jobId: %s, error: %v", job.ID, err)
+			continue
+		}
+		if updated == nil {
+			// 任务已被取消接口结束
+			return true
+		}
+		if timedOut {
+			uc.log.Errorf("文档解析任务超时, jobId: %s, documentId: %s", job.ID, job.DocumentID)
+			if err := adapter.StopParseDocuments(ctx, job.DatasetID, []string{job.DocumentID}); err != nil {
+				uc.log.Warnf("停止超时解析失败, documentId: %s, error: %v", job.DocumentID, err)
+			}
+		}
+		if !updated.IsActive() {
+			return true
+		}
+	}
+}
+
+// sweepParseJobs 定期将租约失效的任务从processing列表移回队列
+// 连续两次检查都没有租约才重新入队，避免与刚取出任务尚未写入租约的节点竞争
+func (uc *DocumentUsecase) sweepParseJobs(ctx context.Context) {
+	client := uc.redisClient.GetClient()
+	ticker := time.NewTicker(parseJobSweepInterval)
+	defer ticker.Stop()
+
+	suspects := make(map[string]bool)
+	for {
+		jobIds, err := client.LRange(ctx, kit.RedisKeyDocumentParseProcessing, 0, -1).Result()
+		if err != nil && ctx.Err() == nil {
+			uc.log.Errorf("检查解析任务租约失败: %v", err)
+		}
+
+		next := make(map[string]bool)
+		for _, jobId := range jobIds {
+			alive, err := uc.redisClient.Exists(ctx, kit.GetDocumentParseJobLeaseKey(jobId))
+			if err != nil || alive {
+				continue
+			}
+			if !suspects[jobId] {
+				next[jobId] = true
+				continue
+			}
+			if removed, err := client.LRem(ctx, kit.RedisKeyDocumentParseProcessing, 1, jobId).Result(); err == nil && removed > 0 {
+				client.RPush(ctx, kit.RedisKeyDocumentParseQueue, jobId)
+				uc.log.Infof("解析任务租约已失效，重新入队, jobId: %s", jobId)
+			}
+		}
+		suspects = next
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// enqueueParseJobs 创建解析任务并加入队列
+func (uc *DocumentUsecase) enqueueParseJobs(ctx context.Context, datasetId string, documentIds []string, attempts map[string]int) ([]*DocumentParseJob, error) {
+	creator, _ := middleware.GetUserIdFromContext(ctx)
+	client := uc.redisClient.GetClient()
+
+	jobs := make([]*DocumentParseJob, 0, len(documentIds))
+	for _, documentId := range documentIds {
+		now := time.Now()
+		job := &DocumentParseJob{
+			ID:         strings.ReplaceAll(uuid.New().String(), "-", ""),
+			DatasetID:  datasetId,
+			DocumentID: documentId,
+			Status:     constant.ParseJobStatusQueued,
+			Message:    "等待解析",
+			Attempt:    attempts[documentId],
+			Creator:    creator,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		}
+		if err := uc.saveParseJob(ctx, job); err != nil {
+			return nil, uc.handleError.ErrInternal(ctx, err)
+		}
+		if err := uc.redisClient.Set(ctx, kit.GetDocumentParseJobByDocumentKey(documentId), job.ID, 0); err != nil {
+			return nil, uc.handleError.ErrInternal(ctx, err)
+		}
+		if err := client.SAdd(ctx, kit.GetDocumentParseJobsByDatasetKey(datasetId), job.ID).Err(); err != nil {
+			return nil, uc.handleError.ErrInternal(ctx, err)
+		}
+		if err := client.LPush(ctx, kit.RedisKeyDocumentParseQueue, job.ID).Err(); err != nil {
+			return nil, uc.handleError.ErrInternal(ctx, err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// failParseJob 将任务标记为失败，返回true表示任务已结束
+func (uc *DocumentUsecase) failParseJob(ctx context.Context, job *DocumentParseJob, msg string) bool {
+	uc.log.Errorf("文档解析任务失败, jobId: %s, documentId: %s, error: %s", job.ID, job.DocumentID, msg)
+	if _, err := uc.updateParseJob(ctx, job.ID, func(job *DocumentParseJob) {
+		uc.finishParseJob(job, constant.ParseJobStatusFailed, msg)
+	}); err != nil {
+		uc.log.Errorf("保存解析任务失败, jobId: %s, error: %v", job.ID, err)
+	}
+	return true
+}
+
+// finishParseJob 设置任务的结束状态
+func (uc *DocumentUsecase) finishParseJob(job *DocumentParseJob, status string, msg string) {
+	now := time.Now()
+	job.Status = status
+	job.Message = msg
+	job.FinishedAt = &now
+}
+
+// saveParseJob 保存任务，已结束的任务在保留期后过期
+func (uc *DocumentUsecase) saveParseJob(ctx context.Context, job *DocumentParseJob) error {
+	job.UpdatedAt = time.Now()
+	var expiration time.Duration
+	if !job.IsActive() {
+		expiration = parseJobRetention
+	}
+	return uc.redisClient.SetObject(ctx, kit.GetDocumentParseJobKey(job.ID), job, expiration)
+}
+
+// updateParseJob 以乐观锁修改未结束的任务并保存，任务不存在或已结束时不修改并返回nil
+func (uc *DocumentUsecase) updateParseJob(ctx context.Context, jobId string, update func(job *DocumentParseJob)) (*DocumentParseJob, error) {
+	client := uc.redisClient.GetClient()
+	key := kit.GetDocumentParseJobKey(jobId)
+	for i := 0; i < parseJobUpdateRetries; i++ {
+		var updated *DocumentParseJob
+		err := client.Watch(ctx, func(tx *redis.Tx) error {
+			value, err := tx.Get(ctx, key).Result()
+			if errors.Is(err, redis.Nil) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			var job DocumentParseJob
+			if err := json.Unmarshal([]byte(value), &job); err != nil {
+				return err
+			}
+			if !job.IsActive() {
+				return nil
+			}
+
+			update(&job)
+			job.UpdatedAt = time.Now()
+			var expiration time.Duration
+			if !job.IsActive() {
+				expiration = parseJobRetention
+			}
+			data, err := json.Marshal(&job)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(ctx, key, data, expiration)
+				return nil
+			}); err != nil {
+				return err
+			}
+			updated = &job
+			return nil
+		}, key)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		return updated, err
+	}
+	return nil, fmt.Errorf("修改解析任务冲突次数过多: %s", jobId)
+}
+
+// getParseJob 获取任务，不存在时返回nil
+func (uc *DocumentUsecase) getParseJob(ctx context.Context, jobId string) (*DocumentParseJob, error) {
+	var job DocumentParseJob
+	if err := uc.redisClient.GetObject(ctx, kit.GetDocumentParseJobKey(jobId), &job); err != nil {
+		return nil, err
+	}
+	if job.ID == "" {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+// getLatestParseJob 获取文档最近一次解析任务
+func (uc *DocumentUsecase) getLatestParseJob(ctx context.Context, documentId string) (*DocumentParseJob, error) {
+	jobId, err := uc.redisClient.Get(ctx, kit.GetDocumentParseJobByDocumentKey(documentId))
+	if err != nil || jobId == "" {
+		return nil, err
+	}
+	return uc.getParseJob(ctx, jobId)
+}
+
+// sleepContext 等待指定时长或ctx取消
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
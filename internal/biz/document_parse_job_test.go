@@ -0,0 +1,146 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/constant"
+	"github.com/weetime/agent-matrix/internal/kit"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessParseJob(t *testing.T) {
+	interval := parseJobPollInterval
+	parseJobPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { parseJobPollInterval = interval })
+
+	tests := []struct {
+		name      string
+		submitted bool  // 任务是否已由租约失效的节点提交过
+		docStatus int32 // 取出任务时文档的状态
+		onPoll    func(uc *DocumentUsecase, jobId string, adapter *fakeParseAdapter)
+		wantStops int
+		wantJob   string
+		wantProg  float64
+	}{
+		{
+			name:      "新任务提交后完成",
+			docStatus: constant.DocumentStatusUnstart,
+			onPoll: func(uc *DocumentUsecase, jobId string, adapter *fakeParseAdapter) {
+				adapter.status = constant.DocumentStatusDone
+			},
+			wantJob:  constant.ParseJobStatusDone,
+			wantProg: 1,
+		},
+		{
+			// 原节点重启后进程内的解析已丢失，文档停留在解析中，需停止后重新提交
+			name:      "租约失效后重新提交",
+			submitted: true,
+			docStatus: constant.DocumentStatusRunning,
+			onPoll: func(uc *DocumentUsecase, jobId string, adapter *fakeParseAdapter) {
+				adapter.status = constant.DocumentStatusDone
+			},
+			wantStops: 1,
+			wantJob:   constant.ParseJobStatusDone,
+			wantProg:  1,
+		},
+		{
+			name:      "轮询期间被取消不覆盖取消状态",
+			docStatus: constant.DocumentStatusUnstart,
+			onPoll: func(uc *DocumentUsecase, jobId string, adapter *fakeParseAdapter) {
+				_, err := uc.updateParseJob(context.Background(), jobId, func(job *DocumentParseJob) {
+					uc.finishParseJob(job, constant.ParseJobStatusCancelled, "解析已取消")
+				})
+				require.NoError(t, err)
+				adapter.progress = 0.5
+			},
+			wantJob: constant.ParseJobStatusCancelled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			uc := newTestParseJobUsecase(t)
+			job := &DocumentParseJob{
+				ID:         "job-1",
+				DatasetID:  "dataset-1",
+				DocumentID: "doc-1",
+				Status:     constant.ParseJobStatusQueued,
+				Submitted:  tt.submitted,
+			}
+			if tt.submitted {
+				job.Status = constant.ParseJobStatusRunning
+			}
+			require.NoError(t, uc.saveParseJob(ctx, job))
+
+			adapter := &fakeParseAdapter{status: tt.docStatus, onPoll: func(adapter *fakeParseAdapter) {
+				tt.onPoll(uc, job.ID, adapter)
+			}}
+			require.True(t, uc.processParseJob(ctx, adapter, job))
+
+			saved, err := uc.getParseJob(ctx, job.ID)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantJob, saved.Status)
+			require.Equal(t, tt.wantProg, saved.Progress)
+			require.Equal(t, 1, adapter.parses)
+			require.Equal(t, tt.wantStops, adapter.stops)
+		})
+	}
+}
+
+func newTestParseJobUsecase(t *testing.T) *DocumentUsecase {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	return NewDocumentUsecase(nil, nil, nil, kit.NewRedisClientWithClient(client, log.DefaultLogger), log.DefaultLogger)
+}
+
+// fakeParseAdapter 按本地适配器的规则模拟文档解析状态
+type fakeParseAdapter struct {
+	RAGAdapter
+	mu       sync.Mutex
+	status   int32
+	progress float64
+	parses   int
+	stops    int
+	polled   bool
+	onPoll   func(adapter *fakeParseAdapter)
+}
+
+func (a *fakeParseAdapter) ParseDocuments(ctx context.Context, datasetId string, documentIds []string) (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.status == constant.DocumentStatusRunning {
+		return false, fmt.Errorf("文档正在解析中")
+	}
+	a.parses++
+	a.status = constant.DocumentStatusRunning
+	return true, nil
+}
+
+func (a *fakeParseAdapter) StopParseDocuments(ctx context.Context, datasetId string, documentIds []string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stops++
+	if a.status == constant.DocumentStatusRunning {
+		a.status = constant.DocumentStatusCancelled
+	}
+	return nil
+}
+
+func (a *fakeParseAdapter) GetDocumentById(ctx context.Context, datasetId, documentId string) (*Document, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.polled {
+		a.polled = true
+		a.onPoll(a)
+	}
+	return &Document{ID: documentId, Status: a.status, Progress: a.progress}, nil
+}
@@ -106,11 +106,12 @@ type ModelProviderRepo interface {
 
 // ModelUsecase 模型配置业务逻辑
 type ModelUsecase struct {
-	repo         ModelConfigRepo
-	providerRepo ModelProviderRepo
-	agentRepo    AgentRepo // 用于检查引用
-	handleError  *cerrors.HandleError
-	log          *log.Helper
+	repo           ModelConfigRepo
+	providerRepo   ModelProviderRepo
+	agentRepo      AgentRepo // 用于检查引用
+	configNotifier *ConfigNotifier
+	handleError    *cerrors.HandleError
+	log            *log.Helper
 }
 
 // NewModelUsecase 创建模型配置用例
@@ -118,14 +119,16 @@ func NewModelUsecase(
 	repo ModelConfigRepo,
 	providerRepo ModelProviderRepo,
 	agentRepo AgentRepo,
+	configNotifier *ConfigNotifier,
 	logger log.Logger,
 ) *ModelUsecase {
 	return &ModelUsecase{
-		repo:         repo,
-		providerRepo: providerRepo,
-		agentRepo:    agentRepo,
-		handleError:  cerrors.NewHandleError(logger),
-		log:          kit.LogHelper(logger),
+		repo:           repo,
+		providerRepo:   providerRepo,
+		agentRepo:      agentRepo,
+		configNotifier: configNotifier,
+		handleError:    cerrors.NewHandleError(logger),
+		log:            kit.LogHelper(logger),
 	}
 }
 
@@ -225,7 +228,13 @@ func (uc *ModelUsecase) AddModelConfig(ctx context.Context, modelType string, pr
 		config.UpdateDate = time.Now()
 	}
 
-	return uc.repo.CreateModelConfig(ctx, config)
+	created, err := uc.repo.CreateModelConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	uc.configNotifier.NotifyModel(ctx, created.ID, "model_config")
+
+	return created, nil
 }
 
 // EditModelConfig 编辑模型配置
@@ -281,6 +290,7 @@ func (uc *ModelUsecase) EditModelConfig(ctx context.Context, modelType string, p
 	if err != nil {
 		return nil, uc.handleError.ErrInternal(ctx, err)
 	}
+	uc.configNotifier.NotifyModel(ctx, id, "model_config")
 
 	// 返回更新后的配置（经过敏感数据处理）
 	return uc.repo.GetModelConfigByID(ctx, id)
@@ -325,7 +335,12 @@ func (uc *ModelUsecase) DeleteModelConfig(ctx context.Context, id string) error
 		}
 	}
 
-	return uc.repo.DeleteModelConfig(ctx, id)
+	if err := uc.repo.DeleteModelConfig(ctx, id); err != nil {
+		return err
+	}
+	uc.configNotifier.NotifyModel(ctx, id, "model_config")
+
+	return nil
 }
 
 // EnableModelConfig 启用/关闭模型
@@ -346,7 +361,12 @@ func (uc *ModelUsecase) EnableModelConfig(ctx context.Context, id string, status
 	config.ConfigJSON = "" // 不更新ConfigJson字段
 	config.UpdateDate = time.Now()
 
-	return uc.repo.UpdateModelConfig(ctx, config)
+	if err := uc.repo.UpdateModelConfig(ctx, config); err != nil {
+		return err
+	}
+	uc.configNotifier.NotifyModel(ctx, id, "model_config")
+
+	return nil
 }
 
 // SetDefaultModel 设置默认模型
@@ -379,6 +399,7 @@ func (uc *ModelUsecase) SetDefaultModel(ctx context.Context, id string) (*ModelC
 	if err != nil {
 		return nil, uc.handleError.ErrInternal(ctx, err)
 	}
+	uc.configNotifier.NotifyModel(ctx, id, "model_config")
 
 	// 返回更新后的配置
 	return uc.repo.GetModelConfigByID(ctx, id)
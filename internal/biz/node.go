@@ -0,0 +1,189 @@
+package biz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+	"github.com/weetime/agent-matrix/internal/kit/cerrors"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// nodeMaxBlockDuration 踢出节点后禁止重连的最长时间
+const nodeMaxBlockDuration = 24 * time.Hour
+
+// nodeMetricsTTL 节点负载信息的保留时间，超过该时间未上报视为无数据
+const nodeMetricsTTL = 5 * time.Minute
+
+// NodeMetrics 节点上报的负载信息
+type NodeMetrics struct {
+	NodeID         string    `json:"node_id"`
+	ActiveSessions int       `json:"active_sessions"` // 当前活跃的设备会话数
+	CPUPercent     float64   `json:"cpu_percent"`
+	MemoryPercent  float64   `json:"memory_percent"`
+	MemoryBytes    uint64    `json:"memory_bytes"`
+	Version        string    `json:"version"`
+	WebSocket      string    `json:"websocket"` // 节点对设备开放的WebSocket地址，对应server.websocket中的配置
+	ReportedAt     time.Time `json:"reported_at"`
+}
+
+// NodeHealth 语音服务节点的健康状态，合并server.websocket配置和已连接节点
+type NodeHealth struct {
+	Address    string        // WebSocket地址，未配置的节点为其上报的地址
+	Configured bool          // 地址是否在server.websocket中配置
+	Online     bool          // 节点是否连接到/ws
+	Node       *kit.NodeInfo // 连接信息，离线时为nil
+	Metrics    *NodeMetrics  // 最近一次上报的负载信息，未上报时为nil
+}
+
+// NodeUsecase 语音服务节点连接管理
+type NodeUsecase struct {
+	configUsecase *ConfigUsecase
+	redisClient   *kit.RedisClient
+	handleError   *cerrors.HandleError
+	log           *log.Helper
+}
+
+// NewNodeUsecase 创建节点连接管理用例
+func NewNodeUsecase(
+	configUsecase *ConfigUsecase,
+	redisClient *kit.RedisClient,
+	logger log.Logger,
+) *NodeUsecase {
+	return &NodeUsecase{
+		configUsecase: configUsecase,
+		redisClient:   redisClient,
+		handleError:   cerrors.NewHandleError(logger),
+		log:           log.NewHelper(log.With(logger, "module", "agent-matrix-service/biz/node")),
+	}
+}
+
+// AuthenticateNode 校验节点连接/ws时携带的token，被踢出且仍在禁止期内的节点拒绝连接
+func (uc *NodeUsecase) AuthenticateNode(ctx context.Context, nodeID, token string) error {
+	blocked, err := uc.redisClient.Exists(ctx, kit.GetNodeBlockedKey(nodeID))
+	if err != nil {
+		return fmt.Errorf("查询节点状态失败: %w", err)
+	}
+	if blocked {
+		return fmt.Errorf("节点 %s 已被禁止连接", nodeID)
+	}
+
+	secret, err := uc.configUsecase.GetValue(ctx, "server.secret", true)
+	if err != nil {
+		return fmt.Errorf("获取server.secret失败: %w", err)
+	}
+	return kit.VerifyNodeToken(token, nodeID, secret)
+}
+
+// ListNodes 获取已连接的节点，按节点ID排序
+func (uc *NodeUsecase) ListNodes(ctx context.Context) []*kit.NodeInfo {
+	nodes := kit.GetWebSocket().ListNodes()
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].NodeID < nodes[j].NodeID
+	})
+	return nodes
+}
+
+// KickNode 断开节点的连接，blockDuration大于0时在该时间内拒绝节点重连
+func (uc *NodeUsecase) KickNode(ctx context.Context, nodeID string, blockDuration time.Duration) error {
+	if nodeID == "" {
+		return uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("节点ID不能为空"))
+	}
+	if blockDuration > nodeMaxBlockDuration {
+		return uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("禁止重连时间不能超过%s", nodeMaxBlockDuration))
+	}
+
+	connected := slices.Contains(kit.GetWebSocket().GetActiveNodeIDs(), nodeID)
+	if !connected && blockDuration <= 0 {
+		return uc.handleError.ErrNotFound(ctx, fmt.Errorf("节点 %s 未连接", nodeID))
+	}
+
+	if blockDuration > 0 {
+		if err := uc.redisClient.Set(ctx, kit.GetNodeBlockedKey(nodeID), time.Now().Unix(), blockDuration); err != nil {
+			return uc.handleError.ErrInternal(ctx, err)
+		}
+	}
+	kit.GetWebSocket().KickNode(nodeID)
+	uc.log.Infof("节点 %s 已被踢出, 禁止重连: %s", nodeID, blockDuration)
+	return nil
+}
+
+// HandleNodeMetrics 处理节点通过/ws定期上报的负载信息
+func (uc *NodeUsecase) HandleNodeMetrics(ctx context.Context, nodeID string, msg kit.WebSocketMessage) error {
+	var metrics NodeMetrics
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, &metrics); err != nil {
+			return fmt.Errorf("解析节点负载信息失败: %w", err)
+		}
+	}
+	metrics.NodeID = nodeID
+	metrics.ReportedAt = time.Now()
+	return uc.redisClient.SetObject(ctx, kit.GetNodeMetricsKey(nodeID), &metrics, nodeMetricsTTL)
+}
+
+// NodeOffline 节点断开时清除其负载信息
+func (uc *NodeUsecase) NodeOffline(ctx context.Context, nodeID string) error {
+	return uc.redisClient.Delete(ctx, kit.GetNodeMetricsKey(nodeID))
+}
+
+// GetNodeHealth 获取语音服务节点的健康状态
+// 先按server.websocket配置的顺序列出配置的地址，再列出未配置但已连接的节点
+func (uc *NodeUsecase) GetNodeHealth(ctx context.Context) ([]*NodeHealth, error) {
+	wsText, err := uc.configUsecase.GetValue(ctx, "server.websocket", true)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	nodes := uc.ListNodes(ctx)
+	metricsByNode := make(map[string]*NodeMetrics, len(nodes))
+	nodeByAddress := make(map[string]*kit.NodeInfo, len(nodes))
+	for _, node := range nodes {
+		var metrics NodeMetrics
+		if err := uc.redisClient.GetObject(ctx, kit.GetNodeMetricsKey(node.NodeID), &metrics); err != nil {
+			uc.log.Warnf("获取节点负载信息失败, 节点ID: %s, error: %v", node.NodeID, err)
+			continue
+		}
+		if metrics.NodeID == "" {
+			continue
+		}
+		metricsByNode[node.NodeID] = &metrics
+		if metrics.WebSocket != "" {
+			nodeByAddress[metrics.WebSocket] = node
+		}
+	}
+
+	result := make([]*NodeHealth, 0, len(nodes))
+	matched := make(map[string]bool, len(nodes))
+	for _, address := range strings.Split(wsText, ";") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+		health := &NodeHealth{Address: address, Configured: true}
+		if node, ok := nodeByAddress[address]; ok {
+			health.Online = true
+			health.Node = node
+			health.Metrics = metricsByNode[node.NodeID]
+			matched[node.NodeID] = true
+		}
+		result = append(result, health)
+	}
+
+	for _, node := range nodes {
+		if matched[node.NodeID] {
+			continue
+		}
+		health := &NodeHealth{Online: true, Node: node, Metrics: metricsByNode[node.NodeID]}
+		if health.Metrics != nil {
+			health.Address = health.Metrics.WebSocket
+		}
+		result = append(result, health)
+	}
+	return result, nil
+}
@@ -10,7 +10,6 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
-	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -37,6 +36,9 @@ type Ota struct {
 	Size         int64     // 文件大小(字节)
 	Remark       string    // 备注/说明
 	FirmwarePath string    // 固件路径
+	Sha256       string    // 固件SHA-256（十六进制）
+	Signature    string    // 固件签名（Ed25519，Base64），未配置签名私钥时为空
+	SignKeyID    string    // 签名公钥标识
 	Sort         int32     // 排序
 	Updater      int64     // 更新者ID
 	UpdateDate   time.Time // 更新时间
@@ -53,6 +55,9 @@ type OtaResponseDTO struct {
 	Size         int64  // 文件大小(字节)
 	Remark       string // 备注/说明
 	FirmwarePath string // 固件路径
+	Sha256       string // 固件SHA-256（十六进制）
+	Signature    string // 固件签名（Base64）
+	SignKeyID    string // 签名公钥标识
 	Sort         int32  // 排序
 	Updater      string // 更新者ID（格式化为字符串）
 	UpdateDate   string // 更新时间（格式化）
@@ -63,6 +68,7 @@ type OtaResponseDTO struct {
 // ListOtaParams 分页查询参数
 type ListOtaParams struct {
 	FirmwareName *string // 可选，固件名称（模糊查询）
+	Type         *string // 可选，固件类型
 }
 
 // OtaRepo OTA固件数据访问接口
@@ -73,7 +79,7 @@ type OtaRepo interface {
 	// GetByID 根据ID查询OTA固件记录
 	GetByID(ctx context.Context, id string) (*Ota, error)
 
-	// Save 保存OTA固件（同类固件只保留最新一条）
+	// Save 保存OTA固件（相同类型和版本的固件覆盖，其他版本保留用于灰度和回滚）
 	Save(ctx context.Context, entity *Ota) error
 
 	// Update 更新OTA固件（检查类型和版本唯一性）
@@ -82,8 +88,14 @@ type OtaRepo interface {
 	// Delete 批量删除OTA固件
 	Delete(ctx context.Context, ids []string) error
 
-	// GetLatestOta 根据类型获取最新OTA固件
-	GetLatestOta(ctx context.Context, otaType string) (*Ota, error)
+	// UpdateSignature 更新固件的SHA-256、大小和签名
+	UpdateSignature(ctx context.Context, entity *Ota) error
+
+	// GetLatestOta 根据类型获取版本号最高的OTA固件，excludeIds中的固件不参与选择
+	GetLatestOta(ctx context.Context, otaType string, excludeIds ...string) (*Ota, error)
+
+	// GetByTypeAndVersion 根据类型和版本查询OTA固件，不存在时返回nil
+	GetByTypeAndVersion(ctx context.Context, otaType, version string) (*Ota, error)
 }
 
 // DeviceReportReqDTO 设备上报请求DTO
@@ -160,8 +172,14 @@ type Activation struct {
 }
 
 type Firmware struct {
-	Version string `json:"version"`
-	URL     string `json:"url"`
+	Version   string `json:"version"`
+	URL       string `json:"url"`
+	Sha256    string `json:"sha256,omitempty"`    // 固件SHA-256，设备刷写前校验
+	Size      int64  `json:"size,omitempty"`      // 固件大小(字节)
+	Signature string `json:"signature,omitempty"` // 固件签名（Ed25519，Base64），签名内容见kit.FirmwareManifest
+	KeyID     string `json:"key_id,omitempty"`    // 签名公钥标识
+
+	Delta *FirmwareDelta `json:"delta,omitempty"` // 差分升级包，设备应用失败时使用URL下载完整固件
 }
 
 type Websocket struct {
@@ -178,9 +196,26 @@ type MQTT struct {
 	SubscribeTopic string `json:"subscribe_topic"`
 }
 
+// 固件下载链接
+const (
+	otaDownloadTTL  = 24 * time.Hour // 下载链接有效期
+	otaMaxDownloads = 3              // 每个下载链接的完整下载次数上限
+)
+
+// FirmwareDownload 固件下载内容
+type FirmwareDownload struct {
+	Object   FirmwareObject
+	Filename string
+	ETag     string // 强ETag，用于If-Range/If-None-Match
+}
+
 // OtaUsecase OTA固件业务逻辑
 type OtaUsecase struct {
 	repo          OtaRepo
+	rolloutRepo   OtaRolloutRepo
+	historyRepo   FirmwareHistoryRepo
+	deltaRepo     OtaDeltaRepo
+	store         FirmwareStore
 	DeviceUsecase *DeviceUsecase
 	ConfigUsecase *ConfigUsecase
 	redisClient   *kit.RedisClient
@@ -191,6 +226,10 @@ type OtaUsecase struct {
 // NewOtaUsecase 创建OTA固件用例
 func NewOtaUsecase(
 	repo OtaRepo,
+	rolloutRepo OtaRolloutRepo,
+	historyRepo FirmwareHistoryRepo,
+	deltaRepo OtaDeltaRepo,
+	store FirmwareStore,
 	deviceUsecase *DeviceUsecase,
 	configUsecase *ConfigUsecase,
 	redisClient *kit.RedisClient,
@@ -198,6 +237,10 @@ func NewOtaUsecase(
 ) *OtaUsecase {
 	return &OtaUsecase{
 		repo:          repo,
+		rolloutRepo:   rolloutRepo,
+		historyRepo:   historyRepo,
+		deltaRepo:     deltaRepo,
+		store:         store,
 		DeviceUsecase: deviceUsecase,
 		ConfigUsecase: configUsecase,
 		redisClient:   redisClient,
@@ -255,11 +298,29 @@ func (uc *OtaUsecase) SaveOta(ctx context.Context, entity *Ota) error {
 	if entity.Version == "" {
 		return uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("版本号不能为空"))
 	}
+	if err := uc.fillFirmwareDigest(ctx, entity); err != nil {
+		return err
+	}
+
+	// 保存前版本号最高的固件，新固件版本更高时生成从该版本到新版本的差分包
+	previous, err := uc.repo.GetLatestOta(ctx, entity.Type)
+	if err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
 
 	if err := uc.repo.Save(ctx, entity); err != nil {
 		return uc.handleError.ErrInternal(ctx, err)
 	}
 
+	if previous != nil && CompareVersions(entity.Version, previous.Version) > 0 && previous.FirmwarePath != "" && previous.Sha256 != "" && entity.Sha256 != "" {
+		saved, err := uc.repo.GetByTypeAndVersion(ctx, entity.Type, entity.Version)
+		if err != nil {
+			uc.log.Warnf("查询新保存的固件失败，跳过生成差分包: %v", err)
+		} else if saved != nil {
+			uc.scheduleOtaDelta(ctx, previous, saved)
+		}
+	}
+
 	return nil
 }
 
@@ -282,6 +343,9 @@ func (uc *OtaUsecase) UpdateOta(ctx context.Context, id string, entity *Ota) err
 	}
 
 	entity.ID = id
+	if err := uc.fillFirmwareDigest(ctx, entity); err != nil {
+		return err
+	}
 	if err := uc.repo.Update(ctx, entity); err != nil {
 		if err == ErrDuplicateOtaTypeVersion {
 			return err
@@ -297,10 +361,16 @@ func (uc *OtaUsecase) DeleteOta(ctx context.Context, ids []string) error {
 	if len(ids) == 0 {
 		return uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("删除的固件ID不能为空"))
 	}
+	if err := uc.checkOtaInRollout(ctx, ids); err != nil {
+		return err
+	}
 
 	if err := uc.repo.Delete(ctx, ids); err != nil {
 		return uc.handleError.ErrInternal(ctx, err)
 	}
+	if err := uc.deltaRepo.DeleteByOtas(ctx, ids); err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
 
 	return nil
 }
@@ -322,66 +392,81 @@ func (uc *OtaUsecase) GetDownloadUrl(ctx context.Context, id string) (string, er
 
 	// 存储到Redis（24小时过期）
 	redisKey := kit.GetOtaIdKey(uuidStr)
-	if err := uc.redisClient.Set(ctx, redisKey, id, 24*time.Hour); err != nil {
+	if err := uc.redisClient.Set(ctx, redisKey, id, otaDownloadTTL); err != nil {
 		return "", uc.handleError.ErrInternal(ctx, err)
 	}
 
 	return uuidStr, nil
 }
 
-// DownloadOta 下载OTA固件（从Redis获取ID，检查下载次数，返回文件数据）
-func (uc *OtaUsecase) DownloadOta(ctx context.Context, uuidStr string) ([]byte, string, error) {
+// OpenOtaDownload 校验下载链接并打开固件文件，调用方负责关闭Object
+// 下载链接在有效期内可重复使用，完整下载次数由CountOtaDownload统计
+func (uc *OtaUsecase) OpenOtaDownload(ctx context.Context, uuidStr string) (*FirmwareDownload, error) {
 	if uuidStr == "" {
-		return nil, "", uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("uuid不能为空"))
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("uuid不能为空"))
 	}
 
 	// 从Redis获取ID
-	redisKey := kit.GetOtaIdKey(uuidStr)
-	id, err := uc.redisClient.Get(ctx, redisKey)
+	id, err := uc.redisClient.Get(ctx, kit.GetOtaIdKey(uuidStr))
 	if err != nil || id == "" {
-		return nil, "", uc.handleError.ErrNotFound(ctx, fmt.Errorf("下载链接不存在或已过期"))
+		return nil, uc.handleError.ErrNotFound(ctx, fmt.Errorf("下载链接不存在或已过期"))
 	}
-
-	// 检查下载次数
-	downloadCountKey := kit.GetOtaDownloadCountKey(uuidStr)
-	downloadCountStr, _ := uc.redisClient.Get(ctx, downloadCountKey)
-	downloadCount := 0
-	if downloadCountStr != "" {
-		fmt.Sscanf(downloadCountStr, "%d", &downloadCount)
-	}
-
-	// 如果下载次数超过3次，删除Redis key并返回错误
-	if downloadCount >= 3 {
-		_ = uc.redisClient.Delete(ctx, downloadCountKey)
-		_ = uc.redisClient.Delete(ctx, redisKey)
-		return nil, "", uc.handleError.ErrNotFound(ctx, fmt.Errorf("下载次数已达上限"))
-	}
-
-	// 增加下载次数
-	downloadCount++
-	if err := uc.redisClient.Set(ctx, downloadCountKey, fmt.Sprintf("%d", downloadCount), 24*time.Hour); err != nil {
-		return nil, "", uc.handleError.ErrInternal(ctx, err)
+	if deltaId, ok := strings.CutPrefix(id, otaDeltaDownloadPrefix); ok {
+		return uc.openOtaDeltaDownload(ctx, deltaId)
 	}
 
 	// 获取固件信息
 	entity, err := uc.repo.GetByID(ctx, id)
 	if err != nil {
-		return nil, "", uc.handleError.ErrInternal(ctx, err)
+		return nil, uc.handleError.ErrInternal(ctx, err)
 	}
 	if entity == nil || entity.FirmwarePath == "" {
-		return nil, "", uc.handleError.ErrNotFound(ctx, fmt.Errorf("固件文件不存在"))
+		return nil, uc.handleError.ErrNotFound(ctx, fmt.Errorf("固件文件不存在"))
+	}
+
+	object, err := uc.store.Open(ctx, entity.FirmwarePath)
+	if err != nil {
+		return nil, uc.handleError.ErrNotFound(ctx, fmt.Errorf("固件文件不存在: %v", err))
 	}
 
-	// 读取文件
-	fileData, filename, err := uc.readFirmwareFile(entity.FirmwarePath, entity.Type, entity.Version)
+	// 优先使用固件SHA-256作为强ETag，文件变化时断点续传会重新下载完整文件
+	etag := entity.Sha256
+	if etag == "" || (entity.Size > 0 && entity.Size != object.Size()) {
+		etag = fmt.Sprintf("%x-%x", object.ModTime().UnixNano(), object.Size())
+	}
+	return &FirmwareDownload{
+		Object:   object,
+		Filename: firmwareFilename(entity.FirmwarePath, entity.Type, entity.Version),
+		ETag:     `"` + etag + `"`,
+	}, nil
+}
+
+// CountOtaDownload 统计一次完整下载，超过下载次数上限时拒绝
+// 断点续传请求不计入次数，下载次数与下载链接同时过期
+func (uc *OtaUsecase) CountOtaDownload(ctx context.Context, uuidStr string) error {
+	client := uc.redisClient.GetClient()
+	downloadCountKey := kit.GetOtaDownloadCountKey(uuidStr)
+	downloadCount, err := client.Incr(ctx, downloadCountKey).Result()
 	if err != nil {
-		return nil, "", uc.handleError.ErrInternal(ctx, err)
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	if downloadCount == 1 {
+		ttl, err := client.TTL(ctx, kit.GetOtaIdKey(uuidStr)).Result()
+		if err != nil || ttl <= 0 {
+			ttl = otaDownloadTTL
+		}
+		if err := client.Expire(ctx, downloadCountKey, ttl).Err(); err != nil {
+			return uc.handleError.ErrInternal(ctx, err)
+		}
 	}
 
-	return fileData, filename, nil
+	if downloadCount > otaMaxDownloads {
+		return uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("下载次数已达上限"))
+	}
+	return nil
 }
 
-// UploadFirmware 上传固件文件（计算MD5，保存文件，返回路径）
+// UploadFirmware 上传固件文件（计算MD5，保存到固件存储，返回路径）
 func (uc *OtaUsecase) UploadFirmware(ctx context.Context, fileData []byte, filename string) (string, error) {
 	if len(fileData) == 0 {
 		return "", uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("上传文件不能为空"))
@@ -400,24 +485,9 @@ func (uc *OtaUsecase) UploadFirmware(ctx context.Context, fileData []byte, filen
 	md5Hash := md5.Sum(fileData)
 	md5Str := fmt.Sprintf("%x", md5Hash)
 
-	// 设置存储路径
-	uploadDir := "uploadfile"
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return "", uc.handleError.ErrInternal(ctx, fmt.Errorf("创建上传目录失败: %w", err))
-	}
-
-	// 使用MD5作为文件名，保留原始扩展名
-	uniqueFileName := md5Str + ext
-	filePath := filepath.Join(uploadDir, uniqueFileName)
-
-	// 检查文件是否已存在
-	if _, err := os.Stat(filePath); err == nil {
-		// 文件已存在，直接返回路径
-		return filePath, nil
-	}
-
-	// 保存文件
-	if err := os.WriteFile(filePath, fileData, 0644); err != nil {
+	// 使用MD5作为文件名，保留原始扩展名，同名文件已存在时直接返回路径
+	filePath, err := uc.store.Put(ctx, md5Str+ext, fileData)
+	if err != nil {
 		return "", uc.handleError.ErrInternal(ctx, fmt.Errorf("保存文件失败: %w", err))
 	}
 
@@ -434,6 +504,9 @@ func (uc *OtaUsecase) convertToResponseDTO(entity *Ota) *OtaResponseDTO {
 		Size:         entity.Size,
 		Remark:       entity.Remark,
 		FirmwarePath: entity.FirmwarePath,
+		Sha256:       entity.Sha256,
+		Signature:    entity.Signature,
+		SignKeyID:    entity.SignKeyID,
 		Sort:         entity.Sort,
 	}
 
@@ -456,53 +529,6 @@ func (uc *OtaUsecase) convertToResponseDTO(entity *Ota) *OtaResponseDTO {
 	return dto
 }
 
-// readFirmwareFile 读取固件文件（支持绝对路径和相对路径查找）
-func (uc *OtaUsecase) readFirmwareFile(firmwarePath, otaType, otaVersion string) ([]byte, string, error) {
-	var filePath string
-	var err error
-
-	// 检查是否是绝对路径
-	if filepath.IsAbs(firmwarePath) {
-		filePath = firmwarePath
-	} else {
-		// 如果是相对路径，从当前工作目录解析
-		wd, _ := os.Getwd()
-		filePath = filepath.Join(wd, firmwarePath)
-	}
-
-	// 尝试读取文件
-	fileData, err := os.ReadFile(filePath)
-	if err == nil {
-		// 文件读取成功，生成文件名
-		filename := otaType + "_" + otaVersion
-		if ext := filepath.Ext(firmwarePath); ext != "" {
-			filename += ext
-		}
-		// 清理文件名，移除不安全字符
-		filename = sanitizeFilename(filename)
-		return fileData, filename, nil
-	}
-
-	// 如果文件不存在，尝试从firmware目录下查找文件名
-	fileName := filepath.Base(firmwarePath)
-	wd, _ := os.Getwd()
-	altPath := filepath.Join(wd, "firmware", fileName)
-
-	fileData, err = os.ReadFile(altPath)
-	if err != nil {
-		return nil, "", fmt.Errorf("固件文件不存在: %v", err)
-	}
-
-	// 文件读取成功，生成文件名
-	filename := otaType + "_" + otaVersion
-	if ext := filepath.Ext(fileName); ext != "" {
-		filename += ext
-	}
-	// 清理文件名，移除不安全字符
-	filename = sanitizeFilename(filename)
-	return fileData, filename, nil
-}
-
 // sanitizeFilename 清理文件名，移除不安全字符
 func sanitizeFilename(filename string) string {
 	result := ""
@@ -545,17 +571,20 @@ func (uc *OtaUsecase) CheckDeviceActive(ctx context.Context, macAddress, clientI
 		firmware.URL = "http://xiaozhi.server.com:8002/xiaozhi/otaMag/download/NOT_ACTIVATED_FIRMWARE_THIS_IS_A_INVALID_URL"
 		response.Firmware = firmware
 	} else {
+		var currentVersion string
+		if deviceReport.Application != nil && deviceReport.Application.Version != nil {
+			currentVersion = *deviceReport.Application.Version
+		}
+		// 根据上报的版本确定上一次固件下发的结果
+		uc.recordFirmwareCheckIn(ctx, device, currentVersion)
+
 		// 只有在设备已绑定且autoUpdate不为0的情况下才返回固件升级信息
 		if device.AutoUpdate != 0 {
 			var deviceType string
 			if deviceReport.Board != nil && deviceReport.Board.Type != nil {
 				deviceType = *deviceReport.Board.Type
 			}
-			var currentVersion string
-			if deviceReport.Application != nil && deviceReport.Application.Version != nil {
-				currentVersion = *deviceReport.Application.Version
-			}
-			firmware := uc.BuildFirmwareInfo(ctx, deviceType, currentVersion)
+			firmware := uc.BuildFirmwareInfo(ctx, device, deviceType, currentVersion, deviceReport.PartitionTable)
 			if firmware != nil {
 				response.Firmware = firmware
 			}
@@ -621,12 +650,13 @@ func (uc *OtaUsecase) CheckDeviceActive(ctx context.Context, macAddress, clientI
 	}
 
 	if device != nil {
-		// 如果设备存在，则异步更新上次连接时间和版本信息
+		// 如果设备存在，则异步更新上次连接时间和版本信息，并保存本次上报的遥测
 		var appVersion string
 		if deviceReport.Application != nil && deviceReport.Application.Version != nil {
 			appVersion = *deviceReport.Application.Version
 		}
-		// 异步更新设备连接信息（这里简化处理，直接同步更新）
+		// 异步更新设备连接信息，不随请求结束而取消
+		ctx := context.WithoutCancel(ctx)
 		go func() {
 			now := time.Now()
 			device.LastConnectedAt = &now
@@ -635,6 +665,9 @@ func (uc *OtaUsecase) CheckDeviceActive(ctx context.Context, macAddress, clientI
 			}
 			device.UpdateDate = now
 			_ = uc.DeviceUsecase.repo.Update(ctx, device)
+			if err := uc.DeviceUsecase.RecordTelemetry(ctx, device, deviceReport); err != nil {
+				uc.log.Warnf("保存设备遥测失败, mac: %s: %v", macAddress, err)
+			}
 		}()
 	} else {
 		// 如果设备不存在，则生成激活码
@@ -645,26 +678,15 @@ func (uc *OtaUsecase) CheckDeviceActive(ctx context.Context, macAddress, clientI
 	return response, nil
 }
 
-// BuildActivation 构建激活码
+// BuildActivation 构建激活码和挑战码
+// 激活码用于用户在控制台绑定设备，挑战码每次检查时重新签发，设备签名后调用 /ota/activate 完成校验
 func (uc *OtaUsecase) BuildActivation(ctx context.Context, deviceId string, deviceReport *DeviceReportReqDTO) *Activation {
 	activation := &Activation{}
 
 	// 检查是否已有激活码
-	cachedCode, _ := uc.DeviceUsecase.GeCodeByDeviceId(ctx, deviceId)
-
-	if cachedCode != "" {
-		activation.Code = cachedCode
-		frontedUrl, _ := uc.ConfigUsecase.GetValue(ctx, "server.fronted_url", true)
-		activation.Message = frontedUrl + "\n" + cachedCode
-		activation.Challenge = deviceId
-	} else {
-		// 生成新的6位随机数字激活码
-		newCode := fmt.Sprintf("%06d", rand.Intn(1000000))
-		activation.Code = newCode
-		frontedUrl, _ := uc.ConfigUsecase.GetValue(ctx, "server.fronted_url", true)
-		activation.Message = frontedUrl + "\n" + newCode
-		activation.Challenge = deviceId
+	code, _ := uc.DeviceUsecase.GeCodeByDeviceId(ctx, deviceId)
 
+	if code == "" {
 		// 构建设备数据Map
 		dataMap := make(map[string]interface{})
 		dataMap["id"] = deviceId
@@ -686,27 +708,26 @@ func (uc *OtaUsecase) BuildActivation(ctx context.Context, deviceId string, devi
 		}
 		dataMap["app_version"] = appVersion
 		dataMap["deviceId"] = deviceId
-		dataMap["activation_code"] = newCode
-
-		// 写入主数据 key
-		safeDeviceId := strings.ToLower(strings.ReplaceAll(deviceId, ":", "_"))
-		dataKey := fmt.Sprintf("ota:activation:data:%s", safeDeviceId)
-		if err := uc.redisClient.SetObject(ctx, dataKey, dataMap, 0); err != nil {
-			uc.log.Error("存储激活数据失败: %v", err)
-		}
 
-		// 写入反查激活码 key
-		codeKey := fmt.Sprintf("ota:activation:code:%s", newCode)
-		if err := uc.redisClient.Set(ctx, codeKey, deviceId, 0); err != nil {
-			uc.log.Error("存储激活码映射失败: %v", err)
+		// 生成新的6位随机数字激活码，同时写入激活数据和反查激活码 key
+		newCode, err := uc.saveActivationCode(ctx, deviceId, dataMap)
+		if err != nil {
+			uc.log.Errorf("存储激活数据失败: %v", err)
 		}
+		code = newCode
 	}
 
+	activation.Code = code
+	frontedUrl, _ := uc.ConfigUsecase.GetValue(ctx, "server.fronted_url", true)
+	activation.Message = frontedUrl + "\n" + code
+	activation.Challenge = uc.issueActivationChallenge(ctx, deviceId)
+
 	return activation
 }
 
-// BuildFirmwareInfo 构建固件信息
-func (uc *OtaUsecase) BuildFirmwareInfo(ctx context.Context, deviceType, currentVersion string) *Firmware {
+// BuildFirmwareInfo 构建固件信息，命中灰度发布的设备返回灰度固件
+// 设备分区表支持且当前版本固件已知时，同时下发差分包
+func (uc *OtaUsecase) BuildFirmwareInfo(ctx context.Context, device *Device, deviceType, currentVersion string, partitions []*Partition) *Firmware {
 	if deviceType == "" {
 		return nil
 	}
@@ -714,7 +735,7 @@ func (uc *OtaUsecase) BuildFirmwareInfo(ctx context.Context, deviceType, current
 		currentVersion = "0.0.0"
 	}
 
-	ota, err := uc.repo.GetLatestOta(ctx, deviceType)
+	ota, downgrade, err := uc.resolveFirmware(ctx, device, deviceType, currentVersion)
 	if err != nil {
 		uc.log.Error("查询最新固件失败: %v", err)
 		return nil
@@ -722,23 +743,21 @@ func (uc *OtaUsecase) BuildFirmwareInfo(ctx context.Context, deviceType, current
 
 	firmware := &Firmware{}
 	var downloadUrl string
+	var delta *FirmwareDelta
 
 	if ota != nil {
-		// 如果设备没有版本信息，或者OTA版本比设备版本新，则返回下载地址
-		if uc.CompareVersions(ota.Version, currentVersion) > 0 {
+		// 如果设备没有版本信息，或者OTA版本比设备版本新，则返回下载地址；回滚时允许降级
+		compare := uc.CompareVersions(ota.Version, currentVersion)
+		if compare > 0 || (downgrade && compare != 0) {
 			otaUrl, err := uc.ConfigUsecase.GetValue(ctx, "server.ota", true)
 			if err != nil || otaUrl == "" || otaUrl == "null" {
 				uc.log.Error("OTA地址未配置，请登录智控台，在参数管理找到【server.ota】配置")
 				// 这里无法从请求中获取URL，使用默认值
 				otaUrl = "http://127.0.0.1:8001/xiaozhi/ota"
 			}
-			// 将URL中的/ota/替换为/otaMag/download/
-			uuidStr := uuid.New().String()
-			redisKey := kit.GetOtaIdKey(uuidStr)
-			if err := uc.redisClient.Set(ctx, redisKey, ota.ID, 24*time.Hour); err != nil {
-				uc.log.Error("存储OTA ID失败: %v", err)
-			}
-			downloadUrl = strings.Replace(otaUrl, "/ota/", "/otaMag/download/", 1) + uuidStr
+			downloadUrl = uc.firmwareDownloadURL(ctx, otaUrl, ota.ID, ota.FirmwarePath, firmwareFilename(ota.FirmwarePath, ota.Type, ota.Version))
+			delta = uc.buildFirmwareDelta(ctx, ota, currentVersion, partitions, otaUrl)
+			uc.recordFirmwareOffer(ctx, device, ota, currentVersion)
 		}
 	}
 
@@ -751,11 +770,35 @@ func (uc *OtaUsecase) BuildFirmwareInfo(ctx context.Context, deviceType, current
 		firmware.URL = "http://xiaozhi.server.com:8002/xiaozhi/otaMag/download/NOT_ACTIVATED_FIRMWARE_THIS_IS_A_INVALID_URL"
 	} else {
 		firmware.URL = downloadUrl
+		firmware.Sha256 = ota.Sha256
+		firmware.Size = ota.Size
+		firmware.Signature = ota.Signature
+		firmware.KeyID = ota.SignKeyID
+		firmware.Delta = delta
 	}
 
 	return firmware
 }
 
+// firmwareDownloadURL 生成设备下载地址，固件存储开启预签名时直接从对象存储下载，否则通过/otaMag/download/接口下载
+func (uc *OtaUsecase) firmwareDownloadURL(ctx context.Context, otaUrl, target, firmwarePath, filename string) string {
+	downloadUrl, err := uc.store.PresignURL(ctx, firmwarePath, filename)
+	if err != nil {
+		uc.log.Warnf("生成固件预签名URL失败，改用下载接口: %v", err)
+	}
+	if err == nil && downloadUrl != "" {
+		return downloadUrl
+	}
+
+	// 将URL中的/ota/替换为/otaMag/download/
+	uuidStr := uuid.New().String()
+	redisKey := kit.GetOtaIdKey(uuidStr)
+	if err := uc.redisClient.Set(ctx, redisKey, target, otaDownloadTTL); err != nil {
+		uc.log.Error("存储OTA ID失败: %v", err)
+	}
+	return strings.Replace(otaUrl, "/ota/", "/otaMag/download/", 1) + uuidStr
+}
+
 // BuildMqttConfig 构建MQTT配置
 func (uc *OtaUsecase) BuildMqttConfig(ctx context.Context, macAddress, groupId string) (*MQTT, error) {
 	// 从系统参数获取签名密钥
@@ -827,6 +870,11 @@ func (uc *OtaUsecase) BuildServerTime() *ServerTime {
 // CompareVersions 比较两个版本号
 // 返回：1 (v1 > v2), -1 (v1 < v2), 0 (v1 == v2)
 func (uc *OtaUsecase) CompareVersions(version1, version2 string) int {
+	return CompareVersions(version1, version2)
+}
+
+// CompareVersions 按点分数字比较两个版本号，任一版本为空时视为相等
+func CompareVersions(version1, version2 string) int {
 	if version1 == "" || version2 == "" {
 		return 0
 	}
@@ -0,0 +1,314 @@
+package biz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+	"github.com/weetime/agent-matrix/internal/middleware"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// 设备激活校验参数（param_type为0，不下发给语音服务节点）
+const (
+	activationSecretsParam     = "server.activation_secrets"      // 型号激活密钥，JSON对象：{"型号":"密钥","*":"默认密钥"}
+	activationKeyModeParam     = "server.activation_key_mode"     // 密钥模式：board-设备烧录型号密钥，device-设备烧录由设备ID派生的独立密钥
+	activationRequireHMACParam = "server.activation_require_hmac" // 为true时所有设备都必须通过挑战校验才能绑定
+)
+
+const (
+	activationTTL           = 24 * time.Hour   // 激活码、激活数据和挑战码的有效期
+	activationCodeRetries   = 10               // 激活码冲突时的重试次数
+	activationMaxFailures   = 5                // 窗口期内每个用户允许尝试激活码的次数，绑定成功后清零
+	activationMaxIPFailures = 20               // 窗口期内每个客户端IP允许输错激活码的次数，限制多账号猜测
+	activationFailWindow    = 15 * time.Minute // 尝试激活码的计数窗口
+	activationAlgorithm     = "hmac-sha256"
+	activationKeyModeDevice = "device"
+	activationDefaultBoard  = "*"
+)
+
+// ActivationPayload 设备调用 /ota/activate 时提交的挑战应答
+type ActivationPayload struct {
+	Algorithm    string `json:"algorithm"`
+	SerialNumber string `json:"serial_number"`
+	Challenge    string `json:"challenge"`
+	HMAC         string `json:"hmac"`
+}
+
+// activationSecrets 设备激活密钥配置
+type activationSecrets struct {
+	Secrets    map[string]string
+	DeviceMode bool
+	Required   bool
+}
+
+// boardSecret 获取型号对应的密钥，未配置时使用默认密钥
+func (s *activationSecrets) boardSecret(board string) string {
+	if secret := s.Secrets[board]; secret != "" {
+		return secret
+	}
+	return s.Secrets[activationDefaultBoard]
+}
+
+// hmacRequired 该型号的设备是否必须通过挑战校验
+func (s *activationSecrets) hmacRequired(board string) bool {
+	return s.Required || s.boardSecret(board) != ""
+}
+
+// deviceKey 获取设备的激活密钥，未配置或缺少设备ID时返回nil
+// 独立密钥由请求头中的Device-Id派生，设备无法借用其他设备的密钥通过校验
+func (s *activationSecrets) deviceKey(board, deviceId string) []byte {
+	secret := s.boardSecret(board)
+	if secret == "" {
+		return nil
+	}
+	if !s.DeviceMode {
+		return []byte(secret)
+	}
+	if deviceId == "" {
+		return nil
+	}
+	return kit.DeriveDeviceActivationKey(secret, deviceId)
+}
+
+// loadActivationSecrets 读取设备激活密钥配置
+func (uc *OtaUsecase) loadActivationSecrets(ctx context.Context) (*activationSecrets, error) {
+	secrets := &activationSecrets{Secrets: map[string]string{}}
+
+	value, _ := uc.ConfigUsecase.GetValue(ctx, activationSecretsParam, true)
+	if value = strings.TrimSpace(value); value != "" {
+		if err := json.Unmarshal([]byte(value), &secrets.Secrets); err != nil {
+			return nil, fmt.Errorf("参数 %s 配置错误: %w", activationSecretsParam, err)
+		}
+	}
+	mode, _ := uc.ConfigUsecase.GetValue(ctx, activationKeyModeParam, true)
+	secrets.DeviceMode = strings.EqualFold(strings.TrimSpace(mode), activationKeyModeDevice)
+	required, _ := uc.ConfigUsecase.GetValue(ctx, activationRequireHMACParam, true)
+	secrets.Required, _ = strconv.ParseBool(strings.TrimSpace(required))
+	return secrets, nil
+}
+
+// saveActivationCode 分配未被占用的激活码并保存激活数据
+func (uc *OtaUsecase) saveActivationCode(ctx context.Context, deviceId string, dataMap map[string]interface{}) (string, error) {
+	client := uc.redisClient.GetClient()
+	for i := 0; i < activationCodeRetries; i++ {
+		code := kit.GenerateDeviceActivationCode()
+		ok, err := client.SetNX(ctx, kit.GetDeviceActivationCodeKey(code), deviceId, activationTTL).Result()
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			continue
+		}
+		dataMap["activation_code"] = code
+		if err := uc.redisClient.SetObject(ctx, kit.GetDeviceActivationDataKey(kit.SafeDeviceID(deviceId)), dataMap, activationTTL); err != nil {
+			client.Del(ctx, kit.GetDeviceActivationCodeKey(code))
+			return "", err
+		}
+		return code, nil
+	}
+	return "", fmt.Errorf("激活码分配失败，请稍后重试")
+}
+
+// issueActivationChallenge 获取设备的挑战码，设备需用激活密钥对其签名后调用 /ota/activate
+// 有效期内重复请求 /ota 时返回同一个挑战码，避免设备签名期间挑战码被替换
+func (uc *OtaUsecase) issueActivationChallenge(ctx context.Context, deviceId string) string {
+	key := kit.GetDeviceActivationChallengeKey(kit.SafeDeviceID(deviceId))
+	challenge, err := uc.redisClient.Get(ctx, key)
+	if err != nil {
+		uc.log.Errorf("查询激活挑战码失败: %v", err)
+		return ""
+	}
+	if challenge != "" {
+		return challenge
+	}
+
+	if challenge, err = kit.GenerateActivationChallenge(); err != nil {
+		uc.log.Errorf("生成激活挑战码失败: %v", err)
+		return ""
+	}
+	ok, err := uc.redisClient.GetClient().SetNX(ctx, key, challenge, activationTTL).Result()
+	if err != nil {
+		uc.log.Errorf("存储激活挑战码失败: %v", err)
+		return ""
+	}
+	if !ok {
+		// 并发请求已签发挑战码
+		if challenge, err = uc.redisClient.Get(ctx, key); err != nil {
+			uc.log.Errorf("查询激活挑战码失败: %v", err)
+			return ""
+		}
+	}
+	return challenge
+}
+
+// getActivationData 查询设备待激活数据，不存在时返回nil
+func (uc *OtaUsecase) getActivationData(ctx context.Context, deviceId string) (map[string]interface{}, error) {
+	var dataMap map[string]interface{}
+	if err := uc.redisClient.GetObject(ctx, kit.GetDeviceActivationDataKey(kit.SafeDeviceID(deviceId)), &dataMap); err != nil {
+		return nil, err
+	}
+	if len(dataMap) == 0 {
+		return nil, nil
+	}
+	return dataMap, nil
+}
+
+// VerifyDeviceActivation 校验设备对挑战码的签名，返回设备是否已绑定
+// 校验通过后在激活数据中标记，用户输入激活码绑定时检查该标记
+func (uc *OtaUsecase) VerifyDeviceActivation(ctx context.Context, deviceId string, payload *ActivationPayload) (bool, error) {
+	if deviceId == "" {
+		return false, nil
+	}
+	device, err := uc.DeviceUsecase.GetDeviceByMacAddress(ctx, deviceId)
+	if err != nil {
+		return false, err
+	}
+	if device != nil {
+		return true, nil
+	}
+
+	dataMap, err := uc.getActivationData(ctx, deviceId)
+	if err != nil {
+		return false, uc.handleError.ErrInternal(ctx, err)
+	}
+	if dataMap == nil {
+		return false, nil
+	}
+	if verified, _ := dataMap["verified"].(bool); verified {
+		return false, nil
+	}
+	if payload == nil || payload.HMAC == "" {
+		return false, nil
+	}
+	if payload.Algorithm != "" && !strings.EqualFold(payload.Algorithm, activationAlgorithm) {
+		return false, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("不支持的签名算法: %s", payload.Algorithm))
+	}
+
+	secrets, err := uc.loadActivationSecrets(ctx)
+	if err != nil {
+		return false, uc.handleError.ErrInternal(ctx, err)
+	}
+	board, _ := dataMap["board"].(string)
+	key := secrets.deviceKey(board, strings.ToLower(deviceId))
+	if key == nil {
+		if secrets.hmacRequired(board) {
+			return false, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("设备型号未配置激活密钥"))
+		}
+		return false, nil
+	}
+
+	challenge, err := uc.redisClient.Get(ctx, kit.GetDeviceActivationChallengeKey(kit.SafeDeviceID(deviceId)))
+	if err != nil {
+		return false, uc.handleError.ErrInternal(ctx, err)
+	}
+	if challenge == "" || payload.Challenge != challenge {
+		return false, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("挑战码无效或已过期"))
+	}
+	if !kit.VerifyActivationChallenge(key, challenge, payload.HMAC) {
+		return false, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("设备签名校验失败"))
+	}
+
+	dataMap["verified"] = true
+	dataMap["serial_number"] = payload.SerialNumber
+	dataKey := kit.GetDeviceActivationDataKey(kit.SafeDeviceID(deviceId))
+	if err := uc.redisClient.Set(ctx, dataKey, dataMap, redis.KeepTTL); err != nil {
+		return false, uc.handleError.ErrInternal(ctx, err)
+	}
+	return false, nil
+}
+
+// ActivateDeviceByCode 用户输入激活码绑定设备
+// 窗口期内输错次数过多时拒绝尝试；型号配置了激活密钥的设备必须先通过挑战校验
+func (uc *OtaUsecase) ActivateDeviceByCode(ctx context.Context, userId int64, agentId, code string) error {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("激活码不能为空"))
+	}
+
+	// 先计数再校验，并发请求不能绕过次数限制
+	failKey := kit.GetDeviceActivationFailKey(userId)
+	var ipFailKey string
+	if ip := middleware.GetClientInfo(ctx).IP; ip != "" {
+		ipFailKey = kit.GetDeviceActivationIPFailKey(ip)
+	}
+	for _, limit := range []struct {
+		key string
+		max int64
+	}{{failKey, activationMaxFailures}, {ipFailKey, activationMaxIPFailures}} {
+		if limit.key == "" {
+			continue
+		}
+		allowed, err := uc.countActivationAttempt(ctx, limit.key, limit.max)
+		if err != nil {
+			return uc.handleError.ErrInternal(ctx, err)
+		}
+		if !allowed {
+			return uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("激活码错误次数过多，请%d分钟后再试", int(activationFailWindow.Minutes())))
+		}
+	}
+
+	codeKey := kit.GetDeviceActivationCodeKey(code)
+	deviceId, err := uc.redisClient.Get(ctx, codeKey)
+	if err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	var dataMap map[string]interface{}
+	if deviceId != "" {
+		if dataMap, err = uc.getActivationData(ctx, deviceId); err != nil {
+			return uc.handleError.ErrInternal(ctx, err)
+		}
+	}
+	if cachedCode, _ := dataMap["activation_code"].(string); cachedCode != code {
+		return uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("激活码错误"))
+	}
+
+	board, _ := dataMap["board"].(string)
+	secrets, err := uc.loadActivationSecrets(ctx)
+	if err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	if verified, _ := dataMap["verified"].(bool); !verified && secrets.hmacRequired(board) {
+		return uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("设备未通过激活校验，请重启设备后重试"))
+	}
+
+	macAddress, _ := dataMap["mac_address"].(string)
+	appVersion, _ := dataMap["app_version"].(string)
+	if err := uc.DeviceUsecase.DeviceActivation(ctx, userId, agentId, code, deviceId, macAddress, board, appVersion); err != nil {
+		return err
+	}
+
+	safeDeviceId := kit.SafeDeviceID(deviceId)
+	uc.redisClient.Delete(ctx,
+		codeKey,
+		kit.GetDeviceActivationDataKey(safeDeviceId),
+		kit.GetDeviceActivationChallengeKey(safeDeviceId),
+		kit.GetAgentDeviceCountKey(agentId),
+		failKey,
+	)
+	// 绑定成功的尝试不计入客户端IP的输错次数
+	if ipFailKey != "" {
+		uc.redisClient.GetClient().Decr(ctx, ipFailKey)
+	}
+	return nil
+}
+
+// countActivationAttempt 累加一次激活码尝试，计数在窗口期后过期，超过次数上限时返回false
+func (uc *OtaUsecase) countActivationAttempt(ctx context.Context, key string, max int64) (bool, error) {
+	client := uc.redisClient.GetClient()
+	count, err := client.Incr(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+	if count == 1 {
+		if err := client.Expire(ctx, key, activationFailWindow).Err(); err != nil {
+			return false, err
+		}
+	}
+	return count <= max, nil
+}
@@ -0,0 +1,147 @@
+package biz
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+	"github.com/weetime/agent-matrix/internal/kit/cerrors"
+	"github.com/weetime/agent-matrix/internal/middleware"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivationSecretsDeviceKey(t *testing.T) {
+	const challenge = "0123456789abcdef"
+
+	boardMode := &activationSecrets{Secrets: map[string]string{"bread-compact-wifi": "board-secret"}}
+	require.True(t, boardMode.hmacRequired("bread-compact-wifi"))
+	require.False(t, boardMode.hmacRequired("other-board"))
+	require.Nil(t, boardMode.deviceKey("other-board", "aa:bb:cc:dd:ee:01"))
+
+	key := boardMode.deviceKey("bread-compact-wifi", "")
+	signature := kit.SignActivationChallenge([]byte("board-secret"), challenge)
+	require.True(t, kit.VerifyActivationChallenge(key, challenge, signature))
+	require.True(t, kit.VerifyActivationChallenge(key, challenge, strings.ToUpper(signature)))
+	require.False(t, kit.VerifyActivationChallenge(key, challenge+"0", signature))
+	require.False(t, kit.VerifyActivationChallenge(key, challenge, "not-hex"))
+
+	deviceMode := &activationSecrets{
+		Secrets:    map[string]string{"*": "default-secret"},
+		DeviceMode: true,
+	}
+	require.True(t, deviceMode.hmacRequired("any-board"))
+	require.Nil(t, deviceMode.deviceKey("any-board", ""))
+
+	deviceKey := kit.DeriveDeviceActivationKey("default-secret", "aa:bb:cc:dd:ee:01")
+	signature = kit.SignActivationChallenge(deviceKey, challenge)
+	require.True(t, kit.VerifyActivationChallenge(deviceMode.deviceKey("any-board", "aa:bb:cc:dd:ee:01"), challenge, signature))
+	require.False(t, kit.VerifyActivationChallenge(deviceMode.deviceKey("any-board", "aa:bb:cc:dd:ee:02"), challenge, signature))
+
+	required := &activationSecrets{Secrets: map[string]string{}, Required: true}
+	require.True(t, required.hmacRequired("any-board"))
+	require.Nil(t, required.deviceKey("any-board", "aa:bb:cc:dd:ee:01"))
+	require.False(t, kit.VerifyActivationChallenge(nil, challenge, signature))
+}
+
+func TestVerifyDeviceActivation(t *testing.T) {
+	const deviceId = "AA:BB:CC:DD:EE:01"
+	tests := []struct {
+		name      string
+		keyDevice string // 签名使用的设备ID派生密钥
+		stale     bool   // 使用过期的挑战码
+		wantErr   string
+	}{
+		{name: "设备ID派生的密钥", keyDevice: "aa:bb:cc:dd:ee:01"},
+		{name: "其他设备的密钥", keyDevice: "aa:bb:cc:dd:ee:02", wantErr: "设备签名校验失败"},
+		{name: "挑战码不一致", keyDevice: "aa:bb:cc:dd:ee:01", stale: true, wantErr: "挑战码无效或已过期"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			server := miniredis.RunT(t)
+			client := kit.NewRedisClientWithClient(redis.NewClient(&redis.Options{Addr: server.Addr()}), log.DefaultLogger)
+			configUc := NewConfigUsecase(&stubConfigRepo{params: map[string]string{
+				activationSecretsParam: `{"*":"default-secret"}`,
+				activationKeyModeParam: activationKeyModeDevice,
+			}}, nil, nil, nil, nil, nil, nil, nil, nil, client, nil, log.DefaultLogger)
+			deviceUc := NewDeviceUsecase(newStubDeviceRepo(), nil, nil, nil, nil, nil, client, log.DefaultLogger)
+			uc := NewOtaUsecase(nil, nil, nil, nil, nil, deviceUc, configUc, client, log.DefaultLogger)
+
+			_, err := uc.saveActivationCode(ctx, deviceId, map[string]interface{}{"board": "bread-compact-wifi"})
+			require.NoError(t, err)
+			challenge := uc.issueActivationChallenge(ctx, deviceId)
+			require.NotEmpty(t, challenge)
+			require.Equal(t, challenge, uc.issueActivationChallenge(ctx, deviceId), "有效期内重复请求返回同一个挑战码")
+
+			signed := challenge
+			if tt.stale {
+				signed, err = kit.GenerateActivationChallenge()
+				require.NoError(t, err)
+			}
+			key := kit.DeriveDeviceActivationKey("default-secret", tt.keyDevice)
+			_, err = uc.VerifyDeviceActivation(ctx, deviceId, &ActivationPayload{
+				SerialNumber: "SN001",
+				Challenge:    signed,
+				HMAC:         kit.SignActivationChallenge(key, signed),
+			})
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			dataMap, err := uc.getActivationData(ctx, deviceId)
+			require.NoError(t, err)
+			require.Equal(t, true, dataMap["verified"])
+		})
+	}
+}
+
+func TestActivateDeviceByCodeLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		users    int64 // 轮流使用的用户数
+		ip       string
+		want     int // 第几次尝试开始被拒绝
+	}{
+		{name: "单个用户", attempts: activationMaxFailures + 1, users: 1, ip: "10.0.0.1", want: activationMaxFailures + 1},
+		{name: "同一IP的多个用户", attempts: activationMaxIPFailures + 1, users: activationMaxIPFailures, ip: "10.0.0.1", want: activationMaxIPFailures + 1},
+		{name: "未知IP只按用户限制", attempts: activationMaxIPFailures + 1, users: activationMaxIPFailures + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := miniredis.RunT(t)
+			client := kit.NewRedisClientWithClient(redis.NewClient(&redis.Options{Addr: server.Addr()}), log.DefaultLogger)
+			uc := NewOtaUsecase(nil, nil, nil, nil, nil, nil, nil, client, log.DefaultLogger)
+			ctx := middleware.WithClientInfo(context.Background(), &middleware.ClientInfo{IP: tt.ip})
+
+			for i := 1; i <= tt.attempts; i++ {
+				err := uc.ActivateDeviceByCode(ctx, int64(i)%tt.users, "agent-1", "123456")
+				if tt.want > 0 && i >= tt.want {
+					require.True(t, cerrors.IsPermissionDenied(err), "第%d次尝试", i)
+				} else {
+					require.True(t, cerrors.IsInvalidInput(err), "第%d次尝试", i)
+				}
+			}
+		})
+	}
+}
+
+// stubConfigRepo 按参数编码返回固定参数值
+type stubConfigRepo struct {
+	ConfigRepo
+	params map[string]string
+}
+
+func (r *stubConfigRepo) GetSysParamsByCode(ctx context.Context, paramCode string) (*SysParam, error) {
+	value, ok := r.params[paramCode]
+	if !ok {
+		return nil, nil
+	}
+	return &SysParam{ParamCode: paramCode, ParamValue: value}, nil
+}
@@ -0,0 +1,225 @@
+package biz
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+)
+
+// 差分包状态
+const (
+	OtaDeltaStatusReady   = "ready"   // 可下发
+	OtaDeltaStatusSkipped = "skipped" // 差分包相对完整固件过大，不下发
+)
+
+const (
+	otaDeltaMaxSizeRatio   = 0.7              // 差分包超过完整固件该比例时不下发
+	otaDeltaLockTTL        = 10 * time.Minute // 生成锁有效期，生成失败时到期后重试
+	otaDeltaDownloadPrefix = "delta:"         // 下载链接指向差分包时Redis中记录的ID前缀
+)
+
+// ESP-IDF分区表：app类型的OTA分区子类型为ota_0(0x10)~ota_15(0x1f)
+const (
+	partitionTypeApp       = 0x00
+	partitionSubtypeOtaMin = 0x10
+	partitionSubtypeOtaMax = 0x1f
+)
+
+// OtaDelta 固件差分包
+type OtaDelta struct {
+	ID          string
+	Type        string
+	FromOtaID   string
+	ToOtaID     string
+	FromVersion string
+	ToVersion   string
+	FromSha256  string // 生成时源固件的SHA-256
+	ToSha256    string // 生成时目标固件的SHA-256
+	PatchPath   string
+	Size        int64
+	Sha256      string
+	Status      string
+	CreatedAt   time.Time
+}
+
+// FirmwareDelta 下发给设备的差分升级包，设备应用后按Firmware中的SHA-256和签名校验完整固件
+type FirmwareDelta struct {
+	FromVersion string `json:"from_version"`
+	URL         string `json:"url"`
+	Sha256      string `json:"sha256"`
+	Size        int64  `json:"size"`
+	Format      string `json:"format"` // 差分包格式，见kit.BsDiffFormat
+}
+
+// OtaDeltaRepo 固件差分包数据访问接口
+type OtaDeltaRepo interface {
+	Create(ctx context.Context, delta *OtaDelta) error
+	GetByID(ctx context.Context, id string) (*OtaDelta, error)
+
+	// GetByOtas 查询源固件到目标固件的差分包，不存在时返回nil
+	GetByOtas(ctx context.Context, fromOtaId, toOtaId string) (*OtaDelta, error)
+
+	Delete(ctx context.Context, id string) error
+
+	// DeleteByOtas 删除源或目标为指定固件的差分包
+	DeleteByOtas(ctx context.Context, otaIds []string) error
+}
+
+// supportsDeltaUpdate 设备需要至少两个能容纳新固件的OTA分区，才能在读取当前分区的同时写入另一个分区
+func supportsDeltaUpdate(partitions []*Partition, imageSize int64) bool {
+	count := 0
+	for _, partition := range partitions {
+		if partition == nil || partition.Type == nil || partition.Subtype == nil {
+			continue
+		}
+		if *partition.Type != partitionTypeApp || *partition.Subtype < partitionSubtypeOtaMin || *partition.Subtype > partitionSubtypeOtaMax {
+			continue
+		}
+		if partition.Size != nil && int64(uint32(*partition.Size)) < imageSize {
+			continue
+		}
+		count++
+	}
+	return count >= 2
+}
+
+// buildFirmwareDelta 查找设备当前版本到目标固件的差分包，尚未生成时后台生成，本次仅下发完整固件
+func (uc *OtaUsecase) buildFirmwareDelta(ctx context.Context, target *Ota, currentVersion string, partitions []*Partition, otaUrl string) *FirmwareDelta {
+	if target.Sha256 == "" || !supportsDeltaUpdate(partitions, target.Size) {
+		return nil
+	}
+	source, err := uc.repo.GetByTypeAndVersion(ctx, target.Type, currentVersion)
+	if err != nil {
+		uc.log.Warnf("查询设备当前版本固件失败: %v", err)
+		return nil
+	}
+	if source == nil || source.ID == target.ID || source.FirmwarePath == "" || source.Sha256 == "" {
+		return nil
+	}
+
+	delta, err := uc.deltaRepo.GetByOtas(ctx, source.ID, target.ID)
+	if err != nil {
+		uc.log.Warnf("查询固件差分包失败: %v", err)
+		return nil
+	}
+	if delta == nil || delta.FromSha256 != source.Sha256 || delta.ToSha256 != target.Sha256 {
+		uc.scheduleOtaDelta(ctx, source, target)
+		return nil
+	}
+	if delta.Status != OtaDeltaStatusReady {
+		return nil
+	}
+
+	return &FirmwareDelta{
+		FromVersion: delta.FromVersion,
+		URL:         uc.firmwareDownloadURL(ctx, otaUrl, otaDeltaDownloadPrefix+delta.ID, delta.PatchPath, otaDeltaFilename(delta)),
+		Sha256:      delta.Sha256,
+		Size:        delta.Size,
+		Format:      kit.BsDiffFormat,
+	}
+}
+
+// scheduleOtaDelta 后台生成差分包，通过Redis锁避免多副本重复生成
+func (uc *OtaUsecase) scheduleOtaDelta(ctx context.Context, source, target *Ota) {
+	lockKey := kit.GetOtaDeltaLockKey(source.ID, target.ID)
+	locked, err := uc.redisClient.GetClient().SetNX(ctx, lockKey, "1", otaDeltaLockTTL).Result()
+	if err != nil || !locked {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if err := uc.generateOtaDelta(ctx, source, target); err != nil {
+			// 保留锁，到期后再重试
+			uc.log.Warnf("生成固件差分包失败, %s -> %s: %v", source.Version, target.Version, err)
+			return
+		}
+		_ = uc.redisClient.Delete(ctx, lockKey)
+	}()
+}
+
+// generateOtaDelta 生成源固件到目标固件的差分包并保存，替换已失效的旧差分包
+func (uc *OtaUsecase) generateOtaDelta(ctx context.Context, source, target *Ota) error {
+	oldData, _, err := uc.readFirmwareFile(ctx, source.FirmwarePath, source.Type, source.Version)
+	if err != nil {
+		return err
+	}
+	newData, _, err := uc.readFirmwareFile(ctx, target.FirmwarePath, target.Type, target.Version)
+	if err != nil {
+		return err
+	}
+
+	patch, err := kit.BsDiff(oldData, newData)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(patch)
+	delta := &OtaDelta{
+		Type:        target.Type,
+		FromOtaID:   source.ID,
+		ToOtaID:     target.ID,
+		FromVersion: source.Version,
+		ToVersion:   target.Version,
+		FromSha256:  source.Sha256,
+		ToSha256:    target.Sha256,
+		Size:        int64(len(patch)),
+		Sha256:      hex.EncodeToString(sum[:]),
+		Status:      OtaDeltaStatusReady,
+	}
+	if float64(len(patch)) > float64(len(newData))*otaDeltaMaxSizeRatio {
+		delta.Status = OtaDeltaStatusSkipped
+	} else {
+		delta.PatchPath, err = uc.store.Put(ctx, fmt.Sprintf("%x.patch", md5.Sum(patch)), patch)
+		if err != nil {
+			return err
+		}
+	}
+
+	existing, err := uc.deltaRepo.GetByOtas(ctx, source.ID, target.ID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		if err := uc.deltaRepo.Delete(ctx, existing.ID); err != nil {
+			return err
+		}
+	}
+	if err := uc.deltaRepo.Create(ctx, delta); err != nil {
+		return err
+	}
+	uc.log.Infof("固件差分包已生成, %s: %s -> %s, 大小: %d/%d, 状态: %s",
+		target.Type, source.Version, target.Version, len(patch), len(newData), delta.Status)
+	return nil
+}
+
+// openOtaDeltaDownload 打开差分包下载
+func (uc *OtaUsecase) openOtaDeltaDownload(ctx context.Context, deltaId string) (*FirmwareDownload, error) {
+	delta, err := uc.deltaRepo.GetByID(ctx, deltaId)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if delta == nil || delta.PatchPath == "" {
+		return nil, uc.handleError.ErrNotFound(ctx, fmt.Errorf("差分包不存在"))
+	}
+
+	object, err := uc.store.Open(ctx, delta.PatchPath)
+	if err != nil {
+		return nil, uc.handleError.ErrNotFound(ctx, fmt.Errorf("差分包文件不存在: %v", err))
+	}
+	return &FirmwareDownload{
+		Object:   object,
+		Filename: otaDeltaFilename(delta),
+		ETag:     `"` + delta.Sha256 + `"`,
+	}, nil
+}
+
+// otaDeltaFilename 生成差分包下载文件名：类型_源版本_目标版本.patch
+func otaDeltaFilename(delta *OtaDelta) string {
+	return sanitizeFilename(strings.Join([]string{delta.Type, delta.FromVersion, delta.ToVersion}, "_") + ".patch")
+}
@@ -0,0 +1,24 @@
+package biz
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupportsDeltaUpdate(t *testing.T) {
+	partition := func(partitionType, subtype, size int32) *Partition {
+		return &Partition{Type: &partitionType, Subtype: &subtype, Size: &size}
+	}
+	factory := partition(0x00, 0x00, 0x100000)
+	ota0 := partition(0x00, 0x10, 0x300000)
+	ota1 := partition(0x00, 0x11, 0x300000)
+	nvs := partition(0x01, 0x02, 0x4000)
+
+	assert.True(t, supportsDeltaUpdate([]*Partition{nvs, factory, ota0, ota1}, 0x200000))
+	// 只有一个OTA分区时无法边读边写
+	assert.False(t, supportsDeltaUpdate([]*Partition{nvs, factory, ota0}, 0x200000))
+	// 新固件超过分区大小
+	assert.False(t, supportsDeltaUpdate([]*Partition{ota0, ota1}, 0x400000))
+	assert.False(t, supportsDeltaUpdate(nil, 0x200000))
+}
@@ -0,0 +1,230 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+)
+
+// 设备固件升级结果
+const (
+	FirmwareOutcomePending    = "pending"    // 已下发，设备尚未上报新版本
+	FirmwareOutcomeSuccess    = "success"    // 设备已上报下发的版本
+	FirmwareOutcomeMismatch   = "mismatch"   // 设备上报的版本与下发的版本不一致
+	FirmwareOutcomeSuperseded = "superseded" // 升级前又下发了其他固件
+	FirmwareOutcomeManual     = "manual"     // 未经OTA下发的版本变更（如手动烧录）
+)
+
+// otaStuckAttempts 设备停留在升级前版本并重复收到同一固件的次数达到该值时，视为反复下载失败
+const otaStuckAttempts = 3
+
+// FirmwareHistory 设备固件版本历史
+type FirmwareHistory struct {
+	ID          string
+	DeviceID    string
+	MacAddress  string
+	Board       string
+	FromVersion string
+	ToVersion   string // 升级后版本，下发中为下发的目标版本
+	OtaID       string // 下发的固件，非OTA升级时为空
+	Outcome     string
+	Attempts    int32 // 下发次数
+	CompletedAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time // 最近一次下发时间
+}
+
+// ListFirmwareHistoryParams 版本历史查询参数
+type ListFirmwareHistoryParams struct {
+	MacAddress *string // 可选，设备MAC地址
+	OtaID      *string // 可选，固件ID
+}
+
+// FirmwareAdoption 固件升级情况
+type FirmwareAdoption struct {
+	Ota          *Ota
+	Installed    int64              // 当前运行该版本的设备数
+	Total        int64              // 该类型的设备总数
+	Outcomes     map[string]int64   // 各升级结果的记录数
+	StuckDevices []*FirmwareHistory // 反复下载仍未升级的设备
+}
+
+// FirmwareHistoryRepo 设备固件版本历史数据访问接口
+type FirmwareHistoryRepo interface {
+	Create(ctx context.Context, history *FirmwareHistory) error
+	Update(ctx context.Context, history *FirmwareHistory) error
+
+	// GetPendingByDevice 获取设备尚未确定结果的下发记录
+	GetPendingByDevice(ctx context.Context, deviceId string) (*FirmwareHistory, error)
+
+	// Page 分页查询版本历史，按创建时间倒序
+	Page(ctx context.Context, params *ListFirmwareHistoryParams, page *kit.PageRequest) ([]*FirmwareHistory, int, error)
+
+	// CountOutcomesByOta 按固件统计各升级结果的记录数
+	CountOutcomesByOta(ctx context.Context, otaIds []string) (map[string]map[string]int64, error)
+
+	// ListStuck 查询下发次数不少于minAttempts且仍未升级的记录
+	ListStuck(ctx context.Context, otaIds []string, minAttempts int32) ([]*FirmwareHistory, error)
+}
+
+// recordFirmwareCheckIn 根据设备上报的版本确定上一次下发的结果，没有下发记录的版本变更记为手动升级
+func (uc *OtaUsecase) recordFirmwareCheckIn(ctx context.Context, device *Device, currentVersion string) {
+	if device == nil || currentVersion == "" {
+		return
+	}
+
+	pending, err := uc.historyRepo.GetPendingByDevice(ctx, device.ID)
+	if err != nil {
+		uc.log.Warnf("查询设备固件下发记录失败, mac: %s, error: %v", device.MacAddress, err)
+		return
+	}
+
+	now := time.Now()
+	if pending != nil {
+		if currentVersion == pending.FromVersion {
+			return
+		}
+		pending.Outcome = FirmwareOutcomeSuccess
+		if currentVersion != pending.ToVersion {
+			pending.Outcome = FirmwareOutcomeMismatch
+			pending.ToVersion = currentVersion
+		}
+		pending.CompletedAt = &now
+		if err := uc.historyRepo.Update(ctx, pending); err != nil {
+			uc.log.Warnf("更新设备固件升级结果失败, mac: %s, error: %v", device.MacAddress, err)
+		}
+		return
+	}
+
+	if device.AppVersion == "" || device.AppVersion == currentVersion {
+		return
+	}
+	history := &FirmwareHistory{
+		DeviceID:    device.ID,
+		MacAddress:  device.MacAddress,
+		Board:       device.Board,
+		FromVersion: device.AppVersion,
+		ToVersion:   currentVersion,
+		Outcome:     FirmwareOutcomeManual,
+		CompletedAt: &now,
+	}
+	if err := uc.historyRepo.Create(ctx, history); err != nil {
+		uc.log.Warnf("记录设备版本变更失败, mac: %s, error: %v", device.MacAddress, err)
+	}
+}
+
+// recordFirmwareOffer 记录向设备下发固件，设备仍为升级前版本时重复下发同一固件只累加次数
+func (uc *OtaUsecase) recordFirmwareOffer(ctx context.Context, device *Device, ota *Ota, currentVersion string) {
+	if device == nil || ota == nil {
+		return
+	}
+
+	pending, err := uc.historyRepo.GetPendingByDevice(ctx, device.ID)
+	if err != nil {
+		uc.log.Warnf("查询设备固件下发记录失败, mac: %s, error: %v", device.MacAddress, err)
+		return
+	}
+
+	if pending != nil {
+		if pending.OtaID == ota.ID && pending.FromVersion == currentVersion {
+			pending.Attempts++
+			if pending.Attempts == otaStuckAttempts {
+				uc.log.Warnf("设备 %s 已 %d 次下载固件 %s 仍未升级", device.MacAddress, pending.Attempts, ota.Version)
+			}
+			if err := uc.historyRepo.Update(ctx, pending); err != nil {
+				uc.log.Warnf("更新设备固件下发记录失败, mac: %s, error: %v", device.MacAddress, err)
+			}
+			return
+		}
+
+		now := time.Now()
+		pending.Outcome = FirmwareOutcomeSuperseded
+		pending.CompletedAt = &now
+		if err := uc.historyRepo.Update(ctx, pending); err != nil {
+			uc.log.Warnf("更新设备固件下发记录失败, mac: %s, error: %v", device.MacAddress, err)
+		}
+	}
+
+	history := &FirmwareHistory{
+		DeviceID:    device.ID,
+		MacAddress:  device.MacAddress,
+		Board:       ota.Type,
+		FromVersion: currentVersion,
+		ToVersion:   ota.Version,
+		OtaID:       ota.ID,
+		Outcome:     FirmwareOutcomePending,
+		Attempts:    1,
+	}
+	if err := uc.historyRepo.Create(ctx, history); err != nil {
+		uc.log.Warnf("记录设备固件下发失败, mac: %s, error: %v", device.MacAddress, err)
+	}
+}
+
+// PageFirmwareHistory 分页查询设备固件版本历史
+func (uc *OtaUsecase) PageFirmwareHistory(ctx context.Context, params *ListFirmwareHistoryParams, page *kit.PageRequest) ([]*FirmwareHistory, int, error) {
+	list, total, err := uc.historyRepo.Page(ctx, params, page)
+	if err != nil {
+		return nil, 0, uc.handleError.ErrInternal(ctx, err)
+	}
+	return list, total, nil
+}
+
+// GetFirmwareAdoption 统计各固件的升级情况，otaType为空时统计全部固件
+func (uc *OtaUsecase) GetFirmwareAdoption(ctx context.Context, otaType string) ([]*FirmwareAdoption, error) {
+	params := &ListOtaParams{}
+	if otaType != "" {
+		params.Type = &otaType
+	}
+	otas, _, err := uc.repo.PageOta(ctx, params, nil)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if len(otas) == 0 {
+		return []*FirmwareAdoption{}, nil
+	}
+
+	otaIds := make([]string, 0, len(otas))
+	for _, ota := range otas {
+		otaIds = append(otaIds, ota.ID)
+	}
+	outcomes, err := uc.historyRepo.CountOutcomesByOta(ctx, otaIds)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	stuck, err := uc.historyRepo.ListStuck(ctx, otaIds, otaStuckAttempts)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	stuckByOta := make(map[string][]*FirmwareHistory)
+	for _, history := range stuck {
+		stuckByOta[history.OtaID] = append(stuckByOta[history.OtaID], history)
+	}
+
+	// 同类型固件共用设备版本统计
+	versionCounts := make(map[string]map[string]int64)
+	result := make([]*FirmwareAdoption, 0, len(otas))
+	for _, ota := range otas {
+		counts, ok := versionCounts[ota.Type]
+		if !ok {
+			counts, err = uc.DeviceUsecase.repo.CountByAppVersion(ctx, ota.Type)
+			if err != nil {
+				return nil, uc.handleError.ErrInternal(ctx, fmt.Errorf("统计设备版本失败: %w", err))
+			}
+			versionCounts[ota.Type] = counts
+		}
+
+		adoption := &FirmwareAdoption{
+			Ota:          ota,
+			Installed:    counts[ota.Version],
+			Outcomes:     outcomes[ota.ID],
+			StuckDevices: stuckByOta[ota.ID],
+		}
+		for _, count := range counts {
+			adoption.Total += count
+		}
+		result = append(result, adoption)
+	}
+	return result, nil
+}
@@ -0,0 +1,139 @@
+package biz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordFirmwareCheckIn(t *testing.T) {
+	pending := &FirmwareHistory{ID: "1", OtaID: "ota2", FromVersion: "1.0.0", ToVersion: "2.0.0", Outcome: FirmwareOutcomePending}
+
+	tests := []struct {
+		name       string
+		pending    *FirmwareHistory
+		appVersion string
+		current    string
+		want       *FirmwareHistory // nil表示不记录
+	}{
+		{
+			name:       "仍为升级前版本",
+			pending:    pending,
+			appVersion: "1.0.0",
+			current:    "1.0.0",
+		},
+		{
+			name:       "上报下发的版本记为成功",
+			pending:    pending,
+			appVersion: "1.0.0",
+			current:    "2.0.0",
+			want:       &FirmwareHistory{FromVersion: "1.0.0", ToVersion: "2.0.0", Outcome: FirmwareOutcomeSuccess},
+		},
+		{
+			name:       "上报的版本与下发不一致",
+			pending:    pending,
+			appVersion: "1.0.0",
+			current:    "3.0.0",
+			want:       &FirmwareHistory{FromVersion: "1.0.0", ToVersion: "3.0.0", Outcome: FirmwareOutcomeMismatch},
+		},
+		{
+			name:       "没有下发记录的版本变更记为手动升级",
+			appVersion: "2.0.0",
+			current:    "2.1.0",
+			want:       &FirmwareHistory{FromVersion: "2.0.0", ToVersion: "2.1.0", Outcome: FirmwareOutcomeManual},
+		},
+		{
+			name:       "没有下发记录且版本未变",
+			appVersion: "2.0.0",
+			current:    "2.0.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &stubFirmwareHistoryRepo{}
+			if tt.pending != nil {
+				copied := *tt.pending
+				repo.pending = &copied
+			}
+			uc := NewOtaUsecase(nil, nil, repo, nil, nil, nil, nil, nil, log.DefaultLogger)
+			device := &Device{ID: "aa:bb:cc:dd:ee:ff", MacAddress: "aa:bb:cc:dd:ee:ff", AppVersion: tt.appVersion}
+
+			uc.recordFirmwareCheckIn(context.Background(), device, tt.current)
+			if tt.want == nil {
+				require.Nil(t, repo.saved)
+				return
+			}
+			require.NotNil(t, repo.saved)
+			require.Equal(t, tt.want.FromVersion, repo.saved.FromVersion)
+			require.Equal(t, tt.want.ToVersion, repo.saved.ToVersion)
+			require.Equal(t, tt.want.Outcome, repo.saved.Outcome)
+			require.NotNil(t, repo.saved.CompletedAt)
+		})
+	}
+}
+
+func TestRecordFirmwareOffer(t *testing.T) {
+	v2 := &Ota{ID: "ota2", Type: "esp32", Version: "2.0.0"}
+	v3 := &Ota{ID: "ota3", Type: "esp32", Version: "3.0.0"}
+
+	tests := []struct {
+		name         string
+		ota          *Ota
+		wantAttempts int32
+		wantOutcome  string // 原下发记录的结果
+		wantCreated  bool
+	}{
+		{name: "重复下发同一固件只累加次数", ota: v2, wantAttempts: 2, wantOutcome: FirmwareOutcomePending},
+		{name: "下发其他固件时原记录被替代", ota: v3, wantAttempts: 1, wantOutcome: FirmwareOutcomeSuperseded, wantCreated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &stubFirmwareHistoryRepo{pending: &FirmwareHistory{
+				ID: "1", OtaID: "ota2", FromVersion: "1.0.0", ToVersion: "2.0.0", Outcome: FirmwareOutcomePending, Attempts: 1,
+			}}
+			uc := NewOtaUsecase(nil, nil, repo, nil, nil, nil, nil, nil, log.DefaultLogger)
+			device := &Device{ID: "aa:bb:cc:dd:ee:ff", MacAddress: "aa:bb:cc:dd:ee:ff", AppVersion: "1.0.0"}
+
+			uc.recordFirmwareOffer(context.Background(), device, tt.ota, "1.0.0")
+			require.Equal(t, tt.wantAttempts, repo.pending.Attempts)
+			require.Equal(t, tt.wantOutcome, repo.pending.Outcome)
+			require.Equal(t, tt.wantCreated, repo.created != nil)
+			if tt.wantCreated {
+				require.Equal(t, tt.ota.ID, repo.created.OtaID)
+				require.Equal(t, FirmwareOutcomePending, repo.created.Outcome)
+			}
+		})
+	}
+}
+
+// stubFirmwareHistoryRepo 返回固定的下发记录，并记录保存的历史
+type stubFirmwareHistoryRepo struct {
+	FirmwareHistoryRepo
+	pending *FirmwareHistory
+	saved   *FirmwareHistory
+	created *FirmwareHistory
+}
+
+func (r *stubFirmwareHistoryRepo) Create(ctx context.Context, history *FirmwareHistory) error {
+	copied := *history
+	r.created = &copied
+	r.saved = &copied
+	return nil
+}
+
+func (r *stubFirmwareHistoryRepo) Update(ctx context.Context, history *FirmwareHistory) error {
+	copied := *history
+	r.pending = &copied
+	r.saved = &copied
+	return nil
+}
+
+func (r *stubFirmwareHistoryRepo) GetPendingByDevice(ctx context.Context, deviceId string) (*FirmwareHistory, error) {
+	if r.pending == nil {
+		return nil, nil
+	}
+	copied := *r.pending
+	return &copied, nil
+}
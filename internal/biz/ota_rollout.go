@@ -0,0 +1,380 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+)
+
+// OTA灰度发布状态
+const (
+	OtaRolloutStatusActive     = "active"      // 灰度中
+	OtaRolloutStatusPaused     = "paused"      // 已暂停，所有设备使用正式版本
+	OtaRolloutStatusCompleted  = "completed"   // 已全量，目标固件成为正式版本
+	OtaRolloutStatusRolledBack = "rolled_back" // 已回滚，已升级的设备降级到回滚版本
+)
+
+// OTA灰度发布操作
+const (
+	OtaRolloutActionPause    = "pause"
+	OtaRolloutActionResume   = "resume"
+	OtaRolloutActionComplete = "complete"
+	OtaRolloutActionRollback = "rollback"
+)
+
+// OtaRollout OTA固件灰度发布计划
+type OtaRollout struct {
+	ID              string
+	OtaID           string     // 灰度发布的目标固件
+	Type            string     // 固件类型，与目标固件一致
+	Percentage      int32      // 目标灰度比例（0-100）
+	StartPercentage int32      // 起始灰度比例，在RampMinutes内线性爬升到Percentage
+	RampMinutes     int32      // 爬升时长（分钟），0表示立即使用Percentage
+	MacAddresses    []string   // 指定灰度的设备，不受比例限制
+	UserID          int64      // 限定灰度范围的用户，0表示不限
+	AgentID         string     // 限定灰度范围的智能体，空表示不限
+	Status          string     // 状态
+	RollbackOtaID   string     // 回滚使用的固件
+	StartedAt       time.Time  // 灰度开始时间，恢复时顺延暂停时长
+	PausedAt        *time.Time // 暂停时间
+	Creator         int64
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// OtaRolloutRepo OTA灰度发布计划数据访问接口
+type OtaRolloutRepo interface {
+	Create(ctx context.Context, rollout *OtaRollout) error
+	Update(ctx context.Context, rollout *OtaRollout) error
+	GetByID(ctx context.Context, id string) (*OtaRollout, error)
+
+	// List 查询灰度发布计划，otaType为空时查询全部，按创建时间倒序
+	List(ctx context.Context, otaType string) ([]*OtaRollout, error)
+
+	// ListUnfinished 查询未全量的灰度发布计划（灰度中、已暂停、已回滚），按创建时间倒序
+	ListUnfinished(ctx context.Context, otaType string) ([]*OtaRollout, error)
+}
+
+// EffectivePercentage 计算当前生效的灰度比例，暂停期间停留在暂停时的比例
+func (r *OtaRollout) EffectivePercentage(now time.Time) int32 {
+	if r.RampMinutes <= 0 || r.StartPercentage >= r.Percentage {
+		return r.Percentage
+	}
+	if r.Status == OtaRolloutStatusPaused && r.PausedAt != nil {
+		now = *r.PausedAt
+	}
+
+	elapsed := now.Sub(r.StartedAt)
+	ramp := time.Duration(r.RampMinutes) * time.Minute
+	if elapsed <= 0 {
+		return r.StartPercentage
+	}
+	if elapsed >= ramp {
+		return r.Percentage
+	}
+	return r.StartPercentage + int32(int64(r.Percentage-r.StartPercentage)*int64(elapsed)/int64(ramp))
+}
+
+// Selects 判断设备是否命中灰度：指定的MAC地址总是命中，其余设备需在用户/智能体范围内，
+// 且按MAC地址哈希的分桶落在当前灰度比例内，保证同一设备多次检查结果一致
+func (r *OtaRollout) Selects(device *Device, now time.Time) bool {
+	if device == nil {
+		return false
+	}
+	mac := normalizeRolloutMac(device.MacAddress)
+	for _, macAddress := range r.MacAddresses {
+		if normalizeRolloutMac(macAddress) == mac {
+			return true
+		}
+	}
+
+	if r.UserID > 0 && device.UserID != r.UserID {
+		return false
+	}
+	if r.AgentID != "" && device.AgentID != r.AgentID {
+		return false
+	}
+	return rolloutBucket(r.ID, mac) < r.EffectivePercentage(now)
+}
+
+// rolloutBucket 计算设备在灰度计划中的分桶（0-99），不同计划的分桶相互独立
+func rolloutBucket(rolloutId, mac string) int32 {
+	h := fnv.New32a()
+	h.Write([]byte(rolloutId + ":" + mac))
+	return int32(h.Sum32() % 100)
+}
+
+// normalizeRolloutMac 统一MAC地址格式（小写、冒号分隔）
+func normalizeRolloutMac(macAddress string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(macAddress), "-", ":"))
+}
+
+// CreateRollout 创建灰度发布计划，同一固件类型同时只能有一个灰度中或已暂停的计划
+func (uc *OtaUsecase) CreateRollout(ctx context.Context, rollout *OtaRollout) (*OtaRollout, error) {
+	if rollout == nil || rollout.OtaID == "" {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("灰度固件不能为空"))
+	}
+	ota, err := uc.repo.GetByID(ctx, rollout.OtaID)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if ota == nil {
+		return nil, uc.handleError.ErrNotFound(ctx, fmt.Errorf("OTA固件记录不存在"))
+	}
+	if err := uc.validateRollout(ctx, rollout); err != nil {
+		return nil, err
+	}
+
+	rollouts, err := uc.rolloutRepo.ListUnfinished(ctx, ota.Type)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	for _, item := range rollouts {
+		if item.Status == OtaRolloutStatusActive || item.Status == OtaRolloutStatusPaused {
+			return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("固件类型 %s 已有进行中的灰度发布，请先全量或回滚", ota.Type))
+		}
+	}
+
+	rollout.Type = ota.Type
+	rollout.Status = OtaRolloutStatusActive
+	rollout.StartedAt = time.Now()
+	rollout.PausedAt = nil
+	rollout.RollbackOtaID = ""
+	if err := uc.rolloutRepo.Create(ctx, rollout); err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	return rollout, nil
+}
+
+// UpdateRollout 修改灰度中或已暂停计划的灰度范围和比例
+func (uc *OtaUsecase) UpdateRollout(ctx context.Context, id string, params *OtaRollout) (*OtaRollout, error) {
+	rollout, err := uc.GetRollout(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rollout.Status != OtaRolloutStatusActive && rollout.Status != OtaRolloutStatusPaused {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("灰度发布已结束，无法修改"))
+	}
+	if err := uc.validateRollout(ctx, params); err != nil {
+		return nil, err
+	}
+
+	rollout.Percentage = params.Percentage
+	rollout.StartPercentage = params.StartPercentage
+	rollout.RampMinutes = params.RampMinutes
+	rollout.MacAddresses = params.MacAddresses
+	rollout.UserID = params.UserID
+	rollout.AgentID = params.AgentID
+	if err := uc.rolloutRepo.Update(ctx, rollout); err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	return rollout, nil
+}
+
+// GetRollout 查询灰度发布计划
+func (uc *OtaUsecase) GetRollout(ctx context.Context, id string) (*OtaRollout, error) {
+	if id == "" {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("id不能为空"))
+	}
+	rollout, err := uc.rolloutRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if rollout == nil {
+		return nil, uc.handleError.ErrNotFound(ctx, fmt.Errorf("灰度发布计划不存在"))
+	}
+	return rollout, nil
+}
+
+// ListRollouts 查询灰度发布计划列表
+func (uc *OtaUsecase) ListRollouts(ctx context.Context, otaType string) ([]*OtaRollout, error) {
+	rollouts, err := uc.rolloutRepo.List(ctx, otaType)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	return rollouts, nil
+}
+
+// ChangeRolloutStatus 暂停、恢复、全量或回滚灰度发布
+// 回滚时rollbackOtaId为空则回滚到当前的正式版本
+func (uc *OtaUsecase) ChangeRolloutStatus(ctx context.Context, id, action, rollbackOtaId string) (*OtaRollout, error) {
+	rollout, err := uc.GetRollout(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	switch action {
+	case OtaRolloutActionPause:
+		if rollout.Status != OtaRolloutStatusActive {
+			return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("只有灰度中的计划可以暂停"))
+		}
+		rollout.Status = OtaRolloutStatusPaused
+		rollout.PausedAt = &now
+	case OtaRolloutActionResume:
+		if rollout.Status != OtaRolloutStatusPaused {
+			return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("只有已暂停的计划可以恢复"))
+		}
+		// 顺延暂停时长，使比例从暂停时的位置继续爬升
+		if rollout.PausedAt != nil {
+			rollout.StartedAt = rollout.StartedAt.Add(now.Sub(*rollout.PausedAt))
+		}
+		rollout.Status = OtaRolloutStatusActive
+		rollout.PausedAt = nil
+	case OtaRolloutActionComplete:
+		if rollout.Status != OtaRolloutStatusActive && rollout.Status != OtaRolloutStatusPaused {
+			return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("灰度发布已结束"))
+		}
+		rollout.Status = OtaRolloutStatusCompleted
+		rollout.PausedAt = nil
+	case OtaRolloutActionRollback:
+		if rollout.Status != OtaRolloutStatusActive && rollout.Status != OtaRolloutStatusPaused {
+			return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("灰度发布已结束"))
+		}
+		target, err := uc.rollbackTarget(ctx, rollout, rollbackOtaId)
+		if err != nil {
+			return nil, err
+		}
+		rollout.Status = OtaRolloutStatusRolledBack
+		rollout.RollbackOtaID = target.ID
+		rollout.PausedAt = nil
+	default:
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("不支持的操作: %s", action))
+	}
+
+	if err := uc.rolloutRepo.Update(ctx, rollout); err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	uc.log.Infof("OTA灰度发布 %s 状态变更为 %s，固件类型: %s", rollout.ID, rollout.Status, rollout.Type)
+	return rollout, nil
+}
+
+// rollbackTarget 确定回滚使用的固件，必须是同类型的其他版本
+func (uc *OtaUsecase) rollbackTarget(ctx context.Context, rollout *OtaRollout, rollbackOtaId string) (*Ota, error) {
+	if rollbackOtaId == "" {
+		ota, err := uc.generalFirmware(ctx, rollout.Type)
+		if err != nil {
+			return nil, uc.handleError.ErrInternal(ctx, err)
+		}
+		if ota == nil {
+			return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("没有可回滚的固件版本，请指定回滚固件"))
+		}
+		return ota, nil
+	}
+
+	ota, err := uc.repo.GetByID(ctx, rollbackOtaId)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if ota == nil {
+		return nil, uc.handleError.ErrNotFound(ctx, fmt.Errorf("回滚固件不存在"))
+	}
+	if ota.Type != rollout.Type || ota.ID == rollout.OtaID {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("回滚固件必须是同类型的其他版本"))
+	}
+	return ota, nil
+}
+
+// validateRollout 校验灰度比例和范围
+func (uc *OtaUsecase) validateRollout(ctx context.Context, rollout *OtaRollout) error {
+	if rollout.Percentage < 0 || rollout.Percentage > 100 || rollout.StartPercentage < 0 || rollout.StartPercentage > 100 {
+		return uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("灰度比例必须在0-100之间"))
+	}
+	if rollout.StartPercentage > rollout.Percentage {
+		return uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("起始比例不能大于目标比例"))
+	}
+	if rollout.RampMinutes < 0 {
+		return uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("爬升时长不能为负数"))
+	}
+
+	macAddresses := make([]string, 0, len(rollout.MacAddresses))
+	for _, macAddress := range rollout.MacAddresses {
+		if !IsMacAddressValid(macAddress) {
+			return uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("MAC地址格式不正确: %s", macAddress))
+		}
+		macAddresses = append(macAddresses, normalizeRolloutMac(macAddress))
+	}
+	rollout.MacAddresses = macAddresses
+
+	if rollout.Percentage == 0 && len(rollout.MacAddresses) == 0 {
+		return uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("灰度比例为0时必须指定设备MAC地址"))
+	}
+	return nil
+}
+
+// generalFirmware 获取指定类型的正式版本：最新的固件，但不包含未全量的灰度固件
+func (uc *OtaUsecase) generalFirmware(ctx context.Context, otaType string) (*Ota, error) {
+	rollouts, err := uc.rolloutRepo.ListUnfinished(ctx, otaType)
+	if err != nil {
+		return nil, err
+	}
+	excludeIds := make([]string, 0, len(rollouts))
+	for _, rollout := range rollouts {
+		excludeIds = append(excludeIds, rollout.OtaID)
+	}
+	return uc.repo.GetLatestOta(ctx, otaType, excludeIds...)
+}
+
+// resolveFirmware 确定设备应使用的固件，返回的downgrade表示是否允许降级（回滚）
+func (uc *OtaUsecase) resolveFirmware(ctx context.Context, device *Device, otaType, currentVersion string) (*Ota, bool, error) {
+	rollouts, err := uc.rolloutRepo.ListUnfinished(ctx, otaType)
+	if err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	excludeIds := make([]string, 0, len(rollouts))
+	for _, rollout := range rollouts {
+		excludeIds = append(excludeIds, rollout.OtaID)
+		switch rollout.Status {
+		case OtaRolloutStatusActive:
+			if !rollout.Selects(device, now) {
+				continue
+			}
+			ota, err := uc.repo.GetByID(ctx, rollout.OtaID)
+			if err != nil {
+				return nil, false, err
+			}
+			if ota != nil {
+				return ota, false, nil
+			}
+		case OtaRolloutStatusRolledBack:
+			// 已升级到灰度固件的设备降级到回滚版本
+			ota, err := uc.repo.GetByID(ctx, rollout.OtaID)
+			if err != nil {
+				return nil, false, err
+			}
+			if ota == nil || ota.Version != currentVersion || rollout.RollbackOtaID == "" {
+				continue
+			}
+			rollbackOta, err := uc.repo.GetByID(ctx, rollout.RollbackOtaID)
+			if err != nil {
+				return nil, false, err
+			}
+			if rollbackOta != nil {
+				return rollbackOta, true, nil
+			}
+		}
+	}
+
+	ota, err := uc.repo.GetLatestOta(ctx, otaType, excludeIds...)
+	return ota, false, err
+}
+
+// checkOtaInRollout 检查固件是否被未全量的灰度发布引用
+func (uc *OtaUsecase) checkOtaInRollout(ctx context.Context, ids []string) error {
+	rollouts, err := uc.rolloutRepo.ListUnfinished(ctx, "")
+	if err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	for _, rollout := range rollouts {
+		for _, id := range ids {
+			if rollout.OtaID == id || rollout.RollbackOtaID == id {
+				return uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("固件被灰度发布计划引用，无法删除"))
+			}
+		}
+	}
+	return nil
+}
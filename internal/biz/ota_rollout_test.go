@@ -0,0 +1,64 @@
+package biz
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOtaRolloutEffectivePercentage(t *testing.T) {
+	startedAt := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	rollout := &OtaRollout{
+		ID:              "rollout1",
+		Percentage:      50,
+		StartPercentage: 10,
+		RampMinutes:     40,
+		Status:          OtaRolloutStatusActive,
+		StartedAt:       startedAt,
+	}
+
+	require.Equal(t, int32(10), rollout.EffectivePercentage(startedAt.Add(-time.Minute)))
+	require.Equal(t, int32(10), rollout.EffectivePercentage(startedAt))
+	require.Equal(t, int32(30), rollout.EffectivePercentage(startedAt.Add(20*time.Minute)))
+	require.Equal(t, int32(50), rollout.EffectivePercentage(startedAt.Add(time.Hour)))
+
+	// 暂停期间停留在暂停时的比例
+	pausedAt := startedAt.Add(10 * time.Minute)
+	rollout.Status = OtaRolloutStatusPaused
+	rollout.PausedAt = &pausedAt
+	require.Equal(t, int32(20), rollout.EffectivePercentage(startedAt.Add(time.Hour)))
+
+	rollout.RampMinutes = 0
+	require.Equal(t, int32(50), rollout.EffectivePercentage(startedAt))
+}
+
+func TestOtaRolloutSelects(t *testing.T) {
+	now := time.Now()
+	rollout := &OtaRollout{ID: "rollout1", Percentage: 30, Status: OtaRolloutStatusActive, StartedAt: now}
+
+	selected := 0
+	for i := 0; i < 1000; i++ {
+		device := &Device{MacAddress: fmt.Sprintf("aa:bb:cc:dd:%02x:%02x", i/256, i%256)}
+		first := rollout.Selects(device, now)
+		// 同一设备多次检查结果一致
+		require.Equal(t, first, rollout.Selects(device, now.Add(time.Hour)))
+		if first {
+			selected++
+		}
+	}
+	require.InDelta(t, 300, selected, 60)
+
+	// 指定的MAC地址总是命中，不区分大小写和分隔符
+	rollout.Percentage = 0
+	rollout.MacAddresses = []string{"aa:bb:cc:dd:ee:ff"}
+	require.True(t, rollout.Selects(&Device{MacAddress: "AA-BB-CC-DD-EE-FF"}, now))
+	require.False(t, rollout.Selects(&Device{MacAddress: "aa:bb:cc:dd:ee:00"}, now))
+
+	// 限定智能体范围时，其他智能体的设备不参与分桶
+	rollout.Percentage = 100
+	rollout.AgentID = "agent1"
+	require.True(t, rollout.Selects(&Device{MacAddress: "aa:bb:cc:dd:ee:00", AgentID: "agent1"}, now))
+	require.False(t, rollout.Selects(&Device{MacAddress: "aa:bb:cc:dd:ee:00", AgentID: "agent2"}, now))
+}
@@ -0,0 +1,242 @@
+package biz
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+)
+
+// OTA固件签名参数（param_type为0，不下发给语音服务节点）
+const (
+	otaSigningKeyParam         = "server.ota_signing_key"          // 当前签名私钥（Ed25519，Base64）
+	otaSigningRetiredKeysParam = "server.ota_signing_retired_keys" // 已轮换的公钥（Base64），多个用;分隔
+)
+
+// OtaSigningKey 固件签名公钥
+type OtaSigningKey struct {
+	KeyID     string
+	PublicKey string // Base64
+	Retired   bool   // 已轮换，仅用于校验旧签名
+}
+
+// FirmwareVerifyResult 固件校验结果
+type FirmwareVerifyResult struct {
+	OtaID          string
+	Sha256         string // 记录的SHA-256
+	ActualSha256   string // 固件文件实际的SHA-256
+	Size           int64  // 记录的大小
+	ActualSize     int64  // 固件文件实际的大小
+	DigestMatch    bool   // 文件与记录一致
+	Signed         bool   // 记录中有签名
+	SignatureValid bool   // 签名校验通过
+	KeyID          string // 签名公钥标识
+	KeyRetired     bool   // 签名公钥已轮换
+}
+
+// fillFirmwareDigest 读取固件文件计算SHA-256和大小，配置了签名私钥时同时签名
+func (uc *OtaUsecase) fillFirmwareDigest(ctx context.Context, entity *Ota) error {
+	if entity.FirmwarePath == "" {
+		return nil
+	}
+	fileData, _, err := uc.readFirmwareFile(ctx, entity.FirmwarePath, entity.Type, entity.Version)
+	if err != nil {
+		return uc.handleError.ErrInvalidInput(ctx, err)
+	}
+	sum := sha256.Sum256(fileData)
+	entity.Sha256 = hex.EncodeToString(sum[:])
+	entity.Size = int64(len(fileData))
+
+	privateKey, err := uc.signingKey(ctx)
+	if err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	signFirmware(privateKey, entity)
+	return nil
+}
+
+// signFirmware 对固件签名，privateKey为nil时清空签名
+func signFirmware(privateKey ed25519.PrivateKey, entity *Ota) {
+	if privateKey == nil {
+		entity.Signature = ""
+		entity.SignKeyID = ""
+		return
+	}
+	manifest := kit.FirmwareManifest(entity.Type, entity.Version, entity.Size, entity.Sha256)
+	entity.Signature = kit.SignFirmwareManifest(privateKey, manifest)
+	entity.SignKeyID = kit.SigningKeyID(privateKey.Public().(ed25519.PublicKey))
+}
+
+// signingKey 获取当前签名私钥，未配置时返回nil
+func (uc *OtaUsecase) signingKey(ctx context.Context) (ed25519.PrivateKey, error) {
+	value, _ := uc.ConfigUsecase.GetValue(ctx, otaSigningKeyParam, false)
+	if strings.TrimSpace(value) == "" || value == "null" {
+		return nil, nil
+	}
+	privateKey, err := kit.ParseSigningKey(value)
+	if err != nil {
+		return nil, fmt.Errorf("参数 %s 配置错误: %w", otaSigningKeyParam, err)
+	}
+	return privateKey, nil
+}
+
+// retiredVerifyKeys 获取已轮换的公钥
+func (uc *OtaUsecase) retiredVerifyKeys(ctx context.Context) []ed25519.PublicKey {
+	value, _ := uc.ConfigUsecase.GetValue(ctx, otaSigningRetiredKeysParam, false)
+	var keys []ed25519.PublicKey
+	for _, item := range strings.Split(value, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		publicKey, err := kit.ParseVerifyKey(item)
+		if err != nil {
+			uc.log.Warnf("忽略无效的已轮换签名公钥: %v", err)
+			continue
+		}
+		keys = append(keys, publicKey)
+	}
+	return keys
+}
+
+// ListSigningKeys 查询当前和已轮换的签名公钥，设备按key_id选择公钥验签
+func (uc *OtaUsecase) ListSigningKeys(ctx context.Context) ([]*OtaSigningKey, error) {
+	privateKey, err := uc.signingKey(ctx)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	keys := make([]*OtaSigningKey, 0)
+	if privateKey != nil {
+		keys = append(keys, newOtaSigningKey(privateKey.Public().(ed25519.PublicKey), false))
+	}
+	for _, publicKey := range uc.retiredVerifyKeys(ctx) {
+		keys = append(keys, newOtaSigningKey(publicKey, true))
+	}
+	return keys, nil
+}
+
+func newOtaSigningKey(publicKey ed25519.PublicKey, retired bool) *OtaSigningKey {
+	return &OtaSigningKey{
+		KeyID:     kit.SigningKeyID(publicKey),
+		PublicKey: base64.StdEncoding.EncodeToString(publicKey),
+		Retired:   retired,
+	}
+}
+
+// RotateSigningKey 生成新的签名私钥，原公钥转为已轮换（仍可校验旧签名）
+// resign为true时用新私钥重新签名全部固件，返回新公钥和重新签名的固件数
+func (uc *OtaUsecase) RotateSigningKey(ctx context.Context, resign bool) (*OtaSigningKey, int, error) {
+	currentKey, err := uc.signingKey(ctx)
+	if err != nil {
+		return nil, 0, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	privateKeyStr, _, err := kit.GenerateSigningKey()
+	if err != nil {
+		return nil, 0, uc.handleError.ErrInternal(ctx, err)
+	}
+	privateKey, err := kit.ParseSigningKey(privateKeyStr)
+	if err != nil {
+		return nil, 0, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	if currentKey != nil {
+		retired := []string{base64.StdEncoding.EncodeToString(currentKey.Public().(ed25519.PublicKey))}
+		for _, publicKey := range uc.retiredVerifyKeys(ctx) {
+			retired = append(retired, base64.StdEncoding.EncodeToString(publicKey))
+		}
+		if err := uc.ConfigUsecase.SetValue(ctx, otaSigningRetiredKeysParam, strings.Join(retired, ";"), "已轮换的OTA固件签名公钥（Base64），多个用;分隔，仍可用于校验"); err != nil {
+			return nil, 0, uc.handleError.ErrInternal(ctx, err)
+		}
+	}
+	if err := uc.ConfigUsecase.SetValue(ctx, otaSigningKeyParam, privateKeyStr, "OTA固件签名私钥（Ed25519，Base64），为空时不签名"); err != nil {
+		return nil, 0, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	key := newOtaSigningKey(privateKey.Public().(ed25519.PublicKey), false)
+	uc.log.Infof("OTA固件签名密钥已轮换，新公钥标识: %s", key.KeyID)
+	if !resign {
+		return key, 0, nil
+	}
+
+	otas, _, err := uc.repo.PageOta(ctx, &ListOtaParams{}, nil)
+	if err != nil {
+		return key, 0, uc.handleError.ErrInternal(ctx, err)
+	}
+	resigned := 0
+	for _, ota := range otas {
+		if ota.Sha256 == "" {
+			if err := uc.fillFirmwareDigest(ctx, ota); err != nil {
+				uc.log.Warnf("固件 %s 计算SHA-256失败，跳过重新签名: %v", ota.ID, err)
+				continue
+			}
+		} else {
+			signFirmware(privateKey, ota)
+		}
+		if err := uc.repo.UpdateSignature(ctx, ota); err != nil {
+			return key, resigned, uc.handleError.ErrInternal(ctx, err)
+		}
+		resigned++
+	}
+	return key, resigned, nil
+}
+
+// VerifyFirmware 校验固件文件与记录的SHA-256是否一致，并使用当前或已轮换的公钥校验签名
+func (uc *OtaUsecase) VerifyFirmware(ctx context.Context, id string) (*FirmwareVerifyResult, error) {
+	if id == "" {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("id不能为空"))
+	}
+	ota, err := uc.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if ota == nil {
+		return nil, uc.handleError.ErrNotFound(ctx, fmt.Errorf("OTA固件记录不存在"))
+	}
+	if ota.FirmwarePath == "" {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("固件未上传文件"))
+	}
+
+	fileData, _, err := uc.readFirmwareFile(ctx, ota.FirmwarePath, ota.Type, ota.Version)
+	if err != nil {
+		return nil, uc.handleError.ErrNotFound(ctx, err)
+	}
+	sum := sha256.Sum256(fileData)
+	result := &FirmwareVerifyResult{
+		OtaID:        ota.ID,
+		Sha256:       ota.Sha256,
+		ActualSha256: hex.EncodeToString(sum[:]),
+		Size:         ota.Size,
+		ActualSize:   int64(len(fileData)),
+		Signed:       ota.Signature != "",
+		KeyID:        ota.SignKeyID,
+	}
+	result.DigestMatch = strings.EqualFold(result.Sha256, result.ActualSha256) && result.Size == result.ActualSize
+	if !result.Signed {
+		return result, nil
+	}
+
+	keys, err := uc.ListSigningKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	manifest := kit.FirmwareManifest(ota.Type, ota.Version, result.ActualSize, result.ActualSha256)
+	for _, key := range keys {
+		if key.KeyID != ota.SignKeyID {
+			continue
+		}
+		publicKey, err := kit.ParseVerifyKey(key.PublicKey)
+		if err != nil {
+			continue
+		}
+		result.SignatureValid = kit.VerifyFirmwareManifest(publicKey, manifest, ota.Signature)
+		result.KeyRetired = key.Retired
+		break
+	}
+	return result, nil
+}
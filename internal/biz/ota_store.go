@@ -0,0 +1,50 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+)
+
+// FirmwareStore 固件文件存储（本地目录或S3兼容对象存储）
+type FirmwareStore interface {
+	// Put 保存固件文件，返回记录到FirmwarePath的路径，同名文件已存在时直接返回路径
+	Put(ctx context.Context, name string, data []byte) (string, error)
+
+	// Get 读取FirmwarePath对应的固件文件
+	Get(ctx context.Context, path string) ([]byte, error)
+
+	// Open 打开固件文件用于流式下载，ctx需覆盖整个读取过程
+	Open(ctx context.Context, path string) (FirmwareObject, error)
+
+	// PresignURL 生成设备可直接下载的预签名URL，未开启或存储不支持时返回空字符串
+	PresignURL(ctx context.Context, path, filename string) (string, error)
+}
+
+// FirmwareObject 可随机读取的固件文件，用于支持Range断点续传
+type FirmwareObject interface {
+	io.ReadSeekCloser
+	Size() int64
+	ModTime() time.Time
+}
+
+// readFirmwareFile 从固件存储读取固件文件，返回文件数据和下载文件名
+func (uc *OtaUsecase) readFirmwareFile(ctx context.Context, firmwarePath, otaType, otaVersion string) ([]byte, string, error) {
+	fileData, err := uc.store.Get(ctx, firmwarePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("固件文件不存在: %v", err)
+	}
+	return fileData, firmwareFilename(firmwarePath, otaType, otaVersion), nil
+}
+
+// firmwareFilename 生成下载文件名：类型_版本+原扩展名
+func firmwareFilename(firmwarePath, otaType, otaVersion string) string {
+	filename := otaType + "_" + otaVersion
+	if ext := filepath.Ext(firmwarePath); ext != "" {
+		filename += ext
+	}
+	// 清理文件名，移除不安全字符
+	return sanitizeFilename(filename)
+}
@@ -1,96 +1,565 @@
 package biz
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/constant"
 
 	"github.com/go-kratos/kratos/v2/log"
 )
 
+// errRAGConfigNotFound RAG模型配置已被删除
+var errRAGConfigNotFound = errors.New("RAG模型配置不存在")
+
 // RAGAdapterFactoryImpl RAG适配器工厂实现
 type RAGAdapterFactoryImpl struct {
-	log *log.Helper
+	localRAGRepo    LocalRAGRepo
+	modelConfigRepo ModelConfigRepo
+	log             *log.Helper
 }
 
 // NewRAGAdapterFactory 创建RAG适配器工厂
-func NewRAGAdapterFactory(logger log.Logger) RAGAdapterFactory {
+func NewRAGAdapterFactory(localRAGRepo LocalRAGRepo, modelConfigRepo ModelConfigRepo, logger log.Logger) RAGAdapterFactory {
 	return &RAGAdapterFactoryImpl{
-		log: log.NewHelper(log.With(logger, "module", "biz/rag_adapter")),
+		localRAGRepo:    localRAGRepo,
+		modelConfigRepo: modelConfigRepo,
+		log:             log.NewHelper(log.With(logger, "module", "biz/rag_adapter")),
 	}
 }
 
 // GetAdapter 获取RAG适配器
 func (f *RAGAdapterFactoryImpl) GetAdapter(adapterType string, config map[string]interface{}) (RAGAdapter, error) {
 	switch adapterType {
-	case "ragflow":
+	case constant.RAGAdapterRAGFlow:
 		adapter := &RAGFlowAdapter{
 			config: config,
-			log:    f.log,
+			httpClient: &http.Client{
+				Timeout: 60 * time.Second,
+			},
+			log: f.log,
 		}
 		// 初始化适配器
 		if err := adapter.validateConfig(config); err != nil {
 			return nil, fmt.Errorf("RAG配置验证失败: %w", err)
 		}
 		return adapter, nil
+	case constant.RAGAdapterLocal:
+		adapter := &LocalRAGAdapter{
+			config:          config,
+			repo:            f.localRAGRepo,
+			modelConfigRepo: f.modelConfigRepo,
+			httpClient: &http.Client{
+				Timeout: 60 * time.Second,
+			},
+			log: f.log,
+		}
+		if err := adapter.validateConfig(config); err != nil {
+			return nil, fmt.Errorf("RAG配置验证失败: %w", err)
+		}
+		return adapter, nil
 	default:
 		return nil, fmt.Errorf("不支持的适配器类型: %s", adapterType)
 	}
 }
 
+// loadRAGConfig 根据RAG模型配置ID加载适配器配置
+// 使用未掩码的原始配置，否则api_key等敏感字段会被替换为掩码值
+func loadRAGConfig(ctx context.Context, modelConfigRepo ModelConfigRepo, ragModelId string) (map[string]interface{}, error) {
+	modelConfig, err := modelConfigRepo.GetModelConfigByIDRaw(ctx, ragModelId)
+	if err != nil {
+		return nil, fmt.Errorf("获取RAG模型配置失败: %w", err)
+	}
+	if modelConfig == nil {
+		return nil, fmt.Errorf("%w: %s", errRAGConfigNotFound, ragModelId)
+	}
+
+	ragConfig := make(map[string]interface{})
+	if modelConfig.ConfigJSON != "" {
+		if err := json.Unmarshal([]byte(modelConfig.ConfigJSON), &ragConfig); err != nil {
+			return nil, fmt.Errorf("解析RAG配置JSON失败: %w", err)
+		}
+	}
+
+	// 从配置中提取适配器类型，如果没有则默认使用ragflow
+	ragConfig["type"] = ragAdapterType(ragConfig)
+
+	return ragConfig, nil
+}
+
+// ragAdapterType 从RAG配置中解析适配器类型，兼容旧的adapter_type字段
+func ragAdapterType(ragConfig map[string]interface{}) string {
+	if adapterType, ok := ragConfig["type"].(string); ok && adapterType != "" {
+		return adapterType
+	}
+	if adapterType, ok := ragConfig["adapter_type"].(string); ok && adapterType != "" {
+		return adapterType
+	}
+	return constant.RAGAdapterRAGFlow
+}
+
 // RAGFlowAdapter RAGFlow适配器实现
 type RAGFlowAdapter struct {
-	config map[string]interface{}
-	log    *log.Helper
+	config     map[string]interface{}
+	httpClient *http.Client
+	log        *log.Helper
+}
+
+// ragflowResponse RAGFlow接口统一响应结构
+type ragflowResponse struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// ragflowDocument RAGFlow文档结构
+type ragflowDocument struct {
+	ID           string                 `json:"id"`
+	Name         string                 `json:"name"`
+	DatasetID    string                 `json:"dataset_id"`
+	Location     string                 `json:"location"`
+	Size         int64                  `json:"size"`
+	Type         string                 `json:"type"`
+	Suffix       string                 `json:"suffix"`
+	ChunkMethod  string                 `json:"chunk_method"`
+	ParserConfig map[string]interface{} `json:"parser_config"`
+	MetaFields   map[string]interface{} `json:"meta_fields"`
+	Run          string                 `json:"run"`
+	Progress     float64                `json:"progress"`
+	ProgressMsg  string                 `json:"progress_msg"`
+	ChunkCount   int64                  `json:"chunk_count"`
+	TokenCount   int64                  `json:"token_count"`
+	CreateTime   int64                  `json:"create_time"`
+	UpdateTime   int64                  `json:"update_time"`
+}
+
+// ragflowDocumentPage RAGFlow文档分页结构
+type ragflowDocumentPage struct {
+	Docs  []*ragflowDocument `json:"docs"`
+	Total int                `json:"total"`
+}
+
+// ragflowDataset RAGFlow数据集结构
+type ragflowDataset struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ragflowRetrievalKeys 召回参数名到RAGFlow接口参数名的映射
+var ragflowRetrievalKeys = map[string]string{
+	"question":               "question",
+	"datasetIds":             "dataset_ids",
+	"documentIds":            "document_ids",
+	"page":                   "page",
+	"pageSize":               "page_size",
+	"similarityThreshold":    "similarity_threshold",
+	"vectorSimilarityWeight": "vector_similarity_weight",
+	"topK":                   "top_k",
+	"rerankId":               "rerank_id",
+	"keyword":                "keyword",
+	"highlight":              "highlight",
+	"crossLanguages":         "cross_languages",
+	"metadataCondition":      "metadata_condition",
 }
 
 // GetDocumentList 分页查询文档列表
 func (a *RAGFlowAdapter) GetDocumentList(ctx context.Context, datasetId string, queryParams map[string]interface{}, page, limit int) ([]*Document, int, error) {
-	// TODO: 实现RAGFlow API调用
-	// 这里先返回空结果，后续需要实现HTTP客户端调用RAGFlow API
-	a.log.Warnf("GetDocumentList not implemented yet for datasetId: %s", datasetId)
-	return []*Document{}, 0, fmt.Errorf("RAGFlow适配器未完全实现")
+	query := url.Values{}
+	if page > 0 {
+		query.Set("page", strconv.Itoa(page))
+	}
+	if limit > 0 {
+		query.Set("page_size", strconv.Itoa(limit))
+	}
+	query.Set("orderby", "create_time")
+	query.Set("desc", "true")
+	if keywords, ok := queryParams["keywords"].(string); ok && keywords != "" {
+		query.Set("keywords", keywords)
+	}
+	if status, ok := queryParams["status"].(int32); ok {
+		if run := documentStatusToRun(status); run != "" {
+			query.Set("run", run)
+		}
+	}
+
+	var result ragflowDocumentPage
+	if err := a.doJSON(ctx, http.MethodGet, fmt.Sprintf("/datasets/%s/documents", url.PathEscape(datasetId)), query, nil, &result); err != nil {
+		return nil, 0, err
+	}
+
+	documents := make([]*Document, 0, len(result.Docs))
+	for _, doc := range result.Docs {
+		documents = append(documents, a.toDocument(datasetId, doc))
+	}
+
+	return documents, result.Total, nil
 }
 
 // GetDocumentById 根据文档ID获取文档详情
 func (a *RAGFlowAdapter) GetDocumentById(ctx context.Context, datasetId, documentId string) (*Document, error) {
-	// TODO: 实现RAGFlow API调用
-	a.log.Warnf("GetDocumentById not implemented yet for datasetId: %s, documentId: %s", datasetId, documentId)
-	return nil, fmt.Errorf("RAGFlow适配器未完全实现")
+	query := url.Values{}
+	query.Set("id", documentId)
+
+	var result ragflowDocumentPage
+	if err := a.doJSON(ctx, http.MethodGet, fmt.Sprintf("/datasets/%s/documents", url.PathEscape(datasetId)), query, nil, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Docs) == 0 {
+		return nil, fmt.Errorf("文档不存在: %s", documentId)
+	}
+
+	return a.toDocument(datasetId, result.Docs[0]), nil
 }
 
 // UploadDocument 上传文档到知识库
 func (a *RAGFlowAdapter) UploadDocument(ctx context.Context, datasetId string, file []byte, fileName string, params map[string]interface{}) (*Document, error) {
-	// TODO: 实现RAGFlow API调用，使用multipart/form-data上传文件
-	a.log.Warnf("UploadDocument not implemented yet for datasetId: %s, fileName: %s", datasetId, fileName)
-	return nil, fmt.Errorf("RAGFlow适配器未完全实现")
+	// 创建multipart form
+	var requestBody bytes.Buffer
+	writer := multipart.NewWriter(&requestBody)
+
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		return nil, fmt.Errorf("创建文件字段失败: %w", err)
+	}
+	if _, err := part.Write(file); err != nil {
+		return nil, fmt.Errorf("写入文件数据失败: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("关闭writer失败: %w", err)
+	}
+
+	req, err := a.newRequest(ctx, http.MethodPost, fmt.Sprintf("/datasets/%s/documents", url.PathEscape(datasetId)), nil, &requestBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	var docs []*ragflowDocument
+	if err := a.send(req, &docs); err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("RAGFlow未返回上传的文档信息")
+	}
+	doc := docs[0]
+
+	// 上传接口不支持设置文档属性，需要再调用一次更新接口
+	update := make(map[string]interface{})
+	for _, key := range []string{"name", "chunk_method", "meta_fields", "parser_config"} {
+		if value, ok := params[key]; ok {
+			update[key] = value
+		}
+	}
+	if len(update) > 0 {
+		path := fmt.Sprintf("/datasets/%s/documents/%s", url.PathEscape(datasetId), url.PathEscape(doc.ID))
+		if err := a.doJSON(ctx, http.MethodPut, path, nil, update, nil); err != nil {
+			return nil, fmt.Errorf("更新文档属性失败: %w", err)
+		}
+		if name, ok := update["name"].(string); ok {
+			doc.Name = name
+		}
+		if chunkMethod, ok := update["chunk_method"].(string); ok {
+			doc.ChunkMethod = chunkMethod
+		}
+		if metaFields, ok := update["meta_fields"].(map[string]interface{}); ok {
+			doc.MetaFields = metaFields
+		}
+		if parserConfig, ok := update["parser_config"].(map[string]interface{}); ok {
+			doc.ParserConfig = parserConfig
+		}
+	}
+
+	return a.toDocument(datasetId, doc), nil
 }
 
 // DeleteDocument 删除文档
 func (a *RAGFlowAdapter) DeleteDocument(ctx context.Context, datasetId, documentId string) error {
-	// TODO: 实现RAGFlow API调用
-	a.log.Warnf("DeleteDocument not implemented yet for datasetId: %s, documentId: %s", datasetId, documentId)
-	return fmt.Errorf("RAGFlow适配器未完全实现")
+	body := map[string]interface{}{
+		"ids": []string{documentId},
+	}
+	return a.doJSON(ctx, http.MethodDelete, fmt.Sprintf("/datasets/%s/documents", url.PathEscape(datasetId)), nil, body, nil)
 }
 
 // ParseDocuments 解析文档（切块）
 func (a *RAGFlowAdapter) ParseDocuments(ctx context.Context, datasetId string, documentIds []string) (bool, error) {
-	// TODO: 实现RAGFlow API调用
-	a.log.Warnf("ParseDocuments not implemented yet for datasetId: %s", datasetId)
-	return false, fmt.Errorf("RAGFlow适配器未完全实现")
+	body := map[string]interface{}{
+		"document_ids": documentIds,
+	}
+	if err := a.doJSON(ctx, http.MethodPost, fmt.Sprintf("/datasets/%s/chunks", url.PathEscape(datasetId)), nil, body, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// StopParseDocuments 停止解析文档
+func (a *RAGFlowAdapter) StopParseDocuments(ctx context.Context, datasetId string, documentIds []string) error {
+	body := map[string]interface{}{
+		"document_ids": documentIds,
+	}
+	return a.doJSON(ctx, http.MethodDelete, fmt.Sprintf("/datasets/%s/chunks", url.PathEscape(datasetId)), nil, body, nil)
 }
 
 // ListChunks 列出指定文档的切片
 func (a *RAGFlowAdapter) ListChunks(ctx context.Context, datasetId, documentId string, params map[string]interface{}) (map[string]interface{}, error) {
-	// TODO: 实现RAGFlow API调用
-	a.log.Warnf("ListChunks not implemented yet for datasetId: %s, documentId: %s", datasetId, documentId)
-	return nil, fmt.Errorf("RAGFlow适配器未完全实现")
+	query := url.Values{}
+	for _, key := range []string{"keywords", "page", "page_size", "id"} {
+		if value, ok := params[key]; ok {
+			query.Set(key, fmt.Sprintf("%v", value))
+		}
+	}
+
+	path := fmt.Sprintf("/datasets/%s/documents/%s/chunks", url.PathEscape(datasetId), url.PathEscape(documentId))
+	result := make(map[string]interface{})
+	if err := a.doJSON(ctx, http.MethodGet, path, query, nil, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
 }
 
 // RetrievalTest 召回测试
 func (a *RAGFlowAdapter) RetrievalTest(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
-	// TODO: 实现RAGFlow API调用
-	a.log.Warnf("RetrievalTest not implemented yet")
-	return nil, fmt.Errorf("RAGFlow适配器未完全实现")
+	body := make(map[string]interface{}, len(params))
+	for key, value := range params {
+		if ragflowKey, ok := ragflowRetrievalKeys[key]; ok {
+			body[ragflowKey] = value
+		}
+	}
+
+	result := make(map[string]interface{})
+	if err := a.doJSON(ctx, http.MethodPost, "/retrieval", nil, body, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// CreateDataset 在RAGFlow中创建数据集
+func (a *RAGFlowAdapter) CreateDataset(ctx context.Context, name, description string) (string, error) {
+	body := map[string]interface{}{
+		"name": name,
+	}
+	if description != "" {
+		body["description"] = description
+	}
+	// 可选的数据集默认参数，由RAG模型配置提供
+	for _, key := range []string{"embedding_model", "chunk_method", "permission"} {
+		if value, ok := a.config[key].(string); ok && value != "" {
+			body[key] = value
+		}
+	}
+
+	var dataset ragflowDataset
+	if err := a.doJSON(ctx, http.MethodPost, "/datasets", nil, body, &dataset); err != nil {
+		return "", err
+	}
+	if dataset.ID == "" {
+		return "", fmt.Errorf("RAGFlow未返回数据集ID")
+	}
+
+	return dataset.ID, nil
+}
+
+// UpdateDataset 更新RAGFlow中的数据集
+func (a *RAGFlowAdapter) UpdateDataset(ctx context.Context, datasetId, name, description string) error {
+	body := map[string]interface{}{
+		"name":        name,
+		"description": description,
+	}
+	return a.doJSON(ctx, http.MethodPut, fmt.Sprintf("/datasets/%s", url.PathEscape(datasetId)), nil, body, nil)
+}
+
+// DeleteDataset 删除RAGFlow中的数据集
+func (a *RAGFlowAdapter) DeleteDataset(ctx context.Context, datasetId string) error {
+	body := map[string]interface{}{
+		"ids": []string{datasetId},
+	}
+	return a.doJSON(ctx, http.MethodDelete, "/datasets", nil, body, nil)
+}
+
+// GetDocumentCount 获取数据集中的文档数量
+func (a *RAGFlowAdapter) GetDocumentCount(ctx context.Context, datasetId string) (int, error) {
+	_, total, err := a.GetDocumentList(ctx, datasetId, nil, 1, 1)
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// toDocument 将RAGFlow文档转换为文档实体
+func (a *RAGFlowAdapter) toDocument(datasetId string, doc *ragflowDocument) *Document {
+	run := normalizeRAGFlowRun(doc.Run)
+	fileType := doc.Suffix
+	if fileType == "" {
+		fileType = doc.Type
+	}
+	if doc.DatasetID != "" {
+		datasetId = doc.DatasetID
+	}
+
+	document := &Document{
+		ID:           doc.ID,
+		DocumentID:   doc.ID,
+		DatasetID:    datasetId,
+		Name:         doc.Name,
+		FileType:     fileType,
+		FileSize:     doc.Size,
+		FilePath:     doc.Location,
+		MetaFields:   doc.MetaFields,
+		ChunkMethod:  doc.ChunkMethod,
+		ParserConfig: doc.ParserConfig,
+		Status:       documentRunToStatus(run),
+		Run:          run,
+		Progress:     doc.Progress,
+		ProgressMsg:  doc.ProgressMsg,
+		ChunkCount:   doc.ChunkCount,
+		TokenCount:   doc.TokenCount,
+	}
+	if doc.CreateTime > 0 {
+		document.CreatedAt = time.UnixMilli(doc.CreateTime)
+	}
+	if doc.UpdateTime > 0 {
+		document.UpdatedAt = time.UnixMilli(doc.UpdateTime)
+	}
+
+	return document
+}
+
+// normalizeRAGFlowRun 统一RAGFlow的运行状态，旧版本接口返回数字字符串
+func normalizeRAGFlowRun(run string) string {
+	switch run {
+	case "0":
+		return constant.DocumentRunUnstart
+	case "1":
+		return constant.DocumentRunRunning
+	case "2":
+		return constant.DocumentRunCancel
+	case "3":
+		return constant.DocumentRunDone
+	case "4":
+		return constant.DocumentRunFail
+	default:
+		return strings.ToUpper(run)
+	}
+}
+
+// documentRunToStatus 将RAG运行状态映射为文档状态
+func documentRunToStatus(run string) int32 {
+	switch run {
+	case constant.DocumentRunRunning:
+		return constant.DocumentStatusRunning
+	case constant.DocumentRunCancel:
+		return constant.DocumentStatusCancelled
+	case constant.DocumentRunDone:
+		return constant.DocumentStatusDone
+	case constant.DocumentRunFail:
+		return constant.DocumentStatusFailed
+	default:
+		return constant.DocumentStatusUnstart
+	}
+}
+
+// documentStatusToRun 将文档状态映射为RAG运行状态
+func documentStatusToRun(status int32) string {
+	switch status {
+	case constant.DocumentStatusUnstart:
+		return constant.DocumentRunUnstart
+	case constant.DocumentStatusRunning:
+		return constant.DocumentRunRunning
+	case constant.DocumentStatusCancelled:
+		return constant.DocumentRunCancel
+	case constant.DocumentStatusDone:
+		return constant.DocumentRunDone
+	case constant.DocumentStatusFailed:
+		return constant.DocumentRunFail
+	default:
+		return ""
+	}
+}
+
+// doJSON 发送JSON请求并解析响应中的data字段
+func (a *RAGFlowAdapter) doJSON(ctx context.Context, method, path string, query url.Values, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("序列化请求参数失败: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := a.newRequest(ctx, method, path, query, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return a.send(req, out)
+}
+
+// newRequest 创建带认证信息的RAGFlow请求
+func (a *RAGFlowAdapter) newRequest(ctx context.Context, method, path string, query url.Values, body io.Reader) (*http.Request, error) {
+	requestUrl := a.getAPIURL() + path
+	if len(query) > 0 {
+		requestUrl += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, requestUrl, body)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+a.getAPIKey())
+
+	return req, nil
+}
+
+// send 发送请求，校验RAGFlow的业务状态码并解析data字段
+func (a *RAGFlowAdapter) send(req *http.Request, out interface{}) error {
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("RAGFlow请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		a.log.Errorf("RAGFlow请求失败,请求路径：%s, 状态码：%d, 响应：%s", req.URL.Path, resp.StatusCode, string(body))
+		return fmt.Errorf("RAGFlow请求失败，状态码：%d", resp.StatusCode)
+	}
+
+	var result ragflowResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("解析RAGFlow响应失败: %w", err)
+	}
+	if result.Code != 0 {
+		a.log.Errorf("RAGFlow返回错误,请求路径：%s, code：%d, message：%s", req.URL.Path, result.Code, result.Message)
+		return fmt.Errorf("RAGFlow返回错误: %s", result.Message)
+	}
+
+	if out == nil || len(result.Data) == 0 || string(result.Data) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(result.Data, out); err != nil {
+		return fmt.Errorf("解析RAGFlow响应数据失败: %w", err)
+	}
+
+	return nil
 }
 
 // validateConfig 验证配置
@@ -104,7 +573,7 @@ func (a *RAGFlowAdapter) validateConfig(config map[string]interface{}) error {
 		return fmt.Errorf("RAG配置缺少base_url")
 	}
 
-	if baseUrl[:7] != "http://" && baseUrl[:8] != "https://" {
+	if !strings.HasPrefix(baseUrl, "http://") && !strings.HasPrefix(baseUrl, "https://") {
 		return fmt.Errorf("RAG配置base_url格式无效")
 	}
 
@@ -124,6 +593,15 @@ func (a *RAGFlowAdapter) getBaseURL() string {
 	return ""
 }
 
+// getAPIURL 获取RAGFlow接口前缀，兼容base_url已包含/api/v1的配置
+func (a *RAGFlowAdapter) getAPIURL() string {
+	baseURL := strings.TrimRight(a.getBaseURL(), "/")
+	if strings.HasSuffix(baseURL, "/api/v1") {
+		return baseURL
+	}
+	return baseURL + "/api/v1"
+}
+
 // getAPIKey 获取RAGFlow API Key
 func (a *RAGFlowAdapter) getAPIKey() string {
 	if apiKey, ok := a.config["api_key"].(string); ok {
@@ -0,0 +1,262 @@
+package biz
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/weetime/agent-matrix/internal/constant"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/stretchr/testify/suite"
+)
+
+const testRAGFlowAPIKey = "ragflow-test-key"
+
+// RAGFlowAdapterSuite 使用httptest模拟RAGFlow服务测试适配器
+type RAGFlowAdapterSuite struct {
+	suite.Suite
+	server  *httptest.Server
+	adapter RAGAdapter
+	// 最近一次请求的JSON请求体
+	lastBody map[string]interface{}
+}
+
+func TestRAGFlowAdapterSuite(t *testing.T) {
+	suite.Run(t, new(RAGFlowAdapterSuite))
+}
+
+func (s *RAGFlowAdapterSuite) SetupTest() {
+	s.lastBody = nil
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /api/v1/datasets/ds1/documents", func(w http.ResponseWriter, r *http.Request) {
+		docs := []map[string]interface{}{
+			{"id": "doc1", "name": "a.pdf", "dataset_id": "ds1", "size": 1024, "suffix": "pdf", "run": "DONE", "chunk_count": 12, "progress": 1, "create_time": 1700000000000},
+			{"id": "doc2", "name": "b.txt", "dataset_id": "ds1", "size": 10, "type": "doc", "run": "1", "progress": 0.5},
+		}
+		if id := r.URL.Query().Get("id"); id != "" {
+			docs = docs[:1]
+		}
+		if run := r.URL.Query().Get("run"); run == constant.DocumentRunFail {
+			docs = docs[:0]
+		}
+		s.writeData(w, map[string]interface{}{"docs": docs, "total": len(docs)})
+	})
+	mux.HandleFunc("POST /api/v1/datasets/ds1/documents", func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("file")
+		s.Require().NoError(err)
+		content, _ := io.ReadAll(file)
+		s.writeData(w, []map[string]interface{}{
+			{"id": "doc3", "name": header.Filename, "dataset_id": "ds1", "size": len(content), "run": "UNSTART"},
+		})
+	})
+	mux.HandleFunc("PUT /api/v1/datasets/ds1/documents/doc3", func(w http.ResponseWriter, r *http.Request) {
+		s.readBody(r)
+		s.writeData(w, nil)
+	})
+	mux.HandleFunc("DELETE /api/v1/datasets/ds1/documents", func(w http.ResponseWriter, r *http.Request) {
+		s.readBody(r)
+		s.writeData(w, nil)
+	})
+	mux.HandleFunc("POST /api/v1/datasets/ds1/chunks", func(w http.ResponseWriter, r *http.Request) {
+		s.readBody(r)
+		s.writeData(w, nil)
+	})
+	mux.HandleFunc("GET /api/v1/datasets/ds1/documents/doc1/chunks", func(w http.ResponseWriter, r *http.Request) {
+		s.Equal("hello", r.URL.Query().Get("keywords"))
+		s.writeData(w, map[string]interface{}{
+			"chunks": []map[string]interface{}{{"id": "c1", "content": "hello world"}},
+			"total":  1,
+		})
+	})
+	mux.HandleFunc("POST /api/v1/retrieval", func(w http.ResponseWriter, r *http.Request) {
+		s.readBody(r)
+		s.writeData(w, map[string]interface{}{
+			"chunks":   []map[string]interface{}{{"id": "c1", "content": "hello world", "similarity": 0.8}},
+			"doc_aggs": []map[string]interface{}{{"doc_id": "doc1", "doc_name": "a.pdf", "count": 1}},
+			"total":    1,
+		})
+	})
+	mux.HandleFunc("POST /api/v1/datasets", func(w http.ResponseWriter, r *http.Request) {
+		s.readBody(r)
+		if s.lastBody["name"] == "dup" {
+			s.writeJSON(w, map[string]interface{}{"code": 102, "message": "Dataset name 'dup' already exists"})
+			return
+		}
+		s.writeData(w, map[string]interface{}{"id": "ds-new", "name": s.lastBody["name"]})
+	})
+	mux.HandleFunc("DELETE /api/v1/datasets", func(w http.ResponseWriter, r *http.Request) {
+		s.readBody(r)
+		s.writeData(w, nil)
+	})
+
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+testRAGFlowAPIKey {
+			s.writeJSON(w, map[string]interface{}{"code": 109, "message": "Authentication error: API key is invalid!"})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+
+	factory := NewRAGAdapterFactory(nil, nil, log.NewStdLogger(os.Stdout))
+	adapter, err := factory.GetAdapter(constant.RAGAdapterRAGFlow, map[string]interface{}{
+		"type":            constant.RAGAdapterRAGFlow,
+		"base_url":        s.server.URL + "/",
+		"api_key":         testRAGFlowAPIKey,
+		"embedding_model": "BAAI/bge-large-zh-v1.5",
+	})
+	s.Require().NoError(err)
+	s.adapter = adapter
+}
+
+func (s *RAGFlowAdapterSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *RAGFlowAdapterSuite) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	s.Require().NoError(json.NewEncoder(w).Encode(v))
+}
+
+func (s *RAGFlowAdapterSuite) writeData(w http.ResponseWriter, data interface{}) {
+	s.writeJSON(w, map[string]interface{}{"code": 0, "data": data})
+}
+
+func (s *RAGFlowAdapterSuite) readBody(r *http.Request) {
+	s.lastBody = nil
+	s.Require().NoError(json.NewDecoder(r.Body).Decode(&s.lastBody))
+}
+
+func (s *RAGFlowAdapterSuite) TestValidateConfig() {
+	factory := NewRAGAdapterFactory(nil, nil, log.NewStdLogger(io.Discard))
+	tests := []struct {
+		name   string
+		config map[string]interface{}
+	}{
+		{name: "nil config", config: nil},
+		{name: "missing base_url", config: map[string]interface{}{"api_key": "k"}},
+		{name: "short base_url", config: map[string]interface{}{"base_url": "x", "api_key": "k"}},
+		{name: "missing api_key", config: map[string]interface{}{"base_url": "http://ragflow"}},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			_, err := factory.GetAdapter(constant.RAGAdapterRAGFlow, tt.config)
+			s.Error(err)
+		})
+	}
+
+	_, err := factory.GetAdapter("unknown", map[string]interface{}{})
+	s.Error(err)
+}
+
+func (s *RAGFlowAdapterSuite) TestGetDocumentList() {
+	docs, total, err := s.adapter.GetDocumentList(context.Background(), "ds1", map[string]interface{}{"keywords": "a"}, 1, 10)
+	s.Require().NoError(err)
+	s.Equal(2, total)
+	s.Require().Len(docs, 2)
+
+	s.Equal("doc1", docs[0].DocumentID)
+	s.Equal("pdf", docs[0].FileType)
+	s.Equal(int64(1024), docs[0].FileSize)
+	s.Equal(constant.DocumentRunDone, docs[0].Run)
+	s.Equal(constant.DocumentStatusDone, docs[0].Status)
+	s.Equal(int64(12), docs[0].ChunkCount)
+	s.Equal(int64(1700000000000), docs[0].CreatedAt.UnixMilli())
+
+	// 旧版本数字状态应被统一为字符串状态
+	s.Equal("doc", docs[1].FileType)
+	s.Equal(constant.DocumentRunRunning, docs[1].Run)
+	s.Equal(constant.DocumentStatusRunning, docs[1].Status)
+	s.InDelta(0.5, docs[1].Progress, 0.0001)
+
+	docs, total, err = s.adapter.GetDocumentList(context.Background(), "ds1", map[string]interface{}{"status": constant.DocumentStatusFailed}, 1, 10)
+	s.Require().NoError(err)
+	s.Equal(0, total)
+	s.Empty(docs)
+}
+
+func (s *RAGFlowAdapterSuite) TestGetDocumentById() {
+	doc, err := s.adapter.GetDocumentById(context.Background(), "ds1", "doc1")
+	s.Require().NoError(err)
+	s.Equal("a.pdf", doc.Name)
+}
+
+func (s *RAGFlowAdapterSuite) TestUploadDocument() {
+	doc, err := s.adapter.UploadDocument(context.Background(), "ds1", []byte("hello"), "c.txt", map[string]interface{}{
+		"name":         "renamed.txt",
+		"chunk_method": "naive",
+	})
+	s.Require().NoError(err)
+	s.Equal("doc3", doc.DocumentID)
+	s.Equal("renamed.txt", doc.Name)
+	s.Equal("naive", doc.ChunkMethod)
+	s.Equal(int64(5), doc.FileSize)
+	s.Equal(constant.DocumentStatusUnstart, doc.Status)
+	s.Equal("renamed.txt", s.lastBody["name"])
+}
+
+func (s *RAGFlowAdapterSuite) TestDeleteAndParseDocuments() {
+	s.Require().NoError(s.adapter.DeleteDocument(context.Background(), "ds1", "doc1"))
+	s.Equal([]interface{}{"doc1"}, s.lastBody["ids"])
+
+	ok, err := s.adapter.ParseDocuments(context.Background(), "ds1", []string{"doc1", "doc2"})
+	s.Require().NoError(err)
+	s.True(ok)
+	s.Equal([]interface{}{"doc1", "doc2"}, s.lastBody["document_ids"])
+}
+
+func (s *RAGFlowAdapterSuite) TestListChunks() {
+	result, err := s.adapter.ListChunks(context.Background(), "ds1", "doc1", map[string]interface{}{"keywords": "hello", "page": 1})
+	s.Require().NoError(err)
+	s.Equal(float64(1), result["total"])
+	s.Len(result["chunks"], 1)
+}
+
+func (s *RAGFlowAdapterSuite) TestRetrievalTest() {
+	result, err := s.adapter.RetrievalTest(context.Background(), map[string]interface{}{
+		"question":            "hello",
+		"datasetIds":          []string{"ds1"},
+		"similarityThreshold": float32(0.2),
+		"topK":                int32(5),
+	})
+	s.Require().NoError(err)
+	s.Len(result["chunks"], 1)
+	s.Equal("hello", s.lastBody["question"])
+	s.Equal([]interface{}{"ds1"}, s.lastBody["dataset_ids"])
+	s.Equal(float64(5), s.lastBody["top_k"])
+	s.Contains(s.lastBody, "similarity_threshold")
+}
+
+func (s *RAGFlowAdapterSuite) TestDatasetLifecycle() {
+	id, err := s.adapter.CreateDataset(context.Background(), "kb", "desc")
+	s.Require().NoError(err)
+	s.Equal("ds-new", id)
+	s.Equal("BAAI/bge-large-zh-v1.5", s.lastBody["embedding_model"])
+
+	_, err = s.adapter.CreateDataset(context.Background(), "dup", "")
+	s.ErrorContains(err, "already exists")
+
+	s.Require().NoError(s.adapter.DeleteDataset(context.Background(), "ds-new"))
+	s.Equal([]interface{}{"ds-new"}, s.lastBody["ids"])
+
+	count, err := s.adapter.GetDocumentCount(context.Background(), "ds1")
+	s.Require().NoError(err)
+	s.Equal(2, count)
+}
+
+func (s *RAGFlowAdapterSuite) TestInvalidAPIKey() {
+	factory := NewRAGAdapterFactory(nil, nil, log.NewStdLogger(io.Discard))
+	adapter, err := factory.GetAdapter(constant.RAGAdapterRAGFlow, map[string]interface{}{
+		"base_url": s.server.URL + "/api/v1",
+		"api_key":  "wrong",
+	})
+	s.Require().NoError(err)
+
+	_, _, err = adapter.GetDocumentList(context.Background(), "ds1", nil, 1, 10)
+	s.ErrorContains(err, "API key is invalid")
+}
@@ -0,0 +1,151 @@
+package biz
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// embeddingBatchSize 单次请求嵌入接口的最大文本数
+const embeddingBatchSize = 16
+
+// EmbeddingClient OpenAI兼容的文本嵌入客户端
+type EmbeddingClient struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+// embeddingRequest 嵌入接口请求体
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// embeddingResponse 嵌入接口响应体
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewEmbeddingClient 根据模型配置JSON创建嵌入客户端
+// 兼容LLM配置的base_url/url、api_key、model_name/model字段，modelOverride不为空时优先使用
+func NewEmbeddingClient(configJSON string, modelOverride string, httpClient *http.Client) (*EmbeddingClient, error) {
+	config := make(map[string]interface{})
+	if configJSON != "" {
+		if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
+			return nil, fmt.Errorf("解析嵌入模型配置失败: %w", err)
+		}
+	}
+
+	client := &EmbeddingClient{
+		baseURL:    firstConfigString(config, "base_url", "url"),
+		apiKey:     firstConfigString(config, "api_key"),
+		model:      modelOverride,
+		httpClient: httpClient,
+	}
+	if client.model == "" {
+		client.model = firstConfigString(config, "model_name", "model")
+	}
+	if client.baseURL == "" {
+		return nil, fmt.Errorf("嵌入模型配置缺少base_url")
+	}
+	if client.model == "" {
+		return nil, fmt.Errorf("嵌入模型配置缺少model_name")
+	}
+
+	return client, nil
+}
+
+// Embed 批量计算文本向量，返回顺序与输入一致
+func (c *EmbeddingClient) Embed(ctx context.Context, inputs []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(inputs))
+	for start := 0; start < len(inputs); start += embeddingBatchSize {
+		end := min(start+embeddingBatchSize, len(inputs))
+		batch, err := c.embedBatch(ctx, inputs[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// embedBatch 调用一次嵌入接口
+func (c *EmbeddingClient) embedBatch(ctx context.Context, inputs []string) ([][]float32, error) {
+	body, err := json.Marshal(embeddingRequest{Model: c.model, Input: inputs})
+	if err != nil {
+		return nil, fmt.Errorf("序列化嵌入请求失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("创建嵌入请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求嵌入模型失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取嵌入响应失败: %w", err)
+	}
+
+	var result embeddingResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("嵌入模型返回状态码 %d: %s", resp.StatusCode, string(respBody))
+	}
+	if result.Error != nil && result.Error.Message != "" {
+		return nil, fmt.Errorf("嵌入模型返回错误: %s", result.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("嵌入模型返回状态码 %d", resp.StatusCode)
+	}
+	if len(result.Data) != len(inputs) {
+		return nil, fmt.Errorf("嵌入模型返回的向量数量不匹配: 期望 %d, 实际 %d", len(inputs), len(result.Data))
+	}
+
+	sort.Slice(result.Data, func(i, j int) bool { return result.Data[i].Index < result.Data[j].Index })
+	vectors := make([][]float32, len(result.Data))
+	for i, item := range result.Data {
+		vectors[i] = item.Embedding
+	}
+	return vectors, nil
+}
+
+// endpoint 获取嵌入接口地址，base_url可以直接配置为完整的/embeddings地址
+func (c *EmbeddingClient) endpoint() string {
+	baseURL := strings.TrimRight(c.baseURL, "/")
+	if strings.HasSuffix(baseURL, "/embeddings") {
+		return baseURL
+	}
+	return baseURL + "/embeddings"
+}
+
+// firstConfigString 返回配置中第一个非空的字符串字段
+func firstConfigString(config map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := config[key].(string); ok && strings.TrimSpace(value) != "" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
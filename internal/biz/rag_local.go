@@ -0,0 +1,631 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/constant"
+	"github.com/weetime/agent-matrix/internal/kit"
+	"github.com/weetime/agent-matrix/internal/middleware"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/google/uuid"
+)
+
+// 本地知识库默认参数，与RAGFlow默认值保持一致
+const (
+	localRAGDefaultChunkSize         = 512
+	localRAGDefaultChunkOverlap      = 64
+	localRAGDefaultSimilarity        = 0.2
+	localRAGDefaultVectorWeight      = 0.3
+	localRAGDefaultTopK              = 1024
+	localRAGDefaultPageSize          = 30
+	localRAGDefaultChunkListPageSize = 1024
+)
+
+// errLocalParseCancelled 文档解析在后台执行期间被取消
+var errLocalParseCancelled = errors.New("文档解析已取消")
+
+// RAGChunk 本地知识库文档切片
+type RAGChunk struct {
+	ID         string
+	DatasetID  string
+	DocumentID string
+	Position   int
+	Content    string
+	Embedding  []float32
+	TokenCount int
+	CreatedAt  time.Time
+}
+
+// LocalRAGRepo 本地知识库存储接口，文档与向量都保存在业务数据库中
+type LocalRAGRepo interface {
+	// CreateDocument 保存文档及原始文件内容
+	CreateDocument(ctx context.Context, doc *Document, content []byte) error
+	// GetDocument 获取文档（不含文件内容），不存在时返回nil
+	GetDocument(ctx context.Context, datasetId, documentId string) (*Document, error)
+	// GetDocumentContent 获取文档原始文件内容
+	GetDocumentContent(ctx context.Context, documentId string) ([]byte, error)
+	// PageDocuments 分页查询文档，按创建时间倒序
+	PageDocuments(ctx context.Context, datasetId, keywords string, status *int32, page, limit int) ([]*Document, int, error)
+	// UpdateDocument 更新文档的名称、解析配置与解析状态
+	UpdateDocument(ctx context.Context, doc *Document) error
+	// DeleteDocument 删除文档及其切片
+	DeleteDocument(ctx context.Context, datasetId, documentId string) error
+	// DeleteDataset 删除知识库下的所有文档和切片
+	DeleteDataset(ctx context.Context, datasetId string) error
+	// ReplaceChunks 替换文档的全部切片
+	ReplaceChunks(ctx context.Context, documentId string, chunks []*RAGChunk) error
+	// PageChunks 分页查询文档切片（不含向量），按切片序号排序
+	PageChunks(ctx context.Context, documentId, keywords, chunkId string, page, pageSize int) ([]*RAGChunk, int, error)
+	// ListChunks 查询知识库中参与召回的切片（含向量），documentIds为空时不按文档过滤
+	ListChunks(ctx context.Context, datasetIds, documentIds []string) ([]*RAGChunk, error)
+	// GetDocumentNames 批量获取文档名称
+	GetDocumentNames(ctx context.Context, documentIds []string) (map[string]string, error)
+}
+
+// LocalRAGAdapter 内置本地知识库适配器
+// 文件与切片存储在ent数据库中，召回时在内存中计算向量余弦相似度与关键词匹配的混合得分，
+// 适用于没有部署RAGFlow的中小规模知识库
+type LocalRAGAdapter struct {
+	config          map[string]interface{}
+	repo            LocalRAGRepo
+	modelConfigRepo ModelConfigRepo
+	httpClient      *http.Client
+	log             *log.Helper
+}
+
+// GetDocumentList 分页查询文档列表
+func (a *LocalRAGAdapter) GetDocumentList(ctx context.Context, datasetId string, queryParams map[string]interface{}, page, limit int) ([]*Document, int, error) {
+	keywords, _ := queryParams["keywords"].(string)
+	var status *int32
+	if value, ok := queryParams["status"].(int32); ok {
+		status = &value
+	}
+
+	documents, total, err := a.repo.PageDocuments(ctx, datasetId, keywords, status, page, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询文档列表失败: %w", err)
+	}
+	return documents, total, nil
+}
+
+// GetDocumentById 根据文档ID获取文档详情
+func (a *LocalRAGAdapter) GetDocumentById(ctx context.Context, datasetId, documentId string) (*Document, error) {
+	doc, err := a.repo.GetDocument(ctx, datasetId, documentId)
+	if err != nil {
+		return nil, fmt.Errorf("查询文档失败: %w", err)
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("文档不存在: %s", documentId)
+	}
+	return doc, nil
+}
+
+// UploadDocument 保存上传的文件，解析需要单独调用ParseDocuments
+func (a *LocalRAGAdapter) UploadDocument(ctx context.Context, datasetId string, file []byte, fileName string, params map[string]interface{}) (*Document, error) {
+	fileType := strings.ToLower(strings.TrimPrefix(filepath.Ext(fileName), "."))
+	if !isLocalRAGFileTypeSupported(fileType) {
+		return nil, fmt.Errorf("本地知识库不支持的文件类型: %s", fileType)
+	}
+	if len(file) == 0 {
+		return nil, fmt.Errorf("文件内容为空")
+	}
+
+	name := fileName
+	if value, ok := params["name"].(string); ok && value != "" {
+		name = value
+	}
+	chunkMethod := "naive"
+	if value, ok := params["chunk_method"].(string); ok && value != "" {
+		chunkMethod = value
+	}
+	parserConfig, _ := params["parser_config"].(map[string]interface{})
+	metaFields, _ := params["meta_fields"].(map[string]interface{})
+
+	now := time.Now()
+	doc := &Document{
+		ID:           strings.ReplaceAll(uuid.New().String(), "-", ""),
+		DatasetID:    datasetId,
+		Name:         name,
+		FileType:     fileType,
+		FileSize:     int64(len(file)),
+		MetaFields:   metaFields,
+		ChunkMethod:  chunkMethod,
+		ParserConfig: parserConfig,
+		Status:       constant.DocumentStatusUnstart,
+		Run:          constant.DocumentRunUnstart,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	doc.DocumentID = doc.ID
+	if userId, err := middleware.GetUserIdFromContext(ctx); err == nil {
+		doc.Creator = userId
+		doc.Updater = userId
+	}
+
+	if err := a.repo.CreateDocument(ctx, doc, file); err != nil {
+		return nil, fmt.Errorf("保存文档失败: %w", err)
+	}
+	return doc, nil
+}
+
+// DeleteDocument 删除文档及其切片
+func (a *LocalRAGAdapter) DeleteDocument(ctx context.Context, datasetId, documentId string) error {
+	if err := a.repo.DeleteDocument(ctx, datasetId, documentId); err != nil {
+		return fmt.Errorf("删除文档失败: %w", err)
+	}
+	return nil
+}
+
+// ParseDocuments 将文档标记为解析中并在后台完成切片与向量化
+func (a *LocalRAGAdapter) ParseDocuments(ctx context.Context, datasetId string, documentIds []string) (bool, error) {
+	// 先校验嵌入模型，避免文档进入解析状态后才失败
+	embedder, err := a.getEmbeddingClient(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	docs := make([]*Document, 0, len(documentIds))
+	for _, documentId := range documentIds {
+		doc, err := a.GetDocumentById(ctx, datasetId, documentId)
+		if err != nil {
+			return false, err
+		}
+		if doc.Status == constant.DocumentStatusRunning {
+			return false, fmt.Errorf("文档正在解析中: %s", doc.Name)
+		}
+		docs = append(docs, doc)
+	}
+
+	for _, doc := range docs {
+		a.setDocumentProgress(doc, constant.DocumentStatusRunning, 0, "等待解析")
+		if err := a.repo.UpdateDocument(ctx, doc); err != nil {
+			return false, fmt.Errorf("更新文档状态失败: %w", err)
+		}
+	}
+
+	// 解析可能耗时较长，不能随请求取消
+	parseCtx := context.WithoutCancel(ctx)
+	go func() {
+		for _, doc := range docs {
+			a.parseDocument(parseCtx, embedder, doc)
+		}
+	}()
+
+	return true, nil
+}
+
+// parseDocument 提取文本、切片、向量化并保存，失败时记录到文档状态
+func (a *LocalRAGAdapter) parseDocument(ctx context.Context, embedder *EmbeddingClient, doc *Document) {
+	// 文件内容不可信，解析中的panic只让当前文档失败，不能影响服务进程
+	defer func() {
+		if r := recover(); r != nil {
+			a.log.Errorf("解析文档panic, documentId: %s, panic: %v", doc.ID, r)
+			a.setDocumentProgress(doc, constant.DocumentStatusFailed, doc.Progress, fmt.Sprintf("解析文档失败: %v", r))
+			if err := a.repo.UpdateDocument(ctx, doc); err != nil {
+				a.log.Errorf("更新文档状态失败, documentId: %s, error: %v", doc.ID, err)
+			}
+		}
+	}()
+
+	if err := a.doParseDocument(ctx, embedder, doc); err != nil {
+		if errors.Is(err, errLocalParseCancelled) {
+			a.log.Infof("文档解析已取消, documentId: %s", doc.ID)
+			return
+		}
+		a.log.Errorf("解析文档失败, documentId: %s, error: %v", doc.ID, err)
+		a.setDocumentProgress(doc, constant.DocumentStatusFailed, doc.Progress, err.Error())
+		if err := a.repo.UpdateDocument(ctx, doc); err != nil {
+			a.log.Errorf("更新文档状态失败, documentId: %s, error: %v", doc.ID, err)
+		}
+	}
+}
+
+func (a *LocalRAGAdapter) doParseDocument(ctx context.Context, embedder *EmbeddingClient, doc *Document) error {
+	content, err := a.repo.GetDocumentContent(ctx, doc.ID)
+	if err != nil {
+		return fmt.Errorf("读取文件内容失败: %w", err)
+	}
+	text, err := extractDocumentText(doc.FileType, content)
+	if err != nil {
+		return err
+	}
+
+	chunkSize, overlap := a.chunkOptions(doc.ParserConfig)
+	markdown := doc.FileType == "md" || doc.FileType == "markdown"
+	contents := splitDocumentText(text, markdown, chunkSize, overlap)
+	if len(contents) == 0 {
+		return fmt.Errorf("文档切片结果为空")
+	}
+
+	if err := a.updateParseProgress(ctx, doc, 0.1, fmt.Sprintf("已切分为%d个切片，开始向量化", len(contents))); err != nil {
+		return err
+	}
+
+	chunks := make([]*RAGChunk, 0, len(contents))
+	var tokenCount int64
+	for start := 0; start < len(contents); start += embeddingBatchSize {
+		end := min(start+embeddingBatchSize, len(contents))
+		vectors, err := embedder.Embed(ctx, contents[start:end])
+		if err != nil {
+			return err
+		}
+		for i, vector := range vectors {
+			tokens := estimateTokenCount(contents[start+i])
+			tokenCount += int64(tokens)
+			chunks = append(chunks, &RAGChunk{
+				ID:         strings.ReplaceAll(uuid.New().String(), "-", ""),
+				DatasetID:  doc.DatasetID,
+				DocumentID: doc.ID,
+				Position:   start + i,
+				Content:    contents[start+i],
+				Embedding:  vector,
+				TokenCount: tokens,
+			})
+		}
+
+		progress := 0.1 + 0.8*float64(end)/float64(len(contents))
+		if err := a.updateParseProgress(ctx, doc, progress, fmt.Sprintf("向量化进度 %d/%d", end, len(contents))); err != nil {
+			return err
+		}
+	}
+
+	if err := a.checkParseCancelled(ctx, doc); err != nil {
+		return err
+	}
+	if err := a.repo.ReplaceChunks(ctx, doc.ID, chunks); err != nil {
+		return fmt.Errorf("保存切片失败: %w", err)
+	}
+
+	doc.ChunkCount = int64(len(chunks))
+	doc.TokenCount = tokenCount
+	a.setDocumentProgress(doc, constant.DocumentStatusDone, 1, "解析完成")
+	if err := a.repo.UpdateDocument(ctx, doc); err != nil {
+		return fmt.Errorf("更新文档状态失败: %w", err)
+	}
+	return nil
+}
+
+// updateParseProgress 保存解析进度，文档已被取消时返回errLocalParseCancelled
+func (a *LocalRAGAdapter) updateParseProgress(ctx context.Context, doc *Document, progress float64, msg string) error {
+	if err := a.checkParseCancelled(ctx, doc); err != nil {
+		return err
+	}
+	a.setDocumentProgress(doc, constant.DocumentStatusRunning, progress, msg)
+	if err := a.repo.UpdateDocument(ctx, doc); err != nil {
+		return fmt.Errorf("更新文档状态失败: %w", err)
+	}
+	return nil
+}
+
+// checkParseCancelled 检查文档是否已通过StopParseDocuments取消
+func (a *LocalRAGAdapter) checkParseCancelled(ctx context.Context, doc *Document) error {
+	current, err := a.repo.GetDocument(ctx, doc.DatasetID, doc.ID)
+	if err != nil {
+		return fmt.Errorf("查询文档状态失败: %w", err)
+	}
+	if current == nil || current.Status == constant.DocumentStatusCancelled {
+		return errLocalParseCancelled
+	}
+	return nil
+}
+
+// setDocumentProgress 更新内存中的文档解析状态
+func (a *LocalRAGAdapter) setDocumentProgress(doc *Document, status int32, progress float64, msg string) {
+	doc.Status = status
+	doc.Run = documentStatusToRun(status)
+	doc.Progress = progress
+	doc.ProgressMsg = msg
+	doc.UpdatedAt = time.Now()
+}
+
+// chunkOptions 获取切片大小和重叠，文档的parser_config（RAGFlow的chunk_token_num）优先于RAG模型配置
+func (a *LocalRAGAdapter) chunkOptions(parserConfig map[string]interface{}) (int, int) {
+	chunkSize := localRAGDefaultChunkSize
+	if value, ok := paramInt(a.config["chunk_size"]); ok && value > 0 {
+		chunkSize = value
+	}
+	if value, ok := paramInt(parserConfig["chunk_token_num"]); ok && value > 0 {
+		chunkSize = value
+	}
+
+	overlap := localRAGDefaultChunkOverlap
+	if value, ok := paramInt(a.config["chunk_overlap"]); ok && value >= 0 {
+		overlap = value
+	}
+	if overlap >= chunkSize {
+		overlap = chunkSize / 8
+	}
+	return chunkSize, overlap
+}
+
+// StopParseDocuments 将解析中的文档标记为已取消，后台解析在下一个检查点退出
+func (a *LocalRAGAdapter) StopParseDocuments(ctx context.Context, datasetId string, documentIds []string) error {
+	for _, documentId := range documentIds {
+		doc, err := a.GetDocumentById(ctx, datasetId, documentId)
+		if err != nil {
+			return err
+		}
+		if doc.Status != constant.DocumentStatusRunning {
+			continue
+		}
+		a.setDocumentProgress(doc, constant.DocumentStatusCancelled, doc.Progress, "解析已取消")
+		if err := a.repo.UpdateDocument(ctx, doc); err != nil {
+			return fmt.Errorf("更新文档状态失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListChunks 列出指定文档的切片，返回结构与RAGFlow一致
+func (a *LocalRAGAdapter) ListChunks(ctx context.Context, datasetId, documentId string, params map[string]interface{}) (map[string]interface{}, error) {
+	doc, err := a.GetDocumentById(ctx, datasetId, documentId)
+	if err != nil {
+		return nil, err
+	}
+
+	keywords, _ := params["keywords"].(string)
+	chunkId, _ := params["id"].(string)
+	page, _ := paramInt(params["page"])
+	pageSize, _ := paramInt(params["page_size"])
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = localRAGDefaultChunkListPageSize
+	}
+
+	chunks, total, err := a.repo.PageChunks(ctx, documentId, keywords, chunkId, page, pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("查询切片失败: %w", err)
+	}
+
+	items := make([]interface{}, 0, len(chunks))
+	for _, chunk := range chunks {
+		items = append(items, map[string]interface{}{
+			"id":                 chunk.ID,
+			"content":            chunk.Content,
+			"document_id":        chunk.DocumentID,
+			"docnm_kwd":          doc.Name,
+			"dataset_id":         chunk.DatasetID,
+			"positions":          []interface{}{},
+			"important_keywords": []interface{}{},
+			"available":          true,
+			"token_count":        chunk.TokenCount,
+		})
+	}
+
+	return map[string]interface{}{
+		"chunks": items,
+		"doc": map[string]interface{}{
+			"id":           doc.ID,
+			"name":         doc.Name,
+			"dataset_id":   doc.DatasetID,
+			"chunk_method": doc.ChunkMethod,
+			"chunk_count":  doc.ChunkCount,
+			"token_count":  doc.TokenCount,
+			"run":          doc.Run,
+			"progress":     doc.Progress,
+			"progress_msg": doc.ProgressMsg,
+		},
+		"total": total,
+	}, nil
+}
+
+// localRetrievalHit 召回命中的切片及得分
+type localRetrievalHit struct {
+	chunk            *RAGChunk
+	similarity       float64
+	vectorSimilarity float64
+	termSimilarity   float64
+}
+
+// RetrievalTest 召回测试，得分 = 向量相似度 * vectorSimilarityWeight + 关键词相似度 * (1 - vectorSimilarityWeight)
+func (a *LocalRAGAdapter) RetrievalTest(ctx context.Context, params map[string]interface{}) (map[string]interface{}, error) {
+	question, _ := params["question"].(string)
+	if strings.TrimSpace(question) == "" {
+		return nil, fmt.Errorf("问题不能为空")
+	}
+	datasetIds, _ := params["datasetIds"].([]string)
+	documentIds, _ := params["documentIds"].([]string)
+
+	threshold := localRAGDefaultSimilarity
+	if value, ok := paramFloat(params["similarityThreshold"]); ok {
+		threshold = value
+	}
+	vectorWeight := localRAGDefaultVectorWeight
+	if value, ok := paramFloat(params["vectorSimilarityWeight"]); ok && value >= 0 && value <= 1 {
+		vectorWeight = value
+	}
+	topK := localRAGDefaultTopK
+	if value, ok := paramInt(params["topK"]); ok && value > 0 {
+		topK = value
+	}
+	page, _ := paramInt(params["page"])
+	if page <= 0 {
+		page = 1
+	}
+	pageSize, _ := paramInt(params["pageSize"])
+	if pageSize <= 0 {
+		pageSize = localRAGDefaultPageSize
+	}
+	highlight, _ := params["highlight"].(bool)
+
+	embedder, err := a.getEmbeddingClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vectors, err := embedder.Embed(ctx, []string{question})
+	if err != nil {
+		return nil, err
+	}
+	questionVector := vectors[0]
+
+	chunks, err := a.repo.ListChunks(ctx, datasetIds, documentIds)
+	if err != nil {
+		return nil, fmt.Errorf("查询切片失败: %w", err)
+	}
+
+	questionTerms := kit.Uniq(tokenizeText(question))
+	hits := make([]*localRetrievalHit, 0)
+	for _, chunk := range chunks {
+		vectorSim := max(cosineSimilarity(questionVector, chunk.Embedding), 0)
+		termSim := termSimilarity(questionTerms, termSet(tokenizeText(chunk.Content)))
+		similarity := vectorWeight*vectorSim + (1-vectorWeight)*termSim
+		if similarity < threshold {
+			continue
+		}
+		hits = append(hits, &localRetrievalHit{
+			chunk:            chunk,
+			similarity:       similarity,
+			vectorSimilarity: vectorSim,
+			termSimilarity:   termSim,
+		})
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].similarity > hits[j].similarity })
+	if len(hits) > topK {
+		hits = hits[:topK]
+	}
+
+	// 文档聚合统计基于全部命中结果
+	docCounts := make(map[string]int)
+	docIds := make([]string, 0)
+	for _, hit := range hits {
+		if docCounts[hit.chunk.DocumentID] == 0 {
+			docIds = append(docIds, hit.chunk.DocumentID)
+		}
+		docCounts[hit.chunk.DocumentID]++
+	}
+	docNames, err := a.repo.GetDocumentNames(ctx, docIds)
+	if err != nil {
+		return nil, fmt.Errorf("查询文档名称失败: %w", err)
+	}
+
+	total := len(hits)
+	start := min((page-1)*pageSize, total)
+	end := min(start+pageSize, total)
+
+	items := make([]interface{}, 0, end-start)
+	for _, hit := range hits[start:end] {
+		item := map[string]interface{}{
+			"id":                hit.chunk.ID,
+			"content":           hit.chunk.Content,
+			"document_id":       hit.chunk.DocumentID,
+			"document_keyword":  docNames[hit.chunk.DocumentID],
+			"kb_id":             hit.chunk.DatasetID,
+			"dataset_id":        hit.chunk.DatasetID,
+			"similarity":        hit.similarity,
+			"vector_similarity": hit.vectorSimilarity,
+			"term_similarity":   hit.termSimilarity,
+			"positions":         []interface{}{},
+		}
+		if highlight {
+			item["highlight"] = highlightText(hit.chunk.Content, questionTerms)
+		}
+		items = append(items, item)
+	}
+
+	sort.SliceStable(docIds, func(i, j int) bool { return docCounts[docIds[i]] > docCounts[docIds[j]] })
+	docAggs := make([]interface{}, 0, len(docIds))
+	for _, docId := range docIds {
+		docAggs = append(docAggs, map[string]interface{}{
+			"doc_id":   docId,
+			"doc_name": docNames[docId],
+			"count":    docCounts[docId],
+		})
+	}
+
+	return map[string]interface{}{
+		"chunks":   items,
+		"doc_aggs": docAggs,
+		"total":    total,
+	}, nil
+}
+
+// CreateDataset 本地知识库无需远程创建，直接生成数据集ID
+func (a *LocalRAGAdapter) CreateDataset(ctx context.Context, name, description string) (string, error) {
+	return strings.ReplaceAll(uuid.New().String(), "-", ""), nil
+}
+
+// UpdateDataset 名称和描述只保存在知识库表中，无需同步
+func (a *LocalRAGAdapter) UpdateDataset(ctx context.Context, datasetId, name, description string) error {
+	return nil
+}
+
+// DeleteDataset 删除知识库下的所有文档和切片
+func (a *LocalRAGAdapter) DeleteDataset(ctx context.Context, datasetId string) error {
+	if err := a.repo.DeleteDataset(ctx, datasetId); err != nil {
+		return fmt.Errorf("删除知识库文档失败: %w", err)
+	}
+	return nil
+}
+
+// GetDocumentCount 获取数据集中的文档数量
+func (a *LocalRAGAdapter) GetDocumentCount(ctx context.Context, datasetId string) (int, error) {
+	_, total, err := a.repo.PageDocuments(ctx, datasetId, "", nil, 1, 1)
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// validateConfig 验证本地知识库配置
+func (a *LocalRAGAdapter) validateConfig(config map[string]interface{}) error {
+	if config == nil {
+		return fmt.Errorf("RAG配置不能为空")
+	}
+	if firstConfigString(config, "embedding_model_id") == "" {
+		return fmt.Errorf("RAG配置缺少embedding_model_id")
+	}
+	if a.repo == nil || a.modelConfigRepo == nil {
+		return fmt.Errorf("本地知识库存储未初始化")
+	}
+	return nil
+}
+
+// getEmbeddingClient 根据embedding_model_id加载嵌入模型配置
+func (a *LocalRAGAdapter) getEmbeddingClient(ctx context.Context) (*EmbeddingClient, error) {
+	modelId := firstConfigString(a.config, "embedding_model_id")
+	modelConfig, err := a.modelConfigRepo.GetModelConfigByIDRaw(ctx, modelId)
+	if err != nil {
+		return nil, fmt.Errorf("获取嵌入模型配置失败: %w", err)
+	}
+	if modelConfig == nil {
+		return nil, fmt.Errorf("嵌入模型配置不存在: %s", modelId)
+	}
+
+	return NewEmbeddingClient(modelConfig.ConfigJSON, firstConfigString(a.config, "embedding_model"), a.httpClient)
+}
+
+// paramInt 将JSON或请求参数中的数字转换为int
+func paramInt(value interface{}) (int, bool) {
+	if f, ok := paramFloat(value); ok {
+		return int(f), true
+	}
+	return 0, false
+}
+
+// paramFloat 将JSON或请求参数中的数字转换为float64
+func paramFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
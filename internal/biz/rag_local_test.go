@@ -0,0 +1,388 @@
+package biz
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/constant"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/stretchr/testify/suite"
+)
+
+// LocalRAGAdapterSuite 使用内存存储和模拟嵌入服务测试本地知识库适配器
+type LocalRAGAdapterSuite struct {
+	suite.Suite
+	server  *httptest.Server
+	repo    *memoryLocalRAGRepo
+	adapter RAGAdapter
+}
+
+func TestLocalRAGAdapterSuite(t *testing.T) {
+	suite.Run(t, new(LocalRAGAdapterSuite))
+}
+
+func (s *LocalRAGAdapterSuite) SetupTest() {
+	// 模拟嵌入服务：按关键词出现与否生成三维向量
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal("/v1/embeddings", r.URL.Path)
+		s.Equal("Bearer embed-key", r.Header.Get("Authorization"))
+		var req embeddingRequest
+		s.Require().NoError(json.NewDecoder(r.Body).Decode(&req))
+		s.Equal("text-embedding-test", req.Model)
+
+		data := make([]map[string]interface{}, len(req.Input))
+		for i, input := range req.Input {
+			vector := []float32{0.01, 0.01, 0.01}
+			for dim, keyword := range []string{"猫", "dog", "天气"} {
+				if strings.Contains(input, keyword) {
+					vector[dim] = 1
+				}
+			}
+			data[len(req.Input)-1-i] = map[string]interface{}{"index": i, "embedding": vector}
+		}
+		s.Require().NoError(json.NewEncoder(w).Encode(map[string]interface{}{"data": data}))
+	}))
+
+	s.repo = newMemoryLocalRAGRepo()
+	modelConfigRepo := &stubModelConfigRepo{configs: map[string]*ModelConfig{
+		"embed": {ID: "embed", ConfigJSON: fmt.Sprintf(`{"type":"openai","base_url":"%s/v1","api_key":"embed-key","model_name":"text-embedding-test"}`, s.server.URL)},
+	}}
+	factory := NewRAGAdapterFactory(s.repo, modelConfigRepo, log.NewStdLogger(io.Discard))
+	adapter, err := factory.GetAdapter(constant.RAGAdapterLocal, map[string]interface{}{
+		"type":               constant.RAGAdapterLocal,
+		"embedding_model_id": "embed",
+		"chunk_size":         float64(20),
+		"chunk_overlap":      float64(0),
+	})
+	s.Require().NoError(err)
+	s.adapter = adapter
+}
+
+func (s *LocalRAGAdapterSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *LocalRAGAdapterSuite) TestValidateConfig() {
+	factory := NewRAGAdapterFactory(s.repo, &stubModelConfigRepo{}, log.NewStdLogger(io.Discard))
+	_, err := factory.GetAdapter(constant.RAGAdapterLocal, map[string]interface{}{"type": constant.RAGAdapterLocal})
+	s.ErrorContains(err, "embedding_model_id")
+
+	_, err = NewRAGAdapterFactory(nil, nil, log.NewStdLogger(io.Discard)).
+		GetAdapter(constant.RAGAdapterLocal, map[string]interface{}{"embedding_model_id": "embed"})
+	s.Error(err)
+}
+
+func (s *LocalRAGAdapterSuite) TestUploadParseAndRetrieve() {
+	ctx := context.Background()
+	datasetId, err := s.adapter.CreateDataset(ctx, "kb", "")
+	s.Require().NoError(err)
+	s.Len(datasetId, 32)
+
+	_, err = s.adapter.UploadDocument(ctx, datasetId, []byte("data"), "a.docx", nil)
+	s.ErrorContains(err, "不支持的文件类型")
+
+	markdown := "# 宠物\n\n家里的猫喜欢睡觉。\n\n# 天气\n\n今天天气晴朗，适合出门。\n\nThe dog runs outside."
+	doc, err := s.adapter.UploadDocument(ctx, datasetId, []byte(markdown), "notes.md", map[string]interface{}{"name": "笔记"})
+	s.Require().NoError(err)
+	s.Equal("笔记", doc.Name)
+	s.Equal("md", doc.FileType)
+	s.Equal(constant.DocumentStatusUnstart, doc.Status)
+
+	ok, err := s.adapter.ParseDocuments(ctx, datasetId, []string{doc.ID})
+	s.Require().NoError(err)
+	s.True(ok)
+	s.Eventually(func() bool {
+		parsed, err := s.adapter.GetDocumentById(ctx, datasetId, doc.ID)
+		return err == nil && parsed.Status == constant.DocumentStatusDone
+	}, 5*time.Second, 10*time.Millisecond)
+
+	parsed, err := s.adapter.GetDocumentById(ctx, datasetId, doc.ID)
+	s.Require().NoError(err)
+	s.Equal(constant.DocumentRunDone, parsed.Run)
+	s.Equal(int64(2), parsed.ChunkCount)
+	s.Positive(parsed.TokenCount)
+
+	chunks, err := s.adapter.ListChunks(ctx, datasetId, doc.ID, map[string]interface{}{"keywords": "天气"})
+	s.Require().NoError(err)
+	s.Equal(1, chunks["total"])
+
+	result, err := s.adapter.RetrievalTest(ctx, map[string]interface{}{
+		"question":   "天气怎么样",
+		"datasetIds": []string{datasetId},
+		"highlight":  true,
+	})
+	s.Require().NoError(err)
+	s.Equal(1, result["total"])
+	hit := result["chunks"].([]interface{})[0].(map[string]interface{})
+	s.Contains(hit["content"], "天气晴朗")
+	s.Contains(hit["highlight"], "<em>天气</em>")
+	s.Equal("笔记", hit["document_keyword"])
+	s.Greater(hit["vector_similarity"], 0.5)
+
+	count, err := s.adapter.GetDocumentCount(ctx, datasetId)
+	s.Require().NoError(err)
+	s.Equal(1, count)
+
+	s.Require().NoError(s.adapter.DeleteDataset(ctx, datasetId))
+	count, err = s.adapter.GetDocumentCount(ctx, datasetId)
+	s.Require().NoError(err)
+	s.Zero(count)
+	s.Empty(s.repo.chunks)
+}
+
+func (s *LocalRAGAdapterSuite) TestParseFailure() {
+	ctx := context.Background()
+	doc, err := s.adapter.UploadDocument(ctx, "ds", []byte("   "), "empty.txt", nil)
+	s.Require().NoError(err)
+
+	_, err = s.adapter.ParseDocuments(ctx, "ds", []string{doc.ID})
+	s.Require().NoError(err)
+	s.Eventually(func() bool {
+		parsed, err := s.adapter.GetDocumentById(ctx, "ds", doc.ID)
+		return err == nil && parsed.Status == constant.DocumentStatusFailed && parsed.ProgressMsg != ""
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSplitDocumentText(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		markdown  bool
+		chunkSize int
+		overlap   int
+		want      []string
+	}{
+		{
+			name:      "paragraphs packed into one chunk",
+			text:      "one two\n\nthree four",
+			chunkSize: 10,
+			want:      []string{"one two\n\nthree four"},
+		},
+		{
+			name:      "sentences split with overlap",
+			text:      "一二三。四五六。七八九。",
+			chunkSize: 6,
+			overlap:   3,
+			want:      []string{"一二三。四五六。", "四五六。七八九。"},
+		},
+		{
+			name:      "markdown breaks at headings",
+			text:      "# A\n\nalpha\n\n# B\n\nbeta",
+			markdown:  true,
+			chunkSize: 100,
+			want:      []string{"# A\n\nalpha", "# B\n\nbeta"},
+		},
+		{
+			name:      "long word run hard split",
+			text:      "a b c d e",
+			chunkSize: 2,
+			want:      []string{"a b", "c d", "e"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitDocumentText(tt.text, tt.markdown, tt.chunkSize, tt.overlap)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("splitDocumentText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractDocumentTextPDF(t *testing.T) {
+	// 构造包含FlateDecode内容流和ToUnicode映射的最小PDF
+	var content bytes.Buffer
+	zw := zlib.NewWriter(&content)
+	zw.Write([]byte("BT /F1 12 Tf 72 712 Td (Hello) Tj 0 -14 Td [(Wor) -10 (ld)] TJ ET BT /F2 12 Tf 72 680 Td <00010002> Tj ET"))
+	zw.Close()
+	cmap := "/CIDInit /ProcSet findresource begin begincmap 1 begincodespacerange <0000> <FFFF> endcodespacerange " +
+		"1 beginbfchar <0001> <77E5> endbfchar 1 beginbfrange <0002> <0002> <8BC6> endbfrange endcmap end"
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 /Resources << /Font << /F1 5 0 R /F2 6 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 4 0 R >>",
+		fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"<< /Type /Font /Subtype /Type0 /BaseFont /SimSun /ToUnicode 7 0 R >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(cmap), cmap),
+	}
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&pdf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	pdf.WriteString("trailer << /Root 1 0 R >>\n%%EOF\n")
+
+	text, err := extractDocumentText("pdf", pdf.Bytes())
+	if err != nil {
+		t.Fatalf("extractDocumentText() error = %v", err)
+	}
+	if text != "Hello\nWorld\n知识" {
+		t.Errorf("extractDocumentText() = %q", text)
+	}
+}
+
+// memoryLocalRAGRepo 内存实现的LocalRAGRepo
+type memoryLocalRAGRepo struct {
+	mu       sync.Mutex
+	docs     map[string]*Document
+	contents map[string][]byte
+	chunks   map[string]*RAGChunk
+}
+
+func newMemoryLocalRAGRepo() *memoryLocalRAGRepo {
+	return &memoryLocalRAGRepo{
+		docs:     make(map[string]*Document),
+		contents: make(map[string][]byte),
+		chunks:   make(map[string]*RAGChunk),
+	}
+}
+
+func (r *memoryLocalRAGRepo) CreateDocument(ctx context.Context, doc *Document, content []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *doc
+	r.docs[doc.ID] = &copied
+	r.contents[doc.ID] = content
+	return nil
+}
+
+func (r *memoryLocalRAGRepo) GetDocument(ctx context.Context, datasetId, documentId string) (*Document, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	doc, ok := r.docs[documentId]
+	if !ok || doc.DatasetID != datasetId {
+		return nil, nil
+	}
+	copied := *doc
+	return &copied, nil
+}
+
+func (r *memoryLocalRAGRepo) GetDocumentContent(ctx context.Context, documentId string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.contents[documentId], nil
+}
+
+func (r *memoryLocalRAGRepo) PageDocuments(ctx context.Context, datasetId, keywords string, status *int32, page, limit int) ([]*Document, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var docs []*Document
+	for _, doc := range r.docs {
+		if doc.DatasetID == datasetId && strings.Contains(doc.Name, keywords) && (status == nil || doc.Status == *status) {
+			copied := *doc
+			docs = append(docs, &copied)
+		}
+	}
+	total := len(docs)
+	start := min((page-1)*limit, total)
+	return docs[start:min(start+limit, total)], total, nil
+}
+
+func (r *memoryLocalRAGRepo) UpdateDocument(ctx context.Context, doc *Document) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *doc
+	r.docs[doc.ID] = &copied
+	return nil
+}
+
+func (r *memoryLocalRAGRepo) DeleteDocument(ctx context.Context, datasetId, documentId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.docs, documentId)
+	for id, chunk := range r.chunks {
+		if chunk.DocumentID == documentId {
+			delete(r.chunks, id)
+		}
+	}
+	return nil
+}
+
+func (r *memoryLocalRAGRepo) DeleteDataset(ctx context.Context, datasetId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, doc := range r.docs {
+		if doc.DatasetID == datasetId {
+			delete(r.docs, id)
+		}
+	}
+	for id, chunk := range r.chunks {
+		if chunk.DatasetID == datasetId {
+			delete(r.chunks, id)
+		}
+	}
+	return nil
+}
+
+func (r *memoryLocalRAGRepo) ReplaceChunks(ctx context.Context, documentId string, chunks []*RAGChunk) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, chunk := range r.chunks {
+		if chunk.DocumentID == documentId {
+			delete(r.chunks, id)
+		}
+	}
+	for _, chunk := range chunks {
+		r.chunks[chunk.ID] = chunk
+	}
+	return nil
+}
+
+func (r *memoryLocalRAGRepo) PageChunks(ctx context.Context, documentId, keywords, chunkId string, page, pageSize int) ([]*RAGChunk, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var chunks []*RAGChunk
+	for _, chunk := range r.chunks {
+		if chunk.DocumentID == documentId && strings.Contains(chunk.Content, keywords) && (chunkId == "" || chunk.ID == chunkId) {
+			chunks = append(chunks, chunk)
+		}
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].Position < chunks[j].Position })
+	total := len(chunks)
+	start := min((page-1)*pageSize, total)
+	return chunks[start:min(start+pageSize, total)], total, nil
+}
+
+func (r *memoryLocalRAGRepo) ListChunks(ctx context.Context, datasetIds, documentIds []string) ([]*RAGChunk, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	datasets := termSet(datasetIds)
+	documents := termSet(documentIds)
+	var chunks []*RAGChunk
+	for _, chunk := range r.chunks {
+		if _, ok := datasets[chunk.DatasetID]; !ok {
+			continue
+		}
+		if _, ok := documents[chunk.DocumentID]; len(documents) > 0 && !ok {
+			continue
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+func (r *memoryLocalRAGRepo) GetDocumentNames(ctx context.Context, documentIds []string) (map[string]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make(map[string]string)
+	for _, id := range documentIds {
+		if doc, ok := r.docs[id]; ok {
+			names[id] = doc.Name
+		}
+	}
+	return names, nil
+}
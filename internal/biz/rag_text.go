@@ -0,0 +1,376 @@
+package biz
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+)
+
+// localRAGTextTypes 本地知识库支持按纯文本解析的文件类型
+var localRAGTextTypes = map[string]bool{
+	"txt":      true,
+	"text":     true,
+	"md":       true,
+	"markdown": true,
+	"csv":      true,
+	"json":     true,
+	"log":      true,
+}
+
+// isLocalRAGFileTypeSupported 判断本地知识库是否支持该文件类型
+func isLocalRAGFileTypeSupported(fileType string) bool {
+	return fileType == "pdf" || localRAGTextTypes[fileType]
+}
+
+// extractDocumentText 按文件类型提取文档纯文本
+func extractDocumentText(fileType string, content []byte) (string, error) {
+	var text string
+	switch {
+	case fileType == "pdf":
+		pdfText, err := kit.ExtractPDFText(content)
+		if err != nil {
+			return "", fmt.Errorf("解析PDF失败: %w", err)
+		}
+		text = pdfText
+	case localRAGTextTypes[fileType]:
+		content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+		if !utf8.Valid(content) {
+			return "", fmt.Errorf("文件不是有效的UTF-8文本")
+		}
+		text = string(content)
+	default:
+		return "", fmt.Errorf("不支持的文件类型: %s", fileType)
+	}
+
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+	if strings.TrimSpace(text) == "" {
+		return "", fmt.Errorf("文档中没有可提取的文本")
+	}
+
+	return text, nil
+}
+
+// splitDocumentText 将文本切分为不超过chunkSize个Token的切片，相邻切片保留overlap个Token的重叠
+// markdown文档在标题处强制断开，保证切片不跨章节
+func splitDocumentText(text string, markdown bool, chunkSize, overlap int) []string {
+	if chunkSize <= 0 {
+		return nil
+	}
+	if overlap < 0 || overlap >= chunkSize {
+		overlap = 0
+	}
+
+	var chunks []string
+	var current []string
+	currentTokens := 0
+
+	flush := func(keepOverlap bool) {
+		if len(current) == 0 {
+			return
+		}
+		chunks = append(chunks, strings.TrimSpace(strings.Join(current, "")))
+
+		// 从末尾保留不超过overlap个Token的片段作为下一个切片的开头
+		var tail []string
+		tailTokens := 0
+		if keepOverlap {
+			for i := len(current) - 1; i >= 0; i-- {
+				tokens := estimateTokenCount(current[i])
+				if tailTokens+tokens > overlap {
+					break
+				}
+				tail = append([]string{current[i]}, tail...)
+				tailTokens += tokens
+			}
+		}
+		current, currentTokens = tail, tailTokens
+	}
+
+	for _, section := range splitTextSections(text, markdown) {
+		for _, segment := range splitTextSegments(section, chunkSize) {
+			tokens := estimateTokenCount(segment)
+			if currentTokens+tokens > chunkSize {
+				flush(true)
+				// 重叠部分加当前片段仍然超长时放弃重叠
+				if currentTokens+tokens > chunkSize {
+					current, currentTokens = nil, 0
+				}
+			}
+			current = append(current, segment)
+			currentTokens += tokens
+		}
+		flush(false)
+	}
+
+	result := chunks[:0]
+	for _, chunk := range chunks {
+		if chunk != "" {
+			result = append(result, chunk)
+		}
+	}
+	return result
+}
+
+// splitTextSections 按markdown标题拆分章节，普通文本作为一个章节
+func splitTextSections(text string, markdown bool) []string {
+	if !markdown {
+		return []string{text}
+	}
+
+	var sections []string
+	var current strings.Builder
+	inCodeBlock := false
+	for _, line := range strings.SplitAfter(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			inCodeBlock = !inCodeBlock
+		}
+		if !inCodeBlock && strings.HasPrefix(trimmed, "#") && current.Len() > 0 {
+			sections = append(sections, current.String())
+			current.Reset()
+		}
+		current.WriteString(line)
+	}
+	if current.Len() > 0 {
+		sections = append(sections, current.String())
+	}
+	return sections
+}
+
+// splitTextSegments 将章节拆为段落，超长段落继续按句子切分，超长句子按字符硬切
+func splitTextSegments(section string, chunkSize int) []string {
+	var segments []string
+	for _, paragraph := range strings.SplitAfter(section, "\n\n") {
+		if strings.TrimSpace(paragraph) == "" {
+			continue
+		}
+		if estimateTokenCount(paragraph) <= chunkSize {
+			segments = append(segments, paragraph)
+			continue
+		}
+		for _, sentence := range splitSentences(paragraph) {
+			if estimateTokenCount(sentence) <= chunkSize {
+				segments = append(segments, sentence)
+				continue
+			}
+			segments = append(segments, hardSplitText(sentence, chunkSize)...)
+		}
+	}
+	return segments
+}
+
+// splitSentences 按中英文句末标点和换行切分句子，标点保留在句尾
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	runes := []rune(text)
+	for i, r := range runes {
+		switch r {
+		case '。', '！', '？', '；', '!', '?', ';', '\n':
+		case '.':
+			// 英文句点需要后跟空白才视为句末，避免切开小数和缩写
+			if i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+				continue
+			}
+		default:
+			continue
+		}
+		sentences = append(sentences, string(runes[start:i+1]))
+		start = i + 1
+	}
+	if start < len(runes) {
+		sentences = append(sentences, string(runes[start:]))
+	}
+	return sentences
+}
+
+// hardSplitText 按Token数硬切文本
+func hardSplitText(text string, chunkSize int) []string {
+	var parts []string
+	var current strings.Builder
+	tokens := 0
+	inWord := false
+	for _, r := range text {
+		wordRune := isWordRune(r)
+		if isHanRune(r) || wordRune && !inWord {
+			if tokens >= chunkSize {
+				parts = append(parts, current.String())
+				current.Reset()
+				tokens = 0
+			}
+			tokens++
+		}
+		inWord = wordRune
+		current.WriteRune(r)
+	}
+	if current.Len() > 0 {
+		parts = append(parts, current.String())
+	}
+	return parts
+}
+
+// estimateTokenCount 估算Token数量：每个汉字计为一个Token，连续的字母数字计为一个Token
+func estimateTokenCount(text string) int {
+	count := 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case isHanRune(r):
+			count++
+			inWord = false
+		case isWordRune(r):
+			if !inWord {
+				count++
+			}
+			inWord = true
+		default:
+			inWord = false
+		}
+	}
+	return count
+}
+
+// tokenizeText 将文本切分为用于关键词匹配的词项：英文数字按单词（小写），中文按相邻二字组合
+func tokenizeText(text string) []string {
+	var terms []string
+	var word []rune
+	var han []rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			terms = append(terms, string(word))
+			word = word[:0]
+		}
+	}
+	flushHan := func() {
+		switch len(han) {
+		case 0:
+		case 1:
+			terms = append(terms, string(han))
+		default:
+			for i := 0; i+1 < len(han); i++ {
+				terms = append(terms, string(han[i:i+2]))
+			}
+		}
+		han = han[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case isHanRune(r):
+			flushWord()
+			han = append(han, r)
+		case isWordRune(r):
+			flushHan()
+			word = append(word, unicode.ToLower(r))
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+
+	return terms
+}
+
+// termSimilarity 计算问题词项在切片中的覆盖率
+func termSimilarity(questionTerms []string, chunkTerms map[string]struct{}) float64 {
+	if len(questionTerms) == 0 {
+		return 0
+	}
+	matched := 0
+	for _, term := range questionTerms {
+		if _, ok := chunkTerms[term]; ok {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(questionTerms))
+}
+
+// termSet 将词项列表转换为集合
+func termSet(terms []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(terms))
+	for _, term := range terms {
+		set[term] = struct{}{}
+	}
+	return set
+}
+
+// cosineSimilarity 计算两个向量的余弦相似度，维度不一致时返回0
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// highlightText 用<em>标签标记文本中命中的问题词项
+func highlightText(text string, questionTerms []string) string {
+	if len(questionTerms) == 0 {
+		return text
+	}
+	terms := termSet(questionTerms)
+	runes := []rune(text)
+	marked := make([]bool, len(runes))
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case isHanRune(r):
+			if i+1 < len(runes) && isHanRune(runes[i+1]) {
+				if _, ok := terms[string(runes[i:i+2])]; ok {
+					marked[i], marked[i+1] = true, true
+				}
+			}
+			i++
+		case isWordRune(r):
+			j := i
+			for j < len(runes) && isWordRune(runes[j]) && !isHanRune(runes[j]) {
+				j++
+			}
+			if _, ok := terms[strings.ToLower(string(runes[i:j]))]; ok {
+				for k := i; k < j; k++ {
+					marked[k] = true
+				}
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+
+	var sb strings.Builder
+	for i, r := range runes {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			sb.WriteString("<em>")
+		}
+		sb.WriteRune(r)
+		if marked[i] && (i == len(runes)-1 || !marked[i+1]) {
+			sb.WriteString("</em>")
+		}
+	}
+	return sb.String()
+}
+
+func isHanRune(r rune) bool {
+	return unicode.Is(unicode.Han, r)
+}
+
+func isWordRune(r rune) bool {
+	return !isHanRune(r) && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
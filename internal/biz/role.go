@@ -0,0 +1,283 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+	"github.com/weetime/agent-matrix/internal/kit/cerrors"
+	"github.com/weetime/agent-matrix/internal/middleware"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// RoleCodeAdmin 内置管理员角色，拥有全部权限，超级管理员迁移后归入该角色
+const RoleCodeAdmin = "admin"
+
+var roleCodePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{1,31}$`)
+
+// Role 角色
+type Role struct {
+	ID          int64
+	Code        string
+	Name        string
+	Permissions []string
+	Remark      string
+	Builtin     bool // 内置角色不能删除、不能修改编码
+	Creator     int64
+	CreateDate  time.Time
+	Updater     int64
+	UpdateDate  time.Time
+}
+
+// RoleRepo 角色数据访问接口
+type RoleRepo interface {
+	List(ctx context.Context) ([]*Role, error)
+	GetByID(ctx context.Context, id int64) (*Role, error)
+	GetByCode(ctx context.Context, code string) (*Role, error)
+	Create(ctx context.Context, role *Role) (*Role, error)
+	Update(ctx context.Context, role *Role) error
+	Delete(ctx context.Context, id int64) error
+	ListByUserId(ctx context.Context, userId int64) ([]*Role, error)
+	SetUserRoles(ctx context.Context, userId int64, roleIds []int64) error
+	DeleteUserRoles(ctx context.Context, userId int64) error
+}
+
+// RoleUsecase 角色管理
+type RoleUsecase struct {
+	repo        RoleRepo
+	userRepo    UserRepo
+	handleError *cerrors.HandleError
+	log         *log.Helper
+}
+
+// NewRoleUsecase 创建RoleUsecase
+func NewRoleUsecase(repo RoleRepo, userRepo UserRepo, logger log.Logger) *RoleUsecase {
+	return &RoleUsecase{
+		repo:        repo,
+		userRepo:    userRepo,
+		handleError: cerrors.NewHandleError(logger),
+		log:         kit.LogHelper(logger),
+	}
+}
+
+// ListRoles 查询全部角色
+func (uc *RoleUsecase) ListRoles(ctx context.Context) ([]*Role, error) {
+	roles, err := uc.repo.List(ctx)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	return roles, nil
+}
+
+// CreateRole 创建角色，不能授予操作人自身没有的权限
+func (uc *RoleUsecase) CreateRole(ctx context.Context, operator *middleware.UserDetail, role *Role) (*Role, error) {
+	if !roleCodePattern.MatchString(role.Code) {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("角色编码只能包含小写字母、数字和下划线，以字母开头，长度2-32"))
+	}
+	if err := uc.checkPermissions(ctx, operator, role.Permissions); err != nil {
+		return nil, err
+	}
+	existing, err := uc.repo.GetByCode(ctx, role.Code)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if existing != nil {
+		return nil, uc.handleError.ErrAlreadyExists(ctx, fmt.Errorf("角色编码 %s 已存在", role.Code))
+	}
+
+	now := time.Now()
+	role.Permissions = normalizePermissions(role.Permissions)
+	role.Builtin = false
+	role.Creator = operator.ID
+	role.CreateDate = now
+	role.Updater = operator.ID
+	role.UpdateDate = now
+	created, err := uc.repo.Create(ctx, role)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	uc.log.Infof("创建角色, operator: %d, role: %s, permissions: %v", operator.ID, created.Code, created.Permissions)
+	return created, nil
+}
+
+// UpdateRole 修改角色名称、权限和备注，admin角色的权限不能修改
+func (uc *RoleUsecase) UpdateRole(ctx context.Context, operator *middleware.UserDetail, role *Role) error {
+	existing, err := uc.getRole(ctx, role.ID)
+	if err != nil {
+		return err
+	}
+	if existing.Code == RoleCodeAdmin {
+		return uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("不能修改内置管理员角色"))
+	}
+	// 修改前后的权限都不能超出操作人自身的权限
+	if err := uc.checkPermissions(ctx, operator, existing.Permissions); err != nil {
+		return err
+	}
+	if err := uc.checkPermissions(ctx, operator, role.Permissions); err != nil {
+		return err
+	}
+
+	existing.Name = role.Name
+	existing.Remark = role.Remark
+	existing.Permissions = normalizePermissions(role.Permissions)
+	existing.Updater = operator.ID
+	existing.UpdateDate = time.Now()
+	if err := uc.repo.Update(ctx, existing); err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	uc.log.Infof("修改角色, operator: %d, role: %s, permissions: %v", operator.ID, existing.Code, existing.Permissions)
+	return nil
+}
+
+// DeleteRole 删除角色及其用户关联，内置角色不能删除
+func (uc *RoleUsecase) DeleteRole(ctx context.Context, operator *middleware.UserDetail, id int64) error {
+	existing, err := uc.getRole(ctx, id)
+	if err != nil {
+		return err
+	}
+	if existing.Builtin {
+		return uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("内置角色不能删除"))
+	}
+	if err := uc.checkPermissions(ctx, operator, existing.Permissions); err != nil {
+		return err
+	}
+	if err := uc.repo.Delete(ctx, id); err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	uc.log.Infof("删除角色, operator: %d, role: %s", operator.ID, existing.Code)
+	return nil
+}
+
+// GetUserRoles 查询用户的角色
+func (uc *RoleUsecase) GetUserRoles(ctx context.Context, userId int64) ([]*Role, error) {
+	if _, err := uc.getUser(ctx, userId); err != nil {
+		return nil, err
+	}
+	roles, err := uc.repo.ListByUserId(ctx, userId)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	return roles, nil
+}
+
+// AssignUserRoles 设置用户的角色（整体替换），新增和移除的角色权限都不能超出操作人自身的权限
+func (uc *RoleUsecase) AssignUserRoles(ctx context.Context, operator *middleware.UserDetail, userId int64, roleIds []int64) error {
+	if userId == operator.ID {
+		return uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("不能修改自己的角色"))
+	}
+	if _, err := uc.getUser(ctx, userId); err != nil {
+		return err
+	}
+
+	current, err := uc.repo.ListByUserId(ctx, userId)
+	if err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	currentIds := make(map[int64]bool, len(current))
+	for _, role := range current {
+		currentIds[role.ID] = true
+	}
+
+	ids := make([]int64, 0, len(roleIds))
+	seen := make(map[int64]bool, len(roleIds))
+	for _, id := range roleIds {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		role, err := uc.getRole(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !currentIds[id] {
+			if err := uc.checkPermissions(ctx, operator, role.Permissions); err != nil {
+				return err
+			}
+		}
+		ids = append(ids, id)
+	}
+	for _, role := range current {
+		if !seen[role.ID] {
+			if err := uc.checkPermissions(ctx, operator, role.Permissions); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := uc.repo.SetUserRoles(ctx, userId, ids); err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	uc.log.Infof("设置用户角色, operator: %d, userId: %d, roleIds: %v", operator.ID, userId, ids)
+	return nil
+}
+
+// checkPermissions 校验权限编码有效，且操作人拥有其中每一项权限
+func (uc *RoleUsecase) checkPermissions(ctx context.Context, operator *middleware.UserDetail, permissions []string) error {
+	for _, permission := range permissions {
+		if !middleware.IsValidPermission(permission) {
+			return uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("无效的权限: %s", permission))
+		}
+		if !operator.HasPermission(permission) {
+			return uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("不能分配自己没有的权限: %s", permission))
+		}
+	}
+	return nil
+}
+
+// getRole 查询角色，不存在时返回NotFound
+func (uc *RoleUsecase) getRole(ctx context.Context, id int64) (*Role, error) {
+	role, err := uc.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if role == nil {
+		return nil, uc.handleError.ErrNotFound(ctx, fmt.Errorf("角色不存在"))
+	}
+	return role, nil
+}
+
+// getUser 查询用户，不存在时返回NotFound
+func (uc *RoleUsecase) getUser(ctx context.Context, userId int64) (*User, error) {
+	user, err := uc.userRepo.GetByUserId(ctx, userId)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if user == nil {
+		return nil, uc.handleError.ErrNotFound(ctx, fmt.Errorf("用户不存在"))
+	}
+	return user, nil
+}
+
+// resolvePermissions 合并用户角色的权限，权限只由角色决定，super_admin标记仅在迁移时转换为admin角色
+func resolvePermissions(roles []*Role) (codes []string, permissions []string) {
+	set := make(map[string]bool)
+	for _, role := range roles {
+		codes = append(codes, role.Code)
+		for _, permission := range role.Permissions {
+			set[permission] = true
+		}
+	}
+	for permission := range set {
+		permissions = append(permissions, permission)
+	}
+	sort.Strings(permissions)
+	return codes, permissions
+}
+
+// normalizePermissions 去重并排序
+func normalizePermissions(permissions []string) []string {
+	set := make(map[string]bool, len(permissions))
+	result := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		if !set[permission] {
+			set[permission] = true
+			result = append(result, permission)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,204 @@
+package biz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/weetime/agent-matrix/internal/middleware"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvePermissions(t *testing.T) {
+	editor := &Role{ID: 2, Code: "content_editor", Permissions: []string{"model:*", "dict:write"}}
+	viewer := &Role{ID: 3, Code: "viewer", Permissions: []string{"model:read", "dict:read"}}
+
+	tests := []struct {
+		name      string
+		roles     []*Role
+		wantCodes []string
+		wantPerms []string
+		allowed   []string
+		denied    []string
+	}{
+		{
+			name:      "合并多个角色的权限",
+			roles:     []*Role{editor, viewer},
+			wantCodes: []string{"content_editor", "viewer"},
+			wantPerms: []string{"dict:read", "dict:write", "model:*", "model:read"},
+			allowed:   []string{middleware.PermissionModelWrite, middleware.PermissionDictWrite},
+			denied:    []string{middleware.PermissionOTARead},
+		},
+		{
+			name:      "admin角色拥有全部权限",
+			roles:     []*Role{{ID: 1, Code: RoleCodeAdmin, Permissions: []string{middleware.PermissionAll}}},
+			wantCodes: []string{RoleCodeAdmin},
+			wantPerms: []string{middleware.PermissionAll},
+			allowed:   []string{middleware.PermissionRoleWrite},
+		},
+		{
+			// super_admin标记已迁移为admin角色，不再单独授予权限
+			name:   "没有角色",
+			denied: []string{middleware.PermissionRoleWrite},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codes, permissions := resolvePermissions(tt.roles)
+			require.Equal(t, tt.wantCodes, codes)
+			require.Equal(t, tt.wantPerms, permissions)
+
+			user := &middleware.UserDetail{Permissions: permissions}
+			for _, p := range tt.allowed {
+				require.True(t, user.HasPermission(p), p)
+			}
+			for _, p := range tt.denied {
+				require.False(t, user.HasPermission(p), p)
+			}
+		})
+	}
+}
+
+func TestIsValidPermission(t *testing.T) {
+	tests := []struct {
+		permission string
+		want       bool
+	}{
+		{permission: "ota:*", want: true},
+		{permission: "ota:delete", want: false},
+		{permission: "unknown:*", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.permission, func(t *testing.T) {
+			require.Equal(t, tt.want, middleware.IsValidPermission(tt.permission))
+		})
+	}
+}
+
+func TestRoleManagement(t *testing.T) {
+	ctx := context.Background()
+	roleRepo := &memoryRoleRepo{
+		roles: []*Role{
+			{ID: 1, Code: RoleCodeAdmin, Permissions: []string{middleware.PermissionAll}, Builtin: true},
+			{ID: 2, Code: "viewer", Permissions: []string{"model:read"}, Builtin: true},
+		},
+		userRoles: map[int64][]int64{1: {1}},
+	}
+	userRepo := &memoryUserRepo{users: map[string]*User{
+		"admin":  {ID: 1, Username: "admin"},
+		"lead":   {ID: 2, Username: "lead"},
+		"editor": {ID: 3, Username: "editor"},
+	}}
+	uc := NewRoleUsecase(roleRepo, userRepo, log.DefaultLogger)
+	admin := &middleware.UserDetail{ID: 1, Permissions: []string{middleware.PermissionAll}}
+	lead := &middleware.UserDetail{ID: 2, Permissions: []string{"role:*", "model:*"}}
+
+	_, err := uc.CreateRole(ctx, admin, &Role{Code: "Editor", Permissions: []string{"model:write"}})
+	require.Error(t, err, "编码格式")
+	_, err = uc.CreateRole(ctx, admin, &Role{Code: "editor", Permissions: []string{"model:delete"}})
+	require.Error(t, err, "无效的权限")
+	_, err = uc.CreateRole(ctx, lead, &Role{Code: "editor", Permissions: []string{"model:write", "ota:write"}})
+	require.Error(t, err, "不能分配自己没有的权限")
+
+	editor, err := uc.CreateRole(ctx, lead, &Role{Code: "editor", Name: "编辑", Permissions: []string{"model:write", "model:write", "model:read"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"model:read", "model:write"}, editor.Permissions)
+	_, err = uc.CreateRole(ctx, admin, &Role{Code: "editor"})
+	require.Error(t, err, "编码重复")
+
+	require.Error(t, uc.UpdateRole(ctx, admin, &Role{ID: 1, Permissions: []string{"model:read"}}), "admin角色不能修改")
+	require.Error(t, uc.DeleteRole(ctx, admin, 2), "内置角色不能删除")
+
+	// 只能分配或移除权限范围内的角色
+	require.Error(t, uc.AssignUserRoles(ctx, lead, 2, []int64{editor.ID}), "不能修改自己的角色")
+	require.Error(t, uc.AssignUserRoles(ctx, lead, 3, []int64{1}), "不能分配admin角色")
+	require.NoError(t, uc.AssignUserRoles(ctx, lead, 3, []int64{editor.ID, 2, editor.ID}))
+	roles, err := uc.GetUserRoles(ctx, 3)
+	require.NoError(t, err)
+	require.Len(t, roles, 2)
+	require.Error(t, uc.AssignUserRoles(ctx, lead, 1, nil), "不能移除admin角色")
+	require.NoError(t, uc.AssignUserRoles(ctx, admin, 3, nil))
+
+	require.NoError(t, uc.DeleteRole(ctx, lead, editor.ID))
+	require.Error(t, uc.AssignUserRoles(ctx, admin, 3, []int64{editor.ID}), "角色不存在")
+}
+
+// memoryRoleRepo 内存中的角色和用户角色
+type memoryRoleRepo struct {
+	roles     []*Role
+	userRoles map[int64][]int64
+}
+
+func (r *memoryRoleRepo) List(ctx context.Context) ([]*Role, error) {
+	return r.roles, nil
+}
+
+func (r *memoryRoleRepo) GetByID(ctx context.Context, id int64) (*Role, error) {
+	for _, role := range r.roles {
+		if role.ID == id {
+			return role, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryRoleRepo) GetByCode(ctx context.Context, code string) (*Role, error) {
+	for _, role := range r.roles {
+		if role.Code == code {
+			return role, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryRoleRepo) Create(ctx context.Context, role *Role) (*Role, error) {
+	role.ID = int64(len(r.roles) + 100)
+	r.roles = append(r.roles, role)
+	return role, nil
+}
+
+func (r *memoryRoleRepo) Update(ctx context.Context, role *Role) error {
+	return nil
+}
+
+func (r *memoryRoleRepo) Delete(ctx context.Context, id int64) error {
+	for i, role := range r.roles {
+		if role.ID == id {
+			r.roles = append(r.roles[:i], r.roles[i+1:]...)
+			break
+		}
+	}
+	for userId := range r.userRoles {
+		ids := r.userRoles[userId][:0]
+		for _, roleId := range r.userRoles[userId] {
+			if roleId != id {
+				ids = append(ids, roleId)
+			}
+		}
+		r.userRoles[userId] = ids
+	}
+	return nil
+}
+
+func (r *memoryRoleRepo) ListByUserId(ctx context.Context, userId int64) ([]*Role, error) {
+	var roles []*Role
+	for _, id := range r.userRoles[userId] {
+		role, _ := r.GetByID(ctx, id)
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+func (r *memoryRoleRepo) SetUserRoles(ctx context.Context, userId int64, roleIds []int64) error {
+	if r.userRoles == nil {
+		r.userRoles = make(map[int64][]int64)
+	}
+	r.userRoles[userId] = roleIds
+	return nil
+}
+
+func (r *memoryRoleRepo) DeleteUserRoles(ctx context.Context, userId int64) error {
+	delete(r.userRoles, userId)
+	return nil
+}
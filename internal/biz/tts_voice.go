@@ -47,20 +47,23 @@ type TtsVoiceRepo interface {
 
 // TtsVoiceUsecase 音色业务逻辑
 type TtsVoiceUsecase struct {
-	repo        TtsVoiceRepo
-	handleError *cerrors.HandleError
-	log         *log.Helper
+	repo           TtsVoiceRepo
+	configNotifier *ConfigNotifier
+	handleError    *cerrors.HandleError
+	log            *log.Helper
 }
 
 // NewTtsVoiceUsecase 创建音色用例
 func NewTtsVoiceUsecase(
 	repo TtsVoiceRepo,
+	configNotifier *ConfigNotifier,
 	logger log.Logger,
 ) *TtsVoiceUsecase {
 	return &TtsVoiceUsecase{
-		repo:        repo,
-		handleError: cerrors.NewHandleError(logger),
-		log:         kit.LogHelper(logger),
+		repo:           repo,
+		configNotifier: configNotifier,
+		handleError:    cerrors.NewHandleError(logger),
+		log:            kit.LogHelper(logger),
 	}
 }
 
@@ -115,7 +118,13 @@ func (uc *TtsVoiceUsecase) CreateTtsVoice(ctx context.Context, voice *TtsVoice)
 		voice.UpdateDate = time.Now()
 	}
 
-	return uc.repo.CreateTtsVoice(ctx, voice)
+	created, err := uc.repo.CreateTtsVoice(ctx, voice)
+	if err != nil {
+		return nil, err
+	}
+	uc.configNotifier.NotifyModel(ctx, created.TtsModelID, "tts_voice")
+
+	return created, nil
 }
 
 // UpdateTtsVoice 更新音色
@@ -136,7 +145,15 @@ func (uc *TtsVoiceUsecase) UpdateTtsVoice(ctx context.Context, voice *TtsVoice)
 	// 设置更新时间
 	voice.UpdateDate = time.Now()
 
-	return uc.repo.UpdateTtsVoice(ctx, voice)
+	if err := uc.repo.UpdateTtsVoice(ctx, voice); err != nil {
+		return err
+	}
+	uc.configNotifier.NotifyModel(ctx, existing.TtsModelID, "tts_voice")
+	if voice.TtsModelID != existing.TtsModelID {
+		uc.configNotifier.NotifyModel(ctx, voice.TtsModelID, "tts_voice")
+	}
+
+	return nil
 }
 
 // DeleteTtsVoice 批量删除音色
@@ -145,5 +162,20 @@ func (uc *TtsVoiceUsecase) DeleteTtsVoice(ctx context.Context, ids []string) err
 		return uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("音色ID列表不能为空"))
 	}
 
-	return uc.repo.DeleteTtsVoice(ctx, ids)
+	// 记录被删除音色所属的TTS模型，删除后通知节点
+	modelIds := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if voice, err := uc.repo.GetTtsVoiceByID(ctx, id); err == nil && voice != nil {
+			modelIds = append(modelIds, voice.TtsModelID)
+		}
+	}
+
+	if err := uc.repo.DeleteTtsVoice(ctx, ids); err != nil {
+		return err
+	}
+	for _, modelId := range kit.Uniq(modelIds) {
+		uc.configNotifier.NotifyModel(ctx, modelId, "tts_voice")
+	}
+
+	return nil
 }
@@ -26,14 +26,18 @@ type User struct {
 	UpdateDate time.Time `json:"update_date"`
 }
 
-// UserToken Token实体
+// UserToken Token实体，每次登录对应一条会话记录
 type UserToken struct {
-	ID         int64     `json:"id"`
-	UserID     int64     `json:"user_id"`
-	Token      string    `json:"token"`
-	ExpireDate time.Time `json:"expire_date"`
-	UpdateDate time.Time `json:"update_date"`
-	CreateDate time.Time `json:"create_date"`
+	ID           int64      `json:"id"`
+	UserID       int64      `json:"user_id"`
+	Token        string     `json:"token"`
+	ClientHash   string     `json:"client_hash"`
+	UserAgent    string     `json:"user_agent"`
+	IP           string     `json:"ip"`
+	LastActiveAt *time.Time `json:"last_active_at"`
+	ExpireDate   time.Time  `json:"expire_date"`
+	UpdateDate   time.Time  `json:"update_date"`
+	CreateDate   time.Time  `json:"create_date"`
 }
 
 // TokenDTO Token数据传输对象
@@ -41,6 +45,9 @@ type TokenDTO struct {
 	Token      string `json:"token"`
 	Expire     int32  `json:"expire"`
 	ClientHash string `json:"client_hash"`
+
+	// TwoFactor 需要双因素认证时不为空，此时Token为空
+	TwoFactor *TwoFactorChallenge `json:"two_factor,omitempty"`
 }
 
 // UserDetail 用户详情（用于返回给前端）
@@ -71,11 +78,23 @@ type UserRepo interface {
 
 // UserTokenRepo Token数据访问接口
 type UserTokenRepo interface {
-	GetByUserId(ctx context.Context, userId int64) (*UserToken, error)
 	GetByToken(ctx context.Context, token string) (*UserToken, error)
 	Save(ctx context.Context, token *UserToken) error
-	Update(ctx context.Context, token *UserToken) error
+
+	// ListActiveByUserId 查询用户未过期的会话，按最近访问时间倒序
+	ListActiveByUserId(ctx context.Context, userId int64, now time.Time) ([]*UserToken, error)
+
+	// Touch 更新会话的最近访问时间和IP
+	Touch(ctx context.Context, id int64, ip string, lastActiveAt time.Time) error
+
+	// Logout 使用户的全部会话失效
 	Logout(ctx context.Context, userId int64, expireDate time.Time) error
+
+	// ExpireByIds 使用户的指定会话失效，返回失效的会话数
+	ExpireByIds(ctx context.Context, userId int64, ids []int64, expireDate time.Time) (int, error)
+
+	// DeleteExpired 删除用户在指定时间前已过期的会话
+	DeleteExpired(ctx context.Context, userId int64, before time.Time) error
 }
 
 // ParamsService 系统参数服务接口
@@ -94,10 +113,16 @@ type CaptchaService interface {
 type UserUsecase struct {
 	userRepo       UserRepo
 	tokenRepo      UserTokenRepo
+	loginAuditRepo LoginAuditRepo
+	totpRepo       UserTOTPRepo
+	identityRepo   UserIdentityRepo
+	roleRepo       RoleRepo
 	paramsService  ParamsService
 	captchaService CaptchaService
 	deviceRepo     DeviceRepo
 	agentRepo      AgentRepo
+	redisClient    *kit.RedisClient
+	oidcProviders  *oidcProviderCache
 	handleError    *cerrors.HandleError
 	log            *log.Helper
 }
@@ -106,19 +131,30 @@ type UserUsecase struct {
 func NewUserUsecase(
 	userRepo UserRepo,
 	tokenRepo UserTokenRepo,
+	loginAuditRepo LoginAuditRepo,
+	totpRepo UserTOTPRepo,
+	identityRepo UserIdentityRepo,
+	roleRepo RoleRepo,
 	paramsService ParamsService,
 	captchaService CaptchaService,
 	deviceRepo DeviceRepo,
 	agentRepo AgentRepo,
+	redisClient *kit.RedisClient,
 	logger log.Logger,
 ) *UserUsecase {
 	return &UserUsecase{
 		userRepo:       userRepo,
 		tokenRepo:      tokenRepo,
+		loginAuditRepo: loginAuditRepo,
+		totpRepo:       totpRepo,
+		identityRepo:   identityRepo,
+		roleRepo:       roleRepo,
 		paramsService:  paramsService,
 		captchaService: captchaService,
 		deviceRepo:     deviceRepo,
 		agentRepo:      agentRepo,
+		redisClient:    redisClient,
+		oidcProviders:  newOIDCProviderCache(),
 		handleError:    cerrors.NewHandleError(logger),
 		log:            kit.LogHelper(logger),
 	}
@@ -158,8 +194,13 @@ type RetrievePasswordRequest struct {
 	CaptchaID string `json:"captcha_id"`
 }
 
-// Login 用户登录
+// Login 用户登录，账号或密码错误次数过多时临时锁定用户名或IP，启用双因素认证时只返回登录凭证
 func (uc *UserUsecase) Login(ctx context.Context, req *LoginRequest) (*TokenDTO, error) {
+	clientIP := middleware.GetClientInfo(ctx).IP
+	if err := uc.checkLoginAllowed(ctx, req.Username, clientIP); err != nil {
+		return nil, err
+	}
+
 	// SM2解密密码并验证验证码
 	actualPassword, err := kit.DecryptAndValidateCaptcha(
 		req.Password,
@@ -177,15 +218,25 @@ func (uc *UserUsecase) Login(ctx context.Context, req *LoginRequest) (*TokenDTO,
 		return nil, uc.handleError.ErrInternal(ctx, err)
 	}
 	if user == nil {
-		return nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("账号或密码错误"))
+		return nil, uc.loginFailed(ctx, req.Username, clientIP, fmt.Errorf("账号或密码错误"))
 	}
 
 	// 验证密码
 	if !kit.CheckPassword(actualPassword, user.Password) {
-		return nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("账号或密码错误"))
+		return nil, uc.loginFailed(ctx, req.Username, clientIP, fmt.Errorf("账号或密码错误"))
+	}
+
+	// 启用了双因素认证时先签发登录凭证，校验动态码后再生成Token，失败计数到那时才清空
+	challenge, err := uc.startTwoFactorLogin(ctx, user)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if challenge != nil {
+		return &TokenDTO{TwoFactor: challenge}, nil
 	}
+	uc.clearLoginFailures(ctx, req.Username)
 
-	// 生成Token
+	// 生成Token，每次登录创建独立的会话
 	tokenDTO, err := uc.createToken(ctx, user.ID)
 	if err != nil {
 		return nil, uc.handleError.ErrInternal(ctx, err)
@@ -279,10 +330,34 @@ func (uc *UserUsecase) Register(ctx context.Context, req *RegisterRequest) error
 	if err := uc.userRepo.Save(ctx, user); err != nil {
 		return uc.handleError.ErrInternal(ctx, err)
 	}
+	if user.SuperAdmin == 1 {
+		if err := uc.assignAdminRole(ctx, user.Username); err != nil {
+			return uc.handleError.ErrInternal(ctx, err)
+		}
+	}
 
 	return nil
 }
 
+// assignAdminRole 为第一个注册的用户分配admin角色，权限由角色决定
+func (uc *UserUsecase) assignAdminRole(ctx context.Context, username string) error {
+	user, err := uc.userRepo.GetByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("用户%s不存在", username)
+	}
+	role, err := uc.roleRepo.GetByCode(ctx, RoleCodeAdmin)
+	if err != nil {
+		return err
+	}
+	if role == nil {
+		return fmt.Errorf("admin角色不存在")
+	}
+	return uc.roleRepo.SetUserRoles(ctx, user.ID, []int64{role.ID})
+}
+
 // GetUserInfo 获取当前用户信息
 func (uc *UserUsecase) GetUserInfo(ctx context.Context, userId int64) (*UserDetail, error) {
 	user, err := uc.userRepo.GetByUserId(ctx, userId)
@@ -293,17 +368,18 @@ func (uc *UserUsecase) GetUserInfo(ctx context.Context, userId int64) (*UserDeta
 		return nil, uc.handleError.ErrNotFound(ctx, fmt.Errorf("用户不存在"))
 	}
 
-	// 获取Token
-	tokenEntity, _ := uc.tokenRepo.GetByUserId(ctx, userId)
-	token := ""
-	if tokenEntity != nil {
-		token = tokenEntity.Token
+	// 获取当前会话的Token
+	token, _ := middleware.GetTokenFromContext(ctx)
+
+	_, permissions, err := uc.userPermissions(ctx, user)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
 	}
 
 	return &UserDetail{
 		ID:         user.ID,
 		Username:   user.Username,
-		SuperAdmin: user.SuperAdmin,
+		SuperAdmin: superAdminFlag(permissions),
 		Status:     user.Status,
 		Token:      token,
 	}, nil
@@ -416,101 +492,6 @@ func (uc *UserUsecase) RetrievePassword(ctx context.Context, req *RetrievePasswo
 	return nil
 }
 
-// createToken 创建Token
-func (uc *UserUsecase) createToken(ctx context.Context, userId int64) (*TokenDTO, error) {
-	now := time.Now()
-	expireTime := now.Add(kit.TokenExpireSeconds * time.Second)
-
-	// 查询是否已有Token
-	tokenEntity, err := uc.tokenRepo.GetByUserId(ctx, userId)
-	if err != nil {
-		return nil, err
-	}
-
-	var token string
-	if tokenEntity != nil && tokenEntity.ExpireDate.After(now) {
-		// Token未过期，复用现有Token
-		token = tokenEntity.Token
-		// 更新过期时间
-		tokenEntity.ExpireDate = expireTime
-		tokenEntity.UpdateDate = now
-		if err := uc.tokenRepo.Update(ctx, tokenEntity); err != nil {
-			return nil, err
-		}
-	} else {
-		// 生成新Token
-		token = kit.GenerateToken()
-		newToken := &UserToken{
-			UserID:     userId,
-			Token:      token,
-			ExpireDate: expireTime,
-			CreateDate: now,
-			UpdateDate: now,
-		}
-
-		if tokenEntity != nil {
-			// 更新现有Token记录
-			tokenEntity.Token = token
-			tokenEntity.ExpireDate = expireTime
-			tokenEntity.UpdateDate = now
-			if err := uc.tokenRepo.Update(ctx, tokenEntity); err != nil {
-				return nil, err
-			}
-		} else {
-			// 创建新Token记录
-			if err := uc.tokenRepo.Save(ctx, newToken); err != nil {
-				return nil, err
-			}
-		}
-	}
-
-	return &TokenDTO{
-		Token:      token,
-		Expire:     int32(kit.TokenExpireSeconds),
-		ClientHash: "", // TODO: 从请求中获取客户端指纹
-	}, nil
-}
-
-// GetUserByToken 根据Token获取用户（实现TokenService接口）
-// 参考Java的Oauth2Realm.doGetAuthenticationInfo实现
-func (uc *UserUsecase) GetUserByToken(ctx context.Context, token string) (*middleware.UserDetail, error) {
-	// 根据accessToken，查询用户token信息
-	tokenEntity, err := uc.tokenRepo.GetByToken(ctx, token)
-	if err != nil {
-		return nil, err
-	}
-	if tokenEntity == nil {
-		return nil, fmt.Errorf("token not found")
-	}
-
-	// token失效检查（参考Java实现）
-	now := time.Now()
-	if tokenEntity.ExpireDate.Before(now) {
-		return nil, fmt.Errorf("token expired")
-	}
-
-	// 查询用户信息
-	user, err := uc.userRepo.GetByUserId(ctx, tokenEntity.UserID)
-	if err != nil {
-		return nil, err
-	}
-	if user == nil {
-		return nil, fmt.Errorf("user not found")
-	}
-
-	// 账号状态检查（参考Java的Oauth2Realm实现）
-	// status为0表示账号被锁定，会在中间件中处理
-	// 这里只返回用户信息，状态检查在中间件中进行
-
-	return &middleware.UserDetail{
-		ID:         user.ID,
-		Username:   user.Username,
-		SuperAdmin: user.SuperAdmin,
-		Status:     user.Status,
-		Token:      token,
-	}, nil
-}
-
 // AdminPageUserVO 管理员分页用户VO
 type AdminPageUserVO struct {
 	UserID      string    `json:"userid"`
@@ -566,8 +547,12 @@ func (uc *UserUsecase) PageAdminUsers(ctx context.Context, mobile *string, page
 	return voList, total, nil
 }
 
-// ResetPassword 重置用户密码
-func (uc *UserUsecase) ResetPassword(ctx context.Context, userId int64) (string, error) {
+// ResetPassword 重置用户密码，目标用户的权限不能超出操作人
+func (uc *UserUsecase) ResetPassword(ctx context.Context, operator *middleware.UserDetail, userId int64) (string, error) {
+	if err := uc.checkManageable(ctx, operator, userId); err != nil {
+		return "", err
+	}
+
 	// 生成随机密码
 	password := kit.GenerateRandomPassword()
 
@@ -586,8 +571,12 @@ func (uc *UserUsecase) ResetPassword(ctx context.Context, userId int64) (string,
 	return password, nil
 }
 
-// DeleteUserById 删除用户（级联删除设备和智能体）
-func (uc *UserUsecase) DeleteUserById(ctx context.Context, userId int64) error {
+// DeleteUserById 删除用户（级联删除设备和智能体），目标用户的权限不能超出操作人
+func (uc *UserUsecase) DeleteUserById(ctx context.Context, operator *middleware.UserDetail, userId int64) error {
+	if err := uc.checkManageable(ctx, operator, userId); err != nil {
+		return err
+	}
+
 	// 删除设备
 	if err := uc.deviceRepo.DeleteByUserId(ctx, userId); err != nil {
 		uc.log.Warnf("Failed to delete devices for user %d: %v", userId, err)
@@ -600,6 +589,21 @@ func (uc *UserUsecase) DeleteUserById(ctx context.Context, userId int64) error {
 		// 继续执行，不中断删除流程
 	}
 
+	// 删除双因素认证绑定
+	if err := uc.totpRepo.Delete(ctx, userId); err != nil {
+		uc.log.Warnf("Failed to delete two-factor binding for user %d: %v", userId, err)
+	}
+
+	// 删除单点登录关联的外部身份
+	if err := uc.identityRepo.DeleteByUserId(ctx, userId); err != nil {
+		uc.log.Warnf("Failed to delete external identities for user %d: %v", userId, err)
+	}
+
+	// 删除用户角色关联
+	if err := uc.roleRepo.DeleteUserRoles(ctx, userId); err != nil {
+		uc.log.Warnf("Failed to delete roles for user %d: %v", userId, err)
+	}
+
 	// 删除用户
 	if err := uc.userRepo.DeleteUserById(ctx, userId); err != nil {
 		return uc.handleError.ErrInternal(ctx, err)
@@ -608,15 +612,22 @@ func (uc *UserUsecase) DeleteUserById(ctx context.Context, userId int64) error {
 	return nil
 }
 
-// ChangeUserStatus 批量修改用户状态
-func (uc *UserUsecase) ChangeUserStatus(ctx context.Context, status int32, userIds []string) error {
+// ChangeUserStatus 批量修改用户状态，任一目标用户的权限超出操作人时整批拒绝
+func (uc *UserUsecase) ChangeUserStatus(ctx context.Context, operator *middleware.UserDetail, status int32, userIds []string) error {
+	ids := make([]int64, 0, len(userIds))
 	for _, userIdStr := range userIds {
 		userId, err := strconv.ParseInt(userIdStr, 10, 64)
 		if err != nil {
 			uc.log.Warnf("Invalid user ID: %s", userIdStr)
 			continue
 		}
+		if err := uc.checkManageable(ctx, operator, userId); err != nil {
+			return err
+		}
+		ids = append(ids, userId)
+	}
 
+	for _, userId := range ids {
 		if err := uc.userRepo.UpdateUserStatus(ctx, userId, status); err != nil {
 			return uc.handleError.ErrInternal(ctx, fmt.Errorf("failed to update user %d status: %w", userId, err))
 		}
@@ -0,0 +1,263 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+)
+
+const (
+	loginMaxFailuresParam   = "server.login_max_failures"    // 同一用户名连续失败多少次后锁定
+	loginIPMaxFailuresParam = "server.login_ip_max_failures" // 同一IP失败多少次后锁定
+	loginLockMinutesParam   = "server.login_lock_minutes"    // 锁定时长（分钟）
+
+	defaultLoginMaxFailures   = 5
+	defaultLoginIPMaxFailures = 20
+	defaultLoginLockMinutes   = 15
+
+	loginFailWindow   = time.Hour // 失败次数的统计窗口，从第一次失败开始计算
+	loginFreeFailures = 2         // 不需要等待的失败次数，之后每次失败的等待时间翻倍
+	loginMaxDelay     = 30 * time.Second
+)
+
+// 登录锁定审计事件
+const (
+	LoginAuditEventLock   = "lock"
+	LoginAuditEventUnlock = "unlock"
+)
+
+// 登录锁定范围
+const (
+	LoginAuditScopeUser = "user"
+	LoginAuditScopeIP   = "ip"
+)
+
+// LoginAudit 登录锁定审计记录
+type LoginAudit struct {
+	ID          int64
+	Event       string // lock/unlock
+	Scope       string // user/ip
+	Username    string
+	IP          string
+	FailCount   int   // 锁定时累计的失败次数
+	LockSeconds int   // 锁定时长
+	Operator    int64 // 解锁的管理员ID，系统锁定时为0
+	CreateDate  time.Time
+}
+
+// LoginAuditRepo 登录锁定审计数据访问接口
+type LoginAuditRepo interface {
+	Create(ctx context.Context, audit *LoginAudit) error
+}
+
+// loginPolicy 登录失败的锁定策略
+type loginPolicy struct {
+	maxFailures   int64
+	ipMaxFailures int64
+	lockDuration  time.Duration
+}
+
+// loadLoginPolicy 从系统参数读取锁定策略，未配置或配置无效时使用默认值
+func (uc *UserUsecase) loadLoginPolicy() *loginPolicy {
+	value := func(code string, def int) int64 {
+		if uc.paramsService == nil {
+			return int64(def)
+		}
+		raw, _ := uc.paramsService.GetValue(code, true)
+		return int64(parsePositiveInt(raw, def))
+	}
+	return &loginPolicy{
+		maxFailures:   value(loginMaxFailuresParam, defaultLoginMaxFailures),
+		ipMaxFailures: value(loginIPMaxFailuresParam, defaultLoginIPMaxFailures),
+		lockDuration:  time.Duration(value(loginLockMinutesParam, defaultLoginLockMinutes)) * time.Minute,
+	}
+}
+
+// parsePositiveInt 解析正整数，无效时返回默认值
+func parsePositiveInt(value string, def int) int {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n <= 0 {
+		return def
+	}
+	return n
+}
+
+// loginDelay 连续失败failures次后，再次尝试前需要等待的时间
+func loginDelay(failures int64) time.Duration {
+	if failures <= loginFreeFailures {
+		return 0
+	}
+	delay := loginMaxDelay
+	if shift := failures - loginFreeFailures - 1; shift < 5 {
+		delay = min(time.Second<<shift, loginMaxDelay)
+	}
+	return delay
+}
+
+// checkLoginAllowed 登录前检查用户名和IP是否被锁定或仍在等待期内
+func (uc *UserUsecase) checkLoginAllowed(ctx context.Context, username, ip string) error {
+	if uc.redisClient == nil {
+		return nil
+	}
+	client := uc.redisClient.GetClient()
+
+	lockKeys := []string{kit.GetLoginLockUserKey(username)}
+	if ip != "" {
+		lockKeys = append(lockKeys, kit.GetLoginLockIPKey(ip))
+	}
+	for _, key := range lockKeys {
+		ttl, err := client.PTTL(ctx, key).Result()
+		if err != nil {
+			uc.log.Warnf("查询登录锁定状态失败: %v", err)
+			continue
+		}
+		if ttl > 0 {
+			minutes := int((ttl + time.Minute - 1) / time.Minute)
+			return uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("登录失败次数过多，请%d分钟后重试", minutes))
+		}
+	}
+
+	ttl, err := client.PTTL(ctx, kit.GetLoginDelayKey(username)).Result()
+	if err != nil {
+		uc.log.Warnf("查询登录等待时间失败: %v", err)
+		return nil
+	}
+	if ttl > 0 {
+		seconds := int((ttl + time.Second - 1) / time.Second)
+		return uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("登录尝试过于频繁，请%d秒后重试", seconds))
+	}
+	return nil
+}
+
+// loginFailed 记录一次登录校验失败，达到上限时锁定用户名或IP，未锁定时返回reason
+func (uc *UserUsecase) loginFailed(ctx context.Context, username, ip string, reason error) error {
+	invalid := uc.handleError.ErrPermissionDenied(ctx, reason)
+	if uc.redisClient == nil {
+		return invalid
+	}
+	policy := uc.loadLoginPolicy()
+	client := uc.redisClient.GetClient()
+
+	locked := false
+	userFailures := uc.incrLoginFailures(ctx, kit.GetLoginFailUserKey(username))
+	if userFailures >= policy.maxFailures {
+		uc.lockLogin(ctx, LoginAuditScopeUser, username, ip, userFailures, policy.lockDuration)
+		locked = true
+	} else if delay := loginDelay(userFailures); delay > 0 {
+		if err := client.Set(ctx, kit.GetLoginDelayKey(username), userFailures, delay).Err(); err != nil {
+			uc.log.Warnf("设置登录等待时间失败: %v", err)
+		}
+	}
+
+	if ip != "" {
+		ipFailures := uc.incrLoginFailures(ctx, kit.GetLoginFailIPKey(ip))
+		if ipFailures >= policy.ipMaxFailures {
+			uc.lockLogin(ctx, LoginAuditScopeIP, username, ip, ipFailures, policy.lockDuration)
+			locked = true
+		}
+	}
+
+	if locked {
+		return uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("登录失败次数过多，已临时锁定%d分钟", int(policy.lockDuration/time.Minute)))
+	}
+	return invalid
+}
+
+// incrLoginFailures 失败次数加一，计数在统计窗口后过期
+func (uc *UserUsecase) incrLoginFailures(ctx context.Context, key string) int64 {
+	client := uc.redisClient.GetClient()
+	count, err := client.Incr(ctx, key).Result()
+	if err != nil {
+		uc.log.Warnf("记录登录失败次数失败: %v", err)
+		return 0
+	}
+	if count == 1 {
+		client.Expire(ctx, key, loginFailWindow)
+	}
+	return count
+}
+
+// lockLogin 锁定用户名或IP，清空对应的失败计数并记录审计
+func (uc *UserUsecase) lockLogin(ctx context.Context, scope, username, ip string, failures int64, duration time.Duration) {
+	lockKey, clearKeys := kit.GetLoginLockUserKey(username), []string{kit.GetLoginFailUserKey(username), kit.GetLoginDelayKey(username)}
+	if scope == LoginAuditScopeIP {
+		lockKey, clearKeys = kit.GetLoginLockIPKey(ip), []string{kit.GetLoginFailIPKey(ip)}
+	}
+	if err := uc.redisClient.Set(ctx, lockKey, failures, duration); err != nil {
+		uc.log.Errorf("锁定登录失败, scope: %s, username: %s, ip: %s: %v", scope, username, ip, err)
+		return
+	}
+	uc.redisClient.Delete(ctx, clearKeys...)
+
+	uc.log.Warnf("登录失败次数过多已锁定, scope: %s, username: %s, ip: %s, failures: %d", scope, username, ip, failures)
+	uc.saveLoginAudit(ctx, &LoginAudit{
+		Event:       LoginAuditEventLock,
+		Scope:       scope,
+		Username:    username,
+		IP:          ip,
+		FailCount:   int(failures),
+		LockSeconds: int(duration / time.Second),
+	})
+}
+
+// clearLoginFailures 登录成功后清空用户名的失败计数，IP的计数保留到窗口结束
+func (uc *UserUsecase) clearLoginFailures(ctx context.Context, username string) {
+	if uc.redisClient == nil {
+		return
+	}
+	if err := uc.redisClient.Delete(ctx, kit.GetLoginFailUserKey(username), kit.GetLoginDelayKey(username)); err != nil {
+		uc.log.Warnf("清空登录失败次数失败: %v", err)
+	}
+}
+
+// UnlockLogin 管理员解除用户名和/或IP的登录锁定，同时清空失败计数
+func (uc *UserUsecase) UnlockLogin(ctx context.Context, operator int64, username, ip string) error {
+	username, ip = strings.TrimSpace(username), strings.TrimSpace(ip)
+	if username == "" && ip == "" {
+		return uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("用户名和IP不能同时为空"))
+	}
+
+	if username != "" {
+		if err := uc.redisClient.Delete(ctx,
+			kit.GetLoginLockUserKey(username),
+			kit.GetLoginFailUserKey(username),
+			kit.GetLoginDelayKey(username),
+		); err != nil {
+			return uc.handleError.ErrInternal(ctx, err)
+		}
+		uc.saveLoginAudit(ctx, &LoginAudit{
+			Event:    LoginAuditEventUnlock,
+			Scope:    LoginAuditScopeUser,
+			Username: username,
+			IP:       ip,
+			Operator: operator,
+		})
+	}
+	if ip != "" {
+		if err := uc.redisClient.Delete(ctx, kit.GetLoginLockIPKey(ip), kit.GetLoginFailIPKey(ip)); err != nil {
+			return uc.handleError.ErrInternal(ctx, err)
+		}
+		uc.saveLoginAudit(ctx, &LoginAudit{
+			Event:    LoginAuditEventUnlock,
+			Scope:    LoginAuditScopeIP,
+			Username: username,
+			IP:       ip,
+			Operator: operator,
+		})
+	}
+	return nil
+}
+
+// saveLoginAudit 保存审计记录，失败只记录日志
+func (uc *UserUsecase) saveLoginAudit(ctx context.Context, audit *LoginAudit) {
+	if uc.loginAuditRepo == nil {
+		return
+	}
+	if err := uc.loginAuditRepo.Create(ctx, audit); err != nil {
+		uc.log.Errorf("保存登录审计记录失败, event: %s, scope: %s: %v", audit.Event, audit.Scope, err)
+	}
+}
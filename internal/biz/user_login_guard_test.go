@@ -0,0 +1,39 @@
+package biz
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginDelay(t *testing.T) {
+	require.Zero(t, loginDelay(1))
+	require.Zero(t, loginDelay(loginFreeFailures))
+	require.Equal(t, time.Second, loginDelay(3))
+	require.Equal(t, 2*time.Second, loginDelay(4))
+	require.Equal(t, 16*time.Second, loginDelay(7))
+	require.Equal(t, loginMaxDelay, loginDelay(8))
+	require.Equal(t, loginMaxDelay, loginDelay(100))
+}
+
+func TestLoadLoginPolicy(t *testing.T) {
+	params := staticParamsService{
+		loginMaxFailuresParam: "3",
+		loginLockMinutesParam: "invalid",
+	}
+	uc := NewUserUsecase(nil, nil, nil, nil, nil, nil, params, nil, nil, nil, nil, log.DefaultLogger)
+
+	policy := uc.loadLoginPolicy()
+	require.Equal(t, int64(3), policy.maxFailures)
+	require.Equal(t, int64(defaultLoginIPMaxFailures), policy.ipMaxFailures)
+	require.Equal(t, defaultLoginLockMinutes*time.Minute, policy.lockDuration)
+}
+
+// staticParamsService 固定值的系统参数
+type staticParamsService map[string]string
+
+func (p staticParamsService) GetValue(paramCode string, isCache bool) (string, error) {
+	return p[paramCode], nil
+}
@@ -0,0 +1,422 @@
+package biz
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+	"github.com/weetime/agent-matrix/internal/middleware"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oidcEnabledParam       = "server.oidc_enabled"
+	oidcIssuerParam        = "server.oidc_issuer"
+	oidcClientIDParam      = "server.oidc_client_id"
+	oidcClientSecretParam  = "server.oidc_client_secret"
+	oidcRedirectURIParam   = "server.oidc_redirect_uri"
+	oidcScopesParam        = "server.oidc_scopes"
+	oidcUsernameClaimParam = "server.oidc_username_claim"
+	oidcAutoProvisionParam = "server.oidc_auto_provision" // 首次登录时自动创建本地账号
+	oidcLinkExistingParam  = "server.oidc_link_existing"  // 首次登录时按已验证的邮箱关联已有账号
+
+	defaultOIDCScopes        = "openid profile email"
+	defaultOIDCUsernameClaim = "preferred_username"
+
+	oidcStateTTL       = 10 * time.Minute // 从跳转身份提供方到回调的时限
+	oidcHTTPTimeout    = 10 * time.Second
+	oidcUsernameMaxLen = 50
+)
+
+// UserIdentity 本地用户关联的外部身份
+type UserIdentity struct {
+	ID          string
+	UserID      int64
+	Issuer      string
+	Subject     string
+	Email       string
+	LastLoginAt *time.Time
+	CreateDate  time.Time
+}
+
+// UserIdentityRepo 外部身份数据访问接口
+type UserIdentityRepo interface {
+	GetBySubject(ctx context.Context, issuer, subject string) (*UserIdentity, error)
+	Create(ctx context.Context, identity *UserIdentity) error
+	TouchLogin(ctx context.Context, id string, at time.Time) error
+	DeleteByUserId(ctx context.Context, userId int64) error
+}
+
+// OIDCAuthorization 跳转身份提供方的授权地址
+type OIDCAuthorization struct {
+	URL   string
+	State string
+}
+
+// oidcSettings 单点登录配置
+type oidcSettings struct {
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	RedirectURI   string
+	Scopes        []string
+	UsernameClaim string
+	AutoProvision bool
+	LinkExisting  bool
+}
+
+// oauth2Config 授权码模式的客户端配置
+func (s *oidcSettings) oauth2Config(provider *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     s.ClientID,
+		ClientSecret: s.ClientSecret,
+		RedirectURL:  s.RedirectURI,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       s.Scopes,
+	}
+}
+
+// oidcLoginState 跳转身份提供方前保存的登录状态，回调时校验并只能使用一次
+type oidcLoginState struct {
+	Issuer       string `json:"issuer"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	ClientHash   string `json:"client_hash"`
+}
+
+// oidcProviderCache 按Issuer缓存身份提供方的发现文档和签名公钥
+type oidcProviderCache struct {
+	mu        sync.Mutex
+	client    *http.Client
+	providers map[string]*oidc.Provider
+}
+
+func newOIDCProviderCache() *oidcProviderCache {
+	return &oidcProviderCache{
+		client:    &http.Client{Timeout: oidcHTTPTimeout},
+		providers: make(map[string]*oidc.Provider),
+	}
+}
+
+// get 获取身份提供方，首次使用时请求发现文档
+func (c *oidcProviderCache) get(issuer string) (*oidc.Provider, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if provider, ok := c.providers[issuer]; ok {
+		return provider, nil
+	}
+	// 公钥在后续校验时按需刷新，不能绑定请求的context
+	provider, err := oidc.NewProvider(c.context(context.Background()), issuer)
+	if err != nil {
+		return nil, err
+	}
+	c.providers[issuer] = provider
+	return provider, nil
+}
+
+// context 请求身份提供方时使用带超时的HTTP客户端
+func (c *oidcProviderCache) context(ctx context.Context) context.Context {
+	return oidc.ClientContext(ctx, c.client)
+}
+
+// OIDCEnabled 是否开启单点登录，用于登录页展示入口
+func (uc *UserUsecase) OIDCEnabled() bool {
+	value, _ := uc.paramsService.GetValue(oidcEnabledParam, true)
+	return value == "true"
+}
+
+// loadOIDCSettings 读取单点登录配置，未开启或配置不完整时返回错误
+func (uc *UserUsecase) loadOIDCSettings(ctx context.Context) (*oidcSettings, error) {
+	if !uc.OIDCEnabled() {
+		return nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("未开启单点登录"))
+	}
+	value := func(code, def string) string {
+		v, _ := uc.paramsService.GetValue(code, true)
+		if v = strings.TrimSpace(v); v == "" {
+			return def
+		}
+		return v
+	}
+
+	settings := &oidcSettings{
+		Issuer:        value(oidcIssuerParam, ""),
+		ClientID:      value(oidcClientIDParam, ""),
+		ClientSecret:  value(oidcClientSecretParam, ""),
+		RedirectURI:   value(oidcRedirectURIParam, ""),
+		Scopes:        strings.Fields(value(oidcScopesParam, defaultOIDCScopes)),
+		UsernameClaim: value(oidcUsernameClaimParam, defaultOIDCUsernameClaim),
+		AutoProvision: value(oidcAutoProvisionParam, "false") == "true",
+		LinkExisting:  value(oidcLinkExistingParam, "false") == "true",
+	}
+	if settings.Issuer == "" || settings.ClientID == "" || settings.RedirectURI == "" {
+		return nil, uc.handleError.ErrInternal(ctx, fmt.Errorf("单点登录配置不完整，请配置Issuer、Client ID和回调地址"))
+	}
+	if !slices.Contains(settings.Scopes, oidc.ScopeOpenID) {
+		settings.Scopes = append([]string{oidc.ScopeOpenID}, settings.Scopes...)
+	}
+	return settings, nil
+}
+
+// StartOIDCLogin 生成跳转身份提供方的授权地址（授权码模式+PKCE）
+func (uc *UserUsecase) StartOIDCLogin(ctx context.Context) (*OIDCAuthorization, error) {
+	settings, err := uc.loadOIDCSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := uc.oidcProviders.get(settings.Issuer)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, fmt.Errorf("获取身份提供方配置失败: %w", err))
+	}
+
+	state := kit.GenerateToken()
+	loginState := &oidcLoginState{
+		Issuer:       settings.Issuer,
+		Nonce:        kit.GenerateToken(),
+		CodeVerifier: oauth2.GenerateVerifier(),
+		ClientHash:   middleware.GetClientInfo(ctx).Hash(),
+	}
+	if err := uc.redisClient.SetObject(ctx, kit.GetOIDCStateKey(state), loginState, oidcStateTTL); err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	url := settings.oauth2Config(provider).AuthCodeURL(state,
+		oidc.Nonce(loginState.Nonce),
+		oauth2.S256ChallengeOption(loginState.CodeVerifier),
+	)
+	return &OIDCAuthorization{URL: url, State: state}, nil
+}
+
+// CompleteOIDCLogin 用授权码换取并校验ID Token，映射到本地用户后生成Token
+func (uc *UserUsecase) CompleteOIDCLogin(ctx context.Context, state, code string) (*TokenDTO, error) {
+	settings, err := uc.loadOIDCSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	loginState, err := uc.takeOIDCLoginState(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	if loginState.Issuer != settings.Issuer || loginState.ClientHash != middleware.GetClientInfo(ctx).Hash() {
+		return nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("登录状态无效或已过期，请重新登录"))
+	}
+
+	idToken, claims, err := uc.exchangeOIDCCode(ctx, settings, loginState, code)
+	if err != nil {
+		return nil, err
+	}
+	user, err := uc.resolveOIDCUser(ctx, settings, idToken.Issuer, idToken.Subject, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// 启用了双因素认证的账号同样需要校验动态码
+	challenge, err := uc.startTwoFactorLogin(ctx, user)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if challenge != nil {
+		return &TokenDTO{TwoFactor: challenge}, nil
+	}
+
+	tokenDTO, err := uc.createToken(ctx, user.ID)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	return tokenDTO, nil
+}
+
+// takeOIDCLoginState 读取并删除登录状态，防止回调被重放
+func (uc *UserUsecase) takeOIDCLoginState(ctx context.Context, state string) (*oidcLoginState, error) {
+	invalid := uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("登录状态无效或已过期，请重新登录"))
+	if state == "" {
+		return nil, invalid
+	}
+	raw, err := uc.redisClient.GetClient().GetDel(ctx, kit.GetOIDCStateKey(state)).Result()
+	if err == redis.Nil {
+		return nil, invalid
+	}
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	var loginState oidcLoginState
+	if err := json.Unmarshal([]byte(raw), &loginState); err != nil {
+		return nil, invalid
+	}
+	return &loginState, nil
+}
+
+// exchangeOIDCCode 用授权码换取ID Token，校验签名、受众、有效期和nonce后返回声明
+func (uc *UserUsecase) exchangeOIDCCode(ctx context.Context, settings *oidcSettings, loginState *oidcLoginState, code string) (*oidc.IDToken, map[string]interface{}, error) {
+	provider, err := uc.oidcProviders.get(settings.Issuer)
+	if err != nil {
+		return nil, nil, uc.handleError.ErrInternal(ctx, fmt.Errorf("获取身份提供方配置失败: %w", err))
+	}
+	httpCtx := uc.oidcProviders.context(ctx)
+	token, err := settings.oauth2Config(provider).Exchange(httpCtx, code, oauth2.VerifierOption(loginState.CodeVerifier))
+	if err != nil {
+		return nil, nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("授权码校验失败: %w", err))
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("身份提供方未返回ID Token"))
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: settings.ClientID}).Verify(httpCtx, rawIDToken)
+	if err != nil {
+		return nil, nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("ID Token校验失败: %w", err))
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(loginState.Nonce)) != 1 {
+		return nil, nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("ID Token校验失败: nonce不匹配"))
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("解析ID Token失败: %w", err))
+	}
+	return idToken, claims, nil
+}
+
+// resolveOIDCUser 按issuer+sub查找关联的本地用户，首次登录时按配置关联已有账号或自动创建
+func (uc *UserUsecase) resolveOIDCUser(ctx context.Context, settings *oidcSettings, issuer, subject string, claims map[string]interface{}) (*User, error) {
+	identity, err := uc.identityRepo.GetBySubject(ctx, issuer, subject)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	now := time.Now()
+	if identity != nil {
+		user, err := uc.userRepo.GetByUserId(ctx, identity.UserID)
+		if err != nil {
+			return nil, uc.handleError.ErrInternal(ctx, err)
+		}
+		if user == nil {
+			return nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("关联的本地账号不存在，请联系管理员"))
+		}
+		if err := uc.identityRepo.TouchLogin(ctx, identity.ID, now); err != nil {
+			uc.log.Warnf("更新单点登录时间失败, identityId: %s: %v", identity.ID, err)
+		}
+		return user, nil
+	}
+
+	username := strings.TrimSpace(claimString(claims, settings.UsernameClaim))
+	if username == "" {
+		return nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("ID Token中缺少用户名声明%s", settings.UsernameClaim))
+	}
+	if len([]rune(username)) > oidcUsernameMaxLen {
+		return nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("用户名超过%d个字符", oidcUsernameMaxLen))
+	}
+
+	// 用户名声明可由用户自行修改，只按已验证的邮箱关联已有账号
+	user, err := uc.linkableOIDCUser(ctx, settings, claims)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		existing, err := uc.userRepo.GetByUsername(ctx, username)
+		if err != nil {
+			return nil, uc.handleError.ErrInternal(ctx, err)
+		}
+		switch {
+		case existing != nil:
+			return nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("用户名%s已被本地账号使用，请联系管理员关联", username))
+		case settings.AutoProvision:
+			if user, err = uc.provisionOIDCUser(ctx, username); err != nil {
+				return nil, uc.handleError.ErrInternal(ctx, err)
+			}
+		default:
+			return nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("账号%s未开通，请联系管理员", username))
+		}
+	}
+
+	if err := uc.identityRepo.Create(ctx, &UserIdentity{
+		UserID:      user.ID,
+		Issuer:      issuer,
+		Subject:     subject,
+		Email:       claimString(claims, "email"),
+		LastLoginAt: &now,
+	}); err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	uc.log.Infof("单点登录关联本地账号, userId: %d, issuer: %s, subject: %s", user.ID, issuer, subject)
+	return user, nil
+}
+
+// linkableOIDCUser 开启关联已有账号时，查找用户名为ID Token中已验证邮箱的本地账号
+// 拥有全部权限的账号不自动关联，避免身份提供方的账号接管管理员
+func (uc *UserUsecase) linkableOIDCUser(ctx context.Context, settings *oidcSettings, claims map[string]interface{}) (*User, error) {
+	if !settings.LinkExisting {
+		return nil, nil
+	}
+	email := strings.TrimSpace(claimString(claims, "email"))
+	if email == "" || !claimBool(claims, "email_verified") {
+		return nil, nil
+	}
+
+	user, err := uc.userRepo.GetByUsername(ctx, email)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if user == nil {
+		return nil, nil
+	}
+	_, permissions, err := uc.userPermissions(ctx, user)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if user.SuperAdmin == 1 || slices.Contains(permissions, middleware.PermissionAll) {
+		return nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("账号%s拥有全部权限，不能通过单点登录自动关联，请联系管理员", user.Username))
+	}
+	return user, nil
+}
+
+// provisionOIDCUser 为首次单点登录的用户创建本地账号，密码随机生成，只能通过单点登录
+func (uc *UserUsecase) provisionOIDCUser(ctx context.Context, username string) (*User, error) {
+	hashedPassword, err := kit.HashPassword(kit.GenerateToken())
+	if err != nil {
+		return nil, err
+	}
+	if err := uc.userRepo.Save(ctx, &User{
+		Username:   username,
+		Password:   hashedPassword,
+		SuperAdmin: 0,
+		Status:     1,
+		CreateDate: time.Now(),
+	}); err != nil {
+		return nil, err
+	}
+
+	user, err := uc.userRepo.GetByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("创建用户%s失败", username)
+	}
+	return user, nil
+}
+
+// claimString 读取字符串类型的声明
+func claimString(claims map[string]interface{}, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// claimBool 读取布尔类型的声明，部分身份提供方以字符串"true"返回
+func claimBool(claims map[string]interface{}, name string) bool {
+	switch value := claims[name].(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	}
+	return false
+}
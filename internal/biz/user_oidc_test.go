@@ -0,0 +1,270 @@
+package biz
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/kit/cerrors"
+	"github.com/weetime/agent-matrix/internal/middleware"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestExchangeOIDCCode(t *testing.T) {
+	provider := newFakeOIDCProvider(t)
+	params := staticParamsService{
+		oidcEnabledParam:      "true",
+		oidcIssuerParam:       provider.URL,
+		oidcClientIDParam:     "agent-matrix",
+		oidcClientSecretParam: "secret",
+		oidcRedirectURIParam:  "https://matrix.example.com/login/oidc",
+	}
+	uc := NewUserUsecase(nil, nil, nil, nil, nil, nil, params, nil, nil, nil, nil, log.DefaultLogger)
+	ctx := context.Background()
+
+	settings, err := uc.loadOIDCSettings(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"openid", "profile", "email"}, settings.Scopes)
+
+	verifier := oauth2.GenerateVerifier()
+	provider.expectVerifier = verifier
+	state := &oidcLoginState{Issuer: provider.URL, Nonce: "nonce-1", CodeVerifier: verifier}
+	provider.nonce = "nonce-1"
+	idToken, claims, err := uc.exchangeOIDCCode(ctx, settings, state, "code-1")
+	require.NoError(t, err)
+	require.Equal(t, "sub-1", idToken.Subject)
+	require.Equal(t, "alice", claims["preferred_username"])
+
+	_, _, err = uc.exchangeOIDCCode(ctx, settings, &oidcLoginState{Issuer: provider.URL, Nonce: "nonce-1", CodeVerifier: "wrong"}, "code-1")
+	require.Error(t, err, "PKCE校验失败")
+
+	provider.nonce = "nonce-2"
+	_, _, err = uc.exchangeOIDCCode(ctx, settings, state, "code-1")
+	require.Error(t, err, "nonce不匹配")
+
+	provider.nonce = "nonce-1"
+	provider.audience = "other-client"
+	_, _, err = uc.exchangeOIDCCode(ctx, settings, state, "code-1")
+	require.Error(t, err, "受众不匹配")
+
+	uc = NewUserUsecase(nil, nil, nil, nil, nil, nil, staticParamsService{}, nil, nil, nil, nil, log.DefaultLogger)
+	_, err = uc.loadOIDCSettings(ctx)
+	require.Error(t, err, "未开启单点登录")
+}
+
+func TestLinkableOIDCUser(t *testing.T) {
+	userRepo := &memoryUserRepo{users: map[string]*User{
+		"alice@example.com": {ID: 2, Username: "alice@example.com"},
+		"root@example.com":  {ID: 1, Username: "root@example.com", SuperAdmin: 1},
+		"admin@example.com": {ID: 3, Username: "admin@example.com"},
+	}}
+	roleRepo := &stubRoleRepo{roles: map[int64][]*Role{
+		3: {{Code: RoleCodeAdmin, Permissions: []string{middleware.PermissionAll}}},
+	}}
+	uc := NewUserUsecase(userRepo, nil, nil, nil, nil, roleRepo, staticParamsService{}, nil, nil, nil, nil, log.DefaultLogger)
+	verified := func(email string) map[string]interface{} {
+		return map[string]interface{}{"preferred_username": "alice", "email": email, "email_verified": true}
+	}
+
+	tests := []struct {
+		name       string
+		link       bool
+		claims     map[string]interface{}
+		wantUserId int64
+		wantErr    bool
+	}{
+		{name: "按已验证邮箱关联", link: true, claims: verified("alice@example.com"), wantUserId: 2},
+		{name: "字符串形式的email_verified", link: true, claims: map[string]interface{}{"email": "alice@example.com", "email_verified": "true"}, wantUserId: 2},
+		{name: "未开启关联", claims: verified("alice@example.com")},
+		{name: "邮箱未验证", link: true, claims: map[string]interface{}{"preferred_username": "alice@example.com", "email": "alice@example.com"}},
+		{name: "没有对应的本地账号", link: true, claims: verified("bob@example.com")},
+		{name: "超级管理员不自动关联", link: true, claims: verified("root@example.com"), wantErr: true},
+		{name: "拥有全部权限的角色不自动关联", link: true, claims: verified("admin@example.com"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := uc.linkableOIDCUser(context.Background(), &oidcSettings{LinkExisting: tt.link}, tt.claims)
+			if tt.wantErr {
+				require.True(t, cerrors.IsPermissionDenied(err))
+				return
+			}
+			require.NoError(t, err)
+			if tt.wantUserId == 0 {
+				require.Nil(t, user)
+				return
+			}
+			require.Equal(t, tt.wantUserId, user.ID)
+		})
+	}
+}
+
+func TestResolveOIDCUser(t *testing.T) {
+	ctx := context.Background()
+	userRepo := &memoryUserRepo{users: map[string]*User{
+		"admin@example.com": {ID: 1, Username: "admin@example.com"},
+		"alice@example.com": {ID: 2, Username: "alice@example.com"},
+		"carol":             {ID: 3, Username: "carol"},
+	}}
+	roleRepo := &stubRoleRepo{roles: map[int64][]*Role{
+		1: {{Code: RoleCodeAdmin, Permissions: []string{middleware.PermissionAll}}},
+	}}
+	identityRepo := &memoryUserIdentityRepo{}
+	uc := NewUserUsecase(userRepo, nil, nil, nil, identityRepo, roleRepo, staticParamsService{}, nil, nil, nil, nil, log.DefaultLogger)
+	settings := &oidcSettings{UsernameClaim: defaultOIDCUsernameClaim}
+	alice := map[string]interface{}{"preferred_username": "alice", "email": "alice@example.com", "email_verified": true}
+
+	_, err := uc.resolveOIDCUser(ctx, settings, "https://idp", "sub-alice", alice)
+	require.Error(t, err, "未开启关联时不能登录已有账号")
+	_, err = uc.resolveOIDCUser(ctx, settings, "https://idp", "sub-carol", map[string]interface{}{"preferred_username": "carol"})
+	require.Error(t, err, "用户名声明与本地账号相同时不关联")
+	_, err = uc.resolveOIDCUser(ctx, settings, "https://idp", "sub-bob", map[string]interface{}{"preferred_username": "bob"})
+	require.Error(t, err, "未开启自动创建时账号需预先开通")
+	_, err = uc.resolveOIDCUser(ctx, settings, "https://idp", "sub-bob", map[string]interface{}{})
+	require.Error(t, err, "缺少用户名声明")
+
+	settings.LinkExisting = true
+	_, err = uc.resolveOIDCUser(ctx, settings, "https://idp", "sub-admin", map[string]interface{}{"preferred_username": "admin", "email": "admin@example.com", "email_verified": true})
+	require.Error(t, err, "拥有全部权限的账号不自动关联")
+	user, err := uc.resolveOIDCUser(ctx, settings, "https://idp", "sub-alice", alice)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), user.ID)
+
+	// 已关联的身份按sub查找，声明变化不影响
+	settings.LinkExisting = false
+	user, err = uc.resolveOIDCUser(ctx, settings, "https://idp", "sub-alice", map[string]interface{}{"preferred_username": "renamed"})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), user.ID)
+	_, err = uc.resolveOIDCUser(ctx, settings, "https://other-idp", "sub-alice", map[string]interface{}{"preferred_username": "renamed"})
+	require.Error(t, err, "不同身份提供方的同一sub不是同一身份")
+
+	settings.AutoProvision = true
+	user, err = uc.resolveOIDCUser(ctx, settings, "https://idp", "sub-bob", map[string]interface{}{"preferred_username": "bob", "email": "bob@example.com"})
+	require.NoError(t, err)
+	require.Equal(t, "bob", user.Username)
+	require.Equal(t, int32(0), user.SuperAdmin)
+	require.NotEmpty(t, user.Password)
+	require.Len(t, identityRepo.items, 2)
+	require.Equal(t, "bob@example.com", identityRepo.items[1].Email)
+}
+
+// fakeOIDCProvider 测试用的身份提供方，签发RS256的ID Token
+type fakeOIDCProvider struct {
+	*httptest.Server
+	signer         jose.Signer
+	expectVerifier string
+	nonce          string
+	audience       string
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, (&jose.SignerOptions{}).WithHeader("kid", "test"))
+	require.NoError(t, err)
+
+	p := &fakeOIDCProvider{signer: signer, audience: "agent-matrix"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"issuer":                                p.URL,
+			"authorization_endpoint":                p.URL + "/authorize",
+			"token_endpoint":                        p.URL + "/token",
+			"jwks_uri":                              p.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "test", Algorithm: string(jose.RS256), Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("code") != "code-1" || oauth2.S256ChallengeFromVerifier(r.PostFormValue("code_verifier")) != oauth2.S256ChallengeFromVerifier(p.expectVerifier) {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		now := time.Now()
+		payload, _ := json.Marshal(map[string]interface{}{
+			"iss":                p.URL,
+			"sub":                "sub-1",
+			"aud":                p.audience,
+			"iat":                now.Unix(),
+			"exp":                now.Add(time.Hour).Unix(),
+			"nonce":              p.nonce,
+			"preferred_username": "alice",
+		})
+		signed, err := p.signer.Sign(payload)
+		require.NoError(t, err)
+		idToken, err := signed.CompactSerialize()
+		require.NoError(t, err)
+		writeJSON(w, map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// stubRoleRepo 按用户ID返回固定的角色
+type stubRoleRepo struct {
+	RoleRepo
+	roles map[int64][]*Role
+}
+
+func (r *stubRoleRepo) ListByUserId(ctx context.Context, userId int64) ([]*Role, error) {
+	return r.roles[userId], nil
+}
+
+// Save 保存用户，自动分配ID
+func (r *memoryUserRepo) Save(ctx context.Context, user *User) error {
+	saved := *user
+	saved.ID = int64(len(r.users) + 1)
+	r.users[user.Username] = &saved
+	return nil
+}
+
+// memoryUserIdentityRepo 内存中的外部身份
+type memoryUserIdentityRepo struct {
+	items []*UserIdentity
+}
+
+func (r *memoryUserIdentityRepo) GetBySubject(ctx context.Context, issuer, subject string) (*UserIdentity, error) {
+	for _, item := range r.items {
+		if item.Issuer == issuer && item.Subject == subject {
+			return item, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryUserIdentityRepo) Create(ctx context.Context, identity *UserIdentity) error {
+	r.items = append(r.items, identity)
+	return nil
+}
+
+func (r *memoryUserIdentityRepo) TouchLogin(ctx context.Context, id string, at time.Time) error {
+	return nil
+}
+
+func (r *memoryUserIdentityRepo) DeleteByUserId(ctx context.Context, userId int64) error {
+	return nil
+}
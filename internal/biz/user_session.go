@@ -0,0 +1,240 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+	"github.com/weetime/agent-matrix/internal/middleware"
+)
+
+const (
+	maxUserSessions        = 10          // 每个用户同时有效的会话数上限，超出时使最早的会话失效
+	sessionTouchInterval   = time.Minute // 最近访问时间的更新间隔，避免每个请求都写库
+	expiredSessionRetained = 24 * time.Hour
+)
+
+// UserSession 登录会话
+type UserSession struct {
+	ID           int64
+	UserAgent    string
+	IP           string
+	CreateDate   time.Time
+	LastActiveAt *time.Time
+	ExpireDate   time.Time
+	Current      bool // 是否为发起请求的会话
+}
+
+// createToken 为当前客户端创建新的登录会话，token与客户端指纹绑定
+func (uc *UserUsecase) createToken(ctx context.Context, userId int64) (*TokenDTO, error) {
+	now := time.Now()
+	client := middleware.GetClientInfo(ctx)
+
+	if err := uc.tokenRepo.DeleteExpired(ctx, userId, now.Add(-expiredSessionRetained)); err != nil {
+		uc.log.Warnf("清理过期会话失败, userId: %d: %v", userId, err)
+	}
+
+	session := &UserToken{
+		UserID:       userId,
+		Token:        kit.GenerateToken(),
+		ClientHash:   client.Hash(),
+		UserAgent:    truncateRunes(client.UserAgent, 255),
+		IP:           client.IP,
+		LastActiveAt: &now,
+		ExpireDate:   now.Add(kit.TokenExpireSeconds * time.Second),
+		CreateDate:   now,
+		UpdateDate:   now,
+	}
+	if err := uc.tokenRepo.Save(ctx, session); err != nil {
+		return nil, err
+	}
+
+	// 超出会话数上限时使最早活跃的会话失效
+	active, err := uc.tokenRepo.ListActiveByUserId(ctx, userId, now)
+	if err != nil {
+		return nil, err
+	}
+	if len(active) > maxUserSessions {
+		var staleIds []int64
+		for _, item := range active[maxUserSessions:] {
+			if item.Token != session.Token {
+				staleIds = append(staleIds, item.ID)
+			}
+		}
+		if _, err := uc.tokenRepo.ExpireByIds(ctx, userId, staleIds, now.Add(-time.Minute)); err != nil {
+			uc.log.Warnf("使超出上限的会话失效失败, userId: %d: %v", userId, err)
+		}
+	}
+
+	return &TokenDTO{
+		Token:      session.Token,
+		Expire:     int32(kit.TokenExpireSeconds),
+		ClientHash: session.ClientHash,
+	}, nil
+}
+
+// GetUserByToken 根据Token获取用户（实现TokenService接口）
+// 参考Java的Oauth2Realm.doGetAuthenticationInfo实现，另外校验会话绑定的客户端指纹
+func (uc *UserUsecase) GetUserByToken(ctx context.Context, token string) (*middleware.UserDetail, error) {
+	// 根据accessToken，查询用户token信息
+	tokenEntity, err := uc.tokenRepo.GetByToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if tokenEntity == nil {
+		return nil, fmt.Errorf("token not found")
+	}
+
+	// token失效检查（参考Java实现）
+	now := time.Now()
+	if tokenEntity.ExpireDate.Before(now) {
+		return nil, fmt.Errorf("token expired")
+	}
+
+	// 会话绑定了客户端指纹时，其他客户端不能使用该token
+	client := middleware.GetClientInfo(ctx)
+	if tokenEntity.ClientHash != "" && tokenEntity.ClientHash != client.Hash() {
+		return nil, fmt.Errorf("token client mismatch")
+	}
+
+	// 查询用户信息
+	user, err := uc.userRepo.GetByUserId(ctx, tokenEntity.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	if tokenEntity.LastActiveAt == nil || now.Sub(*tokenEntity.LastActiveAt) >= sessionTouchInterval || (client.IP != "" && client.IP != tokenEntity.IP) {
+		if err := uc.tokenRepo.Touch(ctx, tokenEntity.ID, client.IP, now); err != nil {
+			uc.log.Warnf("更新会话访问时间失败, sessionId: %d: %v", tokenEntity.ID, err)
+		}
+	}
+
+	// 每次请求按角色重新计算权限，角色调整后立即生效
+	roleCodes, permissions, err := uc.userPermissions(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	// 账号状态检查（参考Java的Oauth2Realm实现）
+	// status为0表示账号被锁定，会在中间件中处理
+	// 这里只返回用户信息，状态检查在中间件中进行
+
+	return &middleware.UserDetail{
+		ID:          user.ID,
+		Username:    user.Username,
+		SuperAdmin:  superAdminFlag(permissions),
+		Status:      user.Status,
+		Token:       token,
+		Roles:       roleCodes,
+		Permissions: permissions,
+	}, nil
+}
+
+// userPermissions 按用户的角色计算角色编码和权限
+func (uc *UserUsecase) userPermissions(ctx context.Context, user *User) ([]string, []string, error) {
+	roles, err := uc.roleRepo.ListByUserId(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	codes, permissions := resolvePermissions(roles)
+	return codes, permissions, nil
+}
+
+// checkManageable 校验操作人拥有目标用户的每一项权限，防止低权限管理员重置、停用或删除更高权限的账号
+func (uc *UserUsecase) checkManageable(ctx context.Context, operator *middleware.UserDetail, userId int64) error {
+	_, permissions, err := uc.userPermissions(ctx, &User{ID: userId})
+	if err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	for _, permission := range permissions {
+		if !operator.HasPermission(permission) {
+			return uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("不能操作权限高于自己的用户"))
+		}
+	}
+	return nil
+}
+
+// superAdminFlag 拥有全部权限时视为超级管理员，兼容按super_admin判断的前端
+func superAdminFlag(permissions []string) int32 {
+	if slices.Contains(permissions, middleware.PermissionAll) {
+		return 1
+	}
+	return 0
+}
+
+// ListSessions 查询用户当前有效的登录会话，currentToken对应的会话标记为当前会话
+func (uc *UserUsecase) ListSessions(ctx context.Context, userId int64, currentToken string) ([]*UserSession, error) {
+	tokens, err := uc.tokenRepo.ListActiveByUserId(ctx, userId, time.Now())
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	sessions := make([]*UserSession, 0, len(tokens))
+	for _, token := range tokens {
+		sessions = append(sessions, &UserSession{
+			ID:           token.ID,
+			UserAgent:    token.UserAgent,
+			IP:           token.IP,
+			CreateDate:   token.CreateDate,
+			LastActiveAt: token.LastActiveAt,
+			ExpireDate:   token.ExpireDate,
+			Current:      token.Token == currentToken,
+		})
+	}
+	// 当前会话排在最前
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].Current && !sessions[j].Current
+	})
+	return sessions, nil
+}
+
+// RevokeSession 使用户的指定会话失效，可用于退出当前会话
+func (uc *UserUsecase) RevokeSession(ctx context.Context, userId, sessionId int64) error {
+	revoked, err := uc.tokenRepo.ExpireByIds(ctx, userId, []int64{sessionId}, time.Now().Add(-time.Minute))
+	if err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	if revoked == 0 {
+		return uc.handleError.ErrNotFound(ctx, fmt.Errorf("会话不存在"))
+	}
+	return nil
+}
+
+// RevokeOtherSessions 使当前会话以外的全部会话失效，返回失效的会话数
+func (uc *UserUsecase) RevokeOtherSessions(ctx context.Context, userId int64, currentToken string) (int, error) {
+	now := time.Now()
+	tokens, err := uc.tokenRepo.ListActiveByUserId(ctx, userId, now)
+	if err != nil {
+		return 0, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	var ids []int64
+	for _, token := range tokens {
+		if token.Token != currentToken {
+			ids = append(ids, token.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	revoked, err := uc.tokenRepo.ExpireByIds(ctx, userId, ids, now.Add(-time.Minute))
+	if err != nil {
+		return 0, uc.handleError.ErrInternal(ctx, err)
+	}
+	return revoked, nil
+}
+
+// truncateRunes 按字符数截断字符串
+func truncateRunes(value string, max int) string {
+	runes := []rune(value)
+	if len(runes) <= max {
+		return value
+	}
+	return string(runes[:max])
+}
@@ -0,0 +1,222 @@
+package biz
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/kit/cerrors"
+	"github.com/weetime/agent-matrix/internal/middleware"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserSessions(t *testing.T) {
+	tokenRepo := &memoryUserTokenRepo{}
+	userRepo := &memoryUserRepo{users: map[string]*User{
+		"alice": {ID: 1, Username: "alice", Status: 1},
+	}}
+	uc := NewUserUsecase(userRepo, tokenRepo, nil, nil, nil, &stubRoleRepo{}, nil, nil, nil, nil, nil, log.DefaultLogger)
+
+	web := middleware.WithClientInfo(context.Background(), &middleware.ClientInfo{IP: "10.0.0.1", UserAgent: "Chrome", Fingerprint: "Chrome"})
+	phone := middleware.WithClientInfo(context.Background(), &middleware.ClientInfo{IP: "10.0.0.2", UserAgent: "iPhone", Fingerprint: "iPhone"})
+
+	// 每次登录创建独立的会话
+	webToken, err := uc.createToken(web, 1)
+	require.NoError(t, err)
+	require.NotEmpty(t, webToken.ClientHash)
+	phoneToken, err := uc.createToken(phone, 1)
+	require.NoError(t, err)
+	require.NotEqual(t, webToken.Token, phoneToken.Token)
+
+	// token只能由登录时的客户端使用
+	user, err := uc.GetUserByToken(web, webToken.Token)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), user.ID)
+	_, err = uc.GetUserByToken(phone, webToken.Token)
+	require.Error(t, err)
+
+	sessions, err := uc.ListSessions(web, 1, webToken.Token)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	require.True(t, sessions[0].Current)
+	require.Equal(t, "Chrome", sessions[0].UserAgent)
+
+	// 使其他会话失效后，当前会话仍然有效
+	revoked, err := uc.RevokeOtherSessions(web, 1, webToken.Token)
+	require.NoError(t, err)
+	require.Equal(t, 1, revoked)
+	_, err = uc.GetUserByToken(phone, phoneToken.Token)
+	require.Error(t, err)
+	_, err = uc.GetUserByToken(web, webToken.Token)
+	require.NoError(t, err)
+
+	require.NoError(t, uc.RevokeSession(web, 1, sessions[0].ID))
+	_, err = uc.GetUserByToken(web, webToken.Token)
+	require.Error(t, err)
+	require.Error(t, uc.RevokeSession(web, 1, sessions[0].ID))
+
+	// 超出会话数上限时最早的会话失效
+	var first *TokenDTO
+	for i := 0; i < maxUserSessions+1; i++ {
+		dto, err := uc.createToken(web, 1)
+		require.NoError(t, err)
+		if first == nil {
+			first = dto
+		}
+	}
+	_, err = uc.GetUserByToken(web, first.Token)
+	require.Error(t, err)
+	sessions, err = uc.ListSessions(web, 1, "")
+	require.NoError(t, err)
+	require.Len(t, sessions, maxUserSessions)
+}
+
+func TestGetUserByTokenClientBinding(t *testing.T) {
+	chrome := &middleware.ClientInfo{IP: "10.0.0.1", UserAgent: "Chrome", Fingerprint: "Chrome"}
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		clientHash string
+		expireDate time.Time
+		client     *middleware.ClientInfo
+		wantErr    bool
+		wantIP     string // 校验通过后会话记录的IP
+	}{
+		{name: "登录时的客户端", clientHash: chrome.Hash(), client: chrome, wantIP: "10.0.0.1"},
+		{name: "同一客户端更换IP", clientHash: chrome.Hash(), client: &middleware.ClientInfo{IP: "10.0.0.9", Fingerprint: "Chrome"}, wantIP: "10.0.0.9"},
+		{name: "其他客户端", clientHash: chrome.Hash(), client: &middleware.ClientInfo{IP: "10.0.0.1", Fingerprint: "Firefox"}, wantErr: true},
+		{name: "未携带客户端信息", clientHash: chrome.Hash(), client: &middleware.ClientInfo{}, wantErr: true},
+		{name: "未绑定客户端的旧会话", client: &middleware.ClientInfo{IP: "10.0.0.2", Fingerprint: "Firefox"}, wantIP: "10.0.0.2"},
+		{name: "已过期", clientHash: chrome.Hash(), client: chrome, expireDate: now.Add(-time.Minute), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expireDate := tt.expireDate
+			if expireDate.IsZero() {
+				expireDate = now.Add(time.Hour)
+			}
+			tokenRepo := &memoryUserTokenRepo{}
+			require.NoError(t, tokenRepo.Save(context.Background(), &UserToken{UserID: 1, Token: "token", ClientHash: tt.clientHash, ExpireDate: expireDate}))
+			userRepo := &memoryUserRepo{users: map[string]*User{"alice": {ID: 1, Username: "alice", Status: 1}}}
+			roleRepo := &stubRoleRepo{roles: map[int64][]*Role{1: {{Code: "viewer", Permissions: []string{middleware.PermissionModelRead}}}}}
+			uc := NewUserUsecase(userRepo, tokenRepo, nil, nil, nil, roleRepo, nil, nil, nil, nil, nil, log.DefaultLogger)
+
+			user, err := uc.GetUserByToken(middleware.WithClientInfo(context.Background(), tt.client), "token")
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, []string{"viewer"}, user.Roles)
+			require.Equal(t, []string{middleware.PermissionModelRead}, user.Permissions)
+			require.Equal(t, tt.wantIP, tokenRepo.tokens[0].IP)
+		})
+	}
+}
+
+func TestCheckManageable(t *testing.T) {
+	roleRepo := &stubRoleRepo{roles: map[int64][]*Role{
+		1: {{Code: RoleCodeAdmin, Permissions: []string{middleware.PermissionAll}}},
+		2: {{Code: "user_admin", Permissions: []string{"user:*"}}},
+		3: {{Code: "viewer", Permissions: []string{"user:read", "model:read"}}},
+	}}
+	uc := NewUserUsecase(nil, nil, nil, nil, nil, roleRepo, staticParamsService{}, nil, nil, nil, nil, log.DefaultLogger)
+
+	tests := []struct {
+		name        string
+		permissions []string
+		userId      int64
+		wantDenied  bool
+	}{
+		{name: "不能操作admin", permissions: []string{"user:*", "model:*"}, userId: 1, wantDenied: true},
+		{name: "通配权限覆盖目标权限", permissions: []string{"user:*", "model:*"}, userId: 3},
+		{name: "缺少目标的部分权限", permissions: []string{"user:*"}, userId: 3, wantDenied: true},
+		{name: "目标的通配权限不能由具体权限覆盖", permissions: []string{"user:read", "user:write"}, userId: 2, wantDenied: true},
+		{name: "没有角色的用户", permissions: []string{middleware.PermissionUserWrite}, userId: 4},
+		{name: "admin可以操作任何用户", permissions: []string{middleware.PermissionAll}, userId: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operator := &middleware.UserDetail{ID: 9, Permissions: tt.permissions}
+			err := uc.checkManageable(context.Background(), operator, tt.userId)
+			if tt.wantDenied {
+				require.True(t, cerrors.IsPermissionDenied(err), err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+// memoryUserTokenRepo 内存中的登录会话
+type memoryUserTokenRepo struct {
+	tokens []*UserToken
+}
+
+func (r *memoryUserTokenRepo) GetByToken(ctx context.Context, token string) (*UserToken, error) {
+	for _, item := range r.tokens {
+		if item.Token == token {
+			copied := *item
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryUserTokenRepo) Save(ctx context.Context, token *UserToken) error {
+	token.ID = int64(len(r.tokens) + 1)
+	copied := *token
+	r.tokens = append(r.tokens, &copied)
+	return nil
+}
+
+func (r *memoryUserTokenRepo) ListActiveByUserId(ctx context.Context, userId int64, now time.Time) ([]*UserToken, error) {
+	var result []*UserToken
+	for i := len(r.tokens) - 1; i >= 0; i-- {
+		item := r.tokens[i]
+		if item.UserID == userId && item.ExpireDate.After(now) {
+			copied := *item
+			result = append(result, &copied)
+		}
+	}
+	return result, nil
+}
+
+func (r *memoryUserTokenRepo) Touch(ctx context.Context, id int64, ip string, lastActiveAt time.Time) error {
+	for _, item := range r.tokens {
+		if item.ID == id {
+			item.IP = ip
+			item.LastActiveAt = &lastActiveAt
+		}
+	}
+	return nil
+}
+
+func (r *memoryUserTokenRepo) Logout(ctx context.Context, userId int64, expireDate time.Time) error {
+	for _, item := range r.tokens {
+		if item.UserID == userId {
+			item.ExpireDate = expireDate
+		}
+	}
+	return nil
+}
+
+func (r *memoryUserTokenRepo) ExpireByIds(ctx context.Context, userId int64, ids []int64, expireDate time.Time) (int, error) {
+	count := 0
+	for _, item := range r.tokens {
+		for _, id := range ids {
+			if item.ID == id && item.UserID == userId && item.ExpireDate.After(expireDate) {
+				item.ExpireDate = expireDate
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryUserTokenRepo) DeleteExpired(ctx context.Context, userId int64, before time.Time) error {
+	return nil
+}
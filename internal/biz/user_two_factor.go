@@ -0,0 +1,413 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+	"github.com/weetime/agent-matrix/internal/middleware"
+)
+
+const (
+	forceSuperAdminTwoFactorParam = "server.force_super_admin_2fa" // 为true时超级管理员必须启用双因素认证
+
+	totpIssuer         = "AgentMatrix"
+	totpSkew           = 1 // 允许前后各一个时间步的时钟偏差
+	recoveryCodeCount  = 10
+	twoFactorTicketTTL = 5 * time.Minute // 密码校验通过后完成双因素认证的时限
+)
+
+// UserTOTP 用户的TOTP绑定
+type UserTOTP struct {
+	UserID        int64
+	Secret        string
+	Enabled       bool
+	RecoveryCodes []string // 未使用的恢复码摘要
+	LastUsedStep  int64
+	EnabledAt     *time.Time
+	CreateDate    time.Time
+	UpdateDate    time.Time
+}
+
+// UserTOTPRepo 双因素认证数据访问接口
+type UserTOTPRepo interface {
+	GetByUserId(ctx context.Context, userId int64) (*UserTOTP, error)
+
+	// Save 保存用户的TOTP绑定，已存在时覆盖
+	Save(ctx context.Context, totp *UserTOTP) error
+
+	// MarkStepUsed 记录已使用的时间步，不大于上次使用的时间步时返回false
+	MarkStepUsed(ctx context.Context, userId, step int64) (bool, error)
+
+	// ReplaceRecoveryCodes 恢复码仍为old时替换为codes，返回是否替换成功
+	ReplaceRecoveryCodes(ctx context.Context, userId int64, old, codes []string) (bool, error)
+
+	Delete(ctx context.Context, userId int64) error
+}
+
+// TwoFactorStatus 双因素认证状态
+type TwoFactorStatus struct {
+	Enabled                bool
+	Required               bool // 系统要求该用户启用
+	RecoveryCodesRemaining int
+	EnabledAt              *time.Time
+}
+
+// TwoFactorEnrollment 绑定信息，otpauth URI用于认证器App扫码
+type TwoFactorEnrollment struct {
+	Secret string
+	URI    string
+}
+
+// TwoFactorChallenge 密码校验通过后还需完成的双因素认证
+type TwoFactorChallenge struct {
+	Ticket    string
+	Setup     bool // 系统要求启用但尚未绑定，需先绑定再校验
+	ExpiresIn int32
+}
+
+// twoFactorTicket 登录凭证，凭此提交动态码完成登录
+type twoFactorTicket struct {
+	UserID     int64  `json:"user_id"`
+	Username   string `json:"username"`
+	ClientHash string `json:"client_hash"`
+	Setup      bool   `json:"setup"`
+}
+
+// twoFactorRequired 系统参数是否要求该用户启用双因素认证，按角色计算的权限判断是否为超级管理员
+func (uc *UserUsecase) twoFactorRequired(ctx context.Context, user *User) (bool, error) {
+	if uc.paramsService == nil {
+		return false, nil
+	}
+	if value, _ := uc.paramsService.GetValue(forceSuperAdminTwoFactorParam, true); value != "true" {
+		return false, nil
+	}
+	_, permissions, err := uc.userPermissions(ctx, user)
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(permissions, middleware.PermissionAll), nil
+}
+
+// GetTwoFactorStatus 查询用户的双因素认证状态
+func (uc *UserUsecase) GetTwoFactorStatus(ctx context.Context, userId int64) (*TwoFactorStatus, error) {
+	user, err := uc.getUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	totp, err := uc.totpRepo.GetByUserId(ctx, userId)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	required, err := uc.twoFactorRequired(ctx, user)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	status := &TwoFactorStatus{Required: required}
+	if totp != nil && totp.Enabled {
+		status.Enabled = true
+		status.RecoveryCodesRemaining = len(totp.RecoveryCodes)
+		status.EnabledAt = totp.EnabledAt
+	}
+	return status, nil
+}
+
+// BeginTwoFactorEnrollment 生成新的TOTP密钥，校验动态码后才会启用
+func (uc *UserUsecase) BeginTwoFactorEnrollment(ctx context.Context, userId int64) (*TwoFactorEnrollment, error) {
+	user, err := uc.getUser(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := uc.totpRepo.GetByUserId(ctx, userId)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if existing != nil && existing.Enabled {
+		return nil, uc.handleError.ErrAlreadyExists(ctx, fmt.Errorf("已启用双因素认证，请先停用后再重新绑定"))
+	}
+
+	secret, err := kit.GenerateTOTPSecret()
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if err := uc.totpRepo.Save(ctx, &UserTOTP{UserID: userId, Secret: secret}); err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	return &TwoFactorEnrollment{
+		Secret: secret,
+		URI:    kit.TOTPURI(totpIssuer, user.Username, secret),
+	}, nil
+}
+
+// EnableTwoFactor 校验绑定密钥生成的动态码，启用双因素认证并返回恢复码
+func (uc *UserUsecase) EnableTwoFactor(ctx context.Context, userId int64, code string) ([]string, error) {
+	totp, err := uc.totpRepo.GetByUserId(ctx, userId)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if totp == nil {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("请先获取绑定密钥"))
+	}
+	if totp.Enabled {
+		return nil, uc.handleError.ErrAlreadyExists(ctx, fmt.Errorf("已启用双因素认证"))
+	}
+
+	recoveryCodes, ok, err := uc.enableTOTP(ctx, totp, code)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if !ok {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("动态码错误"))
+	}
+	return recoveryCodes, nil
+}
+
+// DisableTwoFactor 使用动态码或恢复码停用双因素认证
+func (uc *UserUsecase) DisableTwoFactor(ctx context.Context, userId int64, code string) error {
+	user, err := uc.getUser(ctx, userId)
+	if err != nil {
+		return err
+	}
+	required, err := uc.twoFactorRequired(ctx, user)
+	if err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	if required {
+		return uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("系统要求超级管理员启用双因素认证，不能停用"))
+	}
+	totp, err := uc.getEnabledTOTP(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	ok, err := uc.verifySecondFactor(ctx, totp, code, true)
+	if err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	if !ok {
+		return uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("动态码或恢复码错误"))
+	}
+	if err := uc.totpRepo.Delete(ctx, userId); err != nil {
+		return uc.handleError.ErrInternal(ctx, err)
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes 使用动态码重新生成恢复码，原有恢复码全部失效
+func (uc *UserUsecase) RegenerateRecoveryCodes(ctx context.Context, userId int64, code string) ([]string, error) {
+	totp, err := uc.getEnabledTOTP(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := uc.verifySecondFactor(ctx, totp, code, false)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if !ok {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("动态码错误"))
+	}
+
+	recoveryCodes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	replaced, err := uc.totpRepo.ReplaceRecoveryCodes(ctx, userId, totp.RecoveryCodes, hashes)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if !replaced {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("恢复码已变更，请重试"))
+	}
+	return recoveryCodes, nil
+}
+
+// startTwoFactorLogin 用户已启用或被要求启用双因素认证时签发登录凭证，否则返回nil
+func (uc *UserUsecase) startTwoFactorLogin(ctx context.Context, user *User) (*TwoFactorChallenge, error) {
+	totp, err := uc.totpRepo.GetByUserId(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	enabled := totp != nil && totp.Enabled
+	if !enabled {
+		required, err := uc.twoFactorRequired(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		if !required {
+			return nil, nil
+		}
+	}
+
+	ticket := kit.GenerateToken()
+	data := &twoFactorTicket{
+		UserID:     user.ID,
+		Username:   user.Username,
+		ClientHash: middleware.GetClientInfo(ctx).Hash(),
+		Setup:      !enabled,
+	}
+	if err := uc.redisClient.SetObject(ctx, kit.GetLoginTwoFactorTicketKey(ticket), data, twoFactorTicketTTL); err != nil {
+		return nil, err
+	}
+	return &TwoFactorChallenge{
+		Ticket:    ticket,
+		Setup:     data.Setup,
+		ExpiresIn: int32(twoFactorTicketTTL / time.Second),
+	}, nil
+}
+
+// BeginLoginTwoFactorEnrollment 被要求启用双因素认证的用户凭登录凭证获取绑定密钥
+func (uc *UserUsecase) BeginLoginTwoFactorEnrollment(ctx context.Context, ticket string) (*TwoFactorEnrollment, error) {
+	data, err := uc.loadTwoFactorTicket(ctx, ticket)
+	if err != nil {
+		return nil, err
+	}
+	if !data.Setup {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("已启用双因素认证，请直接输入动态码"))
+	}
+	return uc.BeginTwoFactorEnrollment(ctx, data.UserID)
+}
+
+// CompleteTwoFactorLogin 校验动态码或恢复码后完成登录，绑定流程中首次校验通过时同时返回恢复码
+func (uc *UserUsecase) CompleteTwoFactorLogin(ctx context.Context, ticket, code string) (*TokenDTO, []string, error) {
+	data, err := uc.loadTwoFactorTicket(ctx, ticket)
+	if err != nil {
+		return nil, nil, err
+	}
+	clientIP := middleware.GetClientInfo(ctx).IP
+	if err := uc.checkLoginAllowed(ctx, data.Username, clientIP); err != nil {
+		return nil, nil, err
+	}
+
+	totp, err := uc.totpRepo.GetByUserId(ctx, data.UserID)
+	if err != nil {
+		return nil, nil, uc.handleError.ErrInternal(ctx, err)
+	}
+
+	var recoveryCodes []string
+	ok := false
+	switch {
+	case totp != nil && totp.Enabled:
+		ok, err = uc.verifySecondFactor(ctx, totp, code, true)
+	case totp != nil && data.Setup:
+		recoveryCodes, ok, err = uc.enableTOTP(ctx, totp, code)
+	case data.Setup:
+		return nil, nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("请先获取绑定密钥"))
+	default:
+		return nil, nil, uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("双因素认证已停用，请重新登录"))
+	}
+	if err != nil {
+		return nil, nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if !ok {
+		return nil, nil, uc.loginFailed(ctx, data.Username, clientIP, fmt.Errorf("动态码或恢复码错误"))
+	}
+
+	if err := uc.redisClient.Delete(ctx, kit.GetLoginTwoFactorTicketKey(ticket)); err != nil {
+		uc.log.Warnf("删除登录凭证失败: %v", err)
+	}
+	uc.clearLoginFailures(ctx, data.Username)
+
+	tokenDTO, err := uc.createToken(ctx, data.UserID)
+	if err != nil {
+		return nil, nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	return tokenDTO, recoveryCodes, nil
+}
+
+// loadTwoFactorTicket 读取登录凭证，凭证只能由登录时的客户端使用
+func (uc *UserUsecase) loadTwoFactorTicket(ctx context.Context, ticket string) (*twoFactorTicket, error) {
+	invalid := uc.handleError.ErrPermissionDenied(ctx, fmt.Errorf("登录凭证无效或已过期，请重新登录"))
+	if ticket == "" {
+		return nil, invalid
+	}
+
+	var data twoFactorTicket
+	if err := uc.redisClient.GetObject(ctx, kit.GetLoginTwoFactorTicketKey(ticket), &data); err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if data.UserID == 0 || data.ClientHash != middleware.GetClientInfo(ctx).Hash() {
+		return nil, invalid
+	}
+	return &data, nil
+}
+
+// enableTOTP 动态码正确时启用绑定并生成恢复码
+func (uc *UserUsecase) enableTOTP(ctx context.Context, totp *UserTOTP, code string) ([]string, bool, error) {
+	step, ok := kit.MatchTOTP(totp.Secret, code, time.Now(), totpSkew)
+	if !ok {
+		return nil, false, nil
+	}
+	recoveryCodes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	totp.Enabled = true
+	totp.RecoveryCodes = hashes
+	totp.LastUsedStep = step
+	totp.EnabledAt = &now
+	if err := uc.totpRepo.Save(ctx, totp); err != nil {
+		return nil, false, err
+	}
+	return recoveryCodes, true, nil
+}
+
+// verifySecondFactor 校验动态码，allowRecovery时也接受恢复码；动态码和恢复码都只能使用一次
+func (uc *UserUsecase) verifySecondFactor(ctx context.Context, totp *UserTOTP, code string, allowRecovery bool) (bool, error) {
+	if step, ok := kit.MatchTOTP(totp.Secret, code, time.Now(), totpSkew); ok {
+		return uc.totpRepo.MarkStepUsed(ctx, totp.UserID, step)
+	}
+	if !allowRecovery {
+		return false, nil
+	}
+
+	index := slices.Index(totp.RecoveryCodes, kit.HashRecoveryCode(code))
+	if index < 0 {
+		return false, nil
+	}
+	remaining := slices.Delete(slices.Clone(totp.RecoveryCodes), index, index+1)
+	return uc.totpRepo.ReplaceRecoveryCodes(ctx, totp.UserID, totp.RecoveryCodes, remaining)
+}
+
+// getEnabledTOTP 查询已启用的TOTP绑定
+func (uc *UserUsecase) getEnabledTOTP(ctx context.Context, userId int64) (*UserTOTP, error) {
+	totp, err := uc.totpRepo.GetByUserId(ctx, userId)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if totp == nil || !totp.Enabled {
+		return nil, uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("未启用双因素认证"))
+	}
+	return totp, nil
+}
+
+// getUser 查询用户，不存在时返回NotFound
+func (uc *UserUsecase) getUser(ctx context.Context, userId int64) (*User, error) {
+	user, err := uc.userRepo.GetByUserId(ctx, userId)
+	if err != nil {
+		return nil, uc.handleError.ErrInternal(ctx, err)
+	}
+	if user == nil {
+		return nil, uc.handleError.ErrNotFound(ctx, fmt.Errorf("用户不存在"))
+	}
+	return user, nil
+}
+
+// newRecoveryCodes 生成恢复码，返回明文和用于保存的摘要
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := kit.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, kit.HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
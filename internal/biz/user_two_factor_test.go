@@ -0,0 +1,216 @@
+package biz
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+	"github.com/weetime/agent-matrix/internal/middleware"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTwoFactorEnrollment(t *testing.T) {
+	ctx := context.Background()
+	totpRepo := &memoryUserTOTPRepo{items: map[int64]*UserTOTP{}}
+	userRepo := &memoryUserRepo{users: map[string]*User{
+		"admin": {ID: 1, Username: "admin"},
+	}}
+	roleRepo := &stubRoleRepo{roles: map[int64][]*Role{
+		1: {{Code: RoleCodeAdmin, Permissions: []string{middleware.PermissionAll}}},
+	}}
+	params := staticParamsService{}
+	uc := NewUserUsecase(userRepo, nil, nil, totpRepo, nil, roleRepo, params, nil, nil, nil, nil, log.DefaultLogger)
+
+	_, err := uc.EnableTwoFactor(ctx, 1, "000000")
+	require.Error(t, err, "未获取绑定密钥")
+
+	enrollment, err := uc.BeginTwoFactorEnrollment(ctx, 1)
+	require.NoError(t, err)
+	require.Contains(t, enrollment.URI, "otpauth://totp/AgentMatrix:admin?")
+	status, err := uc.GetTwoFactorStatus(ctx, 1)
+	require.NoError(t, err)
+	require.False(t, status.Enabled, "校验动态码前不启用")
+
+	_, err = uc.EnableTwoFactor(ctx, 1, "000000")
+	require.Error(t, err, "动态码错误")
+	code, err := kit.TOTPCode(enrollment.Secret, kit.TOTPStep(time.Now()))
+	require.NoError(t, err)
+	recoveryCodes, err := uc.EnableTwoFactor(ctx, 1, code)
+	require.NoError(t, err)
+	require.Len(t, recoveryCodes, recoveryCodeCount)
+	_, err = uc.BeginTwoFactorEnrollment(ctx, 1)
+	require.Error(t, err, "已启用时不能重新绑定")
+
+	// 绑定时使用过的动态码不能再次使用
+	ok, err := uc.verifySecondFactor(ctx, totpRepo.items[1], code, true)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// 恢复码只能使用一次
+	ok, err = uc.verifySecondFactor(ctx, totpRepo.items[1], recoveryCodes[0], true)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = uc.verifySecondFactor(ctx, totpRepo.items[1], recoveryCodes[0], true)
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = uc.verifySecondFactor(ctx, totpRepo.items[1], recoveryCodes[1], false)
+	require.NoError(t, err)
+	require.False(t, ok, "不接受恢复码时只校验动态码")
+
+	status, err = uc.GetTwoFactorStatus(ctx, 1)
+	require.NoError(t, err)
+	require.True(t, status.Enabled)
+	require.Equal(t, recoveryCodeCount-1, status.RecoveryCodesRemaining)
+
+	// 重新生成恢复码只接受动态码，原有恢复码全部失效
+	_, err = uc.RegenerateRecoveryCodes(ctx, 1, recoveryCodes[1])
+	require.Error(t, err)
+	nextCode, err := kit.TOTPCode(enrollment.Secret, kit.TOTPStep(time.Now())+1)
+	require.NoError(t, err)
+	regenerated, err := uc.RegenerateRecoveryCodes(ctx, 1, nextCode)
+	require.NoError(t, err)
+	require.Len(t, regenerated, recoveryCodeCount)
+	require.Error(t, uc.DisableTwoFactor(ctx, 1, recoveryCodes[1]), "原恢复码已失效")
+
+	// 系统要求全部权限的用户启用时不能停用
+	params[forceSuperAdminTwoFactorParam] = "true"
+	require.Error(t, uc.DisableTwoFactor(ctx, 1, regenerated[0]))
+	params[forceSuperAdminTwoFactorParam] = "false"
+	require.Error(t, uc.DisableTwoFactor(ctx, 1, "wrong"))
+	require.NoError(t, uc.DisableTwoFactor(ctx, 1, regenerated[0]))
+	require.Nil(t, totpRepo.items[1])
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	secret, err := kit.GenerateTOTPSecret()
+	require.NoError(t, err)
+	users := map[string]*User{
+		"admin": {ID: 1, Username: "admin", Status: 1},
+		"alice": {ID: 2, Username: "alice", Status: 1},
+		"bob":   {ID: 3, Username: "bob", Status: 1},
+	}
+
+	tests := []struct {
+		name          string
+		username      string
+		wantChallenge bool
+		wantSetup     bool
+		enroll        bool   // 凭登录凭证获取绑定密钥
+		code          string // totp表示当前动态码
+		otherClient   bool
+		wantErr       string
+		wantRecovery  bool // 绑定流程中返回恢复码
+	}{
+		{name: "未启用且不要求启用时直接登录", username: "bob"},
+		{name: "校验动态码", username: "alice", wantChallenge: true, code: "totp"},
+		{name: "使用恢复码", username: "alice", wantChallenge: true, code: "recovery-1"},
+		{name: "动态码错误", username: "alice", wantChallenge: true, code: "000000", wantErr: "动态码或恢复码错误"},
+		{name: "凭证只能由登录时的客户端使用", username: "alice", wantChallenge: true, code: "totp", otherClient: true, wantErr: "登录凭证无效"},
+		{name: "要求启用时需先获取绑定密钥", username: "admin", wantChallenge: true, wantSetup: true, code: "000000", wantErr: "请先获取绑定密钥"},
+		{name: "要求启用时绑定后完成登录", username: "admin", wantChallenge: true, wantSetup: true, enroll: true, code: "totp", wantRecovery: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := miniredis.RunT(t)
+			redisClient := kit.NewRedisClientWithClient(redis.NewClient(&redis.Options{Addr: server.Addr()}), log.DefaultLogger)
+			totpRepo := &memoryUserTOTPRepo{items: map[int64]*UserTOTP{
+				2: {UserID: 2, Secret: secret, Enabled: true, RecoveryCodes: []string{kit.HashRecoveryCode("recovery-1")}},
+			}}
+			roleRepo := &stubRoleRepo{roles: map[int64][]*Role{
+				1: {{Code: RoleCodeAdmin, Permissions: []string{middleware.PermissionAll}}},
+			}}
+			params := staticParamsService{forceSuperAdminTwoFactorParam: "true"}
+			uc := NewUserUsecase(&memoryUserRepo{users: users}, &memoryUserTokenRepo{}, nil, totpRepo, nil, roleRepo, params, nil, nil, nil, redisClient, log.DefaultLogger)
+
+			client := &middleware.ClientInfo{IP: "10.0.0.1", Fingerprint: "Chrome"}
+			ctx := middleware.WithClientInfo(context.Background(), client)
+			user := users[tt.username]
+			challenge, err := uc.startTwoFactorLogin(ctx, user)
+			require.NoError(t, err)
+			if !tt.wantChallenge {
+				require.Nil(t, challenge)
+				return
+			}
+			require.NotNil(t, challenge)
+			require.Equal(t, tt.wantSetup, challenge.Setup)
+
+			codeSecret := secret
+			if tt.enroll {
+				enrollment, err := uc.BeginLoginTwoFactorEnrollment(ctx, challenge.Ticket)
+				require.NoError(t, err)
+				codeSecret = enrollment.Secret
+			}
+			code := tt.code
+			if code == "totp" {
+				code, err = kit.TOTPCode(codeSecret, kit.TOTPStep(time.Now()))
+				require.NoError(t, err)
+			}
+			if tt.otherClient {
+				ctx = middleware.WithClientInfo(context.Background(), &middleware.ClientInfo{IP: "10.0.0.1", Fingerprint: "Firefox"})
+			}
+
+			token, recoveryCodes, err := uc.CompleteTwoFactorLogin(ctx, challenge.Ticket, code)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.NotEmpty(t, token.Token)
+			require.Equal(t, tt.wantRecovery, len(recoveryCodes) == recoveryCodeCount)
+			require.True(t, totpRepo.items[user.ID].Enabled)
+
+			// 登录凭证只能使用一次
+			_, _, err = uc.CompleteTwoFactorLogin(ctx, challenge.Ticket, code)
+			require.ErrorContains(t, err, "登录凭证无效")
+		})
+	}
+}
+
+// memoryUserTOTPRepo 内存中的TOTP绑定
+type memoryUserTOTPRepo struct {
+	items map[int64]*UserTOTP
+}
+
+func (r *memoryUserTOTPRepo) GetByUserId(ctx context.Context, userId int64) (*UserTOTP, error) {
+	if item, ok := r.items[userId]; ok {
+		copied := *item
+		copied.RecoveryCodes = slices.Clone(item.RecoveryCodes)
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (r *memoryUserTOTPRepo) Save(ctx context.Context, totp *UserTOTP) error {
+	copied := *totp
+	r.items[totp.UserID] = &copied
+	return nil
+}
+
+func (r *memoryUserTOTPRepo) MarkStepUsed(ctx context.Context, userId, step int64) (bool, error) {
+	item, ok := r.items[userId]
+	if !ok || item.LastUsedStep >= step {
+		return false, nil
+	}
+	item.LastUsedStep = step
+	return true, nil
+}
+
+func (r *memoryUserTOTPRepo) ReplaceRecoveryCodes(ctx context.Context, userId int64, old, codes []string) (bool, error) {
+	item, ok := r.items[userId]
+	if !ok || !item.Enabled || !slices.Equal(item.RecoveryCodes, old) {
+		return false, nil
+	}
+	item.RecoveryCodes = codes
+	return true, nil
+}
+
+func (r *memoryUserTOTPRepo) Delete(ctx context.Context, userId int64) error {
+	delete(r.items, userId)
+	return nil
+}
@@ -98,11 +98,12 @@ type VoiceCloneRepo interface {
 
 // VoiceCloneUsecase 音色克隆业务逻辑
 type VoiceCloneUsecase struct {
-	repo        VoiceCloneRepo
-	modelRepo   ModelConfigRepo
-	userRepo    UserRepo
-	handleError *cerrors.HandleError
-	log         *log.Helper
+	repo           VoiceCloneRepo
+	modelRepo      ModelConfigRepo
+	userRepo       UserRepo
+	configNotifier *ConfigNotifier
+	handleError    *cerrors.HandleError
+	log            *log.Helper
 }
 
 // NewVoiceCloneUsecase 创建音色克隆用例
@@ -110,14 +111,16 @@ func NewVoiceCloneUsecase(
 	repo VoiceCloneRepo,
 	modelRepo ModelConfigRepo,
 	userRepo UserRepo,
+	configNotifier *ConfigNotifier,
 	logger log.Logger,
 ) *VoiceCloneUsecase {
 	return &VoiceCloneUsecase{
-		repo:        repo,
-		modelRepo:   modelRepo,
-		userRepo:    userRepo,
-		handleError: cerrors.NewHandleError(logger),
-		log:         kit.LogHelper(logger),
+		repo:           repo,
+		modelRepo:      modelRepo,
+		userRepo:       userRepo,
+		configNotifier: configNotifier,
+		handleError:    cerrors.NewHandleError(logger),
+		log:            kit.LogHelper(logger),
 	}
 }
 
@@ -218,7 +221,12 @@ func (uc *VoiceCloneUsecase) UpdateName(ctx context.Context, id string, name str
 	}
 
 	// 更新名称
-	return uc.repo.UpdateName(ctx, id, name)
+	if err := uc.repo.UpdateName(ctx, id, name); err != nil {
+		return err
+	}
+	uc.configNotifier.NotifyModel(ctx, entity.ModelID, "voice_clone")
+
+	return nil
 }
 
 // GetVoiceData 获取音频数据
@@ -392,7 +400,12 @@ func (uc *VoiceCloneUsecase) SaveVoiceResource(ctx context.Context, modelId stri
 	}
 
 	// 批量保存
-	return uc.repo.SaveVoiceResource(ctx, entities)
+	if err := uc.repo.SaveVoiceResource(ctx, entities); err != nil {
+		return err
+	}
+	uc.configNotifier.NotifyModel(ctx, modelId, "voice_clone")
+
+	return nil
 }
 
 // DeleteVoiceResource 批量删除音色资源
@@ -400,7 +413,23 @@ func (uc *VoiceCloneUsecase) DeleteVoiceResource(ctx context.Context, ids []stri
 	if len(ids) == 0 {
 		return uc.handleError.ErrInvalidInput(ctx, fmt.Errorf("ids不能为空"))
 	}
-	return uc.repo.DeleteVoiceResource(ctx, ids)
+
+	// 记录被删除音色所属的TTS模型，删除后通知节点
+	modelIds := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if entity, err := uc.repo.GetByID(ctx, id); err == nil && entity != nil {
+			modelIds = append(modelIds, entity.ModelID)
+		}
+	}
+
+	if err := uc.repo.DeleteVoiceResource(ctx, ids); err != nil {
+		return err
+	}
+	for _, modelId := range kit.Uniq(modelIds) {
+		uc.configNotifier.NotifyModel(ctx, modelId, "voice_clone")
+	}
+
+	return nil
 }
 
 // GetByUserIdWithNames 根据用户ID查询带模型名称和用户名称的声音克隆列表
@@ -532,6 +561,7 @@ func (uc *VoiceCloneUsecase) huoshanClone(ctx context.Context, modelConfig *Mode
 			if err := uc.repo.UpdateTrainStatus(ctx, entity.ID, 2, ""); err != nil {
 				return uc.handleError.ErrInternal(ctx, fmt.Errorf("更新训练状态失败: %w", err))
 			}
+			uc.configNotifier.NotifyModel(ctx, entity.ModelID, "voice_clone")
 			return nil
 		} else {
 			// 失败时使用StatusMessage作为错误信息
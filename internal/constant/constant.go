@@ -40,5 +40,38 @@ const (
 	ServerAuthEnabled = "server.auth.enabled"
 )
 
+// RAG适配器类型常量
+const (
+	RAGAdapterRAGFlow = "ragflow" // RAGFlow服务
+	RAGAdapterLocal   = "local"   // 内置本地知识库（向量存储在业务数据库中）
+)
+
+// 知识库文档解析状态（对应 Document.Status）
+const (
+	DocumentStatusUnstart   int32 = 0 // 未开始
+	DocumentStatusRunning   int32 = 1 // 进行中
+	DocumentStatusCancelled int32 = 2 // 已取消
+	DocumentStatusDone      int32 = 3 // 已完成
+	DocumentStatusFailed    int32 = 4 // 失败
+)
+
+// 知识库文档解析任务状态
+const (
+	ParseJobStatusQueued    = "queued"    // 排队中
+	ParseJobStatusRunning   = "running"   // 解析中
+	ParseJobStatusDone      = "done"      // 已完成
+	ParseJobStatusFailed    = "failed"    // 失败
+	ParseJobStatusCancelled = "cancelled" // 已取消
+)
+
+// 知识库文档RAG运行状态（对应 Document.Run）
+const (
+	DocumentRunUnstart = "UNSTART"
+	DocumentRunRunning = "RUNNING"
+	DocumentRunCancel  = "CANCEL"
+	DocumentRunDone    = "DONE"
+	DocumentRunFail    = "FAIL"
+)
+
 // 版本号
 const Version = "0.8.10"
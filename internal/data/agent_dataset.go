@@ -0,0 +1,91 @@
+package data
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/data/ent"
+	"github.com/weetime/agent-matrix/internal/data/ent/agentdataset"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/google/uuid"
+)
+
+type agentDatasetRepo struct {
+	data *Data
+	log  *log.Helper
+}
+
+// NewAgentDatasetRepo 初始化 AgentDataset Repo
+func NewAgentDatasetRepo(data *Data, logger log.Logger) biz.AgentDatasetRepo {
+	return &agentDatasetRepo{
+		data: data,
+		log:  log.NewHelper(log.With(logger, "module", "agent-matrix-service/data/agent_dataset")),
+	}
+}
+
+// ListByAgentId 按排序返回智能体绑定的知识库
+func (r *agentDatasetRepo) ListByAgentId(ctx context.Context, agentId string) ([]*biz.AgentDataset, error) {
+	entities, err := r.data.db.AgentDataset.Query().
+		Where(agentdataset.AgentIDEQ(agentId)).
+		Order(ent.Asc(agentdataset.FieldSort), ent.Asc(agentdataset.FieldCreatedAt)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*biz.AgentDataset, 0, len(entities))
+	for _, entity := range entities {
+		result = append(result, &biz.AgentDataset{
+			ID:        entity.ID,
+			AgentID:   entity.AgentID,
+			DatasetID: entity.DatasetID,
+			Sort:      entity.Sort,
+			Creator:   entity.Creator,
+			CreatedAt: entity.CreatedAt,
+		})
+	}
+	return result, nil
+}
+
+// ReplaceByAgentId 用给定的知识库列表替换智能体的全部绑定，列表顺序即排序
+func (r *agentDatasetRepo) ReplaceByAgentId(ctx context.Context, agentId string, datasetIds []string, userId int64) error {
+	tx, err := r.data.db.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.AgentDataset.Delete().Where(agentdataset.AgentIDEQ(agentId)).Exec(ctx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if len(datasetIds) > 0 {
+		now := time.Now()
+		builders := make([]*ent.AgentDatasetCreate, 0, len(datasetIds))
+		for i, datasetId := range datasetIds {
+			builders = append(builders, tx.AgentDataset.Create().
+				SetID(strings.ReplaceAll(uuid.New().String(), "-", "")).
+				SetAgentID(agentId).
+				SetDatasetID(datasetId).
+				SetSort(int32(i)).
+				SetCreator(userId).
+				SetCreatedAt(now))
+		}
+		if _, err := tx.AgentDataset.CreateBulk(builders...).Save(ctx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteByAgentId 删除智能体的全部知识库绑定
+func (r *agentDatasetRepo) DeleteByAgentId(ctx context.Context, agentId string) error {
+	_, err := r.data.db.AgentDataset.Delete().
+		Where(agentdataset.AgentIDEQ(agentId)).
+		Exec(ctx)
+	return err
+}
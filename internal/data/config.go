@@ -145,9 +145,11 @@ func (r *configRepo) PageSysParams(ctx context.Context, page, limit int32, param
 	return result, total, nil
 }
 
-// GetSysParamsByID 根据ID获取参数
+// GetSysParamsByID 根据ID获取参数，与分页查询一致只返回非系统参数，系统参数（签名私钥、密钥等）不通过接口读取
 func (r *configRepo) GetSysParamsByID(ctx context.Context, id int64) (*biz.SysParam, error) {
-	param, err := r.data.db.SysParams.Get(ctx, id)
+	param, err := r.data.db.SysParams.Query().
+		Where(sysparams.IDEQ(id), sysparams.ParamTypeEQ(1)).
+		Only(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -210,10 +212,10 @@ func (r *configRepo) UpdateSysParams(ctx context.Context, param *biz.SysParam) e
 	return err
 }
 
-// DeleteSysParams 批量删除参数
+// DeleteSysParams 批量删除参数，系统参数不会被删除
 func (r *configRepo) DeleteSysParams(ctx context.Context, ids []int64) error {
 	_, err := r.data.db.SysParams.Delete().
-		Where(sysparams.IDIn(ids...)).
+		Where(sysparams.IDIn(ids...), sysparams.ParamTypeEQ(1)).
 		Exec(ctx)
 	return err
 }
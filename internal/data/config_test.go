@@ -0,0 +1,48 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/weetime/agent-matrix/internal/data/ent"
+	"github.com/weetime/agent-matrix/internal/data/ent/migrate"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSysParamsHideSystemParams(t *testing.T) {
+	ctx := context.Background()
+	data := newTestData(t, migrate.SysParamsTable)
+	repo := NewConfigRepo(data, log.DefaultLogger)
+
+	require.NoError(t, data.db.SysParams.Create().SetID(1).SetParamCode("server.websocket").SetParamValue("ws://node").SetValueType("string").SetParamType(1).Exec(ctx))
+	require.NoError(t, data.db.SysParams.Create().SetID(2).SetParamCode("server.ota_signing_key").SetParamValue("private").SetValueType("string").SetParamType(0).Exec(ctx))
+
+	tests := []struct {
+		name      string
+		id        int64
+		wantFound bool
+	}{
+		{name: "非系统参数", id: 1, wantFound: true},
+		{name: "系统参数不能按ID读取", id: 2},
+		{name: "不存在的参数", id: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			param, err := repo.GetSysParamsByID(ctx, tt.id)
+			if !tt.wantFound {
+				require.True(t, ent.IsNotFound(err), err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.id, param.ID)
+		})
+	}
+
+	// 批量删除时跳过系统参数
+	require.NoError(t, repo.DeleteSysParams(ctx, []int64{1, 2}))
+	ids, err := data.db.SysParams.Query().IDs(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []int64{2}, ids)
+}
@@ -25,17 +25,30 @@ var ProviderSet = wire.NewSet(
 	NewAgentRepo,
 	NewUserRepo,
 	NewUserTokenRepo,
+	NewLoginAuditRepo,
+	NewUserTOTPRepo,
+	NewUserIdentityRepo,
+	NewRoleRepo,
 	NewDictTypeRepo,
 	NewDictDataRepo,
 	NewDeviceRepo,
+	NewDeviceTelemetryRepo,
+	NewDeviceGroupRepo,
+	NewDeviceTransferRepo,
 	NewModelConfigRepo,
 	NewModelProviderRepo,
 	NewTtsVoiceRepo,
 	NewAgentVoicePrintRepo,
 	NewAgentContextProviderRepo,
+	NewAgentDatasetRepo,
 	NewVoiceCloneRepo,
 	NewDatasetRepo,
 	NewOtaRepo,
+	NewOtaRolloutRepo,
+	NewFirmwareHistoryRepo,
+	NewOtaDeltaRepo,
+	NewFirmwareStore,
+	NewLocalRAGRepo,
 	kit.NewRedisClient,
 )
 
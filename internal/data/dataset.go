@@ -6,6 +6,7 @@ import (
 
 	"github.com/weetime/agent-matrix/internal/biz"
 	"github.com/weetime/agent-matrix/internal/data/ent"
+	"github.com/weetime/agent-matrix/internal/data/ent/agentdataset"
 	"github.com/weetime/agent-matrix/internal/data/ent/agentpluginmapping"
 	"github.com/weetime/agent-matrix/internal/data/ent/ragdataset"
 	"github.com/weetime/agent-matrix/internal/kit"
@@ -236,3 +237,32 @@ func (r *datasetRepo) DeletePluginMappingByKnowledgeBaseID(ctx context.Context,
 	return err
 }
 
+// ListAgentIDsByKnowledgeBase 查询通过插件映射或知识库绑定引用该知识库的智能体
+func (r *datasetRepo) ListAgentIDsByKnowledgeBase(ctx context.Context, knowledgeBaseId, datasetId string) ([]string, error) {
+	mappingAgentIds, err := r.data.db.AgentPluginMapping.Query().
+		Where(agentpluginmapping.PluginIDEQ(knowledgeBaseId)).
+		Select(agentpluginmapping.FieldAgentID).
+		Strings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bindingAgentIds, err := r.data.db.AgentDataset.Query().
+		Where(agentdataset.DatasetIDEQ(datasetId)).
+		Select(agentdataset.FieldAgentID).
+		Strings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return kit.Uniq(append(mappingAgentIds, bindingAgentIds...)), nil
+}
+
+// DeleteAgentBindingsByDatasetID 删除智能体与知识库的绑定
+func (r *datasetRepo) DeleteAgentBindingsByDatasetID(ctx context.Context, datasetId string) error {
+	_, err := r.data.db.AgentDataset.Delete().
+		Where(agentdataset.DatasetIDEQ(datasetId)).
+		Exec(ctx)
+	return err
+}
+
@@ -6,8 +6,14 @@ import (
 	"github.com/weetime/agent-matrix/internal/biz"
 	"github.com/weetime/agent-matrix/internal/data/ent"
 	"github.com/weetime/agent-matrix/internal/data/ent/device"
+	"github.com/weetime/agent-matrix/internal/data/ent/devicegroup"
+	"github.com/weetime/agent-matrix/internal/data/ent/devicegroupmember"
+	"github.com/weetime/agent-matrix/internal/data/ent/devicetelemetry"
+	"github.com/weetime/agent-matrix/internal/data/ent/devicetransfer"
+	"github.com/weetime/agent-matrix/internal/data/ent/predicate"
 	"github.com/weetime/agent-matrix/internal/kit"
 
+	"entgo.io/ent/dialect/sql"
 	"github.com/go-kratos/kratos/v2/log"
 )
 
@@ -100,15 +106,29 @@ func (r *deviceRepo) Update(ctx context.Context, d *biz.Device) error {
 	return err
 }
 
-// Delete 删除设备
+// Delete 删除设备，同时移出所在的设备分组
 func (r *deviceRepo) Delete(ctx context.Context, deviceId string, userId int64) error {
-	_, err := r.data.db.Device.Delete().
+	tx, err := r.data.db.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	deleted, err := tx.Device.Delete().
 		Where(
 			device.IDEQ(deviceId),
 			device.UserIDEQ(userId),
 		).
 		Exec(ctx)
-	return err
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if deleted > 0 {
+		if _, err := tx.DeviceGroupMember.Delete().Where(devicegroupmember.DeviceIDEQ(deviceId)).Exec(ctx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 // GetByID 根据ID获取设备
@@ -139,6 +159,25 @@ func (r *deviceRepo) GetByMacAddress(ctx context.Context, macAddress string) (*b
 	return r.toBizDevice(entity), nil
 }
 
+// ListByIds 批量查询设备
+func (r *deviceRepo) ListByIds(ctx context.Context, deviceIds []string) ([]*biz.Device, error) {
+	if len(deviceIds) == 0 {
+		return []*biz.Device{}, nil
+	}
+	entities, err := r.data.db.Device.Query().
+		Where(device.IDIn(deviceIds...)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*biz.Device, len(entities))
+	for i, entity := range entities {
+		result[i] = r.toBizDevice(entity)
+	}
+	return result, nil
+}
+
 // ListByUserAndAgent 根据用户ID和智能体ID获取设备列表
 func (r *deviceRepo) ListByUserAndAgent(ctx context.Context, userId int64, agentId string) ([]*biz.Device, error) {
 	entities, err := r.data.db.Device.Query().
@@ -192,22 +231,64 @@ func (r *deviceRepo) SelectCountByUserId(ctx context.Context, userId int64) (int
 	return int64(count), err
 }
 
-// DeleteByUserId 删除用户的所有设备
+// DeleteByUserId 删除用户的所有设备及用户的设备分组
 func (r *deviceRepo) DeleteByUserId(ctx context.Context, userId int64) error {
-	_, err := r.data.db.Device.Delete().
+	tx, err := r.data.db.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	deviceIds, err := tx.Device.Query().
 		Where(device.UserIDEQ(userId)).
-		Exec(ctx)
-	return err
+		IDs(ctx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	groupIds, err := tx.DeviceGroup.Query().
+		Where(devicegroup.UserIDEQ(userId)).
+		IDs(ctx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if len(deviceIds) > 0 || len(groupIds) > 0 {
+		if _, err := tx.DeviceGroupMember.Delete().
+			Where(devicegroupmember.Or(
+				devicegroupmember.DeviceIDIn(deviceIds...),
+				devicegroupmember.GroupIDIn(groupIds...),
+			)).
+			Exec(ctx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err := tx.DeviceGroup.Delete().Where(devicegroup.UserIDEQ(userId)).Exec(ctx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.DeviceTransfer.Update().
+		Where(
+			devicetransfer.StatusEQ(biz.DeviceTransferStatusPending),
+			devicetransfer.Or(
+				devicetransfer.FromUserIDEQ(userId),
+				devicetransfer.ToUserIDEQ(userId),
+			),
+		).
+		SetStatus(biz.DeviceTransferStatusCancelled).
+		Save(ctx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Device.Delete().Where(device.UserIDEQ(userId)).Exec(ctx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
 }
 
-// PageDevices 分页查询所有设备，支持按别名模糊查询
-func (r *deviceRepo) PageDevices(ctx context.Context, keywords *string, page *kit.PageRequest) ([]*biz.Device, error) {
-	query := r.data.db.Device.Query()
-
-	// 如果提供了关键词，进行模糊查询
-	if keywords != nil && *keywords != "" {
-		query = query.Where(device.AliasContains(*keywords))
-	}
+// PageDevices 分页查询所有设备，支持按别名/MAC地址、型号、版本及最新一次上报的遥测筛选
+func (r *deviceRepo) PageDevices(ctx context.Context, params *biz.ListDeviceParams, page *kit.PageRequest) ([]*biz.Device, error) {
+	query := r.data.db.Device.Query().Where(deviceListPredicates(params)...)
 
 	// 默认按mac_address降序排序
 	if page == nil || page.GetSortField() == "" {
@@ -231,13 +312,75 @@ func (r *deviceRepo) PageDevices(ctx context.Context, keywords *string, page *ki
 	return result, nil
 }
 
-// TotalDevices 获取设备总数（支持按关键词过滤）
-func (r *deviceRepo) TotalDevices(ctx context.Context, keywords *string) (int, error) {
-	query := r.data.db.Device.Query()
+// TotalDevices 获取设备总数（筛选条件与PageDevices一致）
+func (r *deviceRepo) TotalDevices(ctx context.Context, params *biz.ListDeviceParams) (int, error) {
+	return r.data.db.Device.Query().Where(deviceListPredicates(params)...).Count(ctx)
+}
+
+// deviceListPredicates 构建设备列表筛选条件，遥测条件通过子查询匹配设备最新一次上报
+func deviceListPredicates(params *biz.ListDeviceParams) []predicate.Device {
+	var predicates []predicate.Device
+	if params == nil {
+		return predicates
+	}
+	if params.Keywords != nil && *params.Keywords != "" {
+		predicates = append(predicates, device.Or(
+			device.AliasContains(*params.Keywords),
+			device.MACAddressContains(*params.Keywords),
+		))
+	}
+	if params.Board != nil && *params.Board != "" {
+		predicates = append(predicates, device.BoardEQ(*params.Board))
+	}
+	if params.AppVersion != nil && *params.AppVersion != "" {
+		predicates = append(predicates, device.AppVersionEQ(*params.AppVersion))
+	}
+
+	filter := params.Telemetry
+	if filter.IsEmpty() {
+		return predicates
+	}
+	predicates = append(predicates, func(s *sql.Selector) {
+		t := sql.Table(devicetelemetry.Table)
+		conditions := []*sql.Predicate{sql.EQ(t.C(devicetelemetry.FieldLatest), true)}
+		if filter.ChipModelName != nil {
+			conditions = append(conditions, sql.EQ(t.C(devicetelemetry.FieldChipModelName), *filter.ChipModelName))
+		}
+		if filter.IdfVersion != nil {
+			conditions = append(conditions, sql.EQ(t.C(devicetelemetry.FieldIdfVersion), *filter.IdfVersion))
+		}
+		if filter.RSSIBelow != nil {
+			conditions = append(conditions, sql.LT(t.C(devicetelemetry.FieldRssi), *filter.RSSIBelow))
+		}
+		if filter.FreeHeapBelow != nil {
+			conditions = append(conditions, sql.LT(t.C(devicetelemetry.FieldMinimumFreeHeapSize), *filter.FreeHeapBelow))
+		}
+		s.Where(sql.In(
+			s.C(device.FieldID),
+			sql.Select(t.C(devicetelemetry.FieldDeviceID)).From(t).Where(sql.And(conditions...)),
+		))
+	})
+	return predicates
+}
 
-	if keywords != nil && *keywords != "" {
-		query = query.Where(device.AliasContains(*keywords))
+// CountByAppVersion 按固件版本统计指定硬件型号的设备数
+func (r *deviceRepo) CountByAppVersion(ctx context.Context, board string) (map[string]int64, error) {
+	var rows []struct {
+		AppVersion string `json:"app_version"`
+		Count      int64  `json:"count"`
+	}
+	err := r.data.db.Device.Query().
+		Where(device.BoardEQ(board)).
+		GroupBy(device.FieldAppVersion).
+		Aggregate(ent.Count()).
+		Scan(ctx, &rows)
+	if err != nil {
+		return nil, err
 	}
 
-	return query.Count(ctx)
+	result := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		result[row.AppVersion] = row.Count
+	}
+	return result, nil
 }
@@ -0,0 +1,188 @@
+package data
+
+import (
+	"context"
+	"strings"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/data/ent"
+	"github.com/weetime/agent-matrix/internal/data/ent/devicefirmwarehistory"
+	"github.com/weetime/agent-matrix/internal/kit"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/google/uuid"
+)
+
+type firmwareHistoryRepo struct {
+	data *Data
+	log  *log.Helper
+}
+
+// NewFirmwareHistoryRepo 初始化设备固件版本历史Repo
+func NewFirmwareHistoryRepo(data *Data, logger log.Logger) biz.FirmwareHistoryRepo {
+	return &firmwareHistoryRepo{
+		data: data,
+		log:  log.NewHelper(log.With(logger, "module", "agent-matrix-service/data/device_firmware_history")),
+	}
+}
+
+// Create 创建版本历史记录
+func (r *firmwareHistoryRepo) Create(ctx context.Context, history *biz.FirmwareHistory) error {
+	history.ID = strings.ReplaceAll(uuid.New().String(), "-", "")
+	entity, err := r.data.db.DeviceFirmwareHistory.Create().
+		SetID(history.ID).
+		SetDeviceID(history.DeviceID).
+		SetMACAddress(history.MacAddress).
+		SetBoard(history.Board).
+		SetFromVersion(history.FromVersion).
+		SetToVersion(history.ToVersion).
+		SetOtaID(history.OtaID).
+		SetOutcome(history.Outcome).
+		SetAttempts(history.Attempts).
+		SetNillableCompletedAt(history.CompletedAt).
+		Save(ctx)
+	if err != nil {
+		return err
+	}
+	history.CreatedAt = entity.CreatedAt
+	history.UpdatedAt = entity.UpdatedAt
+	return nil
+}
+
+// Update 更新升级结果和下发次数
+func (r *firmwareHistoryRepo) Update(ctx context.Context, history *biz.FirmwareHistory) error {
+	entity, err := r.data.db.DeviceFirmwareHistory.UpdateOneID(history.ID).
+		SetToVersion(history.ToVersion).
+		SetOutcome(history.Outcome).
+		SetAttempts(history.Attempts).
+		SetNillableCompletedAt(history.CompletedAt).
+		Save(ctx)
+	if err != nil {
+		return err
+	}
+	history.UpdatedAt = entity.UpdatedAt
+	return nil
+}
+
+// GetPendingByDevice 获取设备尚未确定结果的下发记录
+func (r *firmwareHistoryRepo) GetPendingByDevice(ctx context.Context, deviceId string) (*biz.FirmwareHistory, error) {
+	entity, err := r.data.db.DeviceFirmwareHistory.Query().
+		Where(
+			devicefirmwarehistory.DeviceIDEQ(deviceId),
+			devicefirmwarehistory.OutcomeEQ(biz.FirmwareOutcomePending),
+		).
+		Order(ent.Desc(devicefirmwarehistory.FieldCreatedAt)).
+		First(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return toBizFirmwareHistory(entity), nil
+}
+
+// Page 分页查询版本历史，按创建时间倒序
+func (r *firmwareHistoryRepo) Page(ctx context.Context, params *biz.ListFirmwareHistoryParams, page *kit.PageRequest) ([]*biz.FirmwareHistory, int, error) {
+	query := r.data.db.DeviceFirmwareHistory.Query()
+	if params.MacAddress != nil && *params.MacAddress != "" {
+		query = query.Where(devicefirmwarehistory.MACAddressEQ(*params.MacAddress))
+	}
+	if params.OtaID != nil && *params.OtaID != "" {
+		query = query.Where(devicefirmwarehistory.OtaIDEQ(*params.OtaID))
+	}
+
+	total, err := query.Count(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Order(ent.Desc(devicefirmwarehistory.FieldCreatedAt))
+	pageNo, _ := page.GetPageNo()
+	pageSize := page.GetPageSize()
+	if pageNo > 0 && pageSize > 0 {
+		query = query.Offset((pageNo - 1) * pageSize).Limit(pageSize)
+	}
+
+	entities, err := query.All(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	result := make([]*biz.FirmwareHistory, 0, len(entities))
+	for _, entity := range entities {
+		result = append(result, toBizFirmwareHistory(entity))
+	}
+	return result, total, nil
+}
+
+// CountOutcomesByOta 按固件统计各升级结果的记录数
+func (r *firmwareHistoryRepo) CountOutcomesByOta(ctx context.Context, otaIds []string) (map[string]map[string]int64, error) {
+	result := make(map[string]map[string]int64)
+	if len(otaIds) == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		OtaID   string `json:"ota_id"`
+		Outcome string `json:"outcome"`
+		Count   int64  `json:"count"`
+	}
+	err := r.data.db.DeviceFirmwareHistory.Query().
+		Where(devicefirmwarehistory.OtaIDIn(otaIds...)).
+		GroupBy(devicefirmwarehistory.FieldOtaID, devicefirmwarehistory.FieldOutcome).
+		Aggregate(ent.Count()).
+		Scan(ctx, &rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		if result[row.OtaID] == nil {
+			result[row.OtaID] = make(map[string]int64)
+		}
+		result[row.OtaID][row.Outcome] = row.Count
+	}
+	return result, nil
+}
+
+// ListStuck 查询下发次数不少于minAttempts且仍未升级的记录
+func (r *firmwareHistoryRepo) ListStuck(ctx context.Context, otaIds []string, minAttempts int32) ([]*biz.FirmwareHistory, error) {
+	if len(otaIds) == 0 {
+		return nil, nil
+	}
+
+	entities, err := r.data.db.DeviceFirmwareHistory.Query().
+		Where(
+			devicefirmwarehistory.OtaIDIn(otaIds...),
+			devicefirmwarehistory.OutcomeEQ(biz.FirmwareOutcomePending),
+			devicefirmwarehistory.AttemptsGTE(minAttempts),
+		).
+		Order(ent.Desc(devicefirmwarehistory.FieldAttempts)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*biz.FirmwareHistory, 0, len(entities))
+	for _, entity := range entities {
+		result = append(result, toBizFirmwareHistory(entity))
+	}
+	return result, nil
+}
+
+func toBizFirmwareHistory(entity *ent.DeviceFirmwareHistory) *biz.FirmwareHistory {
+	return &biz.FirmwareHistory{
+		ID:          entity.ID,
+		DeviceID:    entity.DeviceID,
+		MacAddress:  entity.MACAddress,
+		Board:       entity.Board,
+		FromVersion: entity.FromVersion,
+		ToVersion:   entity.ToVersion,
+		OtaID:       entity.OtaID,
+		Outcome:     entity.Outcome,
+		Attempts:    entity.Attempts,
+		CompletedAt: entity.CompletedAt,
+		CreatedAt:   entity.CreatedAt,
+		UpdatedAt:   entity.UpdatedAt,
+	}
+}
@@ -0,0 +1,61 @@
+package data
+
+import (
+	"context"
+	"testing"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/data/ent"
+	"github.com/weetime/agent-matrix/internal/data/ent/migrate"
+
+	"entgo.io/ent/dialect/sql/schema"
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFirmwareHistoryListStuck(t *testing.T) {
+	ctx := context.Background()
+	repo := NewFirmwareHistoryRepo(newTestData(t, migrate.AiDeviceFirmwareHistoryTable), log.DefaultLogger)
+
+	histories := []*biz.FirmwareHistory{
+		{DeviceID: "d1", MacAddress: "d1", OtaID: "ota1", Outcome: biz.FirmwareOutcomePending, Attempts: 3},
+		{DeviceID: "d2", MacAddress: "d2", OtaID: "ota1", Outcome: biz.FirmwareOutcomePending, Attempts: 5},
+		{DeviceID: "d3", MacAddress: "d3", OtaID: "ota1", Outcome: biz.FirmwareOutcomePending, Attempts: 2},
+		{DeviceID: "d4", MacAddress: "d4", OtaID: "ota1", Outcome: biz.FirmwareOutcomeSuccess, Attempts: 4},
+		{DeviceID: "d5", MacAddress: "d5", OtaID: "ota2", Outcome: biz.FirmwareOutcomePending, Attempts: 4},
+	}
+	for _, history := range histories {
+		require.NoError(t, repo.Create(ctx, history))
+	}
+
+	tests := []struct {
+		name        string
+		otaIds      []string
+		minAttempts int32
+		wantDevices []string
+	}{
+		{name: "只返回下发中且次数达到阈值的记录，按次数倒序", otaIds: []string{"ota1"}, minAttempts: 3, wantDevices: []string{"d2", "d1"}},
+		{name: "多个固件", otaIds: []string{"ota1", "ota2"}, minAttempts: 4, wantDevices: []string{"d2", "d5"}},
+		{name: "未指定固件", minAttempts: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stuck, err := repo.ListStuck(ctx, tt.otaIds, tt.minAttempts)
+			require.NoError(t, err)
+			var devices []string
+			for _, history := range stuck {
+				devices = append(devices, history.DeviceID)
+			}
+			require.Equal(t, tt.wantDevices, devices)
+		})
+	}
+}
+
+// newTestData 使用内存SQLite创建指定的表
+func newTestData(t *testing.T, tables ...*schema.Table) *Data {
+	client, err := ent.Open("sqlite3", "file:"+t.Name()+"?mode=memory&cache=shared&_fk=1")
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	require.NoError(t, migrate.Create(context.Background(), client.Schema, tables))
+	return &Data{db: client}
+}
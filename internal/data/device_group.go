@@ -0,0 +1,222 @@
+package data
+
+import (
+	"context"
+	"strings"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/data/ent"
+	"github.com/weetime/agent-matrix/internal/data/ent/devicegroup"
+	"github.com/weetime/agent-matrix/internal/data/ent/devicegroupmember"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/google/uuid"
+)
+
+type deviceGroupRepo struct {
+	data *Data
+	log  *log.Helper
+}
+
+// NewDeviceGroupRepo 初始化设备分组Repo
+func NewDeviceGroupRepo(data *Data, logger log.Logger) biz.DeviceGroupRepo {
+	return &deviceGroupRepo{
+		data: data,
+		log:  log.NewHelper(log.With(logger, "module", "agent-matrix-service/data/device_group")),
+	}
+}
+
+// Create 创建分组
+func (r *deviceGroupRepo) Create(ctx context.Context, group *biz.DeviceGroup) error {
+	group.ID = strings.ReplaceAll(uuid.New().String(), "-", "")
+	entity, err := r.data.db.DeviceGroup.Create().
+		SetID(group.ID).
+		SetName(group.Name).
+		SetDescription(group.Description).
+		SetUserID(group.UserID).
+		SetCreator(group.Creator).
+		Save(ctx)
+	if err != nil {
+		return err
+	}
+	group.CreatedAt = entity.CreatedAt
+	group.UpdatedAt = entity.UpdatedAt
+	return nil
+}
+
+// Update 更新分组名称和描述
+func (r *deviceGroupRepo) Update(ctx context.Context, group *biz.DeviceGroup) error {
+	return r.data.db.DeviceGroup.UpdateOneID(group.ID).
+		SetName(group.Name).
+		SetDescription(group.Description).
+		Exec(ctx)
+}
+
+// Delete 删除分组及其成员关系
+func (r *deviceGroupRepo) Delete(ctx context.Context, id string) error {
+	tx, err := r.data.db.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.DeviceGroupMember.Delete().Where(devicegroupmember.GroupIDEQ(id)).Exec(ctx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.DeviceGroup.DeleteOneID(id).Exec(ctx); err != nil && !ent.IsNotFound(err) {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetByID 根据ID查询分组
+func (r *deviceGroupRepo) GetByID(ctx context.Context, id string) (*biz.DeviceGroup, error) {
+	entity, err := r.data.db.DeviceGroup.Get(ctx, id)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return toBizDeviceGroup(entity), nil
+}
+
+// GetByName 查询用户下指定名称的分组
+func (r *deviceGroupRepo) GetByName(ctx context.Context, userId int64, name string) (*biz.DeviceGroup, error) {
+	entity, err := r.data.db.DeviceGroup.Query().
+		Where(
+			devicegroup.UserIDEQ(userId),
+			devicegroup.NameEQ(name),
+		).
+		First(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return toBizDeviceGroup(entity), nil
+}
+
+// ListByUserIds 查询指定用户的分组及各分组设备数
+func (r *deviceGroupRepo) ListByUserIds(ctx context.Context, userIds []int64) ([]*biz.DeviceGroup, error) {
+	entities, err := r.data.db.DeviceGroup.Query().
+		Where(devicegroup.UserIDIn(userIds...)).
+		Order(ent.Asc(devicegroup.FieldCreatedAt)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(entities) == 0 {
+		return []*biz.DeviceGroup{}, nil
+	}
+
+	groupIds := make([]string, len(entities))
+	for i, entity := range entities {
+		groupIds[i] = entity.ID
+	}
+	var rows []struct {
+		GroupID string `json:"group_id"`
+		Count   int    `json:"count"`
+	}
+	err = r.data.db.DeviceGroupMember.Query().
+		Where(devicegroupmember.GroupIDIn(groupIds...)).
+		GroupBy(devicegroupmember.FieldGroupID).
+		Aggregate(ent.Count()).
+		Scan(ctx, &rows)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.GroupID] = row.Count
+	}
+
+	result := make([]*biz.DeviceGroup, len(entities))
+	for i, entity := range entities {
+		result[i] = toBizDeviceGroup(entity)
+		result[i].DeviceCount = counts[entity.ID]
+	}
+	return result, nil
+}
+
+// ListDeviceIds 查询分组内的设备ID，按加入时间排序
+func (r *deviceGroupRepo) ListDeviceIds(ctx context.Context, groupId string) ([]string, error) {
+	return r.data.db.DeviceGroupMember.Query().
+		Where(devicegroupmember.GroupIDEQ(groupId)).
+		Order(ent.Asc(devicegroupmember.FieldCreatedAt)).
+		Select(devicegroupmember.FieldDeviceID).
+		Strings(ctx)
+}
+
+// AddDevices 将设备加入分组，已在分组中的设备忽略
+func (r *deviceGroupRepo) AddDevices(ctx context.Context, groupId string, deviceIds []string) error {
+	existing, err := r.data.db.DeviceGroupMember.Query().
+		Where(
+			devicegroupmember.GroupIDEQ(groupId),
+			devicegroupmember.DeviceIDIn(deviceIds...),
+		).
+		Select(devicegroupmember.FieldDeviceID).
+		Strings(ctx)
+	if err != nil {
+		return err
+	}
+	existingSet := make(map[string]bool, len(existing))
+	for _, deviceId := range existing {
+		existingSet[deviceId] = true
+	}
+
+	builders := make([]*ent.DeviceGroupMemberCreate, 0, len(deviceIds))
+	for _, deviceId := range deviceIds {
+		if existingSet[deviceId] {
+			continue
+		}
+		builders = append(builders, r.data.db.DeviceGroupMember.Create().
+			SetID(strings.ReplaceAll(uuid.New().String(), "-", "")).
+			SetGroupID(groupId).
+			SetDeviceID(deviceId))
+	}
+	if len(builders) == 0 {
+		return nil
+	}
+	return r.data.db.DeviceGroupMember.CreateBulk(builders...).
+		OnConflict().
+		DoNothing().
+		Exec(ctx)
+}
+
+// RemoveDevices 将设备移出分组，返回实际移出的设备ID
+func (r *deviceGroupRepo) RemoveDevices(ctx context.Context, groupId string, deviceIds []string) ([]string, error) {
+	removed, err := r.data.db.DeviceGroupMember.Query().
+		Where(
+			devicegroupmember.GroupIDEQ(groupId),
+			devicegroupmember.DeviceIDIn(deviceIds...),
+		).
+		Select(devicegroupmember.FieldDeviceID).
+		Strings(ctx)
+	if err != nil || len(removed) == 0 {
+		return removed, err
+	}
+	_, err = r.data.db.DeviceGroupMember.Delete().
+		Where(
+			devicegroupmember.GroupIDEQ(groupId),
+			devicegroupmember.DeviceIDIn(removed...),
+		).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+func toBizDeviceGroup(entity *ent.DeviceGroup) *biz.DeviceGroup {
+	return &biz.DeviceGroup{
+		ID:          entity.ID,
+		Name:        entity.Name,
+		Description: entity.Description,
+		UserID:      entity.UserID,
+		Creator:     entity.Creator,
+		CreatedAt:   entity.CreatedAt,
+		UpdatedAt:   entity.UpdatedAt,
+	}
+}
@@ -0,0 +1,165 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/data/ent"
+	"github.com/weetime/agent-matrix/internal/data/ent/devicetelemetry"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/google/uuid"
+)
+
+type deviceTelemetryRepo struct {
+	data *Data
+	log  *log.Helper
+}
+
+// NewDeviceTelemetryRepo 初始化设备遥测Repo
+func NewDeviceTelemetryRepo(data *Data, logger log.Logger) biz.DeviceTelemetryRepo {
+	return &deviceTelemetryRepo{
+		data: data,
+		log:  log.NewHelper(log.With(logger, "module", "agent-matrix-service/data/device_telemetry")),
+	}
+}
+
+// Create 保存一次上报，并将该设备此前的上报标记为非最新
+func (r *deviceTelemetryRepo) Create(ctx context.Context, t *biz.DeviceTelemetry) error {
+	var partitionTable string
+	if len(t.PartitionTable) > 0 {
+		data, err := json.Marshal(t.PartitionTable)
+		if err != nil {
+			return err
+		}
+		partitionTable = string(data)
+	}
+
+	tx, err := r.data.db.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.DeviceTelemetry.Update().
+		Where(
+			devicetelemetry.DeviceIDEQ(t.DeviceID),
+			devicetelemetry.LatestEQ(true),
+		).
+		SetLatest(false).
+		Save(ctx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	t.ID = strings.ReplaceAll(uuid.New().String(), "-", "")
+	entity, err := tx.DeviceTelemetry.Create().
+		SetID(t.ID).
+		SetDeviceID(t.DeviceID).
+		SetMACAddress(t.MacAddress).
+		SetBoard(t.Board).
+		SetAppVersion(t.AppVersion).
+		SetChipModelName(t.ChipModelName).
+		SetNillableFlashSize(t.FlashSize).
+		SetNillableMinimumFreeHeapSize(t.MinimumFreeHeapSize).
+		SetIdfVersion(t.IdfVersion).
+		SetPartitionTable(partitionTable).
+		SetOtaLabel(t.OtaLabel).
+		SetSsid(t.SSID).
+		SetNillableRssi(t.RSSI).
+		SetNillableChannel(t.Channel).
+		SetIP(t.IP).
+		SetLatest(true).
+		Save(ctx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	t.CreatedAt = entity.CreatedAt
+	return nil
+}
+
+// ListByDevice 按上报时间倒序查询设备最近的上报
+func (r *deviceTelemetryRepo) ListByDevice(ctx context.Context, deviceId string, limit int) ([]*biz.DeviceTelemetry, error) {
+	entities, err := r.data.db.DeviceTelemetry.Query().
+		Where(devicetelemetry.DeviceIDEQ(deviceId)).
+		Order(ent.Desc(devicetelemetry.FieldCreatedAt)).
+		Limit(limit).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*biz.DeviceTelemetry, len(entities))
+	for i, entity := range entities {
+		result[i] = r.toBizDeviceTelemetry(entity)
+	}
+	return result, nil
+}
+
+// GetLatestByDevices 批量查询设备最新一次上报
+func (r *deviceTelemetryRepo) GetLatestByDevices(ctx context.Context, deviceIds []string) (map[string]*biz.DeviceTelemetry, error) {
+	result := make(map[string]*biz.DeviceTelemetry, len(deviceIds))
+	if len(deviceIds) == 0 {
+		return result, nil
+	}
+	entities, err := r.data.db.DeviceTelemetry.Query().
+		Where(
+			devicetelemetry.DeviceIDIn(deviceIds...),
+			devicetelemetry.LatestEQ(true),
+		).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entity := range entities {
+		// 并发上报时可能短暂存在多条最新记录，取上报时间最晚的一条
+		if existing, ok := result[entity.DeviceID]; ok && existing.CreatedAt.After(entity.CreatedAt) {
+			continue
+		}
+		result[entity.DeviceID] = r.toBizDeviceTelemetry(entity)
+	}
+	return result, nil
+}
+
+// DeleteBefore 删除指定时间之前的历史上报，每台设备最新一次上报不删除
+func (r *deviceTelemetryRepo) DeleteBefore(ctx context.Context, before time.Time) (int, error) {
+	return r.data.db.DeviceTelemetry.Delete().
+		Where(
+			devicetelemetry.CreatedAtLT(before),
+			devicetelemetry.LatestEQ(false),
+		).
+		Exec(ctx)
+}
+
+// toBizDeviceTelemetry 将Ent实体转换为Biz实体
+func (r *deviceTelemetryRepo) toBizDeviceTelemetry(entity *ent.DeviceTelemetry) *biz.DeviceTelemetry {
+	t := &biz.DeviceTelemetry{
+		ID:                  entity.ID,
+		DeviceID:            entity.DeviceID,
+		MacAddress:          entity.MACAddress,
+		Board:               entity.Board,
+		AppVersion:          entity.AppVersion,
+		ChipModelName:       entity.ChipModelName,
+		FlashSize:           entity.FlashSize,
+		MinimumFreeHeapSize: entity.MinimumFreeHeapSize,
+		IdfVersion:          entity.IdfVersion,
+		OtaLabel:            entity.OtaLabel,
+		SSID:                entity.Ssid,
+		RSSI:                entity.Rssi,
+		Channel:             entity.Channel,
+		IP:                  entity.IP,
+		CreatedAt:           entity.CreatedAt,
+	}
+	if entity.PartitionTable != "" {
+		if err := json.Unmarshal([]byte(entity.PartitionTable), &t.PartitionTable); err != nil {
+			r.log.Warnf("解析设备分区表失败, id: %s: %v", entity.ID, err)
+		}
+	}
+	return t
+}
@@ -0,0 +1,217 @@
+package data
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/data/ent"
+	"github.com/weetime/agent-matrix/internal/data/ent/agentchathistory"
+	"github.com/weetime/agent-matrix/internal/data/ent/device"
+	"github.com/weetime/agent-matrix/internal/data/ent/devicegroup"
+	"github.com/weetime/agent-matrix/internal/data/ent/devicegroupmember"
+	"github.com/weetime/agent-matrix/internal/data/ent/devicetransfer"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/google/uuid"
+)
+
+type deviceTransferRepo struct {
+	data *Data
+	log  *log.Helper
+}
+
+// NewDeviceTransferRepo 初始化设备转移Repo
+func NewDeviceTransferRepo(data *Data, logger log.Logger) biz.DeviceTransferRepo {
+	return &deviceTransferRepo{
+		data: data,
+		log:  log.NewHelper(log.With(logger, "module", "agent-matrix-service/data/device_transfer")),
+	}
+}
+
+// Create 创建转移请求
+func (r *deviceTransferRepo) Create(ctx context.Context, transfer *biz.DeviceTransfer) error {
+	transfer.ID = strings.ReplaceAll(uuid.New().String(), "-", "")
+	entity, err := r.data.db.DeviceTransfer.Create().
+		SetID(transfer.ID).
+		SetDeviceID(transfer.DeviceID).
+		SetMACAddress(transfer.MacAddress).
+		SetFromUserID(transfer.FromUserID).
+		SetToUserID(transfer.ToUserID).
+		SetMigrateHistory(transfer.MigrateHistory).
+		SetStatus(transfer.Status).
+		SetExpiresAt(transfer.ExpiresAt).
+		Save(ctx)
+	if err != nil {
+		return err
+	}
+	transfer.CreatedAt = entity.CreatedAt
+	transfer.UpdatedAt = entity.UpdatedAt
+	return nil
+}
+
+// GetByID 根据ID查询转移请求
+func (r *deviceTransferRepo) GetByID(ctx context.Context, id string) (*biz.DeviceTransfer, error) {
+	entity, err := r.data.db.DeviceTransfer.Get(ctx, id)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return toBizDeviceTransfer(entity), nil
+}
+
+// GetPendingByDevice 查询设备待确认的转移请求
+func (r *deviceTransferRepo) GetPendingByDevice(ctx context.Context, deviceId string) (*biz.DeviceTransfer, error) {
+	entity, err := r.data.db.DeviceTransfer.Query().
+		Where(
+			devicetransfer.DeviceIDEQ(deviceId),
+			devicetransfer.StatusEQ(biz.DeviceTransferStatusPending),
+		).
+		Order(ent.Desc(devicetransfer.FieldCreatedAt)).
+		First(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return toBizDeviceTransfer(entity), nil
+}
+
+// ListByUser 查询用户发起或收到的转移请求
+func (r *deviceTransferRepo) ListByUser(ctx context.Context, userId int64, incoming bool) ([]*biz.DeviceTransfer, error) {
+	query := r.data.db.DeviceTransfer.Query()
+	if incoming {
+		query = query.Where(devicetransfer.ToUserIDEQ(userId))
+	} else {
+		query = query.Where(devicetransfer.FromUserIDEQ(userId))
+	}
+	entities, err := query.Order(ent.Desc(devicetransfer.FieldCreatedAt)).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*biz.DeviceTransfer, len(entities))
+	for i, entity := range entities {
+		result[i] = toBizDeviceTransfer(entity)
+	}
+	return result, nil
+}
+
+// UpdateStatus 仅当请求处于fromStatus时更新状态
+func (r *deviceTransferRepo) UpdateStatus(ctx context.Context, id, fromStatus, toStatus string) (bool, error) {
+	updated, err := r.data.db.DeviceTransfer.Update().
+		Where(
+			devicetransfer.IDEQ(id),
+			devicetransfer.StatusEQ(fromStatus),
+		).
+		SetStatus(toStatus).
+		Save(ctx)
+	if err != nil {
+		return false, err
+	}
+	return updated > 0, nil
+}
+
+// Accept 在同一事务中接受转移并变更设备归属
+func (r *deviceTransferRepo) Accept(ctx context.Context, transfer *biz.DeviceTransfer, historyAgentIds []string) (bool, error) {
+	tx, err := r.data.db.Tx(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := tx.DeviceTransfer.Update().
+		Where(
+			devicetransfer.IDEQ(transfer.ID),
+			devicetransfer.StatusEQ(biz.DeviceTransferStatusPending),
+		).
+		SetStatus(biz.DeviceTransferStatusAccepted).
+		SetAgentID(transfer.AgentID).
+		Save(ctx)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if updated == 0 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	// 设备仍属于发起方时才变更归属，避免覆盖并发的解绑或转移
+	updated, err = tx.Device.Update().
+		Where(
+			device.IDEQ(transfer.DeviceID),
+			device.UserIDEQ(transfer.FromUserID),
+		).
+		SetUserID(transfer.ToUserID).
+		SetAgentID(transfer.AgentID).
+		SetUpdater(transfer.ToUserID).
+		SetUpdateDate(time.Now()).
+		Save(ctx)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if updated == 0 {
+		tx.Rollback()
+		return false, nil
+	}
+
+	// 移出原用户的分组，全局分组保留
+	groupIds, err := tx.DeviceGroup.Query().
+		Where(devicegroup.UserIDEQ(transfer.FromUserID)).
+		IDs(ctx)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if len(groupIds) > 0 {
+		_, err = tx.DeviceGroupMember.Delete().
+			Where(
+				devicegroupmember.DeviceIDEQ(transfer.DeviceID),
+				devicegroupmember.GroupIDIn(groupIds...),
+			).
+			Exec(ctx)
+		if err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	if len(historyAgentIds) > 0 && transfer.MacAddress != "" {
+		_, err = tx.AgentChatHistory.Update().
+			Where(
+				agentchathistory.MACAddressEQ(transfer.MacAddress),
+				agentchathistory.AgentIDIn(historyAgentIds...),
+			).
+			SetAgentID(transfer.AgentID).
+			Save(ctx)
+		if err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func toBizDeviceTransfer(entity *ent.DeviceTransfer) *biz.DeviceTransfer {
+	return &biz.DeviceTransfer{
+		ID:             entity.ID,
+		DeviceID:       entity.DeviceID,
+		MacAddress:     entity.MACAddress,
+		FromUserID:     entity.FromUserID,
+		ToUserID:       entity.ToUserID,
+		AgentID:        entity.AgentID,
+		MigrateHistory: entity.MigrateHistory,
+		Status:         entity.Status,
+		ExpiresAt:      entity.ExpiresAt,
+		CreatedAt:      entity.CreatedAt,
+		UpdatedAt:      entity.UpdatedAt,
+	}
+}
@@ -0,0 +1,70 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// AgentDataset holds the schema definition for the AgentDataset entity.
+type AgentDataset struct {
+	ent.Schema
+}
+
+// Fields of the AgentDataset.
+func (AgentDataset) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("id").
+			MaxLen(32).
+			Unique().
+			Immutable().
+			Comment("主键"),
+		field.String("agent_id").
+			MaxLen(32).
+			Comment("智能体ID"),
+		field.String("dataset_id").
+			MaxLen(64).
+			Comment("知识库ID（RAG侧数据集ID）"),
+		field.Int32("sort").
+			Default(0).
+			Comment("排序"),
+		field.Int64("creator").
+			Optional().
+			Comment("创建者"),
+		field.Time("created_at").
+			Default(time.Now).
+			Immutable().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("创建时间"),
+	}
+}
+
+// Edges of the AgentDataset.
+func (AgentDataset) Edges() []ent.Edge {
+	return nil
+}
+
+// Indexes of the AgentDataset.
+func (AgentDataset) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("agent_id", "dataset_id").
+			Unique().
+			StorageKey("uk_agent_dataset"),
+		index.Fields("dataset_id").
+			StorageKey("idx_dataset_id"),
+	}
+}
+
+func (AgentDataset) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.Annotation{Table: "ai_agent_dataset"},
+	}
+}
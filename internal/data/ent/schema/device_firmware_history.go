@@ -0,0 +1,103 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// DeviceFirmwareHistory holds the schema definition for the DeviceFirmwareHistory entity.
+type DeviceFirmwareHistory struct {
+	ent.Schema
+}
+
+// Fields of the DeviceFirmwareHistory.
+func (DeviceFirmwareHistory) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("id").
+			MaxLen(32).
+			Unique().
+			Immutable().
+			Comment("主键"),
+		field.String("device_id").
+			MaxLen(32).
+			Comment("设备ID"),
+		field.String("mac_address").
+			MaxLen(50).
+			Comment("设备MAC地址"),
+		field.String("board").
+			MaxLen(50).
+			Optional().
+			Comment("设备硬件型号（固件类型）"),
+		field.String("from_version").
+			MaxLen(50).
+			Optional().
+			Comment("升级前版本"),
+		field.String("to_version").
+			MaxLen(50).
+			Optional().
+			Comment("升级后版本（下发中为下发的目标版本）"),
+		field.String("ota_id").
+			MaxLen(32).
+			Optional().
+			Comment("下发的固件ID，非OTA升级时为空"),
+		field.String("outcome").
+			MaxLen(20).
+			Comment("结果：pending/success/mismatch/superseded/manual"),
+		field.Int32("attempts").
+			Default(0).
+			Comment("下发次数（设备仍为升级前版本时每次检查累加）"),
+		field.Time("completed_at").
+			Optional().
+			Nillable().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("结果确定时间"),
+		field.Time("created_at").
+			Default(time.Now).
+			Immutable().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("创建时间（首次下发或版本变更时间）"),
+		field.Time("updated_at").
+			Default(time.Now).
+			UpdateDefault(time.Now).
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("更新时间（最近一次下发时间）"),
+	}
+}
+
+// Edges of the DeviceFirmwareHistory.
+func (DeviceFirmwareHistory) Edges() []ent.Edge {
+	return nil
+}
+
+// Indexes of the DeviceFirmwareHistory.
+func (DeviceFirmwareHistory) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("device_id", "outcome").
+			StorageKey("idx_device_outcome"),
+		index.Fields("mac_address").
+			StorageKey("idx_mac_address"),
+		index.Fields("ota_id", "outcome").
+			StorageKey("idx_ota_outcome"),
+	}
+}
+
+func (DeviceFirmwareHistory) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.Annotation{Table: "ai_device_firmware_history"},
+	}
+}
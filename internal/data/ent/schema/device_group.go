@@ -0,0 +1,77 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// DeviceGroup holds the schema definition for the DeviceGroup entity.
+type DeviceGroup struct {
+	ent.Schema
+}
+
+// Fields of the DeviceGroup.
+func (DeviceGroup) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("id").
+			MaxLen(32).
+			Unique().
+			Immutable().
+			Comment("主键"),
+		field.String("name").
+			MaxLen(64).
+			Comment("分组名称"),
+		field.String("description").
+			MaxLen(255).
+			Optional().
+			Comment("分组描述"),
+		field.Int64("user_id").
+			Default(0).
+			Comment("所属用户ID，0表示管理员创建的全局分组"),
+		field.Int64("creator").
+			Optional().
+			Comment("创建者ID"),
+		field.Time("created_at").
+			Default(time.Now).
+			Immutable().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("创建时间"),
+		field.Time("updated_at").
+			Default(time.Now).
+			UpdateDefault(time.Now).
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("更新时间"),
+	}
+}
+
+// Edges of the DeviceGroup.
+func (DeviceGroup) Edges() []ent.Edge {
+	return nil
+}
+
+// Indexes of the DeviceGroup.
+func (DeviceGroup) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("user_id", "name").
+			Unique().
+			StorageKey("uk_user_name"),
+	}
+}
+
+func (DeviceGroup) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.Annotation{Table: "ai_device_group"},
+	}
+}
@@ -0,0 +1,64 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// DeviceGroupMember holds the schema definition for the DeviceGroupMember entity.
+type DeviceGroupMember struct {
+	ent.Schema
+}
+
+// Fields of the DeviceGroupMember.
+func (DeviceGroupMember) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("id").
+			MaxLen(32).
+			Unique().
+			Immutable().
+			Comment("主键"),
+		field.String("group_id").
+			MaxLen(32).
+			Comment("分组ID"),
+		field.String("device_id").
+			MaxLen(32).
+			Comment("设备ID"),
+		field.Time("created_at").
+			Default(time.Now).
+			Immutable().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("加入时间"),
+	}
+}
+
+// Edges of the DeviceGroupMember.
+func (DeviceGroupMember) Edges() []ent.Edge {
+	return nil
+}
+
+// Indexes of the DeviceGroupMember.
+func (DeviceGroupMember) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("group_id", "device_id").
+			Unique().
+			StorageKey("uk_group_device"),
+		index.Fields("device_id").
+			StorageKey("idx_device_id"),
+	}
+}
+
+func (DeviceGroupMember) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.Annotation{Table: "ai_device_group_member"},
+	}
+}
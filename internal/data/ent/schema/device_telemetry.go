@@ -0,0 +1,116 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// DeviceTelemetry holds the schema definition for the DeviceTelemetry entity.
+type DeviceTelemetry struct {
+	ent.Schema
+}
+
+// Fields of the DeviceTelemetry.
+func (DeviceTelemetry) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("id").
+			MaxLen(32).
+			Unique().
+			Immutable().
+			Comment("主键"),
+		field.String("device_id").
+			MaxLen(32).
+			Comment("设备ID"),
+		field.String("mac_address").
+			MaxLen(50).
+			Optional().
+			Comment("MAC地址"),
+		field.String("board").
+			MaxLen(50).
+			Optional().
+			Comment("设备硬件型号"),
+		field.String("app_version").
+			MaxLen(50).
+			Optional().
+			Comment("固件版本号"),
+		field.String("chip_model_name").
+			MaxLen(50).
+			Optional().
+			Comment("芯片型号"),
+		field.Int32("flash_size").
+			Optional().
+			Nillable().
+			Comment("Flash大小(字节)"),
+		field.Int32("minimum_free_heap_size").
+			Optional().
+			Nillable().
+			Comment("最小剩余堆内存(字节)"),
+		field.String("idf_version").
+			MaxLen(50).
+			Optional().
+			Comment("ESP-IDF版本"),
+		field.Text("partition_table").
+			Optional().
+			Comment("分区表(JSON)"),
+		field.String("ota_label").
+			MaxLen(20).
+			Optional().
+			Comment("当前运行的OTA分区"),
+		field.String("ssid").
+			MaxLen(64).
+			Optional().
+			Comment("连接的WiFi名称"),
+		field.Int32("rssi").
+			Optional().
+			Nillable().
+			Comment("WiFi信号强度(dBm)"),
+		field.Int32("channel").
+			Optional().
+			Nillable().
+			Comment("WiFi信道"),
+		field.String("ip").
+			MaxLen(64).
+			Optional().
+			Comment("设备IP"),
+		field.Bool("latest").
+			Default(true).
+			Comment("是否为设备最新一次上报"),
+		field.Time("created_at").
+			Default(time.Now).
+			Immutable().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("上报时间"),
+	}
+}
+
+// Edges of the DeviceTelemetry.
+func (DeviceTelemetry) Edges() []ent.Edge {
+	return nil
+}
+
+// Indexes of the DeviceTelemetry.
+func (DeviceTelemetry) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("device_id", "created_at").
+			StorageKey("idx_device_created_at"),
+		index.Fields("latest", "device_id").
+			StorageKey("idx_latest_device"),
+		index.Fields("created_at").
+			StorageKey("idx_created_at"),
+	}
+}
+
+func (DeviceTelemetry) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.Annotation{Table: "ai_device_telemetry"},
+	}
+}
@@ -0,0 +1,95 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// DeviceTransfer holds the schema definition for the DeviceTransfer entity.
+type DeviceTransfer struct {
+	ent.Schema
+}
+
+// Fields of the DeviceTransfer.
+func (DeviceTransfer) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("id").
+			MaxLen(32).
+			Unique().
+			Immutable().
+			Comment("主键"),
+		field.String("device_id").
+			MaxLen(32).
+			Comment("设备ID"),
+		field.String("mac_address").
+			MaxLen(50).
+			Optional().
+			Comment("MAC地址"),
+		field.Int64("from_user_id").
+			Comment("转出用户ID"),
+		field.Int64("to_user_id").
+			Comment("接收用户ID"),
+		field.String("agent_id").
+			MaxLen(32).
+			Optional().
+			Comment("接收方指定的智能体ID"),
+		field.Bool("migrate_history").
+			Default(false).
+			Comment("是否将设备的聊天记录迁移给接收方"),
+		field.String("status").
+			MaxLen(20).
+			Default("pending").
+			Comment("状态：pending/accepted/rejected/cancelled/expired"),
+		field.Time("expires_at").
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("过期时间，超时未接受自动失效"),
+		field.Time("created_at").
+			Default(time.Now).
+			Immutable().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("创建时间"),
+		field.Time("updated_at").
+			Default(time.Now).
+			UpdateDefault(time.Now).
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("更新时间"),
+	}
+}
+
+// Edges of the DeviceTransfer.
+func (DeviceTransfer) Edges() []ent.Edge {
+	return nil
+}
+
+// Indexes of the DeviceTransfer.
+func (DeviceTransfer) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("device_id", "status").
+			StorageKey("idx_device_status"),
+		index.Fields("from_user_id").
+			StorageKey("idx_from_user_id"),
+		index.Fields("to_user_id").
+			StorageKey("idx_to_user_id"),
+	}
+}
+
+func (DeviceTransfer) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.Annotation{Table: "ai_device_transfer"},
+	}
+}
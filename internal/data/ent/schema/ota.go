@@ -46,6 +46,18 @@ func (Ota) Fields() []ent.Field {
 			MaxLen(255).
 			Optional().
 			Comment("固件路径"),
+		field.String("sha256").
+			MaxLen(64).
+			Optional().
+			Comment("固件SHA-256（十六进制）"),
+		field.String("signature").
+			MaxLen(128).
+			Optional().
+			Comment("固件签名（Ed25519，Base64）"),
+		field.String("sign_key_id").
+			MaxLen(32).
+			Optional().
+			Comment("签名公钥标识"),
 		field.Int32("sort").
 			Default(0).
 			Comment("排序"),
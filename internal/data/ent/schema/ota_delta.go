@@ -0,0 +1,94 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// OtaDelta holds the schema definition for the OtaDelta entity.
+type OtaDelta struct {
+	ent.Schema
+}
+
+// Fields of the OtaDelta.
+func (OtaDelta) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("id").
+			MaxLen(32).
+			Unique().
+			Immutable().
+			Comment("主键"),
+		field.String("type").
+			MaxLen(50).
+			Comment("固件类型"),
+		field.String("from_ota_id").
+			MaxLen(32).
+			Comment("源固件ID（设备当前版本）"),
+		field.String("to_ota_id").
+			MaxLen(32).
+			Comment("目标固件ID"),
+		field.String("from_version").
+			MaxLen(50).
+			Comment("源版本"),
+		field.String("to_version").
+			MaxLen(50).
+			Comment("目标版本"),
+		field.String("from_sha256").
+			MaxLen(64).
+			Comment("生成时源固件的SHA-256，固件文件变化后差分包失效"),
+		field.String("to_sha256").
+			MaxLen(64).
+			Comment("生成时目标固件的SHA-256"),
+		field.String("patch_path").
+			MaxLen(255).
+			Optional().
+			Comment("差分包存储路径"),
+		field.Int64("size").
+			Default(0).
+			Comment("差分包大小(字节)"),
+		field.String("sha256").
+			MaxLen(64).
+			Optional().
+			Comment("差分包SHA-256"),
+		field.String("status").
+			MaxLen(20).
+			Default("ready").
+			Comment("状态：ready可下发/skipped差分包过大不下发"),
+		field.Time("created_at").
+			Default(time.Now).
+			Immutable().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("创建时间"),
+	}
+}
+
+// Edges of the OtaDelta.
+func (OtaDelta) Edges() []ent.Edge {
+	return nil
+}
+
+// Indexes of the OtaDelta.
+func (OtaDelta) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("from_ota_id", "to_ota_id").
+			Unique().
+			StorageKey("uk_from_to"),
+		index.Fields("to_ota_id").
+			StorageKey("idx_to_ota_id"),
+	}
+}
+
+func (OtaDelta) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.Annotation{Table: "ai_ota_delta"},
+	}
+}
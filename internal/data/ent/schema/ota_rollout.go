@@ -0,0 +1,115 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// OtaRollout holds the schema definition for the OtaRollout entity.
+type OtaRollout struct {
+	ent.Schema
+}
+
+// Fields of the OtaRollout.
+func (OtaRollout) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("id").
+			MaxLen(32).
+			Unique().
+			Immutable().
+			Comment("主键"),
+		field.String("ota_id").
+			MaxLen(32).
+			Comment("灰度发布的目标固件ID"),
+		field.String("type").
+			MaxLen(50).
+			Comment("固件类型"),
+		field.Int32("percentage").
+			Default(0).
+			Comment("目标灰度比例（0-100）"),
+		field.Int32("start_percentage").
+			Default(0).
+			Comment("起始灰度比例（0-100）"),
+		field.Int32("ramp_minutes").
+			Default(0).
+			Comment("从起始比例爬升到目标比例的时长（分钟），0表示立即生效"),
+		field.JSON("mac_addresses", []string{}).
+			Optional().
+			Comment("指定灰度的设备MAC地址"),
+		field.Int64("user_id").
+			Optional().
+			Comment("限定灰度范围的用户ID"),
+		field.String("agent_id").
+			MaxLen(32).
+			Optional().
+			Comment("限定灰度范围的智能体ID"),
+		field.String("status").
+			MaxLen(20).
+			Default("active").
+			Comment("状态：active/paused/completed/rolled_back"),
+		field.String("rollback_ota_id").
+			MaxLen(32).
+			Optional().
+			Comment("回滚使用的固件ID"),
+		field.Time("started_at").
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("灰度开始时间（恢复时顺延暂停时长）"),
+		field.Time("paused_at").
+			Optional().
+			Nillable().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("暂停时间"),
+		field.Int64("creator").
+			Optional().
+			Comment("创建者"),
+		field.Time("created_at").
+			Default(time.Now).
+			Immutable().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("创建时间"),
+		field.Time("updated_at").
+			Default(time.Now).
+			UpdateDefault(time.Now).
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("更新时间"),
+	}
+}
+
+// Edges of the OtaRollout.
+func (OtaRollout) Edges() []ent.Edge {
+	return nil
+}
+
+// Indexes of the OtaRollout.
+func (OtaRollout) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("type", "status").
+			StorageKey("idx_type_status"),
+		index.Fields("ota_id").
+			StorageKey("idx_ota_id"),
+	}
+}
+
+func (OtaRollout) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.Annotation{Table: "ai_ota_rollout"},
+	}
+}
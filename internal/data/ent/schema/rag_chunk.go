@@ -0,0 +1,79 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// RagChunk holds the schema definition for the RagChunk entity.
+// 本地知识库适配器的文档切片表，向量以float32小端序字节存储
+type RagChunk struct {
+	ent.Schema
+}
+
+// Fields of the RagChunk.
+func (RagChunk) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("id").
+			MaxLen(32).
+			Unique().
+			Immutable().
+			Comment("切片ID"),
+		field.String("dataset_id").
+			MaxLen(64).
+			Comment("知识库ID"),
+		field.String("document_id").
+			MaxLen(32).
+			Comment("文档ID"),
+		field.Int("position").
+			Default(0).
+			Comment("切片在文档中的序号"),
+		field.Text("content").
+			Comment("切片内容"),
+		field.Bytes("embedding").
+			Optional().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "mediumblob",
+				dialect.Postgres: "bytea",
+			}).
+			Comment("向量（float32小端序）"),
+		field.Int("token_count").
+			Default(0).
+			Comment("Token数量"),
+		field.Time("created_at").
+			Default(time.Now).
+			Immutable().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("创建时间"),
+	}
+}
+
+// Edges of the RagChunk.
+func (RagChunk) Edges() []ent.Edge {
+	return nil
+}
+
+// Indexes of the RagChunk.
+func (RagChunk) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("document_id", "position").
+			StorageKey("idx_ai_rag_chunk_document_position"),
+		index.Fields("dataset_id").
+			StorageKey("idx_ai_rag_chunk_dataset_id"),
+	}
+}
+
+func (RagChunk) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.Annotation{Table: "ai_rag_chunk"},
+	}
+}
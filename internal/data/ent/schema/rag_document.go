@@ -0,0 +1,129 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// RagDocument holds the schema definition for the RagDocument entity.
+// 本地知识库适配器的文档表，原始文件内容直接存储在数据库中
+type RagDocument struct {
+	ent.Schema
+}
+
+// Fields of the RagDocument.
+func (RagDocument) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("id").
+			MaxLen(32).
+			Unique().
+			Immutable().
+			Comment("文档ID"),
+		field.String("dataset_id").
+			MaxLen(64).
+			Comment("知识库ID"),
+		field.String("name").
+			MaxLen(255).
+			Comment("文档名称"),
+		field.String("file_type").
+			MaxLen(32).
+			Optional().
+			Comment("文件类型（扩展名）"),
+		field.Int64("file_size").
+			Default(0).
+			Comment("文件大小（字节）"),
+		field.Bytes("content").
+			Optional().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "longblob",
+				dialect.Postgres: "bytea",
+			}).
+			Comment("原始文件内容"),
+		field.String("chunk_method").
+			MaxLen(32).
+			Optional().
+			Comment("分块方法"),
+		field.String("parser_config").
+			SchemaType(map[string]string{
+				dialect.MySQL:    "json",
+				dialect.Postgres: "jsonb",
+			}).
+			Optional().
+			Comment("解析器配置"),
+		field.String("meta_fields").
+			SchemaType(map[string]string{
+				dialect.MySQL:    "json",
+				dialect.Postgres: "jsonb",
+			}).
+			Optional().
+			Comment("元数据字段"),
+		field.Int32("status").
+			Default(0).
+			Comment("解析状态：0未开始 1进行中 2已取消 3已完成 4失败"),
+		field.String("run").
+			MaxLen(16).
+			Default("UNSTART").
+			Comment("运行状态"),
+		field.Float("progress").
+			Default(0).
+			Comment("解析进度（0-1）"),
+		field.Text("progress_msg").
+			Optional().
+			Comment("解析进度信息"),
+		field.Int64("chunk_count").
+			Default(0).
+			Comment("切片数量"),
+		field.Int64("token_count").
+			Default(0).
+			Comment("Token数量"),
+		field.Int64("creator").
+			Optional().
+			Comment("创建者ID"),
+		field.Time("created_at").
+			Default(time.Now).
+			Immutable().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("创建时间"),
+		field.Int64("updater").
+			Optional().
+			Comment("更新者ID"),
+		field.Time("updated_at").
+			Default(time.Now).
+			UpdateDefault(time.Now).
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("更新时间"),
+	}
+}
+
+// Edges of the RagDocument.
+func (RagDocument) Edges() []ent.Edge {
+	return nil
+}
+
+// Indexes of the RagDocument.
+func (RagDocument) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("dataset_id", "status").
+			StorageKey("idx_ai_rag_document_dataset_status"),
+		index.Fields("created_at").
+			StorageKey("idx_ai_rag_document_created_at"),
+	}
+}
+
+func (RagDocument) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.Annotation{Table: "ai_rag_document"},
+	}
+}
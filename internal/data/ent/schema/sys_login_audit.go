@@ -0,0 +1,79 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// SysLoginAudit holds the schema definition for the SysLoginAudit entity.
+type SysLoginAudit struct {
+	ent.Schema
+}
+
+// Fields of the SysLoginAudit.
+func (SysLoginAudit) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			Comment("id"),
+		field.String("event").
+			MaxLen(20).
+			Comment("事件：lock-锁定 unlock-解锁"),
+		field.String("scope").
+			MaxLen(20).
+			Comment("锁定范围：user-用户名 ip-IP"),
+		field.String("username").
+			MaxLen(100).
+			Optional().
+			Comment("登录用户名"),
+		field.String("ip").
+			MaxLen(64).
+			Optional().
+			Comment("客户端IP"),
+		field.Int("fail_count").
+			Default(0).
+			Comment("锁定时累计的失败次数"),
+		field.Int("lock_seconds").
+			Default(0).
+			Comment("锁定时长（秒）"),
+		field.Int64("operator").
+			Default(0).
+			Comment("解锁的管理员ID，系统锁定时为0"),
+		field.Time("create_date").
+			Default(time.Now).
+			Immutable().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("创建时间"),
+	}
+}
+
+// Edges of the SysLoginAudit.
+func (SysLoginAudit) Edges() []ent.Edge {
+	return nil
+}
+
+// Indexes of the SysLoginAudit.
+func (SysLoginAudit) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("username").
+			StorageKey("idx_username"),
+		index.Fields("ip").
+			StorageKey("idx_ip"),
+		index.Fields("create_date").
+			StorageKey("idx_create_date"),
+	}
+}
+
+func (SysLoginAudit) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.Annotation{Table: "sys_login_audit"},
+	}
+}
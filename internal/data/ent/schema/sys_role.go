@@ -0,0 +1,82 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// SysRole holds the schema definition for the SysRole entity.
+type SysRole struct {
+	ent.Schema
+}
+
+// Fields of the SysRole.
+func (SysRole) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			Comment("id"),
+		field.String("code").
+			MaxLen(32).
+			Comment("角色编码"),
+		field.String("name").
+			MaxLen(64).
+			Comment("角色名称"),
+		field.Text("permissions").
+			Optional().
+			Comment("权限编码，逗号分隔"),
+		field.String("remark").
+			MaxLen(255).
+			Optional().
+			Comment("备注"),
+		field.Bool("builtin").
+			Default(false).
+			Comment("是否内置角色"),
+		field.Int64("creator").
+			Optional().
+			Comment("创建者ID"),
+		field.Time("create_date").
+			Optional().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("创建时间"),
+		field.Int64("updater").
+			Optional().
+			Comment("更新者ID"),
+		field.Time("update_date").
+			Optional().
+			UpdateDefault(time.Now).
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("更新时间"),
+	}
+}
+
+// Edges of the SysRole.
+func (SysRole) Edges() []ent.Edge {
+	return nil
+}
+
+// Indexes of the SysRole.
+func (SysRole) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("code").
+			Unique().
+			StorageKey("uk_code"),
+	}
+}
+
+func (SysRole) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.Annotation{Table: "sys_role"},
+	}
+}
@@ -0,0 +1,78 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// SysUserIdentity holds the schema definition for the SysUserIdentity entity.
+type SysUserIdentity struct {
+	ent.Schema
+}
+
+// Fields of the SysUserIdentity.
+func (SysUserIdentity) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("id").
+			MaxLen(32).
+			Unique().
+			Immutable().
+			Comment("主键"),
+		field.Int64("user_id").
+			Comment("用户id"),
+		field.String("issuer").
+			MaxLen(255).
+			Comment("身份提供方的Issuer"),
+		field.String("subject").
+			MaxLen(255).
+			Comment("身份提供方的用户标识(sub)"),
+		field.String("email").
+			MaxLen(255).
+			Optional().
+			Comment("身份提供方返回的邮箱"),
+		field.Time("last_login_at").
+			Optional().
+			Nillable().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("最近一次单点登录时间"),
+		field.Time("create_date").
+			Default(time.Now).
+			Immutable().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("创建时间"),
+	}
+}
+
+// Edges of the SysUserIdentity.
+func (SysUserIdentity) Edges() []ent.Edge {
+	return nil
+}
+
+// Indexes of the SysUserIdentity.
+func (SysUserIdentity) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("issuer", "subject").
+			Unique().
+			StorageKey("uk_issuer_subject"),
+		index.Fields("user_id").
+			StorageKey("idx_user_id"),
+	}
+}
+
+func (SysUserIdentity) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.Annotation{Table: "sys_user_identity"},
+	}
+}
@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+	"entgo.io/ent/schema/index"
+)
+
+// SysUserRole holds the schema definition for the SysUserRole entity.
+type SysUserRole struct {
+	ent.Schema
+}
+
+// Fields of the SysUserRole.
+func (SysUserRole) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			Comment("id"),
+		field.Int64("user_id").
+			Comment("用户id"),
+		field.Int64("role_id").
+			Comment("角色id"),
+		field.Time("create_date").
+			Default(time.Now).
+			Immutable().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("创建时间"),
+	}
+}
+
+// Edges of the SysUserRole.
+func (SysUserRole) Edges() []ent.Edge {
+	return nil
+}
+
+// Indexes of the SysUserRole.
+func (SysUserRole) Indexes() []ent.Index {
+	return []ent.Index{
+		index.Fields("user_id", "role_id").
+			Unique().
+			StorageKey("uk_user_role"),
+		index.Fields("role_id").
+			StorageKey("idx_role_id"),
+	}
+}
+
+func (SysUserRole) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.Annotation{Table: "sys_user_role"},
+	}
+}
@@ -26,6 +26,26 @@ func (SysUserToken) Fields() []ent.Field {
 		field.String("token").
 			MaxLen(100).
 			Comment("用户token"),
+		field.String("client_hash").
+			MaxLen(64).
+			Optional().
+			Comment("客户端指纹摘要，token只能由该客户端使用"),
+		field.String("user_agent").
+			MaxLen(255).
+			Optional().
+			Comment("登录时的User-Agent"),
+		field.String("ip").
+			MaxLen(64).
+			Optional().
+			Comment("最近访问IP"),
+		field.Time("last_active_at").
+			Optional().
+			Nillable().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("最近访问时间"),
 		field.Time("expire_date").
 			Optional().
 			SchemaType(map[string]string{
@@ -60,8 +80,7 @@ func (SysUserToken) Edges() []ent.Edge {
 func (SysUserToken) Indexes() []ent.Index {
 	return []ent.Index{
 		index.Fields("user_id").
-			Unique().
-			StorageKey("user_id"),
+			StorageKey("idx_user_id"),
 		index.Fields("token").
 			Unique().
 			StorageKey("token"),
@@ -0,0 +1,72 @@
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/dialect"
+	"entgo.io/ent/dialect/entsql"
+	"entgo.io/ent/schema"
+	"entgo.io/ent/schema/field"
+)
+
+// SysUserTotp holds the schema definition for the SysUserTotp entity.
+type SysUserTotp struct {
+	ent.Schema
+}
+
+// Fields of the SysUserTotp.
+func (SysUserTotp) Fields() []ent.Field {
+	return []ent.Field{
+		field.Int64("id").
+			Immutable().
+			Comment("用户id"),
+		field.String("secret").
+			MaxLen(64).
+			Comment("Base32编码的TOTP密钥"),
+		field.Bool("enabled").
+			Default(false).
+			Comment("是否已启用，绑定时校验通过动态码后启用"),
+		field.Text("recovery_codes").
+			Optional().
+			Comment("未使用的恢复码SHA-256摘要，逗号分隔"),
+		field.Int64("last_used_step").
+			Default(0).
+			Comment("最近一次使用的动态码时间步，同一动态码不能重复使用"),
+		field.Time("enabled_at").
+			Optional().
+			Nillable().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("启用时间"),
+		field.Time("update_date").
+			Default(time.Now).
+			UpdateDefault(time.Now).
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("更新时间"),
+		field.Time("create_date").
+			Default(time.Now).
+			Immutable().
+			SchemaType(map[string]string{
+				dialect.MySQL:    "datetime",
+				dialect.Postgres: "timestamp",
+			}).
+			Comment("创建时间"),
+	}
+}
+
+// Edges of the SysUserTotp.
+func (SysUserTotp) Edges() []ent.Edge {
+	return nil
+}
+
+func (SysUserTotp) Annotations() []schema.Annotation {
+	return []schema.Annotation{
+		entsql.Annotation{Table: "sys_user_totp"},
+	}
+}
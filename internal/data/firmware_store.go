@@ -0,0 +1,278 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/conf"
+	"github.com/weetime/agent-matrix/internal/kit"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+const (
+	defaultFirmwareDir     = "uploadfile"
+	defaultPresignExpires  = time.Hour
+	firmwareStoreDriverS3  = "s3"
+	firmwareStoreDriverFS  = "local"
+	firmwareFallbackDir    = "firmware"
+	firmwareObjectMimeType = "application/octet-stream"
+)
+
+// NewFirmwareStore 根据配置初始化固件存储，未配置时使用本地uploadfile目录
+func NewFirmwareStore(c *conf.Bootstrap, logger log.Logger) (biz.FirmwareStore, error) {
+	helper := log.NewHelper(log.With(logger, "module", "agent-matrix-service/data/firmware_store"))
+	storeConf := c.GetData().GetFirmwareStore()
+
+	switch storeConf.GetDriver() {
+	case "", firmwareStoreDriverFS:
+		dir := storeConf.GetDir()
+		if dir == "" {
+			dir = defaultFirmwareDir
+		}
+		if storeConf.GetPresign() {
+			helper.Warn("本地固件存储不支持预签名下载，设备仍通过下载接口获取固件")
+		}
+		return &localFirmwareStore{dir: dir}, nil
+	case firmwareStoreDriverS3:
+		s3Conf := storeConf.GetS3()
+		client, err := kit.NewS3Client(kit.S3Config{
+			Endpoint:  s3Conf.GetEndpoint(),
+			Region:    s3Conf.GetRegion(),
+			Bucket:    s3Conf.GetBucket(),
+			AccessKey: s3Conf.GetAccessKey(),
+			SecretKey: s3Conf.GetSecretKey(),
+			PathStyle: s3Conf.GetPathStyle(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("初始化固件对象存储失败: %w", err)
+		}
+		prefix := s3Conf.GetPrefix()
+		if prefix == "" {
+			// 与本地存储的路径保持一致，迁移时直接把uploadfile目录同步到bucket即可
+			prefix = defaultFirmwareDir + "/"
+		}
+		expires := storeConf.GetPresignExpires().AsDuration()
+		if expires <= 0 {
+			expires = defaultPresignExpires
+		}
+		helper.Infof("固件存储使用S3兼容对象存储, endpoint: %s, bucket: %s", s3Conf.GetEndpoint(), s3Conf.GetBucket())
+		return &s3FirmwareStore{
+			client:         client,
+			prefix:         prefix,
+			presign:        storeConf.GetPresign(),
+			presignExpires: expires,
+		}, nil
+	default:
+		return nil, fmt.Errorf("不支持的固件存储类型: %s", storeConf.GetDriver())
+	}
+}
+
+// localFirmwareStore 本地目录固件存储，仅适用于单副本部署
+type localFirmwareStore struct {
+	dir string
+}
+
+func (s *localFirmwareStore) Put(ctx context.Context, name string, data []byte) (string, error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return "", fmt.Errorf("创建上传目录失败: %w", err)
+	}
+	filePath := filepath.Join(s.dir, name)
+	if _, err := os.Stat(filePath); err == nil {
+		return filePath, nil
+	}
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		return "", err
+	}
+	return filePath, nil
+}
+
+func (s *localFirmwareStore) Get(ctx context.Context, firmwarePath string) ([]byte, error) {
+	file, err := s.openFile(firmwarePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+func (s *localFirmwareStore) Open(ctx context.Context, firmwarePath string) (biz.FirmwareObject, error) {
+	file, err := s.openFile(firmwarePath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &localFirmwareObject{File: file, info: info}, nil
+}
+
+// openFile 打开固件文件（支持绝对路径和相对当前工作目录的路径），不存在时再从firmware目录按文件名查找
+func (s *localFirmwareStore) openFile(firmwarePath string) (*os.File, error) {
+	filePath := firmwarePath
+	wd, _ := os.Getwd()
+	if !filepath.IsAbs(firmwarePath) {
+		filePath = filepath.Join(wd, firmwarePath)
+	}
+	file, err := os.Open(filePath)
+	if err == nil {
+		return file, nil
+	}
+	return os.Open(filepath.Join(wd, firmwareFallbackDir, filepath.Base(firmwarePath)))
+}
+
+func (s *localFirmwareStore) PresignURL(ctx context.Context, firmwarePath, filename string) (string, error) {
+	return "", nil
+}
+
+type localFirmwareObject struct {
+	*os.File
+	info os.FileInfo
+}
+
+func (o *localFirmwareObject) Size() int64 {
+	return o.info.Size()
+}
+
+func (o *localFirmwareObject) ModTime() time.Time {
+	return o.info.ModTime()
+}
+
+// s3FirmwareStore S3兼容对象存储，FirmwarePath记录对象key
+type s3FirmwareStore struct {
+	client         *kit.S3Client
+	prefix         string
+	presign        bool
+	presignExpires time.Duration
+}
+
+func (s *s3FirmwareStore) Put(ctx context.Context, name string, data []byte) (string, error) {
+	key := path.Join(s.prefix, name)
+	exists, err := s.client.HeadObject(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return key, nil
+	}
+	if err := s.client.PutObject(ctx, key, data, firmwareObjectMimeType); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func (s *s3FirmwareStore) Get(ctx context.Context, firmwarePath string) ([]byte, error) {
+	data, err := s.client.GetObject(ctx, objectKey(firmwarePath))
+	if errors.Is(err, kit.ErrS3ObjectNotFound) {
+		return nil, fmt.Errorf("%w: %s", err, firmwarePath)
+	}
+	return data, err
+}
+
+func (s *s3FirmwareStore) Open(ctx context.Context, firmwarePath string) (biz.FirmwareObject, error) {
+	key := objectKey(firmwarePath)
+	info, err := s.client.StatObject(ctx, key)
+	if errors.Is(err, kit.ErrS3ObjectNotFound) {
+		return nil, fmt.Errorf("%w: %s", err, firmwarePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s3FirmwareObject{ctx: ctx, client: s.client, key: key, info: info}, nil
+}
+
+func (s *s3FirmwareStore) PresignURL(ctx context.Context, firmwarePath, filename string) (string, error) {
+	if !s.presign || firmwarePath == "" {
+		return "", nil
+	}
+	query := url.Values{}
+	if filename != "" {
+		query.Set("response-content-disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	}
+	return s.client.PresignGetObject(objectKey(firmwarePath), s.presignExpires, query)
+}
+
+// s3FirmwareObject 按需发起Range请求读取对象，Seek后从新位置重新请求
+type s3FirmwareObject struct {
+	ctx    context.Context
+	client *kit.S3Client
+	key    string
+	info   *kit.S3ObjectInfo
+	offset int64
+	body   io.ReadCloser
+}
+
+func (o *s3FirmwareObject) Read(p []byte) (int, error) {
+	if o.offset >= o.info.Size {
+		return 0, io.EOF
+	}
+	if o.body == nil {
+		body, err := o.client.GetObjectRange(o.ctx, o.key, o.offset)
+		if err != nil {
+			return 0, err
+		}
+		o.body = body
+	}
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+func (o *s3FirmwareObject) Seek(offset int64, whence int) (int64, error) {
+	var position int64
+	switch whence {
+	case io.SeekStart:
+		position = offset
+	case io.SeekCurrent:
+		position = o.offset + offset
+	case io.SeekEnd:
+		position = o.info.Size + offset
+	default:
+		return 0, fmt.Errorf("无效的whence: %d", whence)
+	}
+	if position < 0 {
+		return 0, fmt.Errorf("无效的偏移量: %d", position)
+	}
+	if position != o.offset {
+		o.closeBody()
+		o.offset = position
+	}
+	return position, nil
+}
+
+func (o *s3FirmwareObject) Size() int64 {
+	return o.info.Size
+}
+
+func (o *s3FirmwareObject) ModTime() time.Time {
+	return o.info.LastModified
+}
+
+func (o *s3FirmwareObject) Close() error {
+	o.closeBody()
+	return nil
+}
+
+func (o *s3FirmwareObject) closeBody() {
+	if o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+}
+
+// objectKey 将FirmwarePath转换为对象key（兼容本地存储记录的./等相对路径写法）
+func objectKey(firmwarePath string) string {
+	return strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(firmwarePath)), "/")
+}
@@ -0,0 +1,42 @@
+package data
+
+import (
+	"context"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/kit"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+type loginAuditRepo struct {
+	data *Data
+	log  *log.Helper
+}
+
+// NewLoginAuditRepo 初始化登录锁定审计Repo
+func NewLoginAuditRepo(data *Data, logger log.Logger) biz.LoginAuditRepo {
+	return &loginAuditRepo{
+		data: data,
+		log:  kit.LogHelper(logger),
+	}
+}
+
+// Create 保存审计记录
+func (r *loginAuditRepo) Create(ctx context.Context, audit *biz.LoginAudit) error {
+	entity, err := r.data.db.SysLoginAudit.Create().
+		SetEvent(audit.Event).
+		SetScope(audit.Scope).
+		SetUsername(audit.Username).
+		SetIP(audit.IP).
+		SetFailCount(audit.FailCount).
+		SetLockSeconds(audit.LockSeconds).
+		SetOperator(audit.Operator).
+		Save(ctx)
+	if err != nil {
+		return err
+	}
+	audit.ID = entity.ID
+	audit.CreateDate = entity.CreateDate
+	return nil
+}
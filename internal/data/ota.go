@@ -37,6 +37,9 @@ func (r *otaRepo) PageOta(ctx context.Context, params *biz.ListOtaParams, page *
 		name := *params.FirmwareName
 		query = query.Where(ota.FirmwareNameContains(name))
 	}
+	if params.Type != nil && *params.Type != "" {
+		query = query.Where(ota.TypeEQ(*params.Type))
+	}
 
 	// 获取总数
 	total, err := query.Count(ctx)
@@ -120,17 +123,20 @@ func (r *otaRepo) GetByID(ctx context.Context, id string) (*biz.Ota, error) {
 	return &bizEntity, nil
 }
 
-// Save 保存OTA固件（同类固件只保留最新一条）
+// Save 保存OTA固件（相同类型和版本的固件覆盖，其他版本保留用于灰度和回滚）
 func (r *otaRepo) Save(ctx context.Context, entity *biz.Ota) error {
-	// 查询同类固件（相同type）
+	// 查询相同类型和版本的固件
 	existingList, err := r.data.db.Ota.Query().
-		Where(ota.TypeEQ(entity.Type)).
+		Where(
+			ota.TypeEQ(entity.Type),
+			ota.VersionEQ(entity.Version),
+		).
 		All(ctx)
 	if err != nil {
 		return err
 	}
 
-	// 如果存在同类固件，更新第一条记录
+	// 如果存在相同版本的固件，更新第一条记录
 	if len(existingList) > 0 {
 		existing := existingList[0]
 		update := r.data.db.Ota.UpdateOneID(existing.ID).
@@ -148,6 +154,11 @@ func (r *otaRepo) Save(ctx context.Context, entity *biz.Ota) error {
 		if entity.FirmwarePath != "" {
 			update = update.SetFirmwarePath(entity.FirmwarePath)
 		}
+		if entity.Sha256 != "" {
+			update = update.SetSha256(entity.Sha256).
+				SetSignature(entity.Signature).
+				SetSignKeyID(entity.SignKeyID)
+		}
 		if entity.Sort > 0 {
 			update = update.SetSort(entity.Sort)
 		}
@@ -181,6 +192,11 @@ func (r *otaRepo) Save(ctx context.Context, entity *biz.Ota) error {
 	if entity.FirmwarePath != "" {
 		create = create.SetFirmwarePath(entity.FirmwarePath)
 	}
+	if entity.Sha256 != "" {
+		create = create.SetSha256(entity.Sha256).
+			SetSignature(entity.Signature).
+			SetSignKeyID(entity.SignKeyID)
+	}
 	if entity.Sort > 0 {
 		create = create.SetSort(entity.Sort)
 	}
@@ -241,6 +257,11 @@ func (r *otaRepo) Update(ctx context.Context, entity *biz.Ota) error {
 	if entity.FirmwarePath != "" {
 		update = update.SetFirmwarePath(entity.FirmwarePath)
 	}
+	if entity.Sha256 != "" {
+		update = update.SetSha256(entity.Sha256).
+			SetSignature(entity.Signature).
+			SetSignKeyID(entity.SignKeyID)
+	}
 	if entity.Sort > 0 {
 		update = update.SetSort(entity.Sort)
 	}
@@ -264,14 +285,28 @@ func (r *otaRepo) Delete(ctx context.Context, ids []string) error {
 	return err
 }
 
-// GetLatestOta 根据类型获取最新OTA固件
-func (r *otaRepo) GetLatestOta(ctx context.Context, otaType string) (*biz.Ota, error) {
-	if otaType == "" {
+// UpdateSignature 更新固件的SHA-256、大小和签名（保留原更新时间，避免影响最新固件的判断）
+func (r *otaRepo) UpdateSignature(ctx context.Context, entity *biz.Ota) error {
+	return r.data.db.Ota.UpdateOneID(entity.ID).
+		SetSha256(entity.Sha256).
+		SetSize(entity.Size).
+		SetSignature(entity.Signature).
+		SetSignKeyID(entity.SignKeyID).
+		SetUpdateDate(entity.UpdateDate).
+		Exec(ctx)
+}
+
+// GetByTypeAndVersion 根据类型和版本查询OTA固件
+func (r *otaRepo) GetByTypeAndVersion(ctx context.Context, otaType, version string) (*biz.Ota, error) {
+	if otaType == "" || version == "" {
 		return nil, nil
 	}
 
 	entity, err := r.data.db.Ota.Query().
-		Where(ota.TypeEQ(otaType)).
+		Where(
+			ota.TypeEQ(otaType),
+			ota.VersionEQ(version),
+		).
 		Order(ent.Desc(ota.FieldUpdateDate)).
 		First(ctx)
 	if err != nil {
@@ -288,3 +323,40 @@ func (r *otaRepo) GetLatestOta(ctx context.Context, otaType string) (*biz.Ota, e
 
 	return &bizEntity, nil
 }
+
+// GetLatestOta 根据类型获取版本号最高的OTA固件，excludeIds中的固件不参与选择
+// 版本号为点分数字无法在SQL中排序，取出该类型的固件后比较，相同版本取最近更新的
+func (r *otaRepo) GetLatestOta(ctx context.Context, otaType string, excludeIds ...string) (*biz.Ota, error) {
+	if otaType == "" {
+		return nil, nil
+	}
+
+	query := r.data.db.Ota.Query().
+		Where(ota.TypeEQ(otaType))
+	if len(excludeIds) > 0 {
+		query = query.Where(ota.IDNotIn(excludeIds...))
+	}
+	entities, err := query.
+		Order(ent.Desc(ota.FieldUpdateDate)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *ent.Ota
+	for _, entity := range entities {
+		if latest == nil || biz.CompareVersions(entity.Version, latest.Version) > 0 {
+			latest = entity
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+
+	var bizEntity biz.Ota
+	if err := copier.Copy(&bizEntity, latest); err != nil {
+		return nil, err
+	}
+
+	return &bizEntity, nil
+}
@@ -0,0 +1,120 @@
+package data
+
+import (
+	"context"
+	"strings"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/data/ent"
+	"github.com/weetime/agent-matrix/internal/data/ent/otadelta"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/google/uuid"
+)
+
+type otaDeltaRepo struct {
+	data *Data
+	log  *log.Helper
+}
+
+// NewOtaDeltaRepo 初始化固件差分包Repo
+func NewOtaDeltaRepo(data *Data, logger log.Logger) biz.OtaDeltaRepo {
+	return &otaDeltaRepo{
+		data: data,
+		log:  log.NewHelper(log.With(logger, "module", "agent-matrix-service/data/ota_delta")),
+	}
+}
+
+// Create 创建差分包记录
+func (r *otaDeltaRepo) Create(ctx context.Context, delta *biz.OtaDelta) error {
+	delta.ID = strings.ReplaceAll(uuid.New().String(), "-", "")
+	entity, err := r.data.db.OtaDelta.Create().
+		SetID(delta.ID).
+		SetType(delta.Type).
+		SetFromOtaID(delta.FromOtaID).
+		SetToOtaID(delta.ToOtaID).
+		SetFromVersion(delta.FromVersion).
+		SetToVersion(delta.ToVersion).
+		SetFromSha256(delta.FromSha256).
+		SetToSha256(delta.ToSha256).
+		SetPatchPath(delta.PatchPath).
+		SetSize(delta.Size).
+		SetSha256(delta.Sha256).
+		SetStatus(delta.Status).
+		Save(ctx)
+	if err != nil {
+		return err
+	}
+	delta.CreatedAt = entity.CreatedAt
+	return nil
+}
+
+// GetByID 根据ID查询差分包
+func (r *otaDeltaRepo) GetByID(ctx context.Context, id string) (*biz.OtaDelta, error) {
+	entity, err := r.data.db.OtaDelta.Get(ctx, id)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return toBizOtaDelta(entity), nil
+}
+
+// GetByOtas 查询源固件到目标固件的差分包
+func (r *otaDeltaRepo) GetByOtas(ctx context.Context, fromOtaId, toOtaId string) (*biz.OtaDelta, error) {
+	entity, err := r.data.db.OtaDelta.Query().
+		Where(
+			otadelta.FromOtaIDEQ(fromOtaId),
+			otadelta.ToOtaIDEQ(toOtaId),
+		).
+		Only(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return toBizOtaDelta(entity), nil
+}
+
+// Delete 删除差分包记录
+func (r *otaDeltaRepo) Delete(ctx context.Context, id string) error {
+	err := r.data.db.OtaDelta.DeleteOneID(id).Exec(ctx)
+	if ent.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// DeleteByOtas 删除源或目标为指定固件的差分包
+func (r *otaDeltaRepo) DeleteByOtas(ctx context.Context, otaIds []string) error {
+	if len(otaIds) == 0 {
+		return nil
+	}
+	_, err := r.data.db.OtaDelta.Delete().
+		Where(otadelta.Or(
+			otadelta.FromOtaIDIn(otaIds...),
+			otadelta.ToOtaIDIn(otaIds...),
+		)).
+		Exec(ctx)
+	return err
+}
+
+func toBizOtaDelta(entity *ent.OtaDelta) *biz.OtaDelta {
+	return &biz.OtaDelta{
+		ID:          entity.ID,
+		Type:        entity.Type,
+		FromOtaID:   entity.FromOtaID,
+		ToOtaID:     entity.ToOtaID,
+		FromVersion: entity.FromVersion,
+		ToVersion:   entity.ToVersion,
+		FromSha256:  entity.FromSha256,
+		ToSha256:    entity.ToSha256,
+		PatchPath:   entity.PatchPath,
+		Size:        entity.Size,
+		Sha256:      entity.Sha256,
+		Status:      entity.Status,
+		CreatedAt:   entity.CreatedAt,
+	}
+}
@@ -0,0 +1,144 @@
+package data
+
+import (
+	"context"
+	"strings"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/data/ent"
+	"github.com/weetime/agent-matrix/internal/data/ent/otarollout"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/google/uuid"
+)
+
+type otaRolloutRepo struct {
+	data *Data
+	log  *log.Helper
+}
+
+// NewOtaRolloutRepo 初始化OTA灰度发布计划Repo
+func NewOtaRolloutRepo(data *Data, logger log.Logger) biz.OtaRolloutRepo {
+	return &otaRolloutRepo{
+		data: data,
+		log:  log.NewHelper(log.With(logger, "module", "agent-matrix-service/data/ota_rollout")),
+	}
+}
+
+// Create 创建灰度发布计划
+func (r *otaRolloutRepo) Create(ctx context.Context, rollout *biz.OtaRollout) error {
+	rollout.ID = strings.ReplaceAll(uuid.New().String(), "-", "")
+	entity, err := r.data.db.OtaRollout.Create().
+		SetID(rollout.ID).
+		SetOtaID(rollout.OtaID).
+		SetType(rollout.Type).
+		SetPercentage(rollout.Percentage).
+		SetStartPercentage(rollout.StartPercentage).
+		SetRampMinutes(rollout.RampMinutes).
+		SetMACAddresses(rollout.MacAddresses).
+		SetUserID(rollout.UserID).
+		SetAgentID(rollout.AgentID).
+		SetStatus(rollout.Status).
+		SetRollbackOtaID(rollout.RollbackOtaID).
+		SetStartedAt(rollout.StartedAt).
+		SetNillablePausedAt(rollout.PausedAt).
+		SetCreator(rollout.Creator).
+		Save(ctx)
+	if err != nil {
+		return err
+	}
+	rollout.CreatedAt = entity.CreatedAt
+	rollout.UpdatedAt = entity.UpdatedAt
+	return nil
+}
+
+// Update 更新灰度发布计划的范围、比例和状态
+func (r *otaRolloutRepo) Update(ctx context.Context, rollout *biz.OtaRollout) error {
+	update := r.data.db.OtaRollout.UpdateOneID(rollout.ID).
+		SetPercentage(rollout.Percentage).
+		SetStartPercentage(rollout.StartPercentage).
+		SetRampMinutes(rollout.RampMinutes).
+		SetMACAddresses(rollout.MacAddresses).
+		SetUserID(rollout.UserID).
+		SetAgentID(rollout.AgentID).
+		SetStatus(rollout.Status).
+		SetRollbackOtaID(rollout.RollbackOtaID).
+		SetStartedAt(rollout.StartedAt)
+	if rollout.PausedAt != nil {
+		update = update.SetPausedAt(*rollout.PausedAt)
+	} else {
+		update = update.ClearPausedAt()
+	}
+
+	entity, err := update.Save(ctx)
+	if err != nil {
+		return err
+	}
+	rollout.UpdatedAt = entity.UpdatedAt
+	return nil
+}
+
+// GetByID 根据ID查询灰度发布计划
+func (r *otaRolloutRepo) GetByID(ctx context.Context, id string) (*biz.OtaRollout, error) {
+	entity, err := r.data.db.OtaRollout.Get(ctx, id)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return toBizOtaRollout(entity), nil
+}
+
+// List 查询灰度发布计划，otaType为空时查询全部，按创建时间倒序
+func (r *otaRolloutRepo) List(ctx context.Context, otaType string) ([]*biz.OtaRollout, error) {
+	query := r.data.db.OtaRollout.Query()
+	if otaType != "" {
+		query = query.Where(otarollout.TypeEQ(otaType))
+	}
+	return r.list(ctx, query)
+}
+
+// ListUnfinished 查询未全量的灰度发布计划（灰度中、已暂停、已回滚），按创建时间倒序
+func (r *otaRolloutRepo) ListUnfinished(ctx context.Context, otaType string) ([]*biz.OtaRollout, error) {
+	query := r.data.db.OtaRollout.Query().
+		Where(otarollout.StatusNEQ(biz.OtaRolloutStatusCompleted))
+	if otaType != "" {
+		query = query.Where(otarollout.TypeEQ(otaType))
+	}
+	return r.list(ctx, query)
+}
+
+func (r *otaRolloutRepo) list(ctx context.Context, query *ent.OtaRolloutQuery) ([]*biz.OtaRollout, error) {
+	entities, err := query.Order(ent.Desc(otarollout.FieldCreatedAt)).All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*biz.OtaRollout, 0, len(entities))
+	for _, entity := range entities {
+		result = append(result, toBizOtaRollout(entity))
+	}
+	return result, nil
+}
+
+func toBizOtaRollout(entity *ent.OtaRollout) *biz.OtaRollout {
+	return &biz.OtaRollout{
+		ID:              entity.ID,
+		OtaID:           entity.OtaID,
+		Type:            entity.Type,
+		Percentage:      entity.Percentage,
+		StartPercentage: entity.StartPercentage,
+		RampMinutes:     entity.RampMinutes,
+		MacAddresses:    entity.MACAddresses,
+		UserID:          entity.UserID,
+		AgentID:         entity.AgentID,
+		Status:          entity.Status,
+		RollbackOtaID:   entity.RollbackOtaID,
+		StartedAt:       entity.StartedAt,
+		PausedAt:        entity.PausedAt,
+		Creator:         entity.Creator,
+		CreatedAt:       entity.CreatedAt,
+		UpdatedAt:       entity.UpdatedAt,
+	}
+}
@@ -0,0 +1,416 @@
+package data
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/data/ent"
+	"github.com/weetime/agent-matrix/internal/data/ent/ragchunk"
+	"github.com/weetime/agent-matrix/internal/data/ent/ragdocument"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+// 批量写入切片时每批的数量，避免超出数据库的参数个数限制
+const ragChunkBatchSize = 100
+
+// ragDocumentFields 查询文档时不加载文件内容
+var ragDocumentFields = []string{
+	ragdocument.FieldID,
+	ragdocument.FieldDatasetID,
+	ragdocument.FieldName,
+	ragdocument.FieldFileType,
+	ragdocument.FieldFileSize,
+	ragdocument.FieldChunkMethod,
+	ragdocument.FieldParserConfig,
+	ragdocument.FieldMetaFields,
+	ragdocument.FieldStatus,
+	ragdocument.FieldRun,
+	ragdocument.FieldProgress,
+	ragdocument.FieldProgressMsg,
+	ragdocument.FieldChunkCount,
+	ragdocument.FieldTokenCount,
+	ragdocument.FieldCreator,
+	ragdocument.FieldCreatedAt,
+	ragdocument.FieldUpdater,
+	ragdocument.FieldUpdatedAt,
+}
+
+type localRAGRepo struct {
+	data *Data
+	log  *log.Helper
+}
+
+// NewLocalRAGRepo 初始化本地知识库Repo
+func NewLocalRAGRepo(data *Data, logger log.Logger) biz.LocalRAGRepo {
+	return &localRAGRepo{
+		data: data,
+		log:  log.NewHelper(log.With(logger, "module", "agent-matrix-service/data/rag_local")),
+	}
+}
+
+// CreateDocument 保存文档及原始文件内容
+func (r *localRAGRepo) CreateDocument(ctx context.Context, doc *biz.Document, content []byte) error {
+	create := r.data.db.RagDocument.Create().
+		SetID(doc.ID).
+		SetDatasetID(doc.DatasetID).
+		SetName(doc.Name).
+		SetFileType(doc.FileType).
+		SetFileSize(doc.FileSize).
+		SetContent(content).
+		SetChunkMethod(doc.ChunkMethod).
+		SetStatus(doc.Status).
+		SetRun(doc.Run).
+		SetCreatedAt(doc.CreatedAt).
+		SetUpdatedAt(doc.UpdatedAt)
+
+	if parserConfig := marshalJSONMap(doc.ParserConfig); parserConfig != "" {
+		create.SetParserConfig(parserConfig)
+	}
+	if metaFields := marshalJSONMap(doc.MetaFields); metaFields != "" {
+		create.SetMetaFields(metaFields)
+	}
+	if doc.Creator > 0 {
+		create.SetCreator(doc.Creator)
+	}
+	if doc.Updater > 0 {
+		create.SetUpdater(doc.Updater)
+	}
+
+	_, err := create.Save(ctx)
+	return err
+}
+
+// GetDocument 获取文档（不含文件内容），不存在时返回nil
+func (r *localRAGRepo) GetDocument(ctx context.Context, datasetId, documentId string) (*biz.Document, error) {
+	entity, err := r.data.db.RagDocument.Query().
+		Where(ragdocument.IDEQ(documentId), ragdocument.DatasetIDEQ(datasetId)).
+		Select(ragDocumentFields...).
+		Only(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return r.entityToDocument(entity), nil
+}
+
+// GetDocumentContent 获取文档原始文件内容
+func (r *localRAGRepo) GetDocumentContent(ctx context.Context, documentId string) ([]byte, error) {
+	entity, err := r.data.db.RagDocument.Query().
+		Where(ragdocument.IDEQ(documentId)).
+		Select(ragdocument.FieldID, ragdocument.FieldContent).
+		Only(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return entity.Content, nil
+}
+
+// PageDocuments 分页查询文档，按创建时间倒序
+func (r *localRAGRepo) PageDocuments(ctx context.Context, datasetId, keywords string, status *int32, page, limit int) ([]*biz.Document, int, error) {
+	query := r.data.db.RagDocument.Query().
+		Where(ragdocument.DatasetIDEQ(datasetId))
+	if keywords != "" {
+		query = query.Where(ragdocument.NameContains(keywords))
+	}
+	if status != nil {
+		query = query.Where(ragdocument.StatusEQ(*status))
+	}
+
+	total, err := query.Clone().Count(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if page <= 0 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+	entities, err := query.
+		Order(ent.Desc(ragdocument.FieldCreatedAt)).
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Select(ragDocumentFields...).
+		All(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	documents := make([]*biz.Document, len(entities))
+	for i, entity := range entities {
+		documents[i] = r.entityToDocument(entity)
+	}
+	return documents, total, nil
+}
+
+// UpdateDocument 更新文档的名称、解析配置与解析状态
+func (r *localRAGRepo) UpdateDocument(ctx context.Context, doc *biz.Document) error {
+	update := r.data.db.RagDocument.UpdateOneID(doc.ID).
+		SetName(doc.Name).
+		SetChunkMethod(doc.ChunkMethod).
+		SetStatus(doc.Status).
+		SetRun(doc.Run).
+		SetProgress(doc.Progress).
+		SetProgressMsg(doc.ProgressMsg).
+		SetChunkCount(doc.ChunkCount).
+		SetTokenCount(doc.TokenCount)
+
+	if parserConfig := marshalJSONMap(doc.ParserConfig); parserConfig != "" {
+		update.SetParserConfig(parserConfig)
+	} else {
+		update.ClearParserConfig()
+	}
+	if metaFields := marshalJSONMap(doc.MetaFields); metaFields != "" {
+		update.SetMetaFields(metaFields)
+	} else {
+		update.ClearMetaFields()
+	}
+	if doc.Updater > 0 {
+		update.SetUpdater(doc.Updater)
+	}
+
+	return update.Exec(ctx)
+}
+
+// DeleteDocument 删除文档及其切片
+func (r *localRAGRepo) DeleteDocument(ctx context.Context, datasetId, documentId string) error {
+	tx, err := r.data.db.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	// 切片同时按知识库过滤，避免通过其他知识库的文档ID误删
+	if _, err := tx.RagChunk.Delete().Where(ragchunk.DocumentIDEQ(documentId), ragchunk.DatasetIDEQ(datasetId)).Exec(ctx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.RagDocument.Delete().Where(ragdocument.IDEQ(documentId), ragdocument.DatasetIDEQ(datasetId)).Exec(ctx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteDataset 删除知识库下的所有文档和切片
+func (r *localRAGRepo) DeleteDataset(ctx context.Context, datasetId string) error {
+	tx, err := r.data.db.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.RagChunk.Delete().Where(ragchunk.DatasetIDEQ(datasetId)).Exec(ctx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.RagDocument.Delete().Where(ragdocument.DatasetIDEQ(datasetId)).Exec(ctx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReplaceChunks 替换文档的全部切片
+func (r *localRAGRepo) ReplaceChunks(ctx context.Context, documentId string, chunks []*biz.RAGChunk) error {
+	tx, err := r.data.db.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.RagChunk.Delete().Where(ragchunk.DocumentIDEQ(documentId)).Exec(ctx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	now := time.Now()
+	for start := 0; start < len(chunks); start += ragChunkBatchSize {
+		end := min(start+ragChunkBatchSize, len(chunks))
+		builders := make([]*ent.RagChunkCreate, 0, end-start)
+		for _, chunk := range chunks[start:end] {
+			builders = append(builders, tx.RagChunk.Create().
+				SetID(chunk.ID).
+				SetDatasetID(chunk.DatasetID).
+				SetDocumentID(documentId).
+				SetPosition(chunk.Position).
+				SetContent(chunk.Content).
+				SetEmbedding(encodeEmbedding(chunk.Embedding)).
+				SetTokenCount(chunk.TokenCount).
+				SetCreatedAt(now))
+		}
+		if _, err := tx.RagChunk.CreateBulk(builders...).Save(ctx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// PageChunks 分页查询文档切片（不含向量），按切片序号排序
+func (r *localRAGRepo) PageChunks(ctx context.Context, documentId, keywords, chunkId string, page, pageSize int) ([]*biz.RAGChunk, int, error) {
+	query := r.data.db.RagChunk.Query().
+		Where(ragchunk.DocumentIDEQ(documentId))
+	if keywords != "" {
+		query = query.Where(ragchunk.ContentContains(keywords))
+	}
+	if chunkId != "" {
+		query = query.Where(ragchunk.IDEQ(chunkId))
+	}
+
+	total, err := query.Clone().Count(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entities, err := query.
+		Order(ent.Asc(ragchunk.FieldPosition)).
+		Offset((page-1)*pageSize).
+		Limit(pageSize).
+		Select(
+			ragchunk.FieldID,
+			ragchunk.FieldDatasetID,
+			ragchunk.FieldDocumentID,
+			ragchunk.FieldPosition,
+			ragchunk.FieldContent,
+			ragchunk.FieldTokenCount,
+			ragchunk.FieldCreatedAt,
+		).
+		All(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	chunks := make([]*biz.RAGChunk, len(entities))
+	for i, entity := range entities {
+		chunks[i] = r.entityToChunk(entity)
+	}
+	return chunks, total, nil
+}
+
+// ListChunks 查询知识库中参与召回的切片（含向量）
+func (r *localRAGRepo) ListChunks(ctx context.Context, datasetIds, documentIds []string) ([]*biz.RAGChunk, error) {
+	if len(datasetIds) == 0 {
+		return nil, nil
+	}
+	query := r.data.db.RagChunk.Query().
+		Where(ragchunk.DatasetIDIn(datasetIds...))
+	if len(documentIds) > 0 {
+		query = query.Where(ragchunk.DocumentIDIn(documentIds...))
+	}
+
+	entities, err := query.All(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make([]*biz.RAGChunk, len(entities))
+	for i, entity := range entities {
+		chunks[i] = r.entityToChunk(entity)
+	}
+	return chunks, nil
+}
+
+// GetDocumentNames 批量获取文档名称
+func (r *localRAGRepo) GetDocumentNames(ctx context.Context, documentIds []string) (map[string]string, error) {
+	names := make(map[string]string, len(documentIds))
+	if len(documentIds) == 0 {
+		return names, nil
+	}
+
+	entities, err := r.data.db.RagDocument.Query().
+		Where(ragdocument.IDIn(documentIds...)).
+		Select(ragdocument.FieldID, ragdocument.FieldName).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, entity := range entities {
+		names[entity.ID] = entity.Name
+	}
+	return names, nil
+}
+
+// entityToDocument 转换文档实体
+func (r *localRAGRepo) entityToDocument(entity *ent.RagDocument) *biz.Document {
+	return &biz.Document{
+		ID:           entity.ID,
+		DocumentID:   entity.ID,
+		DatasetID:    entity.DatasetID,
+		Name:         entity.Name,
+		FileType:     entity.FileType,
+		FileSize:     entity.FileSize,
+		MetaFields:   unmarshalJSONMap(entity.MetaFields),
+		ChunkMethod:  entity.ChunkMethod,
+		ParserConfig: unmarshalJSONMap(entity.ParserConfig),
+		Status:       entity.Status,
+		Run:          entity.Run,
+		Progress:     entity.Progress,
+		ProgressMsg:  entity.ProgressMsg,
+		ChunkCount:   entity.ChunkCount,
+		TokenCount:   entity.TokenCount,
+		Creator:      entity.Creator,
+		CreatedAt:    entity.CreatedAt,
+		Updater:      entity.Updater,
+		UpdatedAt:    entity.UpdatedAt,
+	}
+}
+
+// entityToChunk 转换切片实体
+func (r *localRAGRepo) entityToChunk(entity *ent.RagChunk) *biz.RAGChunk {
+	return &biz.RAGChunk{
+		ID:         entity.ID,
+		DatasetID:  entity.DatasetID,
+		DocumentID: entity.DocumentID,
+		Position:   entity.Position,
+		Content:    entity.Content,
+		Embedding:  decodeEmbedding(entity.Embedding),
+		TokenCount: entity.TokenCount,
+		CreatedAt:  entity.CreatedAt,
+	}
+}
+
+// encodeEmbedding 将向量编码为float32小端序字节
+func encodeEmbedding(vector []float32) []byte {
+	buf := make([]byte, len(vector)*4)
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
+	}
+	return buf
+}
+
+// decodeEmbedding 解码float32小端序字节
+func decodeEmbedding(buf []byte) []float32 {
+	vector := make([]float32, len(buf)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:]))
+	}
+	return vector
+}
+
+// marshalJSONMap 序列化JSON对象，空对象返回空字符串
+func marshalJSONMap(value map[string]interface{}) string {
+	if len(value) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// unmarshalJSONMap 反序列化JSON对象，无效内容返回nil
+func unmarshalJSONMap(value string) map[string]interface{} {
+	if value == "" {
+		return nil
+	}
+	result := make(map[string]interface{})
+	if err := json.Unmarshal([]byte(value), &result); err != nil {
+		return nil
+	}
+	return result
+}
@@ -0,0 +1,200 @@
+package data
+
+import (
+	"context"
+	"strings"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/data/ent"
+	"github.com/weetime/agent-matrix/internal/data/ent/sysrole"
+	"github.com/weetime/agent-matrix/internal/data/ent/sysuserrole"
+	"github.com/weetime/agent-matrix/internal/kit"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+type roleRepo struct {
+	data *Data
+	log  *log.Helper
+}
+
+// NewRoleRepo 初始化角色Repo
+func NewRoleRepo(data *Data, logger log.Logger) biz.RoleRepo {
+	return &roleRepo{
+		data: data,
+		log:  kit.LogHelper(logger),
+	}
+}
+
+// List 查询全部角色，内置角色在前
+func (r *roleRepo) List(ctx context.Context) ([]*biz.Role, error) {
+	entities, err := r.data.db.SysRole.Query().
+		Order(ent.Desc(sysrole.FieldBuiltin), ent.Asc(sysrole.FieldID)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]*biz.Role, 0, len(entities))
+	for _, entity := range entities {
+		roles = append(roles, toBizRole(entity))
+	}
+	return roles, nil
+}
+
+// GetByID 根据ID查询角色，不存在时返回nil
+func (r *roleRepo) GetByID(ctx context.Context, id int64) (*biz.Role, error) {
+	entity, err := r.data.db.SysRole.Get(ctx, id)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return toBizRole(entity), nil
+}
+
+// GetByCode 根据编码查询角色，不存在时返回nil
+func (r *roleRepo) GetByCode(ctx context.Context, code string) (*biz.Role, error) {
+	entity, err := r.data.db.SysRole.Query().
+		Where(sysrole.CodeEQ(code)).
+		Only(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return toBizRole(entity), nil
+}
+
+// Create 创建角色
+func (r *roleRepo) Create(ctx context.Context, role *biz.Role) (*biz.Role, error) {
+	entity, err := r.data.db.SysRole.Create().
+		SetID(kit.GenerateInt64ID()).
+		SetCode(role.Code).
+		SetName(role.Name).
+		SetPermissions(strings.Join(role.Permissions, ",")).
+		SetRemark(role.Remark).
+		SetBuiltin(role.Builtin).
+		SetCreator(role.Creator).
+		SetCreateDate(role.CreateDate).
+		SetUpdater(role.Updater).
+		SetUpdateDate(role.UpdateDate).
+		Save(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return toBizRole(entity), nil
+}
+
+// Update 更新角色名称、权限和备注
+func (r *roleRepo) Update(ctx context.Context, role *biz.Role) error {
+	return r.data.db.SysRole.UpdateOneID(role.ID).
+		SetName(role.Name).
+		SetPermissions(strings.Join(role.Permissions, ",")).
+		SetRemark(role.Remark).
+		SetUpdater(role.Updater).
+		SetUpdateDate(role.UpdateDate).
+		Exec(ctx)
+}
+
+// Delete 删除角色及其用户关联
+func (r *roleRepo) Delete(ctx context.Context, id int64) error {
+	tx, err := r.data.db.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.SysUserRole.Delete().Where(sysuserrole.RoleIDEQ(id)).Exec(ctx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.SysRole.DeleteOneID(id).Exec(ctx); err != nil && !ent.IsNotFound(err) {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListByUserId 查询用户的全部角色
+func (r *roleRepo) ListByUserId(ctx context.Context, userId int64) ([]*biz.Role, error) {
+	userRoles, err := r.data.db.SysUserRole.Query().
+		Where(sysuserrole.UserIDEQ(userId)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(userRoles) == 0 {
+		return nil, nil
+	}
+	roleIds := make([]int64, 0, len(userRoles))
+	for _, userRole := range userRoles {
+		roleIds = append(roleIds, userRole.RoleID)
+	}
+	entities, err := r.data.db.SysRole.Query().
+		Where(sysrole.IDIn(roleIds...)).
+		Order(ent.Asc(sysrole.FieldID)).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]*biz.Role, 0, len(entities))
+	for _, entity := range entities {
+		roles = append(roles, toBizRole(entity))
+	}
+	return roles, nil
+}
+
+// SetUserRoles 用给定的角色列表替换用户的全部角色
+func (r *roleRepo) SetUserRoles(ctx context.Context, userId int64, roleIds []int64) error {
+	tx, err := r.data.db.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.SysUserRole.Delete().Where(sysuserrole.UserIDEQ(userId)).Exec(ctx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if len(roleIds) > 0 {
+		builders := make([]*ent.SysUserRoleCreate, 0, len(roleIds))
+		for _, roleId := range roleIds {
+			builders = append(builders, tx.SysUserRole.Create().
+				SetID(kit.GenerateInt64ID()).
+				SetUserID(userId).
+				SetRoleID(roleId))
+		}
+		if _, err := tx.SysUserRole.CreateBulk(builders...).Save(ctx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteUserRoles 删除用户的全部角色关联
+func (r *roleRepo) DeleteUserRoles(ctx context.Context, userId int64) error {
+	_, err := r.data.db.SysUserRole.Delete().
+		Where(sysuserrole.UserIDEQ(userId)).
+		Exec(ctx)
+	return err
+}
+
+func toBizRole(entity *ent.SysRole) *biz.Role {
+	var permissions []string
+	if entity.Permissions != "" {
+		permissions = strings.Split(entity.Permissions, ",")
+	}
+	return &biz.Role{
+		ID:          entity.ID,
+		Code:        entity.Code,
+		Name:        entity.Name,
+		Permissions: permissions,
+		Remark:      entity.Remark,
+		Builtin:     entity.Builtin,
+		Creator:     entity.Creator,
+		CreateDate:  entity.CreateDate,
+		Updater:     entity.Updater,
+		UpdateDate:  entity.UpdateDate,
+	}
+}
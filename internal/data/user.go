@@ -239,25 +239,6 @@ func (r *userRepo) UpdateUserStatus(ctx context.Context, userId int64, status in
 		Exec(ctx)
 }
 
-// GetByUserId 根据用户ID获取Token
-func (r *userTokenRepo) GetByUserId(ctx context.Context, userId int64) (*biz.UserToken, error) {
-	token, err := r.data.db.SysUserToken.Query().
-		Where(sysusertoken.UserID(userId)).
-		Only(ctx)
-	if err != nil {
-		if ent.IsNotFound(err) {
-			return nil, nil
-		}
-		return nil, err
-	}
-
-	var bizToken biz.UserToken
-	if err := copier.Copy(&bizToken, token); err != nil {
-		return nil, err
-	}
-	return &bizToken, nil
-}
-
 // GetByToken 根据Token获取Token记录
 func (r *userTokenRepo) GetByToken(ctx context.Context, token string) (*biz.UserToken, error) {
 	tokenEntity, err := r.data.db.SysUserToken.Query().
@@ -282,6 +263,10 @@ func (r *userTokenRepo) Save(ctx context.Context, token *biz.UserToken) error {
 	create := r.data.db.SysUserToken.Create().
 		SetUserID(token.UserID).
 		SetToken(token.Token).
+		SetClientHash(token.ClientHash).
+		SetUserAgent(token.UserAgent).
+		SetIP(token.IP).
+		SetNillableLastActiveAt(token.LastActiveAt).
 		SetExpireDate(token.ExpireDate)
 
 	if !token.CreateDate.IsZero() {
@@ -291,31 +276,81 @@ func (r *userTokenRepo) Save(ctx context.Context, token *biz.UserToken) error {
 		create.SetUpdateDate(token.UpdateDate)
 	}
 
-	return create.Exec(ctx)
+	entity, err := create.Save(ctx)
+	if err != nil {
+		return err
+	}
+	token.ID = entity.ID
+	return nil
 }
 
-// Update 更新Token
-func (r *userTokenRepo) Update(ctx context.Context, token *biz.UserToken) error {
-	update := r.data.db.SysUserToken.Update().
-		Where(sysusertoken.ID(token.ID))
-
-	if token.Token != "" {
-		update.SetToken(token.Token)
-	}
-	if !token.ExpireDate.IsZero() {
-		update.SetExpireDate(token.ExpireDate)
+// ListActiveByUserId 查询用户未过期的会话，按最近访问时间倒序
+func (r *userTokenRepo) ListActiveByUserId(ctx context.Context, userId int64, now time.Time) ([]*biz.UserToken, error) {
+	entities, err := r.data.db.SysUserToken.Query().
+		Where(
+			sysusertoken.UserID(userId),
+			sysusertoken.ExpireDateGT(now),
+		).
+		Order(
+			ent.Desc(sysusertoken.FieldLastActiveAt),
+			ent.Desc(sysusertoken.FieldID),
+		).
+		All(ctx)
+	if err != nil {
+		return nil, err
 	}
-	if !token.UpdateDate.IsZero() {
-		update.SetUpdateDate(token.UpdateDate)
+
+	tokens := make([]*biz.UserToken, 0, len(entities))
+	for _, entity := range entities {
+		var bizToken biz.UserToken
+		if err := copier.Copy(&bizToken, entity); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, &bizToken)
 	}
+	return tokens, nil
+}
 
+// Touch 更新会话的最近访问时间和IP
+func (r *userTokenRepo) Touch(ctx context.Context, id int64, ip string, lastActiveAt time.Time) error {
+	update := r.data.db.SysUserToken.UpdateOneID(id).
+		SetLastActiveAt(lastActiveAt)
+	if ip != "" {
+		update.SetIP(ip)
+	}
 	return update.Exec(ctx)
 }
 
-// Logout 登出（使Token失效）
+// Logout 登出（使用户的全部Token失效）
 func (r *userTokenRepo) Logout(ctx context.Context, userId int64, expireDate time.Time) error {
 	return r.data.db.SysUserToken.Update().
 		Where(sysusertoken.UserID(userId)).
 		SetExpireDate(expireDate).
 		Exec(ctx)
 }
+
+// ExpireByIds 使用户的指定会话失效
+func (r *userTokenRepo) ExpireByIds(ctx context.Context, userId int64, ids []int64, expireDate time.Time) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	return r.data.db.SysUserToken.Update().
+		Where(
+			sysusertoken.UserID(userId),
+			sysusertoken.IDIn(ids...),
+			sysusertoken.ExpireDateGT(expireDate),
+		).
+		SetExpireDate(expireDate).
+		Save(ctx)
+}
+
+// DeleteExpired 删除用户在指定时间前已过期的会话
+func (r *userTokenRepo) DeleteExpired(ctx context.Context, userId int64, before time.Time) error {
+	_, err := r.data.db.SysUserToken.Delete().
+		Where(
+			sysusertoken.UserID(userId),
+			sysusertoken.ExpireDateLT(before),
+		).
+		Exec(ctx)
+	return err
+}
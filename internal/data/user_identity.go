@@ -0,0 +1,83 @@
+package data
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/data/ent"
+	"github.com/weetime/agent-matrix/internal/data/ent/sysuseridentity"
+	"github.com/weetime/agent-matrix/internal/kit"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/google/uuid"
+)
+
+type userIdentityRepo struct {
+	data *Data
+	log  *log.Helper
+}
+
+// NewUserIdentityRepo 初始化外部身份Repo
+func NewUserIdentityRepo(data *Data, logger log.Logger) biz.UserIdentityRepo {
+	return &userIdentityRepo{
+		data: data,
+		log:  kit.LogHelper(logger),
+	}
+}
+
+// GetBySubject 按身份提供方和用户标识查询外部身份，不存在时返回nil
+func (r *userIdentityRepo) GetBySubject(ctx context.Context, issuer, subject string) (*biz.UserIdentity, error) {
+	entity, err := r.data.db.SysUserIdentity.Query().
+		Where(
+			sysuseridentity.Issuer(issuer),
+			sysuseridentity.Subject(subject),
+		).
+		Only(ctx)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &biz.UserIdentity{
+		ID:          entity.ID,
+		UserID:      entity.UserID,
+		Issuer:      entity.Issuer,
+		Subject:     entity.Subject,
+		Email:       entity.Email,
+		LastLoginAt: entity.LastLoginAt,
+		CreateDate:  entity.CreateDate,
+	}, nil
+}
+
+// Create 创建外部身份关联
+func (r *userIdentityRepo) Create(ctx context.Context, identity *biz.UserIdentity) error {
+	if identity.ID == "" {
+		identity.ID = strings.ReplaceAll(uuid.New().String(), "-", "")
+	}
+	return r.data.db.SysUserIdentity.Create().
+		SetID(identity.ID).
+		SetUserID(identity.UserID).
+		SetIssuer(identity.Issuer).
+		SetSubject(identity.Subject).
+		SetEmail(identity.Email).
+		SetNillableLastLoginAt(identity.LastLoginAt).
+		Exec(ctx)
+}
+
+// TouchLogin 更新最近一次单点登录时间
+func (r *userIdentityRepo) TouchLogin(ctx context.Context, id string, at time.Time) error {
+	return r.data.db.SysUserIdentity.UpdateOneID(id).
+		SetLastLoginAt(at).
+		Exec(ctx)
+}
+
+// DeleteByUserId 删除用户关联的全部外部身份
+func (r *userIdentityRepo) DeleteByUserId(ctx context.Context, userId int64) error {
+	_, err := r.data.db.SysUserIdentity.Delete().
+		Where(sysuseridentity.UserID(userId)).
+		Exec(ctx)
+	return err
+}
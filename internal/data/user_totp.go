@@ -0,0 +1,116 @@
+package data
+
+import (
+	"context"
+	"strings"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/data/ent"
+	"github.com/weetime/agent-matrix/internal/data/ent/predicate"
+	"github.com/weetime/agent-matrix/internal/data/ent/sysusertotp"
+	"github.com/weetime/agent-matrix/internal/kit"
+
+	"github.com/go-kratos/kratos/v2/log"
+)
+
+type userTOTPRepo struct {
+	data *Data
+	log  *log.Helper
+}
+
+// NewUserTOTPRepo 初始化双因素认证Repo
+func NewUserTOTPRepo(data *Data, logger log.Logger) biz.UserTOTPRepo {
+	return &userTOTPRepo{
+		data: data,
+		log:  kit.LogHelper(logger),
+	}
+}
+
+// GetByUserId 查询用户的TOTP绑定，不存在时返回nil
+func (r *userTOTPRepo) GetByUserId(ctx context.Context, userId int64) (*biz.UserTOTP, error) {
+	entity, err := r.data.db.SysUserTotp.Get(ctx, userId)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &biz.UserTOTP{
+		UserID:        entity.ID,
+		Secret:        entity.Secret,
+		Enabled:       entity.Enabled,
+		RecoveryCodes: splitRecoveryCodes(entity.RecoveryCodes),
+		LastUsedStep:  entity.LastUsedStep,
+		EnabledAt:     entity.EnabledAt,
+		CreateDate:    entity.CreateDate,
+		UpdateDate:    entity.UpdateDate,
+	}, nil
+}
+
+// Save 保存TOTP绑定，已存在时覆盖
+func (r *userTOTPRepo) Save(ctx context.Context, totp *biz.UserTOTP) error {
+	return r.data.db.SysUserTotp.Create().
+		SetID(totp.UserID).
+		SetSecret(totp.Secret).
+		SetEnabled(totp.Enabled).
+		SetRecoveryCodes(strings.Join(totp.RecoveryCodes, ",")).
+		SetLastUsedStep(totp.LastUsedStep).
+		SetNillableEnabledAt(totp.EnabledAt).
+		OnConflictColumns(sysusertotp.FieldID).
+		UpdateNewValues().
+		Exec(ctx)
+}
+
+// MarkStepUsed 时间步大于上次使用的时间步时记录，防止同一动态码重复使用
+func (r *userTOTPRepo) MarkStepUsed(ctx context.Context, userId, step int64) (bool, error) {
+	affected, err := r.data.db.SysUserTotp.Update().
+		Where(
+			sysusertotp.ID(userId),
+			sysusertotp.LastUsedStepLT(step),
+		).
+		SetLastUsedStep(step).
+		Save(ctx)
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// ReplaceRecoveryCodes 恢复码未被并发修改时替换，用于消耗或重新生成恢复码
+func (r *userTOTPRepo) ReplaceRecoveryCodes(ctx context.Context, userId int64, old, codes []string) (bool, error) {
+	var unchanged predicate.SysUserTotp
+	if len(old) == 0 {
+		unchanged = sysusertotp.Or(sysusertotp.RecoveryCodesIsNil(), sysusertotp.RecoveryCodesEQ(""))
+	} else {
+		unchanged = sysusertotp.RecoveryCodesEQ(strings.Join(old, ","))
+	}
+
+	affected, err := r.data.db.SysUserTotp.Update().
+		Where(
+			sysusertotp.ID(userId),
+			sysusertotp.Enabled(true),
+			unchanged,
+		).
+		SetRecoveryCodes(strings.Join(codes, ",")).
+		Save(ctx)
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// Delete 删除用户的TOTP绑定
+func (r *userTOTPRepo) Delete(ctx context.Context, userId int64) error {
+	_, err := r.data.db.SysUserTotp.Delete().
+		Where(sysusertotp.ID(userId)).
+		Exec(ctx)
+	return err
+}
+
+// splitRecoveryCodes 拆分逗号分隔的恢复码摘要
+func splitRecoveryCodes(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 
 	"github.com/weetime/agent-matrix/internal"
+	"github.com/weetime/agent-matrix/internal/biz"
 	"github.com/weetime/agent-matrix/internal/kit"
 
+	"github.com/go-kratos/kratos/v2/log"
 	"github.com/google/wire"
 )
 
@@ -15,10 +17,41 @@ var ProviderSet = wire.NewSet(
 
 func NewHock(
 	tracer *internal.Tracer,
+	documentUsecase *biz.DocumentUsecase,
+	deviceUsecase *biz.DeviceUsecase,
+	nodeUsecase *biz.NodeUsecase,
+	redisClient *kit.RedisClient,
 ) func(context.Context) error {
 	return func(ctx context.Context) error {
+		// 节点连接/ws时需携带由server.secret签发的token
+		wsHub := kit.GetWebSocket()
+		wsHub.SetNodeAuthenticator(nodeUsecase.AuthenticateNode)
+
+		// 节点通过/ws上报设备连接、断开和心跳，维护设备在线状态
+		wsHub.HandleNodeMessage(kit.DeviceConnectMessageType, deviceUsecase.HandleNodeDeviceMessage)
+		wsHub.HandleNodeMessage(kit.DeviceDisconnectMessageType, deviceUsecase.HandleNodeDeviceMessage)
+		wsHub.HandleNodeMessage(kit.DeviceHeartbeatMessageType, deviceUsecase.HandleNodeDeviceMessage)
+		wsHub.OnNodeOffline(func(nodeID string) {
+			if err := deviceUsecase.NodeOffline(ctx, nodeID); err != nil {
+				log.Errorf("节点下线处理失败, 节点ID: %s, error: %v", nodeID, err)
+			}
+		})
+
+		// 节点定期上报负载信息，供管理端查看节点健康状态
+		wsHub.HandleNodeMessage(kit.NodeMetricsMessageType, nodeUsecase.HandleNodeMetrics)
+		wsHub.OnNodeOffline(func(nodeID string) {
+			if err := nodeUsecase.NodeOffline(ctx, nodeID); err != nil {
+				log.Errorf("清除节点负载信息失败, 节点ID: %s, error: %v", nodeID, err)
+			}
+		})
+
+		// 多个管理端实例共享节点连接信息，任一实例都能向所有节点推送消息
+		wsHub.EnableCluster(ctx, redisClient)
+
 		go kit.InitWebSocket()
 		go tracer.Run()
+		go documentUsecase.RunParseWorker(ctx)
+		go deviceUsecase.RunTelemetryRetention(ctx)
 		return nil
 	}
 }
@@ -0,0 +1,381 @@
+package kit
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BsDiffFormat 差分包格式：ENDSLEY/BSDIFF43头 + 8字节新文件大小 + zlib压缩的控制/差分/新增数据流
+// 设备端使用bsdiff43的bspatch，在读取回调中用zlib解压即可
+const BsDiffFormat = "bsdiff43+zlib"
+
+const bsDiffMagic = "ENDSLEY/BSDIFF43"
+
+// ErrBsPatchCorrupt 差分包损坏
+var ErrBsPatchCorrupt = errors.New("差分包损坏")
+
+// BsDiff 生成从oldData到newData的差分包
+func BsDiff(oldData, newData []byte) ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteString(bsDiffMagic)
+	var header [8]byte
+	bsOfftout(int64(len(newData)), header[:])
+	out.Write(header[:])
+
+	zw, err := zlib.NewWriterLevel(&out, zlib.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if err := bsDiffStream(oldData, newData, zw); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// BsPatch 将差分包应用到oldData，返回新文件
+func BsPatch(oldData, patch []byte) ([]byte, error) {
+	if len(patch) < len(bsDiffMagic)+8 || string(patch[:len(bsDiffMagic)]) != bsDiffMagic {
+		return nil, ErrBsPatchCorrupt
+	}
+	newSize := bsOfftin(patch[len(bsDiffMagic):])
+	if newSize < 0 {
+		return nil, ErrBsPatchCorrupt
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(patch[len(bsDiffMagic)+8:]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBsPatchCorrupt, err)
+	}
+	defer zr.Close()
+
+	newData := make([]byte, newSize)
+	var oldPos, newPos int64
+	var ctrl [3]int64
+	var buf [8]byte
+	oldSize := int64(len(oldData))
+	for newPos < newSize {
+		for i := range ctrl {
+			if _, err := io.ReadFull(zr, buf[:]); err != nil {
+				return nil, ErrBsPatchCorrupt
+			}
+			ctrl[i] = bsOfftin(buf[:])
+		}
+		if ctrl[0] < 0 || ctrl[1] < 0 || newPos+ctrl[0] > newSize {
+			return nil, ErrBsPatchCorrupt
+		}
+
+		// 差分数据与旧文件对应位置相加
+		if _, err := io.ReadFull(zr, newData[newPos:newPos+ctrl[0]]); err != nil {
+			return nil, ErrBsPatchCorrupt
+		}
+		for i := int64(0); i < ctrl[0]; i++ {
+			if oldPos+i >= 0 && oldPos+i < oldSize {
+				newData[newPos+i] += oldData[oldPos+i]
+			}
+		}
+		newPos += ctrl[0]
+		oldPos += ctrl[0]
+
+		// 新增数据直接复制
+		if newPos+ctrl[1] > newSize {
+			return nil, ErrBsPatchCorrupt
+		}
+		if _, err := io.ReadFull(zr, newData[newPos:newPos+ctrl[1]]); err != nil {
+			return nil, ErrBsPatchCorrupt
+		}
+		newPos += ctrl[1]
+		oldPos += ctrl[2]
+	}
+	return newData, nil
+}
+
+// bsDiffStream 按bsdiff算法输出控制、差分和新增数据
+func bsDiffStream(oldData, newData []byte, w io.Writer) error {
+	oldSize, newSize := len(oldData), len(newData)
+	sa := bsSuffixSort(oldData)
+
+	var scan, pos, length int
+	var lastScan, lastPos, lastOffset int
+	var ctrl [24]byte
+	for scan < newSize {
+		oldScore := 0
+		scan += length
+		for scsc := scan; scan < newSize; scan++ {
+			length, pos = bsSearch(sa, oldData, newData[scan:], 0, oldSize)
+			for ; scsc < scan+length; scsc++ {
+				if scsc+lastOffset < oldSize && oldData[scsc+lastOffset] == newData[scsc] {
+					oldScore++
+				}
+			}
+			if (length == oldScore && length != 0) || length > oldScore+8 {
+				break
+			}
+			if scan+lastOffset < oldSize && oldData[scan+lastOffset] == newData[scan] {
+				oldScore--
+			}
+		}
+
+		if length == oldScore && scan != newSize {
+			continue
+		}
+
+		// 向前扩展匹配
+		s, sf, lenf := 0, 0, 0
+		for i := 0; lastScan+i < scan && lastPos+i < oldSize; {
+			if oldData[lastPos+i] == newData[lastScan+i] {
+				s++
+			}
+			i++
+			if s*2-i > sf*2-lenf {
+				sf, lenf = s, i
+			}
+		}
+
+		// 向后扩展匹配
+		lenb := 0
+		if scan < newSize {
+			s, sb := 0, 0
+			for i := 1; scan >= lastScan+i && pos >= i; i++ {
+				if oldData[pos-i] == newData[scan-i] {
+					s++
+				}
+				if s*2-i > sb*2-lenb {
+					sb, lenb = s, i
+				}
+			}
+		}
+
+		// 处理前后扩展的重叠部分
+		if lastScan+lenf > scan-lenb {
+			overlap := (lastScan + lenf) - (scan - lenb)
+			s, ss, lens := 0, 0, 0
+			for i := 0; i < overlap; i++ {
+				if newData[lastScan+lenf-overlap+i] == oldData[lastPos+lenf-overlap+i] {
+					s++
+				}
+				if newData[scan-lenb+i] == oldData[pos-lenb+i] {
+					s--
+				}
+				if s > ss {
+					ss, lens = s, i+1
+				}
+			}
+			lenf += lens - overlap
+			lenb -= lens
+		}
+
+		extraLen := (scan - lenb) - (lastScan + lenf)
+		bsOfftout(int64(lenf), ctrl[0:8])
+		bsOfftout(int64(extraLen), ctrl[8:16])
+		bsOfftout(int64((pos-lenb)-(lastPos+lenf)), ctrl[16:24])
+		if _, err := w.Write(ctrl[:]); err != nil {
+			return err
+		}
+
+		diff := make([]byte, lenf)
+		for i := 0; i < lenf; i++ {
+			diff[i] = newData[lastScan+i] - oldData[lastPos+i]
+		}
+		if _, err := w.Write(diff); err != nil {
+			return err
+		}
+		if _, err := w.Write(newData[lastScan+lenf : lastScan+lenf+extraLen]); err != nil {
+			return err
+		}
+
+		lastScan = scan - lenb
+		lastPos = pos - lenb
+		lastOffset = pos - scan
+	}
+	return nil
+}
+
+// bsSuffixSort 使用Larsson-Sadakane算法构建后缀数组（qsufsort）
+func bsSuffixSort(data []byte) []int {
+	n := len(data)
+	sa := make([]int, n+1)
+	rank := make([]int, n+1)
+
+	var buckets [256]int
+	for _, b := range data {
+		buckets[b]++
+	}
+	for i := 1; i < 256; i++ {
+		buckets[i] += buckets[i-1]
+	}
+	for i := 255; i > 0; i-- {
+		buckets[i] = buckets[i-1]
+	}
+	buckets[0] = 0
+
+	for i, b := range data {
+		buckets[b]++
+		sa[buckets[b]] = i
+	}
+	sa[0] = n
+	for i, b := range data {
+		rank[i] = buckets[b]
+	}
+	rank[n] = 0
+	for i := 1; i < 256; i++ {
+		if buckets[i] == buckets[i-1]+1 {
+			sa[buckets[i]] = -1
+		}
+	}
+	sa[0] = -1
+
+	for h := 1; sa[0] != -(n + 1); h += h {
+		length := 0
+		i := 0
+		for i < n+1 {
+			if sa[i] < 0 {
+				length -= sa[i]
+				i -= sa[i]
+				continue
+			}
+			if length != 0 {
+				sa[i-length] = -length
+			}
+			length = rank[sa[i]] + 1 - i
+			bsSplit(sa, rank, i, length, h)
+			i += length
+			length = 0
+		}
+		if length != 0 {
+			sa[i-length] = -length
+		}
+	}
+
+	for i := 0; i < n+1; i++ {
+		sa[rank[i]] = i
+	}
+	return sa
+}
+
+func bsSplit(sa, rank []int, start, length, h int) {
+	if length < 16 {
+		for k := start; k < start+length; {
+			j := 1
+			x := rank[sa[k]+h]
+			for i := 1; k+i < start+length; i++ {
+				if rank[sa[k+i]+h] < x {
+					x = rank[sa[k+i]+h]
+					j = 0
+				}
+				if rank[sa[k+i]+h] == x {
+					sa[k+j], sa[k+i] = sa[k+i], sa[k+j]
+					j++
+				}
+			}
+			for i := 0; i < j; i++ {
+				rank[sa[k+i]] = k + j - 1
+			}
+			if j == 1 {
+				sa[k] = -1
+			}
+			k += j
+		}
+		return
+	}
+
+	x := rank[sa[start+length/2]+h]
+	jj, kk := 0, 0
+	for i := start; i < start+length; i++ {
+		if rank[sa[i]+h] < x {
+			jj++
+		}
+		if rank[sa[i]+h] == x {
+			kk++
+		}
+	}
+	jj += start
+	kk += jj
+
+	i, j, k := start, 0, 0
+	for i < jj {
+		switch {
+		case rank[sa[i]+h] < x:
+			i++
+		case rank[sa[i]+h] == x:
+			sa[i], sa[jj+j] = sa[jj+j], sa[i]
+			j++
+		default:
+			sa[i], sa[kk+k] = sa[kk+k], sa[i]
+			k++
+		}
+	}
+	for jj+j < kk {
+		if rank[sa[jj+j]+h] == x {
+			j++
+		} else {
+			sa[jj+j], sa[kk+k] = sa[kk+k], sa[jj+j]
+			k++
+		}
+	}
+
+	if jj > start {
+		bsSplit(sa, rank, start, jj-start, h)
+	}
+	for i := 0; i < kk-jj; i++ {
+		rank[sa[jj+i]] = kk - 1
+	}
+	if jj == kk-1 {
+		sa[jj] = -1
+	}
+	if start+length > kk {
+		bsSplit(sa, rank, kk, start+length-kk, h)
+	}
+}
+
+// bsSearch 在后缀数组中二分查找与target最长匹配的位置，返回匹配长度和旧文件中的位置
+func bsSearch(sa []int, oldData, target []byte, st, en int) (int, int) {
+	for en-st >= 2 {
+		x := st + (en-st)/2
+		n := min(len(oldData)-sa[x], len(target))
+		if bytes.Compare(oldData[sa[x]:sa[x]+n], target[:n]) < 0 {
+			st = x
+		} else {
+			en = x
+		}
+	}
+	x := bsMatchLen(oldData[sa[st]:], target)
+	y := bsMatchLen(oldData[sa[en]:], target)
+	if x > y {
+		return x, sa[st]
+	}
+	return y, sa[en]
+}
+
+func bsMatchLen(a, b []byte) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// bsOfftout 以bsdiff的符号-幅值小端格式写入int64
+func bsOfftout(x int64, buf []byte) {
+	y := x
+	if x < 0 {
+		y = -x
+	}
+	binary.LittleEndian.PutUint64(buf, uint64(y))
+	if x < 0 {
+		buf[7] |= 0x80
+	}
+}
+
+func bsOfftin(buf []byte) int64 {
+	y := int64(binary.LittleEndian.Uint64(buf) &^ (1 << 63))
+	if buf[7]&0x80 != 0 {
+		y = -y
+	}
+	return y
+}
@@ -0,0 +1,45 @@
+package kit_test
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBsDiffRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	oldData := make([]byte, 256*1024)
+	rng.Read(oldData)
+
+	// 模拟固件版本间的少量改动：修改、插入和删除
+	newData := append([]byte(nil), oldData[:1000]...)
+	newData = append(newData, []byte("new feature code")...)
+	newData = append(newData, oldData[1000:50000]...)
+	newData = append(newData, oldData[60000:]...)
+	for i := 100000; i < 100100; i++ {
+		newData[i]++
+	}
+
+	patch, err := kit.BsDiff(oldData, newData)
+	require.NoError(t, err)
+	assert.Less(t, len(patch), len(newData)/10)
+
+	patched, err := kit.BsPatch(oldData, patch)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(newData, patched))
+
+	_, err = kit.BsPatch(oldData, patch[:len(patch)/2])
+	assert.ErrorIs(t, err, kit.ErrBsPatchCorrupt)
+
+	// 旧文件为空时退化为完整数据
+	patch, err = kit.BsDiff(nil, []byte("firmware"))
+	require.NoError(t, err)
+	patched, err = kit.BsPatch(nil, patch)
+	require.NoError(t, err)
+	assert.Equal(t, "firmware", string(patched))
+}
@@ -65,8 +65,8 @@ func ValidateCaptcha(ctx context.Context, redisClient *redis.Client, uuid, code
 		return false
 	}
 
-	// 验证验证码（不区分大小写）
-	valid := storedCode != "" && (storedCode == code || storedCode == code)
+	// 验证验证码（区分大小写）
+	valid := storedCode != "" && storedCode == code
 	if !valid {
 		return false
 	}
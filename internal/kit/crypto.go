@@ -2,11 +2,16 @@ package kit
 
 import (
 	"crypto/aes"
+	"crypto/ed25519"
 	"crypto/hmac"
 	"crypto/md5"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -95,3 +100,113 @@ func GenerateWebSocketToken(clientId, username, secretKey string) (string, error
 	// 返回格式: signature.timestamp
 	return fmt.Sprintf("%s.%d", signatureBase64, timestamp), nil
 }
+
+// NodeTokenMaxSkew 节点token时间戳允许的最大偏差
+const NodeTokenMaxSkew = 5 * time.Minute
+
+// GenerateNodeToken 生成语音服务节点连接/ws的认证token
+// 签名内容为 node|identifier|timestamp，格式与GenerateWebSocketToken一致: signature.timestamp
+func GenerateNodeToken(nodeID, secretKey string) (string, error) {
+	if secretKey == "" {
+		return "", fmt.Errorf("节点认证密钥未配置(server.secret)")
+	}
+	timestamp := time.Now().Unix()
+	return fmt.Sprintf("%s.%d", signNodeToken(nodeID, timestamp, secretKey), timestamp), nil
+}
+
+// VerifyNodeToken 校验节点认证token的签名和时间戳
+func VerifyNodeToken(token, nodeID, secretKey string) error {
+	if secretKey == "" {
+		return fmt.Errorf("节点认证密钥未配置(server.secret)")
+	}
+
+	signature, timestampStr, ok := strings.Cut(token, ".")
+	if !ok || signature == "" {
+		return fmt.Errorf("节点token格式错误")
+	}
+	timestamp, err := strconv.ParseInt(timestampStr, 10, 64)
+	if err != nil {
+		return fmt.Errorf("节点token时间戳无效")
+	}
+
+	issuedAt := time.Unix(timestamp, 0)
+	if skew := time.Since(issuedAt); skew > NodeTokenMaxSkew || skew < -NodeTokenMaxSkew {
+		return fmt.Errorf("节点token已过期")
+	}
+
+	expected := signNodeToken(nodeID, timestamp, secretKey)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return fmt.Errorf("节点token签名无效")
+	}
+	return nil
+}
+
+// signNodeToken 计算节点token签名
+func signNodeToken(nodeID string, timestamp int64, secretKey string) string {
+	h := hmac.New(sha256.New, []byte(secretKey))
+	h.Write([]byte(fmt.Sprintf("node|%s|%d", nodeID, timestamp)))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// GenerateSigningKey 生成Ed25519签名密钥，返回Base64编码的私钥种子（32字节）和公钥
+func GenerateSigningKey() (privateKey, publicKey string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", fmt.Errorf("生成签名密钥失败: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(priv.Seed()), base64.StdEncoding.EncodeToString(pub), nil
+}
+
+// ParseSigningKey 解析Base64编码的Ed25519私钥，支持32字节种子或64字节完整私钥
+func ParseSigningKey(privateKey string) (ed25519.PrivateKey, error) {
+	keyBytes, err := base64.StdEncoding.DecodeString(strings.TrimSpace(privateKey))
+	if err != nil {
+		return nil, fmt.Errorf("签名私钥不是有效的Base64: %w", err)
+	}
+	switch len(keyBytes) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(keyBytes), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(keyBytes), nil
+	default:
+		return nil, fmt.Errorf("签名私钥长度错误: %d", len(keyBytes))
+	}
+}
+
+// ParseVerifyKey 解析Base64编码的Ed25519公钥
+func ParseVerifyKey(publicKey string) (ed25519.PublicKey, error) {
+	keyBytes, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("签名公钥不是有效的Base64: %w", err)
+	}
+	if len(keyBytes) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("签名公钥长度错误: %d", len(keyBytes))
+	}
+	return ed25519.PublicKey(keyBytes), nil
+}
+
+// SigningKeyID 公钥标识，取公钥SHA-256的前8字节（十六进制），设备据此选择验签公钥
+func SigningKeyID(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:8])
+}
+
+// FirmwareManifest 固件签名内容："类型|版本|大小|SHA-256(十六进制小写)"
+// 签名同时覆盖类型和版本，防止旧版本固件的签名被用于降级
+func FirmwareManifest(otaType, version string, size int64, sha256Hex string) []byte {
+	return []byte(fmt.Sprintf("%s|%s|%d|%s", otaType, version, size, strings.ToLower(sha256Hex)))
+}
+
+// SignFirmwareManifest 使用Ed25519私钥对固件签名内容签名，返回Base64编码的签名
+func SignFirmwareManifest(privateKey ed25519.PrivateKey, manifest []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, manifest))
+}
+
+// VerifyFirmwareManifest 校验固件签名
+func VerifyFirmwareManifest(publicKey ed25519.PublicKey, manifest []byte, signature string) bool {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(publicKey, manifest, sig)
+}
@@ -0,0 +1,68 @@
+package kit_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyNodeToken(t *testing.T) {
+	token, err := kit.GenerateNodeToken("node-1", "secret")
+	assert.NoError(t, err)
+
+	signature, _, _ := strings.Cut(token, ".")
+	expired := fmt.Sprintf("%s.%d", signature, time.Now().Add(-kit.NodeTokenMaxSkew-time.Minute).Unix())
+
+	tests := []struct {
+		name    string
+		token   string
+		nodeID  string
+		secret  string
+		wantErr bool
+	}{
+		{name: "valid", token: token, nodeID: "node-1", secret: "secret"},
+		{name: "other identifier", token: token, nodeID: "node-2", secret: "secret", wantErr: true},
+		{name: "wrong secret", token: token, nodeID: "node-1", secret: "other", wantErr: true},
+		{name: "expired", token: expired, nodeID: "node-1", secret: "secret", wantErr: true},
+		{name: "malformed", token: "abc", nodeID: "node-1", secret: "secret", wantErr: true},
+		{name: "empty secret", token: token, nodeID: "node-1", secret: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := kit.VerifyNodeToken(tt.token, tt.nodeID, tt.secret)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestFirmwareManifestSignature(t *testing.T) {
+	privateKey, publicKey, err := kit.GenerateSigningKey()
+	assert.NoError(t, err)
+
+	priv, err := kit.ParseSigningKey(privateKey)
+	assert.NoError(t, err)
+	pub, err := kit.ParseVerifyKey(publicKey)
+	assert.NoError(t, err)
+	assert.Len(t, kit.SigningKeyID(pub), 16)
+
+	manifest := kit.FirmwareManifest("esp32", "1.2.0", 1024, "ABCDEF")
+	signature := kit.SignFirmwareManifest(priv, manifest)
+	assert.True(t, kit.VerifyFirmwareManifest(pub, kit.FirmwareManifest("esp32", "1.2.0", 1024, "abcdef"), signature))
+	assert.False(t, kit.VerifyFirmwareManifest(pub, kit.FirmwareManifest("esp32", "1.1.0", 1024, "abcdef"), signature))
+	assert.False(t, kit.VerifyFirmwareManifest(pub, manifest, "invalid"))
+
+	_, otherPublicKey, err := kit.GenerateSigningKey()
+	assert.NoError(t, err)
+	otherPub, err := kit.ParseVerifyKey(otherPublicKey)
+	assert.NoError(t, err)
+	assert.False(t, kit.VerifyFirmwareManifest(otherPub, manifest, signature))
+}
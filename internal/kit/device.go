@@ -1,10 +1,12 @@
 package kit
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"math/big"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -16,6 +18,12 @@ const (
 	DeviceActivationCodeKeyPrefix = "ota:activation:code:"
 	// DeviceActivationDataKeyPrefix 设备激活数据Redis Key前缀
 	DeviceActivationDataKeyPrefix = "ota:activation:data:"
+	// DeviceActivationChallengeKeyPrefix 设备激活挑战码Redis Key前缀
+	DeviceActivationChallengeKeyPrefix = "ota:activation:challenge:"
+	// DeviceActivationFailKeyPrefix 用户尝试激活码次数Redis Key前缀
+	DeviceActivationFailKeyPrefix = "ota:activation:fail:"
+	// DeviceActivationIPFailKeyPrefix 客户端IP尝试激活码次数Redis Key前缀
+	DeviceActivationIPFailKeyPrefix = "ota:activation:fail:ip:"
 	// AgentDeviceCountKeyPrefix 智能体设备数量缓存Key前缀
 	AgentDeviceCountKeyPrefix = "agent:device:count:"
 	// AgentDeviceLastConnectedKeyPrefix 智能体设备最后连接时间缓存Key前缀
@@ -37,6 +45,21 @@ func GetDeviceActivationDataKey(safeDeviceId string) string {
 	return DeviceActivationDataKeyPrefix + safeDeviceId
 }
 
+// GetDeviceActivationChallengeKey 获取设备激活挑战码Redis Key
+func GetDeviceActivationChallengeKey(safeDeviceId string) string {
+	return DeviceActivationChallengeKeyPrefix + safeDeviceId
+}
+
+// GetDeviceActivationFailKey 获取用户尝试激活码次数Redis Key
+func GetDeviceActivationFailKey(userId int64) string {
+	return DeviceActivationFailKeyPrefix + strconv.FormatInt(userId, 10)
+}
+
+// GetDeviceActivationIPFailKey 获取客户端IP尝试激活码次数Redis Key
+func GetDeviceActivationIPFailKey(ip string) string {
+	return DeviceActivationIPFailKeyPrefix + ip
+}
+
 // GetAgentDeviceCountKey 获取智能体设备数量缓存Key
 func GetAgentDeviceCountKey(agentId string) string {
 	return AgentDeviceCountKeyPrefix + agentId
@@ -73,6 +96,40 @@ func GenerateDeviceActivationCode() string {
 	return string(code)
 }
 
+// GenerateActivationChallenge 生成设备激活挑战码（32字节随机数，十六进制）
+func GenerateActivationChallenge() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// DeriveDeviceActivationKey 由型号密钥和设备ID派生设备独立的激活密钥：HMAC-SHA256(型号密钥, 小写设备ID)
+func DeriveDeviceActivationKey(boardSecret, deviceId string) []byte {
+	h := hmac.New(sha256.New, []byte(boardSecret))
+	h.Write([]byte(deviceId))
+	return h.Sum(nil)
+}
+
+// SignActivationChallenge 计算挑战码的HMAC-SHA256签名（十六进制），与设备固件的计算方式一致
+func SignActivationChallenge(key []byte, challenge string) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(challenge))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// VerifyActivationChallenge 校验设备对挑战码的签名，使用常量时间比较
+func VerifyActivationChallenge(key []byte, challenge, signature string) bool {
+	actual, err := hex.DecodeString(strings.TrimSpace(signature))
+	if err != nil || len(key) == 0 {
+		return false
+	}
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(challenge))
+	return hmac.Equal(actual, h.Sum(nil))
+}
+
 // GenerateDeviceRegisterCode 生成6位设备注册验证码（与Java的Math.random().substring(2, 8)逻辑类似）
 func GenerateDeviceRegisterCode() string {
 	// 生成6位随机数字
@@ -0,0 +1,751 @@
+package kit
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// 单个PDF流解压后的最大字节数，防止压缩炸弹
+const pdfMaxStreamSize = 64 << 20
+
+var (
+	pdfObjectPattern = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	pdfRefPattern    = regexp.MustCompile(`^(\d+)\s+(\d+)\s+R`)
+	pdfRefsPattern   = regexp.MustCompile(`(\d+)\s+\d+\s+R`)
+	pdfNameRefRegexp = regexp.MustCompile(`/([^\s/<>\[\]()]+)\s+(\d+)\s+\d+\s+R`)
+)
+
+// pdfObject PDF间接对象，stream为解码后的流内容（不支持的编码为nil）
+type pdfObject struct {
+	dict   []byte
+	stream []byte
+}
+
+// pdfCMap ToUnicode映射表
+type pdfCMap struct {
+	codeLen int
+	chars   map[uint32]string
+}
+
+// pdfDocument 解析后的PDF对象集合
+type pdfDocument struct {
+	objects map[int]*pdfObject
+	cmaps   map[int]*pdfCMap
+}
+
+// ExtractPDFText 从文本型PDF中提取纯文本
+// 支持未压缩与FlateDecode压缩的内容流、对象流以及字体的ToUnicode映射，
+// 不支持扫描件（图片）与加密文档
+func ExtractPDFText(data []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF-")) {
+		return "", errors.New("not a pdf file")
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return "", errors.New("encrypted pdf is not supported")
+	}
+
+	doc := parsePDF(data)
+	var sb strings.Builder
+	for _, page := range doc.pages() {
+		text := doc.pageText(page)
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		sb.WriteString(text)
+		sb.WriteString("\n\n")
+	}
+
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// parsePDF 扫描并解析文件中所有的间接对象
+func parsePDF(data []byte) *pdfDocument {
+	doc := &pdfDocument{
+		objects: make(map[int]*pdfObject),
+		cmaps:   make(map[int]*pdfCMap),
+	}
+
+	matches := pdfObjectPattern.FindAllSubmatchIndex(data, -1)
+	for i, m := range matches {
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		end := len(data)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		body := data[m[1]:end]
+		if idx := bytes.Index(body, []byte("endobj")); idx >= 0 {
+			body = body[:idx]
+		}
+		doc.objects[num] = parsePDFObject(body)
+	}
+
+	// 展开对象流（PDF 1.5+）中的对象
+	for _, obj := range doc.objects {
+		if obj.stream != nil && pdfNameValue(obj.dict, "Type") == "ObjStm" {
+			doc.expandObjectStream(obj)
+		}
+	}
+
+	for num, obj := range doc.objects {
+		if obj.stream != nil && bytes.Contains(obj.stream, []byte("begincmap")) {
+			doc.cmaps[num] = parsePDFCMap(obj.stream)
+		}
+	}
+
+	return doc
+}
+
+// parsePDFObject 解析对象体，分离字典与流
+func parsePDFObject(body []byte) *pdfObject {
+	obj := &pdfObject{dict: body}
+	idx := bytes.Index(body, []byte("stream"))
+	if idx < 0 {
+		return obj
+	}
+
+	obj.dict = body[:idx]
+	raw := body[idx+len("stream"):]
+	if bytes.HasPrefix(raw, []byte("\r\n")) {
+		raw = raw[2:]
+	} else if bytes.HasPrefix(raw, []byte("\n")) || bytes.HasPrefix(raw, []byte("\r")) {
+		raw = raw[1:]
+	}
+	if end := bytes.LastIndex(raw, []byte("endstream")); end >= 0 {
+		raw = raw[:end]
+	}
+	if length, err := strconv.Atoi(string(pdfDictEntry(obj.dict, "Length"))); err == nil && length >= 0 && length <= len(raw) {
+		raw = raw[:length]
+	}
+
+	filter := strings.Join(strings.Fields(strings.Trim(string(pdfDictEntry(obj.dict, "Filter")), "[]")), "")
+	switch filter {
+	case "":
+		obj.stream = raw
+	case "/FlateDecode":
+		if pdfDictEntry(obj.dict, "DecodeParms") != nil {
+			// 带预测器的流（如xref流）与文本无关
+			return obj
+		}
+		obj.stream = pdfInflate(raw)
+	}
+
+	return obj
+}
+
+// pdfInflate 解压FlateDecode流，损坏的流尽可能返回已解压部分
+func pdfInflate(raw []byte) []byte {
+	r, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+	out, _ := io.ReadAll(io.LimitReader(r, pdfMaxStreamSize))
+	return out
+}
+
+// expandObjectStream 将对象流中的对象加入对象表
+func (d *pdfDocument) expandObjectStream(obj *pdfObject) {
+	n, err1 := strconv.Atoi(string(pdfDictEntry(obj.dict, "N")))
+	first, err2 := strconv.Atoi(string(pdfDictEntry(obj.dict, "First")))
+	if err1 != nil || err2 != nil || first > len(obj.stream) {
+		return
+	}
+
+	header := strings.Fields(string(obj.stream[:first]))
+	type entry struct{ num, offset int }
+	entries := make([]entry, 0, n)
+	for i := 0; i+1 < len(header) && len(entries) < n; i += 2 {
+		num, err1 := strconv.Atoi(header[i])
+		offset, err2 := strconv.Atoi(header[i+1])
+		if err1 != nil || err2 != nil {
+			return
+		}
+		entries = append(entries, entry{num: num, offset: first + offset})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].offset < entries[j].offset })
+
+	for i, e := range entries {
+		end := len(obj.stream)
+		if i+1 < len(entries) {
+			end = entries[i+1].offset
+		}
+		if e.offset > end || end > len(obj.stream) {
+			continue
+		}
+		if _, ok := d.objects[e.num]; !ok {
+			d.objects[e.num] = &pdfObject{dict: obj.stream[e.offset:end]}
+		}
+	}
+}
+
+// resolve 解析间接引用，返回对象字典内容
+func (d *pdfDocument) resolve(value []byte) []byte {
+	if m := pdfRefPattern.FindSubmatch(bytes.TrimSpace(value)); m != nil {
+		num, _ := strconv.Atoi(string(m[1]))
+		if obj, ok := d.objects[num]; ok {
+			return obj.dict
+		}
+		return nil
+	}
+	return value
+}
+
+// pdfPage 页面及其继承的资源
+type pdfPage struct {
+	dict      []byte
+	resources []byte
+}
+
+// pages 按页面树顺序返回所有页面，找不到页面树时按对象编号顺序返回
+func (d *pdfDocument) pages() []*pdfPage {
+	var pages []*pdfPage
+	visited := make(map[int]bool)
+
+	var walk func(num int, resources []byte)
+	walk = func(num int, resources []byte) {
+		obj, ok := d.objects[num]
+		if !ok || visited[num] || len(pages) > 100000 {
+			return
+		}
+		visited[num] = true
+		if res := pdfDictEntry(obj.dict, "Resources"); res != nil {
+			resources = d.resolve(res)
+		}
+		if pdfNameValue(obj.dict, "Type") == "Page" {
+			pages = append(pages, &pdfPage{dict: obj.dict, resources: resources})
+			return
+		}
+		for _, m := range pdfRefsPattern.FindAllSubmatch(pdfDictEntry(obj.dict, "Kids"), -1) {
+			kid, _ := strconv.Atoi(string(m[1]))
+			walk(kid, resources)
+		}
+	}
+
+	for _, num := range d.sortedObjectNums() {
+		if pdfNameValue(d.objects[num].dict, "Type") != "Catalog" {
+			continue
+		}
+		if m := pdfRefPattern.FindSubmatch(pdfDictEntry(d.objects[num].dict, "Pages")); m != nil {
+			root, _ := strconv.Atoi(string(m[1]))
+			walk(root, nil)
+		}
+		break
+	}
+	if len(pages) > 0 {
+		return pages
+	}
+
+	for _, num := range d.sortedObjectNums() {
+		if pdfNameValue(d.objects[num].dict, "Type") == "Page" {
+			pages = append(pages, &pdfPage{dict: d.objects[num].dict, resources: d.resolve(pdfDictEntry(d.objects[num].dict, "Resources"))})
+		}
+	}
+	return pages
+}
+
+func (d *pdfDocument) sortedObjectNums() []int {
+	nums := make([]int, 0, len(d.objects))
+	for num := range d.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+// pageText 提取单个页面的文本
+func (d *pdfDocument) pageText(page *pdfPage) string {
+	fonts := make(map[string]*pdfCMap)
+	for _, m := range pdfNameRefRegexp.FindAllSubmatch(d.resolve(pdfDictEntry(page.resources, "Font")), -1) {
+		num, _ := strconv.Atoi(string(m[2]))
+		font, ok := d.objects[num]
+		if !ok {
+			continue
+		}
+		if ref := pdfRefPattern.FindSubmatch(pdfDictEntry(font.dict, "ToUnicode")); ref != nil {
+			cmapNum, _ := strconv.Atoi(string(ref[1]))
+			fonts[string(m[1])] = d.cmaps[cmapNum]
+		}
+	}
+
+	var content []byte
+	contents := pdfDictEntry(page.dict, "Contents")
+	if m := pdfRefPattern.FindSubmatch(contents); m != nil {
+		// 内容可能是指向数组对象的间接引用
+		num, _ := strconv.Atoi(string(m[1]))
+		if obj, ok := d.objects[num]; ok && obj.stream == nil {
+			contents = obj.dict
+		}
+	}
+	for _, m := range pdfRefsPattern.FindAllSubmatch(contents, -1) {
+		num, _ := strconv.Atoi(string(m[1]))
+		if obj, ok := d.objects[num]; ok && obj.stream != nil {
+			content = append(content, obj.stream...)
+			content = append(content, '\n')
+		}
+	}
+
+	return extractPDFContentText(content, fonts)
+}
+
+// extractPDFContentText 执行内容流中的文本操作符并输出文本
+func extractPDFContentText(content []byte, fonts map[string]*pdfCMap) string {
+	var sb strings.Builder
+	var operands [][]byte
+	var cmap *pdfCMap
+
+	newline := func() {
+		if sb.Len() > 0 && !strings.HasSuffix(sb.String(), "\n") {
+			sb.WriteByte('\n')
+		}
+	}
+
+	lex := &pdfLexer{data: content}
+	for {
+		tok, ok := lex.next()
+		if !ok {
+			break
+		}
+		switch {
+		case tok[0] == '(' || tok[0] == '<' && (len(tok) < 2 || tok[1] != '<') || tok[0] == '[' || tok[0] == '/' ||
+			tok[0] == '-' || tok[0] == '+' || tok[0] == '.' || tok[0] >= '0' && tok[0] <= '9':
+			operands = append(operands, tok)
+			continue
+		}
+
+		switch string(tok) {
+		case "Tf":
+			if len(operands) >= 2 {
+				cmap = fonts[strings.TrimPrefix(string(operands[len(operands)-2]), "/")]
+			}
+		case "Tj":
+			if len(operands) > 0 {
+				sb.WriteString(decodePDFString(operands[len(operands)-1], cmap))
+			}
+		case "'", "\"":
+			newline()
+			if len(operands) > 0 {
+				sb.WriteString(decodePDFString(operands[len(operands)-1], cmap))
+			}
+		case "TJ":
+			if len(operands) > 0 {
+				sb.WriteString(decodePDFArray(operands[len(operands)-1], cmap))
+			}
+		case "T*", "ET":
+			newline()
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, err := strconv.ParseFloat(string(operands[len(operands)-1]), 64); err == nil && ty != 0 {
+					newline()
+				} else if tx, err := strconv.ParseFloat(string(operands[len(operands)-2]), 64); err == nil && tx > 0 && !strings.HasSuffix(sb.String(), " ") {
+					sb.WriteByte(' ')
+				}
+			}
+		case "BI":
+			lex.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+
+	return sb.String()
+}
+
+// decodePDFArray 解码TJ数组，较大的字距调整视为空格
+func decodePDFArray(array []byte, cmap *pdfCMap) string {
+	var sb strings.Builder
+	lex := &pdfLexer{data: bytes.TrimSuffix(bytes.TrimPrefix(array, []byte("[")), []byte("]"))}
+	for {
+		tok, ok := lex.next()
+		if !ok {
+			break
+		}
+		if tok[0] == '(' || tok[0] == '<' {
+			sb.WriteString(decodePDFString(tok, cmap))
+			continue
+		}
+		if adjust, err := strconv.ParseFloat(string(tok), 64); err == nil && adjust < -200 && sb.Len() > 0 && !strings.HasSuffix(sb.String(), " ") {
+			sb.WriteByte(' ')
+		}
+	}
+	return sb.String()
+}
+
+// decodePDFString 解码字面量或十六进制字符串
+func decodePDFString(tok []byte, cmap *pdfCMap) string {
+	var raw []byte
+	switch tok[0] {
+	case '(':
+		raw = unescapePDFLiteral(pdfTokenBody(tok))
+	case '<':
+		raw = decodePDFHex(pdfTokenBody(tok))
+	default:
+		return ""
+	}
+
+	if cmap != nil {
+		return cmap.decode(raw)
+	}
+
+	// 无ToUnicode映射时按单字节编码近似处理
+	var sb strings.Builder
+	for _, b := range raw {
+		switch {
+		case b >= 0x20 && b < 0x7f:
+			sb.WriteByte(b)
+		case b >= 0xa0:
+			sb.WriteRune(rune(b))
+		case b == '\t':
+			sb.WriteByte(' ')
+		}
+	}
+	return sb.String()
+}
+
+// unescapePDFLiteral 处理字面量字符串中的转义序列
+func unescapePDFLiteral(s []byte) []byte {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' || i+1 >= len(s) {
+			out = append(out, c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case '\r':
+			if i+1 < len(s) && s[i+1] == '\n' {
+				i++
+			}
+		case '\n':
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			v := 0
+			j := i
+			for ; j < len(s) && j < i+3 && s[j] >= '0' && s[j] <= '7'; j++ {
+				v = v*8 + int(s[j]-'0')
+			}
+			out = append(out, byte(v))
+			i = j - 1
+		default:
+			out = append(out, s[i])
+		}
+	}
+	return out
+}
+
+// decodePDFHex 解码十六进制字符串，奇数位末尾补0
+func decodePDFHex(s []byte) []byte {
+	clean := make([]byte, 0, len(s)+1)
+	for _, c := range s {
+		if c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F' {
+			clean = append(clean, c)
+		}
+	}
+	if len(clean)%2 == 1 {
+		clean = append(clean, '0')
+	}
+	out := make([]byte, len(clean)/2)
+	if _, err := hex.Decode(out, clean); err != nil {
+		return nil
+	}
+	return out
+}
+
+// decode 按ToUnicode映射解码字符码
+func (c *pdfCMap) decode(raw []byte) string {
+	var sb strings.Builder
+	for i := 0; i+c.codeLen <= len(raw); i += c.codeLen {
+		var code uint32
+		for _, b := range raw[i : i+c.codeLen] {
+			code = code<<8 | uint32(b)
+		}
+		if s, ok := c.chars[code]; ok {
+			sb.WriteString(s)
+		}
+	}
+	return sb.String()
+}
+
+// parsePDFCMap 解析ToUnicode CMap中的codespacerange、bfchar和bfrange
+func parsePDFCMap(data []byte) *pdfCMap {
+	cmap := &pdfCMap{codeLen: 1, chars: make(map[uint32]string)}
+	lex := &pdfLexer{data: data}
+
+	var section string
+	var args [][]byte
+	for {
+		tok, ok := lex.next()
+		if !ok {
+			break
+		}
+		switch string(tok) {
+		case "begincodespacerange", "beginbfchar", "beginbfrange":
+			section = string(tok)
+			args = args[:0]
+			continue
+		case "endcodespacerange", "endbfchar", "endbfrange":
+			section = ""
+			continue
+		}
+		if section == "" || tok[0] != '<' && tok[0] != '[' {
+			continue
+		}
+
+		args = append(args, tok)
+		switch section {
+		case "begincodespacerange":
+			if len(args) == 2 {
+				if n := len(decodePDFHex(pdfTokenBody(args[0]))); n > 0 {
+					cmap.codeLen = n
+				}
+				args = args[:0]
+			}
+		case "beginbfchar":
+			if len(args) == 2 {
+				cmap.chars[pdfHexCode(args[0])] = decodeUTF16BE(decodePDFHex(pdfTokenBody(args[1])))
+				args = args[:0]
+			}
+		case "beginbfrange":
+			if len(args) == 3 {
+				cmap.addRange(args[0], args[1], args[2])
+				args = args[:0]
+			}
+		}
+	}
+
+	return cmap
+}
+
+// addRange 添加bfrange映射，目标可为起始码或数组
+func (c *pdfCMap) addRange(lo, hi, dst []byte) {
+	start, end := pdfHexCode(lo), pdfHexCode(hi)
+	if end < start || end-start > 0xffff {
+		return
+	}
+
+	if dst[0] == '[' {
+		lex := &pdfLexer{data: pdfTokenBody(dst)}
+		for code := start; code <= end; code++ {
+			tok, ok := lex.next()
+			if !ok {
+				return
+			}
+			c.chars[code] = decodeUTF16BE(decodePDFHex(pdfTokenBody(tok)))
+		}
+		return
+	}
+
+	base := decodePDFHex(pdfTokenBody(dst))
+	if len(base) < 2 {
+		return
+	}
+	for code := start; code <= end; code++ {
+		target := append([]byte(nil), base...)
+		offset := code - start
+		last := uint32(target[len(target)-2])<<8 | uint32(target[len(target)-1])
+		last += offset
+		target[len(target)-2], target[len(target)-1] = byte(last>>8), byte(last)
+		c.chars[code] = decodeUTF16BE(target)
+	}
+}
+
+// pdfTokenBody 去掉字符串或数组记号首尾的定界符，不完整的记号返回nil
+func pdfTokenBody(tok []byte) []byte {
+	if len(tok) < 2 {
+		return nil
+	}
+	return tok[1 : len(tok)-1]
+}
+
+func pdfHexCode(tok []byte) uint32 {
+	if len(tok) < 2 || tok[0] != '<' {
+		return 0
+	}
+	var code uint32
+	for _, b := range decodePDFHex(pdfTokenBody(tok)) {
+		code = code<<8 | uint32(b)
+	}
+	return code
+}
+
+func decodeUTF16BE(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// pdfDictEntry 返回字典中指定键的原始值（字典、数组、间接引用或单个记号）
+func pdfDictEntry(dict []byte, key string) []byte {
+	name := []byte("/" + key)
+	for offset := 0; ; {
+		idx := bytes.Index(dict[offset:], name)
+		if idx < 0 {
+			return nil
+		}
+		pos := offset + idx + len(name)
+		offset = pos
+		if pos < len(dict) && !isPDFDelimiter(dict[pos]) {
+			continue
+		}
+
+		rest := bytes.TrimLeft(dict[pos:], " \t\r\n")
+		if m := pdfRefPattern.Find(rest); m != nil {
+			return m
+		}
+		lex := &pdfLexer{data: rest}
+		tok, ok := lex.next()
+		if !ok {
+			return nil
+		}
+		return tok
+	}
+}
+
+// pdfNameValue 返回字典中名字类型的值（去掉前导斜杠）
+func pdfNameValue(dict []byte, key string) string {
+	return strings.TrimPrefix(string(pdfDictEntry(dict, key)), "/")
+}
+
+func isPDFDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0, '/', '<', '>', '[', ']', '(', ')', '%':
+		return true
+	}
+	return false
+}
+
+// pdfLexer PDF记号扫描器，字符串、数组与字典作为单个记号返回
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func (l *pdfLexer) next() ([]byte, bool) {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0 {
+			l.pos++
+			continue
+		}
+		break
+	}
+	if l.pos >= len(l.data) {
+		return nil, false
+	}
+
+	start := l.pos
+	switch c := l.data[l.pos]; {
+	case c == '(':
+		l.skipLiteral()
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.skipBalanced("<<", ">>")
+	case c == '<':
+		if end := bytes.IndexByte(l.data[l.pos:], '>'); end >= 0 {
+			l.pos += end + 1
+		} else {
+			l.pos = len(l.data)
+		}
+	case c == '[':
+		l.skipBalanced("[", "]")
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		l.pos++
+	case c == '/':
+		l.pos++
+		for l.pos < len(l.data) && !isPDFDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+	default:
+		for l.pos < len(l.data) && !isPDFDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+	}
+
+	return l.data[start:l.pos], true
+}
+
+// skipLiteral 跳过可嵌套括号的字面量字符串
+func (l *pdfLexer) skipLiteral() {
+	depth := 0
+	for ; l.pos < len(l.data); l.pos++ {
+		switch l.data[l.pos] {
+		case '\\':
+			l.pos++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				l.pos++
+				return
+			}
+		}
+	}
+}
+
+// skipBalanced 跳过成对出现的定界符，内部的字符串会被整体跳过
+func (l *pdfLexer) skipBalanced(open, close string) {
+	depth := 0
+	for l.pos < len(l.data) {
+		switch {
+		case l.data[l.pos] == '(':
+			l.skipLiteral()
+		case bytes.HasPrefix(l.data[l.pos:], []byte(open)):
+			depth++
+			l.pos += len(open)
+		case bytes.HasPrefix(l.data[l.pos:], []byte(close)):
+			depth--
+			l.pos += len(close)
+			if depth == 0 {
+				return
+			}
+		default:
+			l.pos++
+		}
+	}
+}
+
+// skipInlineImage 跳过内联图片的二进制数据
+func (l *pdfLexer) skipInlineImage() {
+	idx := bytes.Index(l.data[l.pos:], []byte("ID"))
+	if idx < 0 {
+		l.pos = len(l.data)
+		return
+	}
+	l.pos += idx + 2
+	for l.pos < len(l.data) {
+		idx := bytes.Index(l.data[l.pos:], []byte("EI"))
+		if idx < 0 {
+			l.pos = len(l.data)
+			return
+		}
+		end := l.pos + idx
+		l.pos = end + 2
+		if end > 0 && isPDFDelimiter(l.data[end-1]) && (l.pos >= len(l.data) || isPDFDelimiter(l.data[l.pos])) {
+			return
+		}
+	}
+}
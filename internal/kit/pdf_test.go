@@ -0,0 +1,32 @@
+package kit
+
+import "testing"
+
+// 截断或畸形的记号不能导致panic
+func TestPDFMalformedTokens(t *testing.T) {
+	contents := []string{
+		"BT [<] TJ ET",
+		"BT [(] TJ ET",
+		"BT < Tj ET",
+		"BT ( Tj ET",
+		"BT [",
+	}
+	for _, content := range contents {
+		t.Run(content, func(t *testing.T) {
+			extractPDFContentText([]byte(content), nil)
+		})
+	}
+
+	cmaps := []string{
+		"1 begincodespacerange < <FFFF> endcodespacerange",
+		"1 beginbfchar <0001> < endbfchar",
+		"1 beginbfrange <0001> <0002> [ endbfrange",
+		"1 beginbfrange <0001> <0002> [<] endbfrange",
+		"1 beginbfrange <0001> <0002> < endbfrange",
+	}
+	for _, cmap := range cmaps {
+		t.Run(cmap, func(t *testing.T) {
+			parsePDFCMap([]byte(cmap))
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/weetime/agent-matrix/internal/conf"
@@ -62,6 +63,14 @@ func NewRedisClient(conf *conf.Bootstrap, logger log.Logger) (*RedisClient, func
 	return client, cleanup, nil
 }
 
+// NewRedisClientWithClient 使用已创建的redis.Client，用于测试
+func NewRedisClientWithClient(rdb *redis.Client, logger log.Logger) *RedisClient {
+	return &RedisClient{
+		client: rdb,
+		log:    log.NewHelper(log.With(logger, "module", "agent-matrix-service/redis")),
+	}
+}
+
 // Get 获取字符串值
 func (r *RedisClient) Get(ctx context.Context, key string) (string, error) {
 	val, err := r.client.Get(ctx, key).Result()
@@ -161,6 +170,116 @@ func GetOtaDownloadCountKey(uuid string) string {
 	return fmt.Sprintf("ota:download:count:%s", uuid)
 }
 
+// GetOtaDeltaLockKey 获取生成OTA差分包的锁key，避免多副本重复生成
+func GetOtaDeltaLockKey(fromOtaId, toOtaId string) string {
+	return fmt.Sprintf("ota:delta:lock:%s:%s", fromOtaId, toOtaId)
+}
+
+// RedisKeys 文档解析任务队列
+const (
+	RedisKeyDocumentParseQueue      = "rag:parse:queue"      // 待执行的解析任务ID列表
+	RedisKeyDocumentParseProcessing = "rag:parse:processing" // 执行中的解析任务ID列表
+)
+
+// GetDocumentParseJobKey 获取文档解析任务的缓存key
+func GetDocumentParseJobKey(jobId string) string {
+	return fmt.Sprintf("rag:parse:job:%s", jobId)
+}
+
+// GetDocumentParseJobLeaseKey 获取文档解析任务租约的缓存key，执行中的节点定期续约
+func GetDocumentParseJobLeaseKey(jobId string) string {
+	return fmt.Sprintf("rag:parse:lease:%s", jobId)
+}
+
+// GetDocumentParseJobByDocumentKey 获取文档最近一次解析任务ID的缓存key
+func GetDocumentParseJobByDocumentKey(documentId string) string {
+	return fmt.Sprintf("rag:parse:document:%s", documentId)
+}
+
+// GetDocumentParseJobsByDatasetKey 获取知识库解析任务ID集合的缓存key
+func GetDocumentParseJobsByDatasetKey(datasetId string) string {
+	return fmt.Sprintf("rag:parse:dataset:%s", datasetId)
+}
+
+// GetDevicePresenceKey 获取设备在线状态的缓存key
+func GetDevicePresenceKey(macAddress string) string {
+	return fmt.Sprintf("device:presence:%s", macAddress)
+}
+
+// GetDevicePresenceNodeKey 获取节点上在线设备集合的缓存key
+func GetDevicePresenceNodeKey(nodeID string) string {
+	return fmt.Sprintf("device:presence:node:%s", nodeID)
+}
+
+// GetDevicePresenceAgentKey 获取智能体在线设备集合的缓存key
+func GetDevicePresenceAgentKey(agentId string) string {
+	return fmt.Sprintf("device:presence:agent:%s", agentId)
+}
+
+// RedisKeyDeviceTelemetryCleanLock 设备遥测过期清理锁，多副本部署时同一时间只有一个实例执行清理
+const RedisKeyDeviceTelemetryCleanLock = "device:telemetry:clean:lock"
+
+// RedisKeys 多实例WebSocket节点连接
+const (
+	RedisChannelWebSocketBroadcast = "ws:broadcast" // 节点消息广播频道，各实例订阅后投递给本地连接
+	RedisKeyWebSocketInstances     = "ws:instances" // 存活的管理端实例，分值为最后上报时间
+)
+
+// GetWebSocketInstanceNodesKey 获取实例上节点连接数的缓存key，field为节点ID
+func GetWebSocketInstanceNodesKey(instanceID string) string {
+	return fmt.Sprintf("ws:instance:%s:nodes", instanceID)
+}
+
+// GetNodeBlockedKey 获取被管理员踢出后禁止重连的节点的缓存key
+func GetNodeBlockedKey(nodeID string) string {
+	return fmt.Sprintf("ws:node:blocked:%s", nodeID)
+}
+
+// GetNodeMetricsKey 获取节点最近一次上报的负载信息的缓存key
+func GetNodeMetricsKey(nodeID string) string {
+	return fmt.Sprintf("ws:node:metrics:%s", nodeID)
+}
+
+// GetLoginFailUserKey 获取用户名登录失败次数的缓存key
+func GetLoginFailUserKey(username string) string {
+	return fmt.Sprintf("sys:login:fail:user:%s", loginUsernameKey(username))
+}
+
+// loginUsernameKey 规范化用户名，与数据库不区分大小写、忽略尾部空格的比较规则一致，避免变换写法绕过锁定
+func loginUsernameKey(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// GetLoginFailIPKey 获取IP登录失败次数的缓存key
+func GetLoginFailIPKey(ip string) string {
+	return fmt.Sprintf("sys:login:fail:ip:%s", ip)
+}
+
+// GetLoginLockUserKey 获取被临时锁定的用户名的缓存key
+func GetLoginLockUserKey(username string) string {
+	return fmt.Sprintf("sys:login:lock:user:%s", loginUsernameKey(username))
+}
+
+// GetLoginLockIPKey 获取被临时锁定的IP的缓存key
+func GetLoginLockIPKey(ip string) string {
+	return fmt.Sprintf("sys:login:lock:ip:%s", ip)
+}
+
+// GetLoginDelayKey 获取用户名登录失败后需等待的缓存key，过期前不允许再次尝试
+func GetLoginDelayKey(username string) string {
+	return fmt.Sprintf("sys:login:delay:user:%s", loginUsernameKey(username))
+}
+
+// GetLoginTwoFactorTicketKey 获取密码校验通过后等待双因素认证的登录凭证的缓存key
+func GetLoginTwoFactorTicketKey(ticket string) string {
+	return fmt.Sprintf("sys:login:2fa:%s", ticket)
+}
+
+// GetOIDCStateKey 获取单点登录跳转前保存的登录状态的缓存key
+func GetOIDCStateKey(state string) string {
+	return fmt.Sprintf("sys:oidc:state:%s", state)
+}
+
 // GetRedisObject 获取Redis对象（辅助函数，用于直接使用redis.Client的场景）
 func GetRedisObject(ctx context.Context, client *redis.Client, key string, dest interface{}) error {
 	val, err := client.Get(ctx, key).Result()
@@ -0,0 +1,23 @@
+package kit
+
+import "testing"
+
+func TestLoginUserKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+	}{
+		{name: "大写", username: "Admin"},
+		{name: "尾部空格", username: "admin  "},
+		{name: "大写和首尾空格", username: " ADMIN "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []func(string) string{GetLoginFailUserKey, GetLoginLockUserKey, GetLoginDelayKey} {
+				if got, want := key(tt.username), key("admin"); got != want {
+					t.Errorf("key(%q) = %q, want %q", tt.username, got, want)
+				}
+			}
+		})
+	}
+}
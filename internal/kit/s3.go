@@ -0,0 +1,373 @@
+package kit
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3Service         = "s3"
+	s3TimeFormat      = "20060102T150405Z"
+	s3DateFormat      = "20060102"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3MaxPresignTTL   = 7 * 24 * time.Hour
+)
+
+// ErrS3ObjectNotFound 对象不存在
+var ErrS3ObjectNotFound = errors.New("对象不存在")
+
+// S3Config S3兼容对象存储配置
+type S3Config struct {
+	Endpoint  string // 服务地址，如 https://s3.amazonaws.com、http://127.0.0.1:9000
+	Region    string // 区域，默认us-east-1
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool // 使用 endpoint/bucket/key 形式访问（MinIO等）
+}
+
+// S3ObjectInfo 对象元信息
+type S3ObjectInfo struct {
+	Size         int64
+	ETag         string
+	LastModified time.Time
+}
+
+// S3Client S3兼容对象存储客户端（AWS Signature Version 4）
+type S3Client struct {
+	config     S3Config
+	endpoint   *url.URL
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// NewS3Client 创建S3客户端
+func NewS3Client(config S3Config) (*S3Client, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("S3 endpoint和bucket不能为空")
+	}
+	if config.AccessKey == "" || config.SecretKey == "" {
+		return nil, fmt.Errorf("S3 access_key和secret_key不能为空")
+	}
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("S3 endpoint格式错误: %s", config.Endpoint)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("S3 endpoint仅支持http和https: %s", config.Endpoint)
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	return &S3Client{
+		config:     config,
+		endpoint:   endpoint,
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+		now:        time.Now,
+	}, nil
+}
+
+// PutObject 上传对象
+func (c *S3Client) PutObject(ctx context.Context, key string, data []byte, contentType string) error {
+	req, err := c.newRequest(ctx, http.MethodPut, key, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(data))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	sum := sha256.Sum256(data)
+	c.signRequest(req, hex.EncodeToString(sum[:]))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("上传对象失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3ResponseError("上传对象", resp)
+	}
+	return nil
+}
+
+// GetObject 下载对象，对象不存在时返回ErrS3ObjectNotFound
+func (c *S3Client) GetObject(ctx context.Context, key string) ([]byte, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.signRequest(req, emptyPayloadHash())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("下载对象失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrS3ObjectNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3ResponseError("下载对象", resp)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// GetObjectRange 从offset处开始流式读取对象，调用方负责关闭返回的Body
+func (c *S3Client) GetObjectRange(ctx context.Context, key string, offset int64) (io.ReadCloser, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	c.signRequest(req, emptyPayloadHash())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("下载对象失败: %w", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusOK && offset == 0, resp.StatusCode == http.StatusPartialContent:
+		return resp.Body, nil
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrS3ObjectNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, s3ResponseError("下载对象", resp)
+	}
+}
+
+// HeadObject 检查对象是否存在
+func (c *S3Client) HeadObject(ctx context.Context, key string) (bool, error) {
+	_, err := c.StatObject(ctx, key)
+	if errors.Is(err, ErrS3ObjectNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// StatObject 查询对象元信息，对象不存在时返回ErrS3ObjectNotFound
+func (c *S3Client) StatObject(ctx context.Context, key string) (*S3ObjectInfo, error) {
+	req, err := c.newRequest(ctx, http.MethodHead, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.signRequest(req, emptyPayloadHash())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("查询对象失败: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		info := &S3ObjectInfo{
+			Size: resp.ContentLength,
+			ETag: resp.Header.Get("ETag"),
+		}
+		if lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+			info.LastModified = lastModified
+		}
+		return info, nil
+	case http.StatusNotFound:
+		return nil, ErrS3ObjectNotFound
+	default:
+		return nil, s3ResponseError("查询对象", resp)
+	}
+}
+
+// PresignGetObject 生成对象的预签名下载URL，query可附加response-content-disposition等参数
+func (c *S3Client) PresignGetObject(key string, expires time.Duration, query url.Values) (string, error) {
+	if expires <= 0 || expires > s3MaxPresignTTL {
+		return "", fmt.Errorf("预签名有效期须在1秒到7天之间")
+	}
+	objectURL, err := c.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+
+	now := c.now().UTC()
+	values := url.Values{}
+	for name, items := range query {
+		values[name] = append([]string(nil), items...)
+	}
+	values.Set("X-Amz-Algorithm", s3Algorithm)
+	values.Set("X-Amz-Credential", c.config.AccessKey+"/"+c.credentialScope(now))
+	values.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	values.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	values.Set("X-Amz-SignedHeaders", "host")
+
+	canonicalQuery := s3CanonicalQuery(values)
+	canonicalRequest := strings.Join([]string{
+		http.MethodGet,
+		s3EncodePath(objectURL.Path),
+		canonicalQuery,
+		"host:" + objectURL.Host + "\n",
+		"host",
+		s3UnsignedPayload,
+	}, "\n")
+	signature := c.signature(now, canonicalRequest)
+
+	objectURL.RawQuery = canonicalQuery + "&X-Amz-Signature=" + signature
+	return objectURL.String(), nil
+}
+
+// objectURL 生成对象地址，path-style为 endpoint/bucket/key，否则为 bucket.endpoint/key
+func (c *S3Client) objectURL(key string) (*url.URL, error) {
+	key = strings.TrimLeft(key, "/")
+	if key == "" {
+		return nil, fmt.Errorf("对象key不能为空")
+	}
+	objectURL := *c.endpoint
+	basePath := strings.TrimRight(objectURL.Path, "/")
+	if c.config.PathStyle {
+		objectURL.Path = basePath + "/" + c.config.Bucket + "/" + key
+	} else {
+		objectURL.Host = c.config.Bucket + "." + objectURL.Host
+		objectURL.Path = basePath + "/" + key
+	}
+	objectURL.RawPath = ""
+	objectURL.RawQuery = ""
+	return &objectURL, nil
+}
+
+func (c *S3Client) newRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	objectURL, err := c.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	objectURL.RawPath = s3EncodePath(objectURL.Path)
+	return http.NewRequestWithContext(ctx, method, objectURL.String(), body)
+}
+
+// signRequest 使用Authorization请求头签名，签名host、range、content-type和x-amz-*请求头
+func (c *S3Client) signRequest(req *http.Request, payloadHash string) {
+	now := c.now().UTC()
+	req.Header.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "range" || lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3EncodePath(req.URL.Path),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	signature := c.signature(now, canonicalRequest)
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, c.config.AccessKey, c.credentialScope(now), signedHeaders, signature))
+}
+
+func (c *S3Client) credentialScope(now time.Time) string {
+	return now.Format(s3DateFormat) + "/" + c.config.Region + "/" + s3Service + "/aws4_request"
+}
+
+// signature 计算规范请求的签名
+func (c *S3Client) signature(now time.Time, canonicalRequest string) string {
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		now.Format(s3TimeFormat),
+		c.credentialScope(now),
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.config.SecretKey), now.Format(s3DateFormat))
+	key = hmacSHA256(key, c.config.Region)
+	key = hmacSHA256(key, s3Service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func emptyPayloadHash() string {
+	sum := sha256.Sum256(nil)
+	return hex.EncodeToString(sum[:])
+}
+
+// s3CanonicalQuery 按参数名排序并编码查询参数
+func s3CanonicalQuery(values url.Values) string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		items := append([]string(nil), values[name]...)
+		sort.Strings(items)
+		for _, item := range items {
+			parts = append(parts, s3Encode(name, true)+"="+s3Encode(item, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3EncodePath 编码对象路径，保留分隔符/
+func s3EncodePath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return s3Encode(path, false)
+}
+
+// s3Encode 按SigV4规则进行URI编码，仅保留 A-Z a-z 0-9 - _ . ~
+func s3Encode(value string, encodeSlash bool) string {
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		if (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9') ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' || (ch == '/' && !encodeSlash) {
+			builder.WriteByte(ch)
+			continue
+		}
+		fmt.Fprintf(&builder, "%%%02X", ch)
+	}
+	return builder.String()
+}
+
+func s3ResponseError(action string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s失败: HTTP %d %s", action, resp.StatusCode, strings.TrimSpace(string(body)))
+}
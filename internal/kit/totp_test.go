@@ -0,0 +1,53 @@
+package kit_test
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/kit"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238附录B的SHA1测试向量，取后6位
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 20000000000, code: "353130"},
+	}
+	for _, tt := range tests {
+		code, err := kit.TOTPCode(secret, kit.TOTPStep(time.Unix(tt.unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, tt.code, code)
+	}
+
+	now := time.Unix(1111111109, 0)
+	step, ok := kit.MatchTOTP(secret, "081804", now.Add(kit.TOTPPeriod*time.Second), 1)
+	assert.True(t, ok)
+	assert.Equal(t, kit.TOTPStep(now), step)
+	_, ok = kit.MatchTOTP(secret, "081804", now.Add(3*kit.TOTPPeriod*time.Second), 1)
+	assert.False(t, ok)
+	_, ok = kit.MatchTOTP(secret, "81804", now, 1)
+	assert.False(t, ok)
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := kit.GenerateRecoveryCodes(3)
+	assert.NoError(t, err)
+	assert.Len(t, codes, 3)
+	assert.Len(t, codes[0], 9)
+	assert.NotEqual(t, codes[0], codes[1])
+	assert.Equal(t, kit.HashRecoveryCode(codes[0]), kit.HashRecoveryCode(" "+strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))))
+
+	uri := kit.TOTPURI("AgentMatrix", "13800000000", "JBSWY3DPEHPK3PXP")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/AgentMatrix:13800000000?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+}
@@ -1,9 +1,11 @@
 package kit
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -24,19 +26,38 @@ const (
 	MaxMissedHeartbeats = 3                // 允许的最大心跳丢失次数
 )
 
+// 节点推送的设备在线状态消息类型
+const (
+	DeviceConnectMessageType    = "device_connect"    // 设备连接到节点
+	DeviceDisconnectMessageType = "device_disconnect" // 设备从节点断开
+	DeviceHeartbeatMessageType  = "device_heartbeat"  // 节点上设备的心跳，可批量上报
+)
+
+// 节点定期上报的负载信息消息类型
+const (
+	NodeMetricsMessageType = "node_metrics" // 活跃会话数、CPU、内存、版本等
+)
+
+// 管理端下发给节点的消息类型
+const (
+	ConfigChangedMessageType = "config_changed" // 配置变更，节点按范围重新拉取配置
+)
+
 var ws = &WebSocket{
 	upgrader: &websocket.Upgrader{
 		EnableCompression: true,
 		ReadBufferSize:    1024,
 		WriteBufferSize:   1024,
 		CheckOrigin: func(r *http.Request) bool {
-			return true // 允许所有来源的连接
+			return r.Header.Get("Origin") == "" // 节点为服务端进程，不接受浏览器发起的连接
 		},
 	},
 	// 节点服务连接池
 	nodeConnections: make(map[string][]*ConnInfo), // Node服务连接池，key为nodeID
 	connectionMux:   &sync.RWMutex{},              // 用于保护连接池的并发访问
 	done:            make(chan struct{}),
+	nodeHandlers:    make(map[string]NodeMessageHandler),
+	handlerMux:      &sync.RWMutex{},
 }
 
 // GetWebSocket returns the singleton WebSocket instance
@@ -48,21 +69,37 @@ func GetWebSocket() *WebSocket {
 type ConnInfo struct {
 	Conn             *websocket.Conn
 	ID               string     // 节点ID
+	RemoteAddr       string     // 节点的远程地址
 	ConnTime         time.Time  // 连接建立时间
 	LastPongTime     time.Time  // 最后一次Pong响应时间
 	MissedHeartbeats int        // 未响应的心跳次数
 	mu               sync.Mutex // 保护ConnInfo的并发访问
 }
 
+// markAlive 收到节点的心跳响应，重置未响应计数
+func (ci *ConnInfo) markAlive() {
+	ci.mu.Lock()
+	ci.LastPongTime = time.Now()
+	ci.MissedHeartbeats = 0
+	ci.mu.Unlock()
+}
+
 // WebSocketMessage 定义WebSocket消息的基本结构
 type WebSocketMessage struct {
-	Type         string    `json:"type"`         // 消息类型
-	Payload      Payload   `json:"payload"`      // 消息内容
-	ReceiverName string    `json:"receiverName"` // 接收者名称
-	ReceiveScope string    `json:"receiveScope"` // 接收者类型
-	Timestamp    time.Time `json:"timestamp"`    // 时间戳
+	Type         string          `json:"type"`           // 消息类型
+	Payload      Payload         `json:"payload"`        // 消息内容
+	ReceiverName string          `json:"receiverName"`   // 接收者名称
+	ReceiveScope string          `json:"receiveScope"`   // 接收者类型
+	Timestamp    time.Time       `json:"timestamp"`      // 时间戳
+	Data         json.RawMessage `json:"data,omitempty"` // 业务数据，由对应消息类型的处理器解析
 }
 
+// NodeMessageHandler 节点业务消息处理器，ctx在节点连接断开时取消
+type NodeMessageHandler func(ctx context.Context, nodeID string, msg WebSocketMessage) error
+
+// NodeAuthenticator 校验节点连接的identifier和token，返回错误时拒绝连接
+type NodeAuthenticator func(ctx context.Context, nodeID, token string) error
+
 type Payload struct {
 	Title   string `json:"title"`
 	Message string `json:"message"`
@@ -75,6 +112,12 @@ type WebSocket struct {
 	nodeConnections map[string][]*ConnInfo // Node服务连接池，key为nodeID
 	connectionMux   *sync.RWMutex          // 用于保护连接池的并发访问
 	done            chan struct{}          // 用于关闭心跳检测goroutine
+	cluster         *wsCluster             // 多实例模式，未启用时为nil
+
+	nodeHandlers        map[string]NodeMessageHandler // 按消息类型注册的业务消息处理器
+	nodeOfflineHandlers []func(nodeID string)         // 节点所有连接断开后的回调
+	authenticator       NodeAuthenticator             // 节点连接认证，未设置时拒绝所有连接
+	handlerMux          *sync.RWMutex                 // 保护处理器注册表
 }
 
 // InitWebSocket 初始化WebSocket并启动心跳检测
@@ -99,6 +142,10 @@ func (s *WebSocket) Shutdown() {
 
 	// 清空连接
 	s.nodeConnections = make(map[string][]*ConnInfo)
+
+	if s.cluster != nil {
+		s.cluster.leave()
+	}
 }
 
 // 启动心跳检测器
@@ -118,6 +165,7 @@ func (s *WebSocket) startHeartbeatChecker() {
 
 // 检查所有连接的心跳状态
 func (s *WebSocket) checkHeartbeats() {
+	defer s.syncClusterNodes()
 	s.connectionMux.Lock()
 	defer s.connectionMux.Unlock()
 
@@ -157,6 +205,7 @@ func (s *WebSocket) checkHeartbeats() {
 		if len(activeConns) == 0 {
 			delete(s.nodeConnections, nodeID)
 			log.Infof("节点 %s 的所有连接已关闭", nodeID)
+			go s.notifyNodeOffline(nodeID)
 		} else {
 			s.nodeConnections[nodeID] = activeConns
 		}
@@ -164,30 +213,33 @@ func (s *WebSocket) checkHeartbeats() {
 }
 
 // NodeWebSocketHandler 处理来自Node服务的WebSocket连接请求
+// 节点需携带identifier和由server.secret签发的token，同一identifier的新连接通过认证后替换旧连接
 func (s *WebSocket) NodeWebSocketHandler(w http.ResponseWriter, r *http.Request) {
-	conn, err := s.upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Errorf("WebSocket升级失败: %v", err)
-		return
-	}
-
 	// 从URL查询参数获取节点ID
 	nodeID := r.URL.Query().Get("identifier")
 	if nodeID == "" {
-		log.Errorf("缺少identifier参数")
-		conn.Close()
+		http.Error(w, "缺少identifier参数", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.authenticateNode(r, nodeID); err != nil {
+		log.Warnf("节点认证失败, 节点ID: %s, 地址: %s, error: %v", nodeID, r.RemoteAddr, err)
+		http.Error(w, "节点认证失败", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Errorf("WebSocket升级失败: %v", err)
 		return
 	}
 
-	// 注册新的节点连接
-	connInfo := s.registerNodeClient(conn, nodeID)
+	// 注册新的节点连接，节点重连时旧连接可能尚未因心跳超时断开，由新连接替换
+	connInfo := s.registerNodeClient(conn, nodeID, r.RemoteAddr)
 
 	// 设置pong处理函数
 	conn.SetPongHandler(func(string) error {
-		connInfo.mu.Lock()
-		connInfo.LastPongTime = time.Now()
-		connInfo.MissedHeartbeats = 0 // 重置未响应计数
-		connInfo.mu.Unlock()
+		connInfo.markAlive()
 		return nil
 	})
 
@@ -213,47 +265,187 @@ func (s *WebSocket) NodeWebSocketHandler(w http.ResponseWriter, r *http.Request)
 			continue
 		}
 		// fmt.Println("wsMsg", wsMsg)
-		if err := s.handleNodeMessage(wsMsg, conn, nodeID); err != nil {
+		if err := s.handleNodeMessage(r.Context(), wsMsg, connInfo); err != nil {
 			log.Errorf("节点消息处理失败: %v", err)
 		}
 	}
 }
 
 // 处理来自Node服务的消息
-func (s *WebSocket) handleNodeMessage(msg WebSocketMessage, conn *websocket.Conn, nodeID string) error {
+func (s *WebSocket) handleNodeMessage(ctx context.Context, msg WebSocketMessage, ci *ConnInfo) error {
+	nodeID := ci.ID
 	switch msg.Type {
 	case PingMessageType:
-		return s.handlePing(conn)
-	// 节点特有的消息类型可以在这里处理
-	default:
-		log.Warnf("未知节点消息类型: %s, 节点ID: %s", msg.Type, nodeID)
+		return s.handlePing(ci)
+	case PongMessageType:
+		// 节点以文本消息回复心跳
+		ci.markAlive()
 		return nil
+	default:
+		// 节点特有的消息类型交给注册的处理器
+		s.handlerMux.RLock()
+		handler, ok := s.nodeHandlers[msg.Type]
+		s.handlerMux.RUnlock()
+		if !ok {
+			log.Warnf("未知节点消息类型: %s, 节点ID: %s", msg.Type, nodeID)
+			return nil
+		}
+		return handler(ctx, nodeID, msg)
+	}
+}
+
+// HandleNodeMessage 注册节点业务消息处理器，同一消息类型重复注册时覆盖
+func (s *WebSocket) HandleNodeMessage(msgType string, handler NodeMessageHandler) {
+	s.handlerMux.Lock()
+	defer s.handlerMux.Unlock()
+	s.nodeHandlers[msgType] = handler
+}
+
+// SetNodeAuthenticator 设置节点连接认证
+func (s *WebSocket) SetNodeAuthenticator(authenticator NodeAuthenticator) {
+	s.handlerMux.Lock()
+	defer s.handlerMux.Unlock()
+	s.authenticator = authenticator
+}
+
+// authenticateNode 校验节点连接请求，token可通过查询参数token或Authorization头传递
+func (s *WebSocket) authenticateNode(r *http.Request, nodeID string) error {
+	s.handlerMux.RLock()
+	authenticator := s.authenticator
+	s.handlerMux.RUnlock()
+	if authenticator == nil {
+		return fmt.Errorf("未配置节点认证")
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	if token == "" {
+		return fmt.Errorf("缺少token")
+	}
+	return authenticator(r.Context(), nodeID, token)
+}
+
+// KickNode 断开节点的所有连接，多实例模式下由持有该节点连接的实例断开
+func (s *WebSocket) KickNode(nodeID string) {
+	s.connectionMux.RLock()
+	cluster := s.cluster
+	s.connectionMux.RUnlock()
+
+	if cluster != nil {
+		err := cluster.publishKick(nodeID)
+		if err == nil {
+			return
+		}
+		log.Errorf("踢出节点消息发布到Redis失败，仅断开本实例连接: %v", err)
+	}
+	s.kickLocal(nodeID)
+}
+
+// kickLocal 断开本实例上节点的所有连接，连接的读循环退出后完成注销
+func (s *WebSocket) kickLocal(nodeID string) {
+	s.connectionMux.RLock()
+	conns := append([]*ConnInfo{}, s.nodeConnections[nodeID]...)
+	s.connectionMux.RUnlock()
+
+	closeNodeConns(conns, "kicked by admin")
+	if len(conns) > 0 {
+		log.Infof("节点 %s 已被踢出，断开连接数: %d", nodeID, len(conns))
+	}
+}
+
+// replaceLocal 节点已在其他实例重新连接，断开本实例上的旧连接
+func (s *WebSocket) replaceLocal(nodeID string) {
+	s.connectionMux.RLock()
+	conns := append([]*ConnInfo{}, s.nodeConnections[nodeID]...)
+	s.connectionMux.RUnlock()
+
+	closeNodeConns(conns, "replaced by new connection")
+	if len(conns) > 0 {
+		log.Infof("节点 %s 已在其他实例重新连接，断开旧连接数: %d", nodeID, len(conns))
+	}
+}
+
+// closeNodeConns 以策略违规关闭节点连接
+func closeNodeConns(conns []*ConnInfo, reason string) {
+	for _, ci := range conns {
+		ci.mu.Lock()
+		closeWithReason(ci.Conn, websocket.ClosePolicyViolation, reason)
+		ci.mu.Unlock()
+	}
+}
+
+// closeWithReason 发送关闭帧后关闭连接
+func closeWithReason(conn *websocket.Conn, code int, reason string) {
+	deadline := time.Now().Add(time.Second)
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+	conn.Close()
+}
+
+// OnNodeOffline 注册节点下线回调，节点的所有连接都断开后调用
+func (s *WebSocket) OnNodeOffline(fn func(nodeID string)) {
+	s.handlerMux.Lock()
+	defer s.handlerMux.Unlock()
+	s.nodeOfflineHandlers = append(s.nodeOfflineHandlers, fn)
+}
+
+// notifyNodeOffline 通知节点下线，节点仍连接在其他实例上时不通知
+func (s *WebSocket) notifyNodeOffline(nodeID string) {
+	if s.connectedElsewhere(nodeID) {
+		log.Infof("节点 %s 仍连接在其他实例上，跳过下线处理", nodeID)
+		return
+	}
+
+	s.handlerMux.RLock()
+	handlers := append([]func(string){}, s.nodeOfflineHandlers...)
+	s.handlerMux.RUnlock()
+
+	for _, fn := range handlers {
+		fn(nodeID)
 	}
 }
 
-// 注册新的Node服务客户端连接
-func (s *WebSocket) registerNodeClient(conn *websocket.Conn, nodeID string) *ConnInfo {
+// 注册新的Node服务客户端连接，同一节点的旧连接被关闭替换，多实例模式下通知其他实例断开该节点
+func (s *WebSocket) registerNodeClient(conn *websocket.Conn, nodeID, remoteAddr string) *ConnInfo {
 	s.connectionMux.Lock()
-	defer s.connectionMux.Unlock()
+	// 旧连接先从注册表移除，其读循环退出后注销时不会触发下线通知
+	replaced := s.nodeConnections[nodeID]
+	cluster := s.cluster
 
 	now := time.Now()
 	connInfo := &ConnInfo{
 		Conn:             conn,
 		ID:               nodeID,
+		RemoteAddr:       remoteAddr,
 		ConnTime:         now,
 		LastPongTime:     now,
 		MissedHeartbeats: 0,
 	}
 
-	s.nodeConnections[nodeID] = append(s.nodeConnections[nodeID], connInfo)
+	s.nodeConnections[nodeID] = []*ConnInfo{connInfo}
+	s.connectionMux.Unlock()
+
+	log.Infof("新节点连接: %s, 地址: %s", nodeID, remoteAddr)
 
-	log.Infof("新节点连接: %s, 连接总数: %d", nodeID, len(s.nodeConnections[nodeID]))
+	closeNodeConns(replaced, "replaced by new connection")
+	if len(replaced) > 0 {
+		log.Infof("节点 %s 重新连接，已替换旧连接数: %d", nodeID, len(replaced))
+	}
 
+	// 先同步本实例的连接信息，其他实例断开旧连接时不会误判节点下线
+	s.syncClusterNodes()
+	if cluster != nil {
+		if err := cluster.publishReplace(nodeID); err != nil {
+			log.Errorf("节点重连消息发布到Redis失败: %v", err)
+		}
+	}
 	return connInfo
 }
 
 // 注销节点客户端连接
 func (s *WebSocket) unregisterNodeClient(nodeID string, conn *websocket.Conn) {
+	defer s.syncClusterNodes()
 	s.connectionMux.Lock()
 	defer s.connectionMux.Unlock()
 
@@ -273,66 +465,84 @@ func (s *WebSocket) unregisterNodeClient(nodeID string, conn *websocket.Conn) {
 			if len(s.nodeConnections[nodeID]) == 0 {
 				delete(s.nodeConnections, nodeID)
 				log.Infof("节点 %s 所有连接已关闭", nodeID)
+				go s.notifyNodeOffline(nodeID)
 			}
 			break
 		}
 	}
 }
 
-// 广播消息给所有节点服务
+// 广播消息给所有节点服务，多实例模式下经Redis转发给所有实例
 func (s *WebSocket) BroadcastToAllNodes(message WebSocketMessage) {
 	msgBytes, err := json.Marshal(message)
 	if err != nil {
 		log.Errorf("节点广播消息序列化失败: %v", err)
 		return
 	}
-
-	s.connectionMux.RLock()
-	defer s.connectionMux.RUnlock()
-
-	// 广播给所有节点
-	for nodeID, conns := range s.nodeConnections {
-		for _, ci := range conns {
-			err := ci.Conn.WriteMessage(websocket.TextMessage, msgBytes)
-			if err != nil {
-				log.Errorf("向节点 %s 广播消息失败: %v", nodeID, err)
-				// 错误处理在心跳检测中进行
-			}
-			fmt.Println("广播消息给节点", string(msgBytes), nodeID)
-		}
-	}
+	s.dispatch("", msgBytes)
 }
 
-// 广播消息给特定节点的所有连接
+// 广播消息给特定节点的所有连接，多实例模式下由持有该节点连接的实例投递
 func (s *WebSocket) BroadcastToNode(nodeID string, message WebSocketMessage) {
 	msgBytes, err := json.Marshal(message)
 	if err != nil {
 		log.Errorf("节点消息序列化失败: %v", err)
 		return
 	}
+	s.dispatch(nodeID, msgBytes)
+}
 
+// dispatch 发送节点消息，多实例模式下发布到Redis，发布失败时退回为仅投递本地连接
+func (s *WebSocket) dispatch(nodeID string, msgBytes []byte) {
 	s.connectionMux.RLock()
-	defer s.connectionMux.RUnlock()
+	cluster := s.cluster
+	s.connectionMux.RUnlock()
 
-	conns, exists := s.nodeConnections[nodeID]
-	if !exists {
+	if cluster != nil {
+		err := cluster.publish(nodeID, msgBytes)
+		if err == nil {
+			return
+		}
+		log.Errorf("节点消息发布到Redis失败，仅投递本实例连接: %v", err)
+	}
+
+	if !s.deliverLocal(nodeID, msgBytes) && nodeID != "" {
 		log.Warnf("节点 %s 没有活跃连接", nodeID)
-		return
 	}
+}
 
-	for _, ci := range conns {
-		err := ci.Conn.WriteMessage(websocket.TextMessage, msgBytes)
-		if err != nil {
-			log.Errorf("向节点 %s 发送消息失败: %v", nodeID, err)
-			// 错误处理在心跳检测中进行
+// deliverLocal 将消息写入本实例持有的节点连接，nodeID为空时写入所有节点，返回是否存在目标连接
+func (s *WebSocket) deliverLocal(nodeID string, msgBytes []byte) bool {
+	s.connectionMux.RLock()
+	defer s.connectionMux.RUnlock()
+
+	targets := s.nodeConnections
+	if nodeID != "" {
+		conns, exists := s.nodeConnections[nodeID]
+		if !exists {
+			return false
+		}
+		targets = map[string][]*ConnInfo{nodeID: conns}
+	}
+
+	for id, conns := range targets {
+		for _, ci := range conns {
+			ci.mu.Lock()
+			err := ci.Conn.WriteMessage(websocket.TextMessage, msgBytes)
+			ci.mu.Unlock()
+			if err != nil {
+				log.Errorf("向节点 %s 发送消息失败: %v", id, err)
+				// 错误处理在心跳检测中进行
+			}
 		}
 	}
+	return len(targets) > 0
 }
 
 // handlePing 处理ping消息
-func (s *WebSocket) handlePing(conn *websocket.Conn) error {
+func (s *WebSocket) handlePing(ci *ConnInfo) error {
 	// 获取远程地址
-	remoteAddr := conn.RemoteAddr().String()
+	remoteAddr := ci.Conn.RemoteAddr().String()
 
 	response := WebSocketMessage{
 		Type: PongMessageType,
@@ -347,42 +557,78 @@ func (s *WebSocket) handlePing(conn *websocket.Conn) error {
 	if err != nil {
 		return err
 	}
-	// todo fy 更新注册器中uuid的时间戳，超过10min没有更新，则删除
-	return conn.WriteMessage(websocket.TextMessage, msgBytes)
+	ci.markAlive()
+
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	return ci.Conn.WriteMessage(websocket.TextMessage, msgBytes)
 }
 
-// 获取当前节点连接总数
-func (s *WebSocket) GetTotalConnections() int {
+// NodeInfo 节点连接信息
+type NodeInfo struct {
+	NodeID      string    `json:"node_id"`
+	InstanceID  string    `json:"instance_id,omitempty"` // 持有连接的管理端实例，单实例部署时为空
+	RemoteAddr  string    `json:"remote_addr"`
+	Connections int       `json:"connections"`
+	ConnectedAt time.Time `json:"connected_at"`
+
+	LastPongAt       time.Time `json:"last_pong_at"`      // 最后一次心跳响应时间
+	MissedHeartbeats int       `json:"missed_heartbeats"` // 当前未响应的心跳次数
+}
+
+// ListNodes 获取已连接的节点，多实例模式下包含所有实例
+func (s *WebSocket) ListNodes() []*NodeInfo {
+	if nodes := s.clusterNodes(); nodes != nil {
+		return nodes
+	}
+	return s.localNodes()
+}
+
+// localNodes 获取本实例持有的节点连接
+func (s *WebSocket) localNodes() []*NodeInfo {
 	s.connectionMux.RLock()
 	defer s.connectionMux.RUnlock()
 
-	total := 0
-
-	// 统计节点连接
-	for _, conns := range s.nodeConnections {
-		total += len(conns)
+	nodes := make([]*NodeInfo, 0, len(s.nodeConnections))
+	for nodeID, conns := range s.nodeConnections {
+		if len(conns) == 0 {
+			continue
+		}
+		ci := conns[0]
+		ci.mu.Lock()
+		nodes = append(nodes, &NodeInfo{
+			NodeID:           nodeID,
+			RemoteAddr:       ci.RemoteAddr,
+			Connections:      len(conns),
+			ConnectedAt:      ci.ConnTime,
+			LastPongAt:       ci.LastPongTime,
+			MissedHeartbeats: ci.MissedHeartbeats,
+		})
+		ci.mu.Unlock()
 	}
+	return nodes
+}
 
+// 获取当前节点连接总数，多实例模式下统计所有实例
+func (s *WebSocket) GetTotalConnections() int {
+	total := 0
+	for _, node := range s.ListNodes() {
+		total += node.Connections
+	}
 	return total
 }
 
-// 获取当前活跃节点数
+// 获取当前活跃节点数，多实例模式下统计所有实例
 func (s *WebSocket) GetActiveNodeCount() int {
-	s.connectionMux.RLock()
-	defer s.connectionMux.RUnlock()
-
-	return len(s.nodeConnections)
+	return len(s.GetActiveNodeIDs())
 }
 
-// 获取所有活跃节点ID
+// 获取所有活跃节点ID，多实例模式下包含其他实例上的节点
 func (s *WebSocket) GetActiveNodeIDs() []string {
-	s.connectionMux.RLock()
-	defer s.connectionMux.RUnlock()
-
-	nodeIDs := make([]string, 0, len(s.nodeConnections))
-	for nodeID := range s.nodeConnections {
-		nodeIDs = append(nodeIDs, nodeID)
+	nodes := s.ListNodes()
+	nodeIDs := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		nodeIDs = append(nodeIDs, node.NodeID)
 	}
-
-	return nodeIDs
+	return Uniq(nodeIDs)
 }
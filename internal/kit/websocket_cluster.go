@@ -0,0 +1,246 @@
+package kit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kratos/kratos/v2/log"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// wsInstanceTTL 实例连接信息的过期时间，实例在每次心跳检测时续期
+const wsInstanceTTL = HeartbeatInterval * MaxMissedHeartbeats
+
+// wsCluster 多实例部署时通过Redis共享节点连接信息并转发广播消息
+type wsCluster struct {
+	ctx        context.Context
+	redis      *RedisClient
+	instanceID string
+}
+
+// wsClusterActionKick 踢出节点，持有连接的实例断开该节点
+const wsClusterActionKick = "kick"
+
+// wsClusterActionReplace 节点已重新连接到发布消息的实例，其他实例断开该节点的旧连接
+const wsClusterActionReplace = "replace"
+
+// wsClusterEnvelope 经Redis转发的节点消息
+type wsClusterEnvelope struct {
+	Origin  string          `json:"origin"`            // 发布消息的实例ID
+	NodeID  string          `json:"node_id,omitempty"` // 目标节点ID，为空时广播给所有节点
+	Action  string          `json:"action,omitempty"`  // 为空时投递消息
+	Message json.RawMessage `json:"message,omitempty"`
+}
+
+// EnableCluster 启用多实例模式，广播消息经Redis发布后由持有连接的实例投递，需在InitWebSocket之前调用
+func (s *WebSocket) EnableCluster(ctx context.Context, redisClient *RedisClient) {
+	hostname, _ := os.Hostname()
+	cluster := &wsCluster{
+		ctx:        ctx,
+		redis:      redisClient,
+		instanceID: fmt.Sprintf("%s-%s", hostname, strings.ReplaceAll(uuid.New().String(), "-", "")[:8]),
+	}
+
+	s.connectionMux.Lock()
+	s.cluster = cluster
+	s.connectionMux.Unlock()
+
+	s.syncClusterNodes()
+	go s.subscribeCluster(cluster)
+	log.Infof("WebSocket多实例模式已启用, 实例ID: %s", cluster.instanceID)
+}
+
+// subscribeCluster 订阅广播频道，将消息投递给本实例持有的节点连接
+func (s *WebSocket) subscribeCluster(cluster *wsCluster) {
+	pubsub := cluster.redis.GetClient().Subscribe(cluster.ctx, RedisChannelWebSocketBroadcast)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-cluster.ctx.Done():
+			return
+		case <-s.done:
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var envelope wsClusterEnvelope
+			if err := json.Unmarshal([]byte(msg.Payload), &envelope); err != nil {
+				log.Errorf("节点广播消息解析失败: %v", err)
+				continue
+			}
+			if envelope.Action == wsClusterActionKick {
+				s.kickLocal(envelope.NodeID)
+				continue
+			}
+			if envelope.Action == wsClusterActionReplace {
+				if envelope.Origin != cluster.instanceID {
+					s.replaceLocal(envelope.NodeID)
+				}
+				continue
+			}
+			s.deliverLocal(envelope.NodeID, envelope.Message)
+		}
+	}
+}
+
+// publish 发布节点消息到所有实例，nodeID为空时广播给所有节点
+func (c *wsCluster) publish(nodeID string, msgBytes []byte) error {
+	return c.publishEnvelope(&wsClusterEnvelope{
+		Origin:  c.instanceID,
+		NodeID:  nodeID,
+		Message: msgBytes,
+	})
+}
+
+// publishKick 通知所有实例断开节点连接
+func (c *wsCluster) publishKick(nodeID string) error {
+	return c.publishEnvelope(&wsClusterEnvelope{
+		Origin: c.instanceID,
+		NodeID: nodeID,
+		Action: wsClusterActionKick,
+	})
+}
+
+// publishReplace 通知其他实例节点已连接到本实例
+func (c *wsCluster) publishReplace(nodeID string) error {
+	return c.publishEnvelope(&wsClusterEnvelope{
+		Origin: c.instanceID,
+		NodeID: nodeID,
+		Action: wsClusterActionReplace,
+	})
+}
+
+func (c *wsCluster) publishEnvelope(envelope *wsClusterEnvelope) error {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	return c.redis.GetClient().Publish(c.ctx, RedisChannelWebSocketBroadcast, data).Err()
+}
+
+// syncClusterNodes 将本实例的节点连接信息写入Redis并续期
+func (s *WebSocket) syncClusterNodes() {
+	s.connectionMux.RLock()
+	cluster := s.cluster
+	s.connectionMux.RUnlock()
+	if cluster == nil {
+		return
+	}
+
+	nodes := make(map[string]interface{})
+	for _, node := range s.localNodes() {
+		node.InstanceID = cluster.instanceID
+		data, err := json.Marshal(node)
+		if err != nil {
+			continue
+		}
+		nodes[node.NodeID] = string(data)
+	}
+
+	key := GetWebSocketInstanceNodesKey(cluster.instanceID)
+	_, err := cluster.redis.GetClient().TxPipelined(cluster.ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(cluster.ctx, key)
+		if len(nodes) > 0 {
+			pipe.HSet(cluster.ctx, key, nodes)
+			pipe.Expire(cluster.ctx, key, wsInstanceTTL)
+		}
+		pipe.ZAdd(cluster.ctx, RedisKeyWebSocketInstances, redis.Z{
+			Score:  float64(time.Now().Unix()),
+			Member: cluster.instanceID,
+		})
+		return nil
+	})
+	if err != nil {
+		log.Errorf("同步节点连接信息失败: %v", err)
+	}
+}
+
+// leave 移除本实例的节点连接信息
+func (c *wsCluster) leave() {
+	ctx := context.Background()
+	client := c.redis.GetClient()
+	client.Del(ctx, GetWebSocketInstanceNodesKey(c.instanceID))
+	client.ZRem(ctx, RedisKeyWebSocketInstances, c.instanceID)
+}
+
+// listNodes 查询所有存活实例上的节点连接，excludeSelf为true时不包含本实例
+func (c *wsCluster) listNodes(excludeSelf bool) ([]*NodeInfo, error) {
+	client := c.redis.GetClient()
+
+	// 清理过期未续期的实例
+	expiredBefore := strconv.FormatInt(time.Now().Add(-wsInstanceTTL).Unix(), 10)
+	if err := client.ZRemRangeByScore(c.ctx, RedisKeyWebSocketInstances, "-inf", expiredBefore).Err(); err != nil {
+		return nil, err
+	}
+	instanceIDs, err := client.ZRange(c.ctx, RedisKeyWebSocketInstances, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*NodeInfo, 0)
+	for _, instanceID := range instanceIDs {
+		if excludeSelf && instanceID == c.instanceID {
+			continue
+		}
+		values, err := client.HGetAll(c.ctx, GetWebSocketInstanceNodesKey(instanceID)).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			var node NodeInfo
+			if err := json.Unmarshal([]byte(value), &node); err != nil {
+				continue
+			}
+			result = append(result, &node)
+		}
+	}
+	return result, nil
+}
+
+// clusterNodes 返回所有实例上的节点连接，未启用多实例或查询失败时返回nil
+func (s *WebSocket) clusterNodes() []*NodeInfo {
+	s.connectionMux.RLock()
+	cluster := s.cluster
+	s.connectionMux.RUnlock()
+	if cluster == nil {
+		return nil
+	}
+
+	nodes, err := cluster.listNodes(false)
+	if err != nil {
+		log.Errorf("查询集群节点连接失败: %v", err)
+		return nil
+	}
+	return nodes
+}
+
+// connectedElsewhere 判断节点是否仍连接在其他实例上
+func (s *WebSocket) connectedElsewhere(nodeID string) bool {
+	s.connectionMux.RLock()
+	cluster := s.cluster
+	s.connectionMux.RUnlock()
+	if cluster == nil {
+		return false
+	}
+
+	nodes, err := cluster.listNodes(true)
+	if err != nil {
+		log.Errorf("查询集群节点连接失败: %v", err)
+		return false
+	}
+	for _, node := range nodes {
+		if node.NodeID == nodeID {
+			return true
+		}
+	}
+	return false
+}
@@ -23,15 +23,19 @@ const (
 	UserIDKey contextKey = "user_id"
 	// UserDetailKey Context中存储用户详情的key
 	UserDetailKey contextKey = "user_detail"
+	// ServerSecretKey Context中标记请求通过server.secret认证的key
+	ServerSecretKey contextKey = "server_secret"
 )
 
 // UserDetail 用户详情（对应Java的UserDetail）
 type UserDetail struct {
-	ID         int64  `json:"id"`
-	Username   string `json:"username"`
-	SuperAdmin int32  `json:"super_admin"`
-	Status     int32  `json:"status"`
-	Token      string `json:"token"`
+	ID          int64    `json:"id"`
+	Username    string   `json:"username"`
+	SuperAdmin  int32    `json:"super_admin"`
+	Status      int32    `json:"status"`
+	Token       string   `json:"token"`
+	Roles       []string `json:"roles"`       // 角色编码
+	Permissions []string `json:"permissions"` // 角色合并后的权限
 }
 
 // TokenService Token服务接口
@@ -59,6 +63,7 @@ func isPathAllowed(path string) bool {
 		"/user/captcha",                 // 验证码
 		"/user/smsVerification",         // 短信验证
 		"/user/retrieve-password",       // 找回密码
+		"/user/oidc/",                   // 单点登录
 		"/agent/chat-history/report",    // 聊天上报
 		"/agent/chat-history/download/", // 聊天记录下载
 		"/agent/play/",                  // 智能体播放
@@ -67,7 +72,6 @@ func isPathAllowed(path string) bool {
 		"/otaMag/download/",             // OTA下载
 		"/webjars/",                     // WebJars资源
 		"/druid/",                       // Druid监控
-		"/ws",                           // WebSocket
 	}
 
 	// 检查路径是否匹配白名单
@@ -91,6 +95,7 @@ func AuthMiddleware(tokenService TokenService, serverSecretService ServerSecretS
 			if !ok {
 				return handler(ctx, req)
 			}
+			ctx = WithClientInfo(ctx, ClientInfoFromRequest(httpReq))
 
 			// 对OPTIONS请求放行（CORS预检请求）
 			if httpReq.Method == http.MethodOptions {
@@ -132,6 +137,7 @@ func AuthMiddleware(tokenService TokenService, serverSecretService ServerSecretS
 				serverSecret, err := serverSecretService.GetServerSecret(ctx)
 				if err == nil && serverSecret != "" && serverSecret == token {
 					// server.secret验证成功，允许通过（不设置用户信息，因为这是服务器级别的认证）
+					ctx = context.WithValue(ctx, ServerSecretKey, true)
 					return handler(ctx, req)
 				}
 			}
@@ -185,6 +191,12 @@ func GetUserIdFromContext(ctx context.Context) (int64, error) {
 	return user.ID, nil
 }
 
+// IsServerSecretRequest 请求是否通过server.secret认证（来自语音服务等服务器端调用）
+func IsServerSecretRequest(ctx context.Context) bool {
+	ok, _ := ctx.Value(ServerSecretKey).(bool)
+	return ok
+}
+
 // GetTokenFromContext 从Context获取Token（对应Java的SecurityUser.getToken()）
 func GetTokenFromContext(ctx context.Context) (string, error) {
 	user, err := GetUserFromContext(ctx)
@@ -194,10 +206,7 @@ func GetTokenFromContext(ctx context.Context) (string, error) {
 	return user.Token, nil
 }
 
+// IsSuperAdmin 是否拥有全部权限（超级管理员或admin角色），用于不按用户过滤数据的场景
 func IsSuperAdmin(ctx context.Context) bool {
-	user, err := GetUserFromContext(ctx)
-	if err != nil {
-		return false
-	}
-	return user.SuperAdmin == 1
+	return HasPermission(ctx, PermissionAll)
 }
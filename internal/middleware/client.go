@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// ClientFingerprintHeader 客户端指纹请求头，未携带时使用User-Agent作为指纹
+	ClientFingerprintHeader = "X-Client-Fingerprint"
+
+	// ClientInfoKey Context中存储客户端信息的key
+	ClientInfoKey contextKey = "client_info"
+)
+
+// trustedProxies 受信任的反向代理网段，仅来自这些地址的请求采用代理设置的客户端IP请求头
+var trustedProxies atomic.Pointer[[]*net.IPNet]
+
+// SetTrustedProxies 设置受信任的反向代理，支持IP和CIDR
+func SetTrustedProxies(proxies []string) error {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return fmt.Errorf("无效的代理地址: %s", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return fmt.Errorf("无效的代理地址: %s", proxy)
+		}
+		nets = append(nets, ipNet)
+	}
+	trustedProxies.Store(&nets)
+	return nil
+}
+
+// isTrustedProxy 判断连接地址是否为受信任的反向代理
+func isTrustedProxy(addr string) bool {
+	nets := trustedProxies.Load()
+	ip := net.ParseIP(addr)
+	if nets == nil || ip == nil {
+		return false
+	}
+	for _, ipNet := range *nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientInfo 发起请求的客户端信息，用于绑定和展示登录会话
+type ClientInfo struct {
+	IP          string
+	UserAgent   string
+	Fingerprint string
+}
+
+// Hash 客户端指纹的SHA-256摘要，会话token与其绑定
+func (c *ClientInfo) Hash() string {
+	if c == nil || c.Fingerprint == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(c.Fingerprint))
+	return hex.EncodeToString(sum[:])
+}
+
+// ClientInfoFromRequest 从HTTP请求中提取客户端信息
+// 连接来自受信任的反向代理时IP取代理设置的请求头，否则取连接地址，避免客户端伪造请求头
+func ClientInfoFromRequest(req *http.Request) *ClientInfo {
+	info := &ClientInfo{
+		UserAgent:   req.UserAgent(),
+		Fingerprint: strings.TrimSpace(req.Header.Get(ClientFingerprintHeader)),
+	}
+	if info.Fingerprint == "" {
+		info.Fingerprint = info.UserAgent
+	}
+
+	remote := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if isTrustedProxy(remote) {
+		if forwarded := req.Header.Get("X-Forwarded-For"); forwarded != "" {
+			info.IP = forwardedClientIP(remote, forwarded)
+		} else {
+			info.IP = strings.TrimSpace(req.Header.Get("X-Real-IP"))
+		}
+	}
+	if info.IP == "" {
+		info.IP = remote
+	}
+	return info
+}
+
+// forwardedClientIP 从右向左跳过受信任代理追加的地址，第一个非代理地址即客户端IP
+// 更左侧的地址由客户端自行携带，可以任意伪造，不予采信；遇到无效地址时取最近一个有效的地址
+func forwardedClientIP(remote, forwarded string) string {
+	client := remote
+	hops := strings.Split(forwarded, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		client = hop
+		if !isTrustedProxy(hop) {
+			break
+		}
+	}
+	return client
+}
+
+// WithClientInfo 将客户端信息存储到Context中
+func WithClientInfo(ctx context.Context, info *ClientInfo) context.Context {
+	return context.WithValue(ctx, ClientInfoKey, info)
+}
+
+// GetClientInfo 从Context获取客户端信息，非HTTP请求时返回空信息
+func GetClientInfo(ctx context.Context) *ClientInfo {
+	if info, ok := ctx.Value(ClientInfoKey).(*ClientInfo); ok && info != nil {
+		return info
+	}
+	return &ClientInfo{}
+}
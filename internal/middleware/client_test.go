@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientInfoFromRequest(t *testing.T) {
+	if err := SetTrustedProxies([]string{"10.1.2.3", "192.168.0.0/16", "::1"}); err != nil {
+		t.Fatalf("SetTrustedProxies() error = %v", err)
+	}
+	t.Cleanup(func() { _ = SetTrustedProxies(nil) })
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{name: "直连", remoteAddr: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "非代理伪造X-Forwarded-For", remoteAddr: "203.0.113.7:5000", headers: map[string]string{"X-Forwarded-For": "1.2.3.4"}, want: "203.0.113.7"},
+		{name: "非代理伪造X-Real-IP", remoteAddr: "203.0.113.7:5000", headers: map[string]string{"X-Real-IP": "1.2.3.4"}, want: "203.0.113.7"},
+		{name: "受信任代理", remoteAddr: "10.1.2.3:5000", headers: map[string]string{"X-Forwarded-For": "198.51.100.1, 10.1.2.4"}, want: "10.1.2.4"},
+		{name: "多级受信任代理", remoteAddr: "10.1.2.3:5000", headers: map[string]string{"X-Forwarded-For": "198.51.100.1, 192.168.1.5"}, want: "198.51.100.1"},
+		{name: "忽略伪造的最左侧地址", remoteAddr: "10.1.2.3:5000", headers: map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1"}, want: "198.51.100.1"},
+		{name: "忽略无效地址", remoteAddr: "10.1.2.3:5000", headers: map[string]string{"X-Forwarded-For": "unknown, 192.168.1.5"}, want: "192.168.1.5"},
+		{name: "全部为受信任代理", remoteAddr: "10.1.2.3:5000", headers: map[string]string{"X-Forwarded-For": "192.168.1.6, 192.168.1.5"}, want: "192.168.1.6"},
+		{name: "受信任代理X-Real-IP", remoteAddr: "[::1]:5000", headers: map[string]string{"X-Real-IP": "198.51.100.2"}, want: "198.51.100.2"},
+		{name: "受信任代理未携带请求头", remoteAddr: "10.1.2.3:5000", want: "10.1.2.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/user/login", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if got := ClientInfoFromRequest(req).IP; got != tt.want {
+				t.Errorf("ClientInfoFromRequest().IP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if err := SetTrustedProxies([]string{"not-an-ip"}); err == nil {
+		t.Error("SetTrustedProxies() 应拒绝无效地址")
+	}
+}
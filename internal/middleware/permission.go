@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/middleware"
+	"github.com/go-kratos/kratos/v2/transport"
+)
+
+// 权限编码，格式为 资源:操作，"资源:*" 表示该资源的全部操作，"*" 表示全部权限
+const (
+	PermissionAll = "*"
+
+	// PermissionPublic 公开接口，路径在AuthMiddleware白名单中，不校验登录
+	PermissionPublic = "public"
+	// PermissionAuthenticated 只要求登录或server.secret认证，不要求角色权限
+	PermissionAuthenticated = "authenticated"
+
+	PermissionModelRead     = "model:read"
+	PermissionModelWrite    = "model:write"
+	PermissionTemplateRead  = "template:read"
+	PermissionTemplateWrite = "template:write"
+	PermissionVoiceRead     = "voice:read"
+	PermissionVoiceWrite    = "voice:write"
+	PermissionOTARead       = "ota:read"
+	PermissionOTAWrite      = "ota:write"
+	PermissionDictRead      = "dict:read"
+	PermissionDictWrite     = "dict:write"
+	PermissionParamsRead    = "params:read"
+	PermissionParamsWrite   = "params:write"
+	PermissionUserRead      = "user:read"
+	PermissionUserWrite     = "user:write"
+	PermissionDeviceRead    = "device:read"
+	PermissionAgentRead     = "agent:read"
+	PermissionServerRead    = "server:read"
+	PermissionServerWrite   = "server:write"
+	PermissionRoleRead      = "role:read"
+	PermissionRoleWrite     = "role:write"
+)
+
+// PermissionInfo 权限说明
+type PermissionInfo struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// Permissions 可分配给角色的全部权限
+var Permissions = []PermissionInfo{
+	{Code: PermissionModelRead, Name: "查看模型配置、供应器和音色"},
+	{Code: PermissionModelWrite, Name: "管理模型配置、供应器和音色"},
+	{Code: PermissionTemplateRead, Name: "查看智能体模板"},
+	{Code: PermissionTemplateWrite, Name: "管理智能体模板"},
+	{Code: PermissionVoiceRead, Name: "查看音色资源"},
+	{Code: PermissionVoiceWrite, Name: "管理音色资源"},
+	{Code: PermissionOTARead, Name: "查看固件、灰度发布和签名密钥"},
+	{Code: PermissionOTAWrite, Name: "管理固件、灰度发布和签名密钥"},
+	{Code: PermissionDictRead, Name: "查看字典"},
+	{Code: PermissionDictWrite, Name: "管理字典"},
+	{Code: PermissionParamsRead, Name: "查看系统参数"},
+	{Code: PermissionParamsWrite, Name: "管理系统参数"},
+	{Code: PermissionUserRead, Name: "查看用户"},
+	{Code: PermissionUserWrite, Name: "管理用户（状态、密码、删除、登录锁定）"},
+	{Code: PermissionDeviceRead, Name: "查看全部设备"},
+	{Code: PermissionAgentRead, Name: "查看全部智能体"},
+	{Code: PermissionServerRead, Name: "查看服务端配置、语音服务节点和API Key"},
+	{Code: PermissionServerWrite, Name: "操作服务端和语音服务节点，创建API Key"},
+	{Code: PermissionRoleRead, Name: "查看角色和用户角色"},
+	{Code: PermissionRoleWrite, Name: "管理角色和分配用户角色"},
+}
+
+// IsValidPermission 权限编码是否有效，支持"*"和"资源:*"
+func IsValidPermission(permission string) bool {
+	if permission == PermissionAll {
+		return true
+	}
+	resource, action, ok := strings.Cut(permission, ":")
+	for _, p := range Permissions {
+		if p.Code == permission {
+			return true
+		}
+		if ok && action == "*" && strings.HasPrefix(p.Code, resource+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchPermission granted中是否包含required，支持"*"和"资源:*"
+func MatchPermission(granted []string, required string) bool {
+	resource, _, _ := strings.Cut(required, ":")
+	for _, p := range granted {
+		if p == PermissionAll || p == required || p == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission 用户是否拥有指定权限
+func (u *UserDetail) HasPermission(permission string) bool {
+	return MatchPermission(u.Permissions, permission)
+}
+
+// HasPermission 当前登录用户是否拥有指定权限
+func HasPermission(ctx context.Context, permission string) bool {
+	user, err := GetUserFromContext(ctx)
+	if err != nil {
+		return false
+	}
+	return user.HasPermission(permission)
+}
+
+// PermissionMiddleware 按RPC操作名校验权限，operations中未列出的操作直接拒绝
+// 需放在AuthMiddleware之后，通过server.secret认证的服务器端调用不做校验
+func PermissionMiddleware(operations map[string]string) middleware.Middleware {
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			tr, ok := transport.FromServerContext(ctx)
+			if !ok {
+				return handler(ctx, req)
+			}
+			// 未配置的接口一律拒绝，新增接口必须在operations中声明所需权限
+			required, ok := operations[tr.Operation()]
+			if !ok {
+				return nil, errors.Forbidden("FORBIDDEN", "接口未配置权限")
+			}
+			if required == PermissionPublic || IsServerSecretRequest(ctx) {
+				return handler(ctx, req)
+			}
+
+			user, err := GetUserFromContext(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if required != PermissionAuthenticated && !user.HasPermission(required) {
+				return nil, errors.Forbidden("FORBIDDEN", "无权限操作")
+			}
+			return handler(ctx, req)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kratos/kratos/v2/errors"
+	"github.com/go-kratos/kratos/v2/transport"
+)
+
+func TestPermissionMiddleware(t *testing.T) {
+	operations := map[string]string{
+		"/v1.UserService/Login":       PermissionPublic,
+		"/v1.UserService/GetUserInfo": PermissionAuthenticated,
+		"/v1.AdminService/DeleteUser": PermissionUserWrite,
+	}
+	viewer := &UserDetail{ID: 2, Permissions: []string{PermissionUserRead}}
+	admin := &UserDetail{ID: 1, Permissions: []string{PermissionAll}}
+
+	tests := []struct {
+		name         string
+		operation    string
+		user         *UserDetail
+		serverSecret bool
+		wantCode     int // 0表示放行
+	}{
+		{name: "未配置的接口拒绝", operation: "/v1.AdminService/Unknown", user: admin, wantCode: 403},
+		{name: "未配置的接口拒绝server.secret", operation: "/v1.AdminService/Unknown", serverSecret: true, wantCode: 403},
+		{name: "公开接口无需登录", operation: "/v1.UserService/Login"},
+		{name: "只要求登录", operation: "/v1.UserService/GetUserInfo", user: viewer},
+		{name: "只要求登录但未登录", operation: "/v1.UserService/GetUserInfo", wantCode: 401},
+		{name: "缺少权限", operation: "/v1.AdminService/DeleteUser", user: viewer, wantCode: 403},
+		{name: "拥有权限", operation: "/v1.AdminService/DeleteUser", user: admin},
+		{name: "server.secret调用", operation: "/v1.AdminService/DeleteUser", serverSecret: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := transport.NewServerContext(context.Background(), &operationTransport{operation: tt.operation})
+			if tt.user != nil {
+				ctx = context.WithValue(ctx, UserDetailKey, tt.user)
+			}
+			if tt.serverSecret {
+				ctx = context.WithValue(ctx, ServerSecretKey, true)
+			}
+
+			called := false
+			handler := PermissionMiddleware(operations)(func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				return nil, nil
+			})
+			_, err := handler(ctx, nil)
+			if tt.wantCode == 0 {
+				if err != nil || !called {
+					t.Fatalf("handler() error = %v, called = %v, want pass", err, called)
+				}
+				return
+			}
+			if called || errors.FromError(err).Code != int32(tt.wantCode) {
+				t.Fatalf("handler() error = %v, called = %v, want code %d", err, called, tt.wantCode)
+			}
+		})
+	}
+}
+
+// operationTransport 只提供操作名的Transporter
+type operationTransport struct {
+	transport.Transporter
+	operation string
+}
+
+func (t *operationTransport) Operation() string {
+	return t.operation
+}
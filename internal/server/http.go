@@ -35,6 +35,10 @@ func NewHTTPServer(c *conf.Bootstrap,
 	logger log.Logger,
 ) *http.Server {
 
+	if err := middleware.SetTrustedProxies(c.Server.Http.TrustedProxies); err != nil {
+		log.NewHelper(logger).Fatalf("受信任代理配置错误: %v", err)
+	}
+
 	// 创建 ServerSecretService 适配器
 	serverSecretService := service.NewServerSecretServiceAdapter(config.GetConfigUsecase())
 
@@ -44,7 +48,8 @@ func NewHTTPServer(c *conf.Bootstrap,
 			tracing.Server(),
 			validate.Validator(),
 			logging.Server(logger),
-			middleware.AuthMiddleware(tokenService, serverSecretService), // 添加认证中间件
+			middleware.AuthMiddleware(tokenService, serverSecretService),  // 添加认证中间件
+			middleware.PermissionMiddleware(service.OperationPermissions), // 按操作名校验角色权限
 		),
 	}
 	if c.Server.Http.Network != "" {
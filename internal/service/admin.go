@@ -11,6 +11,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/weetime/agent-matrix/internal/biz"
 	"github.com/weetime/agent-matrix/internal/kit"
+	"github.com/weetime/agent-matrix/internal/kit/cerrors"
+	"github.com/weetime/agent-matrix/internal/middleware"
 	pb "github.com/weetime/agent-matrix/protos/v1"
 
 	"google.golang.org/protobuf/types/known/structpb"
@@ -20,15 +22,24 @@ type AdminService struct {
 	pb.UnimplementedAdminServiceServer
 	userUsecase   *biz.UserUsecase
 	configUsecase *biz.ConfigUsecase
+	nodeUsecase   *biz.NodeUsecase
+	deviceUsecase *biz.DeviceUsecase
+	roleUsecase   *biz.RoleUsecase
 }
 
 func NewAdminService(
 	userUsecase *biz.UserUsecase,
 	configUsecase *biz.ConfigUsecase,
+	nodeUsecase *biz.NodeUsecase,
+	deviceUsecase *biz.DeviceUsecase,
+	roleUsecase *biz.RoleUsecase,
 ) *AdminService {
 	return &AdminService{
 		userUsecase:   userUsecase,
 		configUsecase: configUsecase,
+		nodeUsecase:   nodeUsecase,
+		deviceUsecase: deviceUsecase,
+		roleUsecase:   roleUsecase,
 	}
 }
 
@@ -101,6 +112,13 @@ func (s *AdminService) PageAdminUsers(ctx context.Context, req *pb.PageAdminUser
 
 // ResetUserPassword 重置用户密码
 func (s *AdminService) ResetUserPassword(ctx context.Context, req *pb.ResetUserPasswordRequest) (*pb.Response, error) {
+	operator, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
 	userId, err := strconv.ParseInt(req.GetId(), 10, 64)
 	if err != nil {
 		return &pb.Response{
@@ -109,12 +127,9 @@ func (s *AdminService) ResetUserPassword(ctx context.Context, req *pb.ResetUserP
 		}, nil
 	}
 
-	password, err := s.userUsecase.ResetPassword(ctx, userId)
+	password, err := s.userUsecase.ResetPassword(ctx, operator, userId)
 	if err != nil {
-		return &pb.Response{
-			Code: 500,
-			Msg:  err.Error(),
-		}, nil
+		return responseFromError(err), nil
 	}
 
 	// 返回密码字符串（直接作为data字段的值，与Java实现一致）
@@ -142,6 +157,13 @@ func (s *AdminService) ResetUserPassword(ctx context.Context, req *pb.ResetUserP
 
 // DeleteUser 删除用户
 func (s *AdminService) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb.Response, error) {
+	operator, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
 	userId, err := strconv.ParseInt(req.GetId(), 10, 64)
 	if err != nil {
 		return &pb.Response{
@@ -150,12 +172,9 @@ func (s *AdminService) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest
 		}, nil
 	}
 
-	err = s.userUsecase.DeleteUserById(ctx, userId)
+	err = s.userUsecase.DeleteUserById(ctx, operator, userId)
 	if err != nil {
-		return &pb.Response{
-			Code: 500,
-			Msg:  err.Error(),
-		}, nil
+		return responseFromError(err), nil
 	}
 
 	return &pb.Response{
@@ -166,6 +185,13 @@ func (s *AdminService) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest
 
 // ChangeUserStatus 批量修改用户状态
 func (s *AdminService) ChangeUserStatus(ctx context.Context, req *pb.ChangeUserStatusRequest) (*pb.Response, error) {
+	operator, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
 	status := req.GetStatus()
 	userIds := req.GetUserIds()
 
@@ -176,10 +202,34 @@ func (s *AdminService) ChangeUserStatus(ctx context.Context, req *pb.ChangeUserS
 		}, nil
 	}
 
-	err := s.userUsecase.ChangeUserStatus(ctx, status, userIds)
+	err = s.userUsecase.ChangeUserStatus(ctx, operator, status, userIds)
+	if err != nil {
+		return responseFromError(err), nil
+	}
+
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+	}, nil
+}
+
+// UnlockLogin 解除用户名或IP的登录锁定
+func (s *AdminService) UnlockLogin(ctx context.Context, req *pb.UnlockLoginRequest) (*pb.Response, error) {
+	operator, err := middleware.GetUserIdFromContext(ctx)
 	if err != nil {
 		return &pb.Response{
-			Code: 500,
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
+
+	if err := s.userUsecase.UnlockLogin(ctx, operator, req.GetUsername(), req.GetIp()); err != nil {
+		code := int32(500)
+		if cerrors.IsInvalidInput(err) {
+			code = 400
+		}
+		return &pb.Response{
+			Code: code,
 			Msg:  err.Error(),
 		}, nil
 	}
@@ -260,6 +310,25 @@ func (s *AdminService) EmitServerAction(ctx context.Context, req *pb.EmitServerA
 		}, nil
 	}
 
+	// 配置更新通过节点长连接广播给所有节点，无需逐个连接服务端
+	if action == "update_config" {
+		s.configUsecase.ClearConfigCache(ctx)
+		dataStruct, err := structpb.NewStruct(map[string]interface{}{
+			"broadcast": true,
+		})
+		if err != nil {
+			return &pb.Response{
+				Code: 500,
+				Msg:  "构建响应数据失败: " + err.Error(),
+			}, nil
+		}
+		return &pb.Response{
+			Code: 0,
+			Msg:  "success",
+			Data: dataStruct,
+		}, nil
+	}
+
 	// 获取 server.websocket 配置
 	wsText, err := s.configUsecase.GetValue(ctx, "server.websocket", true)
 	if err != nil {
@@ -400,3 +469,112 @@ func (s *AdminService) emitServerActionByWs(ctx context.Context, targetWsUri, ac
 	// 如果收到匹配的响应，返回true
 	return len(responses) > 0, nil
 }
+
+// GetServerStatus 获取WebSocket服务端健康状态，合并server.websocket配置与节点上报的负载
+func (s *AdminService) GetServerStatus(ctx context.Context, req *pb.GetServerStatusRequest) (*pb.Response, error) {
+	healths, err := s.nodeUsecase.GetNodeHealth(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  err.Error(),
+		}, nil
+	}
+
+	list := make([]interface{}, 0, len(healths))
+	for _, health := range healths {
+		item := map[string]interface{}{
+			"address":    health.Address,
+			"configured": health.Configured,
+			"online":     health.Online,
+		}
+		if node := health.Node; node != nil {
+			item["nodeId"] = node.NodeID
+			item["instanceId"] = node.InstanceID
+			item["remoteAddr"] = node.RemoteAddr
+			item["connectedAt"] = node.ConnectedAt.Format(time.DateTime)
+			item["lastHeartbeatAt"] = node.LastPongAt.Format(time.DateTime)
+			item["missedHeartbeats"] = node.MissedHeartbeats
+		}
+		if metrics := health.Metrics; metrics != nil {
+			item["activeSessions"] = metrics.ActiveSessions
+			item["cpuPercent"] = metrics.CPUPercent
+			item["memoryPercent"] = metrics.MemoryPercent
+			item["memoryBytes"] = float64(metrics.MemoryBytes)
+			item["version"] = metrics.Version
+			item["metricsReportedAt"] = metrics.ReportedAt.Format(time.DateTime)
+		}
+		list = append(list, item)
+	}
+
+	dataStruct, err := structpb.NewStruct(map[string]interface{}{
+		"list":  list,
+		"total": len(list),
+	})
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  "构建响应数据失败: " + err.Error(),
+		}, nil
+	}
+
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+		Data: dataStruct,
+	}, nil
+}
+
+// ListNodes 获取已连接的语音服务节点
+func (s *AdminService) ListNodes(ctx context.Context, req *pb.ListNodesRequest) (*pb.Response, error) {
+	nodes := s.nodeUsecase.ListNodes(ctx)
+	list := make([]interface{}, 0, len(nodes))
+	for _, node := range nodes {
+		list = append(list, map[string]interface{}{
+			"nodeId":      node.NodeID,
+			"instanceId":  node.InstanceID,
+			"remoteAddr":  node.RemoteAddr,
+			"connections": node.Connections,
+			"connectedAt": node.ConnectedAt.Format(time.DateTime),
+		})
+	}
+
+	dataStruct, err := structpb.NewStruct(map[string]interface{}{
+		"list":  list,
+		"total": len(list),
+	})
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  "构建响应数据失败: " + err.Error(),
+		}, nil
+	}
+
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+		Data: dataStruct,
+	}, nil
+}
+
+// KickNode 断开语音服务节点的连接
+func (s *AdminService) KickNode(ctx context.Context, req *pb.KickNodeRequest) (*pb.Response, error) {
+	blockDuration := time.Duration(req.GetBlockSeconds().GetValue()) * time.Second
+	if err := s.nodeUsecase.KickNode(ctx, req.GetNodeId(), blockDuration); err != nil {
+		code := int32(500)
+		switch {
+		case cerrors.IsInvalidInput(err):
+			code = 400
+		case cerrors.IsNotFound(err):
+			code = 404
+		}
+		return &pb.Response{
+			Code: code,
+			Msg:  err.Error(),
+		}, nil
+	}
+
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+	}, nil
+}
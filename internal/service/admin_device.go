@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/kit"
+	"github.com/weetime/agent-matrix/internal/kit/cerrors"
+	pb "github.com/weetime/agent-matrix/protos/v1"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// PageAdminDevices 分页查找设备，支持按最新一次上报的遥测筛选
+func (s *AdminService) PageAdminDevices(ctx context.Context, req *pb.PageAdminDevicesRequest) (*pb.Response, error) {
+	params := &biz.ListDeviceParams{
+		Keywords:   stringValue(req.GetKeywords().GetValue()),
+		Board:      stringValue(req.GetBoard().GetValue()),
+		AppVersion: stringValue(req.GetAppVersion().GetValue()),
+		Telemetry: &biz.DeviceTelemetryFilter{
+			ChipModelName: stringValue(req.GetChipModelName().GetValue()),
+			IdfVersion:    stringValue(req.GetIdfVersion().GetValue()),
+		},
+	}
+	if req.RssiBelow != nil {
+		rssi := req.RssiBelow.GetValue()
+		params.Telemetry.RSSIBelow = &rssi
+	}
+	if req.FreeHeapBelow != nil {
+		freeHeap := req.FreeHeapBelow.GetValue()
+		params.Telemetry.FreeHeapBelow = &freeHeap
+	}
+
+	page := &kit.PageRequest{}
+	pageNo := req.GetPage()
+	if pageNo == 0 {
+		pageNo = 1
+	}
+	pageSize := req.GetLimit()
+	if pageSize == 0 {
+		pageSize = kit.DEFAULT_PAGE_ZISE
+	}
+	page.SetPageNo(int(pageNo))
+	page.SetPageSize(int(pageSize))
+
+	devices, telemetries, total, err := s.deviceUsecase.PageAdminDevices(ctx, params, page)
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  err.Error(),
+		}, nil
+	}
+
+	voList := make([]interface{}, 0, len(devices))
+	for _, device := range devices {
+		vo := adminDeviceToVO(device)
+		if telemetry, ok := telemetries[device.ID]; ok {
+			vo["telemetry"] = deviceTelemetryToVO(telemetry)
+		}
+		voList = append(voList, vo)
+	}
+
+	dataStruct, err := structpb.NewStruct(map[string]interface{}{
+		"total": int32(total),
+		"list":  voList,
+	})
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  "构建响应数据失败: " + err.Error(),
+		}, nil
+	}
+
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+		Data: dataStruct,
+	}, nil
+}
+
+// GetAdminDevice 查询设备详情及最近的遥测上报
+func (s *AdminService) GetAdminDevice(ctx context.Context, req *pb.GetAdminDeviceRequest) (*pb.Response, error) {
+	device, telemetries, err := s.deviceUsecase.GetAdminDeviceDetail(ctx, req.GetId())
+	if err != nil {
+		code := int32(500)
+		if cerrors.IsNotFound(err) {
+			code = 404
+		}
+		return &pb.Response{
+			Code: code,
+			Msg:  err.Error(),
+		}, nil
+	}
+
+	vo := adminDeviceToVO(device)
+	history := make([]interface{}, 0, len(telemetries))
+	for _, telemetry := range telemetries {
+		history = append(history, deviceTelemetryToVO(telemetry))
+	}
+	if len(telemetries) > 0 {
+		vo["telemetry"] = history[0]
+	}
+	vo["telemetryHistory"] = history
+
+	dataStruct, err := structpb.NewStruct(vo)
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  "构建响应数据失败: " + err.Error(),
+		}, nil
+	}
+
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+		Data: dataStruct,
+	}, nil
+}
+
+// adminDeviceToVO 转换为VO，ID字段格式化为字符串
+func adminDeviceToVO(device *biz.Device) map[string]interface{} {
+	vo := map[string]interface{}{
+		"id":         device.ID,
+		"userId":     fmt.Sprintf("%d", device.UserID),
+		"macAddress": device.MacAddress,
+		"autoUpdate": device.AutoUpdate,
+		"board":      device.Board,
+		"alias":      device.Alias,
+		"agentId":    device.AgentID,
+		"appVersion": device.AppVersion,
+	}
+	if device.LastConnectedAt != nil {
+		vo["lastConnectedAt"] = device.LastConnectedAt.Format(time.DateTime)
+	}
+	if !device.CreateDate.IsZero() {
+		vo["createDate"] = device.CreateDate.Format(time.DateTime)
+	}
+	return vo
+}
+
+// deviceTelemetryToVO 转换为VO，设备未上报的字段不返回
+func deviceTelemetryToVO(telemetry *biz.DeviceTelemetry) map[string]interface{} {
+	vo := map[string]interface{}{
+		"appVersion":    telemetry.AppVersion,
+		"board":         telemetry.Board,
+		"chipModelName": telemetry.ChipModelName,
+		"idfVersion":    telemetry.IdfVersion,
+		"otaLabel":      telemetry.OtaLabel,
+		"ssid":          telemetry.SSID,
+		"ip":            telemetry.IP,
+		"createdAt":     telemetry.CreatedAt.Format(time.DateTime),
+	}
+	if telemetry.FlashSize != nil {
+		vo["flashSize"] = *telemetry.FlashSize
+	}
+	if telemetry.MinimumFreeHeapSize != nil {
+		vo["minimumFreeHeapSize"] = *telemetry.MinimumFreeHeapSize
+	}
+	if telemetry.RSSI != nil {
+		vo["rssi"] = *telemetry.RSSI
+	}
+	if telemetry.Channel != nil {
+		vo["channel"] = *telemetry.Channel
+	}
+
+	partitions := make([]interface{}, 0, len(telemetry.PartitionTable))
+	for _, partition := range telemetry.PartitionTable {
+		if partition == nil {
+			continue
+		}
+		item := map[string]interface{}{}
+		if partition.Label != nil {
+			item["label"] = *partition.Label
+		}
+		if partition.Type != nil {
+			item["type"] = *partition.Type
+		}
+		if partition.Subtype != nil {
+			item["subtype"] = *partition.Subtype
+		}
+		if partition.Address != nil {
+			item["address"] = *partition.Address
+		}
+		if partition.Size != nil {
+			item["size"] = *partition.Size
+		}
+		partitions = append(partitions, item)
+	}
+	vo["partitionTable"] = partitions
+	return vo
+}
+
+// stringValue 空字符串视为未设置
+func stringValue(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
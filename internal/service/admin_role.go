@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/middleware"
+	pb "github.com/weetime/agent-matrix/protos/v1"
+)
+
+// ListPermissions 查询可分配给角色的权限
+func (s *AdminService) ListPermissions(ctx context.Context, req *pb.ListPermissionsRequest) (*pb.Response, error) {
+	list := make([]interface{}, 0, len(middleware.Permissions))
+	for _, p := range middleware.Permissions {
+		list = append(list, map[string]interface{}{
+			"code": p.Code,
+			"name": p.Name,
+		})
+	}
+	return responseWithData(map[string]interface{}{
+		"list": list,
+	}), nil
+}
+
+// ListRoles 查询角色列表
+func (s *AdminService) ListRoles(ctx context.Context, req *pb.ListRolesRequest) (*pb.Response, error) {
+	roles, err := s.roleUsecase.ListRoles(ctx)
+	if err != nil {
+		return responseFromError(err), nil
+	}
+	return responseWithData(map[string]interface{}{
+		"list": rolesToVO(roles),
+	}), nil
+}
+
+// CreateRole 创建角色
+func (s *AdminService) CreateRole(ctx context.Context, req *pb.CreateRoleRequest) (*pb.Response, error) {
+	operator, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
+
+	role, err := s.roleUsecase.CreateRole(ctx, operator, &biz.Role{
+		Code:        req.GetCode(),
+		Name:        req.GetName(),
+		Permissions: req.GetPermissions(),
+		Remark:      req.GetRemark(),
+	})
+	if err != nil {
+		return responseFromError(err), nil
+	}
+	return responseWithData(roleToVO(role)), nil
+}
+
+// UpdateRole 修改角色
+func (s *AdminService) UpdateRole(ctx context.Context, req *pb.UpdateRoleRequest) (*pb.Response, error) {
+	operator, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
+	roleId, err := strconv.ParseInt(req.GetId(), 10, 64)
+	if err != nil {
+		return &pb.Response{
+			Code: 400,
+			Msg:  "无效的角色ID",
+		}, nil
+	}
+
+	if err := s.roleUsecase.UpdateRole(ctx, operator, &biz.Role{
+		ID:          roleId,
+		Name:        req.GetName(),
+		Permissions: req.GetPermissions(),
+		Remark:      req.GetRemark(),
+	}); err != nil {
+		return responseFromError(err), nil
+	}
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+	}, nil
+}
+
+// DeleteRole 删除角色
+func (s *AdminService) DeleteRole(ctx context.Context, req *pb.DeleteRoleRequest) (*pb.Response, error) {
+	operator, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
+	roleId, err := strconv.ParseInt(req.GetId(), 10, 64)
+	if err != nil {
+		return &pb.Response{
+			Code: 400,
+			Msg:  "无效的角色ID",
+		}, nil
+	}
+
+	if err := s.roleUsecase.DeleteRole(ctx, operator, roleId); err != nil {
+		return responseFromError(err), nil
+	}
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+	}, nil
+}
+
+// GetUserRoles 查询用户的角色
+func (s *AdminService) GetUserRoles(ctx context.Context, req *pb.GetUserRolesRequest) (*pb.Response, error) {
+	userId, err := strconv.ParseInt(req.GetId(), 10, 64)
+	if err != nil {
+		return &pb.Response{
+			Code: 400,
+			Msg:  "无效的用户ID",
+		}, nil
+	}
+
+	roles, err := s.roleUsecase.GetUserRoles(ctx, userId)
+	if err != nil {
+		return responseFromError(err), nil
+	}
+	return responseWithData(map[string]interface{}{
+		"list": rolesToVO(roles),
+	}), nil
+}
+
+// AssignUserRoles 设置用户的角色，修改后用户下次请求即按新角色鉴权
+func (s *AdminService) AssignUserRoles(ctx context.Context, req *pb.AssignUserRolesRequest) (*pb.Response, error) {
+	operator, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
+	userId, err := strconv.ParseInt(req.GetId(), 10, 64)
+	if err != nil {
+		return &pb.Response{
+			Code: 400,
+			Msg:  "无效的用户ID",
+		}, nil
+	}
+	roleIds := make([]int64, 0, len(req.GetRoleIds()))
+	for _, id := range req.GetRoleIds() {
+		roleId, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return &pb.Response{
+				Code: 400,
+				Msg:  "无效的角色ID: " + id,
+			}, nil
+		}
+		roleIds = append(roleIds, roleId)
+	}
+
+	if err := s.roleUsecase.AssignUserRoles(ctx, operator, userId, roleIds); err != nil {
+		return responseFromError(err), nil
+	}
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+	}, nil
+}
+
+// rolesToVO 转换角色列表
+func rolesToVO(roles []*biz.Role) []interface{} {
+	list := make([]interface{}, 0, len(roles))
+	for _, role := range roles {
+		list = append(list, roleToVO(role))
+	}
+	return list
+}
+
+// roleToVO 转换角色，ID以字符串返回避免前端精度丢失
+func roleToVO(role *biz.Role) map[string]interface{} {
+	vo := map[string]interface{}{
+		"id":          strconv.FormatInt(role.ID, 10),
+		"code":        role.Code,
+		"name":        role.Name,
+		"permissions": stringsToVO(role.Permissions),
+		"remark":      role.Remark,
+		"builtin":     role.Builtin,
+	}
+	if !role.UpdateDate.IsZero() {
+		vo["updateDate"] = role.UpdateDate.Format(time.DateTime)
+	}
+	return vo
+}
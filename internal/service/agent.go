@@ -3,10 +3,12 @@ package service
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/weetime/agent-matrix/internal/biz"
 	"github.com/weetime/agent-matrix/internal/kit"
+	"github.com/weetime/agent-matrix/internal/kit/cerrors"
 	"github.com/weetime/agent-matrix/internal/middleware"
 	pb "github.com/weetime/agent-matrix/protos/v1"
 
@@ -18,15 +20,17 @@ type AgentService struct {
 	modelUc           *biz.ModelUsecase
 	voicePrintUc      *biz.AgentVoicePrintUsecase
 	contextProviderUc *biz.AgentContextProviderUsecase
+	agentDatasetUc    *biz.AgentDatasetUsecase
 	pb.UnimplementedAgentServiceServer
 }
 
-func NewAgentService(uc *biz.AgentUsecase, modelUc *biz.ModelUsecase, voicePrintUc *biz.AgentVoicePrintUsecase, contextProviderUc *biz.AgentContextProviderUsecase) *AgentService {
+func NewAgentService(uc *biz.AgentUsecase, modelUc *biz.ModelUsecase, voicePrintUc *biz.AgentVoicePrintUsecase, contextProviderUc *biz.AgentContextProviderUsecase, agentDatasetUc *biz.AgentDatasetUsecase) *AgentService {
 	return &AgentService{
 		uc:                uc,
 		modelUc:           modelUc,
 		voicePrintUc:      voicePrintUc,
 		contextProviderUc: contextProviderUc,
+		agentDatasetUc:    agentDatasetUc,
 	}
 }
 
@@ -65,6 +69,7 @@ func (s *AgentService) ListUserAgents(ctx context.Context, req *pb.Empty) (*pb.R
 			"summaryMemory":   agent.SummaryMemory,
 			"lastConnectedAt": agent.LastConnectedAt,
 			"deviceCount":     agent.DeviceCount,
+			"onlineCount":     agent.OnlineCount,
 		})
 	}
 
@@ -196,6 +201,19 @@ func (s *AgentService) GetAgentById(ctx context.Context, req *pb.GetAgentByIdReq
 		}
 	}
 
+	// 查询绑定的知识库
+	datasets := make([]interface{}, 0)
+	boundDatasets, err := s.agentDatasetUc.GetDatasetsByAgentId(ctx, req.GetId())
+	if err == nil {
+		for _, dataset := range boundDatasets {
+			datasets = append(datasets, map[string]interface{}{
+				"datasetId":  dataset.DatasetID,
+				"name":       dataset.Name,
+				"ragModelId": dataset.RagModelID,
+			})
+		}
+	}
+
 	data := map[string]interface{}{
 		"id":               agent.ID,
 		"userId":           fmt.Sprintf("%d", agent.UserID),
@@ -221,6 +239,7 @@ func (s *AgentService) GetAgentById(ctx context.Context, req *pb.GetAgentByIdReq
 		"updatedAt":        agent.UpdatedAt.Format(time.RFC3339),
 		"functions":        functions,
 		"contextProviders": contextProviders,
+		"datasets":         datasets,
 	}
 
 	dataStruct, err := structpb.NewStruct(data)
@@ -397,6 +416,20 @@ func (s *AgentService) UpdateAgent(ctx context.Context, req *pb.AgentUpdateReque
 		}
 	}
 
+	// 更新绑定的知识库
+	if req.Datasets != nil {
+		if err := s.agentDatasetUc.SaveByAgentId(ctx, req.GetId(), req.Datasets.GetDatasetIds(), userId); err != nil {
+			code := int32(500)
+			if cerrors.IsPermissionDenied(err) {
+				code = 403
+			}
+			return &pb.Response{
+				Code: code,
+				Msg:  fmt.Sprintf("更新知识库绑定失败: %v", err),
+			}, nil
+		}
+	}
+
 	return &pb.Response{
 		Code: 0,
 		Msg:  "success",
@@ -420,6 +453,14 @@ func (s *AgentService) DeleteAgent(ctx context.Context, req *pb.GetAgentByIdRequ
 		// 忽略错误，继续删除智能体
 	}
 
+	// 删除绑定的知识库
+	if err := s.agentDatasetUc.DeleteByAgentId(ctx, agentId); err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  fmt.Sprintf("删除知识库绑定失败: %v", err),
+		}, nil
+	}
+
 	err := s.uc.DeleteAgent(ctx, agentId)
 	if err != nil {
 		return &pb.Response{
@@ -434,6 +475,90 @@ func (s *AgentService) DeleteAgent(ctx context.Context, req *pb.GetAgentByIdRequ
 	}, nil
 }
 
+// RetrieveAgentKnowledge 检索智能体绑定的知识库
+// 语音服务使用server.secret调用，用户token调用时只能检索自己的智能体
+func (s *AgentService) RetrieveAgentKnowledge(ctx context.Context, req *pb.AgentRetrieveRequest) (*pb.Response, error) {
+	if req == nil || req.GetId() == "" {
+		return &pb.Response{
+			Code: 400,
+			Msg:  "智能体ID不能为空",
+		}, nil
+	}
+	if strings.TrimSpace(req.GetQuestion()) == "" {
+		return &pb.Response{
+			Code: 400,
+			Msg:  "检索问题不能为空",
+		}, nil
+	}
+
+	if !middleware.IsServerSecretRequest(ctx) {
+		user, err := middleware.GetUserFromContext(ctx)
+		if err != nil {
+			return &pb.Response{
+				Code: 401,
+				Msg:  "未授权",
+			}, nil
+		}
+		allowed, err := s.uc.CheckAgentPermission(ctx, req.GetId(), user.ID, user.HasPermission(middleware.PermissionAll))
+		if err != nil {
+			return &pb.Response{
+				Code: 404,
+				Msg:  "智能体不存在",
+			}, nil
+		}
+		if !allowed {
+			return &pb.Response{
+				Code: 403,
+				Msg:  "无权限访问该智能体",
+			}, nil
+		}
+	}
+
+	var similarityThreshold *float64
+	if req.SimilarityThreshold != nil {
+		value := req.SimilarityThreshold.GetValue()
+		similarityThreshold = &value
+	}
+
+	chunks, err := s.agentDatasetUc.Retrieve(ctx, req.GetId(), req.GetQuestion(), int(req.GetTopN().GetValue()), similarityThreshold)
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  err.Error(),
+		}, nil
+	}
+
+	chunkList := make([]interface{}, 0, len(chunks))
+	for _, chunk := range chunks {
+		chunkList = append(chunkList, map[string]interface{}{
+			"id":           chunk.ID,
+			"content":      chunk.Content,
+			"documentId":   chunk.DocumentID,
+			"documentName": chunk.DocumentName,
+			"datasetId":    chunk.DatasetID,
+			"datasetName":  chunk.DatasetName,
+			"similarity":   chunk.Similarity,
+		})
+	}
+
+	dataStruct, err := structpb.NewStruct(map[string]interface{}{
+		"chunks": chunkList,
+		"total":  len(chunkList),
+	})
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  "构建响应数据失败: " + err.Error(),
+		}, nil
+	}
+
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+		Data: dataStruct,
+	}, nil
+}
+
 // getModelName 获取模型名称（辅助函数）
 func (s *AgentService) getModelName(ctx context.Context, modelID string) string {
 	if modelID == "" {
@@ -1284,7 +1409,7 @@ func (s *AgentService) GetAgentMcpAddress(ctx context.Context, req *pb.GetAgentM
 			Msg:  "未授权，请先登录",
 		}, nil
 	}
-	isSuperAdmin := userDetail != nil && userDetail.SuperAdmin == 1
+	isSuperAdmin := userDetail != nil && userDetail.HasPermission(middleware.PermissionAll)
 
 	// 检查权限
 	hasPermission, err := s.uc.CheckAgentPermission(ctx, req.GetAgentId(), userId, isSuperAdmin)
@@ -1354,7 +1479,7 @@ func (s *AgentService) GetAgentMcpTools(ctx context.Context, req *pb.GetAgentMcp
 			Msg:  "未授权，请先登录",
 		}, nil
 	}
-	isSuperAdmin := userDetail != nil && userDetail.SuperAdmin == 1
+	isSuperAdmin := userDetail != nil && userDetail.HasPermission(middleware.PermissionAll)
 
 	// 检查权限
 	hasPermission, err := s.uc.CheckAgentPermission(ctx, req.GetAgentId(), userId, isSuperAdmin)
@@ -139,6 +139,7 @@ func (s *AgentService) GetDownloadUrlHandler(w http.ResponseWriter, r *http.Requ
 	}
 
 	// 首先尝试用用户token验证
+	ctx = middleware.WithClientInfo(ctx, middleware.ClientInfoFromRequest(r))
 	user, err := tokenService.GetUserByToken(ctx, token)
 	if err == nil && user != nil {
 		// 用户token验证成功，检查账号状态
@@ -152,7 +153,7 @@ func (s *AgentService) GetDownloadUrlHandler(w http.ResponseWriter, r *http.Requ
 		ctx = context.WithValue(ctx, middleware.UserIDKey, user.ID)
 
 		// 检查权限
-		hasPermission, err := s.uc.CheckAgentPermission(ctx, agentId, user.ID, user.SuperAdmin == 1)
+		hasPermission, err := s.uc.CheckAgentPermission(ctx, agentId, user.ID, user.HasPermission(middleware.PermissionAll))
 		if err != nil {
 			writeErrorResponse(w, http.StatusInternalServerError, 500, err.Error())
 			return
@@ -454,6 +454,10 @@ func (s *DatasetService) PageDocuments(ctx context.Context, req *pb.PageDocument
 			"parser_config": item.ParserConfig,
 			"status":        item.Status,
 			"run":           item.Run,
+			"progress":      item.Progress,
+			"progress_msg":  item.ProgressMsg,
+			"chunk_count":   item.ChunkCount,
+			"token_count":   item.TokenCount,
 			"creator":       item.Creator,
 			"created_at":    item.CreatedAt,
 			"updater":       item.Updater,
@@ -538,6 +542,10 @@ func (s *DatasetService) PageDocumentsByStatus(ctx context.Context, req *pb.Page
 			"parser_config": item.ParserConfig,
 			"status":        item.Status,
 			"run":           item.Run,
+			"progress":      item.Progress,
+			"progress_msg":  item.ProgressMsg,
+			"chunk_count":   item.ChunkCount,
+			"token_count":   item.TokenCount,
 			"creator":       item.Creator,
 			"created_at":    item.CreatedAt,
 			"updater":       item.Updater,
@@ -659,8 +667,8 @@ func (s *DatasetService) ParseDocuments(ctx context.Context, req *pb.ParseDocume
 		}, nil
 	}
 
-	// 调用biz层解析文档
-	success, err := s.documentUsecase.ParseDocuments(ctx, req.GetDatasetId(), req.GetDocumentIds())
+	// 调用biz层创建解析任务，由后台异步执行
+	jobs, err := s.documentUsecase.ParseDocuments(ctx, req.GetDatasetId(), req.GetDocumentIds())
 	if err != nil {
 		return &pb.Response{
 			Code: 500,
@@ -668,16 +676,157 @@ func (s *DatasetService) ParseDocuments(ctx context.Context, req *pb.ParseDocume
 		}, nil
 	}
 
-	if !success {
+	return parseJobsResponse(jobs)
+}
+
+// CancelParseDocuments 取消解析文档
+func (s *DatasetService) CancelParseDocuments(ctx context.Context, req *pb.CancelParseDocumentsRequest) (*pb.Response, error) {
+	// 获取当前用户ID
+	currentUserId, err := getCurrentUserID(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "未授权",
+		}, nil
+	}
+
+	// 验证知识库权限
+	err = s.validateKnowledgeBasePermission(ctx, req.GetDatasetId(), currentUserId)
+	if err != nil {
+		return &pb.Response{
+			Code: 403,
+			Msg:  err.Error(),
+		}, nil
+	}
+
+	// 验证参数
+	if len(req.GetDocumentIds()) == 0 {
+		return &pb.Response{
+			Code: 400,
+			Msg:  "document_ids参数不能为空",
+		}, nil
+	}
+
+	jobs, err := s.documentUsecase.CancelParseDocuments(ctx, req.GetDatasetId(), req.GetDocumentIds())
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  err.Error(),
+		}, nil
+	}
+
+	return parseJobsResponse(jobs)
+}
+
+// RetryParseDocuments 重试解析失败或已取消的文档
+func (s *DatasetService) RetryParseDocuments(ctx context.Context, req *pb.RetryParseDocumentsRequest) (*pb.Response, error) {
+	// 获取当前用户ID
+	currentUserId, err := getCurrentUserID(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "未授权",
+		}, nil
+	}
+
+	// 验证知识库权限
+	err = s.validateKnowledgeBasePermission(ctx, req.GetDatasetId(), currentUserId)
+	if err != nil {
+		return &pb.Response{
+			Code: 403,
+			Msg:  err.Error(),
+		}, nil
+	}
+
+	// 验证参数
+	if len(req.GetDocumentIds()) == 0 {
+		return &pb.Response{
+			Code: 400,
+			Msg:  "document_ids参数不能为空",
+		}, nil
+	}
+
+	jobs, err := s.documentUsecase.RetryParseDocuments(ctx, req.GetDatasetId(), req.GetDocumentIds())
+	if err != nil {
 		return &pb.Response{
 			Code: 500,
-			Msg:  "文档解析失败，文档可能正在处理中",
+			Msg:  err.Error(),
+		}, nil
+	}
+
+	return parseJobsResponse(jobs)
+}
+
+// ListParseJobs 查询知识库的解析任务
+func (s *DatasetService) ListParseJobs(ctx context.Context, req *pb.ListParseJobsRequest) (*pb.Response, error) {
+	// 获取当前用户ID
+	currentUserId, err := getCurrentUserID(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "未授权",
+		}, nil
+	}
+
+	// 验证知识库权限
+	err = s.validateKnowledgeBasePermission(ctx, req.GetDatasetId(), currentUserId)
+	if err != nil {
+		return &pb.Response{
+			Code: 403,
+			Msg:  err.Error(),
+		}, nil
+	}
+
+	jobs, err := s.documentUsecase.ListParseJobs(ctx, req.GetDatasetId(), req.GetStatus().GetValue())
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  err.Error(),
+		}, nil
+	}
+
+	return parseJobsResponse(jobs)
+}
+
+// parseJobsResponse 构建解析任务列表响应
+func parseJobsResponse(jobs []*biz.DocumentParseJob) (*pb.Response, error) {
+	jobList := make([]interface{}, 0, len(jobs))
+	for _, job := range jobs {
+		vo := map[string]interface{}{
+			"id":          job.ID,
+			"dataset_id":  job.DatasetID,
+			"document_id": job.DocumentID,
+			"status":      job.Status,
+			"progress":    job.Progress,
+			"message":     job.Message,
+			"attempt":     int64(job.Attempt),
+			"creator":     strconv.FormatInt(job.Creator, 10),
+			"created_at":  job.CreatedAt.Format("2006-01-02 15:04:05"),
+			"updated_at":  job.UpdatedAt.Format("2006-01-02 15:04:05"),
+		}
+		if job.StartedAt != nil {
+			vo["started_at"] = job.StartedAt.Format("2006-01-02 15:04:05")
+		}
+		if job.FinishedAt != nil {
+			vo["finished_at"] = job.FinishedAt.Format("2006-01-02 15:04:05")
+		}
+		jobList = append(jobList, vo)
+	}
+
+	dataStruct, err := structpb.NewStruct(map[string]interface{}{
+		"jobs": jobList,
+	})
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  "构建响应数据失败: " + err.Error(),
 		}, nil
 	}
 
 	return &pb.Response{
 		Code: 0,
 		Msg:  "success",
+		Data: dataStruct,
 	}, nil
 }
 
@@ -1,13 +1,10 @@
 package service
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"strings"
-	"time"
 
 	"github.com/weetime/agent-matrix/internal/biz"
 	"github.com/weetime/agent-matrix/internal/kit"
@@ -21,23 +18,22 @@ import (
 type DeviceService struct {
 	uc            *biz.DeviceUsecase
 	configUsecase *biz.ConfigUsecase
+	otaUsecase    *biz.OtaUsecase
 	redisClient   *redis.Client
-	httpClient    *http.Client
 	pb.UnimplementedDeviceServiceServer
 }
 
 func NewDeviceService(
 	uc *biz.DeviceUsecase,
 	configUsecase *biz.ConfigUsecase,
+	otaUsecase *biz.OtaUsecase,
 	redisClientWrapper *kit.RedisClient,
 ) *DeviceService {
 	return &DeviceService{
 		uc:            uc,
 		configUsecase: configUsecase,
+		otaUsecase:    otaUsecase,
 		redisClient:   redisClientWrapper.GetClient(),
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
 	}
 }
 
@@ -115,92 +111,10 @@ func (s *DeviceService) BindDevice(ctx context.Context, req *pb.DeviceBindReques
 		}, nil
 	}
 
-	agentId := req.GetAgentId()
-	deviceCode := req.GetDeviceCode()
-
-	// 从Redis获取激活码对应的设备ID
-	deviceKey := kit.GetDeviceActivationCodeKey(deviceCode)
-	deviceId, err := s.redisClient.Get(ctx, deviceKey).Result()
-	if err == redis.Nil {
-		return &pb.Response{
-			Code: 400,
-			Msg:  "激活码错误",
-		}, nil
-	}
-	if err != nil {
-		return &pb.Response{
-			Code: 500,
-			Msg:  "获取激活码失败: " + err.Error(),
-		}, nil
+	if err := s.otaUsecase.ActivateDeviceByCode(ctx, userID, req.GetAgentId(), req.GetDeviceCode()); err != nil {
+		return deviceErrorResponse(err), nil
 	}
 
-	// 获取设备激活数据
-	safeDeviceId := kit.SafeDeviceID(deviceId)
-	cacheDeviceKey := kit.GetDeviceActivationDataKey(safeDeviceId)
-	cacheDataStr, err := s.redisClient.Get(ctx, cacheDeviceKey).Result()
-	if err == redis.Nil {
-		return &pb.Response{
-			Code: 400,
-			Msg:  "激活码错误",
-		}, nil
-	}
-	if err != nil {
-		return &pb.Response{
-			Code: 500,
-			Msg:  "获取激活数据失败: " + err.Error(),
-		}, nil
-	}
-
-	// 解析缓存数据（Redis中存储的是JSON字符串）
-	var cacheMap map[string]interface{}
-	if err := json.Unmarshal([]byte(cacheDataStr), &cacheMap); err != nil {
-		// 如果解析失败，尝试直接使用字符串值
-		// 某些Redis客户端可能已经反序列化了
-		return &pb.Response{
-			Code: 500,
-			Msg:  "解析激活数据失败: " + err.Error(),
-		}, nil
-	}
-
-	// 验证激活码
-	cachedCode, ok := cacheMap["activation_code"].(string)
-	if !ok || cachedCode != deviceCode {
-		return &pb.Response{
-			Code: 400,
-			Msg:  "激活码错误",
-		}, nil
-	}
-
-	// 检查设备是否已被激活
-	existingDevice, err := s.uc.GetDeviceByID(ctx, deviceId)
-	if err != nil {
-		return &pb.Response{
-			Code: 500,
-			Msg:  "查询设备失败: " + err.Error(),
-		}, nil
-	}
-	if existingDevice != nil {
-		return &pb.Response{
-			Code: 400,
-			Msg:  "设备已被激活",
-		}, nil
-	}
-
-	// 获取设备信息
-	macAddress, _ := cacheMap["mac_address"].(string)
-	board, _ := cacheMap["board"].(string)
-	appVersion, _ := cacheMap["app_version"].(string)
-
-	// 调用Usecase的DeviceActivation方法
-	if err := s.uc.DeviceActivation(ctx, userID, agentId, deviceCode, deviceId, macAddress, board, appVersion); err != nil {
-		return nil, err
-	}
-
-	// 清理Redis缓存
-	s.redisClient.Del(ctx, cacheDeviceKey)
-	s.redisClient.Del(ctx, deviceKey)
-	s.redisClient.Del(ctx, kit.GetAgentDeviceCountKey(agentId))
-
 	return &pb.Response{
 		Code: 0,
 		Msg:  "success",
@@ -227,6 +141,15 @@ func (s *DeviceService) GetUserDevices(ctx context.Context, req *pb.GetUserDevic
 		}, nil
 	}
 
+	// 查询设备在线状态
+	presences, err := s.uc.GetDevicePresences(ctx, deviceMacAddresses(devices))
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  err.Error(),
+		}, nil
+	}
+
 	// 转换为VO列表，所有ID字段格式化为字符串
 	deviceList := make([]interface{}, 0, len(devices))
 	for _, device := range devices {
@@ -254,6 +177,14 @@ func (s *DeviceService) GetUserDevices(ctx context.Context, req *pb.GetUserDevic
 			deviceVO["updateDate"] = device.UpdateDate.Format("2006-01-02 15:04:05")
 		}
 
+		// 在线状态及所在节点
+		presence, online := presences[device.MacAddress]
+		deviceVO["online"] = online
+		if online {
+			deviceVO["nodeId"] = presence.NodeID
+			deviceVO["lastSeenAt"] = presence.LastSeenAt.Format("2006-01-02 15:04:05")
+		}
+
 		deviceList = append(deviceList, deviceVO)
 	}
 
@@ -276,7 +207,8 @@ func (s *DeviceService) GetUserDevices(ctx context.Context, req *pb.GetUserDevic
 	}, nil
 }
 
-// ForwardToMqttGateway 设备在线状态查询（转发MQTT）
+// ForwardToMqttGateway 设备在线状态查询
+// 在线状态由语音服务节点通过/ws上报，返回结构与原MQTT网关接口保持一致
 func (s *DeviceService) ForwardToMqttGateway(ctx context.Context, req *pb.DeviceStatusRequest) (*pb.Response, error) {
 	// 从context获取当前用户ID
 	userID, err := middleware.GetUserIdFromContext(ctx)
@@ -287,19 +219,16 @@ func (s *DeviceService) ForwardToMqttGateway(ctx context.Context, req *pb.Device
 		}, nil
 	}
 
-	agentId := req.GetAgentId()
-
-	// 从系统参数获取MQTT网关地址
-	mqttGatewayUrl, err := s.configUsecase.GetValue(ctx, "server.mqtt_manager_api", true)
-	if err != nil || mqttGatewayUrl == "" || mqttGatewayUrl == "null" {
+	// 获取当前用户的设备列表
+	devices, err := s.uc.GetUserDevices(ctx, userID, req.GetAgentId())
+	if err != nil {
 		return &pb.Response{
-			Code: 0,
-			Msg:  "success",
+			Code: 500,
+			Msg:  err.Error(),
 		}, nil
 	}
 
-	// 获取当前用户的设备列表
-	devices, err := s.uc.GetUserDevices(ctx, userID, agentId)
+	presences, err := s.uc.GetDevicePresences(ctx, deviceMacAddresses(devices))
 	if err != nil {
 		return &pb.Response{
 			Code: 500,
@@ -307,8 +236,8 @@ func (s *DeviceService) ForwardToMqttGateway(ctx context.Context, req *pb.Device
 		}, nil
 	}
 
-	// 构建deviceIds数组
-	deviceIds := make([]string, 0, len(devices))
+	// 按MQTT客户端ID格式（groupId@@@macAddress@@@macAddress）返回设备状态
+	statuses := make(map[string]interface{}, len(devices))
 	for _, device := range devices {
 		macAddress := device.MacAddress
 		if macAddress == "" {
@@ -323,81 +252,48 @@ func (s *DeviceService) ForwardToMqttGateway(ctx context.Context, req *pb.Device
 		groupId = strings.ReplaceAll(groupId, ":", "_")
 		macAddress = strings.ReplaceAll(macAddress, ":", "_")
 
-		// 构建mqtt客户端ID格式：groupId@@@macAddress@@@macAddress
-		mqttClientId := fmt.Sprintf("%s@@@%s@@@%s", groupId, macAddress, macAddress)
-		deviceIds = append(deviceIds, mqttClientId)
-	}
-
-	// 构建完整的URL
-	url := fmt.Sprintf("http://%s/api/devices/status", mqttGatewayUrl)
-
-	// 生成Bearer Token
-	signatureKey, err := s.configUsecase.GetValue(ctx, "server.mqtt_signature_key", false)
-	if err != nil || signatureKey == "" {
-		return &pb.Response{
-			Code: 500,
-			Msg:  "令牌生成失败",
-		}, nil
-	}
-	token := kit.GenerateBearerTokenForToday(signatureKey)
-
-	// 构建请求体JSON
-	requestBody := map[string]interface{}{
-		"clientIds": deviceIds,
-	}
-	jsonBody, err := json.Marshal(requestBody)
-	if err != nil {
-		return &pb.Response{
-			Code: 500,
-			Msg:  "构建请求体失败: " + err.Error(),
-		}, nil
-	}
-
-	// 创建HTTP请求
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return &pb.Response{
-			Code: 500,
-			Msg:  "创建请求失败: " + err.Error(),
-		}, nil
-	}
-
-	// 设置请求头
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+token)
-
-	// 发送请求
-	resp, err := s.httpClient.Do(httpReq)
-	if err != nil {
-		return &pb.Response{
-			Code: 500,
-			Msg:  "转发请求失败: " + err.Error(),
-		}, nil
+		status := map[string]interface{}{
+			"exists":  true,
+			"isAlive": false,
+		}
+		if presence, ok := presences[device.MacAddress]; ok {
+			status["isAlive"] = true
+			status["nodeId"] = presence.NodeID
+			status["lastSeenAt"] = presence.LastSeenAt.Format("2006-01-02 15:04:05")
+		}
+		statuses[fmt.Sprintf("%s@@@%s@@@%s", groupId, macAddress, macAddress)] = status
 	}
-	defer resp.Body.Close()
 
-	// 读取响应
-	var responseBody bytes.Buffer
-	_, err = responseBody.ReadFrom(resp.Body)
+	result, err := json.Marshal(statuses)
 	if err != nil {
 		return &pb.Response{
 			Code: 500,
-			Msg:  "读取响应失败: " + err.Error(),
+			Msg:  "构建响应数据失败: " + err.Error(),
 		}, nil
 	}
 
-	// 直接返回MQTT网关的响应体（与Java版本一致）
 	return &pb.Response{
 		Code: 0,
 		Msg:  "success",
 		Data: &structpb.Struct{
 			Fields: map[string]*structpb.Value{
-				"result": {Kind: &structpb.Value_StringValue{StringValue: responseBody.String()}},
+				"result": {Kind: &structpb.Value_StringValue{StringValue: string(result)}},
 			},
 		},
 	}, nil
 }
 
+// deviceMacAddresses 提取设备MAC地址列表
+func deviceMacAddresses(devices []*biz.Device) []string {
+	macAddresses := make([]string, 0, len(devices))
+	for _, device := range devices {
+		if device.MacAddress != "" {
+			macAddresses = append(macAddresses, device.MacAddress)
+		}
+	}
+	return macAddresses
+}
+
 // UnbindDevice 解绑设备
 func (s *DeviceService) UnbindDevice(ctx context.Context, req *pb.DeviceUnbindRequest) (*pb.Response, error) {
 	// 从context获取当前用户ID
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/kit/cerrors"
+	"github.com/weetime/agent-matrix/internal/middleware"
+	pb "github.com/weetime/agent-matrix/protos/v1"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ListDeviceGroups 获取设备分组列表
+func (s *DeviceService) ListDeviceGroups(ctx context.Context, req *pb.ListDeviceGroupsRequest) (*pb.Response, error) {
+	op, resp := deviceOperator(ctx)
+	if resp != nil {
+		return resp, nil
+	}
+
+	groups, err := s.uc.ListDeviceGroups(ctx, op)
+	if err != nil {
+		return deviceErrorResponse(err), nil
+	}
+	list := make([]interface{}, 0, len(groups))
+	for _, group := range groups {
+		list = append(list, deviceGroupToVO(group))
+	}
+	return deviceDataResponse(map[string]interface{}{
+		"list": list,
+	}), nil
+}
+
+// CreateDeviceGroup 创建设备分组
+func (s *DeviceService) CreateDeviceGroup(ctx context.Context, req *pb.CreateDeviceGroupRequest) (*pb.Response, error) {
+	op, resp := deviceOperator(ctx)
+	if resp != nil {
+		return resp, nil
+	}
+
+	group, err := s.uc.CreateDeviceGroup(ctx, op, req.GetName(), req.GetDescription(), req.GetGlobal())
+	if err != nil {
+		return deviceErrorResponse(err), nil
+	}
+	return deviceDataResponse(deviceGroupToVO(group)), nil
+}
+
+// UpdateDeviceGroup 修改设备分组
+func (s *DeviceService) UpdateDeviceGroup(ctx context.Context, req *pb.UpdateDeviceGroupRequest) (*pb.Response, error) {
+	op, resp := deviceOperator(ctx)
+	if resp != nil {
+		return resp, nil
+	}
+
+	var name, description *string
+	if req.Name != nil {
+		val := req.Name.GetValue()
+		name = &val
+	}
+	if req.Description != nil {
+		val := req.Description.GetValue()
+		description = &val
+	}
+	if err := s.uc.UpdateDeviceGroup(ctx, op, req.GetId(), name, description); err != nil {
+		return deviceErrorResponse(err), nil
+	}
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+	}, nil
+}
+
+// DeleteDeviceGroup 删除设备分组
+func (s *DeviceService) DeleteDeviceGroup(ctx context.Context, req *pb.DeleteDeviceGroupRequest) (*pb.Response, error) {
+	op, resp := deviceOperator(ctx)
+	if resp != nil {
+		return resp, nil
+	}
+
+	if err := s.uc.DeleteDeviceGroup(ctx, op, req.GetId()); err != nil {
+		return deviceErrorResponse(err), nil
+	}
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+	}, nil
+}
+
+// GetDeviceGroupDevices 获取分组内的设备
+func (s *DeviceService) GetDeviceGroupDevices(ctx context.Context, req *pb.GetDeviceGroupDevicesRequest) (*pb.Response, error) {
+	op, resp := deviceOperator(ctx)
+	if resp != nil {
+		return resp, nil
+	}
+
+	group, devices, err := s.uc.GetDeviceGroupDevices(ctx, op, req.GetId())
+	if err != nil {
+		return deviceErrorResponse(err), nil
+	}
+	list := make([]interface{}, 0, len(devices))
+	for _, device := range devices {
+		list = append(list, adminDeviceToVO(device))
+	}
+	vo := deviceGroupToVO(group)
+	vo["deviceCount"] = len(devices)
+	vo["list"] = list
+	return deviceDataResponse(vo), nil
+}
+
+// AddDeviceGroupDevices 将设备加入分组
+func (s *DeviceService) AddDeviceGroupDevices(ctx context.Context, req *pb.DeviceGroupDevicesRequest) (*pb.Response, error) {
+	op, resp := deviceOperator(ctx)
+	if resp != nil {
+		return resp, nil
+	}
+
+	results, err := s.uc.AddDeviceGroupDevices(ctx, op, req.GetId(), req.GetDeviceIds())
+	if err != nil {
+		return deviceErrorResponse(err), nil
+	}
+	return deviceBatchResponse(results), nil
+}
+
+// RemoveDeviceGroupDevices 将设备移出分组
+func (s *DeviceService) RemoveDeviceGroupDevices(ctx context.Context, req *pb.DeviceGroupDevicesRequest) (*pb.Response, error) {
+	op, resp := deviceOperator(ctx)
+	if resp != nil {
+		return resp, nil
+	}
+
+	results, err := s.uc.RemoveDeviceGroupDevices(ctx, op, req.GetId(), req.GetDeviceIds())
+	if err != nil {
+		return deviceErrorResponse(err), nil
+	}
+	return deviceBatchResponse(results), nil
+}
+
+// BatchAssignAgent 批量切换设备智能体
+func (s *DeviceService) BatchAssignAgent(ctx context.Context, req *pb.DeviceBatchAssignAgentRequest) (*pb.Response, error) {
+	op, resp := deviceOperator(ctx)
+	if resp != nil {
+		return resp, nil
+	}
+
+	results, err := s.uc.BatchAssignAgent(ctx, op, toBizDeviceBatchTarget(req.GetTarget()), req.GetAgentId())
+	if err != nil {
+		return deviceErrorResponse(err), nil
+	}
+	return deviceBatchResponse(results), nil
+}
+
+// BatchSetAutoUpdate 批量设置自动更新
+func (s *DeviceService) BatchSetAutoUpdate(ctx context.Context, req *pb.DeviceBatchAutoUpdateRequest) (*pb.Response, error) {
+	op, resp := deviceOperator(ctx)
+	if resp != nil {
+		return resp, nil
+	}
+
+	results, err := s.uc.BatchSetAutoUpdate(ctx, op, toBizDeviceBatchTarget(req.GetTarget()), req.GetAutoUpdate())
+	if err != nil {
+		return deviceErrorResponse(err), nil
+	}
+	return deviceBatchResponse(results), nil
+}
+
+// BatchUnbindDevices 批量解绑设备
+func (s *DeviceService) BatchUnbindDevices(ctx context.Context, req *pb.DeviceBatchTarget) (*pb.Response, error) {
+	op, resp := deviceOperator(ctx)
+	if resp != nil {
+		return resp, nil
+	}
+
+	results, err := s.uc.BatchUnbindDevices(ctx, op, toBizDeviceBatchTarget(req))
+	if err != nil {
+		return deviceErrorResponse(err), nil
+	}
+	return deviceBatchResponse(results), nil
+}
+
+// ImportDeviceAliases 从CSV批量导入设备别名
+func (s *DeviceService) ImportDeviceAliases(ctx context.Context, req *pb.ImportDeviceAliasesRequest) (*pb.Response, error) {
+	op, resp := deviceOperator(ctx)
+	if resp != nil {
+		return resp, nil
+	}
+
+	results, err := s.uc.ImportDeviceAliases(ctx, op, []byte(req.GetCsv()))
+	if err != nil {
+		return deviceErrorResponse(err), nil
+	}
+	return deviceBatchResponse(results), nil
+}
+
+// deviceOperator 获取当前操作人，未登录时返回401响应
+func deviceOperator(ctx context.Context) (*biz.DeviceOperator, *pb.Response) {
+	userID, err := middleware.GetUserIdFromContext(ctx)
+	if err != nil {
+		return nil, &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}
+	}
+	return &biz.DeviceOperator{
+		UserID:     userID,
+		SuperAdmin: middleware.IsSuperAdmin(ctx),
+	}, nil
+}
+
+// toBizDeviceBatchTarget 转换批量操作目标
+func toBizDeviceBatchTarget(target *pb.DeviceBatchTarget) *biz.DeviceBatchTarget {
+	return &biz.DeviceBatchTarget{
+		DeviceIDs: target.GetDeviceIds(),
+		GroupID:   target.GetGroupId(),
+	}
+}
+
+// deviceErrorResponse 根据错误类型返回对应的响应码
+func deviceErrorResponse(err error) *pb.Response {
+	code := int32(500)
+	switch {
+	case cerrors.IsInvalidInput(err):
+		code = 400
+	case cerrors.IsPermissionDenied(err):
+		code = 403
+	case cerrors.IsNotFound(err):
+		code = 404
+	}
+	return &pb.Response{
+		Code: code,
+		Msg:  err.Error(),
+	}
+}
+
+// deviceDataResponse 构建带数据的成功响应
+func deviceDataResponse(data map[string]interface{}) *pb.Response {
+	dataStruct, err := structpb.NewStruct(data)
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  "构建响应数据失败: " + err.Error(),
+		}
+	}
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+		Data: dataStruct,
+	}
+}
+
+// deviceBatchResponse 构建批量操作的逐项结果报告
+func deviceBatchResponse(results []*biz.DeviceBatchResult) *pb.Response {
+	list := make([]interface{}, 0, len(results))
+	succeeded := 0
+	for _, result := range results {
+		item := map[string]interface{}{
+			"deviceId": result.DeviceID,
+			"success":  result.Success,
+			"message":  result.Message,
+		}
+		if result.Row > 0 {
+			item["row"] = result.Row
+		}
+		if result.Success {
+			succeeded++
+		}
+		list = append(list, item)
+	}
+	return deviceDataResponse(map[string]interface{}{
+		"total":   len(results),
+		"success": succeeded,
+		"failed":  len(results) - succeeded,
+		"list":    list,
+	})
+}
+
+// deviceGroupToVO 转换为VO，ID字段格式化为字符串
+func deviceGroupToVO(group *biz.DeviceGroup) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          group.ID,
+		"name":        group.Name,
+		"description": group.Description,
+		"userId":      fmt.Sprintf("%d", group.UserID),
+		"global":      group.IsGlobal(),
+		"deviceCount": group.DeviceCount,
+		"createdAt":   group.CreatedAt.Format(time.DateTime),
+		"updatedAt":   group.UpdatedAt.Format(time.DateTime),
+	}
+}
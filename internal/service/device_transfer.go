@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/middleware"
+	pb "github.com/weetime/agent-matrix/protos/v1"
+)
+
+// ListDeviceTransfers 获取设备转移请求列表，direction为incoming时返回收到的请求，否则返回发起的请求
+func (s *DeviceService) ListDeviceTransfers(ctx context.Context, req *pb.ListDeviceTransfersRequest) (*pb.Response, error) {
+	userID, err := middleware.GetUserIdFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
+
+	transfers, err := s.uc.ListDeviceTransfers(ctx, userID, req.GetDirection() == "incoming")
+	if err != nil {
+		return deviceErrorResponse(err), nil
+	}
+	list := make([]interface{}, 0, len(transfers))
+	for _, transfer := range transfers {
+		list = append(list, deviceTransferToVO(transfer))
+	}
+	return deviceDataResponse(map[string]interface{}{
+		"list": list,
+	}), nil
+}
+
+// CreateDeviceTransfer 发起设备转移
+func (s *DeviceService) CreateDeviceTransfer(ctx context.Context, req *pb.CreateDeviceTransferRequest) (*pb.Response, error) {
+	userID, err := middleware.GetUserIdFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
+
+	transfer, err := s.uc.CreateDeviceTransfer(ctx, userID, req.GetDeviceId(), req.GetToUsername(), req.GetMigrateHistory())
+	if err != nil {
+		return deviceErrorResponse(err), nil
+	}
+	return deviceDataResponse(deviceTransferToVO(transfer)), nil
+}
+
+// AcceptDeviceTransfer 接受设备转移
+func (s *DeviceService) AcceptDeviceTransfer(ctx context.Context, req *pb.AcceptDeviceTransferRequest) (*pb.Response, error) {
+	userID, err := middleware.GetUserIdFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
+
+	transfer, err := s.uc.AcceptDeviceTransfer(ctx, userID, req.GetId(), req.GetAgentId())
+	if err != nil {
+		return deviceErrorResponse(err), nil
+	}
+	return deviceDataResponse(deviceTransferToVO(transfer)), nil
+}
+
+// RejectDeviceTransfer 拒绝设备转移
+func (s *DeviceService) RejectDeviceTransfer(ctx context.Context, req *pb.DeviceTransferActionRequest) (*pb.Response, error) {
+	userID, err := middleware.GetUserIdFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
+
+	if err := s.uc.RejectDeviceTransfer(ctx, userID, req.GetId()); err != nil {
+		return deviceErrorResponse(err), nil
+	}
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+	}, nil
+}
+
+// CancelDeviceTransfer 取消设备转移
+func (s *DeviceService) CancelDeviceTransfer(ctx context.Context, req *pb.DeviceTransferActionRequest) (*pb.Response, error) {
+	userID, err := middleware.GetUserIdFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
+
+	if err := s.uc.CancelDeviceTransfer(ctx, userID, req.GetId()); err != nil {
+		return deviceErrorResponse(err), nil
+	}
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+	}, nil
+}
+
+// deviceTransferToVO 转换为VO，ID字段格式化为字符串
+func deviceTransferToVO(transfer *biz.DeviceTransfer) map[string]interface{} {
+	return map[string]interface{}{
+		"id":             transfer.ID,
+		"deviceId":       transfer.DeviceID,
+		"macAddress":     transfer.MacAddress,
+		"fromUserId":     fmt.Sprintf("%d", transfer.FromUserID),
+		"fromUsername":   transfer.FromUsername,
+		"toUserId":       fmt.Sprintf("%d", transfer.ToUserID),
+		"toUsername":     transfer.ToUsername,
+		"agentId":        transfer.AgentID,
+		"migrateHistory": transfer.MigrateHistory,
+		"status":         transfer.Status,
+		"expiresAt":      transfer.ExpiresAt.Format(time.DateTime),
+		"createdAt":      transfer.CreatedAt.Format(time.DateTime),
+		"updatedAt":      transfer.UpdatedAt.Format(time.DateTime),
+	}
+}
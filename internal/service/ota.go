@@ -34,28 +34,8 @@ func NewOtaService(
 	}
 }
 
-// checkSuperAdminPermission 检查是否为超级管理员
-func (s *OtaService) checkSuperAdminPermission(ctx context.Context) error {
-	user, err := middleware.GetUserFromContext(ctx)
-	if err != nil {
-		return fmt.Errorf("未授权")
-	}
-	if user.SuperAdmin != 1 {
-		return fmt.Errorf("需要超级管理员权限")
-	}
-	return nil
-}
-
 // PageOta 分页查询OTA固件
 func (s *OtaService) PageOta(ctx context.Context, req *pb.PageOtaRequest) (*pb.Response, error) {
-	// 权限检查：超级管理员
-	if err := s.checkSuperAdminPermission(ctx); err != nil {
-		return &pb.Response{
-			Code: 403,
-			Msg:  err.Error(),
-		}, nil
-	}
-
 	// 解析搜索条件
 	params := &biz.ListOtaParams{}
 	if req.FirmwareName != nil && req.FirmwareName.GetValue() != "" {
@@ -117,14 +97,6 @@ func (s *OtaService) PageOta(ctx context.Context, req *pb.PageOtaRequest) (*pb.R
 
 // GetOta 获取OTA固件详情
 func (s *OtaService) GetOta(ctx context.Context, req *pb.GetOtaRequest) (*pb.Response, error) {
-	// 权限检查：超级管理员
-	if err := s.checkSuperAdminPermission(ctx); err != nil {
-		return &pb.Response{
-			Code: 403,
-			Msg:  err.Error(),
-		}, nil
-	}
-
 	id := req.GetId()
 	if id == "" {
 		return &pb.Response{
@@ -169,14 +141,6 @@ func (s *OtaService) GetOta(ctx context.Context, req *pb.GetOtaRequest) (*pb.Res
 
 // SaveOta 保存OTA固件信息
 func (s *OtaService) SaveOta(ctx context.Context, req *pb.SaveOtaRequest) (*pb.Response, error) {
-	// 权限检查：超级管理员
-	if err := s.checkSuperAdminPermission(ctx); err != nil {
-		return &pb.Response{
-			Code: 403,
-			Msg:  err.Error(),
-		}, nil
-	}
-
 	// 获取当前用户ID
 	currentUserId, err := middleware.GetUserIdFromContext(ctx)
 	if err != nil {
@@ -217,14 +181,6 @@ func (s *OtaService) SaveOta(ctx context.Context, req *pb.SaveOtaRequest) (*pb.R
 
 // UpdateOta 修改OTA固件信息
 func (s *OtaService) UpdateOta(ctx context.Context, req *pb.UpdateOtaRequest) (*pb.Response, error) {
-	// 权限检查：超级管理员
-	if err := s.checkSuperAdminPermission(ctx); err != nil {
-		return &pb.Response{
-			Code: 403,
-			Msg:  err.Error(),
-		}, nil
-	}
-
 	// 获取当前用户ID
 	currentUserId, err := middleware.GetUserIdFromContext(ctx)
 	if err != nil {
@@ -271,14 +227,6 @@ func (s *OtaService) UpdateOta(ctx context.Context, req *pb.UpdateOtaRequest) (*
 
 // DeleteOta 删除OTA固件
 func (s *OtaService) DeleteOta(ctx context.Context, req *pb.DeleteOtaRequest) (*pb.Response, error) {
-	// 权限检查：超级管理员
-	if err := s.checkSuperAdminPermission(ctx); err != nil {
-		return &pb.Response{
-			Code: 403,
-			Msg:  err.Error(),
-		}, nil
-	}
-
 	id := req.GetId()
 	if id == "" {
 		return &pb.Response{
@@ -287,12 +235,9 @@ func (s *OtaService) DeleteOta(ctx context.Context, req *pb.DeleteOtaRequest) (*
 		}, nil
 	}
 
-	// 调用biz层删除
+	// 调用biz层删除（被灰度发布计划引用的固件不能删除）
 	if err := s.uc.DeleteOta(ctx, []string{id}); err != nil {
-		return &pb.Response{
-			Code: 500,
-			Msg:  err.Error(),
-		}, nil
+		return otaErrorResponse(err), nil
 	}
 
 	return &pb.Response{
@@ -303,14 +248,6 @@ func (s *OtaService) DeleteOta(ctx context.Context, req *pb.DeleteOtaRequest) (*
 
 // GetDownloadUrl 获取下载链接
 func (s *OtaService) GetDownloadUrl(ctx context.Context, req *pb.GetDownloadUrlRequest) (*pb.Response, error) {
-	// 权限检查：超级管理员
-	if err := s.checkSuperAdminPermission(ctx); err != nil {
-		return &pb.Response{
-			Code: 403,
-			Msg:  err.Error(),
-		}, nil
-	}
-
 	id := req.GetId()
 	if id == "" {
 		return &pb.Response{
@@ -376,6 +313,9 @@ func (s *OtaService) otaToVO(dto *biz.OtaResponseDTO) map[string]interface{} {
 		"size":         dto.Size,
 		"remark":       dto.Remark,
 		"firmwarePath": dto.FirmwarePath,
+		"sha256":       dto.Sha256,
+		"signature":    dto.Signature,
+		"signKeyId":    dto.SignKeyID,
 		"sort":         dto.Sort,
 		"createDate":   dto.CreateDate,
 		"updateDate":   dto.UpdateDate,
@@ -415,18 +355,9 @@ func (s *OtaService) checkOTAVersionInternal(ctx context.Context, deviceId, clie
 	return s.uc.CheckDeviceActive(ctx, deviceId, clientId, deviceReport)
 }
 
-// activateDeviceInternal 快速检查激活状态（内部方法，供HTTP handler调用）
-func (s *OtaService) activateDeviceInternal(ctx context.Context, deviceId string) (bool, error) {
-	if deviceId == "" {
-		return false, nil
-	}
-
-	device, err := s.uc.DeviceUsecase.GetDeviceByMacAddress(ctx, deviceId)
-	if err != nil {
-		return false, err
-	}
-
-	return device != nil, nil
+// activateDeviceInternal 校验设备挑战应答并返回激活状态（内部方法，供HTTP handler调用）
+func (s *OtaService) activateDeviceInternal(ctx context.Context, deviceId string, payload *biz.ActivationPayload) (bool, error) {
+	return s.uc.VerifyDeviceActivation(ctx, deviceId, payload)
 }
 
 // getOTAHealthInternal OTA健康检查（内部方法，供HTTP handler调用）
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/kit"
+	pb "github.com/weetime/agent-matrix/protos/v1"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// PageFirmwareHistory 分页查询设备固件版本历史
+func (s *OtaService) PageFirmwareHistory(ctx context.Context, req *pb.PageFirmwareHistoryRequest) (*pb.Response, error) {
+	params := &biz.ListFirmwareHistoryParams{}
+	if req.MacAddress != nil && req.MacAddress.GetValue() != "" {
+		macAddress := req.MacAddress.GetValue()
+		params.MacAddress = &macAddress
+	}
+	if req.OtaId != nil && req.OtaId.GetValue() != "" {
+		otaId := req.OtaId.GetValue()
+		params.OtaID = &otaId
+	}
+
+	page := &kit.PageRequest{}
+	pageNo := req.GetPage()
+	if pageNo == 0 {
+		pageNo = 1
+	}
+	pageSize := req.GetLimit()
+	if pageSize == 0 {
+		pageSize = kit.DEFAULT_PAGE_ZISE
+	}
+	page.SetPageNo(int(pageNo))
+	page.SetPageSize(int(pageSize))
+
+	list, total, err := s.uc.PageFirmwareHistory(ctx, params, page)
+	if err != nil {
+		return otaErrorResponse(err), nil
+	}
+
+	voList := make([]interface{}, 0, len(list))
+	for _, history := range list {
+		voList = append(voList, firmwareHistoryToVO(history))
+	}
+	dataStruct, err := structpb.NewStruct(map[string]interface{}{
+		"total": int32(total),
+		"list":  voList,
+	})
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  "构建响应数据失败: " + err.Error(),
+		}, nil
+	}
+
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+		Data: dataStruct,
+	}, nil
+}
+
+// GetFirmwareAdoption 按固件统计升级情况并列出反复下载仍未升级的设备
+func (s *OtaService) GetFirmwareAdoption(ctx context.Context, req *pb.GetFirmwareAdoptionRequest) (*pb.Response, error) {
+	adoptions, err := s.uc.GetFirmwareAdoption(ctx, req.GetType().GetValue())
+	if err != nil {
+		return otaErrorResponse(err), nil
+	}
+
+	list := make([]interface{}, 0, len(adoptions))
+	for _, adoption := range adoptions {
+		outcomes := make(map[string]interface{}, len(adoption.Outcomes))
+		var offered int64
+		for outcome, count := range adoption.Outcomes {
+			outcomes[outcome] = count
+			offered += count
+		}
+		stuckDevices := make([]interface{}, 0, len(adoption.StuckDevices))
+		for _, history := range adoption.StuckDevices {
+			stuckDevices = append(stuckDevices, firmwareHistoryToVO(history))
+		}
+
+		var adoptionRate float64
+		if adoption.Total > 0 {
+			adoptionRate = float64(adoption.Installed) / float64(adoption.Total)
+		}
+		list = append(list, map[string]interface{}{
+			"otaId":        adoption.Ota.ID,
+			"firmwareName": adoption.Ota.FirmwareName,
+			"type":         adoption.Ota.Type,
+			"version":      adoption.Ota.Version,
+			"installed":    adoption.Installed,
+			"total":        adoption.Total,
+			"adoptionRate": adoptionRate,
+			"offered":      offered,
+			"outcomes":     outcomes,
+			"stuckDevices": stuckDevices,
+		})
+	}
+
+	dataStruct, err := structpb.NewStruct(map[string]interface{}{
+		"total": len(list),
+		"list":  list,
+	})
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  "构建响应数据失败: " + err.Error(),
+		}, nil
+	}
+
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+		Data: dataStruct,
+	}, nil
+}
+
+// firmwareHistoryToVO 转换为VO
+func firmwareHistoryToVO(history *biz.FirmwareHistory) map[string]interface{} {
+	vo := map[string]interface{}{
+		"id":          history.ID,
+		"deviceId":    history.DeviceID,
+		"macAddress":  history.MacAddress,
+		"board":       history.Board,
+		"fromVersion": history.FromVersion,
+		"toVersion":   history.ToVersion,
+		"otaId":       history.OtaID,
+		"outcome":     history.Outcome,
+		"attempts":    history.Attempts,
+		"createdAt":   history.CreatedAt.Format(time.DateTime),
+		"updatedAt":   history.UpdatedAt.Format(time.DateTime),
+	}
+	if history.CompletedAt != nil {
+		vo["completedAt"] = history.CompletedAt.Format(time.DateTime)
+	}
+	return vo
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/weetime/agent-matrix/internal/biz"
 	"github.com/weetime/agent-matrix/internal/constant"
+	"github.com/weetime/agent-matrix/internal/kit/cerrors"
 	"github.com/weetime/agent-matrix/internal/middleware"
 	pb "github.com/weetime/agent-matrix/protos/v1"
 
@@ -25,7 +27,7 @@ func handleCORS(w http.ResponseWriter, r *http.Request, allowedMethods string, a
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", allowedMethods)
 	w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
-	w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Type")
+	w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Type, Content-Range, Accept-Ranges, ETag")
 	w.Header().Set("Access-Control-Max-Age", "3600")
 
 	// 处理OPTIONS预检请求
@@ -37,9 +39,10 @@ func handleCORS(w http.ResponseWriter, r *http.Request, allowedMethods string, a
 }
 
 // DownloadOtaHandler 处理固件下载的HTTP handler
+// 支持Range/If-Range断点续传，下载链接在有效期内可重复使用，仅从头开始的下载计入下载次数
 func (s *OtaService) DownloadOtaHandler(w http.ResponseWriter, r *http.Request) {
-	// 只允许GET请求
-	if r.Method != "GET" {
+	// 只允许GET和HEAD请求
+	if r.Method != "GET" && r.Method != "HEAD" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
@@ -60,29 +63,55 @@ func (s *OtaService) DownloadOtaHandler(w http.ResponseWriter, r *http.Request)
 
 	ctx := r.Context()
 
-	// 调用biz层下载固件
-	fileData, filename, err := s.uc.DownloadOta(ctx, uuidStr)
+	// 调用biz层打开固件
+	download, err := s.uc.OpenOtaDownload(ctx, uuidStr)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("下载失败: %v", err), http.StatusNotFound)
 		return
 	}
-	if fileData == nil || len(fileData) == 0 {
-		http.Error(w, "固件文件不存在", http.StatusNotFound)
-		return
+	defer download.Object.Close()
+
+	if countsAsDownload(r, download.ETag) {
+		if err := s.uc.CountOtaDownload(ctx, uuidStr); err != nil {
+			status := http.StatusInternalServerError
+			if cerrors.IsPermissionDenied(err) {
+				status = http.StatusForbidden
+			}
+			http.Error(w, fmt.Sprintf("下载失败: %v", err), status)
+			return
+		}
 	}
 
-	// 设置响应头
+	// 设置响应头，Content-Length、Content-Range和206/416状态由ServeContent处理
 	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Length", strconv.Itoa(len(fileData)))
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", download.Filename))
+	w.Header().Set("ETag", download.ETag)
+	http.ServeContent(w, r, download.Filename, download.Object.ModTime(), download.Object)
+}
 
-	// 写入文件数据
-	w.WriteHeader(http.StatusOK)
-	_, err = w.Write(fileData)
-	if err != nil {
-		// 写入失败，但响应头已发送，无法返回错误
-		return
+// countsAsDownload 判断请求是否为一次新的完整下载
+// HEAD和命中If-None-Match的请求不传输固件，仅If-Range匹配当前ETag且从非0位置续传单个区间的请求视为同一次下载，其余请求均计入下载次数
+// 多区间请求可能包含起始位置或后缀区间，一律计入
+func countsAsDownload(r *http.Request, etag string) bool {
+	if r.Method == http.MethodHead {
+		return false
+	}
+	if r.Header.Get("If-None-Match") == etag {
+		return false
 	}
+	if ifRange := r.Header.Get("If-Range"); ifRange == "" || ifRange != etag {
+		return true
+	}
+	spec, ok := strings.CutPrefix(r.Header.Get("Range"), "bytes=")
+	if !ok {
+		return true
+	}
+	if strings.Contains(spec, ",") {
+		return true
+	}
+	start, _, _ := strings.Cut(strings.TrimSpace(spec), "-")
+	offset, err := strconv.ParseInt(start, 10, 64)
+	return err != nil || offset <= 0
 }
 
 // UploadFirmwareHandler 处理固件上传的HTTP handler
@@ -110,6 +139,7 @@ func (s *OtaService) UploadFirmwareHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	// 验证Token并获取用户信息
+	ctx = middleware.WithClientInfo(ctx, middleware.ClientInfoFromRequest(r))
 	user, err := s.tokenService.GetUserByToken(ctx, token)
 	if err != nil || user == nil {
 		response := &pb.Response{
@@ -137,11 +167,11 @@ func (s *OtaService) UploadFirmwareHandler(w http.ResponseWriter, r *http.Reques
 	// 将用户信息存储到Context中
 	ctx = context.WithValue(ctx, middleware.UserDetailKey, user)
 
-	// 检查是否为超级管理员
-	if user.SuperAdmin != 1 {
+	// 自定义HTTP路由不经过权限中间件，在此校验固件管理权限
+	if !user.HasPermission(middleware.PermissionOTAWrite) {
 		response := &pb.Response{
 			Code: 403,
-			Msg:  "需要超级管理员权限",
+			Msg:  "无权限操作",
 		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusForbidden)
@@ -275,7 +305,11 @@ func (s *OtaService) CheckOTAVersionHandler(w http.ResponseWriter, r *http.Reque
 	s.writeJSONResponse(w, response, http.StatusOK)
 }
 
-// ActivateDeviceHandler 处理 POST /ota/activate - 快速检查激活状态
+// activatePayloadMaxSize /ota/activate 请求体大小上限
+const activatePayloadMaxSize = 4 << 10
+
+// ActivateDeviceHandler 处理 POST /ota/activate - 校验设备挑战应答并返回激活状态
+// 请求体为设备对挑战码的签名：{"algorithm":"hmac-sha256","serial_number":"...","challenge":"...","hmac":"..."}
 func (s *OtaService) ActivateDeviceHandler(w http.ResponseWriter, r *http.Request) {
 	// 只允许POST请求
 	if r.Method != "POST" {
@@ -288,10 +322,31 @@ func (s *OtaService) ActivateDeviceHandler(w http.ResponseWriter, r *http.Reques
 	// 从Header获取Device-Id
 	deviceId := r.Header.Get("Device-Id")
 
+	// 解析挑战应答，未携带签名的设备请求体为空或{}
+	payload := &biz.ActivationPayload{}
+	body, err := io.ReadAll(io.LimitReader(r.Body, activatePayloadMaxSize))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, payload); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
 	// 调用service层内部方法
-	activated, err := s.activateDeviceInternal(ctx, deviceId)
+	activated, err := s.activateDeviceInternal(ctx, deviceId, payload)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		switch {
+		case cerrors.IsInvalidInput(err):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case cerrors.IsPermissionDenied(err):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
 		return
 	}
 
@@ -378,7 +433,7 @@ func RegisterOtaHTTPHandlers(srv *kratoshttp.Server, otaService *OtaService) {
 
 	// 3. OTA固件管理相关路由（静态路由放在前面）
 	srv.HandlePrefix("/otaMag/download", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if handleCORS(w, r, "GET, OPTIONS", "Content-Type") {
+		if handleCORS(w, r, "GET, HEAD, OPTIONS", "Content-Type, Range, If-Range") {
 			return
 		}
 		otaService.DownloadOtaHandler(w, r)
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCountsAsDownload(t *testing.T) {
+	const etag = `"abc"`
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    bool
+	}{
+		{name: "完整下载", want: true},
+		{name: "HEAD请求", method: http.MethodHead, want: false},
+		{name: "命中If-None-Match", headers: map[string]string{"If-None-Match": etag}, want: false},
+		{name: "续传", headers: map[string]string{"Range": "bytes=100-", "If-Range": etag}, want: false},
+		{name: "续传指定结束位置", headers: map[string]string{"Range": "bytes=100-199", "If-Range": etag}, want: false},
+		{name: "多区间请求", headers: map[string]string{"Range": "bytes=100-199, 300-", "If-Range": etag}, want: true},
+		{name: "多区间包含起始位置", headers: map[string]string{"Range": "bytes=100-199, 0-99", "If-Range": etag}, want: true},
+		{name: "多区间包含后缀范围", headers: map[string]string{"Range": "bytes=100-199, -100", "If-Range": etag}, want: true},
+		{name: "未携带If-Range的续传", headers: map[string]string{"Range": "bytes=100-"}, want: true},
+		{name: "If-Range不匹配", headers: map[string]string{"Range": "bytes=100-", "If-Range": `"old"`}, want: true},
+		{name: "从0开始", headers: map[string]string{"Range": "bytes=0-", "If-Range": etag}, want: true},
+		{name: "前导0", headers: map[string]string{"Range": "bytes=00-", "If-Range": etag}, want: true},
+		{name: "后缀范围", headers: map[string]string{"Range": "bytes=-100", "If-Range": etag}, want: true},
+		{name: "非字节范围", headers: map[string]string{"Range": "items=100-", "If-Range": etag}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "/otaMag/download/id", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := countsAsDownload(r, etag); got != tt.want {
+				t.Errorf("countsAsDownload() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/kit/cerrors"
+	"github.com/weetime/agent-matrix/internal/middleware"
+	pb "github.com/weetime/agent-matrix/protos/v1"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ListOtaRollouts 查询灰度发布计划
+func (s *OtaService) ListOtaRollouts(ctx context.Context, req *pb.ListOtaRolloutsRequest) (*pb.Response, error) {
+	rollouts, err := s.uc.ListRollouts(ctx, req.GetType().GetValue())
+	if err != nil {
+		return otaErrorResponse(err), nil
+	}
+
+	now := time.Now()
+	list := make([]interface{}, 0, len(rollouts))
+	for _, rollout := range rollouts {
+		list = append(list, rolloutToVO(rollout, now))
+	}
+	dataStruct, err := structpb.NewStruct(map[string]interface{}{
+		"total": len(list),
+		"list":  list,
+	})
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  "构建响应数据失败: " + err.Error(),
+		}, nil
+	}
+
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+		Data: dataStruct,
+	}, nil
+}
+
+// CreateOtaRollout 创建灰度发布计划
+func (s *OtaService) CreateOtaRollout(ctx context.Context, req *pb.CreateOtaRolloutRequest) (*pb.Response, error) {
+	currentUserId, err := middleware.GetUserIdFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "未授权",
+		}, nil
+	}
+
+	rollout := rolloutFromScope(req.GetScope())
+	rollout.OtaID = req.GetOtaId()
+	rollout.Creator = currentUserId
+	rollout, err = s.uc.CreateRollout(ctx, rollout)
+	if err != nil {
+		return otaErrorResponse(err), nil
+	}
+	return rolloutResponse(rollout)
+}
+
+// GetOtaRollout 获取灰度发布计划详情
+func (s *OtaService) GetOtaRollout(ctx context.Context, req *pb.GetOtaRolloutRequest) (*pb.Response, error) {
+	rollout, err := s.uc.GetRollout(ctx, req.GetId())
+	if err != nil {
+		return otaErrorResponse(err), nil
+	}
+	return rolloutResponse(rollout)
+}
+
+// UpdateOtaRollout 修改灰度发布计划的范围和比例
+func (s *OtaService) UpdateOtaRollout(ctx context.Context, req *pb.UpdateOtaRolloutRequest) (*pb.Response, error) {
+	rollout, err := s.uc.UpdateRollout(ctx, req.GetId(), rolloutFromScope(req.GetScope()))
+	if err != nil {
+		return otaErrorResponse(err), nil
+	}
+	return rolloutResponse(rollout)
+}
+
+// ChangeOtaRolloutStatus 暂停、恢复、全量或回滚灰度发布
+func (s *OtaService) ChangeOtaRolloutStatus(ctx context.Context, req *pb.ChangeOtaRolloutStatusRequest) (*pb.Response, error) {
+	rollout, err := s.uc.ChangeRolloutStatus(ctx, req.GetId(), req.GetAction(), req.GetRollbackOtaId())
+	if err != nil {
+		return otaErrorResponse(err), nil
+	}
+	return rolloutResponse(rollout)
+}
+
+// rolloutFromScope 将请求中的灰度范围转换为灰度发布计划
+func rolloutFromScope(scope *pb.OtaRolloutScope) *biz.OtaRollout {
+	return &biz.OtaRollout{
+		Percentage:      scope.GetPercentage(),
+		StartPercentage: scope.GetStartPercentage(),
+		RampMinutes:     scope.GetRampMinutes(),
+		MacAddresses:    scope.GetMacAddresses(),
+		UserID:          scope.GetUserId(),
+		AgentID:         scope.GetAgentId(),
+	}
+}
+
+// rolloutResponse 构建灰度发布计划详情响应
+func rolloutResponse(rollout *biz.OtaRollout) (*pb.Response, error) {
+	dataStruct, err := structpb.NewStruct(rolloutToVO(rollout, time.Now()))
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  "构建响应数据失败: " + err.Error(),
+		}, nil
+	}
+
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+		Data: dataStruct,
+	}, nil
+}
+
+// otaErrorResponse 按错误类型返回对应的响应码
+func otaErrorResponse(err error) *pb.Response {
+	code := int32(500)
+	switch {
+	case cerrors.IsInvalidInput(err):
+		code = 400
+	case cerrors.IsNotFound(err):
+		code = 404
+	}
+	return &pb.Response{
+		Code: code,
+		Msg:  err.Error(),
+	}
+}
+
+// rolloutToVO 转换为VO，effectivePercentage为当前生效的灰度比例
+func rolloutToVO(rollout *biz.OtaRollout, now time.Time) map[string]interface{} {
+	macAddresses := make([]interface{}, 0, len(rollout.MacAddresses))
+	for _, macAddress := range rollout.MacAddresses {
+		macAddresses = append(macAddresses, macAddress)
+	}
+
+	vo := map[string]interface{}{
+		"id":                  rollout.ID,
+		"otaId":               rollout.OtaID,
+		"type":                rollout.Type,
+		"percentage":          rollout.Percentage,
+		"startPercentage":     rollout.StartPercentage,
+		"rampMinutes":         rollout.RampMinutes,
+		"effectivePercentage": rollout.EffectivePercentage(now),
+		"macAddresses":        macAddresses,
+		"agentId":             rollout.AgentID,
+		"status":              rollout.Status,
+		"rollbackOtaId":       rollout.RollbackOtaID,
+		"startedAt":           rollout.StartedAt.Format(time.DateTime),
+		"createdAt":           rollout.CreatedAt.Format(time.DateTime),
+		"updatedAt":           rollout.UpdatedAt.Format(time.DateTime),
+	}
+	if rollout.UserID > 0 {
+		vo["userId"] = strconv.FormatInt(rollout.UserID, 10)
+	}
+	if rollout.PausedAt != nil {
+		vo["pausedAt"] = rollout.PausedAt.Format(time.DateTime)
+	}
+	if rollout.Creator > 0 {
+		vo["creator"] = strconv.FormatInt(rollout.Creator, 10)
+	}
+	return vo
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+
+	pb "github.com/weetime/agent-matrix/protos/v1"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// VerifyFirmware 校验固件文件的SHA-256和签名
+func (s *OtaService) VerifyFirmware(ctx context.Context, req *pb.VerifyFirmwareRequest) (*pb.Response, error) {
+	result, err := s.uc.VerifyFirmware(ctx, req.GetId())
+	if err != nil {
+		return otaErrorResponse(err), nil
+	}
+
+	dataStruct, err := structpb.NewStruct(map[string]interface{}{
+		"otaId":          result.OtaID,
+		"sha256":         result.Sha256,
+		"actualSha256":   result.ActualSha256,
+		"size":           result.Size,
+		"actualSize":     result.ActualSize,
+		"digestMatch":    result.DigestMatch,
+		"signed":         result.Signed,
+		"signatureValid": result.SignatureValid,
+		"keyId":          result.KeyID,
+		"keyRetired":     result.KeyRetired,
+	})
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  "构建响应数据失败: " + err.Error(),
+		}, nil
+	}
+
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+		Data: dataStruct,
+	}, nil
+}
+
+// ListOtaSigningKeys 查询当前和已轮换的固件签名公钥
+func (s *OtaService) ListOtaSigningKeys(ctx context.Context, req *pb.ListOtaSigningKeysRequest) (*pb.Response, error) {
+	keys, err := s.uc.ListSigningKeys(ctx)
+	if err != nil {
+		return otaErrorResponse(err), nil
+	}
+
+	list := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		list = append(list, map[string]interface{}{
+			"keyId":     key.KeyID,
+			"publicKey": key.PublicKey,
+			"retired":   key.Retired,
+		})
+	}
+	dataStruct, err := structpb.NewStruct(map[string]interface{}{
+		"list": list,
+	})
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  "构建响应数据失败: " + err.Error(),
+		}, nil
+	}
+
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+		Data: dataStruct,
+	}, nil
+}
+
+// RotateOtaSigningKey 轮换固件签名密钥，可选用新密钥重新签名全部固件
+func (s *OtaService) RotateOtaSigningKey(ctx context.Context, req *pb.RotateOtaSigningKeyRequest) (*pb.Response, error) {
+	key, resigned, err := s.uc.RotateSigningKey(ctx, req.GetResign())
+	if err != nil {
+		return otaErrorResponse(err), nil
+	}
+
+	dataStruct, err := structpb.NewStruct(map[string]interface{}{
+		"keyId":     key.KeyID,
+		"publicKey": key.PublicKey,
+		"resigned":  resigned,
+	})
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  "构建响应数据失败: " + err.Error(),
+		}, nil
+	}
+
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+		Data: dataStruct,
+	}, nil
+}
@@ -155,9 +155,6 @@ func (s *SysParamsService) SaveSysParams(ctx context.Context, req *pb.SaveSysPar
 		}, nil
 	}
 
-	// 清除配置缓存
-	s.uc.ClearConfigCache(ctx)
-
 	data := map[string]interface{}{
 		"id":         fmt.Sprintf("%d", created.ID),
 		"paramCode":  created.ParamCode,
@@ -199,9 +196,6 @@ func (s *SysParamsService) UpdateSysParams(ctx context.Context, req *pb.UpdateSy
 		}, nil
 	}
 
-	// 清除配置缓存
-	s.uc.ClearConfigCache(ctx)
-
 	return &pb.Response{
 		Code: 0,
 		Msg:  "success",
@@ -226,9 +220,6 @@ func (s *SysParamsService) DeleteSysParams(ctx context.Context, req *pb.DeleteSy
 		}, nil
 	}
 
-	// 清除配置缓存
-	s.uc.ClearConfigCache(ctx)
-
 	return &pb.Response{
 		Code: 0,
 		Msg:  "success",
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"github.com/weetime/agent-matrix/internal/middleware"
+	pb "github.com/weetime/agent-matrix/protos/v1"
+)
+
+// OperationPermissions 全部接口所需的权限，由PermissionMiddleware按操作名统一校验
+// 未列出的接口直接拒绝；公开接口标记为PermissionPublic，只要求登录的标记为PermissionAuthenticated
+var OperationPermissions = map[string]string{
+	// 公开接口，路径在AuthMiddleware白名单中
+	pb.UserService_Login_FullMethodName:                middleware.PermissionPublic,
+	pb.UserService_LoginTwoFactor_FullMethodName:       middleware.PermissionPublic,
+	pb.UserService_LoginTwoFactorEnroll_FullMethodName: middleware.PermissionPublic,
+	pb.UserService_Register_FullMethodName:             middleware.PermissionPublic,
+	pb.UserService_GetPubConfig_FullMethodName:         middleware.PermissionPublic,
+	pb.UserService_GetCaptcha_FullMethodName:           middleware.PermissionPublic,
+	pb.UserService_SendSMSVerification_FullMethodName:  middleware.PermissionPublic,
+	pb.UserService_RetrievePassword_FullMethodName:     middleware.PermissionPublic,
+	pb.UserService_OIDCAuthorize_FullMethodName:        middleware.PermissionPublic,
+	pb.UserService_OIDCCallback_FullMethodName:         middleware.PermissionPublic,
+	pb.AgentService_PlayAudio_FullMethodName:           middleware.PermissionPublic,
+	pb.VoiceCloneService_PlayVoice_FullMethodName:      middleware.PermissionPublic,
+	pb.OtaService_GetOTAHealth_FullMethodName:          middleware.PermissionPublic,
+	pb.OtaService_CheckOTAVersion_FullMethodName:       middleware.PermissionPublic,
+	pb.OtaService_ActivateDevice_FullMethodName:        middleware.PermissionPublic,
+	pb.OtaService_DownloadOta_FullMethodName:           middleware.PermissionPublic,
+
+	// 当前用户的账号、会话和双因素认证
+	pb.UserService_GetUserInfo_FullMethodName:             middleware.PermissionAuthenticated,
+	pb.UserService_ChangePassword_FullMethodName:          middleware.PermissionAuthenticated,
+	pb.UserService_ListSessions_FullMethodName:            middleware.PermissionAuthenticated,
+	pb.UserService_RevokeSession_FullMethodName:           middleware.PermissionAuthenticated,
+	pb.UserService_RevokeOtherSessions_FullMethodName:     middleware.PermissionAuthenticated,
+	pb.UserService_GetTwoFactorStatus_FullMethodName:      middleware.PermissionAuthenticated,
+	pb.UserService_EnrollTwoFactor_FullMethodName:         middleware.PermissionAuthenticated,
+	pb.UserService_EnableTwoFactor_FullMethodName:         middleware.PermissionAuthenticated,
+	pb.UserService_DisableTwoFactor_FullMethodName:        middleware.PermissionAuthenticated,
+	pb.UserService_RegenerateRecoveryCodes_FullMethodName: middleware.PermissionAuthenticated,
+
+	// 用户自己的智能体、声纹和聊天记录，由业务层按所有者校验
+	pb.AgentService_ListUserAgents_FullMethodName:                middleware.PermissionAuthenticated,
+	pb.AgentService_GetAgentById_FullMethodName:                  middleware.PermissionAuthenticated,
+	pb.AgentService_CreateAgent_FullMethodName:                   middleware.PermissionAuthenticated,
+	pb.AgentService_UpdateAgent_FullMethodName:                   middleware.PermissionAuthenticated,
+	pb.AgentService_DeleteAgent_FullMethodName:                   middleware.PermissionAuthenticated,
+	pb.AgentService_GetAgentTemplates_FullMethodName:             middleware.PermissionAuthenticated,
+	pb.AgentService_GetAgentSessions_FullMethodName:              middleware.PermissionAuthenticated,
+	pb.AgentService_GetAgentChatHistory_FullMethodName:           middleware.PermissionAuthenticated,
+	pb.AgentService_GetRecentFiftyUserChats_FullMethodName:       middleware.PermissionAuthenticated,
+	pb.AgentService_GetContentByAudioId_FullMethodName:           middleware.PermissionAuthenticated,
+	pb.AgentService_GetAudioDownloadID_FullMethodName:            middleware.PermissionAuthenticated,
+	pb.AgentService_GetAgentMcpAddress_FullMethodName:            middleware.PermissionAuthenticated,
+	pb.AgentService_GetAgentMcpTools_FullMethodName:              middleware.PermissionAuthenticated,
+	pb.AgentService_RetrieveAgentKnowledge_FullMethodName:        middleware.PermissionAuthenticated,
+	pb.AgentService_ListAgentVoicePrints_FullMethodName:          middleware.PermissionAuthenticated,
+	pb.AgentService_CreateAgentVoicePrint_FullMethodName:         middleware.PermissionAuthenticated,
+	pb.AgentService_UpdateAgentVoicePrint_FullMethodName:         middleware.PermissionAuthenticated,
+	pb.AgentService_DeleteAgentVoicePrint_FullMethodName:         middleware.PermissionAuthenticated,
+	pb.AgentService_UpdateAgentMemoryByMacAddress_FullMethodName: middleware.PermissionAuthenticated,
+
+	// 用户自己的设备、分组和设备转移
+	pb.DeviceService_GetUserDevices_FullMethodName:           middleware.PermissionAuthenticated,
+	pb.DeviceService_BindDevice_FullMethodName:               middleware.PermissionAuthenticated,
+	pb.DeviceService_UnbindDevice_FullMethodName:             middleware.PermissionAuthenticated,
+	pb.DeviceService_RegisterDevice_FullMethodName:           middleware.PermissionAuthenticated,
+	pb.DeviceService_ManualAddDevice_FullMethodName:          middleware.PermissionAuthenticated,
+	pb.DeviceService_UpdateDeviceInfo_FullMethodName:         middleware.PermissionAuthenticated,
+	pb.DeviceService_ForwardToMqttGateway_FullMethodName:     middleware.PermissionAuthenticated,
+	pb.DeviceService_BatchAssignAgent_FullMethodName:         middleware.PermissionAuthenticated,
+	pb.DeviceService_BatchSetAutoUpdate_FullMethodName:       middleware.PermissionAuthenticated,
+	pb.DeviceService_BatchUnbindDevices_FullMethodName:       middleware.PermissionAuthenticated,
+	pb.DeviceService_ImportDeviceAliases_FullMethodName:      middleware.PermissionAuthenticated,
+	pb.DeviceService_ListDeviceGroups_FullMethodName:         middleware.PermissionAuthenticated,
+	pb.DeviceService_CreateDeviceGroup_FullMethodName:        middleware.PermissionAuthenticated,
+	pb.DeviceService_UpdateDeviceGroup_FullMethodName:        middleware.PermissionAuthenticated,
+	pb.DeviceService_DeleteDeviceGroup_FullMethodName:        middleware.PermissionAuthenticated,
+	pb.DeviceService_GetDeviceGroupDevices_FullMethodName:    middleware.PermissionAuthenticated,
+	pb.DeviceService_AddDeviceGroupDevices_FullMethodName:    middleware.PermissionAuthenticated,
+	pb.DeviceService_RemoveDeviceGroupDevices_FullMethodName: middleware.PermissionAuthenticated,
+	pb.DeviceService_ListDeviceTransfers_FullMethodName:      middleware.PermissionAuthenticated,
+	pb.DeviceService_CreateDeviceTransfer_FullMethodName:     middleware.PermissionAuthenticated,
+	pb.DeviceService_AcceptDeviceTransfer_FullMethodName:     middleware.PermissionAuthenticated,
+	pb.DeviceService_RejectDeviceTransfer_FullMethodName:     middleware.PermissionAuthenticated,
+	pb.DeviceService_CancelDeviceTransfer_FullMethodName:     middleware.PermissionAuthenticated,
+
+	// 用户自己的知识库
+	pb.DatasetService_PageDatasets_FullMethodName:          middleware.PermissionAuthenticated,
+	pb.DatasetService_GetDataset_FullMethodName:            middleware.PermissionAuthenticated,
+	pb.DatasetService_CreateDataset_FullMethodName:         middleware.PermissionAuthenticated,
+	pb.DatasetService_UpdateDataset_FullMethodName:         middleware.PermissionAuthenticated,
+	pb.DatasetService_DeleteDataset_FullMethodName:         middleware.PermissionAuthenticated,
+	pb.DatasetService_BatchDeleteDatasets_FullMethodName:   middleware.PermissionAuthenticated,
+	pb.DatasetService_GetRAGModels_FullMethodName:          middleware.PermissionAuthenticated,
+	pb.DatasetService_PageDocuments_FullMethodName:         middleware.PermissionAuthenticated,
+	pb.DatasetService_PageDocumentsByStatus_FullMethodName: middleware.PermissionAuthenticated,
+	pb.DatasetService_UploadDocument_FullMethodName:        middleware.PermissionAuthenticated,
+	pb.DatasetService_DeleteDocument_FullMethodName:        middleware.PermissionAuthenticated,
+	pb.DatasetService_ParseDocuments_FullMethodName:        middleware.PermissionAuthenticated,
+	pb.DatasetService_CancelParseDocuments_FullMethodName:  middleware.PermissionAuthenticated,
+	pb.DatasetService_RetryParseDocuments_FullMethodName:   middleware.PermissionAuthenticated,
+	pb.DatasetService_ListParseJobs_FullMethodName:         middleware.PermissionAuthenticated,
+	pb.DatasetService_ListChunks_FullMethodName:            middleware.PermissionAuthenticated,
+	pb.DatasetService_RetrievalTest_FullMethodName:         middleware.PermissionAuthenticated,
+
+	// 用户自己的声音克隆和音色资源
+	pb.VoiceCloneService_PageVoiceClone_FullMethodName:           middleware.PermissionAuthenticated,
+	pb.VoiceCloneService_UploadVoice_FullMethodName:              middleware.PermissionAuthenticated,
+	pb.VoiceCloneService_CloneAudio_FullMethodName:               middleware.PermissionAuthenticated,
+	pb.VoiceCloneService_UpdateVoiceCloneName_FullMethodName:     middleware.PermissionAuthenticated,
+	pb.VoiceCloneService_GetAudioId_FullMethodName:               middleware.PermissionAuthenticated,
+	pb.VoiceCloneService_PageVoiceResource_FullMethodName:        middleware.PermissionAuthenticated,
+	pb.VoiceCloneService_GetVoiceResourceByUserId_FullMethodName: middleware.PermissionAuthenticated,
+
+	// 配置智能体时使用的模型和字典选项
+	pb.ModelService_GetModelNames_FullMethodName:           middleware.PermissionAuthenticated,
+	pb.ModelService_GetLlmModelNames_FullMethodName:        middleware.PermissionAuthenticated,
+	pb.ModelService_GetModelVoices_FullMethodName:          middleware.PermissionAuthenticated,
+	pb.ModelService_GetPluginNames_FullMethodName:          middleware.PermissionAuthenticated,
+	pb.SysDictDataService_GetDictDataByType_FullMethodName: middleware.PermissionAuthenticated,
+
+	// 模型配置、供应器和音色
+	pb.ModelService_PageModelProvider_FullMethodName:    middleware.PermissionModelRead,
+	pb.ModelService_GetModelProviderList_FullMethodName: middleware.PermissionModelRead,
+	pb.ModelService_PageModelConfig_FullMethodName:      middleware.PermissionModelRead,
+	pb.ModelService_GetModelConfig_FullMethodName:       middleware.PermissionModelRead,
+	pb.ModelService_AddModelProvider_FullMethodName:     middleware.PermissionModelWrite,
+	pb.ModelService_EditModelProvider_FullMethodName:    middleware.PermissionModelWrite,
+	pb.ModelService_DeleteModelProvider_FullMethodName:  middleware.PermissionModelWrite,
+	pb.ModelService_AddModelConfig_FullMethodName:       middleware.PermissionModelWrite,
+	pb.ModelService_EditModelConfig_FullMethodName:      middleware.PermissionModelWrite,
+	pb.ModelService_DeleteModelConfig_FullMethodName:    middleware.PermissionModelWrite,
+	pb.ModelService_EnableModelConfig_FullMethodName:    middleware.PermissionModelWrite,
+	pb.ModelService_SetDefaultModel_FullMethodName:      middleware.PermissionModelWrite,
+	pb.TtsVoiceService_PageTtsVoice_FullMethodName:      middleware.PermissionModelRead,
+	pb.TtsVoiceService_SaveTtsVoice_FullMethodName:      middleware.PermissionModelWrite,
+	pb.TtsVoiceService_UpdateTtsVoice_FullMethodName:    middleware.PermissionModelWrite,
+	pb.TtsVoiceService_DeleteTtsVoice_FullMethodName:    middleware.PermissionModelWrite,
+
+	// 智能体模板
+	pb.AgentService_GetAgentTemplatePage_FullMethodName:      middleware.PermissionTemplateRead,
+	pb.AgentService_GetAgentTemplateById_FullMethodName:      middleware.PermissionTemplateRead,
+	pb.AgentService_CreateAgentTemplate_FullMethodName:       middleware.PermissionTemplateWrite,
+	pb.AgentService_UpdateAgentTemplate_FullMethodName:       middleware.PermissionTemplateWrite,
+	pb.AgentService_DeleteAgentTemplate_FullMethodName:       middleware.PermissionTemplateWrite,
+	pb.AgentService_BatchDeleteAgentTemplates_FullMethodName: middleware.PermissionTemplateWrite,
+	pb.AgentService_ListAllAgents_FullMethodName:             middleware.PermissionAgentRead,
+
+	// 音色资源
+	pb.VoiceCloneService_GetTtsPlatforms_FullMethodName:     middleware.PermissionVoiceRead,
+	pb.VoiceCloneService_GetVoiceResource_FullMethodName:    middleware.PermissionVoiceRead,
+	pb.VoiceCloneService_SaveVoiceResource_FullMethodName:   middleware.PermissionVoiceWrite,
+	pb.VoiceCloneService_DeleteVoiceResource_FullMethodName: middleware.PermissionVoiceWrite,
+
+	// OTA固件、灰度发布和签名密钥
+	pb.OtaService_PageOta_FullMethodName:                middleware.PermissionOTARead,
+	pb.OtaService_GetOta_FullMethodName:                 middleware.PermissionOTARead,
+	pb.OtaService_GetDownloadUrl_FullMethodName:         middleware.PermissionOTARead,
+	pb.OtaService_VerifyFirmware_FullMethodName:         middleware.PermissionOTARead,
+	pb.OtaService_PageFirmwareHistory_FullMethodName:    middleware.PermissionOTARead,
+	pb.OtaService_GetFirmwareAdoption_FullMethodName:    middleware.PermissionOTARead,
+	pb.OtaService_ListOtaRollouts_FullMethodName:        middleware.PermissionOTARead,
+	pb.OtaService_GetOtaRollout_FullMethodName:          middleware.PermissionOTARead,
+	pb.OtaService_ListOtaSigningKeys_FullMethodName:     middleware.PermissionOTARead,
+	pb.OtaService_SaveOta_FullMethodName:                middleware.PermissionOTAWrite,
+	pb.OtaService_UpdateOta_FullMethodName:              middleware.PermissionOTAWrite,
+	pb.OtaService_DeleteOta_FullMethodName:              middleware.PermissionOTAWrite,
+	pb.OtaService_UploadFirmware_FullMethodName:         middleware.PermissionOTAWrite,
+	pb.OtaService_CreateOtaRollout_FullMethodName:       middleware.PermissionOTAWrite,
+	pb.OtaService_UpdateOtaRollout_FullMethodName:       middleware.PermissionOTAWrite,
+	pb.OtaService_ChangeOtaRolloutStatus_FullMethodName: middleware.PermissionOTAWrite,
+	pb.OtaService_RotateOtaSigningKey_FullMethodName:    middleware.PermissionOTAWrite,
+
+	// 字典
+	pb.SysDictTypeService_PageSysDictType_FullMethodName:   middleware.PermissionDictRead,
+	pb.SysDictTypeService_GetSysDictType_FullMethodName:    middleware.PermissionDictRead,
+	pb.SysDictTypeService_SaveSysDictType_FullMethodName:   middleware.PermissionDictWrite,
+	pb.SysDictTypeService_UpdateSysDictType_FullMethodName: middleware.PermissionDictWrite,
+	pb.SysDictTypeService_DeleteSysDictType_FullMethodName: middleware.PermissionDictWrite,
+	pb.SysDictDataService_PageSysDictData_FullMethodName:   middleware.PermissionDictRead,
+	pb.SysDictDataService_GetSysDictData_FullMethodName:    middleware.PermissionDictRead,
+	pb.SysDictDataService_SaveSysDictData_FullMethodName:   middleware.PermissionDictWrite,
+	pb.SysDictDataService_UpdateSysDictData_FullMethodName: middleware.PermissionDictWrite,
+	pb.SysDictDataService_DeleteSysDictData_FullMethodName: middleware.PermissionDictWrite,
+
+	// 系统参数
+	pb.SysParamsService_PageSysParams_FullMethodName:   middleware.PermissionParamsRead,
+	pb.SysParamsService_GetSysParams_FullMethodName:    middleware.PermissionParamsRead,
+	pb.SysParamsService_SaveSysParams_FullMethodName:   middleware.PermissionParamsWrite,
+	pb.SysParamsService_UpdateSysParams_FullMethodName: middleware.PermissionParamsWrite,
+	pb.SysParamsService_DeleteSysParams_FullMethodName: middleware.PermissionParamsWrite,
+
+	// 用户、设备和服务端管理
+	pb.AdminService_PageAdminUsers_FullMethodName:    middleware.PermissionUserRead,
+	pb.AdminService_ChangeUserStatus_FullMethodName:  middleware.PermissionUserWrite,
+	pb.AdminService_UnlockLogin_FullMethodName:       middleware.PermissionUserWrite,
+	pb.AdminService_ResetUserPassword_FullMethodName: middleware.PermissionUserWrite,
+	pb.AdminService_DeleteUser_FullMethodName:        middleware.PermissionUserWrite,
+	pb.AdminService_PageAdminDevices_FullMethodName:  middleware.PermissionDeviceRead,
+	pb.AdminService_GetAdminDevice_FullMethodName:    middleware.PermissionDeviceRead,
+	pb.AdminService_GetServerList_FullMethodName:     middleware.PermissionServerRead,
+	pb.AdminService_GetServerStatus_FullMethodName:   middleware.PermissionServerRead,
+	pb.AdminService_ListNodes_FullMethodName:         middleware.PermissionServerRead,
+	pb.AdminService_EmitServerAction_FullMethodName:  middleware.PermissionServerWrite,
+	pb.AdminService_KickNode_FullMethodName:          middleware.PermissionServerWrite,
+
+	// 语音服务通过server.secret拉取配置，API Key不区分用户，登录用户需要服务端权限
+	pb.ConfigService_GetServerConfig_FullMethodName: middleware.PermissionServerRead,
+	pb.ConfigService_GetAgentModels_FullMethodName:  middleware.PermissionServerRead,
+	pb.ApiKeyService_List_FullMethodName:            middleware.PermissionServerRead,
+	pb.ApiKeyService_TotalCount_FullMethodName:      middleware.PermissionServerRead,
+	pb.ApiKeyService_Create_FullMethodName:          middleware.PermissionServerWrite,
+
+	// 角色
+	pb.AdminService_ListPermissions_FullMethodName: middleware.PermissionRoleRead,
+	pb.AdminService_ListRoles_FullMethodName:       middleware.PermissionRoleRead,
+	pb.AdminService_GetUserRoles_FullMethodName:    middleware.PermissionRoleRead,
+	pb.AdminService_CreateRole_FullMethodName:      middleware.PermissionRoleWrite,
+	pb.AdminService_UpdateRole_FullMethodName:      middleware.PermissionRoleWrite,
+	pb.AdminService_DeleteRole_FullMethodName:      middleware.PermissionRoleWrite,
+	pb.AdminService_AssignUserRoles_FullMethodName: middleware.PermissionRoleWrite,
+}
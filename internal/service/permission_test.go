@@ -0,0 +1,40 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/weetime/agent-matrix/internal/middleware"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// TestOperationPermissionsExhaustive 每个RPC都必须声明所需权限，未声明的接口会被PermissionMiddleware拒绝
+func TestOperationPermissionsExhaustive(t *testing.T) {
+	operations := make(map[string]bool)
+	protoregistry.GlobalFiles.RangeFilesByPackage("v1", func(file protoreflect.FileDescriptor) bool {
+		services := file.Services()
+		for i := 0; i < services.Len(); i++ {
+			methods := services.Get(i).Methods()
+			for j := 0; j < methods.Len(); j++ {
+				method := methods.Get(j)
+				operations[fmt.Sprintf("/%s/%s", services.Get(i).FullName(), method.Name())] = true
+			}
+		}
+		return true
+	})
+	require.NotEmpty(t, operations)
+
+	for operation := range operations {
+		required, ok := OperationPermissions[operation]
+		require.True(t, ok, "接口未配置权限: %s", operation)
+		valid := required == middleware.PermissionPublic || required == middleware.PermissionAuthenticated ||
+			(required != middleware.PermissionAll && middleware.IsValidPermission(required))
+		require.True(t, valid, "接口权限无效: %s -> %s", operation, required)
+	}
+	for operation := range OperationPermissions {
+		require.True(t, operations[operation], "权限配置了不存在的接口: %s", operation)
+	}
+}
@@ -2,8 +2,10 @@ package service
 
 import (
 	"github.com/weetime/agent-matrix/internal/kit"
+	"github.com/weetime/agent-matrix/internal/kit/cerrors"
 
 	v1 "github.com/weetime/agent-matrix/protos/v1"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 func apiToPageRequest(to *kit.PageRequest, from *v1.PageRequest) {
@@ -17,3 +19,38 @@ func apiToPageRequest(to *kit.PageRequest, from *v1.PageRequest) {
 		to.Way = &kit.PageRequest_PageNo{PageNo: int(x.PageNo)}
 	}
 }
+
+// responseWithData 构建带数据的成功响应
+func responseWithData(data map[string]interface{}) *v1.Response {
+	dataStruct, err := structpb.NewStruct(data)
+	if err != nil {
+		return &v1.Response{
+			Code: 500,
+			Msg:  "构建响应数据失败: " + err.Error(),
+		}
+	}
+	return &v1.Response{
+		Code: 0,
+		Msg:  "success",
+		Data: dataStruct,
+	}
+}
+
+// responseFromError 按错误类型转换响应码
+func responseFromError(err error) *v1.Response {
+	code := int32(500)
+	switch {
+	case cerrors.IsInvalidInput(err):
+		code = 400
+	case cerrors.IsPermissionDenied(err):
+		code = 403
+	case cerrors.IsNotFound(err):
+		code = 404
+	case cerrors.IsAlreadyExists(err):
+		code = 409
+	}
+	return &v1.Response{
+		Code: code,
+		Msg:  err.Error(),
+	}
+}
@@ -129,6 +129,9 @@ func (s *UserService) Login(ctx context.Context, req *pb.LoginRequest) (*pb.Resp
 	if err != nil {
 		return nil, err
 	}
+	if tokenDTO.TwoFactor != nil {
+		return twoFactorChallengeResponse(tokenDTO.TwoFactor), nil
+	}
 
 	// 参考Java版本：Result<TokenDTO>，data直接包含token、expire、clientHash字段
 	data := map[string]interface{}{
@@ -205,6 +208,10 @@ func (s *UserService) GetUserInfo(ctx context.Context, req *pb.GetUserInfoReques
 		"status":     userDetail.Status,
 		"token":      userDetail.Token,
 	}
+	if user, err := middleware.GetUserFromContext(ctx); err == nil {
+		data["roles"] = stringsToVO(user.Roles)
+		data["permissions"] = stringsToVO(user.Permissions)
+	}
 
 	dataStruct, err := structpb.NewStruct(data)
 	if err != nil {
@@ -328,6 +335,7 @@ func (s *UserService) GetPubConfig(ctx context.Context, req *pb.GetPubConfigRequ
 		"beianGaNum":           beianGaNum,
 		"name":                 name,
 		"sm2PublicKey":         sm2PublicKey,
+		"oidcEnabled":          s.uc.OIDCEnabled(),
 	}
 
 	// 获取system-web.menu参数配置
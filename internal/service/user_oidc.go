@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+
+	pb "github.com/weetime/agent-matrix/protos/v1"
+)
+
+// OIDCAuthorize 获取跳转身份提供方的单点登录地址
+func (s *UserService) OIDCAuthorize(ctx context.Context, req *pb.OIDCAuthorizeRequest) (*pb.Response, error) {
+	authorization, err := s.uc.StartOIDCLogin(ctx)
+	if err != nil {
+		return responseFromError(err), nil
+	}
+	return responseWithData(map[string]interface{}{
+		"authorizationUrl": authorization.URL,
+		"state":            authorization.State,
+	}), nil
+}
+
+// OIDCCallback 用授权码完成单点登录，启用了双因素认证时返回登录凭证
+func (s *UserService) OIDCCallback(ctx context.Context, req *pb.OIDCCallbackRequest) (*pb.Response, error) {
+	tokenDTO, err := s.uc.CompleteOIDCLogin(ctx, req.GetState(), req.GetCode())
+	if err != nil {
+		return responseFromError(err), nil
+	}
+	if tokenDTO.TwoFactor != nil {
+		return twoFactorChallengeResponse(tokenDTO.TwoFactor), nil
+	}
+
+	return responseWithData(map[string]interface{}{
+		"token":      tokenDTO.Token,
+		"expire":     tokenDTO.Expire,
+		"clientHash": tokenDTO.ClientHash,
+	}), nil
+}
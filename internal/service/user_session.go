@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/kit/cerrors"
+	"github.com/weetime/agent-matrix/internal/middleware"
+	pb "github.com/weetime/agent-matrix/protos/v1"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ListSessions 获取当前用户的登录会话
+func (s *UserService) ListSessions(ctx context.Context, req *pb.ListSessionsRequest) (*pb.Response, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
+
+	sessions, err := s.uc.ListSessions(ctx, user.ID, user.Token)
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  err.Error(),
+		}, nil
+	}
+
+	list := make([]interface{}, 0, len(sessions))
+	for _, session := range sessions {
+		list = append(list, userSessionToVO(session))
+	}
+	dataStruct, err := structpb.NewStruct(map[string]interface{}{
+		"list": list,
+	})
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  "构建响应数据失败: " + err.Error(),
+		}, nil
+	}
+
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+		Data: dataStruct,
+	}, nil
+}
+
+// RevokeOtherSessions 使当前会话以外的全部会话失效
+func (s *UserService) RevokeOtherSessions(ctx context.Context, req *pb.RevokeOtherSessionsRequest) (*pb.Response, error) {
+	user, err := middleware.GetUserFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
+
+	revoked, err := s.uc.RevokeOtherSessions(ctx, user.ID, user.Token)
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  err.Error(),
+		}, nil
+	}
+
+	dataStruct, err := structpb.NewStruct(map[string]interface{}{
+		"revoked": revoked,
+	})
+	if err != nil {
+		return &pb.Response{
+			Code: 500,
+			Msg:  "构建响应数据失败: " + err.Error(),
+		}, nil
+	}
+
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+		Data: dataStruct,
+	}, nil
+}
+
+// RevokeSession 使指定会话失效
+func (s *UserService) RevokeSession(ctx context.Context, req *pb.RevokeSessionRequest) (*pb.Response, error) {
+	userID, err := middleware.GetUserIdFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
+
+	if err := s.uc.RevokeSession(ctx, userID, req.GetId()); err != nil {
+		code := int32(500)
+		if cerrors.IsNotFound(err) {
+			code = 404
+		}
+		return &pb.Response{
+			Code: code,
+			Msg:  err.Error(),
+		}, nil
+	}
+
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+	}, nil
+}
+
+// userSessionToVO 转换为VO，ID字段格式化为字符串
+func userSessionToVO(session *biz.UserSession) map[string]interface{} {
+	vo := map[string]interface{}{
+		"id":         fmt.Sprintf("%d", session.ID),
+		"userAgent":  session.UserAgent,
+		"ip":         session.IP,
+		"current":    session.Current,
+		"expireDate": session.ExpireDate.Format(time.DateTime),
+	}
+	if !session.CreateDate.IsZero() {
+		vo["createDate"] = session.CreateDate.Format(time.DateTime)
+	}
+	if session.LastActiveAt != nil {
+		vo["lastActiveAt"] = session.LastActiveAt.Format(time.DateTime)
+	}
+	return vo
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/weetime/agent-matrix/internal/biz"
+	"github.com/weetime/agent-matrix/internal/middleware"
+	pb "github.com/weetime/agent-matrix/protos/v1"
+)
+
+// LoginTwoFactor 提交动态码或恢复码完成登录
+func (s *UserService) LoginTwoFactor(ctx context.Context, req *pb.LoginTwoFactorRequest) (*pb.Response, error) {
+	tokenDTO, recoveryCodes, err := s.uc.CompleteTwoFactorLogin(ctx, req.GetTicket(), req.GetCode())
+	if err != nil {
+		return nil, err
+	}
+
+	data := map[string]interface{}{
+		"token":      tokenDTO.Token,
+		"expire":     tokenDTO.Expire,
+		"clientHash": tokenDTO.ClientHash,
+	}
+	// 登录时完成绑定的，恢复码只在此返回一次
+	if len(recoveryCodes) > 0 {
+		data["recoveryCodes"] = stringsToVO(recoveryCodes)
+	}
+	return responseWithData(data), nil
+}
+
+// LoginTwoFactorEnroll 被要求启用双因素认证的用户凭登录凭证获取绑定密钥
+func (s *UserService) LoginTwoFactorEnroll(ctx context.Context, req *pb.LoginTwoFactorEnrollRequest) (*pb.Response, error) {
+	enrollment, err := s.uc.BeginLoginTwoFactorEnrollment(ctx, req.GetTicket())
+	if err != nil {
+		return nil, err
+	}
+	return responseWithData(twoFactorEnrollmentToVO(enrollment)), nil
+}
+
+// GetTwoFactorStatus 获取当前用户的双因素认证状态
+func (s *UserService) GetTwoFactorStatus(ctx context.Context, req *pb.GetTwoFactorStatusRequest) (*pb.Response, error) {
+	userID, err := middleware.GetUserIdFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
+
+	status, err := s.uc.GetTwoFactorStatus(ctx, userID)
+	if err != nil {
+		return responseFromError(err), nil
+	}
+
+	data := map[string]interface{}{
+		"enabled":                status.Enabled,
+		"required":               status.Required,
+		"recoveryCodesRemaining": status.RecoveryCodesRemaining,
+	}
+	if status.EnabledAt != nil {
+		data["enabledAt"] = status.EnabledAt.Format(time.DateTime)
+	}
+	return responseWithData(data), nil
+}
+
+// EnrollTwoFactor 获取绑定密钥，校验动态码后才会启用
+func (s *UserService) EnrollTwoFactor(ctx context.Context, req *pb.EnrollTwoFactorRequest) (*pb.Response, error) {
+	userID, err := middleware.GetUserIdFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
+
+	enrollment, err := s.uc.BeginTwoFactorEnrollment(ctx, userID)
+	if err != nil {
+		return responseFromError(err), nil
+	}
+	return responseWithData(twoFactorEnrollmentToVO(enrollment)), nil
+}
+
+// EnableTwoFactor 校验动态码启用双因素认证，返回只显示一次的恢复码
+func (s *UserService) EnableTwoFactor(ctx context.Context, req *pb.TwoFactorCodeRequest) (*pb.Response, error) {
+	userID, err := middleware.GetUserIdFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
+
+	recoveryCodes, err := s.uc.EnableTwoFactor(ctx, userID, req.GetCode())
+	if err != nil {
+		return responseFromError(err), nil
+	}
+	return responseWithData(map[string]interface{}{
+		"recoveryCodes": stringsToVO(recoveryCodes),
+	}), nil
+}
+
+// DisableTwoFactor 使用动态码或恢复码停用双因素认证
+func (s *UserService) DisableTwoFactor(ctx context.Context, req *pb.TwoFactorCodeRequest) (*pb.Response, error) {
+	userID, err := middleware.GetUserIdFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
+
+	if err := s.uc.DisableTwoFactor(ctx, userID, req.GetCode()); err != nil {
+		return responseFromError(err), nil
+	}
+	return &pb.Response{
+		Code: 0,
+		Msg:  "success",
+	}, nil
+}
+
+// RegenerateRecoveryCodes 使用动态码重新生成恢复码
+func (s *UserService) RegenerateRecoveryCodes(ctx context.Context, req *pb.TwoFactorCodeRequest) (*pb.Response, error) {
+	userID, err := middleware.GetUserIdFromContext(ctx)
+	if err != nil {
+		return &pb.Response{
+			Code: 401,
+			Msg:  "user not authenticated",
+		}, nil
+	}
+
+	recoveryCodes, err := s.uc.RegenerateRecoveryCodes(ctx, userID, req.GetCode())
+	if err != nil {
+		return responseFromError(err), nil
+	}
+	return responseWithData(map[string]interface{}{
+		"recoveryCodes": stringsToVO(recoveryCodes),
+	}), nil
+}
+
+// twoFactorChallengeResponse 密码校验通过但需要双因素认证时的登录响应
+func twoFactorChallengeResponse(challenge *biz.TwoFactorChallenge) *pb.Response {
+	return responseWithData(map[string]interface{}{
+		"twoFactorRequired": true,
+		"twoFactorSetup":    challenge.Setup,
+		"ticket":            challenge.Ticket,
+		"expire":            challenge.ExpiresIn,
+	})
+}
+
+// stringsToVO 转换字符串列表，structpb不支持[]string
+func stringsToVO(values []string) []interface{} {
+	list := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		list = append(list, value)
+	}
+	return list
+}
+
+// twoFactorEnrollmentToVO 转换绑定信息
+func twoFactorEnrollmentToVO(enrollment *biz.TwoFactorEnrollment) map[string]interface{} {
+	return map[string]interface{}{
+		"secret":     enrollment.Secret,
+		"otpauthUri": enrollment.URI,
+	}
+}
@@ -400,28 +400,8 @@ func parseUserID(userIDStr string) (int64, error) {
 	return userId, err
 }
 
-// checkSuperAdminPermission 检查是否为超级管理员
-func (s *VoiceCloneService) checkSuperAdminPermission(ctx context.Context) error {
-	user, err := middleware.GetUserFromContext(ctx)
-	if err != nil {
-		return fmt.Errorf("未授权")
-	}
-	if user.SuperAdmin != 1 {
-		return fmt.Errorf("需要超级管理员权限")
-	}
-	return nil
-}
-
-// GetTtsPlatforms 获取TTS平台列表（超级管理员）
+// GetTtsPlatforms 获取TTS平台列表
 func (s *VoiceCloneService) GetTtsPlatforms(ctx context.Context, req *emptypb.Empty) (*pb.Response, error) {
-	// 权限检查：超级管理员
-	if err := s.checkSuperAdminPermission(ctx); err != nil {
-		return &pb.Response{
-			Code: 403,
-			Msg:  err.Error(),
-		}, nil
-	}
-
 	// 调用modelUsecase获取TTS平台列表
 	platforms, err := s.modelUC.GetTtsPlatforms(ctx)
 	if err != nil {
@@ -549,16 +529,8 @@ func (s *VoiceCloneService) PageVoiceResource(ctx context.Context, req *pb.PageV
 	}, nil
 }
 
-// GetVoiceResource 获取音色资源详情（超级管理员）
+// GetVoiceResource 获取音色资源详情
 func (s *VoiceCloneService) GetVoiceResource(ctx context.Context, req *pb.GetVoiceResourceRequest) (*pb.Response, error) {
-	// 权限检查：超级管理员
-	if err := s.checkSuperAdminPermission(ctx); err != nil {
-		return &pb.Response{
-			Code: 403,
-			Msg:  err.Error(),
-		}, nil
-	}
-
 	id := req.GetId()
 	if id == "" {
 		return &pb.Response{
@@ -601,16 +573,8 @@ func (s *VoiceCloneService) GetVoiceResource(ctx context.Context, req *pb.GetVoi
 	}, nil
 }
 
-// SaveVoiceResource 新增音色资源（超级管理员）
+// SaveVoiceResource 新增音色资源
 func (s *VoiceCloneService) SaveVoiceResource(ctx context.Context, req *pb.SaveVoiceResourceRequest) (*pb.Response, error) {
-	// 权限检查：超级管理员
-	if err := s.checkSuperAdminPermission(ctx); err != nil {
-		return &pb.Response{
-			Code: 403,
-			Msg:  err.Error(),
-		}, nil
-	}
-
 	// 参数验证
 	modelId := req.GetModelId()
 	if modelId == "" {
@@ -651,16 +615,8 @@ func (s *VoiceCloneService) SaveVoiceResource(ctx context.Context, req *pb.SaveV
 	}, nil
 }
 
-// DeleteVoiceResource 删除音色资源（超级管理员）
+// DeleteVoiceResource 删除音色资源
 func (s *VoiceCloneService) DeleteVoiceResource(ctx context.Context, req *pb.DeleteVoiceResourceRequest) (*pb.Response, error) {
-	// 权限检查：超级管理员
-	if err := s.checkSuperAdminPermission(ctx); err != nil {
-		return &pb.Response{
-			Code: 403,
-			Msg:  err.Error(),
-		}, nil
-	}
-
 	// 参数验证
 	ids := req.GetIds()
 	if len(ids) == 0 {
@@ -0,0 +1,14 @@
+-- 智能体绑定知识库迁移：新增智能体与知识库关联表，绑定的知识库通过RAG模块下发给设备
+-- 执行时间：2026-10-17
+
+CREATE TABLE IF NOT EXISTS `ai_agent_dataset` (
+    `id` VARCHAR(32) NOT NULL COMMENT '主键',
+    `agent_id` VARCHAR(32) NOT NULL COMMENT '智能体ID',
+    `dataset_id` VARCHAR(64) NOT NULL COMMENT '知识库ID（RAG侧数据集ID）',
+    `sort` INT NOT NULL DEFAULT 0 COMMENT '排序',
+    `creator` BIGINT COMMENT '创建者ID',
+    `created_at` DATETIME COMMENT '创建时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_agent_dataset` (`agent_id`, `dataset_id`),
+    INDEX `idx_dataset_id` (`dataset_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='智能体知识库关联表';
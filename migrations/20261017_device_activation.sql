@@ -0,0 +1,9 @@
+-- 设备激活校验迁移：新增设备激活密钥参数，设备需用密钥对服务端签发的挑战码计算HMAC-SHA256后才能绑定（不下发给语音服务节点）
+-- 执行时间：2026-10-17
+
+DELETE FROM `sys_params` WHERE param_code IN ('server.activation_secrets', 'server.activation_key_mode', 'server.activation_require_hmac');
+
+INSERT INTO `sys_params` (id, param_code, param_value, value_type, param_type, remark) VALUES
+(612, 'server.activation_secrets', '', 'json', 0, '设备激活密钥，JSON对象：{"型号":"密钥","*":"默认密钥"}，配置了密钥的型号必须通过挑战校验才能绑定'),
+(613, 'server.activation_key_mode', 'board', 'string', 0, '设备激活密钥模式：board-设备烧录型号密钥，device-设备烧录HMAC-SHA256(型号密钥, 小写设备ID)派生的独立密钥'),
+(614, 'server.activation_require_hmac', 'false', 'boolean', 0, '为true时所有设备都必须通过挑战校验才能绑定，未配置密钥的型号将无法绑定');
@@ -0,0 +1,21 @@
+-- 设备固件版本历史迁移：记录OTA下发和设备版本变更，用于统计固件升级率和发现反复下载的设备
+-- 执行时间：2026-10-17
+
+CREATE TABLE IF NOT EXISTS `ai_device_firmware_history` (
+    `id` VARCHAR(32) NOT NULL COMMENT '主键',
+    `device_id` VARCHAR(32) NOT NULL COMMENT '设备ID',
+    `mac_address` VARCHAR(50) NOT NULL COMMENT '设备MAC地址',
+    `board` VARCHAR(50) COMMENT '设备硬件型号（固件类型）',
+    `from_version` VARCHAR(50) COMMENT '升级前版本',
+    `to_version` VARCHAR(50) COMMENT '升级后版本（下发中为下发的目标版本）',
+    `ota_id` VARCHAR(32) COMMENT '下发的固件ID，非OTA升级时为空',
+    `outcome` VARCHAR(20) NOT NULL COMMENT '结果：pending/success/mismatch/superseded/manual',
+    `attempts` INT NOT NULL DEFAULT 0 COMMENT '下发次数（设备仍为升级前版本时每次检查累加）',
+    `completed_at` DATETIME COMMENT '结果确定时间',
+    `created_at` DATETIME COMMENT '创建时间（首次下发或版本变更时间）',
+    `updated_at` DATETIME COMMENT '更新时间（最近一次下发时间）',
+    PRIMARY KEY (`id`),
+    INDEX `idx_device_outcome` (`device_id`, `outcome`),
+    INDEX `idx_mac_address` (`mac_address`),
+    INDEX `idx_ota_outcome` (`ota_id`, `outcome`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='设备固件版本历史表';
@@ -0,0 +1,24 @@
+-- 设备分组迁移：新增设备分组表和分组成员表，支持按分组批量操作设备
+-- 执行时间：2026-10-17
+
+CREATE TABLE IF NOT EXISTS `ai_device_group` (
+    `id` VARCHAR(32) NOT NULL COMMENT '主键',
+    `name` VARCHAR(64) NOT NULL COMMENT '分组名称',
+    `description` VARCHAR(255) COMMENT '分组描述',
+    `user_id` BIGINT NOT NULL DEFAULT 0 COMMENT '所属用户ID，0表示管理员创建的全局分组',
+    `creator` BIGINT COMMENT '创建者ID',
+    `created_at` DATETIME COMMENT '创建时间',
+    `updated_at` DATETIME COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_user_name` (`user_id`, `name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='设备分组表';
+
+CREATE TABLE IF NOT EXISTS `ai_device_group_member` (
+    `id` VARCHAR(32) NOT NULL COMMENT '主键',
+    `group_id` VARCHAR(32) NOT NULL COMMENT '分组ID',
+    `device_id` VARCHAR(32) NOT NULL COMMENT '设备ID',
+    `created_at` DATETIME COMMENT '加入时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_group_device` (`group_id`, `device_id`),
+    INDEX `idx_device_id` (`device_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='设备分组成员表';
@@ -0,0 +1,26 @@
+-- 设备遥测迁移：新增设备遥测表，保存设备每次OTA检查时上报的硬件与网络信息
+-- 执行时间：2026-10-17
+
+CREATE TABLE IF NOT EXISTS `ai_device_telemetry` (
+    `id` VARCHAR(32) NOT NULL COMMENT '主键',
+    `device_id` VARCHAR(32) NOT NULL COMMENT '设备ID',
+    `mac_address` VARCHAR(50) COMMENT 'MAC地址',
+    `board` VARCHAR(50) COMMENT '设备硬件型号',
+    `app_version` VARCHAR(50) COMMENT '固件版本号',
+    `chip_model_name` VARCHAR(50) COMMENT '芯片型号',
+    `flash_size` INT COMMENT 'Flash大小(字节)',
+    `minimum_free_heap_size` INT COMMENT '最小剩余堆内存(字节)',
+    `idf_version` VARCHAR(50) COMMENT 'ESP-IDF版本',
+    `partition_table` TEXT COMMENT '分区表(JSON)',
+    `ota_label` VARCHAR(20) COMMENT '当前运行的OTA分区',
+    `ssid` VARCHAR(64) COMMENT '连接的WiFi名称',
+    `rssi` INT COMMENT 'WiFi信号强度(dBm)',
+    `channel` INT COMMENT 'WiFi信道',
+    `ip` VARCHAR(64) COMMENT '设备IP',
+    `latest` TINYINT(1) NOT NULL DEFAULT 1 COMMENT '是否为设备最新一次上报',
+    `created_at` DATETIME COMMENT '上报时间',
+    PRIMARY KEY (`id`),
+    INDEX `idx_device_created_at` (`device_id`, `created_at`),
+    INDEX `idx_latest_device` (`latest`, `device_id`),
+    INDEX `idx_created_at` (`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='设备遥测表';
@@ -0,0 +1,20 @@
+-- 设备转移迁移：新增设备转移表，设备所有者发起转移，接收方确认后变更设备归属
+-- 执行时间：2026-10-17
+
+CREATE TABLE IF NOT EXISTS `ai_device_transfer` (
+    `id` VARCHAR(32) NOT NULL COMMENT '主键',
+    `device_id` VARCHAR(32) NOT NULL COMMENT '设备ID',
+    `mac_address` VARCHAR(50) COMMENT 'MAC地址',
+    `from_user_id` BIGINT NOT NULL COMMENT '转出用户ID',
+    `to_user_id` BIGINT NOT NULL COMMENT '接收用户ID',
+    `agent_id` VARCHAR(32) COMMENT '接收方指定的智能体ID',
+    `migrate_history` TINYINT(1) NOT NULL DEFAULT 0 COMMENT '是否将设备的聊天记录迁移给接收方',
+    `status` VARCHAR(20) NOT NULL DEFAULT 'pending' COMMENT '状态：pending/accepted/rejected/cancelled/expired',
+    `expires_at` DATETIME NOT NULL COMMENT '过期时间，超时未接受自动失效',
+    `created_at` DATETIME COMMENT '创建时间',
+    `updated_at` DATETIME COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    INDEX `idx_device_status` (`device_id`, `status`),
+    INDEX `idx_from_user_id` (`from_user_id`),
+    INDEX `idx_to_user_id` (`to_user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='设备转移表';
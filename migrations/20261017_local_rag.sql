@@ -0,0 +1,50 @@
+-- 本地知识库适配器迁移：新增文档与切片表，注册local类型的RAG供应器
+-- 执行时间：2026-10-17
+
+-- 1. 创建 ai_rag_document 表
+CREATE TABLE IF NOT EXISTS `ai_rag_document` (
+    `id` VARCHAR(32) NOT NULL COMMENT '文档ID',
+    `dataset_id` VARCHAR(64) NOT NULL COMMENT '知识库ID',
+    `name` VARCHAR(255) NOT NULL COMMENT '文档名称',
+    `file_type` VARCHAR(32) COMMENT '文件类型（扩展名）',
+    `file_size` BIGINT NOT NULL DEFAULT 0 COMMENT '文件大小（字节）',
+    `content` LONGBLOB COMMENT '原始文件内容',
+    `chunk_method` VARCHAR(32) COMMENT '分块方法',
+    `parser_config` JSON COMMENT '解析器配置',
+    `meta_fields` JSON COMMENT '元数据字段',
+    `status` INT NOT NULL DEFAULT 0 COMMENT '解析状态：0未开始 1进行中 2已取消 3已完成 4失败',
+    `run` VARCHAR(16) NOT NULL DEFAULT 'UNSTART' COMMENT '运行状态',
+    `progress` DOUBLE NOT NULL DEFAULT 0 COMMENT '解析进度（0-1）',
+    `progress_msg` TEXT COMMENT '解析进度信息',
+    `chunk_count` BIGINT NOT NULL DEFAULT 0 COMMENT '切片数量',
+    `token_count` BIGINT NOT NULL DEFAULT 0 COMMENT 'Token数量',
+    `creator` BIGINT COMMENT '创建者ID',
+    `created_at` DATETIME COMMENT '创建时间',
+    `updater` BIGINT COMMENT '更新者ID',
+    `updated_at` DATETIME COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    INDEX `idx_ai_rag_document_dataset_status` (`dataset_id`, `status`),
+    INDEX `idx_ai_rag_document_created_at` (`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='本地知识库文档表';
+
+-- 2. 创建 ai_rag_chunk 表
+CREATE TABLE IF NOT EXISTS `ai_rag_chunk` (
+    `id` VARCHAR(32) NOT NULL COMMENT '切片ID',
+    `dataset_id` VARCHAR(64) NOT NULL COMMENT '知识库ID',
+    `document_id` VARCHAR(32) NOT NULL COMMENT '文档ID',
+    `position` INT NOT NULL DEFAULT 0 COMMENT '切片在文档中的序号',
+    `content` TEXT NOT NULL COMMENT '切片内容',
+    `embedding` MEDIUMBLOB COMMENT '向量（float32小端序）',
+    `token_count` INT NOT NULL DEFAULT 0 COMMENT 'Token数量',
+    `created_at` DATETIME COMMENT '创建时间',
+    PRIMARY KEY (`id`),
+    INDEX `idx_ai_rag_chunk_document_position` (`document_id`, `position`),
+    INDEX `idx_ai_rag_chunk_dataset_id` (`dataset_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='本地知识库切片表';
+
+-- 3. 注册本地知识库供应器
+-- embedding_model_id 指向 ai_model_config 中提供 OpenAI 兼容 /embeddings 接口的模型配置
+DELETE FROM `ai_model_provider` WHERE id = 'SYSTEM_RAG_local';
+
+INSERT INTO `ai_model_provider` (id, model_type, provider_code, name, fields, sort) VALUES
+('SYSTEM_RAG_local', 'RAG', 'local', '本地知识库', '[{"key":"embedding_model_id","label":"嵌入模型配置ID","type":"string"},{"key":"embedding_model","label":"嵌入模型名称（可选，覆盖模型配置）","type":"string"},{"key":"chunk_size","label":"切片Token数","type":"number"},{"key":"chunk_overlap","label":"切片重叠Token数","type":"number"}]', 2);
@@ -0,0 +1,21 @@
+-- OTA差分升级迁移：新增固件差分包表，记录相邻版本间生成的bsdiff差分包
+-- 执行时间：2026-10-17
+
+CREATE TABLE IF NOT EXISTS `ai_ota_delta` (
+    `id` VARCHAR(32) NOT NULL COMMENT '主键',
+    `type` VARCHAR(50) NOT NULL COMMENT '固件类型',
+    `from_ota_id` VARCHAR(32) NOT NULL COMMENT '源固件ID（设备当前版本）',
+    `to_ota_id` VARCHAR(32) NOT NULL COMMENT '目标固件ID',
+    `from_version` VARCHAR(50) NOT NULL COMMENT '源版本',
+    `to_version` VARCHAR(50) NOT NULL COMMENT '目标版本',
+    `from_sha256` VARCHAR(64) NOT NULL COMMENT '生成时源固件的SHA-256，固件文件变化后差分包失效',
+    `to_sha256` VARCHAR(64) NOT NULL COMMENT '生成时目标固件的SHA-256',
+    `patch_path` VARCHAR(255) COMMENT '差分包存储路径',
+    `size` BIGINT NOT NULL DEFAULT 0 COMMENT '差分包大小(字节)',
+    `sha256` VARCHAR(64) COMMENT '差分包SHA-256',
+    `status` VARCHAR(20) NOT NULL DEFAULT 'ready' COMMENT '状态：ready可下发/skipped差分包过大不下发',
+    `created_at` DATETIME COMMENT '创建时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_from_to` (`from_ota_id`, `to_ota_id`),
+    INDEX `idx_to_ota_id` (`to_ota_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='OTA固件差分包表';
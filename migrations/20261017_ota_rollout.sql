@@ -0,0 +1,24 @@
+-- OTA灰度发布迁移：新增固件灰度发布计划表，同类型固件改为按版本保留多条记录以支持回滚
+-- 执行时间：2026-10-17
+
+CREATE TABLE IF NOT EXISTS `ai_ota_rollout` (
+    `id` VARCHAR(32) NOT NULL COMMENT '主键',
+    `ota_id` VARCHAR(32) NOT NULL COMMENT '灰度发布的目标固件ID',
+    `type` VARCHAR(50) NOT NULL COMMENT '固件类型',
+    `percentage` INT NOT NULL DEFAULT 0 COMMENT '目标灰度比例（0-100）',
+    `start_percentage` INT NOT NULL DEFAULT 0 COMMENT '起始灰度比例（0-100）',
+    `ramp_minutes` INT NOT NULL DEFAULT 0 COMMENT '从起始比例爬升到目标比例的时长（分钟），0表示立即生效',
+    `mac_addresses` JSON COMMENT '指定灰度的设备MAC地址',
+    `user_id` BIGINT COMMENT '限定灰度范围的用户ID',
+    `agent_id` VARCHAR(32) COMMENT '限定灰度范围的智能体ID',
+    `status` VARCHAR(20) NOT NULL DEFAULT 'active' COMMENT '状态：active/paused/completed/rolled_back',
+    `rollback_ota_id` VARCHAR(32) COMMENT '回滚使用的固件ID',
+    `started_at` DATETIME NOT NULL COMMENT '灰度开始时间（恢复时顺延暂停时长）',
+    `paused_at` DATETIME COMMENT '暂停时间',
+    `creator` BIGINT COMMENT '创建者ID',
+    `created_at` DATETIME COMMENT '创建时间',
+    `updated_at` DATETIME COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    INDEX `idx_type_status` (`type`, `status`),
+    INDEX `idx_ota_id` (`ota_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='OTA固件灰度发布计划表';
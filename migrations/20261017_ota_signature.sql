@@ -0,0 +1,13 @@
+-- OTA固件签名迁移：固件记录增加SHA-256和Ed25519签名，新增签名私钥和已轮换公钥参数（不下发给语音服务节点）
+-- 执行时间：2026-10-17
+
+ALTER TABLE `ai_ota`
+    ADD COLUMN `sha256` VARCHAR(64) COMMENT '固件SHA-256（十六进制）' AFTER `firmware_path`,
+    ADD COLUMN `signature` VARCHAR(128) COMMENT '固件签名（Ed25519，Base64）' AFTER `sha256`,
+    ADD COLUMN `sign_key_id` VARCHAR(32) COMMENT '签名公钥标识' AFTER `signature`;
+
+DELETE FROM `sys_params` WHERE param_code IN ('server.ota_signing_key', 'server.ota_signing_retired_keys');
+
+INSERT INTO `sys_params` (id, param_code, param_value, value_type, param_type, remark) VALUES
+(610, 'server.ota_signing_key', '', 'string', 0, 'OTA固件签名私钥（Ed25519，Base64），为空时不签名'),
+(611, 'server.ota_signing_retired_keys', '', 'string', 0, '已轮换的OTA固件签名公钥（Base64），多个用;分隔，仍可用于校验');
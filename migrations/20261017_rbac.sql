@@ -0,0 +1,39 @@
+-- 角色权限迁移：新增角色表和用户角色关联表，预置内置角色，并将现有超级管理员归入admin角色
+-- 执行时间：2026-10-17
+
+CREATE TABLE IF NOT EXISTS `sys_role` (
+    `id` BIGINT NOT NULL COMMENT 'id',
+    `code` VARCHAR(32) NOT NULL COMMENT '角色编码',
+    `name` VARCHAR(64) NOT NULL COMMENT '角色名称',
+    `permissions` TEXT COMMENT '权限编码，逗号分隔',
+    `remark` VARCHAR(255) COMMENT '备注',
+    `builtin` TINYINT(1) NOT NULL DEFAULT 0 COMMENT '是否内置角色',
+    `creator` BIGINT COMMENT '创建者ID',
+    `create_date` DATETIME COMMENT '创建时间',
+    `updater` BIGINT COMMENT '更新者ID',
+    `update_date` DATETIME COMMENT '更新时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_code` (`code`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='角色表';
+
+CREATE TABLE IF NOT EXISTS `sys_user_role` (
+    `id` BIGINT NOT NULL COMMENT 'id',
+    `user_id` BIGINT NOT NULL COMMENT '用户id',
+    `role_id` BIGINT NOT NULL COMMENT '角色id',
+    `create_date` DATETIME NOT NULL COMMENT '创建时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_user_role` (`user_id`, `role_id`),
+    KEY `idx_role_id` (`role_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='用户角色关联表';
+
+-- 内置角色已存在时保留管理员调整过的权限
+INSERT IGNORE INTO `sys_role` (id, code, name, permissions, remark, builtin, create_date, update_date) VALUES
+(1, 'admin', '管理员', '*', '拥有全部权限，原超级管理员归入该角色', 1, NOW(), NOW()),
+(2, 'operator', '运维人员', 'server:*,ota:*,device:read,agent:read,user:read', '管理服务端、语音服务节点和固件发布', 1, NOW(), NOW()),
+(3, 'content_editor', '内容编辑', 'model:*,template:*,voice:*,dict:*', '管理模型配置、智能体模板、音色资源和字典', 1, NOW(), NOW()),
+(4, 'auditor', '审计员', 'model:read,template:read,voice:read,ota:read,dict:read,user:read,device:read,agent:read,server:read,role:read', '只读查看除参数配置外的管理数据', 1, NOW(), NOW()),
+(5, 'viewer', '访客', 'model:read,template:read,voice:read,ota:read,dict:read', '只读查看模型、模板、固件和字典', 1, NOW(), NOW());
+
+-- 现有超级管理员归入admin角色，用户id作为关联id
+INSERT IGNORE INTO `sys_user_role` (id, user_id, role_id, create_date)
+SELECT u.id, u.id, 1, NOW() FROM `sys_user` u WHERE u.super_admin = 1;
@@ -0,0 +1,28 @@
+-- 单点登录迁移：新增用户外部身份关联表和OpenID Connect配置参数，IdP用户标识(issuer+sub)关联到本地用户
+-- 执行时间：2026-10-17
+
+CREATE TABLE IF NOT EXISTS `sys_user_identity` (
+    `id` VARCHAR(32) NOT NULL COMMENT '主键',
+    `user_id` BIGINT NOT NULL COMMENT '用户id',
+    `issuer` VARCHAR(255) NOT NULL COMMENT '身份提供方的Issuer',
+    `subject` VARCHAR(255) NOT NULL COMMENT '身份提供方的用户标识(sub)',
+    `email` VARCHAR(255) COMMENT '身份提供方返回的邮箱',
+    `last_login_at` DATETIME COMMENT '最近一次单点登录时间',
+    `create_date` DATETIME COMMENT '创建时间',
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_issuer_subject` (`issuer`, `subject`),
+    INDEX `idx_user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='用户外部身份关联表';
+
+DELETE FROM `sys_params` WHERE param_code IN ('server.oidc_enabled', 'server.oidc_issuer', 'server.oidc_client_id', 'server.oidc_client_secret', 'server.oidc_redirect_uri', 'server.oidc_scopes', 'server.oidc_username_claim', 'server.oidc_auto_provision', 'server.oidc_link_existing');
+
+INSERT INTO `sys_params` (id, param_code, param_value, value_type, param_type, remark) VALUES
+(619, 'server.oidc_enabled', 'false', 'boolean', 1, '是否开启OpenID Connect单点登录'),
+(620, 'server.oidc_issuer', '', 'string', 1, '单点登录身份提供方的Issuer地址，需支持/.well-known/openid-configuration'),
+(621, 'server.oidc_client_id', '', 'string', 1, '单点登录的Client ID'),
+(622, 'server.oidc_client_secret', '', 'string', 0, '单点登录的Client Secret，公共客户端留空'),
+(623, 'server.oidc_redirect_uri', '', 'string', 1, '单点登录回调地址，需与身份提供方登记的一致，如https://console.example.com/#/oidc-callback'),
+(624, 'server.oidc_scopes', 'openid profile email', 'string', 1, '单点登录申请的scope，空格分隔'),
+(625, 'server.oidc_username_claim', 'preferred_username', 'string', 1, '作为本地用户名的ID Token声明'),
+(626, 'server.oidc_auto_provision', 'false', 'boolean', 1, '为true时首次单点登录的用户自动创建本地账号'),
+(627, 'server.oidc_link_existing', 'false', 'boolean', 1, '为true时首次单点登录关联用户名为已验证邮箱（email_verified）的本地账号，拥有全部权限的账号不自动关联');
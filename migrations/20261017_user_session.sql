@@ -0,0 +1,10 @@
+-- 多会话登录迁移：用户token改为每次登录一条会话记录，绑定客户端指纹并记录访问设备、IP和最近访问时间
+-- 执行时间：2026-10-17
+
+ALTER TABLE `sys_user_token`
+    DROP INDEX `user_id`,
+    ADD INDEX `idx_user_id` (`user_id`),
+    ADD COLUMN `client_hash` VARCHAR(64) COMMENT '客户端指纹摘要，token只能由该客户端使用' AFTER `token`,
+    ADD COLUMN `user_agent` VARCHAR(255) COMMENT '登录时的User-Agent' AFTER `client_hash`,
+    ADD COLUMN `ip` VARCHAR(64) COMMENT '最近访问IP' AFTER `user_agent`,
+    ADD COLUMN `last_active_at` DATETIME COMMENT '最近访问时间' AFTER `ip`;
@@ -0,0 +1,19 @@
+-- 双因素认证迁移：新增用户TOTP绑定表和强制超级管理员启用双因素认证的参数
+-- 执行时间：2026-10-17
+
+CREATE TABLE IF NOT EXISTS `sys_user_totp` (
+    `id` BIGINT NOT NULL COMMENT '用户id',
+    `secret` VARCHAR(64) NOT NULL COMMENT 'Base32编码的TOTP密钥',
+    `enabled` TINYINT(1) NOT NULL DEFAULT 0 COMMENT '是否已启用，绑定时校验通过动态码后启用',
+    `recovery_codes` TEXT COMMENT '未使用的恢复码SHA-256摘要，逗号分隔',
+    `last_used_step` BIGINT NOT NULL DEFAULT 0 COMMENT '最近一次使用的动态码时间步，同一动态码不能重复使用',
+    `enabled_at` DATETIME COMMENT '启用时间',
+    `update_date` DATETIME COMMENT '更新时间',
+    `create_date` DATETIME COMMENT '创建时间',
+    PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='用户双因素认证表';
+
+DELETE FROM `sys_params` WHERE param_code IN ('server.force_super_admin_2fa');
+
+INSERT INTO `sys_params` (id, param_code, param_value, value_type, param_type, remark) VALUES
+(618, 'server.force_super_admin_2fa', 'false', 'boolean', 1, '为true时超级管理员必须启用TOTP双因素认证，未绑定的超级管理员登录时需先完成绑定');